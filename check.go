@@ -0,0 +1,341 @@
+package draco
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/policy"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/cqdetdev/draco/draco/status"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// dryRunTimeout is the maximum duration that obtaining the status of a backend or checking the XBL token may take
+// during a dry run.
+const dryRunTimeout = 5 * time.Second
+
+// Check is the result of a single check of a config or dry run.
+type Check struct {
+	// Name describes what was checked, such as "config: fallback backend".
+	Name string
+	// Err is the reason that the check failed, or nil if it passed.
+	Err error
+}
+
+// checkErr returns an error describing the first of the checks passed that failed, or nil if all passed.
+func checkErr(checks []Check) error {
+	for _, ch := range checks {
+		if ch.Err != nil {
+			return fmt.Errorf("%v: %w", ch.Name, ch.Err)
+		}
+	}
+	return nil
+}
+
+// DryRun checks if a Proxy with the config passed is ready to accept players without creating one: the remote config
+// is obtained if Remote is set, the config is checked using CheckConfig, and the translation tables, the addresses to
+// listen on, the backends and the XBOX Live tokens of the accounts required are checked too. A Check is returned for
+// everything checked.
+func DryRun(c Config) []Check {
+	var checks []Check
+	add := func(name string, err error) {
+		checks = append(checks, Check{Name: name, Err: err})
+	}
+	if c.Remote.Kind != "" {
+		merged, _, err := loadRemoteConfig(c, false)
+		add("remote config from "+c.Remote.URL, err)
+		c = merged
+	}
+	checks = append(checks, CheckConfig(c)...)
+	if err := prepare(c); err != nil {
+		add("messages and caches", err)
+		return checks
+	}
+
+	add("translation tables", draco.SelfTest().Err())
+
+	add("listen on "+c.Connection.LocalAddress, checkUDP(c.Connection.LocalAddress))
+	if c.Guest.Address != "" {
+		add("listen for guests on "+c.Guest.Address, checkUDP(c.Guest.Address))
+	}
+	for _, l := range c.Listeners {
+		add("listen on "+l.Address, checkUDP(l.Address))
+	}
+	for _, s := range [][2]string{{"metrics", c.Metrics.Address}, {"link API", c.Link.Address}, {"ban API", c.Bans.Address}, {"admin API", c.Admin.Address}} {
+		if s[1] != "" {
+			add("serve "+s[0]+" on "+s[1], checkTCP(s[1]))
+		}
+	}
+
+	for _, b := range c.Backends {
+		_, err := status.Foreign{Address: b.Address, Timeout: dryRunTimeout}.Status()
+		add("backend "+b.Name+" ("+b.Address+")", err)
+	}
+
+	for _, name := range requiredAccounts(c) {
+		a, ok := account(c, name)
+		if !ok {
+			// Unknown accounts are reported by CheckConfig.
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), dryRunTimeout)
+		if name == "" {
+			add("XBL token", draco.CheckAccount(ctx, a))
+		} else {
+			add("XBL token of account "+name, draco.CheckAccount(ctx, a))
+		}
+		cancel()
+	}
+	return checks
+}
+
+// CheckConfig checks the settings of the config passed that may be invalid, returning a Check for every setting
+// checked. Checking the permissions of roles, the packet priorities, the middleware and the filters sets them.
+func CheckConfig(c Config) []Check {
+	var checks []Check
+	add := func(name string, err error) {
+		checks = append(checks, Check{Name: name, Err: err})
+	}
+
+	for _, d := range [][2]string{
+		{"status interval", c.Status.Interval},
+		{"status timeout", c.Status.Timeout},
+		{"identity clock skew", c.Identity.ClockSkew},
+		{"link code TTL", c.Link.CodeTTL},
+		{"backend probe interval", c.Network.BackendProbe.Interval},
+		{"backend probe timeout", c.Network.BackendProbe.Timeout},
+		{"memory watch interval", c.Network.MemoryWatch.Interval},
+		{"dial limit max wait", c.Network.DialLimit.MaxWait},
+		{"inventory resync window", c.Network.InventoryResync.Window},
+		{"fallback timeout", c.Fallback.Timeout},
+		{"challenge timeout", c.Challenge.Timeout},
+		{"login timeout", c.Connection.LoginTimeout},
+		{"status cache TTL", c.Status.CacheTTL},
+		{"remote config interval", c.Remote.Interval},
+		{"discovery interval", c.Discovery.Interval},
+		{"telemetry interval", c.Telemetry.Interval},
+		{"chat cooldown", c.Cooldowns.Chat},
+		{"command cooldown", c.Cooldowns.Commands},
+	} {
+		if d[1] != "" {
+			_, err := time.ParseDuration(d[1])
+			add("config: "+d[0], err)
+		}
+	}
+	if c.Connection.DevMode && c.Identity.Verify {
+		add("config: dev mode", errors.New("identities can't be verified with Identity.Verify in dev mode"))
+	}
+	if c.Connection.AuthenticationDisabled && !c.Connection.DevMode && c.Identity.Verify {
+		add("config: authentication disabled", errors.New("identities can't be verified with Identity.Verify if authentication is disabled"))
+	}
+	if c.Bans.Sync.URL != "" {
+		_, err := banSource(c)
+		add("config: ban sync", err)
+	}
+	if c.Remote.Kind != "" {
+		_, err := remoteSource(c)
+		add("config: remote config", err)
+	}
+	if c.Discovery.Kind != "" {
+		_, err := discoveryRegistrar(c)
+		add("config: discovery", err)
+	}
+	if c.Telemetry.URL != "" {
+		var err error
+		if u, e := url.Parse(c.Telemetry.URL); e != nil {
+			err = e
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			err = errors.New("URL must use HTTP or HTTPS")
+		} else if d, e := time.ParseDuration(c.Telemetry.Interval); c.Telemetry.Interval != "" && e == nil && d <= 0 {
+			err = errors.New("interval must be positive")
+		}
+		add("config: telemetry", err)
+	}
+	_, err := policy.Parse(c.Network.DecodePolicy)
+	add("config: decode policy", err)
+	if v := c.Network.AdaptiveView; v.MinRadius < 0 || v.MaxLoss < 0 || v.MaxLoss > 1 || v.MaxInFlight < 0 {
+		add("config: adaptive view", errors.New("MinRadius and MaxInFlight must not be negative and MaxLoss must be between 0 and 1"))
+	}
+	if c.Network.DialLimit.Burst < 0 {
+		add("config: dial limit", errors.New("Burst must not be negative"))
+	}
+	if c.Network.FallbackBlock != "" {
+		add("config: fallback block", checkFallbackBlock(c.Network.FallbackBlock))
+	}
+	_, err = joinObjective(c)
+	add("config: join SLO", err)
+	for _, d := range c.Discord {
+		if d.PollInterval != "" {
+			_, err := time.ParseDuration(d.PollInterval)
+			add("config: discord poll interval", err)
+		}
+	}
+	for _, name := range c.Status.Providers {
+		var err error
+		switch name {
+		case "static", "foreign", "aggregate", "script":
+		default:
+			err = fmt.Errorf("unknown provider %v", name)
+		}
+		add("config: status provider "+name, err)
+	}
+	if c.Status.Override != (status.Override{}) {
+		_, err := status.NewCached(status.Static{}, 0, c.Status.Override).Status()
+		add("config: status override", err)
+	}
+	for _, b := range c.BlockedCommands {
+		for _, name := range b.Roles {
+			var err error
+			if _, ok := proxy.ParseRole(name); !ok {
+				err = fmt.Errorf("unknown role %v", name)
+			}
+			add("config: blocked command "+b.Command, err)
+		}
+	}
+	for _, b := range c.Backends {
+		for _, name := range b.Accounts {
+			var err error
+			if _, ok := account(c, name); !ok || name == "" {
+				err = fmt.Errorf("unknown account %q", name)
+			}
+			add("config: backend "+b.Name+" accounts", err)
+		}
+	}
+	if _, err := parseRoles(c.Cooldowns.Bypass); err != nil {
+		add("config: cooldown bypass", err)
+	}
+	for _, a := range c.Atmospheres {
+		for _, name := range a.Roles {
+			var err error
+			if _, ok := proxy.ParseRole(name); !ok {
+				err = fmt.Errorf("unknown role %v", name)
+			}
+			add("config: atmosphere roles", err)
+		}
+	}
+	for _, m := range c.GameModes {
+		for _, name := range m.Roles {
+			var err error
+			if _, ok := proxy.ParseRole(name); !ok {
+				err = fmt.Errorf("unknown role %v", name)
+			}
+			add("config: game mode roles", err)
+		}
+		var err error
+		if !proxy.ValidGameMode(m.Mode) {
+			err = fmt.Errorf("unknown game mode %v", m.Mode)
+		}
+		add("config: game modes", err)
+	}
+	for _, p := range c.Permissions {
+		r, ok := proxy.ParseRole(p.Role)
+		if !ok {
+			add("config: permissions", fmt.Errorf("unknown role %v", p.Role))
+			continue
+		}
+		add("config: permissions of "+p.Role, proxy.SetPermissions(r, proxy.Permissions{PermissionLevel: p.PermissionLevel, CommandPermissionLevel: p.CommandPermissionLevel}))
+	}
+	if c.Fallback.Backend != "" {
+		err := fmt.Errorf("unknown backend %v", c.Fallback.Backend)
+		for _, b := range c.Backends {
+			if strings.EqualFold(b.Name, c.Fallback.Backend) {
+				err = nil
+			}
+		}
+		add("config: fallback backend", err)
+	}
+	for _, name := range c.Balance.Backends {
+		err := fmt.Errorf("unknown backend %v", name)
+		for _, b := range c.Backends {
+			if strings.EqualFold(b.Name, name) {
+				err = nil
+			}
+		}
+		add("config: balanced backends", err)
+	}
+	for _, l := range c.Listeners {
+		add("config: listener "+l.Address, l.check(c))
+	}
+	add("config: packet priorities", proxy.SetPacketPriorities(packetPriorities(c)))
+	add("config: middleware", proxy.SetMiddleware(c.Network.Middleware.Order, c.Network.Middleware.Disabled))
+	add("config: filters", proxy.SetFilters(c.Filters))
+	add("config: rules", proxy.CheckRules(c.Rules))
+	for i, r := range c.Rules {
+		if r.Backend == "" {
+			continue
+		}
+		err := fmt.Errorf("unknown backend %v", r.Backend)
+		for _, b := range c.Backends {
+			if strings.EqualFold(b.Name, r.Backend) {
+				err = nil
+			}
+		}
+		add(fmt.Sprintf("config: rule %v backend", i+1), err)
+	}
+	if c.ServerSettings.Policy != "" {
+		var err error
+		if _, ok := proxy.ParseSettingsPolicy(c.ServerSettings.Policy); !ok {
+			err = fmt.Errorf("unknown policy %v", c.ServerSettings.Policy)
+		}
+		add("config: server settings policy", err)
+	}
+	if len(c.Cluster.Nodes) > 0 {
+		var err error
+		if c.Admin.Address == "" {
+			err = fmt.Errorf("the admin API must be served to receive migrations")
+		}
+		add("config: cluster", err)
+		for _, n := range c.Cluster.Nodes {
+			_, _, err := net.SplitHostPort(n.Address)
+			if err == nil && n.API == "" {
+				err = fmt.Errorf("no API set")
+			}
+			add("config: cluster node "+n.Name, err)
+		}
+	}
+	return checks
+}
+
+// checkFallbackBlock returns an error if the block with the name passed has no state without properties in the block
+// palette of the latest version or of any version of the protocols registered, as blocks are substituted with it.
+func checkFallbackBlock(name string) error {
+	versions := []state.Version{protocol.CurrentProtocol}
+	for _, p := range draco.Protocols() {
+		versions = append(versions, state.Version(p.ID()))
+	}
+	for _, v := range versions {
+		p, ok := state.PaletteOf(v)
+		if !ok {
+			continue
+		}
+		if _, ok := p.RuntimeID(name, nil); !ok {
+			return fmt.Errorf("block %v has no state without properties in version %v", name, v)
+		}
+	}
+	return nil
+}
+
+// checkUDP checks if a UDP socket may be bound to the address passed, as is done by a listener.
+func checkUDP(address string) error {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// checkTCP checks if a TCP listener may be bound to the address passed, as is done by the HTTP servers.
+func checkTCP(address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+const (
+	// benchTick is the interval at which the simulated clients of the bench command move, which is the interval of a
+	// game tick.
+	benchTick = time.Second / 20
+	// benchRadius is the radius of the circle in blocks that the simulated clients walk around their spawn in.
+	benchRadius = 8
+	// benchSpeed is the speed in blocks per second that the simulated clients walk at, which is that of a walking
+	// player.
+	benchSpeed = 4.3
+)
+
+// benchReport is the report printed by the bench command.
+type benchReport struct {
+	// Clients is the amount of clients that the bench was started with. Joined is the amount of them that spawned,
+	// and Failed and Disconnected the amounts that failed to join and that were disconnected after joining.
+	Clients      int `json:"clients"`
+	Joined       int `json:"joined"`
+	Failed       int `json:"failed"`
+	Disconnected int `json:"disconnected"`
+	// Duration is the duration that the clients were walking around for, in seconds.
+	Duration float64 `json:"duration_seconds"`
+	// Join holds the percentiles of the durations in milliseconds that clients took from dialing to spawning.
+	Join benchPercentiles `json:"join_ms"`
+	// Latency holds the percentiles of the latency in milliseconds of the connections of the clients, sampled
+	// every second.
+	Latency benchPercentiles `json:"latency_ms"`
+	// PacketsIn and PacketsOut are the amounts of packets per second that all clients received and sent together.
+	PacketsIn  float64 `json:"packets_in_per_second"`
+	PacketsOut float64 `json:"packets_out_per_second"`
+	// ProxyCPU is the CPU usage of the proxy process passed with -pid, in percent of a single core, or -1 if no
+	// process was passed. BenchCPU is the CPU usage of the bench command itself, which should stay well below that of
+	// the proxy for the results to be meaningful.
+	ProxyCPU float64 `json:"proxy_cpu_percent"`
+	BenchCPU float64 `json:"bench_cpu_percent"`
+}
+
+// benchPercentiles holds percentiles of a set of samples.
+type benchPercentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// bench runs the bench command with the arguments passed, which joins a running proxy with many simulated clients
+// that walk around their spawn, and reports the throughput and latency they saw and the CPU that the proxy used, so
+// that capacity can be planned and performance regressions measured reproducibly. It returns the exit code of the
+// command, which is 1 if any client failed to join or was disconnected.
+//
+// Without -xbl, the clients join as guests with the names bench0, bench1 and so on, which requires the proxy to
+// accept them, for example with Guest.Address set. With -xbl, all clients join with the XBOX Live account of the
+// proxy, which only works with backends that allow the same player to join more than once.
+func bench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	address := fs.String("address", "127.0.0.1:19132", "address of the proxy listener that the clients join")
+	version := fs.String("version", protocol.CurrentVersion, "version or protocol ID that the clients join with")
+	clients := fs.Int("clients", 10, "amount of clients that join the proxy")
+	ramp := fs.Duration("ramp", time.Millisecond*100, "interval between the joins of two clients")
+	duration := fs.Duration("duration", time.Minute, "duration that the clients walk around for once all of them joined")
+	pid := fs.Int("pid", 0, "process ID of the proxy, if it runs on the same Linux machine, to report its CPU usage")
+	xbl := fs.Bool("xbl", false, "join with the XBOX Live account of the proxy rather than as guests")
+	asJSON := fs.Bool("json", false, "print the report as JSON, for comparing runs")
+	timeout := fs.Duration("timeout", time.Second*30, "maximum duration of a join")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: draco bench [-address host:port] [-version version] [-clients 10] [-ramp 100ms] [-duration 1m] [-pid pid] [-xbl] [-json] [-timeout 30s]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *clients <= 0 {
+		fmt.Println("-clients must be positive")
+		return 2
+	}
+
+	id, err := draco.ParseVersion(*version)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	var p minecraft.Protocol
+	if id != protocol.CurrentProtocol {
+		p, _ = draco.ProtocolByID(id)
+	}
+	if *xbl {
+		if err := draco.InitializeToken(log.New(logging.Writer(logging.LevelWarn), "", 0)); err != nil {
+			fmt.Printf("error signing in to XBOX Live: %v\n", err)
+			return 1
+		}
+	}
+
+	b := &benchRun{address: *address, protocol: p, xbl: *xbl, timeout: *timeout, stop: make(chan struct{})}
+	var wg sync.WaitGroup
+	for i := 0; i < *clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b.client(i)
+		}(i)
+		if i != *clients-1 {
+			time.Sleep(*ramp)
+		}
+	}
+	// Throughput is only measured once all clients joined, so that the chunks sent while joining don't skew it.
+	b.waitJoined(*clients, *timeout)
+	if b.report(*clients, time.Second).Joined == 0 {
+		fmt.Println("no client joined the proxy")
+		return 1
+	}
+	proxyStart, proxyErr := processCPU(strconv.Itoa(*pid))
+	if *pid != 0 && proxyErr != nil {
+		fmt.Printf("error reading CPU usage of proxy: %v\n", proxyErr)
+	}
+	benchStart, _ := processCPU("self")
+	b.resetCounters()
+	start := time.Now()
+
+	done := time.After(*duration)
+	sample := time.NewTicker(time.Second)
+	progress := time.NewTicker(time.Second * 5)
+wait:
+	for {
+		select {
+		case <-sample.C:
+			b.sampleLatency()
+		case <-progress.C:
+			if !*asJSON {
+				fmt.Println(b.progress(time.Since(start)))
+			}
+		case <-done:
+			break wait
+		}
+	}
+	sample.Stop()
+	progress.Stop()
+	elapsed := time.Since(start)
+
+	r := b.report(*clients, elapsed)
+	r.ProxyCPU, r.BenchCPU = -1, -1
+	if *pid != 0 && proxyErr == nil {
+		if proxyEnd, err := processCPU(strconv.Itoa(*pid)); err == nil {
+			r.ProxyCPU = cpuPercent(proxyEnd-proxyStart, elapsed)
+		}
+	}
+	if benchEnd, err := processCPU("self"); err == nil {
+		r.BenchCPU = cpuPercent(benchEnd-benchStart, elapsed)
+	}
+	b.close()
+	wg.Wait()
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(r, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		fmt.Print(r)
+	}
+	if r.Failed > 0 || r.Disconnected > 0 {
+		return 1
+	}
+	return 0
+}
+
+// benchRun holds the state of a run of the bench command, shared by all of its clients.
+type benchRun struct {
+	address  string
+	protocol minecraft.Protocol
+	xbl      bool
+	timeout  time.Duration
+	stop     chan struct{}
+
+	mu                    sync.Mutex
+	conns                 []*minecraft.Conn
+	joins, latencies      []float64
+	failed, disconnected  int
+	packetsIn, packetsOut int64
+}
+
+// client runs the simulated client with the index passed until the run is stopped.
+func (b *benchRun) client(i int) {
+	d := minecraft.Dialer{ErrorLog: log.New(ioutil.Discard, "", 0), Protocol: b.protocol}
+	if b.xbl {
+		d.TokenSource = draco.TokenSrc
+	} else {
+		d.IdentityData = login.IdentityData{DisplayName: "bench" + strconv.Itoa(i)}
+	}
+	start := time.Now()
+	conn, err := d.DialTimeout("raknet", b.address, b.timeout)
+	if err == nil {
+		if err = conn.DoSpawnTimeout(b.timeout); err != nil {
+			_ = conn.Close()
+		}
+	}
+	b.mu.Lock()
+	if err != nil {
+		b.failed++
+		b.mu.Unlock()
+		logging.Default().Warn("bench client failed to join", "client", i, "err", err)
+		return
+	}
+	b.joins = append(b.joins, float64(time.Since(start))/float64(time.Millisecond))
+	select {
+	case <-b.stop:
+		// The client joined after the run was stopped.
+		b.mu.Unlock()
+		_ = conn.Close()
+		return
+	default:
+	}
+	b.conns = append(b.conns, conn)
+	b.mu.Unlock()
+
+	go b.walk(conn)
+	for {
+		_, err := conn.ReadPacket()
+		if err != nil {
+			select {
+			case <-b.stop:
+			default:
+				b.mu.Lock()
+				b.disconnected++
+				b.mu.Unlock()
+				logging.Default().Warn("bench client was disconnected", "client", i, "err", err)
+			}
+			return
+		}
+		b.mu.Lock()
+		b.packetsIn++
+		b.mu.Unlock()
+	}
+}
+
+// close stops the run, closing the connections of all clients.
+func (b *benchRun) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	close(b.stop)
+	for _, conn := range b.conns {
+		_ = conn.Close()
+	}
+}
+
+// walk moves the player of the client passed around its spawn every tick until the run is stopped or the
+// connection is closed.
+func (b *benchRun) walk(conn *minecraft.Conn) {
+	data := conn.GameData()
+	t := time.NewTicker(benchTick)
+	defer t.Stop()
+	var tick uint64
+	for {
+		select {
+		case <-t.C:
+		case <-b.stop:
+			return
+		}
+		tick++
+		pos := walkPosition(data.PlayerPosition, tick)
+		var err error
+		if data.PlayerMovementSettings.MovementType == protocol.PlayerMovementModeClient {
+			err = conn.WritePacket(&packet.MovePlayer{EntityRuntimeID: data.EntityRuntimeID, Position: pos, Mode: packet.MoveModeNormal, OnGround: true, Tick: tick})
+		} else {
+			err = conn.WritePacket(&packet.PlayerAuthInput{Position: pos, InputMode: packet.InputModeMouse, PlayMode: packet.PlayModeNormal, Tick: tick})
+		}
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.packetsOut++
+		b.mu.Unlock()
+	}
+}
+
+// walkPosition returns the position of a simulated client on the tick passed, walking at benchSpeed on a circle
+// with a radius of benchRadius around the spawn passed.
+func walkPosition(spawn mgl32.Vec3, tick uint64) mgl32.Vec3 {
+	angle := float64(tick) * benchSpeed / 20 / benchRadius
+	return spawn.Add(mgl32.Vec3{float32(math.Cos(angle)*benchRadius) - benchRadius, 0, float32(math.Sin(angle) * benchRadius)})
+}
+
+// waitJoined waits until the amount of clients passed either joined or failed to join, or until the timeout passed
+// expired after the join of the last client was started.
+func (b *benchRun) waitJoined(clients int, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		b.mu.Lock()
+		n := len(b.joins) + b.failed
+		b.mu.Unlock()
+		if n >= clients {
+			return
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+}
+
+// resetCounters resets the throughput counters of the run.
+func (b *benchRun) resetCounters() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.packetsIn, b.packetsOut = 0, 0
+}
+
+// sampleLatency samples the latency of the connections of all clients that joined.
+func (b *benchRun) sampleLatency() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, conn := range b.conns {
+		b.latencies = append(b.latencies, float64(conn.Latency())/float64(time.Millisecond))
+	}
+}
+
+// progress returns a line describing the progress of the run, the duration passed after it started measuring.
+func (b *benchRun) progress(elapsed time.Duration) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	seconds := elapsed.Seconds()
+	return fmt.Sprintf("%v: %v clients, %.0f packets/s in, %.0f packets/s out", elapsed.Truncate(time.Second), len(b.conns)-b.disconnected, float64(b.packetsIn)/seconds, float64(b.packetsOut)/seconds)
+}
+
+// report returns the benchReport of the run, which was started with the amount of clients passed and measured for
+// the duration passed.
+func (b *benchRun) report(clients int, elapsed time.Duration) benchReport {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	seconds := elapsed.Seconds()
+	return benchReport{
+		Clients:      clients,
+		Joined:       len(b.joins),
+		Failed:       b.failed,
+		Disconnected: b.disconnected,
+		Duration:     seconds,
+		Join:         percentiles(b.joins),
+		Latency:      percentiles(b.latencies),
+		PacketsIn:    float64(b.packetsIn) / seconds,
+		PacketsOut:   float64(b.packetsOut) / seconds,
+	}
+}
+
+// String ...
+func (r benchReport) String() string {
+	s := fmt.Sprintf("clients: %v joined, %v failed, %v disconnected of %v\n", r.Joined, r.Failed, r.Disconnected, r.Clients)
+	s += fmt.Sprintf("join: %v\n", r.Join)
+	s += fmt.Sprintf("latency: %v\n", r.Latency)
+	s += fmt.Sprintf("throughput: %.0f packets/s in, %.0f packets/s out over %.0fs\n", r.PacketsIn, r.PacketsOut, r.Duration)
+	if r.ProxyCPU >= 0 {
+		s += fmt.Sprintf("proxy CPU: %.1f%%\n", r.ProxyCPU)
+	}
+	if r.BenchCPU >= 0 {
+		s += fmt.Sprintf("bench CPU: %.1f%%\n", r.BenchCPU)
+	}
+	return s
+}
+
+// String ...
+func (p benchPercentiles) String() string {
+	return fmt.Sprintf("p50 %.1fms, p95 %.1fms, p99 %.1fms, max %.1fms", p.P50, p.P95, p.P99, p.Max)
+}
+
+// percentiles returns the benchPercentiles of the samples passed, which are sorted in the process.
+func percentiles(samples []float64) benchPercentiles {
+	if len(samples) == 0 {
+		return benchPercentiles{}
+	}
+	sort.Float64s(samples)
+	at := func(p float64) float64 {
+		return samples[int(math.Ceil(p*float64(len(samples))))-1]
+	}
+	return benchPercentiles{P50: at(0.5), P95: at(0.95), P99: at(0.99), Max: samples[len(samples)-1]}
+}
+
+// clockTicks is the amount of clock ticks per second that the CPU times in /proc are counted in, which is 100 on
+// practically all Linux systems.
+const clockTicks = 100
+
+// processCPU returns the CPU time that the process passed, either a process ID or "self", used so far, as reported
+// by /proc. An error is returned on systems without /proc, such as Windows and macOS.
+func processCPU(pid string) (time.Duration, error) {
+	data, err := ioutil.ReadFile("/proc/" + pid + "/stat")
+	if err != nil {
+		return 0, err
+	}
+	return parseProcStat(string(data))
+}
+
+// parseProcStat parses the CPU time, user and system, from the content of a /proc/<pid>/stat file.
+func parseProcStat(stat string) (time.Duration, error) {
+	// The name of the process is in parentheses and may hold spaces, so fields are counted from the last one.
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, fmt.Errorf("malformed stat %q", stat)
+	}
+	// After the name follow the state, and utime and stime as the 12th and 13th field.
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("malformed stat %q", stat)
+	}
+	utime, err := strconv.ParseInt(fields[11], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse utime: %w", err)
+	}
+	stime, err := strconv.ParseInt(fields[12], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse stime: %w", err)
+	}
+	return time.Duration(utime+stime) * time.Second / clockTicks, nil
+}
+
+// cpuPercent returns the CPU usage in percent of a single core of a process that used the CPU time passed over the
+// duration passed.
+func cpuPercent(cpu, elapsed time.Duration) float64 {
+	return float64(cpu) / float64(elapsed) * 100
+}
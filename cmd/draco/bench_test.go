@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestPercentiles(t *testing.T) {
+	var samples []float64
+	for i := 100; i > 0; i-- {
+		samples = append(samples, float64(i))
+	}
+	p := percentiles(samples)
+	if p.P50 != 50 || p.P95 != 95 || p.P99 != 99 || p.Max != 100 {
+		t.Errorf("unexpected percentiles %+v", p)
+	}
+	if p := percentiles(nil); p != (benchPercentiles{}) {
+		t.Errorf("unexpected percentiles %+v of no samples", p)
+	}
+}
+
+func TestParseProcStat(t *testing.T) {
+	cpu, err := parseProcStat("1234 (draco proxy) S 1 1234 1234 0 -1 4194560 5000 0 0 0 250 50 0 0 20 0 12 0 100 0 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpu != time.Second*3 {
+		t.Errorf("parsed CPU time %v, expected 3s", cpu)
+	}
+	if _, err := parseProcStat("1234 (draco"); err == nil {
+		t.Error("expected an error parsing a malformed stat")
+	}
+}
+
+func TestWalkPosition(t *testing.T) {
+	spawn := mgl32.Vec3{10, 64, 10}
+	if pos := walkPosition(spawn, 0); pos != spawn {
+		t.Fatalf("client starts walking at %v rather than its spawn %v", pos, spawn)
+	}
+	// Over a second, the client walks 4.3 blocks along the circle, which is slightly more than the distance it covers.
+	d := walkPosition(spawn, 20).Sub(walkPosition(spawn, 0)).Len()
+	if d > benchSpeed || d < benchSpeed*0.95 || math.IsNaN(float64(d)) {
+		t.Errorf("client covered %v blocks in a second", d)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/cqdetdev/draco"
+)
+
+// defaultConfigPath is the path of the config file if none is passed using -config.
+const defaultConfigPath = "config.toml"
+
+// configFlags holds the command line flags that select the config file and override its settings, so that the proxy
+// may be deployed in containers without baking a config file into the image.
+type configFlags struct {
+	path                  string
+	localAddr, remoteAddr string
+	dev                   bool
+	set                   settingFlags
+}
+
+// settingFlags holds the settings passed using -set, each as "Section.Setting=value".
+type settingFlags []string
+
+// String ...
+func (s *settingFlags) String() string { return strings.Join(*s, ", ") }
+
+// Set ...
+func (s *settingFlags) Set(v string) error {
+	if !strings.Contains(v, "=") {
+		return fmt.Errorf("expected Section.Setting=value, got %q", v)
+	}
+	*s = append(*s, v)
+	return nil
+}
+
+// register registers the flags of the configFlags in the flag set passed.
+func (f *configFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "config", defaultConfigPath, "path of the config file")
+	fs.StringVar(&f.localAddr, "local-addr", "", "address to accept players on, overriding Connection.LocalAddress")
+	fs.StringVar(&f.remoteAddr, "remote-addr", "", "address of the server to forward players to, overriding Connection.RemoteAddress")
+	fs.BoolVar(&f.dev, "dev", false, "runs without XBOX Live and with fake identities for development, setting Connection.DevMode")
+	fs.Var(&f.set, "set", "overrides a setting of the config file, such as -set Status.ServerName=Draco (may be repeated)")
+}
+
+// overrides returns the overrides of the settings of the config file: those of the DRACO_ environment variables,
+// followed by those of the flags, so that flags take precedence.
+func (f *configFlags) overrides() ([]draco.Override, error) {
+	overrides, err := draco.EnvOverrides(os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	if f.localAddr != "" {
+		overrides = append(overrides, draco.Override{Setting: "Connection.LocalAddress", Value: f.localAddr, Source: "flag -local-addr"})
+	}
+	if f.remoteAddr != "" {
+		overrides = append(overrides, draco.Override{Setting: "Connection.RemoteAddress", Value: f.remoteAddr, Source: "flag -remote-addr"})
+	}
+	if f.dev {
+		overrides = append(overrides, draco.Override{Setting: "Connection.DevMode", Value: "true", Source: "flag -dev"})
+	}
+	for _, kv := range f.set {
+		setting, value, _ := strings.Cut(kv, "=")
+		overrides = append(overrides, draco.Override{Setting: setting, Value: value, Source: "flag -set " + setting})
+	}
+	return overrides, nil
+}
+
+// read reads the config file, running the setup first if the default config file doesn't exist, and writes it back
+// with the defaults of the settings that are not set filled in. The overrides of the environment and flags are
+// applied to the config returned, but never written to the file. If the config file doesn't exist but settings are
+// overridden, the default config with the overrides applied is used without writing a file.
+func (f *configFlags) read() draco.Config {
+	overrides, err := f.overrides()
+	if err != nil {
+		log.Fatalf("error reading overrides: %v", err)
+	}
+	if _, err := os.Stat(f.path); os.IsNotExist(err) {
+		switch {
+		case len(overrides) > 0:
+			// The proxy is configured using overrides only, such as in a container.
+			c, err := draco.DecodeConfigWithOverrides(nil, overrides)
+			if err != nil {
+				log.Fatalf("error applying overrides: %v", err)
+			}
+			return c
+		case f.path != defaultConfigPath:
+			log.Fatalf("config file %v not found", f.path)
+		case !interactive():
+			// An empty config can't forward players anywhere, so the settings needed are asked for instead.
+			log.Fatalf("config.toml not found: run draco setup to create it, or configure the proxy using DRACO_ environment variables")
+		}
+		fmt.Println("config.toml not found, starting the setup.")
+		if code := setup(nil); code != 0 {
+			os.Exit(code)
+		}
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		log.Fatalf("error reading config: %v", err)
+	}
+	c, err := draco.DecodeConfig(data)
+	if err != nil {
+		log.Fatalf("error decoding config: %v", err)
+	}
+	encoded, err := encodeConfig(c)
+	if err != nil {
+		log.Fatalf("error encoding config: %v", err)
+	}
+	if err := ioutil.WriteFile(f.path, encoded, 0644); err != nil {
+		// The config file may be mounted read-only in a container, which is fine as long as it can be read.
+		log.Printf("error writing config file: %v", err)
+	}
+	if c, err = draco.DecodeConfigWithOverrides(data, overrides); err != nil {
+		log.Fatalf("error applying overrides: %v", err)
+	}
+	return c
+}
+
+// load reads and decodes the config file with the overrides of the environment and flags applied, which is how the
+// config is obtained when it is reloaded.
+func (f *configFlags) load() (draco.Config, error) {
+	overrides, err := f.overrides()
+	if err != nil {
+		return draco.Config{}, err
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil && !(os.IsNotExist(err) && len(overrides) > 0) {
+		return draco.Config{}, fmt.Errorf("read config: %w", err)
+	}
+	c, err := draco.DecodeConfigWithOverrides(data, overrides)
+	if err != nil {
+		return draco.Config{}, fmt.Errorf("decode config: %w", err)
+	}
+	return c, nil
+}
+
+// checkConfig checks the config passed, printing every invalid setting and exiting if any is invalid, rather than
+// only the first as the proxy does when it starts. Configs obtained from a remote endpoint are only checked once the
+// remote config is applied, which the proxy does when it starts.
+func checkConfig(c draco.Config) {
+	if c.Remote.Kind != "" {
+		return
+	}
+	var problems []string
+	for _, ch := range draco.CheckConfig(c) {
+		if ch.Err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", ch.Name, ch.Err))
+		}
+	}
+	if len(problems) > 0 {
+		log.Fatalf("invalid config:\n\t%v", strings.Join(problems, "\n\t"))
+	}
+}
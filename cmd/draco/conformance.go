@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/chunk"
+	"github.com/cqdetdev/draco/draco/lang"
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// conformanceStep is a step of the scenario run by the conformance command.
+type conformanceStep struct {
+	name string
+	run  func(h *harness) error
+}
+
+// errSkipped is returned by a conformanceStep that could not run with the flags passed.
+var errSkipped = errors.New("skipped")
+
+// conformanceSteps is the scenario run by the conformance command, in order. Every step expects the packets that a
+// vanilla server sends in response to what the simulated client did, as translated by the proxy.
+var conformanceSteps = []conformanceStep{
+	{name: "join", run: (*harness).join},
+	{name: "move", run: (*harness).move},
+	{name: "break block", run: (*harness).breakBlock},
+	{name: "open chest", run: (*harness).openChest},
+	{name: "chat", run: (*harness).chat},
+}
+
+// conformance runs the conformance command with the arguments passed, which joins a running proxy with a simulated
+// client and runs a scripted scenario against the backend of the proxy, validating the packets that the client
+// receives. It should be run against a vanilla Bedrock Dedicated Server before releasing support for a new game
+// version, once with every version that clients may join with. It returns the exit code of the command.
+//
+// The backend must run with online-mode=false and gamemode=creative, have client authoritative movement and block
+// breaking, and be configured as an Offline backend of the proxy. Without -xbl, the client joins as a guest, which
+// requires Guest.Address to be set, and guests may not chat, so the chat step checks that the proxy stops them.
+func conformance(args []string) int {
+	fs := flag.NewFlagSet("conformance", flag.ExitOnError)
+	address := fs.String("address", "127.0.0.1:19132", "address of the proxy listener that the client joins")
+	version := fs.String("version", protocol.CurrentVersion, "version or protocol ID that the client joins with")
+	chest := fs.String("chest", "", "position x,y,z of a chest that the client opens; the step is skipped if empty")
+	xbl := fs.Bool("xbl", false, "join with the XBOX Live account of the proxy rather than as a guest")
+	timeout := fs.Duration("timeout", time.Second*10, "maximum duration of every step")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: draco conformance [-address host:port] [-version version] [-chest x,y,z] [-xbl] [-timeout 10s]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	id, err := draco.ParseVersion(*version)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	logger := log.New(logging.Writer(logging.LevelWarn), "", 0)
+	h := &harness{address: *address, protocol: id, timeout: *timeout, guest: !*xbl}
+	if *chest != "" {
+		pos, err := parseBlockPos(*chest)
+		if err != nil {
+			fmt.Printf("invalid chest position: %v\n", err)
+			return 2
+		}
+		h.chest = &pos
+	}
+	if *xbl {
+		if err := draco.InitializeToken(logger); err != nil {
+			fmt.Printf("error signing in to XBOX Live: %v\n", err)
+			return 1
+		}
+	}
+	defer h.close()
+
+	code := 0
+	for _, step := range conformanceSteps {
+		switch err := step.run(h); {
+		case errors.Is(err, errSkipped):
+			fmt.Printf("%v: skipped\n", step.name)
+		case err != nil:
+			fmt.Printf("%v: FAIL: %v\n", step.name, err)
+			code = 1
+			if h.conn == nil {
+				// The client never joined, so none of the other steps can run.
+				return code
+			}
+		default:
+			fmt.Printf("%v: ok\n", step.name)
+		}
+	}
+	return code
+}
+
+// harness holds the state of the simulated client of the conformance command.
+type harness struct {
+	address  string
+	protocol int32
+	timeout  time.Duration
+	guest    bool
+	chest    *protocol.BlockPos
+
+	conn *minecraft.Conn
+	data minecraft.GameData
+	// palette is the block palette of the version that the client joined with, which the runtime IDs of blocks sent
+	// to it are in.
+	palette  *state.Palette
+	position mgl32.Vec3
+	tick     uint64
+}
+
+// close closes the connection of the client, if it joined.
+func (h *harness) close() {
+	if h.conn != nil {
+		_ = h.conn.Close()
+	}
+}
+
+// join joins the proxy and checks if the client spawns and receives a chunk that decodes in its version.
+func (h *harness) join() error {
+	var p minecraft.Protocol
+	if h.protocol != protocol.CurrentProtocol {
+		p, _ = draco.ProtocolByID(h.protocol)
+	}
+	palette, ok := state.PaletteOf(state.Version(h.protocol))
+	if !ok {
+		return fmt.Errorf("no block palette registered for protocol %v", h.protocol)
+	}
+	d := minecraft.Dialer{ErrorLog: log.New(logging.Writer(logging.LevelWarn), "", 0), Protocol: p}
+	if !h.guest {
+		d.TokenSource = draco.TokenSrc
+	}
+	conn, err := d.DialTimeout("raknet", h.address, h.timeout)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+	if err := conn.DoSpawnTimeout(h.timeout); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("spawn: %w", err)
+	}
+	h.conn, h.data, h.palette, h.position = conn, conn.GameData(), palette, conn.GameData().PlayerPosition
+
+	air, _ := palette.RuntimeID("minecraft:air", nil)
+	return h.expect("a chunk", func(pk packet.Packet) (bool, error) {
+		c, ok := pk.(*packet.LevelChunk)
+		if !ok {
+			_, ok := pk.(*packet.SubChunk)
+			return ok, nil
+		}
+		if c.SubChunkRequestMode != protocol.SubChunkRequestModeLegacy {
+			return true, nil
+		}
+		if _, err := chunk.NetworkDecode(air, bytes.NewBuffer(c.RawPayload), int(c.SubChunkCount), conformanceRange(h.data.Dimension)); err != nil {
+			return false, fmt.Errorf("decode chunk %v: %w", c.Position, err)
+		}
+		return true, nil
+	})
+}
+
+// move moves the player half a block and checks if the server doesn't move it back.
+func (h *harness) move() error {
+	h.position = h.position.Add(mgl32.Vec3{0.5, 0, 0})
+	if h.data.PlayerMovementSettings.MovementType == protocol.PlayerMovementModeClient {
+		h.write(&packet.MovePlayer{EntityRuntimeID: h.data.EntityRuntimeID, Position: h.position, Mode: packet.MoveModeNormal, OnGround: true})
+	} else {
+		h.tick++
+		h.write(&packet.PlayerAuthInput{Position: h.position, InputMode: packet.InputModeMouse, PlayMode: packet.PlayModeNormal, Tick: h.tick})
+	}
+	err := h.expectFor(time.Second*2, func(pk packet.Packet) (bool, error) {
+		if m, ok := pk.(*packet.MovePlayer); ok && m.EntityRuntimeID == h.data.EntityRuntimeID && m.Mode != packet.MoveModeNormal {
+			return false, fmt.Errorf("server moved the player back to %v", m.Position)
+		}
+		return false, nil
+	})
+	if errors.Is(err, errTimeout) {
+		// The server never moved the player back.
+		return nil
+	}
+	return err
+}
+
+// breakBlock breaks the block below the player and checks if the server sends the client that it was replaced with
+// air, with a runtime ID in the palette of the version of the client.
+func (h *harness) breakBlock() error {
+	if h.data.PlayerGameMode != packet.GameTypeCreative && (h.data.PlayerGameMode != packet.GameTypeDefault || h.data.WorldGameMode != packet.GameTypeCreative) {
+		return fmt.Errorf("player spawned in game mode %v: the backend must have gamemode=creative", h.data.PlayerGameMode)
+	}
+	// The position of the player is that of its eyes, 1.62 blocks above its feet.
+	pos := protocol.BlockPos{int32(math.Floor(float64(h.position[0]))), int32(math.Floor(float64(h.position[1])-1.62)) - 1, int32(math.Floor(float64(h.position[2])))}
+	h.write(&packet.InventoryTransaction{TransactionData: &protocol.UseItemTransactionData{
+		ActionType:    protocol.UseItemActionBreakBlock,
+		BlockPosition: pos,
+		BlockFace:     1,
+		Position:      h.position,
+	}})
+	return h.expect("an update of the block broken", func(pk packet.Packet) (bool, error) {
+		u, ok := pk.(*packet.UpdateBlock)
+		if !ok || u.Position != pos {
+			return false, nil
+		}
+		b, ok := h.palette.State(u.NewBlockRuntimeID)
+		if !ok {
+			return false, fmt.Errorf("block %v updated to runtime ID %v, which doesn't exist in the palette of the client", pos, u.NewBlockRuntimeID)
+		}
+		if b.Name != "minecraft:air" {
+			return false, fmt.Errorf("block %v updated to %v rather than air", pos, b.Name)
+		}
+		return true, nil
+	})
+}
+
+// openChest opens the chest passed with -chest and checks if the server opens its container for the client.
+func (h *harness) openChest() error {
+	if h.chest == nil {
+		return errSkipped
+	}
+	h.write(&packet.InventoryTransaction{TransactionData: &protocol.UseItemTransactionData{
+		ActionType:    protocol.UseItemActionClickBlock,
+		BlockPosition: *h.chest,
+		BlockFace:     1,
+		Position:      h.position,
+	}})
+	return h.expect("the chest to open", func(pk packet.Packet) (bool, error) {
+		c, ok := pk.(*packet.ContainerOpen)
+		if !ok || c.ContainerPosition != *h.chest {
+			return false, nil
+		}
+		h.write(&packet.ContainerClose{WindowID: c.WindowID})
+		return true, nil
+	})
+}
+
+// chat sends a chat message and checks if the server broadcasts it back to the client. Guests may not chat, so for
+// guests, it checks if the proxy tells them so instead.
+func (h *harness) chat() error {
+	msg := "draco conformance " + strconv.FormatInt(time.Now().UnixNano(), 36)
+	h.write(&packet.Text{TextType: packet.TextTypeChat, SourceName: h.conn.IdentityData().DisplayName, Message: msg, XUID: h.conn.IdentityData().XUID})
+	if h.guest {
+		restricted := lang.Translate(h.conn.ClientData().LanguageCode, "guest.restricted")
+		return h.expect("the proxy to stop the guest from chatting", func(pk packet.Packet) (bool, error) {
+			t, ok := pk.(*packet.Text)
+			if ok && strings.Contains(t.Message, msg) {
+				return false, errors.New("chat message of a guest reached the backend")
+			}
+			return ok && t.Message == restricted, nil
+		})
+	}
+	return h.expect("the chat message to be broadcast", func(pk packet.Packet) (bool, error) {
+		t, ok := pk.(*packet.Text)
+		return ok && strings.Contains(t.Message, msg), nil
+	})
+}
+
+// write writes a packet to the proxy.
+func (h *harness) write(pk packet.Packet) {
+	_ = h.conn.WritePacket(pk)
+}
+
+// errTimeout is returned by harness.expectFor if no packet matched before the timeout.
+var errTimeout = errors.New("timed out")
+
+// expect reads packets until the function passed returns true for one of them, returning an error if it returns an
+// error, if the connection is closed or if the timeout of the harness expires.
+func (h *harness) expect(what string, f func(pk packet.Packet) (bool, error)) error {
+	if err := h.expectFor(h.timeout, f); err != nil {
+		if errors.Is(err, errTimeout) {
+			return fmt.Errorf("timed out waiting for %v", what)
+		}
+		return err
+	}
+	return nil
+}
+
+// expectFor reads packets like expect for the duration passed, returning errTimeout once it expires.
+func (h *harness) expectFor(d time.Duration, f func(pk packet.Packet) (bool, error)) error {
+	deadline := time.Now().Add(d)
+	_ = h.conn.SetReadDeadline(deadline)
+	defer h.conn.SetReadDeadline(time.Time{})
+	for {
+		pk, err := h.conn.ReadPacket()
+		if err != nil {
+			if !time.Now().Before(deadline) {
+				return errTimeout
+			}
+			return fmt.Errorf("read packet: %w", err)
+		}
+		if ok, err := f(pk); err != nil || ok {
+			return err
+		}
+	}
+}
+
+// parseBlockPos parses a block position such as "10,64,-20".
+func parseBlockPos(s string) (protocol.BlockPos, error) {
+	var pos protocol.BlockPos
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return pos, fmt.Errorf("%q is not of the form x,y,z", s)
+	}
+	for i, p := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return pos, fmt.Errorf("%q is not of the form x,y,z", s)
+		}
+		pos[i] = int32(v)
+	}
+	return pos, nil
+}
+
+// conformanceRange returns the range of the dimension passed.
+func conformanceRange(dimension int32) cube.Range {
+	switch dimension {
+	case packet.DimensionNether:
+		return cube.Range{0, 127}
+	case packet.DimensionEnd:
+		return cube.Range{0, 255}
+	}
+	return cube.Range{-64, 319}
+}
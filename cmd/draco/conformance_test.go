@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+func TestParseBlockPos(t *testing.T) {
+	pos, err := parseBlockPos("10, 64,-20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != (protocol.BlockPos{10, 64, -20}) {
+		t.Fatalf("parsed %v, expected [10 64 -20]", pos)
+	}
+	for _, s := range []string{"", "1,2", "1,2,x", "1,2,3,4"} {
+		if _, err := parseBlockPos(s); err == nil {
+			t.Fatalf("invalid position %q was parsed", s)
+		}
+	}
+}
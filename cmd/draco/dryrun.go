@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cqdetdev/draco"
+)
+
+// dryRun checks if the proxy is ready to accept players with the config passed without accepting any, printing a
+// report of all checks to stdout. It returns false if any check failed.
+func dryRun(c draco.Config) bool {
+	ready := true
+	for _, ch := range draco.DryRun(c) {
+		if ch.Err != nil {
+			ready = false
+			_, _ = fmt.Fprintf(os.Stdout, "FAIL  %v: %v\n", ch.Name, ch.Err)
+			continue
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "ok    %v\n", ch.Name)
+	}
+	if ready {
+		_, _ = fmt.Fprintln(os.Stdout, "ready to accept players")
+	} else {
+		_, _ = fmt.Fprintln(os.Stdout, "not ready to accept players")
+	}
+	return ready
+}
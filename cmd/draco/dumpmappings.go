@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/item"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// mappingDump holds the runtime ID mapping tables between two versions and the block palettes of both, as written by
+// dump-mappings.
+type mappingDump struct {
+	From, To mappingVersion
+	// Blocks and Items map every block and item runtime ID of the version From to its runtime ID in the version To.
+	Blocks, Items []runtimeIDMapping
+	// Palettes holds the block states of both versions, indexed by their runtime ID.
+	Palettes struct {
+		From, To []state.Block
+	}
+}
+
+// mappingVersion is a version of which the mappings are dumped.
+type mappingVersion struct {
+	Version  string
+	Protocol int32
+}
+
+// runtimeIDMapping maps a runtime ID of one version to that of another. To is nil if the block state or item doesn't
+// exist in the other version, in which case the proxy substitutes it.
+type runtimeIDMapping struct {
+	From       int64
+	To         *int64
+	Name       string
+	Properties map[string]any `json:",omitempty"`
+}
+
+// dumpMappings runs the dump-mappings command with the arguments passed, which writes the block and item runtime ID
+// mapping tables that the proxy translates with from one version to another, along with the block palettes of both
+// versions, so that translations may be verified, versions diffed and the tables reused by other tools. It returns
+// the exit code of the command.
+func dumpMappings(args []string) int {
+	fs := flag.NewFlagSet("dump-mappings", flag.ExitOnError)
+	from := fs.String("from", "", "version or protocol ID that runtime IDs are translated from")
+	to := fs.String("to", "", "version or protocol ID that runtime IDs are translated to")
+	format := fs.String("format", "json", "format of the output: json, or csv holding the mapping tables only")
+	out := fs.String("out", "", "file that the mappings are written to, stdout by default")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: draco dump-mappings -from version -to version [-format json|csv] [-out file]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *from == "" || *to == "" || fs.NArg() > 0 || (*format != "json" && *format != "csv") {
+		fs.Usage()
+		return 2
+	}
+	d, err := buildMappingDump(*from, *to)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Printf("error creating output: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "csv" {
+		err = d.writeCSV(w)
+	} else {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(d)
+	}
+	if err != nil {
+		fmt.Printf("error writing mappings: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// buildMappingDump builds the mappingDump between the versions passed, which are versions, such as "1.18.10", or
+// protocol IDs, such as "486".
+func buildMappingDump(fromVersion, toVersion string) (mappingDump, error) {
+	var d mappingDump
+	var err error
+	if d.From, err = parseMappingVersion(fromVersion); err != nil {
+		return d, err
+	}
+	if d.To, err = parseMappingVersion(toVersion); err != nil {
+		return d, err
+	}
+	from, to := state.Version(d.From.Protocol), state.Version(d.To.Protocol)
+
+	fromStates, ok := state.PaletteOf(from)
+	if !ok {
+		return d, fmt.Errorf("no block palette registered for %v", d.From.Version)
+	}
+	toStates, ok := state.PaletteOf(to)
+	if !ok {
+		return d, fmt.Errorf("no block palette registered for %v", d.To.Version)
+	}
+	d.Palettes.From, d.Palettes.To = paletteStates(fromStates), paletteStates(toStates)
+	for rid, s := range d.Palettes.From {
+		m := runtimeIDMapping{From: int64(rid), Name: s.Name, Properties: s.Properties}
+		if translated, ok := state.TranslateRuntimeID(from, to, uint32(rid)); ok {
+			v := int64(translated)
+			m.To = &v
+		}
+		d.Blocks = append(d.Blocks, m)
+	}
+
+	fromItems, ok := item.PaletteOf(from)
+	if !ok {
+		return d, fmt.Errorf("no item palette registered for %v", d.From.Version)
+	}
+	for _, e := range fromItems.Entries() {
+		m := runtimeIDMapping{From: int64(e.RuntimeID), Name: e.Name}
+		if translated, ok := item.TranslateRuntimeID(from, to, int32(e.RuntimeID)); ok {
+			v := int64(translated)
+			m.To = &v
+		}
+		d.Items = append(d.Items, m)
+	}
+	sort.Slice(d.Items, func(i, j int) bool {
+		return d.Items[i].From < d.Items[j].From
+	})
+	return d, nil
+}
+
+// parseMappingVersion parses the version or protocol ID passed.
+func parseMappingVersion(s string) (mappingVersion, error) {
+	id, err := draco.ParseVersion(s)
+	if err != nil {
+		return mappingVersion{}, err
+	}
+	v := mappingVersion{Version: protocol.CurrentVersion, Protocol: id}
+	for _, p := range draco.Protocols() {
+		if p.ID() == id {
+			v.Version = p.Ver()
+		}
+	}
+	return v, nil
+}
+
+// paletteStates returns all block states of the Palette passed, indexed by their runtime ID.
+func paletteStates(p *state.Palette) []state.Block {
+	states := make([]state.Block, 0, p.Len())
+	for rid := uint32(0); rid < p.Len(); rid++ {
+		s, _ := p.State(rid)
+		states = append(states, s)
+	}
+	return states
+}
+
+// writeCSV writes the block and item mapping tables of the mappingDump to the writer passed as CSV, with a row per
+// runtime ID. The runtime ID translated to is empty for block states and items that don't exist in the other version.
+func (d mappingDump) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"kind", "from", "to", "name", "properties"})
+	for _, table := range []struct {
+		kind     string
+		mappings []runtimeIDMapping
+	}{{"block", d.Blocks}, {"item", d.Items}} {
+		for _, m := range table.mappings {
+			to, properties := "", ""
+			if m.To != nil {
+				to = strconv.FormatInt(*m.To, 10)
+			}
+			if len(m.Properties) > 0 {
+				data, err := json.Marshal(m.Properties)
+				if err != nil {
+					return err
+				}
+				properties = string(data)
+			}
+			_ = cw.Write([]string{table.kind, strconv.FormatInt(m.From, 10), to, m.Name, properties})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
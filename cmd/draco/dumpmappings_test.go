@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/legacymappings"
+)
+
+func TestDumpMappings(t *testing.T) {
+	d, err := buildMappingDump("1.18.30", "486")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.From.Protocol != 503 || d.To.Version != "1.18.10" {
+		t.Errorf("unexpected versions %+v and %+v", d.From, d.To)
+	}
+	if uint32(len(d.Palettes.From)) != latestmappings.StateCount() || uint32(len(d.Palettes.To)) != legacymappings.StateCount() {
+		t.Errorf("palettes of %v and %v block states dumped", len(d.Palettes.From), len(d.Palettes.To))
+	}
+	air, _ := latestmappings.StateToRuntimeID("minecraft:air", nil)
+	legacyAir, _ := legacymappings.StateToRuntimeID("minecraft:air", nil)
+	if m := d.Blocks[air]; m.Name != "minecraft:air" || m.To == nil || *m.To != int64(legacyAir) {
+		t.Errorf("air mapped as %+v, expected %v", m, legacyAir)
+	}
+	if len(d.Items) != len(latestmappings.Items()) {
+		t.Errorf("%v items dumped, expected %v", len(d.Items), len(latestmappings.Items()))
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := d.writeCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1+len(d.Blocks)+len(d.Items) {
+		t.Errorf("%v CSV rows written, expected %v", len(rows), 1+len(d.Blocks)+len(d.Items))
+	}
+
+	if _, err := buildMappingDump("1.18.30", "0.1"); err == nil {
+		t.Error("mappings dumped for an unknown version")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cqdetdev/draco"
+)
+
+// exportWorld runs the export-world command with the arguments passed, which downloads the world made up of the
+// chunks that the running proxy cached of a backend from the admin API and writes it as a world folder, or as a
+// .mcworld file if the output ends with .mcworld. The backend must have CacheChunks set. It returns the exit code of
+// the command.
+func exportWorld(args []string) int {
+	fs := flag.NewFlagSet("export-world", flag.ExitOnError)
+	backend := fs.String("backend", "", "name of the backend of which the world is exported")
+	out := fs.String("out", "", "folder or .mcworld file that the world is written to, by default named after the backend")
+	var conf configFlags
+	conf.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: draco export-world -backend name [-out folder]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *backend == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	if *out == "" {
+		*out = *backend
+	}
+	c := conf.read()
+	if c.Admin.Address == "" {
+		fmt.Println("export-world requires the admin API to be served: set Admin.Address in the config")
+		return 1
+	}
+	data, err := downloadWorld(c, *backend)
+	if err != nil {
+		fmt.Printf("error downloading world: %v\n", err)
+		return 1
+	}
+	if strings.HasSuffix(*out, ".mcworld") {
+		err = ioutil.WriteFile(*out, data, 0644)
+	} else {
+		err = extractWorld(data, *out)
+	}
+	if err != nil {
+		fmt.Printf("error writing world: %v\n", err)
+		return 1
+	}
+	fmt.Printf("exported world of %v to %v\n", *backend, *out)
+	return 0
+}
+
+// downloadWorld downloads the .mcworld file of the backend passed from the admin API of the proxy.
+func downloadWorld(c draco.Config, backend string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, adminURL(c.Admin.Address)+"/export?backend="+url.QueryEscape(backend), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Admin.Secret)
+	resp, err := (&http.Client{Timeout: time.Minute}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v: %v", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// adminURL returns the URL of the admin API served on the address passed. Addresses that listen on all interfaces,
+// such as ":8080", are reached on the loopback interface.
+func adminURL(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "http://" + address
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// extractWorld extracts the .mcworld file passed, a zip archive, into the folder passed.
+func extractWorld(data []byte, dir string) error {
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range z.File {
+		path := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if rel, err := filepath.Rel(dir, path); err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("file %v outside of the world folder", f.Name)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			return err
+		}
+		if err := extractFile(f, path); err != nil {
+			return fmt.Errorf("extract %v: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// extractFile writes the file passed from a zip archive to the path passed.
+func extractFile(f *zip.File, path string) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/chunk"
+	"github.com/cqdetdev/draco/draco/mcdb"
+	"github.com/df-mc/dragonfly/server/block/cube"
+)
+
+func TestAdminURL(t *testing.T) {
+	for address, expected := range map[string]string{
+		":8080":          "http://127.0.0.1:8080",
+		"0.0.0.0:8080":   "http://127.0.0.1:8080",
+		"[::]:8080":      "http://127.0.0.1:8080",
+		"10.0.0.2:8080":  "http://10.0.0.2:8080",
+		"localhost:8080": "http://localhost:8080",
+	} {
+		if u := adminURL(address); u != expected {
+			t.Errorf("admin URL of %v is %v, expected %v", address, u, expected)
+		}
+	}
+}
+
+func TestExtractWorld(t *testing.T) {
+	w := mcdb.World{Name: "lobby", Chunks: []mcdb.Chunk{{Chunk: chunk.New(0, cube.Range{-64, 319})}}}
+	buf := bytes.NewBuffer(nil)
+	if err := w.WriteZip(buf); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := extractWorld(buf.Bytes(), dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"level.dat", "levelname.txt", "db/CURRENT", "db/MANIFEST-000001", "db/000002.log"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("world folder has no %v: %v", name, err)
+		}
+	}
+}
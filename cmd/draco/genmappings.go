@@ -0,0 +1,404 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/biome"
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// genMappings runs the gen-mappings command with the arguments passed, which joins two servers of different versions,
+// usually vanilla Bedrock Dedicated Servers, as a client, captures the items, creative items and biomes they send,
+// and writes candidate mapping tables for both versions along with a report of the differences between them. The
+// candidates are a starting point for supporting a new version and must be reviewed before they are added to a
+// mappings package. It returns the exit code of the command.
+//
+// Both servers must run with online-mode=false, unless -xbl is passed. Packets are decoded using the packet formats
+// of the latest protocol known to gophertunnel, so the StartGame, CreativeContent and BiomeDefinitionList packets of
+// both versions must be encoded the same way as in that protocol.
+func genMappings(args []string) int {
+	fs := flag.NewFlagSet("gen-mappings", flag.ExitOnError)
+	oldAddress := fs.String("old", "", "address of the server running the older version")
+	newAddress := fs.String("new", "", "address of the server running the newer version")
+	oldVersion := fs.String("old-version", protocol.CurrentVersion, "version or protocol ID of the older server")
+	newVersion := fs.String("new-version", protocol.CurrentVersion, "version or protocol ID of the newer server")
+	out := fs.String("out", "mappings", "folder that the tables and the report are written to")
+	xbl := fs.Bool("xbl", false, "join with the XBOX Live account of the proxy")
+	timeout := fs.Duration("timeout", time.Second*30, "maximum duration to wait for a server to send its data")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: draco gen-mappings -old host:port -old-version version -new host:port -new-version version [-out folder] [-xbl] [-timeout 30s]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *oldAddress == "" || *newAddress == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	oldProto, err := parseRawProtocol(*oldVersion)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	newProto, err := parseRawProtocol(*newVersion)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if *xbl {
+		if err := draco.InitializeToken(log.New(logging.Writer(logging.LevelWarn), "", 0)); err != nil {
+			fmt.Printf("error signing in to XBOX Live: %v\n", err)
+			return 1
+		}
+	}
+
+	var snapshots [2]mappingSnapshot
+	for i, s := range []struct {
+		address string
+		proto   rawProtocol
+	}{{*oldAddress, oldProto}, {*newAddress, newProto}} {
+		if snapshots[i], err = captureMappings(s.address, s.proto, *xbl, *timeout); err != nil {
+			fmt.Printf("error capturing the mappings of %v: %v\n", s.address, err)
+			return 1
+		}
+	}
+	if err := writeMappings(*out, snapshots[0], snapshots[1]); err != nil {
+		fmt.Printf("error writing mappings: %v\n", err)
+		return 1
+	}
+	fmt.Printf("wrote the mappings of protocols %v and %v to %v: review report.txt before using them\n", oldProto.id, newProto.id, *out)
+	return 0
+}
+
+// rawProtocol is a minecraft.Protocol that announces a protocol ID without translating any packets, so that the data
+// sent by a server is captured exactly as it sends it, even if draco doesn't support its version yet.
+type rawProtocol struct {
+	id  int32
+	ver string
+}
+
+// ID ...
+func (p rawProtocol) ID() int32 {
+	return p.id
+}
+
+// Ver ...
+func (p rawProtocol) Ver() string {
+	return p.ver
+}
+
+// Packets ...
+func (rawProtocol) Packets() packet.Pool {
+	return packet.NewPool()
+}
+
+// ConvertToLatest ...
+func (rawProtocol) ConvertToLatest(pk packet.Packet) packet.Packet {
+	return pk
+}
+
+// ConvertFromLatest ...
+func (rawProtocol) ConvertFromLatest(pk packet.Packet) packet.Packet {
+	return pk
+}
+
+// parseRawProtocol parses a version known to draco, such as "1.18.10", or any protocol ID, such as "503".
+func parseRawProtocol(s string) (rawProtocol, error) {
+	if id, err := strconv.ParseInt(s, 10, 32); err == nil {
+		p := rawProtocol{id: int32(id)}
+		if known, ok := draco.ProtocolByID(p.id); ok {
+			p.ver = known.Ver()
+		} else if p.id == protocol.CurrentProtocol {
+			p.ver = protocol.CurrentVersion
+		}
+		return p, nil
+	}
+	id, err := draco.ParseVersion(s)
+	if err != nil {
+		return rawProtocol{}, err
+	}
+	return rawProtocol{id: id, ver: s}, nil
+}
+
+// mappingSnapshot holds the data captured from a server of a single version.
+type mappingSnapshot struct {
+	protocol int32
+	// items holds the runtime IDs of all items, keyed by their name.
+	items map[string]int32
+	// creative holds the names of the items of the creative inventory, in the order they are shown.
+	creative []string
+	// biomes holds the IDs of all biomes, keyed by their name. Biomes of which the ID isn't known are -1.
+	biomes map[string]int32
+}
+
+// captureMappings joins the server on the address passed using the protocol passed and captures its items, creative
+// items and biomes.
+func captureMappings(address string, p rawProtocol, xbl bool, timeout time.Duration) (mappingSnapshot, error) {
+	d := minecraft.Dialer{ErrorLog: log.New(logging.Writer(logging.LevelWarn), "", 0), Protocol: p}
+	if xbl {
+		d.TokenSource = draco.TokenSrc
+	}
+	conn, err := d.DialTimeout("raknet", address, timeout)
+	if err != nil {
+		return mappingSnapshot{}, fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.DoSpawnTimeout(timeout); err != nil {
+		return mappingSnapshot{}, fmt.Errorf("spawn: %w", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+
+	s := mappingSnapshot{protocol: p.id, items: map[string]int32{}}
+	for _, entry := range conn.GameData().Items {
+		s.items[entry.Name] = int32(entry.RuntimeID)
+	}
+	var creative *packet.CreativeContent
+	for creative == nil || s.biomes == nil {
+		pk, err := conn.ReadPacket()
+		if err != nil {
+			return mappingSnapshot{}, fmt.Errorf("read creative content and biome definitions: %w", err)
+		}
+		switch pk := pk.(type) {
+		case *packet.CreativeContent:
+			creative = pk
+		case *packet.BiomeDefinitionList:
+			if s.biomes, err = biomeIDs(pk.SerialisedBiomeDefinitions); err != nil {
+				return mappingSnapshot{}, err
+			}
+		}
+	}
+	names := make(map[int32]string, len(s.items))
+	for name, rid := range s.items {
+		names[rid] = name
+	}
+	for _, it := range creative.Items {
+		name, ok := names[it.Item.NetworkID]
+		if !ok {
+			name = fmt.Sprintf("unknown item %v", it.Item.NetworkID)
+		}
+		s.creative = append(s.creative, name)
+	}
+	return s, nil
+}
+
+// biomeIDs decodes the biome definitions passed and returns the IDs of the biomes defined, keyed by their name.
+// Definitions only hold the IDs of biomes in some versions, so the IDs of other biomes are taken from the newest
+// version registered that has a biome with the same name, or set to -1 if none has.
+func biomeIDs(data []byte) (map[string]int32, error) {
+	var definitions map[string]any
+	if err := nbt.UnmarshalEncoding(data, &definitions, nbt.NetworkLittleEndian); err != nil {
+		return nil, fmt.Errorf("decode biome definitions: %w", err)
+	}
+	versions := biome.Versions()
+	ids := make(map[string]int32, len(definitions))
+	for name, definition := range definitions {
+		ids[name] = -1
+		if m, ok := definition.(map[string]any); ok {
+			switch id := m["id"].(type) {
+			case int16:
+				ids[name] = int32(id)
+				continue
+			case int32:
+				ids[name] = id
+				continue
+			}
+		}
+		for i := len(versions) - 1; i >= 0; i-- {
+			if id, ok := biome.ID(versions[i], name); ok {
+				ids[name] = id
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+// writeMappings writes the item and biome tables of both snapshots passed to the folder passed, in the format of the
+// tables embedded in the mappings packages, along with a report of the differences between them.
+func writeMappings(dir string, older, newer mappingSnapshot) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	for _, s := range []mappingSnapshot{older, newer} {
+		biomes := make(map[string]int32, len(s.biomes))
+		for name, id := range s.biomes {
+			if id >= 0 {
+				biomes[name] = id
+			}
+		}
+		for file, v := range map[string]map[string]int32{
+			fmt.Sprintf("item_runtime_ids_%v.nbt", s.protocol): s.items,
+			fmt.Sprintf("biome_ids_%v.nbt", s.protocol):        biomes,
+		} {
+			data, err := nbt.Marshal(v)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, file), data, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	f, err := os.Create(filepath.Join(dir, "report.txt"))
+	if err != nil {
+		return err
+	}
+	writeMappingReport(f, older, newer)
+	return f.Close()
+}
+
+// writeMappingReport writes a report of the differences between the snapshots passed to the io.Writer passed, with
+// candidate aliases for items and fallbacks for biomes that were added.
+func writeMappingReport(w io.Writer, older, newer mappingSnapshot) {
+	for _, s := range []mappingSnapshot{older, newer} {
+		fmt.Fprintf(w, "protocol %v: %v items, %v creative items, %v biomes\n", s.protocol, len(s.items), len(s.creative), len(s.biomes))
+	}
+
+	addedItems, removedItems := diffNames(older.items, newer.items)
+	renumbered := 0
+	for name, rid := range newer.items {
+		if oldRID, ok := older.items[name]; ok && oldRID != rid {
+			renumbered++
+		}
+	}
+	fmt.Fprintf(w, "\nitems renumbered: %v\n", renumbered)
+	writeNames(w, "items added", addedItems)
+	writeNames(w, "items removed", removedItems)
+	aliases := candidates(addedItems, removedItems)
+	fmt.Fprintf(w, "\ncandidate item aliases, from the names in protocol %v to those in protocol %v (%v):\n", newer.protocol, older.protocol, len(aliases))
+	for _, name := range addedItems {
+		if alias, ok := aliases[name]; ok {
+			fmt.Fprintf(w, "\t%q: %q,\n", name, alias)
+		}
+	}
+	var missing []string
+	for _, name := range newer.creative {
+		if _, ok := older.items[name]; !ok {
+			if _, ok := aliases[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+	}
+	writeNames(w, fmt.Sprintf("creative items without an equivalent in protocol %v, shown as air", older.protocol), missing)
+
+	addedBiomes, removedBiomes := diffNames(older.biomes, newer.biomes)
+	writeNames(w, "biomes added", addedBiomes)
+	writeNames(w, "biomes removed", removedBiomes)
+	var oldBiomes []string
+	for name := range older.biomes {
+		oldBiomes = append(oldBiomes, name)
+	}
+	fallbacks := candidates(addedBiomes, oldBiomes)
+	fmt.Fprintln(w, "\ncandidate biome fallbacks for biomes added without a fallback registered:")
+	for _, name := range addedBiomes {
+		if _, ok := biome.Fallback(name); ok {
+			continue
+		}
+		fallback, ok := fallbacks[name]
+		if !ok {
+			fallback = biome.Default
+		}
+		fmt.Fprintf(w, "\t%q: %q,\n", name, fallback)
+	}
+	for _, s := range []mappingSnapshot{older, newer} {
+		var unknown []string
+		for name, id := range s.biomes {
+			if id < 0 {
+				unknown = append(unknown, name)
+			}
+		}
+		sort.Strings(unknown)
+		writeNames(w, fmt.Sprintf("biomes of protocol %v without an ID, left out of its table", s.protocol), unknown)
+	}
+}
+
+// diffNames returns the names in newer but not in older, and those in older but not in newer, both sorted.
+func diffNames(older, newer map[string]int32) (added, removed []string) {
+	for name := range newer {
+		if _, ok := older[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	for name := range older {
+		if _, ok := newer[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// writeNames writes a list of names under the title passed.
+func writeNames(w io.Writer, title string, names []string) {
+	fmt.Fprintf(w, "\n%v (%v):\n", title, len(names))
+	for _, name := range names {
+		fmt.Fprintf(w, "\t%v\n", name)
+	}
+}
+
+// candidates matches every name in names with the most similar name in others, such as an item that was renamed
+// with the name it had before. Names are similar if one holds the other, such as grass and grass_block, or if few
+// characters differ between them. Names that aren't similar to any other are left out.
+func candidates(names, others []string) map[string]string {
+	m := map[string]string{}
+	for _, name := range names {
+		a := strings.TrimPrefix(name, "minecraft:")
+		best, bestDistance := "", len(a)/3+1
+		for _, other := range others {
+			b := strings.TrimPrefix(other, "minecraft:")
+			d := editDistance(a, b)
+			if strings.Contains(a, b) || strings.Contains(b, a) {
+				d = 1
+			}
+			if d < bestDistance || (d == bestDistance && best != "" && other < best) {
+				best, bestDistance = other, d
+			}
+		}
+		if best != "" {
+			m[name] = best
+		}
+	}
+	return m
+}
+
+// editDistance returns the Levenshtein distance between the strings passed.
+func editDistance(a, b string) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			next := prev + cost
+			if row[j]+1 < next {
+				next = row[j] + 1
+			}
+			if row[j-1]+1 < next {
+				next = row[j-1] + 1
+			}
+			prev, row[j] = row[j], next
+		}
+	}
+	return row[len(b)]
+}
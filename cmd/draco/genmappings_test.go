@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMappingReport(t *testing.T) {
+	older := mappingSnapshot{
+		protocol: 1,
+		items:    map[string]int32{"minecraft:stone": 1, "minecraft:grass": 2},
+		creative: []string{"minecraft:stone", "minecraft:grass"},
+		biomes:   map[string]int32{"plains": 1, "jungle": 21},
+	}
+	newer := mappingSnapshot{
+		protocol: 2,
+		items:    map[string]int32{"minecraft:stone": 1, "minecraft:grass_block": 3, "minecraft:echo_shard": 4},
+		creative: []string{"minecraft:stone", "minecraft:grass_block", "minecraft:echo_shard"},
+		biomes:   map[string]int32{"plains": 1, "jungle": 21, "jungles": -1},
+	}
+	buf := bytes.NewBuffer(nil)
+	writeMappingReport(buf, older, newer)
+	report := buf.String()
+	for _, expected := range []string{
+		"\"minecraft:grass_block\": \"minecraft:grass\",",
+		"creative items without an equivalent in protocol 1, shown as air (1):\n\tminecraft:echo_shard\n",
+		"\"jungles\": \"jungle\",",
+		"biomes of protocol 2 without an ID, left out of its table (1):\n\tjungles\n",
+	} {
+		if !strings.Contains(report, expected) {
+			t.Errorf("expected report to contain %q, got:\n%v", expected, report)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, test := range []struct {
+		a, b     string
+		distance int
+	}{{"", "abc", 3}, {"grass", "grass_block", 6}, {"kitten", "sitting", 3}, {"same", "same", 0}} {
+		if d := editDistance(test.a, test.b); d != test.distance {
+			t.Errorf("distance between %q and %q is %v, expected %v", test.a, test.b, d, test.distance)
+		}
+	}
+}
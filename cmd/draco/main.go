@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cqdetdev/draco"
+	"github.com/cqdetdev/draco/draco/gdpr"
+	"github.com/cqdetdev/draco/draco/logfile"
+	"github.com/cqdetdev/draco/draco/logging"
+)
+
+// The following program implements a proxy that forwards players from one local address to a remote address.
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "test-packet" {
+		os.Exit(testPacket(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export-world" {
+		os.Exit(exportWorld(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "conformance" {
+		os.Exit(conformance(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(replay(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "gen-mappings" {
+		os.Exit(genMappings(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "dump-mappings" {
+		os.Exit(dumpMappings(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		os.Exit(bench(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "setup" {
+		os.Exit(setup(os.Args[2:]))
+	}
+	dry := flag.Bool("dry-run", false, "check if the proxy is ready to accept players and exit without accepting any")
+	var f configFlags
+	f.register(flag.CommandLine)
+	flag.Parse()
+
+	c := f.read()
+	setupLogging(c)
+	if *dry {
+		if !dryRun(c) {
+			os.Exit(1)
+		}
+		return
+	}
+	checkConfig(c)
+	p, err := draco.New(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.SetConfigSource(f.load)
+	go reloadOnHangup(p, f.load)
+
+	// The proxy is closed on the first SIGINT or SIGTERM, which saves the caches. A second signal received while
+	// closing exits immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if err := p.ListenAndServe(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		_ = p.Close()
+		log.Fatal(err)
+	}
+}
+
+// reloadOnHangup reloads the config file into the Proxy passed every time the process receives SIGHUP, which also
+// reopens the log file.
+func reloadOnHangup(p *draco.Proxy, loadConfig func() (draco.Config, error)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		c, err := loadConfig()
+		if err == nil {
+			err = p.Reload(c)
+		}
+		if err != nil {
+			logging.Default().Error("error reloading config", "err", err)
+			continue
+		}
+		logging.Default().Info("config reloaded")
+	}
+}
+
+// setupLogging configures the structured logger using the config passed, writing to stderr and, if set, the log
+// file, and redirects the standard logger to it, so that all lines are logged in the same format.
+func setupLogging(c draco.Config) {
+	level, err := logging.ParseLevel(c.Log.Level)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var out io.Writer = os.Stderr
+	if c.Log.File != "" {
+		out = io.MultiWriter(os.Stderr, openLogFile(c))
+	}
+	logging.Configure(logging.Config{Level: level, JSON: c.Log.JSON, Output: out})
+	log.SetFlags(0)
+	log.SetOutput(logging.Writer(logging.LevelInfo))
+}
+
+// openLogFile opens the log file in the config passed. The log file is rotated according to the config and reopened
+// when the process receives SIGHUP, so that external tools such as logrotate may move it away.
+func openLogFile(c draco.Config) *logfile.Writer {
+	conf := logfile.Config{Path: c.Log.File, MaxSize: int64(c.Log.MaxSizeMB) << 20, MaxBackups: c.Log.MaxBackups}
+	if c.Log.RotateInterval != "" {
+		d, err := time.ParseDuration(c.Log.RotateInterval)
+		if err != nil {
+			log.Fatalf("error parsing log rotate interval: %v", err)
+		}
+		conf.MaxAge = d
+	}
+	w, err := logfile.Open(conf)
+	if err != nil {
+		log.Fatalf("error opening log file: %v", err)
+	}
+	// Lines logged about a player hold its XUID, so they are exported and purged with the rest of its data.
+	gdpr.Register("log", gdpr.Store{
+		Export: func(xuid string) (any, error) {
+			lines, err := w.Lines(xuid)
+			if err != nil || len(lines) == 0 {
+				return nil, err
+			}
+			return lines, nil
+		},
+		Purge: w.Purge,
+	})
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := w.Reopen(); err != nil {
+				log.Printf("error reopening log file: %v", err)
+			}
+		}
+	}()
+	return w
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/capture"
+	"github.com/cqdetdev/draco/draco/policy"
+	"github.com/pelletier/go-toml"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// replay runs the replay command with the arguments passed, which feeds the packets of a capture written by the
+// proxy, as configured using Log.CaptureFile, back through the translation layer. The packets sent by the servers are
+// translated to the protocol of the client of their session, like the proxy did, and every packet that fails to
+// translate is printed and optionally written as a fixture for test-packet. Packets sent by clients are captured
+// after they were translated already, so they are only counted. It returns the exit code of the command.
+func replay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	version := fs.String("version", "", "version or protocol ID that packets are translated to, overriding the protocol of the client captured")
+	session := fs.Uint("session", 0, "ID of the only session replayed, by default all sessions are replayed")
+	fixtures := fs.String("fixtures", "", "folder that packets failing to translate are written to as test-packet fixtures")
+	verbose := fs.Bool("v", false, "print every packet replayed rather than only those failing to translate")
+	decodePolicy := fs.String("policy", "strict", "decode policy that packets are translated under: strict or lenient")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: draco replay [-version version] [-session id] [-fixtures folder] [-policy strict|lenient] [-v] capture")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	p, err := policy.Parse(*decodePolicy)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	policy.Set(p)
+	var to int32
+	if *version != "" {
+		if to, err = draco.ParseVersion(*version); err != nil {
+			fmt.Println(err)
+			return 2
+		}
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer f.Close()
+
+	r := replayer{to: to, session: uint32(*session), fixtures: *fixtures, verbose: *verbose, out: os.Stdout}
+	if err := r.run(f); err != nil {
+		fmt.Printf("error reading capture: %v\n", err)
+		return 1
+	}
+	if r.failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// replayer replays the packets of a capture.
+type replayer struct {
+	// to is the protocol that packets are translated to. If 0, they are translated to the protocol of the client of
+	// their session.
+	to int32
+	// session is the ID of the only session replayed. If 0, all sessions are replayed.
+	session uint32
+	// fixtures is the folder that packets failing to translate are written to. If empty, no fixtures are written.
+	fixtures string
+	verbose  bool
+	out      io.Writer
+
+	sessions map[uint32]*replayedSession
+	failed   int
+}
+
+// replayedSession is a session of a capture being replayed.
+type replayedSession struct {
+	capture.Session
+	// to is the protocol that the packets of the session are translated to.
+	to int32
+	// packets is the amount of packets of the session read, translated the amount of packets sent by the server that
+	// were translated, and failed the amount of those that failed to translate.
+	packets, translated, failed int
+}
+
+// run replays all packets of the capture read from the io.Reader passed, printing a summary of every session at the
+// end. A capture cut off at the end, such as that of a proxy that crashed, is replayed up to the last full record.
+func (r *replayer) run(rd io.Reader) error {
+	c, err := capture.NewReader(rd)
+	if err != nil {
+		return err
+	}
+	r.sessions = map[uint32]*replayedSession{}
+	var order []uint32
+	for {
+		record, err := c.Next()
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			fmt.Fprintln(r.out, "capture ends in the middle of a record, which is skipped")
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch record := record.(type) {
+		case capture.Session:
+			if r.session != 0 && record.ID != r.session {
+				continue
+			}
+			s := &replayedSession{Session: record, to: r.to}
+			if s.to == 0 {
+				s.to = record.Protocol
+			}
+			r.sessions[record.ID] = s
+			order = append(order, record.ID)
+		case capture.Packet:
+			if s, ok := r.sessions[record.Session]; ok {
+				r.replayPacket(s, record)
+			}
+		}
+	}
+	for _, id := range order {
+		s := r.sessions[id]
+		fmt.Fprintf(r.out, "session %v (%v, protocol %v, backend %v): %v packets, %v translated to protocol %v, %v failed\n", s.ID, s.Name, s.Protocol, s.Backend, s.packets, s.translated, s.to, s.failed)
+	}
+	return nil
+}
+
+// replayPacket replays a single packet of the session passed.
+func (r *replayer) replayPacket(s *replayedSession, pk capture.Packet) {
+	s.packets++
+	offset := pk.Time.Sub(s.Time).Truncate(time.Millisecond)
+	if pk.ClientToServer {
+		if r.verbose {
+			fmt.Fprintf(r.out, "session %v #%v +%v client->server\n", s.ID, s.packets, offset)
+		}
+		return
+	}
+	if s.to == 0 {
+		// The protocol of the client wasn't known when the session was captured, and -version was not passed.
+		return
+	}
+	s.translated++
+	decoded, translated, err := draco.TranslatePacket(pk.Data, protocol.CurrentProtocol, s.to)
+	name := "packet"
+	if decoded != nil {
+		name = fmt.Sprintf("%T", decoded)
+	}
+	if err == nil {
+		if r.verbose {
+			fmt.Fprintf(r.out, "session %v #%v +%v server->client %v: translated to %T\n", s.ID, s.packets, offset, name, translated)
+		}
+		return
+	}
+	s.failed++
+	r.failed++
+	fmt.Fprintf(r.out, "session %v #%v +%v server->client %v: %v\n", s.ID, s.packets, offset, name, err)
+	if r.fixtures == "" {
+		return
+	}
+	if file, err := r.writeFixture(s, s.packets, pk.Data, err); err != nil {
+		fmt.Fprintf(r.out, "error writing fixture: %v\n", err)
+	} else {
+		fmt.Fprintf(r.out, "wrote fixture %v\n", file)
+	}
+}
+
+// writeFixture writes the packet data passed, which failed to translate with the error passed, as a test-packet
+// fixture to the fixtures folder, returning the path of its .hex file.
+func (r *replayer) writeFixture(s *replayedSession, index int, data []byte, translateErr error) (string, error) {
+	if err := os.MkdirAll(r.fixtures, 0755); err != nil {
+		return "", err
+	}
+	base := filepath.Join(r.fixtures, fmt.Sprintf("capture-%v-%v", s.ID, index))
+	meta, err := toml.Marshal(fixture{
+		From:        strconv.Itoa(int(protocol.CurrentProtocol)),
+		To:          strconv.Itoa(int(s.to)),
+		Backend:     s.Backend,
+		Description: fmt.Sprintf("packet %v of session %v (%v) captured by the proxy: %v", index, s.ID, s.Name, translateErr),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(base+".toml", meta, 0644); err != nil {
+		return "", err
+	}
+	return base + ".hex", ioutil.WriteFile(base+".hex", []byte(formatHex(data)), 0644)
+}
+
+// formatHex formats the data passed as a hex dump of 32 bytes per line, as read by readHex.
+func formatHex(data []byte) string {
+	var b bytes.Buffer
+	for len(data) > 0 {
+		n := 32
+		if len(data) < n {
+			n = len(data)
+		}
+		b.WriteString(hex.EncodeToString(data[:n]))
+		b.WriteByte('\n')
+		data = data[n:]
+	}
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/capture"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestReplay(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	w, err := capture.NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	proto := draco.Protocols()[0].ID()
+	_ = w.WriteSession(capture.Session{ID: 1, Name: "Steve", Backend: "lobby", Protocol: proto, Time: now})
+
+	data := bytes.NewBuffer(nil)
+	_ = (&packet.Header{PacketID: packet.IDText}).Write(data)
+	(&packet.Text{TextType: packet.TextTypeRaw, Message: "hello"}).Marshal(protocol.NewWriter(data, 0))
+	_ = w.WritePacket(capture.Packet{Session: 1, Time: now, Data: data.Bytes()})
+	_ = w.WritePacket(capture.Packet{Session: 1, ClientToServer: true, Time: now, Data: data.Bytes()})
+	// The payload of the Text packet is cut off, so it can't be decoded.
+	_ = w.WritePacket(capture.Packet{Session: 1, Time: now.Add(time.Second), Data: data.Bytes()[:3]})
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := bytes.NewBuffer(nil)
+	r := replayer{fixtures: t.TempDir(), out: out}
+	if err := r.run(buf); err != nil {
+		t.Fatal(err)
+	}
+	if r.failed != 1 {
+		t.Fatalf("expected one packet to fail, got %v:\n%v", r.failed, out)
+	}
+	if !strings.Contains(out.String(), "3 packets, 2 translated") {
+		t.Errorf("unexpected summary:\n%v", out)
+	}
+	fixture, err := runFixture(filepath.Join(r.fixtures, "capture-1-3.hex"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fixture, "error:") {
+		t.Errorf("expected the fixture written to reproduce the error, got:\n%v", fixture)
+	}
+}
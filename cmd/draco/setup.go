@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cqdetdev/draco"
+	core "github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/pelletier/go-toml"
+	"github.com/sandertv/go-raknet"
+)
+
+// configComments holds the comments written above the settings of config.toml, keyed by their path. They describe
+// the settings asked for by draco setup, and are written again every time the config is written, so that they are
+// kept when the proxy fills in defaults on start.
+var configComments = []struct {
+	path    string
+	comment string
+}{
+	{"Connection", "The listener that players join, and how players and backends are authenticated."},
+	{"Connection.LocalAddress", "Address that players join the proxy on."},
+	{"Connection.RemoteAddress", "Address of the server that players are forwarded to if no Backends are configured."},
+	{"Connection.Offline", "If true, backends run with online-mode=false and players are forwarded without an XBOX Live token."},
+	{"Connection.AuthenticationDisabled", "If true, players may join without being signed in to XBOX Live. Only set this on trusted networks."},
+	{"Guest", "An optional listener that players may join as guests without XBOX Live."},
+	{"Guest.Address", "Address of the guest listener. If empty, it is disabled."},
+	{"Admin", "The admin HTTP API, used to list, kick and transfer players and to reload the config."},
+	{"Admin.Address", "Address that the admin API is served on. If empty, it is disabled."},
+	{"Admin.Secret", "Secret sent as a bearer token in requests to the admin API."},
+	{"Metrics", "Metrics of the proxy served over HTTP as JSON."},
+	{"Metrics.Address", "Address that the metrics are served on. If empty, they are not served."},
+	{"ResourcePacks", "Resource packs of backends, offered to players from the start after they were first seen."},
+	{"ResourcePacks.Passthrough", "If true, the resource packs that backends send are cached and offered to players."},
+	{"Cache", "Caches that make the proxy start faster."},
+	{"Cache.Directory", "Directory that translation tables and recipes are cached in. If empty, nothing is cached."},
+}
+
+// encodeConfig encodes the config passed as it is written to config.toml, with the comments in configComments.
+func encodeConfig(c draco.Config) ([]byte, error) {
+	data, err := toml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range configComments {
+		path := strings.Split(c.path, ".")
+		if v := tree.GetPath(path); v != nil {
+			tree.SetPathWithComment(path, c.comment, false, v)
+		}
+	}
+	s, err := tree.ToTomlString()
+	return []byte(s), err
+}
+
+// setup runs the setup command with the arguments passed, which asks for the settings needed to run the proxy,
+// signs in to XBOX Live if the backends need it, checks if the backend is reachable and writes config.toml. It is
+// also run by the proxy if it is started without a config in an interactive terminal. It returns the exit code of
+// the command.
+func setup(args []string) int {
+	fs := flag.NewFlagSet("setup", flag.ExitOnError)
+	force := fs.Bool("force", false, "overwrite config.toml if it already exists")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: draco setup [-force]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if _, err := os.Stat("config.toml"); err == nil && !*force {
+		fmt.Println("config.toml already exists: pass -force to overwrite it")
+		return 2
+	}
+
+	p := &prompter{r: bufio.NewReader(os.Stdin), w: os.Stdout}
+	c, err := setupConfig(p, pingBackend)
+	if err != nil {
+		fmt.Printf("setup aborted: %v\n", err)
+		return 1
+	}
+	if !c.Connection.Offline {
+		fmt.Println("The proxy signs players in to the backend with an XBOX Live account. Sign in with it now:")
+		if err := core.InitializeToken(log.New(os.Stdout, "", 0)); err != nil {
+			fmt.Printf("error signing in to XBOX Live: %v\n", err)
+			return 1
+		}
+	}
+	data, err := encodeConfig(c)
+	if err != nil {
+		fmt.Printf("error encoding config: %v\n", err)
+		return 1
+	}
+	if err := ioutil.WriteFile("config.toml", data, 0644); err != nil {
+		fmt.Printf("error writing config: %v\n", err)
+		return 1
+	}
+	fmt.Println("Wrote config.toml, which holds all other settings with their defaults.")
+	return 0
+}
+
+// errSetupAborted is returned by setupConfig if the player chose not to continue with an unreachable backend.
+var errSetupAborted = errors.New("backend not reachable")
+
+// setupConfig asks for the settings of a new config using the prompter passed and returns the config. check is
+// called to check if the backend entered is reachable.
+func setupConfig(p *prompter, check func(address string) error) (draco.Config, error) {
+	c, err := draco.DecodeConfig(nil)
+	if err != nil {
+		return c, err
+	}
+	c.Connection.LocalAddress = p.ask("Address that players join the proxy on", c.Connection.LocalAddress)
+	c.Connection.RemoteAddress = p.ask("Address of the backend server that players are forwarded to", "127.0.0.1:19133")
+	c.Backends = []proxy.Backend{{Name: "default", Address: c.Connection.RemoteAddress}}
+	for {
+		err := check(c.Connection.RemoteAddress)
+		if err == nil {
+			break
+		}
+		fmt.Fprintf(p.w, "The backend at %v could not be reached: %v\n", c.Connection.RemoteAddress, err)
+		if !p.confirm("Check again?", false) {
+			if !p.confirm("Continue without a reachable backend?", false) {
+				return c, errSetupAborted
+			}
+			break
+		}
+	}
+
+	mode := p.choose("Does the backend run with online-mode enabled (xbl) or disabled (offline)?", "xbl", "xbl", "offline")
+	c.Connection.Offline = mode == "offline"
+	c.Connection.AuthenticationDisabled = !p.confirm("Must players be signed in to XBOX Live to join?", true)
+
+	c.Guest.Address = p.ask("Address of a listener for guests without XBOX Live, or empty to disable it", "")
+	c.Admin.Address = p.ask("Address of the admin HTTP API, or empty to disable it", "")
+	if c.Admin.Address != "" {
+		c.Admin.Secret = randomSecret()
+		fmt.Fprintf(p.w, "The admin API requires the bearer token %v, which is written to the config.\n", c.Admin.Secret)
+	}
+	c.Metrics.Address = p.ask("Address that metrics are served on, or empty to disable them", "")
+	c.ResourcePacks.Passthrough = p.confirm("Offer the resource packs of the backend to players?", false)
+	if p.confirm("Cache translation tables so that the proxy starts faster?", true) {
+		c.Cache.Directory = "cache"
+	}
+	return c, nil
+}
+
+// pingBackend checks if a server is running at the address passed, printing its MOTD if it is.
+func pingBackend(address string) error {
+	data, err := raknet.PingTimeout(address, time.Second*5)
+	if err != nil {
+		return err
+	}
+	if fields := strings.Split(string(data), ";"); len(fields) > 1 {
+		fmt.Printf("Reached %v: %v\n", address, fields[1])
+	}
+	return nil
+}
+
+// randomSecret returns a random secret for the admin API.
+func randomSecret() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// interactive reports if the standard input of the process is a terminal, in which case the setup may ask questions.
+// The null device is a character device too, but never a terminal, as is the case for services.
+func interactive() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// prompter asks questions on a terminal.
+type prompter struct {
+	r *bufio.Reader
+	w io.Writer
+	// eof specifies if no more answers can be read, in which case questions are answered with their defaults.
+	eof bool
+}
+
+// ask asks the question passed and returns the answer, or the default passed if the answer is empty.
+func (p *prompter) ask(question, def string) string {
+	if def != "" {
+		fmt.Fprintf(p.w, "%v [%v]: ", question, def)
+	} else {
+		fmt.Fprintf(p.w, "%v: ", question)
+	}
+	line, err := p.r.ReadString('\n')
+	if err != nil {
+		p.eof = true
+	}
+	if line = strings.TrimSpace(line); line != "" {
+		return line
+	}
+	return def
+}
+
+// confirm asks the yes or no question passed until it is answered, returning the default passed for an empty answer.
+func (p *prompter) confirm(question string, def bool) bool {
+	options := "y/N"
+	if def {
+		options = "Y/n"
+	}
+	for {
+		switch strings.ToLower(p.ask(question+" ("+options+")", "")) {
+		case "":
+			return def
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+		if p.eof {
+			return def
+		}
+	}
+}
+
+// choose asks the question passed until it is answered with one of the options passed, returning the default
+// passed for an empty answer.
+func (p *prompter) choose(question, def string, options ...string) string {
+	for {
+		answer := strings.ToLower(p.ask(question+" ("+strings.Join(options, "/")+")", def))
+		for _, o := range options {
+			if answer == o {
+				return o
+			}
+		}
+		if p.eof {
+			return def
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/cqdetdev/draco"
+)
+
+// testPrompter returns a prompter answering questions with the lines passed.
+func testPrompter(answers ...string) *prompter {
+	return &prompter{r: bufio.NewReader(strings.NewReader(strings.Join(answers, "\n") + "\n")), w: ioutil.Discard}
+}
+
+func TestSetupConfig(t *testing.T) {
+	checks := 0
+	check := func(string) error {
+		if checks++; checks == 1 {
+			return errors.New("no response")
+		}
+		return nil
+	}
+	p := testPrompter(
+		"",               // Local address.
+		"10.0.0.2:19132", // Backend address.
+		"y",              // Check again after the backend wasn't reachable.
+		"maybe",          // An invalid authentication mode of the backend, which is asked for again.
+		"offline",        // Authentication mode of the backend.
+		"n",              // Players must be signed in.
+		"",               // Guest listener.
+		"127.0.0.1:8080", // Admin API.
+	)
+	c, err := setupConfig(p, check)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Connection.LocalAddress != "0.0.0.0:19132" || len(c.Backends) != 1 || c.Backends[0].Address != "10.0.0.2:19132" {
+		t.Errorf("unexpected addresses %v and %v", c.Connection.LocalAddress, c.Backends)
+	}
+	if checks != 2 {
+		t.Errorf("backend checked %v times, expected 2", checks)
+	}
+	if !c.Connection.Offline || !c.Connection.AuthenticationDisabled {
+		t.Errorf("unexpected authentication: offline %v, authentication disabled %v", c.Connection.Offline, c.Connection.AuthenticationDisabled)
+	}
+	if c.Admin.Address != "127.0.0.1:8080" || len(c.Admin.Secret) != 32 {
+		t.Errorf("unexpected admin API %v with secret %q", c.Admin.Address, c.Admin.Secret)
+	}
+	// The remaining questions are answered with their defaults once the answers run out.
+	if c.Cache.Directory != "cache" || c.ResourcePacks.Passthrough {
+		t.Errorf("unexpected defaults: cache directory %q, passthrough %v", c.Cache.Directory, c.ResourcePacks.Passthrough)
+	}
+
+	if _, err := setupConfig(testPrompter("", "10.0.0.2:19132", "n", "n"), func(string) error { return errors.New("no response") }); !errors.Is(err, errSetupAborted) {
+		t.Errorf("expected the setup to be aborted for an unreachable backend, got %v", err)
+	}
+}
+
+func TestEncodeConfig(t *testing.T) {
+	c, err := draco.DecodeConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Admin.Secret = "secret"
+	data, err := encodeConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# Address that players join the proxy on.") {
+		t.Errorf("comments missing from encoded config:\n%s", data)
+	}
+	decoded, err := draco.DecodeConfig(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Admin.Secret != "secret" || decoded.Connection.LocalAddress != c.Connection.LocalAddress {
+		t.Error("config changed when encoded and decoded again")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/policy"
+	"github.com/pelletier/go-toml"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// fixture is the metadata of a packet fixture, read from the .toml file next to its .hex file. Fixtures allow
+// operators to reproduce a packet that draco fails to translate: the packet is dumped as hex into a file such as
+// fixtures/name.hex, holding the packet header and payload of a single decompressed packet, with whitespace and lines
+// starting with # ignored. fixtures/name.toml may hold the metadata below, and fixtures/name.golden the output that
+// test-packet is expected to print for the fixture.
+type fixture struct {
+	// From and To are the versions, such as "1.18.30", or protocol IDs, such as "486", that the packet is translated
+	// from and to.
+	From, To string
+	// Backend is the name of the backend that sent the packet, for reference only.
+	Backend string
+	// Description describes the problem that the fixture reproduces.
+	Description string
+}
+
+// testPacket runs the test-packet command with the arguments passed, translating the packets of every fixture passed
+// and printing the result. If a fixture has a .golden file, the output is compared with it instead, or written to it
+// if -update is passed. It returns the exit code of the command.
+func testPacket(args []string) int {
+	fs := flag.NewFlagSet("test-packet", flag.ExitOnError)
+	from := fs.String("from", "", "version or protocol ID that the packets are sent by, overriding the fixture metadata")
+	to := fs.String("to", "", "version or protocol ID that the packets are translated to, overriding the fixture metadata")
+	update := fs.Bool("update", false, "write the output of every fixture to its .golden file")
+	decodePolicy := fs.String("policy", "strict", "decode policy that packets are translated under: strict or lenient")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: draco test-packet [-from version] [-to version] [-policy strict|lenient] [-update] file.hex...")
+		fs.PrintDefaults()
+	}
+	// Flags may follow the files, as in draco test-packet file.hex -from 1.18.30 -to 1.18.10.
+	var files []string
+	_ = fs.Parse(args)
+	for fs.NArg() > 0 {
+		files = append(files, fs.Arg(0))
+		_ = fs.Parse(fs.Args()[1:])
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return 2
+	}
+	p, err := policy.Parse(*decodePolicy)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	policy.Set(p)
+
+	code := 0
+	for _, file := range files {
+		out, err := runFixture(file, *from, *to)
+		if err != nil {
+			fmt.Printf("%v: %v\n", file, err)
+			code = 1
+			continue
+		}
+		golden := strings.TrimSuffix(file, ".hex") + ".golden"
+		expected, err := ioutil.ReadFile(golden)
+		switch {
+		case *update:
+			if err := ioutil.WriteFile(golden, []byte(out), 0644); err != nil {
+				fmt.Printf("%v: write golden file: %v\n", file, err)
+				code = 1
+			}
+		case os.IsNotExist(err):
+			fmt.Printf("%v:\n%v", file, out)
+		case err != nil:
+			fmt.Printf("%v: read golden file: %v\n", file, err)
+			code = 1
+		case string(expected) != out:
+			fmt.Printf("%v: output differs from %v:\n%v", file, golden, out)
+			code = 1
+		default:
+			fmt.Printf("%v: ok\n", file)
+		}
+	}
+	return code
+}
+
+// runFixture reads the fixture with the .hex file passed and returns the output of translating its packet. The
+// versions passed override those of the fixture metadata if not empty. Errors decoding or translating the packet are
+// part of the output, so that golden files may hold them.
+func runFixture(file, from, to string) (string, error) {
+	var f fixture
+	if data, err := ioutil.ReadFile(strings.TrimSuffix(file, ".hex") + ".toml"); err == nil {
+		if err := toml.Unmarshal(data, &f); err != nil {
+			return "", fmt.Errorf("decode metadata: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("read metadata: %w", err)
+	}
+	if from != "" {
+		f.From = from
+	}
+	if to != "" {
+		f.To = to
+	}
+	fromID, err := draco.ParseVersion(f.From)
+	if err != nil {
+		return "", fmt.Errorf("from: %w", err)
+	}
+	toID, err := draco.ParseVersion(f.To)
+	if err != nil {
+		return "", fmt.Errorf("to: %w", err)
+	}
+	data, err := readHex(file)
+	if err != nil {
+		return "", err
+	}
+
+	out := bytes.NewBuffer(nil)
+	decoded, translated, err := draco.TranslatePacket(data, fromID, toID)
+	if decoded != nil {
+		fmt.Fprintf(out, "decoded (%v): %v\n", fromID, formatPacket(decoded))
+	}
+	if translated != nil {
+		fmt.Fprintf(out, "translated (%v): %v\n", toID, formatPacket(translated))
+	}
+	if err != nil {
+		fmt.Fprintf(out, "error: %v\n", err)
+	}
+	return out.String(), nil
+}
+
+// readHex reads the hex dump in the file passed, ignoring whitespace and lines starting with #.
+func readHex(file string) ([]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "#") {
+			continue
+		}
+		b.WriteString(strings.Join(strings.Fields(line), ""))
+	}
+	decoded, err := hex.DecodeString(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("decode hex: %w", err)
+	}
+	return decoded, nil
+}
+
+// formatPacket formats a packet as its type followed by its fields encoded as indented JSON.
+func formatPacket(pk packet.Packet) string {
+	data, err := json.MarshalIndent(pk, "", "  ")
+	if err != nil {
+		// Some values, such as NaN floats, can't be encoded as JSON.
+		return fmt.Sprintf("%T %+v", pk, pk)
+	}
+	return fmt.Sprintf("%T %s", pk, data)
+}
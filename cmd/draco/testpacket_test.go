@@ -0,0 +1,30 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFixtures(t *testing.T) {
+	files, err := filepath.Glob("fixtures/*.hex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		out, err := runFixture(file, "", "")
+		if err != nil {
+			t.Errorf("%v: %v", file, err)
+			continue
+		}
+		golden, err := ioutil.ReadFile(strings.TrimSuffix(file, ".hex") + ".golden")
+		if err != nil {
+			t.Errorf("%v: %v", file, err)
+			continue
+		}
+		if string(golden) != out {
+			t.Errorf("%v: output differs from golden file, run draco test-packet -update %v if the change is expected:\n%v", file, file, out)
+		}
+	}
+}
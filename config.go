@@ -0,0 +1,603 @@
+package draco
+
+import (
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/cluster"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/cqdetdev/draco/draco/sockopt"
+	"github.com/cqdetdev/draco/draco/status"
+)
+
+// Config is the config of a Proxy, as it is stored in config.toml. A Config is obtained using DecodeConfig, which
+// fills in the defaults of the settings not set, and may then be changed programmatically.
+type Config struct {
+	Connection struct {
+		LocalAddress string
+		// RemoteAddress is the address of the server that players are forwarded to if no Backends are configured.
+		RemoteAddress string
+		// MaxPlayers is the maximum amount of players connected to the proxy at the same time. If 0, there is no
+		// limit.
+		MaxPlayers int
+		// MaxConnections is the maximum amount of clients connected to each listener at the same time, including
+		// clients that are still logging in, which bots flooding the proxy may never finish. Clients connecting
+		// while the limit is reached are turned away before logging in. It should be above MaxPlayers. If 0, there
+		// is no limit.
+		MaxConnections int
+		// LoginsPerIP is the maximum amount of logins per minute from a single IP. Clients logging in more often
+		// are turned away before a backend is dialed for them. If 0, there is no limit.
+		LoginsPerIP int
+		// LoginTimeout is a duration, such as "30s", within which clients must complete their login, including
+		// downloading resource packs, or be turned away before a backend is dialed for them. If empty, there is no
+		// timeout.
+		LoginTimeout string
+		// ResourcePacks is a list of paths to resource packs that the proxy sends to clients when they join, before a
+		// backend is dialed for them. The packs that backends send themselves are not forwarded, unless
+		// ResourcePacks.Passthrough is set, in which case the packs configured take precedence over a cached pack with
+		// the same UUID. Packs of a single backend may be set in the ResourcePacks of the backend.
+		ResourcePacks []string
+		// DuplicateLogins is the policy applied when a player joins with the XUID of a player that is already
+		// connected: "reject" rejects the new player, and an empty value kicks the player already connected. If
+		// Cluster.Nodes is set, the policy also applies to players connected to the other nodes, which are asked
+		// through their admin APIs. Nodes that don't respond within two seconds are not taken into account.
+		DuplicateLogins proxy.DuplicateLoginPolicy
+		// StripEducationFeatures strips education edition game rules and packets sent by the remote server, which
+		// may crash regular clients.
+		StripEducationFeatures bool
+		// Offline runs the proxy without XBOX Live on the side of the backends: every backend is treated as
+		// Offline, so players are forwarded with the identity they logged in with and no XBL token is needed. The
+		// backends must run with online-mode=false.
+		Offline bool
+		// AuthenticationDisabled specifies if clients on LocalAddress may join without being signed in to XBOX
+		// Live. Unlike guests, they join as regular players with the identity they claim, which is not verified, so
+		// it should only be set on private networks, such as a LAN or a test environment, where all clients are
+		// trusted.
+		AuthenticationDisabled bool
+		// DevMode runs the proxy without any XBOX Live authentication or Microsoft account, for development and
+		// tests: it implies Offline and AuthenticationDisabled, no account is signed in to, and every player is
+		// assigned a fake identity derived from the name it claims, so that the same name always has the same XUID.
+		// It must never be set on a public proxy.
+		DevMode bool
+		// PassthroughNewest accepts clients on versions released after the newest version supported, such as on the
+		// day a new version of the game is released, by passing their packets through as if they were of the newest
+		// version. This works for as long as the packets of the new version didn't change, so a warning is logged for
+		// every such client, and it should only be set until the new version is supported.
+		PassthroughNewest bool
+	}
+	ResourcePacks struct {
+		// Passthrough specifies if the resource packs that backends send are offered to clients. Clients download
+		// packs from the proxy before a backend is dialed for them, so the packs of backends are cached in
+		// CacheDirectory when players join them, and offered to clients from the next start.
+		Passthrough bool
+		// CacheDirectory is the directory that the resource packs of backends are cached in, "packs" by default.
+		CacheDirectory string
+		// Prefetch specifies if every backend is dialed once on start to download its resource packs before
+		// players are accepted, so that new packs of backends are offered to clients from the first start.
+		// Passthrough must be set.
+		Prefetch bool
+		// Required specifies if clients must accept the resource packs offered to join. Clients declining them are
+		// disconnected.
+		Required bool
+	}
+	Log struct {
+		// File is the file that logs are written to in addition to stderr. If empty, logs are only written to
+		// stderr.
+		File string
+		// MaxSizeMB is the size in megabytes after which the log file is rotated. 0 disables rotation by size.
+		MaxSizeMB int
+		// RotateInterval is a duration, such as "24h", after which the log file is rotated. An empty value disables
+		// rotation by age.
+		RotateInterval string
+		// MaxBackups is the amount of rotated log files to keep. 0 keeps all of them.
+		MaxBackups int
+		// Level is the minimum level of lines logged: "debug", "info", the default, "warn" or "error".
+		Level string
+		// JSON specifies if lines are logged as JSON objects, one per line, for log aggregation tools, rather than as
+		// text. Lines about a player hold its XUID, name, address, game version and protocol as fields.
+		JSON bool
+		// LatencyReports specifies if a report of the latency that the proxy added to the packets of a player,
+		// including the share spent translating packets per packet class, is logged when the player leaves. The
+		// same report is served by the admin API while the player is online.
+		LatencyReports bool
+		// CaptureFile is a file that all packets forwarded for all players are recorded to, so that their sessions
+		// may be replayed through the translation layer offline using draco replay. The file is replaced when the
+		// proxy starts and grows quickly, so it should only be set while debugging. If empty, packets are not
+		// recorded.
+		CaptureFile string
+		// MismatchReport is a JSON file that the block states found in chunks without an equivalent in the version
+		// of the player are written to every minute, with the amount of sub chunks they were found in, so that
+		// entries missing from the translation tables are found. The same report is served by the admin API at
+		// /mismatches. If empty, the report is only served by the admin API.
+		MismatchReport string
+	}
+	// Backends is a list of servers that the proxy forwards players to. Players join the first backend in the list,
+	// and may transfer themselves to the others using /server <name>.
+	Backends []proxy.Backend
+	// Accounts holds the XBOX Live accounts that backends may sign players in with using their Accounts, other
+	// than the default account, which is cached in token.json. Every account is signed in to using device auth on
+	// the first start, after which its token is cached in its TokenFile, ./token-<name>.json by default, and
+	// refreshed automatically.
+	Accounts []draco.Account
+	Network  struct {
+		// Listener holds the socket options applied to the socket that clients connect to.
+		Listener sockopt.Options
+		// Dialer holds the socket options applied to the sockets of connections to the remote server.
+		Dialer sockopt.Options
+		// ChunkRate holds the rates in kilobytes per second that chunks are sent to clients at. The rate of a
+		// client starts at InitialKB and is lowered down to MinKB if its connection can't keep up, or raised up to
+		// MaxKB if it can. If MaxKB is 0, chunks are sent as soon as the remote server sends them.
+		ChunkRate struct {
+			InitialKB, MinKB, MaxKB int
+		}
+		// PacketPriorities configures the order in which packets are written to clients that can't keep up,
+		// such as while their chunks are paced according to ChunkRate. If Enabled, movement is written first,
+		// followed by block updates and other packets, chat and cosmetics, such as sounds and particles. Classes
+		// overrides the priorities of the classes "movement", "block_updates", "chat", "cosmetics" and "other",
+		// where packets of higher priorities are written first. If not Enabled, packets are written in order.
+		PacketPriorities struct {
+			Enabled bool
+			Classes map[string]int
+		}
+		// DecodePolicy is the policy applied to packets that can't be translated: "lenient", the default, replaces
+		// blocks without an equivalent with the FallbackBlock and items with air and drops packets that still can't
+		// be translated, "drop" drops packets holding such blocks and items instead, and "strict" disconnects the
+		// player, which makes translation bugs visible during development. Only the player whose packet couldn't be
+		// translated is affected.
+		DecodePolicy string
+		// FallbackBlock is the name of the block, without properties, that blocks without an equivalent are
+		// replaced with under the lenient DecodePolicy, such as "minecraft:info_update". It is air if empty.
+		FallbackBlock string
+		// CoalesceBlockUpdates specifies if block updates of the same sub chunk sent by the remote server within a
+		// tick are combined into a single packet before they are sent to clients.
+		CoalesceBlockUpdates bool
+		// BackendProbe configures the probes sent to the remote server to detect that it stopped responding before
+		// the connection times out. Interval, such as "2s", is the interval at which probes are sent, and Timeout
+		// is the duration without any packets after which the connection is closed. If Interval is empty, no
+		// probes are sent.
+		BackendProbe struct {
+			Interval, Timeout string
+		}
+		// Potato configures the low bandwidth mode that players may enable using /potato. ChunkRadius is the
+		// maximum chunk radius of these players, 4 by default, and only one in every KeepOneIn sounds and particles
+		// is sent to them, 4 by default.
+		Potato struct {
+			ChunkRadius int32
+			KeepOneIn   int
+		}
+		// AdaptiveView configures the adaptive view distance. If Enabled, the chunk radius of players whose
+		// connection degrades is reduced by two chunks at a time, down to MinRadius, 4 by default, and raised again
+		// one chunk at a time once the connection recovers. A connection is degraded if more than MaxLoss of the
+		// datagrams sent over the last minute, 0.05 by default, had to be sent again, or if more than MaxInFlight
+		// datagrams, 512 by default, are unacknowledged.
+		AdaptiveView struct {
+			Enabled     bool
+			MinRadius   int32
+			MaxLoss     float64
+			MaxInFlight int
+		}
+		// MemoryWatch configures the accounting of the memory that the proxy holds for every player, such as the
+		// entities, chunks and packets it tracks. Every Interval, such as "1m", a warning is logged for players of
+		// which this state exceeds SessionLimitKB kilobytes, and for players who left minutes ago of whom the state
+		// is still held, which points to a leak. The footprint of a player is served by the admin API at /memory.
+		// If Interval is empty, memory is not watched.
+		MemoryWatch struct {
+			Interval       string
+			SessionLimitKB int
+		}
+		// DialLimit limits the connections that the proxy dials to every backend, so that players reconnecting all at
+		// once can't flood a small backend. At most MaxConcurrent players, 16 by default, log in to a single backend
+		// at the same time, and at most PerSecond connections, 10 by default, are dialed to it per second, of which
+		// Burst, PerSecond by default, may be dialed at once. Joins exceeding the limit wait for up to MaxWait, such
+		// as "5s", the default, after which the player is disconnected. A negative MaxConcurrent or PerSecond
+		// disables that limit.
+		DialLimit struct {
+			MaxConcurrent int
+			PerSecond     float64
+			Burst         int
+			MaxWait       string
+		}
+		// InventoryResync configures the detection of players whose inventory went out of sync with their backend,
+		// which shows as item stack requests rejected by the backend and inventory mismatches reported by the
+		// client. Once Failures of them, 3 by default, happened within Window, "10s" by default, the inventory of
+		// the player is sent again. A negative Failures disables the detection.
+		InventoryResync struct {
+			Failures int
+			Window   string
+		}
+		// PacketRateLimit is the maximum amount of packets that a client may send per second. Packets above the
+		// limit are dropped. If 0, there is no limit.
+		PacketRateLimit int
+		// ChunkWorkers is the amount of goroutines translating the chunks sent to players on older versions, so that
+		// other packets keep being forwarded while the chunks of a player are translated. Packets are still sent in
+		// order. If 0, chunks are translated by the goroutine forwarding the packets of every player.
+		ChunkWorkers int
+		// Middleware configures the chain of middleware that every packet forwarded passes through: "rate_limit",
+		// "anti_crash", "log", "capture", "boss_bars", "filters", which applies Filters, and "handlers", which
+		// passes packets to plugins, run in that order by default. The middleware in Order run first, in the order listed, followed by the others, and
+		// those in Disabled don't run at all.
+		Middleware struct {
+			Order, Disabled []string
+		}
+	}
+	Link struct {
+		// Address is the address that the account linking HTTP API is served on. If empty, the API is disabled.
+		Address string
+		// Secret is the bearer token that websites must pass in the Authorization header of requests.
+		Secret string
+		// CodeTTL is the duration, such as "5m", that a link code remains valid for.
+		CodeTTL string
+	}
+	Guest struct {
+		// Address is the address of a listener that players may join without being authenticated with XBOX Live,
+		// for example in test environments or at LAN events. If empty, the listener is disabled. Guests are not
+		// allowed to chat or run commands, and the backends must have authentication disabled to accept them.
+		Address string
+		// Prefix is prepended to the names of guests, so that they can't impersonate other players.
+		Prefix string
+	}
+	// Balance spreads the players joining Connection.LocalAddress, and listeners without a Backend, over a group of
+	// backends rather than having them join the first backend. Backends lists the names of the backends in the group.
+	// New players are preferably routed to backends with a low round trip time from the proxy and few recent errors,
+	// as measured when dialing them and by the probes of Network.BackendProbe, so that a struggling backend receives
+	// fewer of them. If empty, players join the first backend.
+	Balance struct {
+		Backends []string
+	}
+	// Listeners lists addresses that players may join in addition to Connection.LocalAddress, each of which routes
+	// players to a backend of its own and may show a server name and accept versions of its own, such as
+	// [[Listeners]] Address = "0.0.0.0:19133", Backend = "skywars", MOTD = "SkyWars" and Protocols = ["1.18.10"].
+	Listeners []Listener
+	// Rules is a list of rules evaluated when players join, after the listener, bans and whitelist decided which
+	// backend they join and if they are let in. A rule applies to the players matching all of its conditions, any
+	// of Names, XUIDs, Networks, Locales, Versions and Hours, such as Locales = ["de"] or Hours = "22:00-06:00", and
+	// may Deny them with a Message, or route them to a Backend, assign them a Role and limit their ViewDistance. The
+	// actions of all rules that apply are combined in order, later rules overriding earlier ones, until a rule
+	// applies that denies the player or has Stop set. Players migrated from another node of a cluster keep their
+	// backend.
+	Rules    []proxy.Rule
+	Identity struct {
+		// Verify enables verifying the login identity chain of players at the proxy, in addition to the
+		// verification done by gophertunnel. Players whose chain fails verification are disconnected.
+		Verify bool
+		// Strict requires every chain to be signed by Mojang and to hold an XUID.
+		Strict bool
+		// ClockSkew is the difference between the clocks of clients and the proxy, such as "1m", that is
+		// tolerated when checking if a chain has expired.
+		ClockSkew string
+	}
+	Metrics struct {
+		// Address is the address that the metrics of the proxy are served on over HTTP as JSON. If empty, they
+		// are not served.
+		Address string
+	}
+	// JoinSLO is the objective for the time that players take to join, measured from the proxy accepting a player up
+	// to its client being initialised in the world. Target of the joins, such as 0.95 for 95%, must spawn within
+	// Threshold, such as "5s", measured over the joins in the last Window, "10m" by default. The objective is only
+	// breached with at least MinJoins joins in the window. Once it is breached, and once it is met again, an alert
+	// is posted to WebhookURL, such as that of a Discord webhook. Compliance is served by the admin API at /slo. If
+	// Threshold is empty, join times are not tracked.
+	JoinSLO struct {
+		Threshold  string
+		Target     float64
+		Window     string
+		MinJoins   int
+		WebhookURL string
+	}
+	Status struct {
+		// Providers holds the providers of the status shown in the server list, in order of preference. If a
+		// provider fails, the next one is used. Providers may be "static", showing ServerName, "foreign", showing
+		// the status of the first backend, "aggregate", showing the combined player counts of all backends, or
+		// "script", running Script.
+		Providers []string
+		// ServerName is the name shown by the static provider.
+		ServerName string
+		// Script is the command, followed by its arguments, run by the script provider. It must print a JSON
+		// object with the fields server_name, player_count and max_players.
+		Script []string
+		// Interval is the interval, such as "1s", at which the status is updated.
+		Interval string
+		// Timeout is the maximum duration, such as "2s", that a provider may take to obtain the status.
+		Timeout string
+		// CacheTTL is the duration, such as "30s", for which the status obtained from a provider is kept, so that
+		// backends are pinged less often than every Interval. If empty, the status is obtained every Interval.
+		CacheTTL string
+		// Override overrides the server name, player count and maximum amount of players obtained from the
+		// providers. Its fields may hold the placeholders {name}, {online} and {max}, which are replaced with the
+		// values obtained, such as those of the backend pinged by the foreign provider.
+		Override status.Override
+	}
+	// Filters is a list of rules that drop, log or rewrite packets forwarded, such as Text packets sent by the server,
+	// applied in order. Direction is "client" or "server" for packets sent by either, or empty for both, Packets
+	// lists packets by ID or by name, such as "Text", and Action is "drop", "log" or "rewrite", which sets the
+	// fields of packets to the values in Fields, such as {Message = "[hidden]"}.
+	Filters []proxy.Filter
+	// BlockedCommands is a list of commands that are blocked at the proxy, so that they never reach the backend.
+	BlockedCommands []struct {
+		// Command is the name of the command, such as "me".
+		Command string
+		// Roles holds the roles, "member" or "guest", of the players that may not use the command.
+		Roles []string
+		// Message is the message sent to players using the command. If empty, a default message is sent.
+		Message string
+	}
+	Cooldowns struct {
+		// Chat and Commands are the minimum durations, such as "2s", between two chat messages and between two
+		// commands of a player. Messages and commands sent sooner are answered with a warning at the proxy and never
+		// reach the backend. If empty, there is no cooldown.
+		Chat, Commands string
+		// Bypass holds the roles, "member" or "guest", of the players that the cooldowns don't apply to.
+		Bypass []string
+	}
+	// Permissions overrides the permissions shown to clients of players with a role, independent of what the
+	// backend grants them. This may be used to hide the operator UI, such as the gamemode switcher.
+	Permissions []struct {
+		// Role is the role, "member" or "guest", of the players whose permissions are overridden.
+		Role string
+		// PermissionLevel is the permission level shown: "visitor", "member", "operator" or "custom". If empty,
+		// it isn't overridden.
+		PermissionLevel string
+		// CommandPermissionLevel is the command permission level shown: "normal", "game_directors", "admin",
+		// "host", "owner" or "internal". If empty, it isn't overridden.
+		CommandPermissionLevel string
+	}
+	// Discord is a list of Discord channels that the chat of players is bridged to.
+	Discord []struct {
+		// Backend is the name of the backend whose players are bridged. If empty, all players are.
+		Backend string
+		// WebhookURL is the URL of a webhook that messages are posted through.
+		WebhookURL string
+		// BotToken and ChannelID are the token of a bot and the ID of the channel it bridges. Unlike a webhook,
+		// a bot also bridges messages from Discord to the game.
+		BotToken, ChannelID string
+		// PollInterval is the interval, such as "2s", at which the bot checks the channel for new messages.
+		PollInterval string
+	}
+	Forwarding struct {
+		// Secret is a secret shared with the backends. If set, the address, role and join time of players and
+		// the ID of the proxy are attached to the login requests sent to backends in a claim signed with it,
+		// which backends may read using forward.Parse.
+		Secret string
+		// Node is the ID of the proxy attached to login requests.
+		Node string
+	}
+	// Atmospheres overrides the fog and weather shown to players, such as to keep the sky clear in a lobby. The
+	// first entry applying to the Backend, if not empty, and to one of the Roles, "member" or "guest", if not empty,
+	// of a player is applied. Fog is a fog stack defined by the resource packs of the client, such as
+	// ["minecraft:fog_ocean"], replacing the fog sent by the backend if not empty, and ClearWeather stops rain and
+	// thunder. Atmospheres are restored after transfers.
+	Atmospheres []struct {
+		Backend      string
+		Roles        []string
+		Fog          []string
+		ClearWeather bool
+	}
+	// GameModes overrides the game mode shown to players, such as to show adventure mode to visitors of a build
+	// server while the backend keeps them in survival. The first entry applying to the Backend, if not empty, and to
+	// one of the Roles, "member" or "guest", if not empty, of a player is applied. Mode is "survival", "creative",
+	// "adventure" or "spectator". The backend still enforces the game mode it set.
+	GameModes []struct {
+		Backend string
+		Roles   []string
+		Mode    string
+	}
+	Sidebar struct {
+		// Title and Lines are the title and lines of the sidebar shown to players. They may hold the placeholders
+		// {name}, {backend}, {ping} and {online}. If Lines is empty, no sidebar is shown.
+		Title string
+		Lines []string
+		// Hidden specifies if the sidebar is hidden until it is shown for a player through the proxy API.
+		Hidden bool
+	}
+	ServerSettings struct {
+		// Policy is the way requests for server settings, sent when players open their settings, are handled. It
+		// is "forward" to forward them to the backend, "proxy" to answer them with a form of the proxy holding
+		// Text, or "suppress" to drop them.
+		Policy string
+		// Title and Text are the title and text of the form of the proxy.
+		Title, Text string
+	}
+	Bans struct {
+		// File is the JSON file that bans, mutes and whitelisted players are stored in. If empty, players cannot
+		// be banned, muted or whitelisted.
+		File string
+		// Whitelist specifies if only whitelisted players may join. Guests can't be whitelisted. The whitelist may
+		// also be enabled and disabled through the HTTP API.
+		Whitelist bool
+		// Address is the address that the HTTP API for managing bans, mutes and the whitelist is served on. The
+		// same API is served through the admin API. If empty, it is not served.
+		Address string
+		// Secret is the secret that must be sent as a bearer token in requests to the HTTP API.
+		Secret string
+		// Sync holds an HTTP endpoint, such as that of a web panel, that bans, mutes and whitelisted players are
+		// fetched from every Interval, such as "1m", so that they don't need to be pushed to every proxy. The
+		// endpoint responds with a JSON object holding the entries in "entries", in the format of File, and
+		// optionally whether the whitelist is enabled in "whitelist". URL must use HTTPS unless PublicKey, a base64
+		// encoded Ed25519 public key, is set, in which case responses must be signed with the matching private key
+		// in the X-Signature header. Entries created on the proxy are kept. If URL is empty, nothing is fetched.
+		Sync struct {
+			URL       string
+			Interval  string
+			PublicKey string
+		}
+	}
+	Positions struct {
+		// File is the JSON file that the last known positions of players are stored in when they leave the proxy,
+		// which the admin API serves at /positions together with the positions of players online. If empty, the
+		// positions of players are not stored.
+		File string
+	}
+	Fallback struct {
+		// Backend is the name of the backend that players are moved to if the connection to their backend drops. If
+		// empty, or if it can't be joined, Limbo applies.
+		Backend string
+		// Limbo specifies if players are held in a limbo served by the proxy while their backend is retried, rather
+		// than being disconnected. Timeout, such as "1m", is the duration for which the backend is retried.
+		Limbo   bool
+		Timeout string
+		// OnKick specifies if players kicked by their backend fall back as well, rather than only players whose
+		// connection to their backend was lost. Backends kick all players when they shut down.
+		OnKick bool
+	}
+	Limbo struct {
+		// Message is the message shown to players in the limbo served by the proxy, on a boss bar if BossBar is true
+		// or as a title otherwise. If empty, a translated default message is shown.
+		Message string
+		BossBar bool
+		// DuringTransfers specifies if players are held in the limbo while the backend they transfer to is dialed.
+		DuringTransfers bool
+	}
+	Admin struct {
+		// Address is the address that the admin HTTP API is served on. It allows listing, kicking and messaging
+		// players, logging the packets of a single player for a while, transferring players to other backends,
+		// reloading the config, draining the proxy and exporting the worlds of backends with CacheChunks set, which
+		// draco export-world uses. The protocols of clients turned away for their version are listed per day at
+		// /protocols/rejected. All data stored about a player, such as its bans, position, captured packets and
+		// log lines, is exported with GET /gdpr?xuid=<xuid> and purged with DELETE /gdpr?xuid=<xuid>, to answer
+		// the requests of data subjects. If empty, it is not served.
+		Address string
+		// Secret is the secret that must be sent as a bearer token in requests to the HTTP API.
+		Secret string
+	}
+	Challenge struct {
+		// Always specifies if all players must pass a challenge before a backend is dialed for them, rather than
+		// only players joining while more than JoinRate players joined in the last minute. Challenged players are
+		// spawned in an empty world and must move within Timeout, such as "10s", which bots flooding the proxy
+		// don't do. Players that passed are not challenged again from the same IP for an hour. No players are
+		// challenged until the first player spawned on a backend, as challenged players are spawned with its items
+		// and blocks.
+		Always   bool
+		JoinRate int
+		Timeout  string
+	}
+	Cluster struct {
+		// Nodes lists the other proxies of the cluster. Draining the proxy through the admin API migrates its
+		// players to one of them: their XUID and backend are sent to the admin API of the node, after which they
+		// are transferred to its address and attached to the same backend again. Joining players are turned away
+		// while the proxy drains. All nodes must share the same admin secret, and this node is identified by
+		// Forwarding.Node. If empty, clustering is disabled.
+		Nodes []cluster.Node
+	}
+	Cache struct {
+		// Directory is a directory that the translation tables between versions are cached in, so that they don't
+		// have to be generated on every start. The translated recipes of backends and the index of the client blob
+		// cache are saved in it when the proxy is stopped with SIGINT or SIGTERM, and loaded on the next start. If
+		// empty, nothing is cached.
+		Directory string
+	}
+	Lang struct {
+		// Directory is a directory holding files named after a language code, such as en_US.toml, which override
+		// or translate the messages sent to players by the proxy.
+		Directory string
+	}
+	Remote struct {
+		// Kind is the kind of remote endpoint that the config is obtained from: "http", "consul" or "etcd". The
+		// settings in the remote config override those in config.toml, so that settings specific to one proxy, such
+		// as Forwarding.Node, may be left out of it. The Remote settings themselves are always read from config.toml.
+		// If empty, the config is only read from config.toml.
+		Kind string
+		// URL is the URL of the endpoint for "http", which responds to GET requests with the config, or the base URL
+		// of the Consul agent or the JSON gateway of the etcd cluster, such as "http://127.0.0.1:8500".
+		URL string
+		// Key is the key in Consul or etcd that holds the config.
+		Key string
+		// Token authenticates the requests to the endpoint, if set.
+		Token string
+		// Interval, such as "30s", is the interval at which the endpoint is checked for changes, which are applied
+		// like a reload. For Consul, changes are watched using blocking queries lasting up to Interval. Defaults to
+		// 30 seconds.
+		Interval string
+		// CacheFile is the file that the remote config obtained last is stored in, which is used if the endpoint
+		// can't be reached when the proxy starts. Defaults to config.remote.toml.
+		CacheFile string
+	}
+	Discovery struct {
+		// Kind is the kind of service discovery that the proxy registers with once it listens: "consul", "etcd" or
+		// "kubernetes". The address, capacity, player count and supported protocols of the proxy are registered and
+		// refreshed every Interval, so that DNS or load balancers may steer clients across a fleet of proxies. For
+		// Consul, the proxy is a service named Key with the state in its metadata. For etcd, the state is stored as
+		// JSON under Key/<node>, attached to a lease. For Kubernetes, the pod that the proxy runs in is annotated
+		// with annotations prefixed with Key, such as "draco/players". The registration is removed when the proxy
+		// stops. If empty, the proxy is not registered.
+		Kind string
+		// URL is the base URL of the Consul agent, the JSON gateway of the etcd cluster or the Kubernetes API
+		// server. For Kubernetes, it defaults to the API server of the cluster that the proxy runs in.
+		URL string
+		// Key is the service name, key prefix or annotation prefix that the proxy is registered under.
+		Key string
+		// Token authenticates the requests to the endpoint, if set. For Kubernetes, it defaults to the token of
+		// the service account of the pod.
+		Token string
+		// Interval, such as "10s", is the interval at which the registration is refreshed. The registration
+		// expires after three intervals without being refreshed. Defaults to 10 seconds.
+		Interval string
+		// Address is the address that players join the proxy on, as registered. The ID that the proxy is
+		// registered with is Forwarding.Node, or the hostname if not set. Defaults to Connection.LocalAddress.
+		Address string
+	}
+	Telemetry struct {
+		// URL is an endpoint that anonymised statistics of the gaps of the translator are posted to as JSON every
+		// Interval, to help the maintainers of the mapping tables prioritise the gaps found in real traffic. Only the
+		// kind, versions, ID and count of the block states, items, sounds, level events and particle effects that
+		// couldn't be translated are sent, never anything about players, backends or the proxy. If empty, the
+		// default, nothing is recorded or sent.
+		URL string
+		// Interval, such as "1h", is the interval at which the statistics are posted. Defaults to an hour.
+		Interval string
+	}
+}
+
+// DecodeConfig decodes the contents of config.toml passed, filling in the defaults of settings that are not set.
+// DecodeConfig(nil) returns the default config.
+func DecodeConfig(data []byte) (Config, error) {
+	return DecodeConfigWithOverrides(data, nil)
+}
+
+// fillDefaults fills in the defaults of the settings of the config passed that are not set.
+func fillDefaults(c *Config) {
+	if c.Connection.LocalAddress == "" {
+		c.Connection.LocalAddress = "0.0.0.0:19132"
+	}
+	if len(c.Status.Providers) == 0 {
+		c.Status.Providers = []string{"foreign", "static"}
+	}
+	if c.Status.ServerName == "" {
+		c.Status.ServerName = "Draco"
+	}
+	if c.Status.Interval == "" {
+		c.Status.Interval = "1s"
+	}
+	if c.Status.Timeout == "" {
+		c.Status.Timeout = "2s"
+	}
+	if c.ResourcePacks.CacheDirectory == "" {
+		c.ResourcePacks.CacheDirectory = "packs"
+	}
+	if c.Network.DialLimit.MaxConcurrent == 0 {
+		c.Network.DialLimit.MaxConcurrent = 16
+	}
+	if c.Network.DialLimit.PerSecond == 0 {
+		c.Network.DialLimit.PerSecond = 10
+	}
+	if c.Network.DialLimit.MaxWait == "" {
+		c.Network.DialLimit.MaxWait = "5s"
+	}
+	if c.Network.InventoryResync.Failures == 0 {
+		c.Network.InventoryResync.Failures = 3
+	}
+	if c.Network.InventoryResync.Window == "" {
+		c.Network.InventoryResync.Window = "10s"
+	}
+	if c.Guest.Prefix == "" {
+		c.Guest.Prefix = "Guest_"
+	}
+	if len(c.Backends) == 0 {
+		c.Backends = []proxy.Backend{{Name: "default", Address: c.Connection.RemoteAddress}}
+	}
+	if c.Connection.DevMode {
+		c.Connection.Offline, c.Connection.AuthenticationDisabled = true, true
+	}
+	if c.Connection.Offline {
+		for i := range c.Backends {
+			c.Backends[i].Offline = true
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package draco
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/cluster"
+	"github.com/cqdetdev/draco/draco/discovery"
+	"github.com/cqdetdev/draco/draco/proxy"
+)
+
+// discoveryRegistrar returns the discovery.Registrar configured in Discovery of the config passed.
+func discoveryRegistrar(c Config) (*discovery.Registrar, error) {
+	r := &discovery.Registrar{Kind: c.Discovery.Kind, URL: c.Discovery.URL, Key: c.Discovery.Key, Token: c.Discovery.Token, Interval: 10 * time.Second}
+	if c.Discovery.Interval != "" {
+		d, err := time.ParseDuration(c.Discovery.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("parse interval: %w", err)
+		}
+		r.Interval = d
+	}
+	return r, r.Validate()
+}
+
+// register registers the Proxy with the service discovery in the config passed until it is closed.
+func (p *Proxy) register(c Config) {
+	r, err := discoveryRegistrar(c)
+	if err != nil {
+		// The registrar was checked before the Proxy was created.
+		return
+	}
+	id := c.Forwarding.Node
+	if id == "" {
+		id, _ = os.Hostname()
+	}
+	address := c.Discovery.Address
+	if address == "" {
+		address = c.Connection.LocalAddress
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	p.mu.Lock()
+	p.stopDiscovery = func() {
+		cancel()
+		<-done
+	}
+	p.mu.Unlock()
+	go func() {
+		defer close(done)
+		r.Run(ctx, func() discovery.Instance {
+			inst := discovery.Instance{
+				ID:       id,
+				Address:  address,
+				Capacity: p.runningConfig().Connection.MaxConnections,
+				Players:  len(proxy.Sessions()),
+			}
+			if protocols := draco.Protocols(); len(protocols) > 0 {
+				// Protocols are ordered from the newest to the oldest.
+				newest, oldest := protocols[0], protocols[len(protocols)-1]
+				inst.MinProtocol, inst.MinVersion = oldest.ID(), oldest.Ver()
+				inst.MaxProtocol, inst.MaxVersion = newest.ID(), newest.Ver()
+			}
+			_, inst.Draining = cluster.Draining()
+			return inst
+		})
+	}()
+}
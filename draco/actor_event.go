@@ -0,0 +1,70 @@
+package draco
+
+import (
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/legacymappings"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// actorEventDataDowngrades holds functions that translate the EventData field of a 1.18.30 ActorEvent to its
+// 1.18.12 equivalent, keyed by the event type. The event types themselves are identical across the two versions,
+// but some events embed an item runtime ID in their data, which is not. Event types that are not present in this
+// table carry data that is identical across versions and are forwarded unchanged.
+var actorEventDataDowngrades = map[byte]func(data int32) int32{
+	packet.ActorEventFeed: downgradeItemEventData,
+}
+
+// actorEventDataUpgrades holds functions that translate the EventData field of a 1.18.12 ActorEvent, as sent by
+// clients when they eat, to its 1.18.30 equivalent, keyed by the event type.
+var actorEventDataUpgrades = map[byte]func(data int32) int32{
+	packet.ActorEventFeed: upgradeItemEventData,
+}
+
+// downgradeActorEventData translates the data of a 1.18.30 ActorEvent with the event type passed to the data
+// expected by a 1.18.12 client.
+func downgradeActorEventData(eventType byte, data int32) int32 {
+	if f, ok := actorEventDataDowngrades[eventType]; ok {
+		return f(data)
+	}
+	return data
+}
+
+// upgradeActorEventData translates the data of a 1.18.12 ActorEvent with the event type passed to the data
+// expected by a 1.18.30 server.
+func upgradeActorEventData(eventType byte, data int32) int32 {
+	if f, ok := actorEventDataUpgrades[eventType]; ok {
+		return f(data)
+	}
+	return data
+}
+
+// downgradeItemEventData translates event data that holds an item runtime ID in its upper 16 bits and the
+// metadata value of the item in its lower 16 bits, as used for the particles of a player eating. Unlike most
+// translations, an item that doesn't exist in 1.18.12 doesn't panic: the event is merely cosmetic, so the item is
+// replaced with air, which shows no particles.
+func downgradeItemEventData(data int32) int32 {
+	rid, meta := data>>16, data&0xffff
+	name, found := latestmappings.ItemRuntimeIDToName(rid)
+	if !found {
+		return meta
+	}
+	legacyRID, found := legacymappings.ItemNameToRuntimeID(name)
+	if !found {
+		return meta
+	}
+	return legacyRID<<16 | meta
+}
+
+// upgradeItemEventData is the opposite of downgradeItemEventData.
+func upgradeItemEventData(data int32) int32 {
+	rid, meta := data>>16, data&0xffff
+	name, found := legacymappings.ItemRuntimeIDToName(rid)
+	if !found {
+		return meta
+	}
+	latestRID, found := latestmappings.ItemNameToRuntimeID(name)
+	if !found {
+		return meta
+	}
+	return latestRID<<16 | meta
+}
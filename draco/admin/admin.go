@@ -0,0 +1,493 @@
+// Package admin serves an HTTP API that operators may use to debug and manage the sessions of the proxy at runtime.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/cluster"
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// maxPacketLogDuration is the longest duration that the packets of a session may be logged for at once.
+const maxPacketLogDuration = time.Hour
+
+// API serves the admin HTTP API. The secret of the API must be sent as a bearer token in the Authorization header
+// of all requests.
+type API struct {
+	secret   string
+	reload   func() error
+	handlers map[string]http.Handler
+}
+
+// NewAPI returns an API protected by the secret passed. reload is called to reload the config of the proxy. If nil,
+// the config can't be reloaded through the API.
+func NewAPI(secret string, reload func() error) *API {
+	return &API{secret: secret, reload: reload, handlers: map[string]http.Handler{}}
+}
+
+// Handle serves requests to the path passed using the handler passed once they are authenticated, so that the APIs
+// of other packages, such as that of the bans, may be served through the API. The handler is passed the request
+// as is, including its Authorization header. Handle must not be called once the API serves requests.
+func (a *API) Handle(path string, h http.Handler) {
+	a.handlers[path] = h
+}
+
+// player is an online player as listed in the response to requests to /players.
+type player struct {
+	Name     string `json:"name"`
+	XUID     string `json:"xuid"`
+	Role     string `json:"role"`
+	Backend  string `json:"backend"`
+	Protocol int32  `json:"protocol,omitempty"`
+	PingMS   int64  `json:"ping_ms"`
+	// TranslationErrors is the amount of packets of the player that couldn't be translated.
+	TranslationErrors uint64 `json:"translation_errors"`
+}
+
+// packetLogStatus is the response to requests to /packetlog.
+type packetLogStatus struct {
+	Name    string    `json:"name"`
+	XUID    string    `json:"xuid"`
+	Logging bool      `json:"logging"`
+	Until   time.Time `json:"until"`
+}
+
+// ServeHTTP serves the API. Players are selected using either the xuid or the name parameter, the latter of which
+// also selects guests. It supports the following requests:
+//
+//	GET    /packetlog?xuid=<xuid>                             responds with the packet logging status of a player
+//	POST   /packetlog?xuid=<xuid>&duration=30s[&ids=1,2,...]  logs the packets of a player, optionally by packet ID
+//	DELETE /packetlog?xuid=<xuid>                             stops logging the packets of a player
+//	POST   /transfer?xuid=<xuid>&backend=<name>               transfers a player to another backend
+//	GET    /latency?xuid=<xuid>                               responds with the latency the proxy added for a player
+//	GET    /quality?xuid=<xuid>                               responds with the connection quality of a player
+//	GET    /ping?xuid=<xuid>                                  responds with the round trip times of both legs of a player
+//	GET    /position?xuid=<xuid>                              responds with the last known position of a player
+//	GET    /memory?xuid=<xuid>                                responds with the memory the proxy holds for a player
+//	GET    /export?backend=<name>                             responds with the cached world of a backend as .mcworld
+//	GET    /players[?backend=<name>][&xuid=<xuid>]            responds with the players online, optionally on a backend
+//	GET    /disconnects                                       responds with the disconnects by cause, backend and version
+//	POST   /kick?xuid=<xuid>[&message=<message>]              disconnects a player, showing the message passed
+//	POST   /broadcast?message=<message>[&backend=<name>]      sends a chat message to all players, optionally on a backend
+//	POST   /bulk/kick[?message=<message>]                     starts a job disconnecting players
+//	POST   /bulk/broadcast?message=<message>                  starts a job sending a chat message to players
+//	POST   /bulk/transfer?to=<name>[&from=<name>]             starts a job transferring players to another backend
+//	GET    /jobs[?id=<id>]                                    responds with the progress of all jobs or of a single job
+//	DELETE /jobs?id=<id>                                      cancels a job
+//	POST   /reload                                            reloads the config of the proxy
+//	POST   /drain?node=<name>                                 migrates all players to another node of the cluster
+//	POST   /migrations                                        accepts the JSON list of players migrated by another node
+//
+// The players acted on by /bulk requests may be narrowed down using the backend and role parameters, and the actions
+// paced using the interval parameter, such as interval=50ms, which is 100ms for transfers by default.
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+a.secret)) != 1 {
+		http.Error(w, "unauthorised", http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/export":
+		export(w, r)
+		return
+	case "/players":
+		players(w, r)
+		return
+	case "/disconnects":
+		disconnects(w, r)
+		return
+	case "/broadcast":
+		broadcast(w, r)
+		return
+	case "/bulk/kick", "/bulk/broadcast", "/bulk/transfer":
+		bulk(w, r)
+		return
+	case "/jobs":
+		listJobs(w, r)
+		return
+	case "/reload":
+		a.reloadConfig(w, r)
+		return
+	case "/drain":
+		drain(w, r)
+		return
+	case "/migrations":
+		migrations(w, r)
+		return
+	case "/packetlog", "/transfer", "/latency", "/quality", "/ping", "/position", "/memory", "/kick":
+	default:
+		if h, ok := a.handlers[r.URL.Path]; ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	s, ok := session(q.Get("xuid"), q.Get("name"))
+	if !ok {
+		http.Error(w, "player not online", http.StatusNotFound)
+		return
+	}
+	switch r.URL.Path {
+	case "/transfer":
+		transfer(w, r, s)
+		return
+	case "/latency":
+		latency(w, r, s)
+		return
+	case "/quality":
+		quality(w, r, s)
+		return
+	case "/ping":
+		ping(w, r, s)
+		return
+	case "/position":
+		position(w, r, s)
+		return
+	case "/memory":
+		memory(w, r, s)
+		return
+	case "/kick":
+		kick(w, r, s)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		d, err := time.ParseDuration(q.Get("duration"))
+		if err != nil || d <= 0 || d > maxPacketLogDuration {
+			http.Error(w, "invalid duration", http.StatusBadRequest)
+			return
+		}
+		ids, err := parseIDs(q.Get("ids"))
+		if err != nil {
+			http.Error(w, "invalid packet IDs", http.StatusBadRequest)
+			return
+		}
+		s.LogPackets(d, ids...)
+	case http.MethodDelete:
+		s.LogPackets(0)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	status := packetLogStatus{Name: s.Name(), XUID: s.XUID()}
+	status.Until, status.Logging = s.LoggingPackets()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// transfer serves a request to transfer the Session passed to another backend. It responds once the transfer
+// succeeded or failed.
+func transfer(w http.ResponseWriter, r *http.Request, s *proxy.Session) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	b, ok := proxy.BackendByName(r.URL.Query().Get("backend"))
+	if !ok {
+		http.Error(w, "no such backend", http.StatusNotFound)
+		return
+	}
+	switch err := s.Transfer(b); {
+	case errors.Is(err, proxy.ErrAlreadyConnected), errors.Is(err, proxy.ErrBackendFull), errors.Is(err, proxy.ErrResourcePacks):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, proxy.ErrDialLimited):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// latencyReport is the response to requests to /latency.
+type latencyReport struct {
+	Name           string              `json:"name"`
+	XUID           string              `json:"xuid"`
+	ServerToClient proxy.LatencyReport `json:"server_to_client"`
+	ClientToServer proxy.LatencyReport `json:"client_to_server"`
+}
+
+// latency serves a request for the latency that the proxy added to the packets of the Session passed.
+func latency(w http.ResponseWriter, r *http.Request, s *proxy.Session) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(latencyReport{
+		Name:           s.Name(),
+		XUID:           s.XUID(),
+		ServerToClient: s.Latency(proxy.ServerToClient),
+		ClientToServer: s.Latency(proxy.ClientToServer),
+	})
+}
+
+// qualityReport is the response to requests to /quality.
+type qualityReport struct {
+	Name string `json:"name"`
+	XUID string `json:"xuid"`
+	proxy.ConnectionQuality
+}
+
+// quality serves a request for the quality of the connections of the Session passed.
+func quality(w http.ResponseWriter, r *http.Request, s *proxy.Session) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(qualityReport{Name: s.Name(), XUID: s.XUID(), ConnectionQuality: s.ConnectionQuality()})
+}
+
+// pingReport is the response to requests to /ping.
+type pingReport struct {
+	Name    string `json:"name"`
+	XUID    string `json:"xuid"`
+	Backend string `json:"backend"`
+	proxy.PingReport
+}
+
+// ping serves a request for the round trip times between the client of the Session passed and the proxy, and between
+// the proxy and its backend.
+func ping(w http.ResponseWriter, r *http.Request, s *proxy.Session) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(pingReport{Name: s.Name(), XUID: s.XUID(), Backend: s.Backend().Name, PingReport: s.Ping()})
+}
+
+// positionReport is the response to requests to /position.
+type positionReport struct {
+	Name string `json:"name"`
+	XUID string `json:"xuid"`
+	proxy.Position
+}
+
+// position serves a request for the last known position of the player of the Session passed.
+func position(w http.ResponseWriter, r *http.Request, s *proxy.Session) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(positionReport{Name: s.Name(), XUID: s.XUID(), Position: s.Position()})
+}
+
+// memoryReport is the response to requests to /memory.
+type memoryReport struct {
+	Name  string `json:"name"`
+	XUID  string `json:"xuid"`
+	Total int    `json:"total"`
+	proxy.Footprint
+}
+
+// memory serves a request for the estimated memory that the proxy holds for the state of a player.
+func memory(w http.ResponseWriter, r *http.Request, s *proxy.Session) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	f := s.Footprint()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(memoryReport{Name: s.Name(), XUID: s.XUID(), Total: f.Total(), Footprint: f})
+}
+
+// export serves a request to export the world made up of the chunks cached of a backend. The world is sent as a
+// .mcworld file.
+func export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	b, ok := proxy.BackendByName(r.URL.Query().Get("backend"))
+	if !ok {
+		http.Error(w, "no such backend", http.StatusNotFound)
+		return
+	}
+	world, err := proxy.CachedWorld(b)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", b.Name+".mcworld"))
+	if err := world.WriteZip(w); err != nil {
+		logging.Default().Warn("error exporting world", "backend", b.Name, "err", err)
+	}
+}
+
+// players serves a request for the players online, sorted by name. If the xuid parameter is set, only the player
+// with that XUID is listed, which other nodes of the cluster use to check if a player is online.
+func players(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	list := make([]player, 0)
+	xuid := r.URL.Query().Get("xuid")
+	for _, s := range onBackend(r.URL.Query().Get("backend")) {
+		if xuid != "" && s.XUID() != xuid {
+			continue
+		}
+		p := player{Name: s.Name(), XUID: s.XUID(), Role: s.Role().String(), Backend: s.Backend().Name, PingMS: s.Client().Latency().Milliseconds()}
+		p.Protocol, _ = proxy.ClientProtocol(s.Client())
+		p.TranslationErrors = s.TranslationErrors(proxy.ServerToClient) + s.TranslationErrors(proxy.ClientToServer)
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// disconnects serves a request for the amount of disconnects since the proxy started by their cause, both in total
+// and by backend and game version, so that the causes of most disconnects may be looked into first.
+func disconnects(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(proxy.Disconnects())
+}
+
+// kick serves a request to disconnect the Session passed. The player is shown the message passed, or a translated
+// default message if none was passed.
+func kick(w http.ResponseWriter, r *http.Request, s *proxy.Session) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	message := r.URL.Query().Get("message")
+	if message == "" {
+		message = s.Translate("disconnect.kicked")
+	}
+	s.Disconnect(message)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// broadcast serves a request to send a chat message to all players online, or to those on a single backend.
+func broadcast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	message := r.URL.Query().Get("message")
+	if message == "" {
+		http.Error(w, "no message", http.StatusBadRequest)
+		return
+	}
+	proxy.Broadcast(onBackend(r.URL.Query().Get("backend")), &packet.Text{TextType: packet.TextTypeRaw, Message: message})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reloadConfig serves a request to reload the config of the proxy.
+func (a *API) reloadConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.reload == nil {
+		http.Error(w, "reloading is not supported", http.StatusNotImplemented)
+		return
+	}
+	if err := a.reload(); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// drain serves a request to migrate all players to another node of the cluster, responding with the amount of
+// players migrated.
+func drain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	n, err := cluster.Drain(r.URL.Query().Get("node"))
+	if errors.Is(err, cluster.ErrUnknownNode) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]int{"migrated": n})
+}
+
+// migrations serves a request of a draining node holding the players it is about to migrate to this node.
+func migrations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var list []cluster.Migration
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&list); err != nil {
+		http.Error(w, "invalid migrations: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	cluster.Accept(list)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// onBackend returns the sessions online on the backend with the name passed, ignoring case, or all sessions online
+// if the name is empty.
+func onBackend(name string) []*proxy.Session {
+	var list []*proxy.Session
+	for _, s := range proxy.Sessions() {
+		if name == "" || strings.EqualFold(s.Backend().Name, name) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// session looks up the online player with the XUID passed, or with the name passed if the XUID is empty.
+func session(xuid, name string) (*proxy.Session, bool) {
+	if xuid != "" {
+		return proxy.SessionByXUID(xuid)
+	}
+	if name == "" {
+		return nil, false
+	}
+	for _, s := range proxy.Sessions() {
+		if strings.EqualFold(s.Name(), name) {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// parseIDs parses a comma separated list of packet IDs, such as "1,2,3". An empty list results in no IDs.
+func parseIDs(list string) ([]uint32, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var ids []uint32
+	for _, s := range strings.Split(list, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, uint32(id))
+	}
+	return ids, nil
+}
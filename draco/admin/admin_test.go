@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/proxy"
+)
+
+// serve serves a request with the method, path and secret passed using the API passed, returning the response.
+func serve(a *API, method, path, secret string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	if secret != "" {
+		r.Header.Set("Authorization", "Bearer "+secret)
+	}
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	return w
+}
+
+func TestAPI(t *testing.T) {
+	reloads := 0
+	var reloadErr error
+	a := NewAPI("secret", func() error {
+		reloads++
+		return reloadErr
+	})
+
+	if w := serve(a, http.MethodGet, "/players", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("request without secret: got status %v, expected %v", w.Code, http.StatusUnauthorized)
+	}
+	if w := serve(a, http.MethodGet, "/players", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("request with wrong secret: got status %v, expected %v", w.Code, http.StatusUnauthorized)
+	}
+	if w := serve(a, http.MethodGet, "/players", "secret"); w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("players: got status %v and body %q, expected %v and []", w.Code, w.Body, http.StatusOK)
+	}
+	if w := serve(a, http.MethodGet, "/disconnects", "secret"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"causes"`) {
+		t.Errorf("disconnects: got status %v and body %q, expected %v and the breakdown", w.Code, w.Body, http.StatusOK)
+	}
+	if w := serve(a, http.MethodPost, "/kick?name=Nobody", "secret"); w.Code != http.StatusNotFound {
+		t.Errorf("kick of player offline: got status %v, expected %v", w.Code, http.StatusNotFound)
+	}
+	if w := serve(a, http.MethodGet, "/ping?name=Nobody", "secret"); w.Code != http.StatusNotFound {
+		t.Errorf("ping of player offline: got status %v, expected %v", w.Code, http.StatusNotFound)
+	}
+	if w := serve(a, http.MethodGet, "/position?name=Nobody", "secret"); w.Code != http.StatusNotFound {
+		t.Errorf("position of player offline: got status %v, expected %v", w.Code, http.StatusNotFound)
+	}
+	if w := serve(a, http.MethodPost, "/broadcast", "secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("broadcast without message: got status %v, expected %v", w.Code, http.StatusBadRequest)
+	}
+	if w := serve(a, http.MethodPost, "/broadcast?message=hi", "secret"); w.Code != http.StatusNoContent {
+		t.Errorf("broadcast: got status %v, expected %v", w.Code, http.StatusNoContent)
+	}
+
+	if w := serve(a, http.MethodGet, "/reload", "secret"); w.Code != http.StatusMethodNotAllowed || reloads != 0 {
+		t.Errorf("reload using GET: got status %v and %v reloads, expected %v and none", w.Code, reloads, http.StatusMethodNotAllowed)
+	}
+	if w := serve(a, http.MethodPost, "/reload", "secret"); w.Code != http.StatusNoContent || reloads != 1 {
+		t.Errorf("reload: got status %v and %v reloads, expected %v and 1", w.Code, reloads, http.StatusNoContent)
+	}
+	reloadErr = errors.New("invalid config")
+	if w := serve(a, http.MethodPost, "/reload", "secret"); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "invalid config") {
+		t.Errorf("failed reload: got status %v and body %q, expected %v and the error", w.Code, w.Body, http.StatusUnprocessableEntity)
+	}
+	if w := serve(NewAPI("secret", nil), http.MethodPost, "/reload", "secret"); w.Code != http.StatusNotImplemented {
+		t.Errorf("reload without reload func: got status %v, expected %v", w.Code, http.StatusNotImplemented)
+	}
+}
+
+func TestJobs(t *testing.T) {
+	a := NewAPI("secret", nil)
+	if w := serve(a, http.MethodPost, "/bulk/transfer?to=nowhere", "secret"); w.Code != http.StatusNotFound {
+		t.Errorf("transfer to unknown backend: got status %v, expected %v", w.Code, http.StatusNotFound)
+	}
+	if w := serve(a, http.MethodPost, "/bulk/kick?role=admin", "secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("kick of unknown role: got status %v, expected %v", w.Code, http.StatusBadRequest)
+	}
+	w := serve(a, http.MethodPost, "/bulk/broadcast?message=hi&role=guest", "secret")
+	if w.Code != http.StatusAccepted || !strings.Contains(w.Body.String(), `"kind":"broadcast"`) {
+		t.Errorf("broadcast: got status %v and body %q, expected %v and the job", w.Code, w.Body, http.StatusAccepted)
+	}
+
+	// The sessions are never used by the action, so a job may be run without players online.
+	acted := make(chan struct{}, 3)
+	j := startJob("test", make([]*proxy.Session, 3), time.Minute, func(*proxy.Session) error {
+		acted <- struct{}{}
+		return nil
+	})
+	<-acted
+	if w := serve(a, http.MethodDelete, "/jobs?id="+strconv.FormatInt(j.id, 10), "secret"); w.Code != http.StatusOK {
+		t.Fatalf("cancel: got status %v, expected %v", w.Code, http.StatusOK)
+	}
+	deadline := time.Now().Add(time.Second)
+	for j.status().State != "cancelled" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if st := j.status(); st.State != "cancelled" || st.Done != 1 || st.Total != 3 {
+		t.Errorf("cancelled job: got %+v, expected 1 of 3 players done", st)
+	}
+	if w := serve(a, http.MethodGet, "/jobs?id=0", "secret"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown job: got status %v, expected %v", w.Code, http.StatusNotFound)
+	}
+}
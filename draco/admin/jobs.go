@@ -0,0 +1,333 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+const (
+	// defaultTransferInterval is the interval between the transfers of a bulk transfer if none is passed, so that
+	// the backend transferred to isn't flooded with hundreds of logins at once.
+	defaultTransferInterval = 100 * time.Millisecond
+	// maxJobInterval is the longest interval between the actions of a job.
+	maxJobInterval = time.Minute
+	// maxJobErrors is the maximum amount of errors remembered per job.
+	maxJobErrors = 20
+	// maxFinishedJobs is the amount of finished jobs remembered. Older finished jobs are forgotten.
+	maxFinishedJobs = 50
+)
+
+// job is a bulk action performed on a list of sessions in the background, such as kicking all players of a backend.
+// Its progress is reported through /jobs.
+type job struct {
+	id       int64
+	kind     string
+	started  time.Time
+	cancel   chan struct{}
+	stopOnce sync.Once
+
+	mu        sync.Mutex
+	total     int
+	done      int
+	failed    int
+	errors    []string
+	cancelled bool
+	finished  time.Time
+}
+
+// jobStatus is the progress of a job as reported through /jobs.
+type jobStatus struct {
+	ID    int64  `json:"id"`
+	Kind  string `json:"kind"`
+	State string `json:"state"`
+	// Total is the amount of players that the job acts on, of which Done were acted on successfully and Failed
+	// unsuccessfully. The reasons of the first failures are listed in Errors.
+	Total    int       `json:"total"`
+	Done     int       `json:"done"`
+	Failed   int       `json:"failed"`
+	Errors   []string  `json:"errors,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+}
+
+var (
+	// jobsMu guards jobs and lastJobID.
+	jobsMu sync.Mutex
+	// jobs holds the jobs running and the last maxFinishedJobs jobs finished, keyed by their ID.
+	jobs      = map[int64]*job{}
+	lastJobID int64
+)
+
+// startJob starts a job of the kind passed that calls act for every Session passed, waiting the interval passed
+// between starting each call. Calls may run concurrently if they take longer than the interval. The job is returned
+// immediately.
+func startJob(kind string, sessions []*proxy.Session, interval time.Duration, act func(s *proxy.Session) error) *job {
+	jobsMu.Lock()
+	lastJobID++
+	j := &job{id: lastJobID, kind: kind, started: time.Now(), cancel: make(chan struct{}), total: len(sessions)}
+	jobs[j.id] = j
+	jobsMu.Unlock()
+
+	go j.run(sessions, interval, act)
+	return j
+}
+
+// run runs the job until all sessions were acted on or until it is cancelled.
+func (j *job) run(sessions []*proxy.Session, interval time.Duration, act func(s *proxy.Session) error) {
+	var wg sync.WaitGroup
+loop:
+	for i, s := range sessions {
+		if i > 0 && interval > 0 {
+			select {
+			case <-time.After(interval):
+			case <-j.cancel:
+				break loop
+			}
+		}
+		select {
+		case <-j.cancel:
+			break loop
+		default:
+		}
+		wg.Add(1)
+		go func(s *proxy.Session) {
+			defer wg.Done()
+			j.record(s, act(s))
+		}(s)
+	}
+	wg.Wait()
+
+	j.mu.Lock()
+	j.finished = time.Now()
+	j.mu.Unlock()
+	forgetFinishedJobs()
+}
+
+// record records the result of acting on the Session passed.
+func (j *job) record(s *proxy.Session, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err == nil {
+		j.done++
+		return
+	}
+	j.failed++
+	if len(j.errors) < maxJobErrors {
+		j.errors = append(j.errors, s.Name()+": "+err.Error())
+	}
+}
+
+// stop cancels the job if it is still running. Actions already started are not interrupted.
+func (j *job) stop() {
+	j.stopOnce.Do(func() {
+		j.mu.Lock()
+		j.cancelled = j.finished.IsZero()
+		j.mu.Unlock()
+		close(j.cancel)
+	})
+}
+
+// status returns the jobStatus of the job.
+func (j *job) status() jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st := jobStatus{
+		ID:       j.id,
+		Kind:     j.kind,
+		State:    "running",
+		Total:    j.total,
+		Done:     j.done,
+		Failed:   j.failed,
+		Errors:   append([]string(nil), j.errors...),
+		Started:  j.started,
+		Finished: j.finished,
+	}
+	switch {
+	case j.cancelled && !j.finished.IsZero():
+		st.State = "cancelled"
+	case j.cancelled:
+		st.State = "cancelling"
+	case !j.finished.IsZero():
+		st.State = "finished"
+	}
+	return st
+}
+
+// forgetFinishedJobs forgets the oldest finished jobs if more than maxFinishedJobs finished.
+func forgetFinishedJobs() {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	var finished []*job
+	for _, j := range jobs {
+		j.mu.Lock()
+		if !j.finished.IsZero() {
+			finished = append(finished, j)
+		}
+		j.mu.Unlock()
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].id < finished[j].id
+	})
+	for _, j := range finished[:len(finished)-maxFinishedJobs] {
+		delete(jobs, j.id)
+	}
+}
+
+// jobByID looks up the job with the ID passed.
+func jobByID(id string) (*job, bool) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	j, ok := jobs[n]
+	return j, ok
+}
+
+// listJobs serves a request for the jobs running and recently finished, or for a single job if the id parameter is
+// passed. A DELETE request cancels the job with the id passed.
+func listJobs(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	switch r.Method {
+	case http.MethodGet:
+		if id == "" {
+			jobsMu.Lock()
+			list := make([]jobStatus, 0, len(jobs))
+			for _, j := range jobs {
+				list = append(list, j.status())
+			}
+			jobsMu.Unlock()
+			sort.Slice(list, func(i, j int) bool {
+				return list[i].ID < list[j].ID
+			})
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(list)
+			return
+		}
+	case http.MethodDelete:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	j, ok := jobByID(id)
+	if !ok {
+		http.Error(w, "no such job", http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		j.stop()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(j.status())
+}
+
+// bulk serves a request to act on many players at once, which is started as a job. It responds with the jobStatus of
+// the job, the progress of which may then be followed through /jobs. The players are selected using the backend and
+// role parameters, both of which are optional.
+func bulk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	backend := q.Get("backend")
+	if r.URL.Path == "/bulk/transfer" {
+		backend = q.Get("from")
+	}
+	if backend != "" {
+		if _, ok := proxy.BackendByName(backend); !ok {
+			http.Error(w, "no such backend", http.StatusNotFound)
+			return
+		}
+	}
+	sessions := onBackend(backend)
+	if name := q.Get("role"); name != "" {
+		role, ok := proxy.ParseRole(name)
+		if !ok {
+			http.Error(w, "no such role", http.StatusBadRequest)
+			return
+		}
+		sessions = withRole(sessions, role)
+	}
+	var interval time.Duration
+	if r.URL.Path == "/bulk/transfer" {
+		interval = defaultTransferInterval
+	}
+	if v := q.Get("interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 || d > maxJobInterval {
+			http.Error(w, "invalid interval", http.StatusBadRequest)
+			return
+		}
+		interval = d
+	}
+
+	var act func(s *proxy.Session) error
+	switch r.URL.Path {
+	case "/bulk/kick":
+		message := q.Get("message")
+		act = func(s *proxy.Session) error {
+			if message == "" {
+				s.Disconnect(s.Translate("disconnect.kicked"))
+			} else {
+				s.Disconnect(message)
+			}
+			return nil
+		}
+	case "/bulk/broadcast":
+		message := q.Get("message")
+		if message == "" {
+			http.Error(w, "no message", http.StatusBadRequest)
+			return
+		}
+		pk := &packet.Text{TextType: packet.TextTypeRaw, Message: message}
+		act = func(s *proxy.Session) error {
+			proxy.Broadcast([]*proxy.Session{s}, pk)
+			return nil
+		}
+	case "/bulk/transfer":
+		to, ok := proxy.BackendByName(q.Get("to"))
+		if !ok {
+			http.Error(w, "no such backend", http.StatusNotFound)
+			return
+		}
+		act = func(s *proxy.Session) error {
+			err := s.Transfer(to)
+			if errors.Is(err, proxy.ErrAlreadyConnected) {
+				// The player got to the backend by other means in the meantime.
+				return nil
+			}
+			return err
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	j := startJob(r.URL.Path[len("/bulk/"):], sessions, interval, act)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(j.status())
+}
+
+// withRole returns the sessions passed of which the player has the Role passed.
+func withRole(sessions []*proxy.Session, role proxy.Role) []*proxy.Session {
+	var list []*proxy.Session
+	for _, s := range sessions {
+		if s.Role() == role {
+			list = append(list, s)
+		}
+	}
+	return list
+}
@@ -0,0 +1,373 @@
+package ban
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Kind is the kind of an Entry: a punishment, or an exemption from the whitelist.
+type Kind string
+
+const (
+	// KindBan prevents a player from joining the proxy.
+	KindBan Kind = "ban"
+	// KindMute prevents a player from chatting.
+	KindMute Kind = "mute"
+	// KindWhitelist allows a player to join the proxy while the whitelist is enabled.
+	KindWhitelist Kind = "whitelist"
+)
+
+// Entry is a ban, mute or whitelisting of a single player.
+type Entry struct {
+	// XUID is the XBOX Live user ID of the player. It is empty if the entry was created for the gamertag of a
+	// player that had not joined yet, in which case it is set once the player joins.
+	XUID string `json:"xuid"`
+	// Name is the gamertag of the player at the time the entry was created.
+	Name string `json:"name,omitempty"`
+	// Kind is the kind of the entry.
+	Kind Kind `json:"kind"`
+	// Reason is the reason shown to the player.
+	Reason string `json:"reason,omitempty"`
+	// Created is the time the entry was created.
+	Created time.Time `json:"created"`
+	// Expires is the time after which the entry is lifted. If zero, the entry is permanent.
+	Expires time.Time `json:"expires,omitempty"`
+	// Source is the URL of the Source that the entry was fetched from, or empty if it was created on the proxy.
+	Source string `json:"source,omitempty"`
+}
+
+// Expired checks if the Entry has expired at the time passed.
+func (e Entry) Expired(t time.Time) bool {
+	return !e.Expires.IsZero() && !t.Before(e.Expires)
+}
+
+// key is the key of an Entry in a Store. Entries are keyed by XUID, or by their lowercase name if they have no XUID.
+type key struct {
+	xuid, name string
+	kind       Kind
+}
+
+// keyOf returns the key of the Entry passed.
+func keyOf(e Entry) key {
+	if e.XUID != "" {
+		return key{xuid: e.XUID, kind: e.Kind}
+	}
+	return key{name: strings.ToLower(e.Name), kind: e.Kind}
+}
+
+// sweepInterval is the interval at which expired entries are removed from a Store.
+const sweepInterval = time.Minute
+
+// Store holds the bans, mutes and whitelisted players and persists them to a JSON file. Expired entries are lifted
+// automatically. Players are looked up by XUID, or by gamertag for entries created before they joined. A Store is
+// safe for concurrent use.
+type Store struct {
+	path string
+
+	mu        sync.Mutex
+	entries   map[key]Entry
+	whitelist bool
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+// Open opens the Store persisted in the file at the path passed, creating it if it does not exist, and starts
+// removing expired entries from it in the background. Open also registers the handlers that enforce mutes.
+// Bans and the whitelist must be enforced by calling Banned and Allowed when a player joins.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, entries: map[key]Entry{}, closed: make(chan struct{})}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read bans: %w", err)
+	}
+	if len(data) > 0 {
+		var entries []Entry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("decode bans: %w", err)
+		}
+		for _, e := range entries {
+			s.entries[keyOf(e)] = e
+		}
+	}
+	go s.sweep()
+
+	proxy.Handle(proxy.ClientToServer, func(sess *proxy.Session, pk *packet.Text) proxy.Action {
+		e, ok := s.Muted(sess.XUID(), sess.Name())
+		if !ok {
+			return proxy.Forward
+		}
+		_ = sess.Client().WritePacket(&packet.Text{TextType: packet.TextTypeRaw, Message: Message(sess.Translate, e)})
+		return proxy.Drop
+	})
+	return s, nil
+}
+
+// Add adds an Entry of the Kind passed for the player with the XUID or, if empty, the gamertag passed, replacing any
+// existing entry of the same Kind. If d is 0, the entry is permanent. If the player is banned while online, they
+// are disconnected.
+func (s *Store) Add(kind Kind, xuid, name, reason string, d time.Duration) (Entry, error) {
+	if xuid == "" && name == "" {
+		return Entry{}, errors.New("xuid or name must be set")
+	}
+	if kind != KindBan && kind != KindMute && kind != KindWhitelist {
+		return Entry{}, fmt.Errorf("unknown kind %q", kind)
+	}
+	e := Entry{XUID: xuid, Name: name, Kind: kind, Reason: reason, Created: time.Now()}
+	if d > 0 {
+		e.Expires = e.Created.Add(d)
+	}
+	s.mu.Lock()
+	s.entries[keyOf(e)] = e
+	err := s.saveLocked()
+	s.mu.Unlock()
+
+	if kind == KindBan {
+		kick(e)
+	}
+	return e, err
+}
+
+// kick disconnects the players online that the ban passed applies to.
+func kick(e Entry) {
+	for _, sess := range proxy.Sessions() {
+		if (e.XUID != "" && sess.XUID() == e.XUID) || (e.XUID == "" && sess.XUID() != "" && strings.EqualFold(sess.Name(), e.Name)) {
+			sess.Disconnect(Message(sess.Translate, e))
+		}
+	}
+}
+
+// Remove lifts the entries of the Kind passed for the player with the XUID passed or, if empty, for the gamertag
+// passed. False is returned if there were no such entries.
+func (s *Store) Remove(kind Kind, xuid, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := false
+	for k, e := range s.entries {
+		if e.Kind != kind {
+			continue
+		}
+		if (xuid != "" && e.XUID == xuid) || (xuid == "" && name != "" && strings.EqualFold(e.Name, name)) {
+			delete(s.entries, k)
+			removed = true
+		}
+	}
+	if !removed {
+		return false, nil
+	}
+	return true, s.saveLocked()
+}
+
+// Banned returns the ban of the player with the XUID and gamertag passed, if they are banned.
+func (s *Store) Banned(xuid, name string) (Entry, bool) {
+	return s.lookup(KindBan, xuid, name)
+}
+
+// Muted returns the mute of the player with the XUID and gamertag passed, if they are muted.
+func (s *Store) Muted(xuid, name string) (Entry, bool) {
+	return s.lookup(KindMute, xuid, name)
+}
+
+// Whitelisted returns the whitelisting of the player with the XUID and gamertag passed, if they are whitelisted.
+func (s *Store) Whitelisted(xuid, name string) (Entry, bool) {
+	return s.lookup(KindWhitelist, xuid, name)
+}
+
+// SetWhitelist enables or disables the whitelist. While it is enabled, only whitelisted players may join. Players
+// online that are not whitelisted are not disconnected when it is enabled.
+func (s *Store) SetWhitelist(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.whitelist = enabled
+}
+
+// WhitelistEnabled checks if the whitelist is enabled.
+func (s *Store) WhitelistEnabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.whitelist
+}
+
+// Allowed checks if the player with the XUID and gamertag passed may join according to the whitelist: all players
+// may join while it is disabled. Players without an XUID, such as guests, can't be whitelisted, as their gamertag
+// is not verified.
+func (s *Store) Allowed(xuid, name string) bool {
+	if !s.WhitelistEnabled() {
+		return true
+	}
+	if xuid == "" {
+		return false
+	}
+	_, ok := s.Whitelisted(xuid, name)
+	return ok
+}
+
+// Entries returns all entries in the Store that have not expired, sorted by the time they were created.
+func (s *Store) Entries() []Entry {
+	now := time.Now()
+	s.mu.Lock()
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		if !e.Expired(now) {
+			entries = append(entries, e)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Created.Before(entries[j].Created)
+	})
+	return entries
+}
+
+// EntriesOf returns all entries of the player with the XUID passed, including expired entries not yet lifted, sorted
+// by the time they were created. Entries created for the gamertag of a player are only bound to its XUID once the
+// player joins, so entries of players that didn't join since are not returned.
+func (s *Store) EntriesOf(xuid string) []Entry {
+	s.mu.Lock()
+	var entries []Entry
+	for _, e := range s.entries {
+		if e.XUID == xuid {
+			entries = append(entries, e)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Created.Before(entries[j].Created)
+	})
+	return entries
+}
+
+// Purge removes all entries of the player with the XUID passed, lifting its bans and mutes, and returns the amount
+// of entries removed. The Store is saved right away if any were removed.
+func (s *Store) Purge(xuid string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for k, e := range s.entries {
+		if e.XUID == xuid {
+			delete(s.entries, k)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.saveLocked()
+}
+
+// Close stops removing expired entries from the Store. Close always returns nil.
+func (s *Store) Close() error {
+	s.once.Do(func() {
+		close(s.closed)
+	})
+	return nil
+}
+
+// lookup looks up the Entry of the Kind passed for the player with the XUID passed, or for its gamertag if it has no
+// Entry by XUID, returning false if it does not exist or has expired. An Entry found by gamertag is bound to the
+// XUID passed, so that it still applies after the player changes its gamertag.
+func (s *Store) lookup(kind Kind, xuid, name string) (Entry, bool) {
+	if xuid == "" {
+		return Entry{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key{xuid: xuid, kind: kind}]
+	if !ok && name != "" {
+		k := key{name: strings.ToLower(name), kind: kind}
+		if e, ok = s.entries[k]; ok {
+			delete(s.entries, k)
+			e.XUID = xuid
+			s.entries[keyOf(e)] = e
+			_ = s.saveLocked()
+		}
+	}
+	if !ok || e.Expired(time.Now()) {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+// sweep removes expired entries from the Store every sweepInterval until it is closed.
+func (s *Store) sweep() {
+	t := time.NewTicker(sweepInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.removeExpired(time.Now())
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// removeExpired removes all entries that have expired at the time passed, saving the Store if any were removed.
+func (s *Store) removeExpired(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := false
+	for k, e := range s.entries {
+		if e.Expired(t) {
+			delete(s.entries, k)
+			removed = true
+		}
+	}
+	if removed {
+		_ = s.saveLocked()
+	}
+}
+
+// saveLocked writes all entries to the file of the Store. The file is replaced atomically, so that it is never left
+// half written. s.mu must be held when calling saveLocked.
+func (s *Store) saveLocked() error {
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Created.Before(entries[j].Created)
+	})
+	data, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		return fmt.Errorf("encode bans: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("save bans: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save bans: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save bans: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("save bans: %w", err)
+	}
+	return nil
+}
+
+// Message returns the message shown to a player with the Entry passed, translated using the function passed, such
+// as Session.Translate.
+func Message(translate func(key string, args ...any) string, e Entry) string {
+	if e.Expires.IsZero() {
+		return translate("ban."+string(e.Kind), e.Reason)
+	}
+	return translate("ban."+string(e.Kind)+"_until", e.Expires.Format("2006-01-02 15:04 MST"), e.Reason)
+}
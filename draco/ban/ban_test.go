@@ -0,0 +1,116 @@
+package ban
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err := s.Add(KindBan, "", "", "", 0); err == nil {
+		t.Error("entry without XUID and name was added")
+	}
+	if _, err := s.Add(KindBan, "", "Griefer", "griefing", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Banned("1", "Someone"); ok {
+		t.Error("player banned by the name of another player")
+	}
+	e, ok := s.Banned("2", "griefer")
+	if !ok || e.Reason != "griefing" {
+		t.Fatalf("player not banned by name: %#v", e)
+	}
+	// The ban was bound to the XUID of the player, so it still applies after a change of gamertag.
+	if e, ok := s.Banned("2", "Renamed"); !ok || e.XUID != "2" {
+		t.Errorf("ban not bound to XUID: %#v", e)
+	}
+
+	if _, err := s.Add(KindMute, "3", "Spammer", "", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 5)
+	if _, ok := s.Muted("3", "Spammer"); ok {
+		t.Error("expired mute still applies")
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if _, ok := reopened.Banned("2", "Renamed"); !ok {
+		t.Error("ban not persisted")
+	}
+	if removed, err := reopened.Remove(KindBan, "", "GRIEFER"); err != nil || !removed {
+		t.Errorf("removing ban by name returned %v, %v", removed, err)
+	}
+	if _, ok := reopened.Banned("2", "Griefer"); ok {
+		t.Error("ban still applies after it was removed")
+	}
+}
+
+func TestWhitelist(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "bans.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if !s.Allowed("1", "Player") {
+		t.Error("player not allowed while the whitelist is disabled")
+	}
+	s.SetWhitelist(true)
+	if s.Allowed("1", "Player") {
+		t.Error("player allowed while not whitelisted")
+	}
+	if _, err := s.Add(KindWhitelist, "", "Player", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Allowed("1", "player") {
+		t.Error("whitelisted player not allowed")
+	}
+	if s.Allowed("", "Player") {
+		t.Error("player without XUID allowed by name")
+	}
+}
+
+func TestPurge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	_, _ = s.Add(KindBan, "1", "Griefer", "griefing", 0)
+	_, _ = s.Add(KindMute, "1", "Griefer", "spamming", 0)
+	_, _ = s.Add(KindBan, "2", "Other", "", 0)
+
+	if entries := s.EntriesOf("1"); len(entries) != 2 {
+		t.Fatalf("expected 2 entries of the player, got %#v", entries)
+	}
+	if n, err := s.Purge("1"); err != nil || n != 2 {
+		t.Fatalf("expected 2 entries to be purged, got %v (%v)", n, err)
+	}
+	if _, ok := s.Banned("1", "Griefer"); ok {
+		t.Error("ban still applies after it was purged")
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if entries := reopened.EntriesOf("1"); len(entries) != 0 {
+		t.Errorf("purge not persisted: %#v", entries)
+	}
+	if _, ok := reopened.Banned("2", "Other"); !ok {
+		t.Error("ban of another player was purged")
+	}
+}
@@ -0,0 +1,118 @@
+package ban
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API serves an HTTP API for managing the entries and the whitelist of a Store. The secret of the API must be sent as a
+// bearer token in the Authorization header of all requests.
+type API struct {
+	store  *Store
+	secret string
+}
+
+// NewAPI returns an API for the Store passed, protected by the secret passed.
+func NewAPI(store *Store, secret string) *API {
+	return &API{store: store, secret: secret}
+}
+
+// ServeHTTP serves the API. It supports the following requests:
+//
+//	GET    /bans                                                     responds with a JSON array of all entries
+//	GET    /bans?xuid=<xuid>|name=<name>                             responds with the entries of a player
+//	POST   /bans?xuid=<xuid>|name=<name>&kind=<ban|mute|whitelist>   bans, mutes or whitelists a player, optionally
+//	       [&duration=1h][&reason=...]                               for a duration and with a reason
+//	DELETE /bans?xuid=<xuid>|name=<name>&kind=<ban|mute|whitelist>   lifts the ban, mute or whitelisting of a player
+//	GET    /whitelist                                                responds with whether the whitelist is enabled
+//	POST   /whitelist?enabled=<true|false>                           enables or disables the whitelist
+//
+// Players may be identified by gamertag to create entries for players that have not joined yet.
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+a.secret)) != 1 {
+		http.Error(w, "unauthorised", http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/bans":
+	case "/whitelist":
+		a.serveWhitelist(w, r)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	xuid, name, kind := q.Get("xuid"), q.Get("name"), Kind(q.Get("kind"))
+	switch r.Method {
+	case http.MethodGet:
+		entries := a.store.Entries()
+		if xuid != "" || name != "" {
+			filtered := entries[:0]
+			for _, e := range entries {
+				if (xuid != "" && e.XUID == xuid) || (xuid == "" && strings.EqualFold(e.Name, name)) {
+					filtered = append(filtered, e)
+				}
+			}
+			entries = filtered
+		}
+		writeJSON(w, http.StatusOK, entries)
+	case http.MethodPost:
+		var d time.Duration
+		if s := q.Get("duration"); s != "" {
+			var err error
+			if d, err = time.ParseDuration(s); err != nil || d < 0 {
+				http.Error(w, "invalid duration", http.StatusBadRequest)
+				return
+			}
+		}
+		e, err := a.store.Add(kind, xuid, name, q.Get("reason"), d)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusCreated, e)
+	case http.MethodDelete:
+		removed, err := a.store.Remove(kind, xuid, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !removed {
+			http.Error(w, "no such entry", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// serveWhitelist serves a request to /whitelist.
+func (a *API) serveWhitelist(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			http.Error(w, "invalid enabled", http.StatusBadRequest)
+			return
+		}
+		a.store.SetWhitelist(enabled)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"enabled": a.store.WhitelistEnabled()})
+}
+
+// writeJSON writes v to w as JSON with the status code passed.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,173 @@
+package ban
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/logging"
+)
+
+// Source is an HTTP endpoint that a Store periodically fetches entries and the state of the whitelist from, such as
+// a web panel managing the access to a network of proxies. The endpoint must respond to GET requests with a JSON
+// Snapshot.
+type Source struct {
+	// URL is the URL of the endpoint. It must use HTTPS, unless PublicKey is set.
+	URL string
+	// Interval is the interval at which the endpoint is fetched.
+	Interval time.Duration
+	// PublicKey is the Ed25519 public key that responses are signed with. If set, the X-Signature header of every
+	// response must hold the base64 encoded signature of its body, and responses without a valid signature are
+	// rejected.
+	PublicKey ed25519.PublicKey
+	// Client is the HTTP client used to fetch the endpoint. If nil, a client with a timeout of 10 seconds is used.
+	Client *http.Client
+}
+
+// Snapshot is the body of a response of a Source.
+type Snapshot struct {
+	// Whitelist specifies if the whitelist is enabled. If absent, the whitelist is left as it is.
+	Whitelist *bool `json:"whitelist,omitempty"`
+	// Entries holds all entries of the Source. Entries fetched from the Source before that it no longer holds are
+	// removed from the Store.
+	Entries []Entry `json:"entries"`
+}
+
+// maxSnapshotSize is the maximum size of the body of a response of a Source.
+const maxSnapshotSize = 16 << 20
+
+// Sync fetches the Source passed every Source.Interval until the Store is closed, replacing the entries fetched from
+// it before with those it holds. Entries created on the proxy itself, such as using Add, are kept. Responses are
+// cached using their ETag, so that unchanged snapshots are not sent again. Players banned by a Source while online
+// are disconnected. An error is returned if the Source is invalid.
+func (s *Store) Sync(src Source) error {
+	u, err := url.Parse(src.URL)
+	if err != nil {
+		return fmt.Errorf("parse source URL: %w", err)
+	}
+	if u.Scheme != "https" && src.PublicKey == nil {
+		return errors.New("sources without a public key must use HTTPS")
+	}
+	if src.PublicKey != nil && len(src.PublicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("public key must be %v bytes long", ed25519.PublicKeySize)
+	}
+	if src.Interval <= 0 {
+		return errors.New("sync interval must be positive")
+	}
+	if src.Client == nil {
+		src.Client = &http.Client{Timeout: time.Second * 10}
+	}
+	go func() {
+		t := time.NewTicker(src.Interval)
+		defer t.Stop()
+		etag := ""
+		for {
+			var err error
+			if etag, err = s.fetch(src, etag); err != nil {
+				logging.Default().Warn("error syncing bans", "source", src.URL, "err", err)
+			}
+			select {
+			case <-t.C:
+			case <-s.closed:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// fetch fetches the Source passed once and applies the Snapshot it responds with. The ETag of the Snapshot applied
+// last is passed, and the ETag of the Snapshot applied is returned.
+func (s *Store) fetch(src Source, etag string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, src.URL, nil)
+	if err != nil {
+		return etag, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := src.Client.Do(req)
+	if err != nil {
+		return etag, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return etag, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return etag, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSnapshotSize+1))
+	if err != nil {
+		return etag, err
+	}
+	if len(data) > maxSnapshotSize {
+		return etag, fmt.Errorf("snapshot exceeds %v bytes", maxSnapshotSize)
+	}
+	if src.PublicKey != nil {
+		sig, err := base64.StdEncoding.DecodeString(resp.Header.Get("X-Signature"))
+		if err != nil || !ed25519.Verify(src.PublicKey, data, sig) {
+			return etag, errors.New("invalid signature")
+		}
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return etag, fmt.Errorf("decode snapshot: %w", err)
+	}
+	if err := s.apply(src.URL, snapshot); err != nil {
+		return etag, err
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// apply replaces the entries of the Store fetched from the source with the URL passed with those of the Snapshot
+// passed, and applies its whitelist state.
+func (s *Store) apply(source string, snapshot Snapshot) error {
+	var banned []Entry
+	s.mu.Lock()
+	for k, e := range s.entries {
+		if e.Source == source {
+			delete(s.entries, k)
+		}
+	}
+	for _, e := range snapshot.Entries {
+		if e.XUID == "" && e.Name == "" {
+			continue
+		}
+		if e.Kind != KindBan && e.Kind != KindMute && e.Kind != KindWhitelist {
+			continue
+		}
+		if e.Created.IsZero() {
+			e.Created = time.Now()
+		}
+		e.Source = source
+		k := keyOf(e)
+		if existing, ok := s.entries[k]; ok && existing.Source == "" {
+			// Entries created on the proxy take precedence over those of sources.
+			continue
+		}
+		s.entries[k] = e
+		if e.Kind == KindBan {
+			banned = append(banned, e)
+		}
+	}
+	if snapshot.Whitelist != nil {
+		s.whitelist = *snapshot.Whitelist
+	}
+	err := s.saveLocked()
+	s.mu.Unlock()
+
+	now := time.Now()
+	for _, e := range banned {
+		if !e.Expired(now) {
+			kick(e)
+		}
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package ban
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestSync(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"whitelist": true, "entries": [{"xuid": "1", "kind": "ban", "reason": "panel"}, {"xuid": "2", "kind": "whitelist"}]}`)
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"1"`)
+		w.Header().Set("X-Signature", base64.StdEncoding.EncodeToString(ed25519.Sign(priv, body)))
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	s, err := Open(filepath.Join(t.TempDir(), "bans.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Add(KindBan, "3", "", "local", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Sync(Source{URL: srv.URL, Interval: 1}); err == nil {
+		t.Error("expected an unsigned source without HTTPS to be rejected")
+	}
+
+	src := Source{URL: srv.URL, PublicKey: pub, Client: srv.Client()}
+	etag, err := s.fetch(src, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := s.Banned("1", ""); !ok || e.Reason != "panel" || e.Source != srv.URL {
+		t.Errorf("expected the ban of the source to apply, got %#v", e)
+	}
+	if !s.Allowed("2", "") || s.Allowed("3", "") {
+		t.Error("expected the whitelist of the source to apply")
+	}
+	if etag, err = s.fetch(src, etag); err != nil || etag != `"1"` || requests != 2 {
+		t.Errorf("expected the snapshot to be cached by its ETag, got %v, %v", etag, err)
+	}
+
+	// The source no longer holds any entries, so only the entry created on the proxy is left.
+	if err := s.apply(srv.URL, Snapshot{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Banned("1", ""); ok {
+		t.Error("expected a ban removed from the source to be lifted")
+	}
+	if _, ok := s.Banned("3", ""); !ok {
+		t.Error("expected the ban created on the proxy to be kept")
+	}
+
+	_, other, _ := ed25519.GenerateKey(nil)
+	body = []byte(`{"entries": []}`)
+	priv = other
+	if _, err := s.fetch(src, ""); err == nil {
+		t.Error("expected a snapshot with an invalid signature to be rejected")
+	}
+}
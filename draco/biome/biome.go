@@ -0,0 +1,180 @@
+// Package biome holds the biomes of every protocol version known to draco, with lookups between the names and IDs
+// of biomes. Biome IDs are the IDs stored in the biome storages of chunks.
+package biome
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/fallback"
+	"github.com/cqdetdev/draco/draco/state"
+)
+
+// Biome is a biome of a single version.
+type Biome struct {
+	// Name is the name of the biome, such as "plains" or "mesa".
+	Name string
+	// ID is the numerical ID of the biome, as stored in chunks.
+	ID int32
+}
+
+// registry holds the biomes of a single version.
+type registry struct {
+	ids   map[string]int32
+	names map[int32]string
+}
+
+var (
+	// registryMu guards registries and fallbacks.
+	registryMu sync.RWMutex
+	// registries holds the biomes registered using Register, keyed by their version.
+	registries = map[state.Version]registry{}
+	// fallbacks maps the names of biomes to the names of similar biomes that existed before them. Biomes are
+	// translated to their fallback for versions that don't have them, instead of being shown as plains.
+	fallbacks = map[string]string{
+		"bamboo_jungle":       "jungle",
+		"bamboo_jungle_hills": "jungle_hills",
+		"soulsand_valley":     "hell",
+		"crimson_forest":      "hell",
+		"warped_forest":       "hell",
+		"basalt_deltas":       "hell",
+		"jagged_peaks":        "frozen_peaks",
+		"frozen_peaks":        "ice_mountains",
+		"snowy_slopes":        "ice_plains",
+		"grove":               "cold_taiga",
+		"meadow":              "plains",
+		"stony_peaks":         "extreme_hills",
+		"lush_caves":          "plains",
+		"dripstone_caves":     "plains",
+		"deep_dark":           "dripstone_caves",
+		"mangrove_swamp":      "swampland",
+		"cherry_grove":        "meadow",
+		"pale_garden":         "roofed_forest",
+	}
+)
+
+// Default is the name of the biome that biomes without an equivalent or fallback are translated to.
+const Default = "plains"
+
+// Register registers the biomes of the version passed, keyed by their name. Registering the biomes of a version
+// that already has biomes replaces them. Biomes are generally registered in the init function of the package
+// holding them.
+func Register(v state.Version, biomes map[string]int32) {
+	r := registry{ids: make(map[string]int32, len(biomes)), names: make(map[int32]string, len(biomes))}
+	for name, id := range biomes {
+		r.ids[name] = id
+		r.names[id] = name
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registries[v] = r
+}
+
+// RegisterFallbacks registers the fallbacks of biomes, keyed by the name of the biome. A biome is translated to its
+// fallback for versions that don't have it, or to the fallback of its fallback if that doesn't exist either.
+// Registering the fallback of a biome that already has one replaces it.
+func RegisterFallbacks(f map[string]string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for name, fallback := range f {
+		fallbacks[name] = fallback
+	}
+}
+
+// Fallback returns the fallback registered for the biome with the name passed. False is returned if the biome has
+// no fallback.
+func Fallback(name string) (string, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	fallback, ok := fallbacks[name]
+	return fallback, ok
+}
+
+// registryOf returns the biomes registered for the version passed.
+func registryOf(v state.Version) (registry, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	r, ok := registries[v]
+	return r, ok
+}
+
+// Registered checks if the version passed has biomes registered.
+func Registered(v state.Version) bool {
+	_, ok := registryOf(v)
+	return ok
+}
+
+// Versions returns all versions that have biomes registered, from the oldest to the newest.
+func Versions() []state.Version {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	versions := make([]state.Version, 0, len(registries))
+	for v := range registries {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] < versions[j]
+	})
+	return versions
+}
+
+// Biomes returns all biomes of the version passed, ordered by their ID. It returns nil if the version has no biomes
+// registered.
+func Biomes(v state.Version) []Biome {
+	r, ok := registryOf(v)
+	if !ok {
+		return nil
+	}
+	biomes := make([]Biome, 0, len(r.ids))
+	for name, id := range r.ids {
+		biomes = append(biomes, Biome{Name: name, ID: id})
+	}
+	sort.Slice(biomes, func(i, j int) bool {
+		return biomes[i].ID < biomes[j].ID
+	})
+	return biomes
+}
+
+// ID looks up the ID of the biome with the name passed in the version passed.
+func ID(v state.Version, name string) (int32, bool) {
+	r, ok := registryOf(v)
+	if !ok {
+		return 0, false
+	}
+	id, ok := r.ids[name]
+	return id, ok
+}
+
+// Name looks up the name of the biome with the ID passed in the version passed.
+func Name(v state.Version, id int32) (string, bool) {
+	r, ok := registryOf(v)
+	if !ok {
+		return "", false
+	}
+	name, ok := r.names[id]
+	return name, ok
+}
+
+// TranslateID translates the ID of a biome of the version from to the ID of the biome with the same name in the
+// version to. Biomes that don't exist in the version to are translated to their fallback, as registered using
+// RegisterFallbacks. False is returned if either version has no biomes registered, or if neither the biome nor any of
+// its fallbacks exist in the version to.
+func TranslateID(from, to state.Version, id int32) (int32, bool) {
+	if from == to {
+		return id, true
+	}
+	name, ok := Name(from, id)
+	if !ok {
+		return 0, false
+	}
+	r, ok := registryOf(to)
+	if !ok {
+		return 0, false
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return fallback.Resolve(name, fallbacks, func(name string) (int32, bool) {
+		other, ok := r.ids[name]
+		return other, ok
+	})
+}
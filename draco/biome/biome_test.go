@@ -0,0 +1,38 @@
+package biome
+
+import "testing"
+
+func TestTranslateID(t *testing.T) {
+	Register(-1, map[string]int32{"plains": 1, "removed": 2})
+	Register(-2, map[string]int32{"plains": 3})
+
+	if id, ok := ID(-1, "plains"); !ok || id != 1 {
+		t.Errorf("plains has ID %v (found: %v), expected 1", id, ok)
+	}
+	if name, ok := Name(-2, 3); !ok || name != "plains" {
+		t.Errorf("biome 3 has name %q (found: %v), expected plains", name, ok)
+	}
+	if id, ok := TranslateID(-1, -2, 1); !ok || id != 3 {
+		t.Errorf("plains translated to %v (found: %v), expected 3", id, ok)
+	}
+	if _, ok := TranslateID(-1, -2, 2); ok {
+		t.Error("biome without equivalent was translated")
+	}
+	if b := Biomes(-1); len(b) != 2 || b[0].Name != "plains" {
+		t.Errorf("unexpected biomes %v", b)
+	}
+}
+
+func TestTranslateIDFallback(t *testing.T) {
+	Register(-3, map[string]int32{"plains": 1, "new_cave": 2, "newer_cave": 3})
+	Register(-4, map[string]int32{"plains": 4, "old_cave": 5})
+	RegisterFallbacks(map[string]string{"newer_cave": "new_cave", "new_cave": "old_cave"})
+
+	if id, ok := TranslateID(-3, -4, 3); !ok || id != 5 {
+		t.Errorf("biome translated to %v (found: %v) through its fallbacks, expected 5", id, ok)
+	}
+	RegisterFallbacks(map[string]string{"new_cave": "newer_cave"})
+	if _, ok := TranslateID(-3, -4, 3); ok {
+		t.Error("biome with looping fallbacks was translated")
+	}
+}
@@ -0,0 +1,92 @@
+package draco
+
+import (
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/chunk"
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/legacymappings"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// blobKind is the kind of data held by a blob of the client blob cache.
+type blobKind uint8
+
+const (
+	blobSubChunk blobKind = iota + 1
+	blobBiomes
+)
+
+// maxBlobs is the maximum amount of blobs of which the kind is remembered. Once exceeded, the blobs remembered are
+// forgotten, after which blobs of chunks sent before are forwarded without being translated.
+const maxBlobs = 1 << 18
+
+var (
+	// blobMu guards blobs.
+	blobMu sync.Mutex
+	// blobs holds the kind of the blobs of all chunks sent using the client blob cache, keyed by their hash. Blobs
+	// are content addressed, so the kind of a hash is the same for all connections.
+	blobs = map[uint64]blobKind{}
+)
+
+// rememberBlobs remembers the kind of the blobs referred to by a LevelChunk or SubChunk packet sent using the client
+// blob cache, so that the blobs can be translated once the server sends them in a ClientCacheMissResponse packet.
+// The blobs of a LevelChunk packet are its sub chunks, followed by its biomes.
+func rememberBlobs(pk packet.Packet) {
+	blobMu.Lock()
+	defer blobMu.Unlock()
+	if len(blobs) >= maxBlobs {
+		blobs = map[uint64]blobKind{}
+	}
+	switch pk := pk.(type) {
+	case *packet.LevelChunk:
+		for i, hash := range pk.BlobHashes {
+			if i == len(pk.BlobHashes)-1 {
+				blobs[hash] = blobBiomes
+			} else {
+				blobs[hash] = blobSubChunk
+			}
+		}
+	case *packet.SubChunk:
+		for _, e := range pk.SubChunkEntries {
+			if e.Result == protocol.SubChunkResultSuccess {
+				blobs[e.BlobHash] = blobSubChunk
+			}
+		}
+	}
+}
+
+// translateBlobs translates the blobs of a ClientCacheMissResponse packet from the latest version to 1.18.10. The
+// hashes of the blobs are kept, so that they match the hashes of the chunks sent before: the hash of a blob is only
+// used by the client to look it up, and every client only ever receives blobs translated to its own version. Blobs
+// of which the kind is not known are forwarded as they are. Block runtime IDs are translated using the palettes of
+// the state.Registry passed.
+func translateBlobs(blocks *state.Registry, pk *packet.ClientCacheMissResponse) {
+	if palettesIdentical(blocks) && identicalBiomes && !translatesBlockEntities() {
+		return
+	}
+	for i, b := range pk.Blobs {
+		blobMu.Lock()
+		kind := blobs[b.Hash]
+		blobMu.Unlock()
+
+		var (
+			payload []byte
+			err     error
+		)
+		switch kind {
+		case blobSubChunk:
+			payload, err = chunk.TranslateSubChunk(blocks, b.Payload, worldRange, latestmappings.Version, legacymappings.Version)
+		case blobBiomes:
+			payload, err = chunk.TranslateBiomes(b.Payload, worldRange, latestmappings.Version, legacymappings.Version)
+		default:
+			continue
+		}
+		if err != nil {
+			panic(err)
+		}
+		pk.Blobs[i].Payload = payload
+	}
+}
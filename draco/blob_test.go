@@ -0,0 +1,35 @@
+package draco
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestRememberBlobs(t *testing.T) {
+	rememberBlobs(&packet.LevelChunk{CacheEnabled: true, BlobHashes: []uint64{101, 102, 103}})
+	rememberBlobs(&packet.SubChunk{CacheEnabled: true, SubChunkEntries: []protocol.SubChunkEntry{
+		{Result: protocol.SubChunkResultSuccess, BlobHash: 104},
+		{Result: protocol.SubChunkResultChunkNotFound, BlobHash: 105},
+	}})
+	for hash, want := range map[uint64]blobKind{101: blobSubChunk, 102: blobSubChunk, 103: blobBiomes, 104: blobSubChunk, 105: 0} {
+		blobMu.Lock()
+		kind := blobs[hash]
+		blobMu.Unlock()
+		if kind != want {
+			t.Errorf("blob %v: expected kind %v, got %v", hash, want, kind)
+		}
+	}
+}
+
+func TestTranslateUnknownBlobs(t *testing.T) {
+	payload := []byte{1, 2, 3}
+	pk := &packet.ClientCacheMissResponse{Blobs: []protocol.CacheBlob{{Hash: 999, Payload: payload}}}
+	translateBlobs(state.Default(), pk)
+	if pk.Blobs[0].Hash != 999 || !bytes.Equal(pk.Blobs[0].Payload, payload) {
+		t.Errorf("expected blob of unknown kind to be forwarded as it is, got %v", pk.Blobs[0])
+	}
+}
@@ -0,0 +1,61 @@
+// Package blockentity translates the NBT of block entities, such as signs, chests and command blocks, between
+// protocol versions. Translators are registered per block entity type and pair of versions, and are applied to the
+// block entities of chunks and to BlockActorData packets.
+package blockentity
+
+import (
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/state"
+)
+
+// Translator translates the NBT of a single block entity from one version to another, modifying the map passed.
+type Translator func(data map[string]any)
+
+// direction is a pair of versions that block entities are translated between.
+type direction struct {
+	from, to state.Version
+}
+
+var (
+	// translatorMu guards translators.
+	translatorMu sync.RWMutex
+	// translators holds the translators registered using Register, keyed by the versions they translate between and
+	// the ID of the block entity type they translate.
+	translators = map[direction]map[string][]Translator{}
+)
+
+// Register registers a Translator for block entities with the ID passed, such as "Sign", translating their NBT from
+// the version from to the version to. Multiple translators may be registered for the same block entity type, in
+// which case they are applied in the order they were registered in. Translators are generally registered in the init
+// function of the package holding them.
+func Register(from, to state.Version, id string, t Translator) {
+	translatorMu.Lock()
+	defer translatorMu.Unlock()
+	d := direction{from: from, to: to}
+	if translators[d] == nil {
+		translators[d] = map[string][]Translator{}
+	}
+	translators[d][id] = append(translators[d][id], t)
+}
+
+// Translates checks if any translators are registered to translate block entities from the version from to the
+// version to. If not, block entities may be forwarded without being decoded.
+func Translates(from, to state.Version) bool {
+	translatorMu.RLock()
+	defer translatorMu.RUnlock()
+	return len(translators[direction{from: from, to: to}]) > 0
+}
+
+// Translate translates the NBT of the block entity passed from the version from to the version to, using the
+// translators registered for its type. The type of the block entity is read from its "id" tag. Block entities of
+// types without translators are left unchanged.
+func Translate(from, to state.Version, data map[string]any) {
+	id, _ := data["id"].(string)
+	translatorMu.RLock()
+	ts := translators[direction{from: from, to: to}][id]
+	translatorMu.RUnlock()
+	for _, t := range ts {
+		t(data)
+	}
+}
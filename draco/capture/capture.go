@@ -0,0 +1,459 @@
+// Package capture implements a file format for recording the packets forwarded by the proxy, so that the sessions
+// of players may be replayed through the translation layer offline, without a live client. A capture holds a header
+// followed by records, each of which is either a Session, written when a session is first captured, or a Packet of
+// a session written before.
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// magic and version make up the header that every capture starts with: magic is followed by the version of the
+// format.
+const (
+	magic   = "DRACOCAP"
+	version = 1
+)
+
+const (
+	// recordSession and recordPacket are the kinds of records in a capture.
+	recordSession byte = iota
+	recordPacket
+)
+
+// maxRecordSize is the maximum size of a byte slice in a record read. Larger sizes are treated as a corrupt
+// capture rather than allocated.
+const maxRecordSize = 64 << 20
+
+// Session is a session captured. Its ID is unique within the capture and identifies the packets of the session.
+type Session struct {
+	ID uint32
+	// Name and XUID are the display name and XBOX Live user ID of the player of the session.
+	Name, XUID string
+	// Backend is the name of the backend that the player was connected to when the session was first captured.
+	Backend string
+	// Protocol is the protocol ID of the client of the session.
+	Protocol int32
+	// Time is the time at which the session was first captured.
+	Time time.Time
+}
+
+// Packet is a packet captured.
+type Packet struct {
+	// Session is the ID of the Session that the packet was forwarded for.
+	Session uint32
+	// ClientToServer specifies if the packet was sent by the client. If false, it was sent by the server.
+	ClientToServer bool
+	// Time is the time at which the packet was forwarded.
+	Time time.Time
+	// Data holds the packet header followed by the payload of the packet, encoded in the latest protocol.
+	Data []byte
+}
+
+// Writer writes sessions and packets to a capture. It is safe for concurrent use.
+type Writer struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	c   io.Closer
+	err error
+	// path is the path of the file of a Writer returned by Create, and purged holds the IDs of the sessions purged
+	// from it using Purge, of which packets are no longer written.
+	path   string
+	purged map[uint32]struct{}
+
+	once   sync.Once
+	closed chan struct{}
+}
+
+// NewWriter returns a Writer writing a capture to the io.Writer passed. The records written are buffered, so Flush
+// must be called to write them.
+func NewWriter(w io.Writer) (*Writer, error) {
+	bw := bufio.NewWriterSize(w, 64<<10)
+	if _, err := bw.WriteString(magic); err != nil {
+		return nil, err
+	}
+	if err := bw.WriteByte(version); err != nil {
+		return nil, err
+	}
+	return &Writer{w: bw, closed: make(chan struct{})}, nil
+}
+
+// Create creates the file at the path passed, replacing it if it exists, and returns a Writer writing a capture to
+// it. The records written are flushed to the file every second until the Writer is closed.
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWriter(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	w.c, w.path = f, path
+	go w.flushPeriodically()
+	return w, nil
+}
+
+// WriteSession writes a Session to the capture.
+func (w *Writer) WriteSession(s Session) error {
+	buf := bytes.NewBuffer(make([]byte, 0, 64))
+	buf.WriteByte(recordSession)
+	writeUvarint(buf, uint64(s.ID))
+	writeString(buf, s.Name)
+	writeString(buf, s.XUID)
+	writeString(buf, s.Backend)
+	writeVarint(buf, int64(s.Protocol))
+	writeVarint(buf, s.Time.UnixNano())
+	return w.write(buf.Bytes())
+}
+
+// WritePacket writes a Packet to the capture. Packets of sessions purged using Purge are not written.
+func (w *Writer) WritePacket(pk Packet) error {
+	w.mu.Lock()
+	_, purged := w.purged[pk.Session]
+	w.mu.Unlock()
+	if purged {
+		return nil
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, len(pk.Data)+24))
+	buf.WriteByte(recordPacket)
+	writeUvarint(buf, uint64(pk.Session))
+	if pk.ClientToServer {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+	writeVarint(buf, pk.Time.UnixNano())
+	writeUvarint(buf, uint64(len(pk.Data)))
+	buf.Write(pk.Data)
+	return w.write(buf.Bytes())
+}
+
+// write writes a single encoded record. Once a write failed, all later writes return the same error.
+func (w *Writer) write(record []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	_, w.err = w.w.Write(record)
+	return w.err
+}
+
+// Flush writes all records buffered to the underlying io.Writer.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.w.Flush()
+	return w.err
+}
+
+// Close flushes the Writer and closes the file of a Writer returned by Create.
+func (w *Writer) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.closed)
+		err = w.Flush()
+		if w.c != nil {
+			if cerr := w.c.Close(); err == nil {
+				err = cerr
+			}
+		}
+	})
+	return err
+}
+
+// Summary is a Session in a capture file together with the amount of packets captured of it.
+type Summary struct {
+	Session
+	Packets int
+}
+
+// SessionsOf returns the sessions of the player with the XUID passed in the file written by a Writer returned by
+// Create, with the amount of packets captured of every session.
+func (w *Writer) SessionsOf(xuid string) ([]Summary, error) {
+	if w.path == "" {
+		return nil, errors.New("capture is not written to a file")
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(w.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []Summary
+	index := map[uint32]int{}
+	for {
+		record, err := r.Next()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// A record may be cut off if it is being written while the file is read.
+			return sessions, nil
+		} else if err != nil {
+			return nil, err
+		}
+		switch record := record.(type) {
+		case Session:
+			if record.XUID == xuid {
+				index[record.ID] = len(sessions)
+				sessions = append(sessions, Summary{Session: record})
+			}
+		case Packet:
+			if i, ok := index[record.Session]; ok {
+				sessions[i].Packets++
+			}
+		}
+	}
+}
+
+// Purge removes the sessions of the player with the XUID passed and their packets from the file written by a Writer
+// returned by Create, and returns the amount of sessions removed. Packets of these sessions written after Purge
+// returns are left out as well. The file is rewritten without them, during which no records can be written.
+func (w *Writer) Purge(xuid string) (int, error) {
+	if w.path == "" {
+		return 0, errors.New("capture is not written to a file")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.err = w.w.Flush(); w.err != nil {
+		return 0, w.err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), filepath.Base(w.path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	removed, err := w.copyExcept(tmp, xuid)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), w.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return 0, err
+	}
+	// The file written so far was replaced, so records written from now on must be appended to the new file.
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		w.err = err
+		return removed, err
+	}
+	_ = w.c.Close()
+	w.w, w.c = bufio.NewWriterSize(f, 64<<10), f
+	return removed, nil
+}
+
+// copyExcept copies the capture in the file of the Writer to the io.Writer passed, except for the sessions of the
+// player with the XUID passed and their packets, which are added to w.purged. It returns the amount of sessions left
+// out. w.mu must be held when calling copyExcept.
+func (w *Writer) copyExcept(dst io.Writer, xuid string) (int, error) {
+	f, err := os.Open(w.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r, err := NewReader(f)
+	if err != nil {
+		return 0, err
+	}
+	cw, err := NewWriter(dst)
+	if err != nil {
+		return 0, err
+	}
+	if w.purged == nil {
+		w.purged = map[uint32]struct{}{}
+	}
+	removed := 0
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return removed, cw.Flush()
+		} else if err != nil {
+			return 0, err
+		}
+		switch record := record.(type) {
+		case Session:
+			if record.XUID == xuid {
+				w.purged[record.ID] = struct{}{}
+				removed++
+				continue
+			}
+			err = cw.WriteSession(record)
+		case Packet:
+			if _, ok := w.purged[record.Session]; ok {
+				continue
+			}
+			err = cw.WritePacket(record)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// flushPeriodically flushes the Writer every second until it is closed.
+func (w *Writer) flushPeriodically() {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			_ = w.Flush()
+		case <-w.closed:
+			return
+		}
+	}
+}
+
+// Reader reads the sessions and packets of a capture.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader returns a Reader reading a capture from the io.Reader passed. An error is returned if the data read is
+// not a capture or of an unsupported version.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, errors.New("not a capture")
+	}
+	if header[len(magic)] != version {
+		return nil, fmt.Errorf("unsupported capture version %v", header[len(magic)])
+	}
+	return &Reader{r: br}, nil
+}
+
+// Next reads the next record of the capture, which is either a Session or a Packet. io.EOF is returned once all
+// records were read. A capture cut off in the middle of a record, such as one of a proxy that crashed, results in
+// io.ErrUnexpectedEOF.
+func (r *Reader) Next() (any, error) {
+	kind, err := r.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	var record any
+	switch kind {
+	case recordSession:
+		record, err = r.readSession()
+	case recordPacket:
+		record, err = r.readPacket()
+	default:
+		return nil, fmt.Errorf("unknown record kind %v", kind)
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return record, err
+}
+
+// readSession reads a Session record after its kind.
+func (r *Reader) readSession() (Session, error) {
+	var s Session
+	id, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return s, err
+	}
+	s.ID = uint32(id)
+	for _, str := range []*string{&s.Name, &s.XUID, &s.Backend} {
+		if *str, err = r.readString(); err != nil {
+			return s, err
+		}
+	}
+	proto, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return s, err
+	}
+	s.Protocol = int32(proto)
+	t, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return s, err
+	}
+	s.Time = time.Unix(0, t)
+	return s, nil
+}
+
+// readPacket reads a Packet record after its kind.
+func (r *Reader) readPacket() (Packet, error) {
+	var pk Packet
+	id, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return pk, err
+	}
+	pk.Session = uint32(id)
+	dir, err := r.r.ReadByte()
+	if err != nil {
+		return pk, err
+	}
+	pk.ClientToServer = dir == 1
+	t, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return pk, err
+	}
+	pk.Time = time.Unix(0, t)
+	pk.Data, err = r.readBytes()
+	return pk, err
+}
+
+// readString reads a string prefixed with its length.
+func (r *Reader) readString() (string, error) {
+	b, err := r.readBytes()
+	return string(b), err
+}
+
+// readBytes reads a byte slice prefixed with its length.
+func (r *Reader) readBytes() ([]byte, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxRecordSize {
+		return nil, fmt.Errorf("record of %v bytes exceeds maximum size", n)
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(r.r, b)
+	return b, err
+}
+
+// writeUvarint writes an unsigned varint to the buffer passed.
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+// writeVarint writes a signed varint to the buffer passed.
+func writeVarint(buf *bytes.Buffer, v int64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutVarint(b[:], v)])
+}
+
+// writeString writes a string prefixed with its length to the buffer passed.
+func writeString(buf *bytes.Buffer, s string) {
+	writeUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
@@ -0,0 +1,109 @@
+package capture
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	now := time.Unix(0, time.Now().UnixNano())
+	records := []any{
+		Session{ID: 1, Name: "Steve", XUID: "123", Backend: "lobby", Protocol: 486, Time: now},
+		Packet{Session: 1, ClientToServer: true, Time: now, Data: []byte{0x01, 0x02}},
+		Packet{Session: 1, Time: now.Add(time.Second), Data: []byte{}},
+	}
+	buf := bytes.NewBuffer(nil)
+	w, err := NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		switch record := record.(type) {
+		case Session:
+			err = w.WriteSession(record)
+		case Packet:
+			err = w.WritePacket(record)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range records {
+		record, err := r.Next()
+		if err != nil {
+			t.Fatalf("record %v: %v", i, err)
+		}
+		if !reflect.DeepEqual(record, expected) {
+			t.Errorf("record %v: expected %+v, got %+v", i, expected, record)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF after the last record, got %v", err)
+	}
+
+	r, _ = NewReader(bytes.NewReader(data[:len(data)-1]))
+	for i := 0; i < len(records)-1; i++ {
+		_, _ = r.Next()
+	}
+	if _, err := r.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF for a truncated capture, got %v", err)
+	}
+	if _, err := NewReader(bytes.NewReader([]byte("not a capture"))); err == nil {
+		t.Error("expected an error reading data that is not a capture")
+	}
+}
+
+func TestPurge(t *testing.T) {
+	now := time.Unix(0, time.Now().UnixNano())
+	path := filepath.Join(t.TempDir(), "capture.bin")
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	_ = w.WriteSession(Session{ID: 1, Name: "Steve", XUID: "123", Time: now})
+	_ = w.WriteSession(Session{ID: 2, Name: "Alex", XUID: "456", Time: now})
+	_ = w.WritePacket(Packet{Session: 1, Time: now, Data: []byte{0x01}})
+	_ = w.WritePacket(Packet{Session: 2, Time: now, Data: []byte{0x02}})
+	_ = w.WritePacket(Packet{Session: 1, Time: now, Data: []byte{0x03}})
+
+	sessions, err := w.SessionsOf("123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != 1 || sessions[0].Packets != 2 {
+		t.Fatalf("expected session 1 with 2 packets, got %+v", sessions)
+	}
+
+	if n, err := w.Purge("123"); err != nil || n != 1 {
+		t.Fatalf("expected 1 session to be purged, got %v (%v)", n, err)
+	}
+	// Packets of the sessions purged are no longer written, while those of other sessions are appended.
+	_ = w.WritePacket(Packet{Session: 1, Time: now, Data: []byte{0x04}})
+	_ = w.WritePacket(Packet{Session: 2, Time: now, Data: []byte{0x05}})
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sessions, _ := w.SessionsOf("123"); len(sessions) != 0 {
+		t.Errorf("expected no sessions of the player purged, got %+v", sessions)
+	}
+	sessions, _ = w.SessionsOf("456")
+	if len(sessions) != 1 || sessions[0].Packets != 2 {
+		t.Errorf("expected the other session to be kept with 2 packets, got %+v", sessions)
+	}
+}
@@ -0,0 +1,90 @@
+package chunk
+
+import (
+	"math"
+	"sync"
+)
+
+// The chunks that the proxy translates are decoded into a Chunk, SubChunks and PalettedStorages that are thrown away
+// as soon as the chunk is encoded again. With hundreds of chunks translated per second, allocating these for every
+// chunk puts a lot of pressure on the garbage collector, so they are taken from the pools below while decoding and
+// returned to them by the translation functions once the chunk was encoded. Structures taken from the pools can't be
+// told apart from new ones, so chunks that are kept after decoding, such as by callers of NetworkDecode, are simply
+// never returned.
+
+var (
+	// chunkPool and subChunkPool pool chunks and sub chunks, which keep the slices holding their sub chunks, biomes
+	// and layers when they are released.
+	chunkPool    = sync.Pool{New: func() any { return &Chunk{} }}
+	subChunkPool = sync.Pool{New: func() any { return &SubChunk{} }}
+	// storagePools pool paletted storages by the offset of their paletteSize in sizes, so that the indices of a
+	// storage taken from a pool have the right length.
+	storagePools [len(sizes)]sync.Pool
+)
+
+// releaseDecoded specifies if the translation functions return the structures they decoded to the pools. It is only
+// disabled by benchmarks, to compare with allocating them for every chunk.
+var releaseDecoded = true
+
+// getStorage returns a PalettedStorage with the paletteSize passed from the pools, or a new one if the pools hold
+// none. All indices of the storage are zero, and its palette is empty.
+func getStorage(size paletteSize) *PalettedStorage {
+	storage, _ := storagePools[offsets[size]].Get().(*PalettedStorage)
+	if storage == nil {
+		return newPalettedStorage(make([]uint32, size.uint32s()), newPalette(size, nil))
+	}
+	for i := range storage.indices {
+		storage.indices[i] = 0
+	}
+	storage.palette.reset(size)
+	return storage
+}
+
+// release returns the PalettedStorage to the pools, together with its Palette. Neither may be used after calling
+// release.
+func (storage *PalettedStorage) release() {
+	storagePools[offsets[storage.bitsPerIndex]].Put(storage)
+}
+
+// reset empties the Palette and sets its size to the paletteSize passed, keeping the memory of its values.
+func (palette *Palette) reset(size paletteSize) {
+	palette.size, palette.values, palette.last, palette.lastIndex = size, palette.values[:0], math.MaxUint32, 0
+}
+
+// release returns the SubChunk and all its layers to the pools if releaseDecoded is true. Neither the sub chunk nor
+// any of its layers may be used after calling release.
+func (sub *SubChunk) release() {
+	if !releaseDecoded {
+		return
+	}
+	for i, storage := range sub.storages {
+		storage.release()
+		sub.storages[i] = nil
+	}
+	sub.storages, sub.blockLight, sub.skyLight = sub.storages[:0], nil, nil
+	subChunkPool.Put(sub)
+}
+
+// release returns the Chunk, its sub chunks and its biome storages to the pools if releaseDecoded is true. Nothing
+// obtained from the chunk may be used after calling release.
+func (chunk *Chunk) release() {
+	if !releaseDecoded {
+		return
+	}
+	for i, sub := range chunk.sub {
+		if sub != nil {
+			sub.release()
+		}
+		chunk.sub[i] = nil
+	}
+	var last *PalettedStorage
+	for i, b := range chunk.biomes {
+		// Successive biome storages may be the same storage, which must only be released once.
+		if b != last {
+			b.release()
+		}
+		last, chunk.biomes[i] = b, nil
+	}
+	chunk.sub, chunk.biomes = chunk.sub[:0], chunk.biomes[:0]
+	chunkPool.Put(chunk)
+}
@@ -0,0 +1,129 @@
+package chunk
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/state"
+)
+
+// registerArenaPalettes registers the palettes of two versions holding air and the same n other blocks in reverse
+// order, and returns the versions.
+func registerArenaPalettes(t testing.TB, n int) (from, to state.Version) {
+	blocks := []state.Block{{Name: "minecraft:air"}}
+	for i := 1; i <= n; i++ {
+		blocks = append(blocks, state.Block{Name: fmt.Sprintf("test:block_%v", i)})
+	}
+	p, err := state.NewPalette(blocks, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reversed := []state.Block{blocks[0]}
+	for i := n; i >= 1; i-- {
+		reversed = append(reversed, blocks[i])
+	}
+	q, err := state.NewPalette(reversed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.RegisterPalette(-11, p)
+	state.RegisterPalette(-12, q)
+	return -11, -12
+}
+
+// arenaPayload returns the payload of a LevelChunk holding count sub chunks, filled with blocks up to the runtime ID
+// max so that the sub chunks have different palette sizes depending on the seed passed.
+func arenaPayload(seed uint32, count int, max uint32) []byte {
+	c := New(0, testRange)
+	for i, s := range c.Sub()[:count] {
+		n := (seed*uint32(i+1))%max + 1
+		for x := byte(0); x < 16; x++ {
+			for y := byte(0); y < 16; y++ {
+				for z := byte(0); z < 16; z++ {
+					s.SetBlock(x, y, z, 0, (uint32(x)*seed+uint32(y)+uint32(z)*7)%(n+1))
+				}
+			}
+		}
+	}
+	c.SetBiome(0, 0, 0, seed%4)
+	c.Compact()
+	data := Encode(c, NetworkEncoding)
+	payload := bytes.NewBuffer(nil)
+	for _, sub := range data.SubChunks[:count] {
+		payload.Write(sub)
+	}
+	payload.Write(data.Biomes)
+	payload.WriteByte(0)
+	return payload.Bytes()
+}
+
+func TestTranslateReusesStructures(t *testing.T) {
+	from, to := registerArenaPalettes(t, 300)
+	var payloads [][]byte
+	for seed := uint32(1); seed <= 20; seed++ {
+		payloads = append(payloads, arenaPayload(seed, 8, 300))
+	}
+
+	releaseDecoded = false
+	var expected [][]byte
+	for _, payload := range payloads {
+		translated, err := Translate(state.Default(), payload, 8, testRange, from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, translated)
+	}
+	releaseDecoded = true
+
+	// The chunks are translated twice, so that the structures of every chunk are reused for chunks with other
+	// palette sizes.
+	for round := 0; round < 2; round++ {
+		for i, payload := range payloads {
+			translated, err := Translate(state.Default(), payload, 8, testRange, from, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(translated, expected[i]) {
+				t.Fatalf("chunk %v translated differently with reused structures in round %v", i, round)
+			}
+		}
+	}
+}
+
+func BenchmarkTranslate(b *testing.B) {
+	from, to := registerArenaPalettes(b, 300)
+	var payloads [][]byte
+	for seed := uint32(1); seed <= 16; seed++ {
+		payloads = append(payloads, arenaPayload(seed, 16, 300))
+	}
+	defer func() {
+		releaseDecoded = true
+	}()
+	for _, pooled := range []bool{true, false} {
+		name := "Pooled"
+		if !pooled {
+			name = "Allocated"
+		}
+		b.Run(name, func(b *testing.B) {
+			releaseDecoded = pooled
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := Translate(state.Default(), payloads[i%len(payloads)], 16, testRange, from, to); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			runtime.ReadMemStats(&after)
+			// The time the garbage collector paused the program for is what translating chunks costs the other
+			// goroutines of the proxy, on top of the time spent translating itself.
+			b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(b.N), "gc-pause-ns/op")
+			b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N)*1000, "gcs/1k-op")
+		})
+	}
+}
@@ -24,12 +24,13 @@ type Chunk struct {
 // New initialises a new chunk and returns it, so that it may be used.
 func New(air uint32, r cube.Range) *Chunk {
 	n := (r.Height() >> 4) + 1
-	sub, biomes := make([]*SubChunk, n), make([]*PalettedStorage, n)
+	c := chunkPool.Get().(*Chunk)
+	c.r, c.air = r, air
 	for i := 0; i < n; i++ {
-		sub[i] = NewSubChunk(air)
-		biomes[i] = emptyStorage(0)
+		c.sub = append(c.sub, NewSubChunk(air))
+		c.biomes = append(c.biomes, emptyStorage(0))
 	}
-	return &Chunk{r: r, air: air, sub: sub, biomes: biomes}
+	return c
 }
 
 // Range returns the cube.Range of the Chunk as passed to New.
@@ -43,8 +44,12 @@ func (chunk *Chunk) Sub() []*SubChunk {
 }
 
 // Block returns the runtime ID of the block at a given x, y and z in a chunk at the given layer. If no
-// sub chunk exists at the given y, the block is assumed to be air.
+// sub chunk exists at the given y, or if y is outside the range of the chunk, the block is assumed to be air.
+// Block may be used on chunks obtained through NetworkDecode, for example to inspect chunks sent by a server.
 func (chunk *Chunk) Block(x uint8, y int16, z uint8, layer uint8) uint32 {
+	if !chunk.inRange(y) {
+		return chunk.air
+	}
 	sub := chunk.subChunk(y)
 	if sub.Empty() || uint8(len(sub.storages)) <= layer {
 		return chunk.air
@@ -53,8 +58,13 @@ func (chunk *Chunk) Block(x uint8, y int16, z uint8, layer uint8) uint32 {
 }
 
 // SetBlock sets the runtime ID of a block at a given x, y and z in a chunk at the given layer. If no
-// SubChunk exists at the given y, a new SubChunk is created and the block is set.
+// SubChunk exists at the given y, a new SubChunk is created and the block is set. The palette of the layer is
+// grown and its storage resized to a larger bits per index if needed. SetBlock has no effect if y is outside the
+// range of the chunk. Chunks modified with SetBlock may be encoded again using Encode.
 func (chunk *Chunk) SetBlock(x uint8, y int16, z uint8, layer uint8, block uint32) {
+	if !chunk.inRange(y) {
+		return
+	}
 	sub := chunk.sub[chunk.subIndex(y)]
 	if uint8(len(sub.storages)) <= layer && block == chunk.air {
 		// Air was set at n layer, but there were less than n layers, so there already was air there.
@@ -90,12 +100,20 @@ func (chunk *Chunk) HighestBlock(x, z uint8) int16 {
 }
 
 // Compact compacts the chunk as much as possible, getting rid of any sub chunks that are empty, and compacts
-// all storages in the sub chunks to occupy as little space as possible.
-// Compact should be called right before the chunk is saved in order to optimise the storage space.
+// all storages in the sub chunks and all biome storages to occupy as little space as possible.
+// Compact should be called right before the chunk is saved or sent in order to optimise the storage space.
 func (chunk *Chunk) Compact() {
 	for i := range chunk.sub {
-		chunk.sub[i].compact()
+		chunk.sub[i].Compact()
 	}
+	for _, b := range chunk.biomes {
+		b.compact()
+	}
+}
+
+// inRange checks if y lies within the vertical range of the chunk.
+func (chunk *Chunk) inRange(y int16) bool {
+	return int(y) >= chunk.r[0] && int(y) <= chunk.r[1]
 }
 
 // subChunk finds the correct SubChunk in the Chunk by a Y value.
@@ -11,28 +11,40 @@ import (
 // The sub chunk count passed must be that found in the LevelChunk packet.
 //noinspection GoUnusedExportedFunction
 func NetworkDecode(air uint32, buf *bytes.Buffer, count int, r cube.Range) (*Chunk, error) {
-	var (
-		c   = New(air, r)
-		err error
-	)
+	c := New(air, r)
+	if count > len(c.sub) {
+		return nil, fmt.Errorf("sub chunk count %v exceeds the %v sub chunks of range %v", count, len(c.sub), r)
+	}
 	for i := 0; i < count; i++ {
 		index := uint8(i)
-		c.sub[index], err = DecodeSubChunk(c.air, c.r, buf, &index, NetworkEncoding)
+		sub, err := DecodeSubChunk(c.air, c.r, buf, &index, NetworkEncoding)
 		if err != nil {
+			c.release()
 			return nil, err
 		}
+		c.sub[index].release()
+		c.sub[index] = sub
+	}
+	if err := decodeBiomes(buf, c); err != nil {
+		c.release()
+		return nil, err
 	}
+	return c, nil
+}
+
+// decodeBiomes decodes the biome storages of all sub chunks of the chunk passed from a bytes.Buffer.
+func decodeBiomes(buf *bytes.Buffer, c *Chunk) error {
 	var last *PalettedStorage
 	for i := 0; i < len(c.sub); i++ {
 		b, err := decodePalettedStorage(buf, NetworkEncoding, BiomePaletteEncoding)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		// b == nil means this paletted storage had the flag pointing to the previous one. It basically means we should
 		// inherit whatever palette we decoded last.
 		if i == 0 && b == nil {
 			// This should never happen and there is no way to handle this.
-			return nil, fmt.Errorf("first biome storage pointed to previous one")
+			return fmt.Errorf("first biome storage pointed to previous one")
 		}
 		if b == nil {
 			// This means this paletted storage had the flag pointing to the previous one. It basically means we should
@@ -41,9 +53,11 @@ func NetworkDecode(air uint32, buf *bytes.Buffer, count int, r cube.Range) (*Chu
 		} else {
 			last = b
 		}
+		// The empty storage set by New is replaced, so it may be reused.
+		c.biomes[i].release()
 		c.biomes[i] = b
 	}
-	return c, nil
+	return nil
 }
 
 // DecodeSubChunk decodes a SubChunk from a bytes.Buffer. The Encoding passed defines how the block storages of the
@@ -76,16 +90,20 @@ func DecodeSubChunk(air uint32, r cube.Range, buf *bytes.Buffer, index *byte, e
 				return nil, fmt.Errorf("error reading subchunk index: %w", err)
 			}
 			// The index as written here isn't the actual index of the subchunk within the chunk. Rather, it is the Y
-			// value of the subchunk. This means that we need to translate it to an index.
-			*index = uint8(int8(uIndex) - int8(r[0]>>4))
+			// value of the subchunk, which is negative for sub chunks below Y 0. This means that we need to translate
+			// it to an index.
+			i := int(int8(uIndex)) - r[0]>>4
+			if i < 0 || i > r.Height()>>4 {
+				return nil, fmt.Errorf("sub chunk Y %v is outside of range %v", int8(uIndex), r)
+			}
+			*index = uint8(i)
 		}
-		sub.storages = make([]*PalettedStorage, storageCount)
-
 		for i := byte(0); i < storageCount; i++ {
-			sub.storages[i], err = decodePalettedStorage(buf, e, BlockPaletteEncoding)
+			storage, err := decodePalettedStorage(buf, e, BlockPaletteEncoding)
 			if err != nil {
 				return nil, err
 			}
+			sub.storages = append(sub.storages, storage)
 		}
 	}
 	return sub, nil
@@ -102,21 +120,36 @@ func decodePalettedStorage(buf *bytes.Buffer, e Encoding, pe paletteEncoding) (*
 	if blockSize == 0x7f {
 		return nil, nil
 	}
+	if !validSize(paletteSize(blockSize)) {
+		return nil, fmt.Errorf("cannot read paletted storage %T: invalid block size %v", pe, blockSize)
+	}
 
 	size := paletteSize(blockSize)
 	uint32Count := size.uint32s()
-
-	uint32s := make([]uint32, uint32Count)
 	byteCount := uint32Count * 4
 
 	data := buf.Next(byteCount)
 	if len(data) != byteCount {
 		return nil, fmt.Errorf("cannot read paletted storage (size=%v) %T: not enough block data present: expected %v bytes, got %v", blockSize, pe, byteCount, len(data))
 	}
+	storage := getStorage(size)
 	for i := 0; i < uint32Count; i++ {
 		// Explicitly don't use the binary package to greatly improve performance of reading the uint32s.
-		uint32s[i] = uint32(data[i*4]) | uint32(data[i*4+1])<<8 | uint32(data[i*4+2])<<16 | uint32(data[i*4+3])<<24
+		storage.indices[i] = uint32(data[i*4]) | uint32(data[i*4+1])<<8 | uint32(data[i*4+2])<<16 | uint32(data[i*4+3])<<24
+	}
+	if err := e.decodePalette(buf, storage.palette, pe); err != nil {
+		storage.release()
+		return nil, err
+	}
+	return storage, nil
+}
+
+// validSize checks if the paletteSize passed is one of the sizes that a PalettedStorage may have.
+func validSize(size paletteSize) bool {
+	for _, s := range sizes {
+		if s == size {
+			return true
+		}
 	}
-	p, err := e.decodePalette(buf, paletteSize(blockSize), pe)
-	return newPalettedStorage(uint32s, p), err
+	return false
 }
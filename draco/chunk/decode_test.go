@@ -0,0 +1,99 @@
+package chunk
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeSubChunkIndex(t *testing.T) {
+	for _, index := range []int{0, 3, 4, 23} {
+		s := newTestSubChunk(5)
+		data := EncodeSubChunk(s, NetworkEncoding, testRange, index)
+		if y := int8(data[2]); int(y) != index-4 {
+			t.Fatalf("sub chunk %v encoded with Y %v, expected %v", index, y, index-4)
+		}
+
+		var decodedIndex byte
+		decoded, err := DecodeSubChunk(0, testRange, bytes.NewBuffer(data), &decodedIndex, NetworkEncoding)
+		if err != nil {
+			t.Fatalf("decode sub chunk %v: %v", index, err)
+		}
+		if int(decodedIndex) != index {
+			t.Errorf("sub chunk %v decoded with index %v", index, decodedIndex)
+		}
+		if got, want := decoded.Block(3, 7, 11, 0), s.Block(3, 7, 11, 0); got != want {
+			t.Errorf("block of sub chunk %v decoded as %v, expected %v", index, got, want)
+		}
+	}
+}
+
+func TestDecodeSubChunkVersion8(t *testing.T) {
+	s := newTestSubChunk(5)
+	// Version 8 sub chunks are the same as version 9 sub chunks, except that they don't have a Y index.
+	data := EncodeSubChunk(s, NetworkEncoding, testRange, 2)
+	data = append([]byte{8, data[1]}, data[3:]...)
+
+	index := byte(6)
+	decoded, err := DecodeSubChunk(0, testRange, bytes.NewBuffer(data), &index, NetworkEncoding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != 6 {
+		t.Errorf("index changed to %v for a version 8 sub chunk", index)
+	}
+	if got, want := decoded.Block(1, 2, 3, 0), s.Block(1, 2, 3, 0); got != want {
+		t.Errorf("block decoded as %v, expected %v", got, want)
+	}
+}
+
+func TestDecodeSubChunkInvalid(t *testing.T) {
+	valid := EncodeSubChunk(newTestSubChunk(5), NetworkEncoding, testRange, 2)
+	outOfRange := append([]byte(nil), valid...)
+	y := int8(-5)
+	outOfRange[2] = byte(y)
+	invalidSize := append([]byte(nil), valid...)
+	invalidSize[3] = 40<<1 | 1
+
+	for name, data := range map[string][]byte{
+		"unknown version": {7},
+		"Y out of range":  outOfRange,
+		"invalid size":    invalidSize,
+		"truncated":       valid[:len(valid)/2],
+	} {
+		var index byte
+		if _, err := DecodeSubChunk(0, testRange, bytes.NewBuffer(data), &index, NetworkEncoding); err == nil {
+			t.Errorf("%v: sub chunk decoded without error", name)
+		}
+	}
+}
+
+func TestDecodedChunkSetBlock(t *testing.T) {
+	// Runtime ID 0 is not air in the chunk, and is the second value in the palette of the decoded chunk. The lookup
+	// cache of the decoded palette must not claim that it is the first.
+	c := New(5, testRange)
+	c.SetBlock(0, 0, 0, 0, 0)
+	data := Encode(c, NetworkEncoding)
+	buf := bytes.NewBuffer(nil)
+	for _, sub := range data.SubChunks[:data.SubChunkCount()] {
+		buf.Write(sub)
+	}
+	buf.Write(data.Biomes)
+
+	decoded, err := NetworkDecode(5, buf, int(data.SubChunkCount()), testRange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded.SetBlock(1, 0, 1, 0, 0)
+	if rid := decoded.Block(1, 0, 1, 0); rid != 0 {
+		t.Errorf("block set in decoded chunk is %v, expected 0", rid)
+	}
+	if rid := decoded.Block(2, 0, 2, 0); rid != 5 {
+		t.Errorf("block not set in decoded chunk is %v, expected 5", rid)
+	}
+
+	// Y values outside of the range of the chunk are ignored rather than panicking.
+	decoded.SetBlock(0, 320, 0, 0, 1)
+	if rid := decoded.Block(0, -65, 0, 0); rid != 5 {
+		t.Errorf("block below the chunk is %v, expected air", rid)
+	}
+}
@@ -16,19 +16,42 @@ const (
 	CurrentBlockVersion int32 = 17825806
 )
 
-// pool is used to pool byte buffers used for encoding chunks.
+// pool is used to pool byte buffers used for encoding chunks. Buffers must be obtained using getBuffer and returned
+// using putBuffer, and no slice pointing into the memory of a buffer may be retained after it is returned.
 var pool = sync.Pool{
 	New: func() any {
 		return bytes.NewBuffer(make([]byte, 0, 1024))
 	},
 }
 
+// getBuffer obtains an empty buffer from the pool. The caller owns the buffer until it passes it to putBuffer.
+func getBuffer() *bytes.Buffer {
+	return pool.Get().(*bytes.Buffer)
+}
+
+// putBuffer resets a buffer and returns it to the pool. Ownership of the buffer passes back to the pool, meaning
+// neither the buffer nor any slice obtained through buf.Bytes() may be used after calling putBuffer.
+func putBuffer(buf *bytes.Buffer) {
+	buf.Reset()
+	pool.Put(buf)
+}
+
+// CloneBytes returns a copy of the unread bytes held by buf. The slice returned does not share memory with buf, so
+// it remains valid after buf is modified, reset or returned to a pool. Data that outlives a pooled buffer must
+// always be copied out using CloneBytes rather than by retaining buf.Bytes().
+func CloneBytes(buf *bytes.Buffer) []byte {
+	b := make([]byte, buf.Len())
+	copy(b, buf.Bytes())
+	return b
+}
+
 type (
 	// SerialisedData holds the serialised data of a chunk. It consists of the chunk's block data itself, a height
 	// map, the biomes and entities and block entities.
 	SerialisedData struct {
-		// sub holds the data of the serialised sub chunks in a chunk. Sub chunks that are empty or that otherwise
-		// don't exist are represented as an empty slice (or technically, nil).
+		// SubChunks holds the data of the serialised sub chunks in a chunk, one for every sub chunk in its range.
+		// Sub chunks that only hold air are serialised without any layers. SubChunkCount returns the amount of
+		// them that must be sent to clients.
 		SubChunks [][]byte
 		// Biomes is the biome data of the chunk, which is composed of a biome storage for each subchunk.
 		Biomes []byte
@@ -38,64 +61,120 @@ type (
 	}
 )
 
+// SubChunkCount returns the amount of sub chunks of the SerialisedData from the bottom up to and including the highest
+// sub chunk holding blocks other than air. Clients treat the sub chunks above the count of a LevelChunk as air, so only
+// these need to be sent.
+func (d SerialisedData) SubChunkCount() int {
+	n := len(d.SubChunks)
+	for n > 0 && emptySubChunk(d.SubChunks[n-1]) {
+		n--
+	}
+	return n
+}
+
+// emptySubChunk checks if the serialised sub chunk passed has no layers, as written by EncodeSubChunk for sub chunks
+// that only hold air.
+func emptySubChunk(b []byte) bool {
+	return len(b) == 3 && b[0] == SubChunkVersion && b[1] == 0
+}
+
 // Encode encodes Chunk to an intermediate representation SerialisedData. An Encoding may be passed to encode either for
 // network or disk purposed, the most notable difference being that the network encoding generally uses varints and no
-// NBT.
+// NBT. The sub chunks and biomes are serialised into a single allocation, with every slice of the SerialisedData
+// capped at its own length, so that appending to one never overwrites another.
 func Encode(c *Chunk, e Encoding) SerialisedData {
-	d := SerialisedData{SubChunks: make([][]byte, len(c.sub))}
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	offsets := make([]int, len(c.sub)+1)
 	for i, s := range c.sub {
-		d.SubChunks[i] = EncodeSubChunk(s, e, c.r, i)
+		EncodeSubChunkTo(buf, s, e, c.r, i)
+		offsets[i+1] = buf.Len()
+	}
+	EncodeBiomesTo(buf, c, e)
+	b := CloneBytes(buf)
+
+	d := SerialisedData{SubChunks: make([][]byte, len(c.sub))}
+	for i := range c.sub {
+		d.SubChunks[i] = b[offsets[i]:offsets[i+1]:offsets[i+1]]
 	}
-	d.Biomes = EncodeBiomes(c, e)
+	d.Biomes = b[offsets[len(c.sub)]:]
 	return d
 }
 
 // EncodeSubChunk encodes a sub-chunk from a chunk into bytes. An Encoding may be passed to encode either for network or
 // disk purposed, the most notable difference being that the network encoding generally uses varints and no NBT.
+// The slice returned is owned by the caller and does not share memory with any buffer used internally, so it is safe
+// to call EncodeSubChunk from multiple goroutines simultaneously, as long as the sub chunk itself is not modified.
+// Layers only holding air are written with a single palette entry and no indices, and trailing ones are left out, so
+// that sub chunks only holding air are written without layers.
 func EncodeSubChunk(s *SubChunk, e Encoding, r cube.Range, ind int) []byte {
-	buf := pool.Get().(*bytes.Buffer)
-	defer func() {
-		buf.Reset()
-		pool.Put(buf)
-	}()
-
-	_, _ = buf.Write([]byte{SubChunkVersion, byte(len(s.storages)), uint8(ind + (r[0] >> 4))})
-	for _, storage := range s.storages {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	EncodeSubChunkTo(buf, s, e, r, ind)
+	return CloneBytes(buf)
+}
+
+// EncodeSubChunkTo encodes a sub-chunk like EncodeSubChunk, but appends it to the buffer passed rather than returning
+// a copy, so that callers serialising many sub chunks, or a sub chunk followed by other data, can do so without an
+// allocation for every sub chunk. The buffer is owned by the caller, so it may only be shared between goroutines if
+// the caller synchronises access to it.
+func EncodeSubChunkTo(buf *bytes.Buffer, s *SubChunk, e Encoding, r cube.Range, ind int) {
+	layers := len(s.storages)
+	for layers > 0 && s.storages[layers-1].uniform(s.air) {
+		layers--
+	}
+	_, _ = buf.Write([]byte{SubChunkVersion, byte(layers), uint8(ind + (r[0] >> 4))})
+	for _, storage := range s.storages[:layers] {
+		if storage.bitsPerIndex != 0 && storage.uniform(s.air) {
+			empty := emptyStorage(s.air)
+			encodePalettedStorage(buf, empty, e, BlockPaletteEncoding)
+			empty.release()
+			continue
+		}
 		encodePalettedStorage(buf, storage, e, BlockPaletteEncoding)
 	}
-	sub := make([]byte, buf.Len())
-	_, _ = buf.Read(sub)
-	return sub
 }
 
 // EncodeBiomes encodes the biomes of a chunk into bytes. An Encoding may be passed to encode either for network or
 // disk purposed, the most notable difference being that the network encoding generally uses varints and no NBT.
+// Like EncodeSubChunk, the slice returned is owned by the caller.
 func EncodeBiomes(c *Chunk, e Encoding) []byte {
-	buf := pool.Get().(*bytes.Buffer)
-	defer func() {
-		buf.Reset()
-		pool.Put(buf)
-	}()
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	EncodeBiomesTo(buf, c, e)
+	return CloneBytes(buf)
+}
 
+// EncodeBiomesTo encodes the biomes of a chunk like EncodeBiomes, but appends them to the buffer passed, like
+// EncodeSubChunkTo.
+func EncodeBiomesTo(buf *bytes.Buffer, c *Chunk, e Encoding) {
 	for _, b := range c.biomes {
 		encodePalettedStorage(buf, b, e, BiomePaletteEncoding)
 	}
-	biomes := make([]byte, buf.Len())
-	_, _ = buf.Read(biomes)
-	return biomes
 }
 
 // encodePalettedStorage encodes a PalettedStorage into a bytes.Buffer. The Encoding passed is used to write the Palette
 // of the PalettedStorage.
 func encodePalettedStorage(buf *bytes.Buffer, storage *PalettedStorage, e Encoding, pe paletteEncoding) {
-	b := make([]byte, len(storage.indices)*4+1)
-	b[0] = byte(storage.bitsPerIndex<<1) | e.network()
+	buf.Grow(len(storage.indices)*4 + 1)
+	_ = buf.WriteByte(byte(storage.bitsPerIndex<<1) | e.network())
 
-	for i, v := range storage.indices {
-		// Explicitly don't use the binary package to greatly improve performance of writing the uint32s.
-		b[i*4+1], b[i*4+2], b[i*4+3], b[i*4+4] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+	// The indices are written in batches through an array on the stack, so that no slice is allocated for them.
+	var b [256]byte
+	for i := 0; i < len(storage.indices); i += len(b) / 4 {
+		n := len(storage.indices) - i
+		if n > len(b)/4 {
+			n = len(b) / 4
+		}
+		for j, v := range storage.indices[i : i+n] {
+			// Explicitly don't use the binary package to greatly improve performance of writing the uint32s.
+			b[j*4], b[j*4+1], b[j*4+2], b[j*4+3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+		}
+		_, _ = buf.Write(b[:n*4])
 	}
-	_, _ = buf.Write(b)
 
 	e.encodePalette(buf, storage.palette, pe)
 }
@@ -0,0 +1,256 @@
+package chunk
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/df-mc/dragonfly/server/block/cube"
+)
+
+// testRange is the range of the chunks used in tests.
+var testRange = cube.Range{-64, 319}
+
+// newTestSubChunk returns a sub chunk with a pattern of blocks that depends on the seed passed, so that sub chunks
+// created with different seeds encode to different bytes.
+func newTestSubChunk(seed uint32) *SubChunk {
+	s := NewSubChunk(0)
+	for x := byte(0); x < 16; x++ {
+		for y := byte(0); y < 16; y++ {
+			for z := byte(0); z < 16; z++ {
+				s.SetBlock(x, y, z, 0, (uint32(x)+uint32(y)*seed+uint32(z))%(seed+1))
+			}
+		}
+	}
+	return s
+}
+
+func TestCloneBytesDoesNotAlias(t *testing.T) {
+	buf := bytes.NewBuffer([]byte{1, 2, 3})
+	b := CloneBytes(buf)
+	buf.Reset()
+	buf.Write([]byte{4, 5, 6})
+	if !bytes.Equal(b, []byte{1, 2, 3}) {
+		t.Fatalf("cloned bytes changed after buffer reuse: got %v", b)
+	}
+}
+
+func TestEncodeSubChunkResultOwned(t *testing.T) {
+	first := EncodeSubChunk(newTestSubChunk(3), NetworkEncoding, testRange, 4)
+	want := append([]byte(nil), first...)
+
+	// Encoding another sub chunk reuses the pooled buffer used to encode the first. The first result must not
+	// change as a result.
+	_ = EncodeSubChunk(newTestSubChunk(7), NetworkEncoding, testRange, 5)
+	if !bytes.Equal(first, want) {
+		t.Fatal("encoded sub chunk was modified by a later encode")
+	}
+}
+
+func TestEncodeSubChunkConcurrent(t *testing.T) {
+	const n = 16
+	subs, want := make([]*SubChunk, n), make([][]byte, n)
+	for i := range subs {
+		subs[i] = newTestSubChunk(uint32(i + 1))
+		want[i] = EncodeSubChunk(subs[i], NetworkEncoding, testRange, i)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				i := j % n
+				if got := EncodeSubChunk(subs[i], NetworkEncoding, testRange, i); !bytes.Equal(got, want[i]) {
+					t.Errorf("sub chunk %v encoded differently under concurrent encoding", i)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestEncodeConcurrent(t *testing.T) {
+	c := New(0, testRange)
+	for i, s := range c.Sub() {
+		*s = *newTestSubChunk(uint32(i + 1))
+	}
+	want := Encode(c, NetworkEncoding)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got := Encode(c, NetworkEncoding)
+			if !bytes.Equal(got.Biomes, want.Biomes) {
+				t.Error("biomes encoded differently under concurrent encoding")
+			}
+			for i := range got.SubChunks {
+				if !bytes.Equal(got.SubChunks[i], want.SubChunks[i]) {
+					t.Errorf("sub chunk %v encoded differently under concurrent encoding", i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestEncodeUniformStorage(t *testing.T) {
+	s := newTestSubChunk(5)
+	s.Layer(0).Palette().Replace(func(uint32) uint32 { return 7 })
+	s.Compact()
+
+	if n := s.Layer(0).bitsPerIndex; n != 0 {
+		t.Fatalf("expected uniform storage to be compacted to 0 bits per index, got %v", n)
+	}
+	b := EncodeSubChunk(s, NetworkEncoding, testRange, 4)
+	// Version, layer count, y index, the storage header and the varint of the single palette value.
+	if expected := []byte{SubChunkVersion, 1, 0, 1, 14}; !bytes.Equal(b, expected) {
+		t.Fatalf("expected uniform sub chunk to encode to %v, got %v", expected, b)
+	}
+
+	var ind byte
+	decoded, err := DecodeSubChunk(0, testRange, bytes.NewBuffer(b), &ind, NetworkEncoding)
+	if err != nil {
+		t.Fatalf("error decoding uniform sub chunk: %v", err)
+	}
+	if ind != 4 {
+		t.Fatalf("expected decoded sub chunk index 4, got %v", ind)
+	}
+	for x := byte(0); x < 16; x++ {
+		for y := byte(0); y < 16; y++ {
+			for z := byte(0); z < 16; z++ {
+				if v := decoded.Block(x, y, z, 0); v != 7 {
+					t.Fatalf("expected block 7 at %v %v %v, got %v", x, y, z, v)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeDiskRoundTrip(t *testing.T) {
+	// Any block states are encoded by their name and properties, so these are arbitrary runtime IDs.
+	rids := []uint32{0, 100, 1000, latestmappings.StateCount() - 1}
+	s := NewSubChunk(rids[0])
+	for x := byte(0); x < 16; x++ {
+		for y := byte(0); y < 16; y++ {
+			for z := byte(0); z < 16; z++ {
+				s.SetBlock(x, y, z, 0, rids[(int(x)+int(y)+int(z))%len(rids)])
+			}
+		}
+	}
+	b := EncodeSubChunk(s, DiskEncoding, testRange, 6)
+
+	ind := byte(6)
+	decoded, err := DecodeSubChunk(rids[0], testRange, bytes.NewBuffer(b), &ind, DiskEncoding)
+	if err != nil {
+		t.Fatalf("error decoding disk sub chunk: %v", err)
+	}
+	for x := byte(0); x < 16; x++ {
+		for y := byte(0); y < 16; y++ {
+			for z := byte(0); z < 16; z++ {
+				if v, expected := decoded.Block(x, y, z, 0), s.Block(x, y, z, 0); v != expected {
+					t.Fatalf("expected block %v at %v %v %v, got %v", expected, x, y, z, v)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeEmptySubChunk(t *testing.T) {
+	c := New(0, testRange)
+	c.SetBlock(0, 0, 0, 0, 5)
+	c.SetBlock(0, 0, 0, 1, 6)
+	// Setting the blocks back to air leaves both layers with a palette holding the blocks, which must not be
+	// encoded.
+	c.SetBlock(0, 0, 0, 0, 0)
+	c.SetBlock(0, 0, 0, 1, 0)
+	c.SetBlock(0, 20, 0, 1, 7)
+
+	data := Encode(c, NetworkEncoding)
+	index := 0 - testRange[0]>>4
+	if b := data.SubChunks[index]; !bytes.Equal(b, []byte{SubChunkVersion, 0, 0}) {
+		t.Fatalf("sub chunk only holding air encoded as %v", b)
+	}
+	above := data.SubChunks[index+1]
+	if above[1] != 2 || len(above) > 3+2+1024*4+3 {
+		t.Fatalf("sub chunk with an empty first layer encoded with %v layers in %v bytes", above[1], len(above))
+	}
+	if n := data.SubChunkCount(); n != index+2 {
+		t.Fatalf("sub chunk count %v, expected %v", n, index+2)
+	}
+
+	var i byte
+	s, err := DecodeSubChunk(0, testRange, bytes.NewBuffer(above), &i, NetworkEncoding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Block(0, 4, 0, 1) != 7 || s.Block(0, 4, 0, 0) != 0 {
+		t.Fatal("sub chunk decoded with different blocks")
+	}
+}
+
+func TestEncodeSubChunkTo(t *testing.T) {
+	s := newTestSubChunk(5)
+	want := EncodeSubChunk(s, NetworkEncoding, testRange, 2)
+
+	buf := bytes.NewBuffer([]byte{0xff})
+	EncodeSubChunkTo(buf, s, NetworkEncoding, testRange, 2)
+	if got := buf.Bytes(); got[0] != 0xff || !bytes.Equal(got[1:], want) {
+		t.Fatal("sub chunk encoded into a buffer differs from EncodeSubChunk or overwrote its contents")
+	}
+}
+
+func TestEncodeSlicesDoNotOverlap(t *testing.T) {
+	c := New(0, testRange)
+	for i, s := range c.Sub() {
+		*s = *newTestSubChunk(uint32(i + 1))
+	}
+	d := Encode(c, NetworkEncoding)
+	for i, s := range c.Sub() {
+		if !bytes.Equal(d.SubChunks[i], EncodeSubChunk(s, NetworkEncoding, testRange, i)) {
+			t.Fatalf("sub chunk %v differs from EncodeSubChunk", i)
+		}
+	}
+	if !bytes.Equal(d.Biomes, EncodeBiomes(c, NetworkEncoding)) {
+		t.Fatal("biomes differ from EncodeBiomes")
+	}
+	next := append([]byte(nil), d.SubChunks[1]...)
+	_ = append(d.SubChunks[0], 1, 2, 3)
+	if !bytes.Equal(d.SubChunks[1], next) {
+		t.Fatal("appending to a sub chunk overwrote the next")
+	}
+}
+
+func BenchmarkEncodeSubChunk(b *testing.B) {
+	s := newTestSubChunk(7)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = EncodeSubChunk(s, NetworkEncoding, testRange, 4)
+	}
+}
+
+func BenchmarkEncodeSubChunkTo(b *testing.B) {
+	s := newTestSubChunk(7)
+	buf := bytes.NewBuffer(nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		EncodeSubChunkTo(buf, s, NetworkEncoding, testRange, 4)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	c := New(0, testRange)
+	for i, s := range c.Sub() {
+		*s = *newTestSubChunk(uint32(i + 1))
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = Encode(c, NetworkEncoding)
+	}
+}
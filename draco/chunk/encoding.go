@@ -15,7 +15,8 @@ type (
 	// NetworkEncoding, which can be used to encode a Chunk to an intermediate disk or network representation respectively.
 	Encoding interface {
 		encodePalette(buf *bytes.Buffer, p *Palette, e paletteEncoding)
-		decodePalette(buf *bytes.Buffer, blockSize paletteSize, e paletteEncoding) (*Palette, error)
+		// decodePalette decodes the values of a palette into the empty Palette passed, the size of which must be set.
+		decodePalette(buf *bytes.Buffer, p *Palette, e paletteEncoding) error
 		network() byte
 	}
 	// paletteEncoding is an encoding type used for Chunk encoding. It is used to encode different types of palettes
@@ -27,6 +28,9 @@ type (
 )
 
 var (
+	// DiskEncoding is the Encoding used for writing a Chunk to disk, such as to a LevelDB world. It writes block states
+	// encoded as NBT.
+	DiskEncoding diskEncoding
 	// NetworkEncoding is the Encoding used for sending a Chunk over network. It does not use NBT and writes varints.
 	NetworkEncoding networkEncoding
 	// BiomePaletteEncoding is the paletteEncoding used for encoding a palette of biomes.
@@ -67,6 +71,39 @@ func (blockPaletteEncoding) decode(buf *bytes.Buffer) (uint32, error) {
 	return v, nil
 }
 
+// diskEncoding implements the Chunk encoding for writing to disk.
+type diskEncoding struct{}
+
+func (diskEncoding) network() byte { return 0 }
+func (diskEncoding) encodePalette(buf *bytes.Buffer, p *Palette, e paletteEncoding) {
+	if p.size != 0 {
+		_ = binary.Write(buf, binary.LittleEndian, uint32(p.Len()))
+	}
+	for _, v := range p.values {
+		e.encode(buf, v)
+	}
+}
+func (diskEncoding) decodePalette(buf *bytes.Buffer, p *Palette, e paletteEncoding) error {
+	paletteCount := uint32(1)
+	if p.size != 0 {
+		if err := binary.Read(buf, binary.LittleEndian, &paletteCount); err != nil {
+			return fmt.Errorf("error reading palette entry count: %w", err)
+		}
+		if paletteCount == 0 || paletteCount > 1<<p.size || paletteCount > 4096 {
+			return fmt.Errorf("invalid palette entry count %v for block size %v", paletteCount, p.size)
+		}
+	}
+
+	for i := uint32(0); i < paletteCount; i++ {
+		v, err := e.decode(buf)
+		if err != nil {
+			return err
+		}
+		p.values = append(p.values, v)
+	}
+	return nil
+}
+
 // networkEncoding implements the Chunk encoding for sending over network.
 type networkEncoding struct{}
 
@@ -79,23 +116,24 @@ func (networkEncoding) encodePalette(buf *bytes.Buffer, p *Palette, _ paletteEnc
 		_ = protocol.WriteVarint32(buf, int32(val))
 	}
 }
-func (networkEncoding) decodePalette(buf *bytes.Buffer, blockSize paletteSize, _ paletteEncoding) (*Palette, error) {
+func (networkEncoding) decodePalette(buf *bytes.Buffer, p *Palette, _ paletteEncoding) error {
 	var paletteCount int32 = 1
-	if blockSize > 0 {
+	if p.size > 0 {
 		if err := protocol.Varint32(buf, &paletteCount); err != nil {
-			return nil, fmt.Errorf("error reading palette entry count: %w", err)
+			return fmt.Errorf("error reading palette entry count: %w", err)
 		}
-		if paletteCount <= 0 {
-			return nil, fmt.Errorf("invalid palette entry count %v", paletteCount)
+		if paletteCount <= 0 || paletteCount > 1<<p.size || paletteCount > 4096 {
+			// A palette never holds more values than its indices can point to, or than a storage has blocks.
+			return fmt.Errorf("invalid palette entry count %v for block size %v", paletteCount, p.size)
 		}
 	}
 
-	blocks, temp := make([]uint32, paletteCount), int32(0)
+	var temp int32
 	for i := int32(0); i < paletteCount; i++ {
 		if err := protocol.Varint32(buf, &temp); err != nil {
-			return nil, fmt.Errorf("error decoding palette entry: %w", err)
+			return fmt.Errorf("error decoding palette entry: %w", err)
 		}
-		blocks[i] = uint32(temp)
+		p.values = append(p.values, uint32(temp))
 	}
-	return &Palette{values: blocks, size: blockSize}, nil
+	return nil
 }
@@ -56,7 +56,9 @@ func newPalettedStorage(indices []uint32, palette *Palette) *PalettedStorage {
 
 // emptyStorage creates a PalettedStorage filled completely with a value v.
 func emptyStorage(v uint32) *PalettedStorage {
-	return newPalettedStorage([]uint32{}, newPalette(0, []uint32{v}))
+	storage := getStorage(0)
+	storage.palette.values = append(storage.palette.values, v)
+	return storage
 }
 
 // Palette returns the Palette of the PalettedStorage.
@@ -69,6 +71,31 @@ func (storage *PalettedStorage) At(x, y, z byte) uint32 {
 	return storage.palette.Value(storage.paletteIndex(x&15, y&15, z&15))
 }
 
+// uniform checks if every value in the PalettedStorage is v. Unlike Palette.Index, it does not modify the Palette,
+// so that it may be called while encoding a sub chunk from multiple goroutines.
+func (storage *PalettedStorage) uniform(v uint32) bool {
+	mixed := false
+	for _, val := range storage.palette.values {
+		mixed = mixed || val != v
+	}
+	if !mixed {
+		return true
+	}
+	if storage.bitsPerIndex == 0 {
+		return false
+	}
+	for x := byte(0); x < 16; x++ {
+		for y := byte(0); y < 16; y++ {
+			for z := byte(0); z < 16; z++ {
+				if storage.palette.values[storage.paletteIndex(x, y, z)] != v {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
 // Set sets a value at a specific x, y and z. The Palette and PalettedStorage are expanded
 // automatically to make space for the value, should that be needed.
 func (storage *PalettedStorage) Set(x, y, z byte, v uint32) {
@@ -129,7 +156,7 @@ func (storage *PalettedStorage) resize(newPaletteSize paletteSize) {
 	}
 	// Construct a new storage and set all values in there manually. We can't easily do this in a better
 	// way, because all values will be at a different index with a different length.
-	newStorage := newPalettedStorage(make([]uint32, newPaletteSize.uint32s()), storage.palette)
+	newStorage := getStorage(newPaletteSize)
 	for x := byte(0); x < 16; x++ {
 		for y := byte(0); y < 16; y++ {
 			for z := byte(0); z < 16; z++ {
@@ -137,14 +164,23 @@ func (storage *PalettedStorage) resize(newPaletteSize paletteSize) {
 			}
 		}
 	}
-	// Set the new storage.
-	*storage = *newStorage
+	// Set the new storage, which keeps the palette of the storage, and return the old indices to the pools.
+	newStorage.palette, storage.palette = storage.palette, newStorage.palette
+	*storage, *newStorage = *newStorage, *storage
+	newStorage.release()
 }
 
-// compact clears unused indexes in the palette by scanning for usages in the PalettedStorage. This is a
+// compact clears unused indexes in the palette by scanning for usages in the PalettedStorage, and merges indexes
+// pointing to the same value, which may exist after the values of the palette were replaced using Replace. This is a
 // relatively heavy task which should only happen right before the sub chunk holding this PalettedStorage is
-// saved to disk. compact also shrinks the palette size if possible.
+// saved to disk or sent over network. compact also shrinks the palette size, and with it the bits per index of the
+// storage, if possible. Storages that hold only a single value are shrunk to 0 bits per index, in which case no
+// indices are stored at all.
 func (storage *PalettedStorage) compact() {
+	if storage.bitsPerIndex == 0 {
+		// The storage holds a single value and can't be compacted any further.
+		return
+	}
 	usedIndices := make([]bool, storage.palette.Len())
 	for x := byte(0); x < 16; x++ {
 		for y := byte(0); y < 16; y++ {
@@ -153,19 +189,28 @@ func (storage *PalettedStorage) compact() {
 			}
 		}
 	}
-	newRuntimeIDs := make([]uint32, 0, len(usedIndices))
 	conversion := make([]uint16, len(usedIndices))
+	newIndices := make(map[uint32]uint16, len(usedIndices))
 
 	for index, set := range usedIndices {
 		if set {
-			conversion[index] = uint16(len(newRuntimeIDs))
-			newRuntimeIDs = append(newRuntimeIDs, storage.palette.values[index])
+			v := storage.palette.values[index]
+			if newIndex, ok := newIndices[v]; ok {
+				// The value is already present at another index, so simply point to that index instead.
+				conversion[index] = newIndex
+				continue
+			}
+			newIndices[v] = uint16(len(newIndices))
+			conversion[index] = newIndices[v]
 		}
 	}
 	// Construct a new storage and set all values in there manually. We can't easily do this in a better
 	// way, because all values will be at a different index with a different length.
-	size := paletteSizeFor(len(newRuntimeIDs))
-	newStorage := newPalettedStorage(make([]uint32, size.uint32s()), newPalette(size, newRuntimeIDs))
+	newStorage := getStorage(paletteSizeFor(len(newIndices)))
+	newStorage.palette.values = append(newStorage.palette.values, make([]uint32, len(newIndices))...)
+	for v, index := range newIndices {
+		newStorage.palette.values[index] = v
+	}
 
 	for x := byte(0); x < 16; x++ {
 		for y := byte(0); y < 16; y++ {
@@ -176,5 +221,6 @@ func (storage *PalettedStorage) compact() {
 			}
 		}
 	}
-	*storage = *newStorage
+	*storage, *newStorage = *newStorage, *storage
+	newStorage.release()
 }
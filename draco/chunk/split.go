@@ -0,0 +1,112 @@
+package chunk
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/cqdetdev/draco/draco/nbtcodec"
+	"github.com/df-mc/dragonfly/server/block/cube"
+)
+
+// SplitLevelChunk splits the payload of a LevelChunk packet holding count sub chunks, as sent using the legacy sub
+// chunk request mode, into the payloads of the sub chunks as sent in SubChunk packets and the remainder of the
+// chunk, so that the chunk may be sent using sub chunk requests instead. Every sub chunk payload holds the sub chunk
+// itself, encoded with its Y index, followed by the block entities within it. The remainder holds the biomes and
+// border blocks, which form the payload of a LevelChunk packet sent using sub chunk requests.
+func SplitLevelChunk(payload []byte, count int, r cube.Range) (subs [][]byte, biomes []byte, err error) {
+	n := (r.Height() >> 4) + 1
+	if count > n {
+		return nil, nil, fmt.Errorf("sub chunk count %v exceeds the %v sub chunks of range %v", count, n, r)
+	}
+	buf := bytes.NewBuffer(payload)
+	subs = make([][]byte, count)
+	for i := 0; i < count; i++ {
+		data := buf.Bytes()
+		index := byte(i)
+		// The air runtime ID doesn't matter here, as the sub chunk is only decoded to be encoded again.
+		s, err := DecodeSubChunk(0, r, buf, &index, NetworkEncoding)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decode sub chunk %v: %w", i, err)
+		}
+		if int(index) >= count {
+			return nil, nil, fmt.Errorf("sub chunk %v holds index %v beyond the sub chunk count %v", i, index, count)
+		}
+		if data[0] == SubChunkVersion {
+			// The sub chunk already holds its Y index, so it may be sent as it is.
+			subs[index] = append([]byte(nil), data[:len(data)-buf.Len()]...)
+		} else {
+			subs[index] = EncodeSubChunk(s, NetworkEncoding, r, int(index))
+		}
+		s.release()
+	}
+
+	data := buf.Bytes()
+	for i := 0; i < n; i++ {
+		b, err := decodePalettedStorage(buf, NetworkEncoding, BiomePaletteEncoding)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decode biomes: %w", err)
+		}
+		if b != nil {
+			b.release()
+		}
+	}
+	borderBlocks, err := buf.ReadByte()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read border blocks: %w", err)
+	}
+	if len(buf.Next(int(borderBlocks))) != int(borderBlocks) {
+		return nil, nil, fmt.Errorf("read border blocks: expected %v bytes", borderBlocks)
+	}
+	biomes = append([]byte(nil), data[:len(data)-buf.Len()]...)
+
+	// The block entities of all sub chunks follow the border blocks. They are appended to the sub chunk they are in.
+	blockEntities, err := nbtcodec.DecodeAll(buf.Bytes(), nbtcodec.Network)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode block entities: %w", err)
+	}
+	for _, blockEntity := range blockEntities {
+		y, _ := blockEntity["y"].(int32)
+		index := int(y>>4) - r[0]>>4
+		if index < 0 || index >= count {
+			// Block entities outside of the sub chunks sent can't be shown anyway.
+			continue
+		}
+		encoded, err := nbtcodec.Encode(blockEntity, nbtcodec.Network)
+		if err != nil {
+			return nil, nil, fmt.Errorf("encode block entity: %w", err)
+		}
+		subs[index] = append(subs[index], encoded...)
+	}
+	return subs, biomes, nil
+}
+
+// JoinSubChunks joins the payloads of sub chunks as sent in SubChunk packets and the payload of a LevelChunk sent
+// using sub chunk requests, which holds the biomes and border blocks, into the payload of a LevelChunk sent using
+// the legacy sub chunk request mode. It is the reverse of SplitLevelChunk. subs is indexed by the index of the sub
+// chunks in the chunk, and nil entries are sent as sub chunks consisting of air. The LevelChunk must be sent with a
+// sub chunk count of len(subs).
+func JoinSubChunks(subs [][]byte, biomes []byte, r cube.Range) ([]byte, error) {
+	out, blockEntities := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	for i, data := range subs {
+		if data == nil {
+			_, _ = out.Write([]byte{SubChunkVersion, 0, uint8(i + (r[0] >> 4))})
+			continue
+		}
+		var index byte
+		buf := bytes.NewBuffer(data)
+		s, err := DecodeSubChunk(0, r, buf, &index, NetworkEncoding)
+		if err != nil {
+			return nil, fmt.Errorf("decode sub chunk %v: %w", i, err)
+		}
+		s.release()
+		if int(index) != i {
+			return nil, fmt.Errorf("sub chunk %v holds index %v", i, index)
+		}
+		// The sub chunk is followed by the block entities within it, which are sent after the biomes instead.
+		_, _ = out.Write(data[:len(data)-buf.Len()])
+		_, _ = blockEntities.Write(buf.Bytes())
+	}
+	_, _ = out.Write(biomes)
+	_, _ = out.Write(blockEntities.Bytes())
+	return out.Bytes(), nil
+}
@@ -0,0 +1,76 @@
+package chunk
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+func TestSplitJoinLevelChunk(t *testing.T) {
+	c := New(0, testRange)
+	c.SetBlock(0, -64, 0, 0, 1)
+	c.SetBlock(3, 20, 5, 0, 2)
+	const count = 6
+	data := Encode(c, NetworkEncoding)
+	payload := bytes.NewBuffer(nil)
+	for _, sub := range data.SubChunks[:count] {
+		payload.Write(sub)
+	}
+	payload.Write(data.Biomes)
+	// No border blocks, followed by a block entity in the second sub chunk.
+	payload.WriteByte(0)
+	_ = nbt.NewEncoderWithEncoding(payload, nbt.NetworkLittleEndian).Encode(map[string]any{"id": "Chest", "x": int32(3), "y": int32(-44), "z": int32(5)})
+
+	subs, biomes, err := SplitLevelChunk(payload.Bytes(), count, testRange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != count {
+		t.Fatalf("chunk split into %v sub chunks, expected %v", len(subs), count)
+	}
+	if !bytes.Equal(biomes, append(append([]byte(nil), data.Biomes...), 0)) {
+		t.Error("biomes and border blocks were not split off")
+	}
+	for i, sub := range subs {
+		var index byte
+		buf := bytes.NewBuffer(sub)
+		if _, err := DecodeSubChunk(0, testRange, buf, &index, NetworkEncoding); err != nil {
+			t.Fatalf("decode sub chunk %v: %v", i, err)
+		}
+		if int(index) != i {
+			t.Errorf("sub chunk %v holds index %v", i, index)
+		}
+		if hasBlockEntity := buf.Len() > 0; hasBlockEntity != (i == 1) {
+			t.Errorf("sub chunk %v holds block entities: %v", i, hasBlockEntity)
+		}
+	}
+
+	// Sub chunks left out of the joined chunk are air.
+	subs[4] = nil
+	joined, err := JoinSubChunks(subs, biomes, testRange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.NewBuffer(joined)
+	back, err := NetworkDecode(0, buf, count, testRange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rid := back.Block(0, -64, 0, 0); rid != 1 {
+		t.Errorf("block at Y -64 joined as %v, expected 1", rid)
+	}
+	if rid := back.Block(3, 20, 5, 0); rid != 2 {
+		t.Errorf("block at Y 20 joined as %v, expected 2", rid)
+	}
+	if border, _ := buf.ReadByte(); border != 0 {
+		t.Fatalf("joined chunk holds %v border blocks", border)
+	}
+	var blockEntity map[string]any
+	if err := nbt.NewDecoderWithEncoding(buf, nbt.NetworkLittleEndian).Decode(&blockEntity); err != nil {
+		t.Fatalf("decode joined block entity: %v", err)
+	}
+	if blockEntity["id"] != "Chest" || buf.Len() != 0 {
+		t.Errorf("unexpected block entities after biomes: %v", blockEntity)
+	}
+}
@@ -11,7 +11,9 @@ type SubChunk struct {
 
 // NewSubChunk creates a new sub chunk. All sub chunks should be created through this function
 func NewSubChunk(air uint32) *SubChunk {
-	return &SubChunk{air: air}
+	sub := subChunkPool.Get().(*SubChunk)
+	sub.air = air
+	return sub
 }
 
 // Empty checks if the SubChunk is considered empty. This is the case if the SubChunk has 0 block storages or if it has
@@ -51,16 +53,18 @@ func (sub *SubChunk) SetBlock(x, y, z byte, layer uint8, block uint32) {
 }
 
 // Compact cleans the garbage from all block storages that sub chunk contains, so that they may be
-// cleanly written to a database.
-func (sub *SubChunk) compact() {
-	newStorages := make([]*PalettedStorage, 0, len(sub.storages))
+// cleanly written to a database or sent over network using as few bytes as possible.
+func (sub *SubChunk) Compact() {
 	for _, storage := range sub.storages {
 		storage.compact()
-		if len(storage.palette.values) == 1 && storage.palette.values[0] == sub.air {
-			// If the palette has only air in it, it means the storage is empty, so we can ignore it.
-			continue
+	}
+	// If the palette of a storage has only air in it, it means the storage is empty, so we can ignore it. Only
+	// trailing storages may be dropped, as removing any other would move the layers after it down.
+	for len(sub.storages) > 0 {
+		last := sub.storages[len(sub.storages)-1]
+		if len(last.palette.values) != 1 || last.palette.values[0] != sub.air {
+			break
 		}
-		newStorages = append(newStorages, storage)
+		sub.storages = sub.storages[:len(sub.storages)-1]
 	}
-	sub.storages = newStorages
 }
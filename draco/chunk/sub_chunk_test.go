@@ -0,0 +1,47 @@
+package chunk
+
+import "testing"
+
+func TestCompactMergesDuplicates(t *testing.T) {
+	s := NewSubChunk(0)
+	for x := byte(0); x < 16; x++ {
+		s.SetBlock(x, 0, 0, 0, uint32(x%4)+1)
+	}
+	// Replacing the values of the palette leaves the five indices, including that of air, pointing to only two
+	// distinct values.
+	s.Layer(0).Palette().Replace(func(v uint32) uint32 {
+		return v%2 + 10
+	})
+	s.Compact()
+
+	values := s.Layer(0).Palette().values
+	seen := map[uint32]bool{}
+	for _, v := range values {
+		if seen[v] {
+			t.Fatalf("palette holds duplicate value %v after compacting: %v", v, values)
+		}
+		seen[v] = true
+	}
+	if len(values) != 2 {
+		t.Errorf("palette holds %v values after compacting, expected 2: %v", len(values), values)
+	}
+	for x := byte(0); x < 16; x++ {
+		if got, want := s.Block(x, 0, 0, 0), (uint32(x%4)+1)%2+10; got != want {
+			t.Errorf("block %v is %v after compacting, expected %v", x, got, want)
+		}
+	}
+}
+
+func TestCompactKeepsLayers(t *testing.T) {
+	s := NewSubChunk(0)
+	// The first layer only holds air, but the second layer must stay the second layer after compacting.
+	s.SetBlock(0, 0, 0, 1, 7)
+	s.SetBlock(0, 0, 0, 2, 0)
+	s.Compact()
+	if n := len(s.Layers()); n != 2 {
+		t.Fatalf("sub chunk has %v layers after compacting, expected 2", n)
+	}
+	if rid := s.Block(0, 0, 0, 1); rid != 7 {
+		t.Errorf("block in the second layer is %v after compacting, expected 7", rid)
+	}
+}
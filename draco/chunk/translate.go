@@ -0,0 +1,233 @@
+package chunk
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/cqdetdev/draco/draco/biome"
+	"github.com/cqdetdev/draco/draco/blockentity"
+	"github.com/cqdetdev/draco/draco/mismatch"
+	"github.com/cqdetdev/draco/draco/nbtcodec"
+	"github.com/cqdetdev/draco/draco/policy"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/cqdetdev/draco/draco/telemetry"
+	"github.com/df-mc/dragonfly/server/block/cube"
+)
+
+// Translate translates the payload of a LevelChunk packet holding count sub chunks, as sent using the legacy sub
+// chunk request mode, from the version from to the version to. The block runtime IDs in the palettes of all sub
+// chunks are remapped using the translation tables of the state.Registry passed and the biome IDs using the biome
+// registry, after which the chunk is encoded again. The Registry is usually state.Default, or one holding the custom
+// blocks of the server that sent the chunk. The block entities following the biomes and border blocks are
+// translated using the translators registered in the blockentity package. An error is returned if the payload can't
+// be decoded or, under the strict and drop decode policies, if a block state has no equivalent in the version to.
+// Under the lenient policy, such block states are translated to the fallback block (see state.Registry.Fallback).
+func Translate(blocks *state.Registry, payload []byte, count int, r cube.Range, from, to state.Version) ([]byte, error) {
+	fromAir, toAir, err := airOf(blocks, from, to)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(payload)
+	c, err := NetworkDecode(fromAir, buf, count, r)
+	if err != nil {
+		return nil, fmt.Errorf("decode chunk: %w", err)
+	}
+	c.air = toAir
+	for _, s := range c.sub {
+		if err := translateSubChunk(blocks, s, from, to, toAir); err != nil {
+			c.release()
+			return nil, err
+		}
+	}
+	translateBiomes(c, from, to)
+	c.Compact()
+
+	out := getBuffer()
+	defer putBuffer(out)
+	// Sub chunks that weren't present in the payload are not sent.
+	for i, s := range c.sub[:count] {
+		EncodeSubChunkTo(out, s, NetworkEncoding, r, i)
+	}
+	EncodeBiomesTo(out, c, NetworkEncoding)
+	// The chunk was encoded, so nothing refers to its structures anymore.
+	c.release()
+	blockEntities, err := translateBlockEntities(buf.Bytes(), true, from, to)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = out.Write(blockEntities)
+	return CloneBytes(out), nil
+}
+
+// TranslateSubChunk translates the payload of a single sub chunk, as sent in a SubChunk packet, from the version from
+// to the version to, like Translate, including the block entities following the sub chunk.
+func TranslateSubChunk(blocks *state.Registry, payload []byte, r cube.Range, from, to state.Version) ([]byte, error) {
+	fromAir, toAir, err := airOf(blocks, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var index byte
+	buf := bytes.NewBuffer(payload)
+	s, err := DecodeSubChunk(fromAir, r, buf, &index, NetworkEncoding)
+	if err != nil {
+		return nil, fmt.Errorf("decode sub chunk: %w", err)
+	}
+	if err := translateSubChunk(blocks, s, from, to, toAir); err != nil {
+		s.release()
+		return nil, err
+	}
+	s.Compact()
+	blockEntities, err := translateBlockEntities(buf.Bytes(), false, from, to)
+	if err != nil {
+		s.release()
+		return nil, err
+	}
+	out := getBuffer()
+	defer putBuffer(out)
+	EncodeSubChunkTo(out, s, NetworkEncoding, r, int(index))
+	s.release()
+	_, _ = out.Write(blockEntities)
+	return CloneBytes(out), nil
+}
+
+// translateBlockEntities translates the block entities held by the data passed from the version from to the version
+// to. If border is true, the data starts with the border blocks of a chunk, which are kept as they are. The data is
+// returned unchanged if no block entity translators are registered for the versions.
+func translateBlockEntities(data []byte, border bool, from, to state.Version) ([]byte, error) {
+	if !blockentity.Translates(from, to) {
+		return data, nil
+	}
+	buf, out := bytes.NewBuffer(data), bytes.NewBuffer(make([]byte, 0, len(data)))
+	if border {
+		n, err := buf.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("read border blocks: %w", err)
+		}
+		borderBlocks := buf.Next(int(n))
+		if len(borderBlocks) != int(n) {
+			return nil, fmt.Errorf("read border blocks: expected %v bytes", n)
+		}
+		_ = out.WriteByte(n)
+		_, _ = out.Write(borderBlocks)
+	}
+	blockEntities, err := nbtcodec.DecodeAll(buf.Bytes(), nbtcodec.Network)
+	if err != nil {
+		return nil, fmt.Errorf("decode block entities: %w", err)
+	}
+	for _, blockEntity := range blockEntities {
+		blockentity.Translate(from, to, blockEntity)
+	}
+	encoded, err := nbtcodec.EncodeAll(blockEntities, nbtcodec.Network)
+	if err != nil {
+		return nil, fmt.Errorf("encode block entities: %w", err)
+	}
+	_, _ = out.Write(encoded)
+	return out.Bytes(), nil
+}
+
+// translateSubChunk remaps all palette entries of the sub chunk passed from the version from to the version to, using
+// the palettes of the state.Registry passed.
+// Multiple block states may map to the same block state, so the sub chunk should be compacted afterwards to merge
+// duplicate palette entries and send it using as few bits per block as possible. Block states without an equivalent
+// in the version to are recorded in the mismatch report and are replaced with the fallback block under the lenient
+// decode policy, or result in an error otherwise.
+func translateSubChunk(blocks *state.Registry, s *SubChunk, from, to state.Version, toAir uint32) error {
+	s.air = toAir
+	substitutes := policy.Current().Substitutes()
+	fallback, ok := blocks.Fallback(to)
+	if !ok {
+		fallback = toAir
+	}
+	var err error
+	for _, l := range s.storages {
+		l.palette.Replace(func(rid uint32) uint32 {
+			translated, ok := blocks.TranslateRuntimeID(from, to, rid)
+			if !ok {
+				mismatch.Record(from, to, rid)
+				telemetry.RecordBlock(from, to, rid)
+				if substitutes {
+					return fallback
+				}
+				if err == nil {
+					err = fmt.Errorf("translate block runtime ID %v from version %v to %v: no such block state", rid, from, to)
+				}
+			}
+			return translated
+		})
+	}
+	return err
+}
+
+// TranslateBiomes translates the payload of a LevelChunk packet sent using sub chunk requests, which holds the biome
+// storages of a chunk followed by its border blocks, from the version from to the version to, like Translate. The
+// data following the biomes is kept as it is.
+func TranslateBiomes(payload []byte, r cube.Range, from, to state.Version) ([]byte, error) {
+	buf := bytes.NewBuffer(payload)
+	c := New(0, r)
+	if err := decodeBiomes(buf, c); err != nil {
+		c.release()
+		return nil, fmt.Errorf("decode biomes: %w", err)
+	}
+	translateBiomes(c, from, to)
+	// Several biomes may have been translated to the same biome, leaving duplicate palette entries.
+	for _, b := range c.biomes {
+		b.compact()
+	}
+	out := getBuffer()
+	defer putBuffer(out)
+	EncodeBiomesTo(out, c, NetworkEncoding)
+	c.release()
+	_, _ = out.Write(buf.Bytes())
+	return CloneBytes(out), nil
+}
+
+// translateBiomes remaps the biome IDs of the chunk passed from the version from to the version to. Biomes without
+// an equivalent in the version to are translated to their fallback, and biomes that have no fallback, or that don't
+// exist in the version from at all, to the default biome, so that clients don't show corrupted terrain.
+func translateBiomes(c *Chunk, from, to state.Version) {
+	if from == to || !biome.Registered(from) {
+		return
+	}
+	def, hasDefault := biome.ID(to, biome.Default)
+	translated := map[*PalettedStorage]struct{}{}
+	for _, b := range c.biomes {
+		if _, ok := translated[b]; ok {
+			// Successive biome storages may be the same storage, which must only be translated once.
+			continue
+		}
+		translated[b] = struct{}{}
+		b.palette.Replace(func(id uint32) uint32 {
+			if other, ok := biome.TranslateID(from, to, int32(id)); ok {
+				return uint32(other)
+			}
+			if hasDefault {
+				return uint32(def)
+			}
+			return id
+		})
+	}
+}
+
+// airOf returns the runtime IDs of air in the versions passed, as registered in the state.Registry passed.
+func airOf(blocks *state.Registry, from, to state.Version) (fromAir, toAir uint32, err error) {
+	if fromAir, err = airIn(blocks, from); err != nil {
+		return 0, 0, err
+	}
+	if toAir, err = airIn(blocks, to); err != nil {
+		return 0, 0, err
+	}
+	return fromAir, toAir, nil
+}
+
+// airIn returns the runtime ID of air in the version passed, as registered in the state.Registry passed.
+func airIn(blocks *state.Registry, v state.Version) (uint32, error) {
+	p, ok := blocks.PaletteOf(v)
+	if !ok {
+		return 0, fmt.Errorf("no block palette registered for version %v", v)
+	}
+	rid, ok := p.RuntimeID("minecraft:air", nil)
+	if !ok {
+		return 0, fmt.Errorf("block palette of version %v has no air", v)
+	}
+	return rid, nil
+}
@@ -0,0 +1,199 @@
+package chunk
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/biome"
+	"github.com/cqdetdev/draco/draco/blockentity"
+	"github.com/cqdetdev/draco/draco/policy"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+func TestTranslateRoundTrip(t *testing.T) {
+	from, err := state.NewPalette([]state.Block{{Name: "minecraft:air"}, {Name: "minecraft:stone"}, {Name: "minecraft:dirt"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := state.NewPalette([]state.Block{{Name: "minecraft:dirt"}, {Name: "minecraft:stone"}, {Name: "minecraft:air"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.RegisterPalette(-1, from)
+	state.RegisterPalette(-2, to)
+
+	c := New(0, testRange)
+	c.SetBlock(0, 0, 0, 0, 1)
+	c.SetBlock(1, 0, 0, 0, 2)
+	c.SetBlock(0, 20, 0, 0, 2)
+	const count = 6
+	data := Encode(c, NetworkEncoding)
+	payload := bytes.NewBuffer(nil)
+	for _, sub := range data.SubChunks[:count] {
+		payload.Write(sub)
+	}
+	payload.Write(data.Biomes)
+	payload.Write([]byte{0xff})
+
+	translated, err := Translate(state.Default(), payload.Bytes(), count, testRange, -1, -2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.NewBuffer(translated)
+	back, err := NetworkDecode(2, buf, count, testRange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rid := back.Block(0, 0, 0, 0); rid != 1 {
+		t.Errorf("stone translated to %v, expected 1", rid)
+	}
+	if rid := back.Block(1, 0, 0, 0); rid != 0 {
+		t.Errorf("dirt translated to %v, expected 0", rid)
+	}
+	if rid := back.Block(0, 20, 0, 0); rid != 0 {
+		t.Errorf("dirt translated to %v, expected 0", rid)
+	}
+	if rid := back.Block(5, 5, 5, 0); rid != 2 {
+		t.Errorf("air translated to %v, expected 2", rid)
+	}
+	if !bytes.Equal(buf.Bytes(), []byte{0xff}) {
+		t.Errorf("data following the biomes was not kept: %v", buf.Bytes())
+	}
+}
+
+func TestTranslateBiomes(t *testing.T) {
+	biome.Register(-5, map[string]int32{"plains": 1, "meadow": 2, "unknown": 3})
+	biome.Register(-6, map[string]int32{"ocean": 0, "plains": 7})
+
+	c := New(0, testRange)
+	c.SetBiome(0, 0, 0, 2)
+	c.SetBiome(1, 0, 0, 3)
+	c.SetBiome(2, 0, 0, 40)
+	payload := append(EncodeBiomes(c, NetworkEncoding), 0)
+
+	translated, err := TranslateBiomes(payload, testRange, -5, -6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.NewBuffer(translated)
+	back := New(0, testRange)
+	if err := decodeBiomes(buf, back); err != nil {
+		t.Fatal(err)
+	}
+	for x := uint8(0); x < 3; x++ {
+		if id := back.Biome(x, 0, 0); id != 7 {
+			t.Errorf("biome at x %v translated to %v, expected the default biome 7", x, id)
+		}
+	}
+	if !bytes.Equal(buf.Bytes(), []byte{0}) {
+		t.Errorf("data following the biomes was not kept: %v", buf.Bytes())
+	}
+}
+
+func TestTranslateBlockEntities(t *testing.T) {
+	p, err := state.NewPalette([]state.Block{{Name: "minecraft:air"}, {Name: "minecraft:standing_sign"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.RegisterPalette(-7, p)
+	state.RegisterPalette(-8, p)
+	blockentity.Register(-7, -8, "Sign", func(data map[string]any) {
+		data["FrontText"] = data["Text"]
+		delete(data, "Text")
+	})
+
+	c := New(0, testRange)
+	c.SetBlock(0, 0, 0, 0, 1)
+	data := Encode(c, NetworkEncoding)
+	payload := bytes.NewBuffer(nil)
+	payload.Write(data.SubChunks[0])
+	payload.Write(data.Biomes)
+	payload.Write([]byte{1, 0})
+	_ = nbt.NewEncoderWithEncoding(payload, nbt.NetworkLittleEndian).Encode(map[string]any{"id": "Sign", "x": int32(0), "y": int32(0), "z": int32(0), "Text": "hi"})
+	_ = nbt.NewEncoderWithEncoding(payload, nbt.NetworkLittleEndian).Encode(map[string]any{"id": "Chest", "x": int32(1), "y": int32(0), "z": int32(0)})
+
+	translated, err := Translate(state.Default(), payload.Bytes(), 1, testRange, -7, -8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.NewBuffer(translated)
+	if _, err := NetworkDecode(0, buf, 1, testRange); err != nil {
+		t.Fatal(err)
+	}
+	if border := buf.Next(2); !bytes.Equal(border, []byte{1, 0}) {
+		t.Fatalf("border blocks were not kept: %v", border)
+	}
+	dec := nbt.NewDecoderWithEncoding(buf, nbt.NetworkLittleEndian)
+	var sign, chest map[string]any
+	if err := dec.Decode(&sign); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&chest); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sign["Text"]; ok || sign["FrontText"] != "hi" {
+		t.Errorf("sign was not translated: %v", sign)
+	}
+	if chest["id"] != "Chest" || len(chest) != 4 {
+		t.Errorf("chest without translator was changed: %v", chest)
+	}
+}
+
+func TestTranslatePolicy(t *testing.T) {
+	from, err := state.NewPalette([]state.Block{{Name: "minecraft:air"}, {Name: "minecraft:stone"}, {Name: "minecraft:copper_block"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := state.NewPalette([]state.Block{{Name: "minecraft:stone"}, {Name: "minecraft:air"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.RegisterPalette(-9, from)
+	state.RegisterPalette(-10, to)
+	defer policy.Set(policy.Current())
+
+	c := New(0, testRange)
+	c.SetBlock(0, -64, 0, 0, 1)
+	c.SetBlock(1, -64, 0, 0, 2)
+	payload := Encode(c, NetworkEncoding).SubChunks[0]
+
+	policy.Set(policy.Strict)
+	if _, err := TranslateSubChunk(state.Default(), payload, testRange, -9, -10); err == nil {
+		t.Error("expected error translating a block state without equivalent under the strict policy")
+	}
+	policy.Set(policy.Lenient)
+	translated, err := TranslateSubChunk(state.Default(), payload, testRange, -9, -10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index byte
+	sub, err := DecodeSubChunk(1, testRange, bytes.NewBuffer(translated), &index, NetworkEncoding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rid := sub.Block(0, 0, 0, 0); rid != 0 {
+		t.Errorf("stone translated to %v, expected 0", rid)
+	}
+	if rid := sub.Block(1, 0, 0, 0); rid != 1 {
+		t.Errorf("copper block translated to %v, expected air (1)", rid)
+	}
+
+	policy.SetFallbackBlock("minecraft:stone")
+	defer policy.SetFallbackBlock("")
+	translated, err = TranslateSubChunk(state.Default(), payload, testRange, -9, -10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub, err = DecodeSubChunk(1, testRange, bytes.NewBuffer(translated), &index, NetworkEncoding); err != nil {
+		t.Fatal(err)
+	}
+	if rid := sub.Block(1, 0, 0, 0); rid != 0 {
+		t.Errorf("copper block translated to %v, expected the fallback block stone (0)", rid)
+	}
+
+	policy.Set(policy.Drop)
+	if _, err := TranslateSubChunk(state.Default(), payload, testRange, -9, -10); err == nil {
+		t.Error("expected error translating a block state without equivalent under the drop policy")
+	}
+}
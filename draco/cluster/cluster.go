@@ -0,0 +1,300 @@
+// Package cluster implements migrating players between the nodes of a cluster of proxies, so that a node may be
+// restarted without disconnecting its players: a draining node hands the XUID and backend of every player over to
+// another node and transfers the players there, where they are attached to the backend they were on again.
+// Nodes exchange migrations over their admin APIs, which must share the same secret. The admin APIs are also used
+// to apply the duplicate login policy to players connected to other nodes.
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/proxy"
+)
+
+// Node is another proxy of the cluster that players may be migrated to.
+type Node struct {
+	// Name is the name that the node is identified by.
+	Name string
+	// Address is the address that players join the node on, such as "play2.example.com:19132".
+	Address string
+	// API is the URL of the admin API of the node, such as "http://10.0.0.2:8081", which migrations are sent to.
+	API string
+}
+
+// Migration holds the identity of a player migrated from another node and the backend it was attached to.
+type Migration struct {
+	// XUID is the XBOX Live user ID of the player.
+	XUID string `json:"xuid"`
+	// Name is the name of the player.
+	Name string `json:"name"`
+	// Backend is the name of the backend that the player was attached to on the node it was migrated from.
+	Backend string `json:"backend"`
+	// From is the name of the node that the player was migrated from.
+	From string `json:"from,omitempty"`
+}
+
+// migrationTTL is the duration for which a Migration accepted is kept, after which the player is considered not to
+// have followed the transfer and joins like any other player.
+const migrationTTL = time.Minute
+
+// ErrUnknownNode is returned by Drain if no Node with the name passed was set using SetNodes.
+var ErrUnknownNode = errors.New("unknown node")
+
+var (
+	// mu guards name, secret, nodes, draining and pending.
+	mu sync.Mutex
+	// name is the name of the node itself, and secret the secret of the admin APIs of all nodes.
+	name, secret string
+	// nodes holds the nodes set using SetNodes.
+	nodes []Node
+	// draining is the Node that players are migrated to while the node drains.
+	draining *Node
+	// pending holds the migrations accepted using Accept that were not yet taken, keyed by XUID, with the time at
+	// which they expire.
+	pending = map[string]pendingMigration{}
+)
+
+// pendingMigration is a Migration accepted that was not yet taken.
+type pendingMigration struct {
+	Migration
+	expires time.Time
+}
+
+// SetNodes sets the other nodes of the cluster, replacing those set before. self is the name of the node itself,
+// sent along with migrations, and apiSecret the secret of the admin APIs of the nodes passed.
+func SetNodes(self, apiSecret string, n []Node) {
+	mu.Lock()
+	defer mu.Unlock()
+	name, secret, nodes = self, apiSecret, append([]Node(nil), n...)
+}
+
+// Accept accepts migrations sent by a draining node, so that the players migrated are attached to the backend they
+// were on once they join.
+func Accept(migrations []Migration) {
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+	for xuid, m := range pending {
+		if now.After(m.expires) {
+			delete(pending, xuid)
+		}
+	}
+	for _, m := range migrations {
+		if m.XUID != "" {
+			pending[m.XUID] = pendingMigration{Migration: m, expires: now.Add(migrationTTL)}
+		}
+	}
+}
+
+// Take returns and removes the Migration accepted for the player with the XUID passed. False is returned if no
+// Migration was accepted for the player, or if it expired.
+func Take(xuid string) (Migration, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	m, ok := pending[xuid]
+	delete(pending, xuid)
+	if !ok || time.Now().After(m.expires) {
+		return Migration{}, false
+	}
+	return m.Migration, true
+}
+
+// Draining returns the Node that players are migrated to if the node is draining. False is returned if it is not.
+func Draining() (Node, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if draining == nil {
+		return Node{}, false
+	}
+	return *draining, true
+}
+
+// Drain migrates all players online to the Node with the name passed, ignoring case, after which Draining reports the
+// Node, so that players joining afterwards may be turned away and the node shut down. The migrations are sent to the
+// API of the Node before the players are transferred to its address. Guests have no XUID to be recognised by, so they
+// join the first backend of the Node. Drain returns the amount of players migrated.
+func Drain(nodeName string) (int, error) {
+	mu.Lock()
+	var target *Node
+	for _, n := range nodes {
+		if strings.EqualFold(n.Name, nodeName) {
+			n := n
+			target = &n
+			break
+		}
+	}
+	self, apiSecret := name, secret
+	if target != nil {
+		// Players joining while the migrations are sent are turned away, as they would not be migrated otherwise.
+		draining = target
+	}
+	mu.Unlock()
+	if target == nil {
+		return 0, ErrUnknownNode
+	}
+
+	sessions := proxy.Sessions()
+	migrations := make([]Migration, 0, len(sessions))
+	for _, s := range sessions {
+		if s.XUID() != "" {
+			migrations = append(migrations, Migration{XUID: s.XUID(), Name: s.Name(), Backend: s.Backend().Name, From: self})
+		}
+	}
+	if err := send(*target, apiSecret, migrations); err != nil {
+		mu.Lock()
+		draining = nil
+		mu.Unlock()
+		return 0, fmt.Errorf("send migrations to node %v: %w", target.Name, err)
+	}
+
+	migrated := 0
+	for _, s := range sessions {
+		if err := s.Migrate(target.Address); err != nil {
+			s.Logger().Error("error migrating to node", "node", target.Name, "err", err)
+			continue
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// ClaimIdentity applies the DuplicateLoginPolicy passed to the other nodes of the cluster for a player with the XUID
+// passed joining the node, after it was claimed on the node itself using proxy.ClaimIdentity. With
+// proxy.DuplicateLoginReject, proxy.ErrDuplicateLogin is returned if the player is online on another node. Otherwise
+// the player is kicked from the other nodes, showing the message passed. The nodes are asked at the same time, and
+// nodes that can't be reached are skipped, so that a node that is down never keeps players from joining the others.
+// An empty XUID, as held by guests, is never claimed.
+func ClaimIdentity(xuid string, policy proxy.DuplicateLoginPolicy, message string) error {
+	mu.Lock()
+	others, apiSecret := nodes, secret
+	mu.Unlock()
+	if xuid == "" || len(others) == 0 {
+		return nil
+	}
+	online := make([]bool, len(others))
+	var wg sync.WaitGroup
+	for i, n := range others {
+		wg.Add(1)
+		go func(i int, n Node) {
+			defer wg.Done()
+			var err error
+			if policy == proxy.DuplicateLoginReject {
+				online[i], err = playerOnline(n, apiSecret, xuid)
+			} else {
+				err = kickPlayer(n, apiSecret, xuid, message)
+			}
+			if err != nil {
+				logging.Default().Warn("error claiming identity on node", "node", n.Name, "xuid", xuid, "err", err)
+			}
+		}(i, n)
+	}
+	wg.Wait()
+	for _, ok := range online {
+		if ok {
+			return proxy.ErrDuplicateLogin
+		}
+	}
+	return nil
+}
+
+// playerOnline checks if the player with the XUID passed is online on the Node passed.
+func playerOnline(n Node, apiSecret, xuid string) (bool, error) {
+	resp, err := request(n, apiSecret, http.MethodGet, "/players", url.Values{"xuid": {xuid}})
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	var players []struct {
+		XUID string `json:"xuid"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&players); err != nil {
+		return false, fmt.Errorf("decode response: %w", err)
+	}
+	for _, p := range players {
+		if p.XUID == xuid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// kickPlayer disconnects the player with the XUID passed from the Node passed, showing the message passed. It does
+// nothing if the player is not online on the Node.
+func kickPlayer(n Node, apiSecret, xuid, message string) error {
+	resp, err := request(n, apiSecret, http.MethodPost, "/kick", url.Values{"xuid": {xuid}, "message": {message}})
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil
+		}
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// errNotFound is returned by request if the admin API of a node responded with 404 Not Found.
+var errNotFound = errors.New("not found")
+
+// duplicateClient is the http.Client that the other nodes are asked about players joining with. Its timeout is short,
+// as players wait for the nodes to respond before they are connected to a backend.
+var duplicateClient = &http.Client{Timeout: time.Second * 2}
+
+// request sends a request with the method, path and query passed to the admin API of the Node passed, authenticated
+// with the secret passed. An error is returned if the API did not respond with a 2xx status, which wraps errNotFound
+// for 404 Not Found. The body of the response returned must be closed.
+func request(n Node, apiSecret, method, path string, query url.Values) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(n.API, "/")+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiSecret)
+	resp, err := duplicateClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%v request: %w", method, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%v request: %w", method, errNotFound)
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%v request: %v: %s", method, resp.Status, bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
+
+// client is the http.Client that migrations are sent with.
+var client = &http.Client{Timeout: time.Second * 10}
+
+// send sends the migrations passed to the admin API of the Node passed, authenticated with the secret passed.
+func send(n Node, apiSecret string, migrations []Migration) error {
+	body, err := json.Marshal(migrations)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(n.API, "/")+"/migrations", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiSecret)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("POST request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("POST request: %v: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
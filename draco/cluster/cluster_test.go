@@ -0,0 +1,124 @@
+package cluster
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/proxy"
+)
+
+func TestTake(t *testing.T) {
+	Accept([]Migration{{XUID: "1", Backend: "lobby"}, {Backend: "guests"}})
+	if _, ok := Take(""); ok {
+		t.Error("took a migration without XUID")
+	}
+	if m, ok := Take("1"); !ok || m.Backend != "lobby" {
+		t.Errorf("took %#v, %v, expected the migration to lobby", m, ok)
+	}
+	if _, ok := Take("1"); ok {
+		t.Error("took the same migration twice")
+	}
+
+	Accept([]Migration{{XUID: "2", Backend: "lobby"}})
+	mu.Lock()
+	m := pending["2"]
+	m.expires = time.Now().Add(-time.Second)
+	pending["2"] = m
+	mu.Unlock()
+	if _, ok := Take("2"); ok {
+		t.Error("took an expired migration")
+	}
+}
+
+func TestDrain(t *testing.T) {
+	defer func() {
+		mu.Lock()
+		draining = nil
+		mu.Unlock()
+		SetNodes("", "", nil)
+	}()
+	var received []Migration
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/migrations" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorised", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	SetNodes("node-1", "wrong", []Node{{Name: "node-2", Address: "127.0.0.1:19133", API: srv.URL}})
+	if _, err := Drain("node-3"); err != ErrUnknownNode {
+		t.Errorf("draining to an unknown node returned %v, expected %v", err, ErrUnknownNode)
+	}
+	if _, err := Drain("node-2"); err == nil {
+		t.Error("draining with the wrong secret succeeded")
+	}
+	if _, ok := Draining(); ok {
+		t.Error("still draining after a failed drain")
+	}
+
+	SetNodes("node-1", "secret", []Node{{Name: "node-2", Address: "127.0.0.1:19133", API: srv.URL + "/"}})
+	if n, err := Drain("NODE-2"); err != nil || n != 0 {
+		t.Fatalf("drain returned %v, %v, expected no players migrated", n, err)
+	}
+	if received == nil {
+		t.Error("no migrations were received")
+	}
+	if n, ok := Draining(); !ok || n.Name != "node-2" {
+		t.Errorf("draining to %#v, %v, expected node-2", n, ok)
+	}
+}
+
+func TestClaimIdentity(t *testing.T) {
+	defer SetNodes("", "", nil)
+	var kicked []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorised", http.StatusUnauthorized)
+			return
+		}
+		xuid := r.URL.Query().Get("xuid")
+		switch {
+		case r.URL.Path == "/players" && r.Method == http.MethodGet:
+			// Both players are listed, like a node that doesn't support the xuid parameter would.
+			_, _ = w.Write([]byte(`[{"name":"Steve","xuid":"1"},{"name":"Alex","xuid":"2"}]`))
+		case r.URL.Path == "/kick" && r.Method == http.MethodPost && xuid == "1":
+			kicked = append(kicked, xuid+": "+r.URL.Query().Get("message"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "player not online", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	if err := ClaimIdentity("1", proxy.DuplicateLoginReject, ""); err != nil {
+		t.Errorf("claim without nodes returned %v, expected no error", err)
+	}
+	SetNodes("node-1", "secret", []Node{{Name: "node-2", API: srv.URL}, {Name: "node-3", API: down.URL}})
+	tests := []struct {
+		xuid   string
+		policy proxy.DuplicateLoginPolicy
+		err    error
+	}{
+		{"1", proxy.DuplicateLoginReject, proxy.ErrDuplicateLogin},
+		{"3", proxy.DuplicateLoginReject, nil},
+		{"", proxy.DuplicateLoginReject, nil},
+		{"1", proxy.DuplicateLoginKickOld, nil},
+		{"3", proxy.DuplicateLoginKickOld, nil},
+	}
+	for _, test := range tests {
+		if err := ClaimIdentity(test.xuid, test.policy, "You logged in elsewhere."); err != test.err {
+			t.Errorf("claim of %q with policy %q returned %v, expected %v", test.xuid, test.policy, err, test.err)
+		}
+	}
+	if len(kicked) != 1 || kicked[0] != "1: You logged in elsewhere." {
+		t.Errorf("kicked %q, expected the player online on the other node only", kicked)
+	}
+}
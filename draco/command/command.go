@@ -0,0 +1,158 @@
+// Package command translates the argument types of command parameters, as sent in the AvailableCommands packet,
+// between protocol versions. The numerical IDs of argument types shift whenever types are added to the game, so the
+// argument types of every version are registered by name, like actor metadata keys are in the metadata package, and
+// parameters are translated by looking up the name of their type in the version translated from and its ID in the
+// version translated to.
+package command
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/item"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// String is the name of the string argument type, which argument types without an equivalent in the version
+// translated to are translated to. Every version must register it.
+const String = "string"
+
+// argTypes holds the argument types of a single version keyed by their name, and the names keyed by their ID.
+type argTypes struct {
+	ids   map[string]uint32
+	names map[uint32]string
+}
+
+var (
+	// typesMu guards types.
+	typesMu sync.RWMutex
+	// types holds the argument types registered using Register, keyed by their version.
+	types = map[state.Version]argTypes{}
+)
+
+// Register registers the argument types of the version passed, keyed by their name, such as "target" or "position".
+// Registering argument types for a version that already has them replaces them. Argument types are generally
+// registered in the init function of the package holding them.
+func Register(v state.Version, ids map[string]uint32) {
+	t := argTypes{ids: ids, names: make(map[uint32]string, len(ids))}
+	for name, id := range ids {
+		t.names[id] = name
+	}
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	types[v] = t
+}
+
+// typesOf returns the argument types registered for the version passed.
+func typesOf(v state.Version) (argTypes, bool) {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	t, ok := types[v]
+	return t, ok
+}
+
+// ArgType looks up the ID of the argument type with the name passed in the version passed.
+func ArgType(v state.Version, name string) (uint32, bool) {
+	t, ok := typesOf(v)
+	if !ok {
+		return 0, false
+	}
+	id, ok := t.ids[name]
+	return id, ok
+}
+
+// TranslateArgType translates the ID of an argument type from the version from to the version to. Argument types
+// without an equivalent in the version to are translated to String, so that the client shows a parameter that accepts
+// any text rather than one of an unrelated type. IDs not known in the version from are returned as they are, as are
+// all IDs if either version has no argument types registered.
+func TranslateArgType(from, to state.Version, id uint32) uint32 {
+	if from == to {
+		return id
+	}
+	f, ok := typesOf(from)
+	if !ok {
+		return id
+	}
+	t, ok := typesOf(to)
+	if !ok {
+		return id
+	}
+	name, ok := f.names[id]
+	if !ok {
+		return id
+	}
+	if other, ok := t.ids[name]; ok {
+		return other
+	}
+	return t.ids[String]
+}
+
+// Translate translates the argument types of all command parameters in the packet passed from the version from to
+// the version to. Parameters with a suffix don't hold an argument type and are left unchanged, as are parameters of
+// enums, of which only the options of the enums of items and blocks are translated, using TranslateEnum.
+func Translate(from, to state.Version, pk *packet.AvailableCommands) {
+	if from == to {
+		return
+	}
+	// Parameters of the same enum share its options, so every enum is translated only once.
+	enums := map[string][]string{}
+	for _, c := range pk.Commands {
+		for _, o := range c.Overloads {
+			for i, param := range o.Parameters {
+				if !param.Enum.Dynamic && itemEnums[param.Enum.Type] {
+					options, ok := enums[param.Enum.Type]
+					if !ok {
+						options = TranslateEnum(from, to, param.Enum.Options)
+						enums[param.Enum.Type] = options
+					}
+					o.Parameters[i].Enum.Options = options
+				}
+				if param.Enum.Dynamic || len(param.Enum.Options) != 0 || param.Suffix != "" || param.Type&(protocol.CommandArgEnum|protocol.CommandArgSoftEnum|protocol.CommandArgSuffixed) != 0 {
+					continue
+				}
+				o.Parameters[i].Type = param.Type&^0xffff | TranslateArgType(from, to, param.Type&0xffff)
+			}
+		}
+	}
+}
+
+// itemEnums holds the types of the enums of which the options are the names of items, without namespace, which
+// includes the enum of blocks, as all blocks with an item form are items.
+var itemEnums = map[string]bool{"Item": true, "Block": true}
+
+// TranslateEnum translates the options of an enum of items or blocks from the version from to the version to,
+// returning the options translated. Items that were renamed are translated to their name in the version to, and
+// items that don't exist in the version to are left out, so that clients don't suggest them. Options that aren't
+// items in the version from, such as blocks without an item form, are kept as they are, as are all options if either
+// version has no item palette registered.
+func TranslateEnum(from, to state.Version, options []string) []string {
+	f, ok := item.PaletteOf(from)
+	if !ok {
+		return options
+	}
+	if _, ok := item.PaletteOf(to); !ok {
+		return options
+	}
+	translated := make([]string, 0, len(options))
+	for _, o := range options {
+		name := o
+		if !strings.Contains(name, ":") {
+			name = "minecraft:" + name
+		}
+		if _, ok := f.RuntimeID(name); !ok {
+			translated = append(translated, o)
+			continue
+		}
+		other, ok := item.TranslateName(from, to, name)
+		if !ok {
+			continue
+		}
+		if !strings.Contains(o, ":") {
+			other = strings.TrimPrefix(other, "minecraft:")
+		}
+		translated = append(translated, other)
+	}
+	return translated
+}
@@ -0,0 +1,58 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/item"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestTranslate(t *testing.T) {
+	Register(-1, map[string]uint32{"int": 1, "target": 8, String: 44, "position": 53, "block_states": 71})
+	Register(-2, map[string]uint32{"int": 1, "target": 8, String: 39, "position": 48})
+
+	param := func(typ uint32) protocol.CommandParameter {
+		return protocol.CommandParameter{Type: protocol.CommandArgValid | typ}
+	}
+	pk := &packet.AvailableCommands{Commands: []protocol.Command{{Name: "tp", Overloads: []protocol.CommandOverload{{
+		Parameters: []protocol.CommandParameter{
+			param(8),
+			param(53),
+			param(71),
+			param(100),
+			{Type: protocol.CommandArgEnum | protocol.CommandArgValid | 53, Enum: protocol.CommandEnum{Type: "mode", Options: []string{"a"}}},
+			{Type: protocol.CommandArgSuffixed | 53, Suffix: "L"},
+		},
+	}}}}}
+	Translate(-1, -2, pk)
+	params := pk.Commands[0].Overloads[0].Parameters
+	for i, expected := range []uint32{
+		protocol.CommandArgValid | 8,
+		protocol.CommandArgValid | 48,
+		protocol.CommandArgValid | 39,
+		protocol.CommandArgValid | 100,
+		protocol.CommandArgEnum | protocol.CommandArgValid | 53,
+		protocol.CommandArgSuffixed | 53,
+	} {
+		if params[i].Type != expected {
+			t.Errorf("parameter %v translated to type %#x, expected %#x", i, params[i].Type, expected)
+		}
+	}
+}
+
+func TestTranslateEnum(t *testing.T) {
+	item.RegisterPalette(-3, item.NewPalette(map[string]int32{"minecraft:stick": 1, "minecraft:new_name": 2, "minecraft:removed": 3}, nil))
+	item.RegisterPalette(-4, item.NewPalette(map[string]int32{"minecraft:stick": 5, "minecraft:old_name": 6}, map[string]string{"minecraft:new_name": "minecraft:old_name"}))
+
+	options := TranslateEnum(-3, -4, []string{"stick", "new_name", "removed", "water"})
+	expected := []string{"stick", "old_name", "water"}
+	if len(options) != len(expected) {
+		t.Fatalf("expected options %v, got %v", expected, options)
+	}
+	for i := range expected {
+		if options[i] != expected[i] {
+			t.Errorf("expected options %v, got %v", expected, options)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package draco
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// copyPlan is the plan to copy the fields of one packet type to another, built once per pair of types by planCopy.
+type copyPlan struct {
+	fields []copiedField
+	// gaps is true if any field of either type could not be copied.
+	gaps bool
+}
+
+// copiedField is a field copied by a copyPlan from the field with index src to the field with index dst. If convert is
+// true, the value is converted to the type of the field copied to.
+type copiedField struct {
+	src, dst int
+	convert  bool
+}
+
+// copyPlans holds the copyPlans built, keyed by a [2]reflect.Type of the types copied to and from.
+var copyPlans sync.Map
+
+// copyFields copies the fields of the packet src to the fields with the same name of the packet dst, which is the
+// same packet of another protocol, such as a packet of the legacy package. Numbers are converted if the fields differ
+// in size. Fields of dst that src doesn't have, or of which the type differs, are left zero, and fields of src that
+// dst doesn't have are dropped. Such gaps are logged once per packet type and counted in the "field_gaps" metrics
+// group, so that a packet that changed in a new version degrades gracefully instead of failing to translate until
+// its translation is written. handled lists the fields that the caller translates itself, which are neither copied
+// nor reported, and must be the same for every call copying the same types.
+func copyFields(dst, src packet.Packet, handled ...string) {
+	d, s := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	key := [2]reflect.Type{d.Type(), s.Type()}
+	p, ok := copyPlans.Load(key)
+	if !ok {
+		p, _ = copyPlans.LoadOrStore(key, planCopy(d.Type(), s.Type(), handled))
+	}
+	plan := p.(copyPlan)
+	for _, f := range plan.fields {
+		v := s.Field(f.src)
+		if f.convert {
+			v = v.Convert(d.Field(f.dst).Type())
+		}
+		d.Field(f.dst).Set(v)
+	}
+	if plan.gaps {
+		metrics.AddTo("field_gaps", d.Type().Name(), 1)
+	}
+}
+
+// planCopy builds the copyPlan to copy the fields of the struct type src to those of the struct type dst, logging
+// the fields that can't be copied.
+func planCopy(dst, src reflect.Type, handled []string) copyPlan {
+	skip := make(map[string]bool, len(handled))
+	for _, name := range handled {
+		skip[name] = true
+	}
+	var plan copyPlan
+	var zeroed, dropped []string
+	for i := 0; i < dst.NumField(); i++ {
+		df := dst.Field(i)
+		if !df.IsExported() || skip[df.Name] {
+			continue
+		}
+		sf, ok := src.FieldByName(df.Name)
+		switch {
+		case !ok || len(sf.Index) != 1:
+			zeroed = append(zeroed, df.Name)
+		case sf.Type.AssignableTo(df.Type):
+			plan.fields = append(plan.fields, copiedField{src: sf.Index[0], dst: i})
+		case numeric(sf.Type) && numeric(df.Type):
+			plan.fields = append(plan.fields, copiedField{src: sf.Index[0], dst: i, convert: true})
+		default:
+			zeroed = append(zeroed, fmt.Sprintf("%v (%v, was %v)", df.Name, df.Type, sf.Type))
+		}
+	}
+	for i := 0; i < src.NumField(); i++ {
+		sf := src.Field(i)
+		if !sf.IsExported() || skip[sf.Name] {
+			continue
+		}
+		if _, ok := dst.FieldByName(sf.Name); !ok {
+			dropped = append(dropped, sf.Name)
+		}
+	}
+	if len(zeroed) > 0 || len(dropped) > 0 {
+		plan.gaps = true
+		logging.Default().Warn("packet fields not translated", "packet", dst.Name(), "zeroed", strings.Join(zeroed, ", "), "dropped", strings.Join(dropped, ", "))
+	}
+	return plan
+}
+
+// numeric checks if values of the type passed are integers or floats.
+func numeric(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
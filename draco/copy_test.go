@@ -0,0 +1,68 @@
+package draco
+
+import (
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/legacy"
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// newerText is a Text packet of a fictional newer version, with a field that changed type, one that was added and
+// one that was removed.
+type newerText struct {
+	TextType         byte
+	NeedsTranslation int
+	SourceName       []byte
+	Message          string
+	Unknown          float32
+}
+
+// ID ...
+func (*newerText) ID() uint32 { return packet.IDText }
+
+// Marshal ...
+func (*newerText) Marshal(*protocol.Writer) {}
+
+// Unmarshal ...
+func (*newerText) Unmarshal(*protocol.Reader) {}
+
+func TestCopyFields(t *testing.T) {
+	src := &newerText{TextType: 2, NeedsTranslation: 1, SourceName: []byte("Steve"), Message: "hi", Unknown: 3}
+	dst := &packet.Text{XUID: "kept"}
+	before := metrics.Group("field_gaps")["Text"]
+	copyFields(dst, src, "XUID")
+	if dst.TextType != 2 || dst.Message != "hi" {
+		t.Errorf("identical fields not copied: %#v", dst)
+	}
+	if dst.SourceName != "" || dst.NeedsTranslation {
+		t.Errorf("fields of another type not zeroed: %#v", dst)
+	}
+	if dst.XUID != "kept" {
+		t.Errorf("handled field overwritten: %#v", dst)
+	}
+	if metrics.Group("field_gaps")["Text"] != before+1 {
+		t.Error("expected the gaps of the copy to be counted")
+	}
+}
+
+func TestCopyFieldsNoGaps(t *testing.T) {
+	start := &packet.StartGame{EntityRuntimeID: 7, WorldSeed: 1<<40 + 5, LevelID: "level"}
+	legacyStart := &legacy.StartGame{}
+	copyFields(legacyStart, start, "Items")
+	if legacyStart.EntityRuntimeID != 7 || legacyStart.WorldSeed != 5 || legacyStart.LevelID != "level" {
+		t.Errorf("StartGame not copied: %#v", legacyStart)
+	}
+	copyFields(&legacy.AddPlayer{}, &packet.AddPlayer{}, "GameType")
+
+	before := metrics.Group("field_gaps")
+	copyFields(&legacy.StartGame{}, start, "Items")
+	copyFields(&legacy.AddPlayer{}, &packet.AddPlayer{}, "GameType")
+	after := metrics.Group("field_gaps")
+	for _, name := range []string{"StartGame", "AddPlayer"} {
+		if after[name] != before[name] {
+			t.Errorf("expected all fields of %v to be translated", name)
+		}
+	}
+}
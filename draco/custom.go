@@ -0,0 +1,103 @@
+package draco
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/legacymappings"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// maxCustomRegistries is the maximum amount of registries holding custom blocks that are kept. Every backend with
+// custom blocks usually sends the same blocks to every player, so it only needs to hold one for every backend.
+const maxCustomRegistries = 64
+
+var (
+	// customMu guards customRegistries.
+	customMu sync.Mutex
+	// customRegistries holds the registries created by WithServerBlocks, keyed by the custom block states of the
+	// client and the server, so that clients of the same server share a registry and the tables generated for it.
+	customRegistries = map[string]*state.Registry{}
+)
+
+// WithCustomBlocks returns the protocol passed translating block runtime IDs with palettes that hold the custom
+// blocks passed, as sent by a server in the StartGame packet, and the state.Registry holding these palettes. Clients
+// merge the custom blocks of the server they join into their block palette, which changes the runtime IDs of other
+// block states too, so the protocol returned must only be used for a client that was sent these custom blocks in
+// its StartGame packet. Protocols other than Protocol are returned unchanged along with state.Default, which is also
+// the registry of servers without custom blocks. An error is returned if the custom blocks collide with the blocks of
+// either version.
+func WithCustomBlocks(p minecraft.Protocol, entries []protocol.BlockEntry) (minecraft.Protocol, *state.Registry, error) {
+	return WithServerBlocks(p, entries, entries)
+}
+
+// WithServerBlocks returns the protocol passed translating block runtime IDs for a client that was sent other custom
+// blocks in its StartGame packet than those of the server it is attached to, such as a client transferred to another
+// server. client holds the custom blocks that the client was sent and server those of the server: the palette of the
+// latest version holds the custom blocks of the server and that of the client the custom blocks of the client, so that
+// custom blocks of the server that the client doesn't know are translated like other blocks without an equivalent.
+// Like WithCustomBlocks, it returns the state.Registry holding the palettes, or state.Default if there are no custom
+// blocks at all.
+func WithServerBlocks(p minecraft.Protocol, client, server []protocol.BlockEntry) (minecraft.Protocol, *state.Registry, error) {
+	switch pr := p.(type) {
+	case Protocol:
+		blocks, err := customRegistry(state.CustomBlockStates(client), state.CustomBlockStates(server))
+		if err != nil {
+			return p, state.Default(), err
+		}
+		pr.blocks = blocks
+		return pr, blocks, nil
+	case shadowProtocol:
+		converted, blocks, err := WithServerBlocks(pr.Protocol, client, server)
+		if err != nil {
+			return p, state.Default(), err
+		}
+		// The shadow translates the same packets, so it must know the same custom blocks.
+		shadow, _, _ := WithServerBlocks(pr.shadow, client, server)
+		return shadowProtocol{Protocol: converted, shadow: shadow}, blocks, nil
+	}
+	return p, state.Default(), nil
+}
+
+// customRegistry returns a Registry holding the palettes of state.Default with the custom block states of the server
+// merged into the palette of the latest version and those of the client into the palette of the legacy version,
+// reusing a Registry created before for the same block states. state.Default is returned if neither have custom
+// block states.
+func customRegistry(client, server []state.Block) (*state.Registry, error) {
+	if len(client) == 0 && len(server) == 0 {
+		return state.Default(), nil
+	}
+	key := fmt.Sprint(client, server)
+	customMu.Lock()
+	defer customMu.Unlock()
+	if r, ok := customRegistries[key]; ok {
+		return r, nil
+	}
+	r := state.Default().Clone()
+	for _, v := range []struct {
+		version  state.Version
+		ordering state.Ordering
+		custom   []state.Block
+	}{{latestmappings.Version, latestmappings.Ordering, server}, {legacymappings.Version, legacymappings.Ordering, client}} {
+		if len(v.custom) == 0 {
+			continue
+		}
+		p, ok := r.PaletteOf(v.version)
+		if !ok {
+			return nil, fmt.Errorf("no block palette registered for version %v", v.version)
+		}
+		merged, err := p.WithCustomBlocks(v.custom, v.ordering)
+		if err != nil {
+			return nil, fmt.Errorf("merge custom blocks into palette of version %v: %w", v.version, err)
+		}
+		r.RegisterPalette(v.version, merged)
+	}
+	if len(customRegistries) >= maxCustomRegistries {
+		customRegistries = map[string]*state.Registry{}
+	}
+	customRegistries[key] = r
+	return r, nil
+}
@@ -0,0 +1,91 @@
+package draco
+
+import (
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/legacymappings"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestWithCustomBlocks(t *testing.T) {
+	entries := []protocol.BlockEntry{{Name: "draco:custom"}}
+	p, blocks, err := WithCustomBlocks(Protocol{}, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks == state.Default() {
+		t.Fatal("expected a registry holding the custom blocks")
+	}
+	if _, again, _ := WithCustomBlocks(Protocol{}, entries); again != blocks {
+		t.Error("expected protocols with the same custom blocks to share a registry")
+	}
+
+	latest, _ := blocks.PaletteOf(latestmappings.Version)
+	legacy, _ := blocks.PaletteOf(legacymappings.Version)
+	latestRID, ok := latest.RuntimeID("draco:custom", nil)
+	if !ok {
+		t.Fatal("custom block not merged into the palette of 1.18.30")
+	}
+	expected, _ := legacy.RuntimeID("draco:custom", nil)
+	pk := p.ConvertFromLatest(&packet.UpdateBlock{NewBlockRuntimeID: latestRID}).(*packet.UpdateBlock)
+	if pk.NewBlockRuntimeID != expected {
+		t.Errorf("custom block translated to %v, expected %v", pk.NewBlockRuntimeID, expected)
+	}
+
+	if _, blocks, _ := WithCustomBlocks(Protocol{}, nil); blocks != state.Default() {
+		t.Error("expected a protocol without custom blocks to use the default registry")
+	}
+	shadowed, _, _ := WithCustomBlocks(shadowProtocol{Protocol: Protocol{}, shadow: Protocol{}}, entries)
+	if s := shadowed.(shadowProtocol); s.Protocol.(Protocol).blocks != blocks || s.shadow.(Protocol).blocks != blocks {
+		t.Error("expected a protocol and its shadow to translate the custom blocks")
+	}
+}
+
+func TestWithServerBlocks(t *testing.T) {
+	a, b := []protocol.BlockEntry{{Name: "draco:a"}}, []protocol.BlockEntry{{Name: "draco:b"}}
+	tests := []struct {
+		name           string
+		client, server []protocol.BlockEntry
+	}{
+		// Challenged clients may be started without the custom blocks of the backend they are attached to.
+		{"challenged", nil, a},
+		// Transferred clients keep the custom blocks of the backend they first joined.
+		{"transferred", a, b},
+	}
+	for _, test := range tests {
+		_, blocks, err := WithServerBlocks(Protocol{}, test.client, test.server)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		latest, _ := blocks.PaletteOf(latestmappings.Version)
+		legacy, _ := blocks.PaletteOf(legacymappings.Version)
+		if _, ok := latest.RuntimeID(test.server[0].Name, nil); !ok {
+			t.Errorf("%v: custom block of server not merged into the palette of 1.18.30", test.name)
+		}
+		if _, ok := legacy.RuntimeID(test.server[0].Name, nil); ok {
+			t.Errorf("%v: custom block of server merged into the palette of the client", test.name)
+		}
+		if test.client != nil {
+			if _, ok := legacy.RuntimeID(test.client[0].Name, nil); !ok {
+				t.Errorf("%v: custom block of client not merged into the palette of the client", test.name)
+			}
+		}
+
+		// Vanilla blocks sent by the server must still arrive as the same block, even though the custom blocks of
+		// the server shifted their runtime IDs.
+		for _, name := range []string{"minecraft:air", "minecraft:barrier", "minecraft:info_update"} {
+			serverRID, _ := latest.RuntimeID(name, nil)
+			rid, ok := blocks.TranslateRuntimeID(latestmappings.Version, legacymappings.Version, serverRID)
+			if s, _ := legacy.State(rid); !ok || s.Name != name {
+				t.Errorf("%v: %v of server translated to %v (%v), expected %v", test.name, name, s.Name, ok, name)
+			}
+		}
+		customRID, _ := latest.RuntimeID(test.server[0].Name, nil)
+		if rid, ok := blocks.TranslateRuntimeID(latestmappings.Version, legacymappings.Version, customRID); ok {
+			t.Errorf("%v: custom block of server translated to %v, expected no equivalent for the client", test.name, rid)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// baseURL is the base URL of the Discord API.
+const baseURL = "https://discord.com/api/v10"
+
+// api is a minimal client of the Discord REST API, implementing only the endpoints needed by a Bridge.
+type api struct {
+	c     *http.Client
+	token string
+}
+
+// apiMessage is a message as returned by the Discord API.
+type apiMessage struct {
+	ID        string `json:"id"`
+	Content   string `json:"content"`
+	WebhookID string `json:"webhook_id"`
+	Author    struct {
+		Username string `json:"username"`
+		Bot      bool   `json:"bot"`
+	} `json:"author"`
+}
+
+// noMentions disables all mentions in a message posted, so that players can't mention everyone in the channel.
+var noMentions = map[string][]string{"parse": {}}
+
+// executeWebhook posts a message through the webhook with the URL passed.
+func (a *api) executeWebhook(webhookURL, username, content string) error {
+	body := map[string]any{"content": content, "allowed_mentions": noMentions}
+	if username != "" {
+		body["username"] = username
+	}
+	return a.do(http.MethodPost, webhookURL, false, body, nil)
+}
+
+// createMessage posts a message in the channel with the ID passed as the bot.
+func (a *api) createMessage(channelID, content string) error {
+	body := map[string]any{"content": content, "allowed_mentions": noMentions}
+	return a.do(http.MethodPost, baseURL+"/channels/"+url.PathEscape(channelID)+"/messages", true, body, nil)
+}
+
+// messages returns up to limit messages from the channel with the ID passed, newest first. If after is not empty,
+// only messages posted after the message with that ID are returned.
+func (a *api) messages(channelID, after string, limit int) ([]apiMessage, error) {
+	q := url.Values{"limit": {strconv.Itoa(limit)}}
+	if after != "" {
+		q.Set("after", after)
+	}
+	var msgs []apiMessage
+	err := a.do(http.MethodGet, baseURL+"/channels/"+url.PathEscape(channelID)+"/messages?"+q.Encode(), true, nil, &msgs)
+	return msgs, err
+}
+
+// do makes a request to the Discord API, encoding body as JSON if non-nil and decoding the response into v if
+// non-nil. If auth is true, the request is authorised using the bot token of the api.
+func (a *api) do(method, u string, auth bool, body, v any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if auth {
+		req.Header.Set("Authorization", "Bot "+a.token)
+	}
+	// The URL is not included in any errors returned, as the URL of a webhook holds its token.
+	resp, err := a.c.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%v request: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%v request: %v: %s", method, resp.Status, msg)
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return nil
+}
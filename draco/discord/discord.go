@@ -0,0 +1,177 @@
+package discord
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/lang"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Config is the configuration of a Bridge.
+type Config struct {
+	// Backend is the name of the backend whose players are bridged. If empty, the players of all backends are.
+	Backend string
+	// WebhookURL is the URL of a Discord webhook that messages are posted to. In webhook mode, messages are only
+	// bridged from the game to Discord, and chat messages are posted using the name of the player.
+	WebhookURL string
+	// BotToken and ChannelID are the token of a Discord bot and the ID of the channel it bridges. In bot mode,
+	// messages are bridged in both directions: messages posted in the channel by users other than bots are sent
+	// to the players in-game. The bot must be allowed to read message content.
+	// If WebhookURL is also set, messages are posted through the webhook rather than by the bot.
+	BotToken, ChannelID string
+	// PollInterval is the interval at which the channel is checked for new messages in bot mode. If 0, it is
+	// checked every two seconds.
+	PollInterval time.Duration
+}
+
+// queueSize is the maximum amount of messages waiting to be posted to Discord. Messages posted while the queue is
+// full are dropped, so that a slow or unavailable Discord API never blocks players.
+const queueSize = 64
+
+// Bridge bridges the chat of the players on the proxy to a Discord channel, and announces players joining and
+// leaving the proxy in it.
+type Bridge struct {
+	conf  Config
+	api   *api
+	queue chan message
+}
+
+// message is a message waiting to be posted to Discord.
+type message struct {
+	// username is the name the message is posted with through a webhook. If empty, the name of the webhook is used.
+	username, content string
+}
+
+// New creates a Bridge using the Config passed. An error is returned if the Config has neither a webhook nor a
+// bot configured.
+func New(conf Config) (*Bridge, error) {
+	if conf.WebhookURL == "" && (conf.BotToken == "" || conf.ChannelID == "") {
+		return nil, errors.New("discord bridge needs a webhook URL or a bot token and channel ID")
+	}
+	if conf.PollInterval == 0 {
+		conf.PollInterval = time.Second * 2
+	}
+	return &Bridge{
+		conf:  conf,
+		api:   &api{c: &http.Client{Timeout: time.Second * 10}, token: conf.BotToken},
+		queue: make(chan message, queueSize),
+	}, nil
+}
+
+// Start starts bridging messages. It registers the handlers of the Bridge with the proxy and returns immediately.
+func (b *Bridge) Start() {
+	go b.post()
+	if b.conf.BotToken != "" && b.conf.ChannelID != "" {
+		go b.poll()
+	}
+
+	proxy.OnStart(func(s *proxy.Session) {
+		if b.bridges(s) {
+			b.enqueue(message{content: lang.Translate(lang.DefaultLocale, "discord.join", escape(s.Name()))})
+		}
+	})
+	proxy.OnClose(func(s *proxy.Session) {
+		if b.bridges(s) {
+			b.enqueue(message{content: lang.Translate(lang.DefaultLocale, "discord.quit", escape(s.Name()))})
+		}
+	})
+	proxy.Handle(proxy.ClientToServer, func(s *proxy.Session, pk *packet.Text) proxy.Action {
+		if pk.TextType == packet.TextTypeChat && b.bridges(s) {
+			if b.conf.WebhookURL != "" {
+				b.enqueue(message{username: s.Name(), content: escape(pk.Message)})
+			} else {
+				b.enqueue(message{content: lang.Translate(lang.DefaultLocale, "discord.chat", escape(s.Name()), escape(pk.Message))})
+			}
+		}
+		return proxy.Forward
+	})
+}
+
+// bridges checks if the Bridge bridges the player of the Session passed.
+func (b *Bridge) bridges(s *proxy.Session) bool {
+	return b.conf.Backend == "" || s.Backend().Name == b.conf.Backend
+}
+
+// enqueue adds a message to the queue of messages to post. If the queue is full, the message is dropped.
+func (b *Bridge) enqueue(m message) {
+	select {
+	case b.queue <- m:
+	default:
+		log.Printf("discord: dropping message, queue is full")
+	}
+}
+
+// post posts the messages in the queue to Discord, one at a time.
+func (b *Bridge) post() {
+	for m := range b.queue {
+		var err error
+		if b.conf.WebhookURL != "" {
+			err = b.api.executeWebhook(b.conf.WebhookURL, m.username, m.content)
+		} else {
+			err = b.api.createMessage(b.conf.ChannelID, m.content)
+		}
+		if err != nil {
+			log.Printf("discord: error posting message: %v", err)
+		}
+	}
+}
+
+// poll checks the channel of the Bridge for new messages every PollInterval, sending them to the bridged players.
+func (b *Bridge) poll() {
+	// Only messages posted after the Bridge was started are bridged, so start after the latest message.
+	var after string
+	if latest, err := b.api.messages(b.conf.ChannelID, "", 1); err != nil {
+		log.Printf("discord: error reading channel: %v", err)
+	} else if len(latest) > 0 {
+		after = latest[0].ID
+	}
+
+	t := time.NewTicker(b.conf.PollInterval)
+	defer t.Stop()
+	for range t.C {
+		msgs, err := b.api.messages(b.conf.ChannelID, after, 50)
+		if err != nil {
+			log.Printf("discord: error reading channel: %v", err)
+			continue
+		}
+		// Messages are returned newest first.
+		for i := len(msgs) - 1; i >= 0; i-- {
+			m := msgs[i]
+			after = m.ID
+			if m.Author.Bot || m.WebhookID != "" || strings.TrimSpace(m.Content) == "" {
+				// Skip messages by bots and webhooks, which includes those posted by the Bridge itself.
+				continue
+			}
+			b.broadcast(m.Author.Username, m.Content)
+		}
+	}
+}
+
+// broadcast sends a message posted on Discord to all players bridged.
+func (b *Bridge) broadcast(author, content string) {
+	// Strip formatting codes, so that Discord users can't make their messages look like messages from the server.
+	author, content = strings.ReplaceAll(author, "§", ""), strings.ReplaceAll(content, "§", "")
+	// The message is translated once for every locale, so that it is only encoded once for all players sharing one.
+	locales := map[string][]*proxy.Session{}
+	for _, s := range proxy.Sessions() {
+		if b.bridges(s) {
+			locales[s.Locale()] = append(locales[s.Locale()], s)
+		}
+	}
+	for _, sessions := range locales {
+		proxy.Broadcast(sessions, &packet.Text{TextType: packet.TextTypeRaw, Message: sessions[0].Translate("discord.message", author, content)})
+	}
+}
+
+// escape escapes Discord markdown in the string passed, so that it is shown as-is.
+func escape(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// markdownEscaper escapes all characters with a special meaning in Discord markdown.
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `_`, `\_`, "`", "\\`", `~`, `\~`, `|`, `\|`, `>`, `\>`)
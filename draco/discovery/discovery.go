@@ -0,0 +1,388 @@
+// Package discovery registers the proxy with a service discovery system, such as Consul, etcd or Kubernetes, so that
+// DNS-based or load-balancer-based steering of clients across a fleet of proxies can pick up proxies as they come and
+// go and prefer those with room for more players. The registration is refreshed on a heartbeat and expires if the
+// proxy stops refreshing it.
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/logging"
+)
+
+const (
+	// KindConsul is the Kind of Registrars registering the proxy as a service named Key with the Consul agent at
+	// their URL. The service has a TTL check that is passed on every heartbeat, and the state of the proxy is held in
+	// the metadata of the service.
+	KindConsul = "consul"
+	// KindEtcd is the Kind of Registrars storing the Instance as JSON under Key/<ID> in the etcd cluster at their
+	// URL, which is written through the JSON gateway of etcd v3. The key is attached to a lease kept alive on every
+	// heartbeat, so that it is deleted if the proxy stops.
+	KindEtcd = "etcd"
+	// KindKubernetes is the Kind of Registrars annotating the pod that the proxy runs in with the state of the
+	// proxy, using annotations prefixed with Key, such as "draco/players". URL, Token, Namespace and Pod default to
+	// those of the pod when running in a cluster.
+	KindKubernetes = "kubernetes"
+)
+
+// serviceAccount is the directory that Kubernetes mounts the credentials of the service account of a pod in.
+const serviceAccount = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Instance describes the proxy as it is registered.
+type Instance struct {
+	// ID identifies the proxy among all proxies registered.
+	ID string `json:"id"`
+	// Address is the address that players join the proxy on, such as "play1.example.com:19132".
+	Address string `json:"address"`
+	// Capacity is the maximum amount of players online at the same time, or 0 if it is not limited, and Players the
+	// amount of players online.
+	Capacity int `json:"capacity"`
+	Players  int `json:"players"`
+	// MinProtocol and MaxProtocol are the oldest and newest protocol IDs that clients may join with, and MinVersion
+	// and MaxVersion the matching game versions.
+	MinProtocol int32  `json:"min_protocol"`
+	MaxProtocol int32  `json:"max_protocol"`
+	MinVersion  string `json:"min_version"`
+	MaxVersion  string `json:"max_version"`
+	// Draining is true if the proxy is draining and turns joining players away.
+	Draining bool `json:"draining"`
+}
+
+// fields returns the state of the Instance as string fields, as held in Consul metadata and Kubernetes annotations.
+func (inst Instance) fields() map[string]string {
+	return map[string]string{
+		"address":      inst.Address,
+		"capacity":     strconv.Itoa(inst.Capacity),
+		"players":      strconv.Itoa(inst.Players),
+		"min_protocol": strconv.Itoa(int(inst.MinProtocol)),
+		"max_protocol": strconv.Itoa(int(inst.MaxProtocol)),
+		"min_version":  inst.MinVersion,
+		"max_version":  inst.MaxVersion,
+		"draining":     strconv.FormatBool(inst.Draining),
+	}
+}
+
+// Registrar registers an Instance with a service discovery system. A Registrar must not be copied after it was
+// first used, and its methods must not be called concurrently.
+type Registrar struct {
+	// Kind is the kind of system that the Instance is registered with: KindConsul, KindEtcd or KindKubernetes.
+	Kind string
+	// URL is the base URL of the Consul agent, the JSON gateway of the etcd cluster or the Kubernetes API server,
+	// such as "http://127.0.0.1:8500".
+	URL string
+	// Key is the name of the service in Consul, the prefix of the key in etcd or the prefix of the annotations in
+	// Kubernetes.
+	Key string
+	// Token authenticates the requests to the system, if set.
+	Token string
+	// Namespace and Pod are the namespace and name of the pod annotated for KindKubernetes.
+	Namespace, Pod string
+	// Interval is the interval at which the registration is refreshed. It expires after three intervals without
+	// being refreshed.
+	Interval time.Duration
+	// Client is the client that requests are sent with. If nil, a client with a timeout of Interval is used.
+	Client *http.Client
+
+	// lease is the ID of the etcd lease that the key of the Instance is attached to, or 0 if none was granted yet.
+	lease int64
+	// registered is the ID of the Instance registered last.
+	registered string
+}
+
+// Validate checks if the Registrar is valid, returning an error if it isn't.
+func (r *Registrar) Validate() error {
+	switch r.Kind {
+	case KindConsul, KindEtcd, KindKubernetes:
+	default:
+		return fmt.Errorf("unknown kind %q", r.Kind)
+	}
+	if r.URL != "" || r.Kind != KindKubernetes {
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			return fmt.Errorf("parse URL: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return errors.New("URL must use HTTP or HTTPS")
+		}
+	}
+	if r.Key == "" {
+		return fmt.Errorf("a key must be set for %v", r.Kind)
+	}
+	if r.Interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	return nil
+}
+
+// Run registers the Instance returned by f every Interval until ctx is done, after which the Instance is
+// deregistered. Errors are logged and retried on the next heartbeat. Run blocks until the Instance was deregistered.
+func (r *Registrar) Run(ctx context.Context, f func() Instance) {
+	t := time.NewTicker(r.Interval)
+	defer t.Stop()
+	for {
+		if err := r.Register(ctx, f()); err != nil && ctx.Err() == nil {
+			logging.Default().Warn("error registering with service discovery", "kind", r.Kind, "err", err)
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			dctx, cancel := context.WithTimeout(context.Background(), r.Interval)
+			if err := r.Deregister(dctx); err != nil {
+				logging.Default().Warn("error deregistering from service discovery", "kind", r.Kind, "err", err)
+			}
+			cancel()
+			return
+		}
+	}
+}
+
+// Register registers the Instance passed, or refreshes its registration if it was registered before.
+func (r *Registrar) Register(ctx context.Context, inst Instance) error {
+	if err := r.init(); err != nil {
+		return err
+	}
+	var err error
+	switch r.Kind {
+	case KindConsul:
+		err = r.registerConsul(ctx, inst)
+	case KindEtcd:
+		err = r.registerEtcd(ctx, inst)
+	case KindKubernetes:
+		err = r.annotate(ctx, inst.fields())
+	default:
+		return fmt.Errorf("unknown kind %v", r.Kind)
+	}
+	if err == nil {
+		r.registered = inst.ID
+	}
+	return err
+}
+
+// Deregister removes the Instance registered last. Nothing happens if no Instance was registered.
+func (r *Registrar) Deregister(ctx context.Context) error {
+	if r.registered == "" {
+		return nil
+	}
+	var err error
+	switch r.Kind {
+	case KindConsul:
+		err = r.do(ctx, http.MethodPut, "/v1/agent/service/deregister/"+url.PathEscape(r.registered), "", nil, nil)
+	case KindEtcd:
+		if r.lease != 0 {
+			// Revoking the lease deletes the key attached to it.
+			err = r.do(ctx, http.MethodPost, "/v3/lease/revoke", "", map[string]any{"ID": r.lease}, nil)
+			r.lease = 0
+		}
+	case KindKubernetes:
+		// A merge patch removes annotations set to null.
+		fields := map[string]string{}
+		for k := range (Instance{}).fields() {
+			fields[k] = ""
+		}
+		err = r.annotate(ctx, fields)
+	}
+	if err == nil {
+		r.registered = ""
+	}
+	return err
+}
+
+// init fills in the settings of the Registrar that are not set with their defaults.
+func (r *Registrar) init() error {
+	if r.Kind == KindKubernetes {
+		if r.URL == "" {
+			host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+			if host == "" {
+				return errors.New("no API server URL set and not running in a Kubernetes cluster")
+			}
+			r.URL = "https://" + net.JoinHostPort(host, port)
+			if r.Client == nil {
+				client, err := inClusterClient(r.Interval)
+				if err != nil {
+					return err
+				}
+				r.Client = client
+			}
+		}
+		if r.Token == "" {
+			if token, err := os.ReadFile(serviceAccount + "/token"); err == nil {
+				r.Token = strings.TrimSpace(string(token))
+			}
+		}
+		if r.Namespace == "" {
+			ns, err := os.ReadFile(serviceAccount + "/namespace")
+			if err != nil {
+				return fmt.Errorf("no namespace set: %w", err)
+			}
+			r.Namespace = strings.TrimSpace(string(ns))
+		}
+		if r.Pod == "" {
+			if r.Pod = os.Getenv("POD_NAME"); r.Pod == "" {
+				// The hostname of a pod is its name unless set otherwise in its spec.
+				name, err := os.Hostname()
+				if err != nil {
+					return fmt.Errorf("no pod set: %w", err)
+				}
+				r.Pod = name
+			}
+		}
+	}
+	if r.Client == nil {
+		r.Client = &http.Client{Timeout: r.Interval}
+	}
+	return nil
+}
+
+// inClusterClient returns an HTTP client trusting the CA of the Kubernetes cluster that the pod runs in.
+func inClusterClient(timeout time.Duration) (*http.Client, error) {
+	ca, err := os.ReadFile(serviceAccount + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("read cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid cluster CA")
+	}
+	return &http.Client{Timeout: timeout, Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}, nil
+}
+
+// registerConsul registers the Instance passed as a service with the Consul agent and passes its TTL check.
+func (r *Registrar) registerConsul(ctx context.Context, inst Instance) error {
+	service := map[string]any{
+		"ID":   inst.ID,
+		"Name": r.Key,
+		"Meta": inst.fields(),
+		"Check": map[string]any{
+			"TTL":                            (r.Interval * 3).String(),
+			"DeregisterCriticalServiceAfter": (r.Interval * 30).String(),
+		},
+	}
+	if host, port, err := net.SplitHostPort(inst.Address); err == nil {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsUnspecified() {
+			// Services registered without an address take that of the agent, which is what an address such as
+			// 0.0.0.0 means.
+			service["Address"] = host
+		}
+		service["Port"], _ = strconv.Atoi(port)
+	}
+	if err := r.do(ctx, http.MethodPut, "/v1/agent/service/register", "", service, nil); err != nil {
+		return err
+	}
+	return r.do(ctx, http.MethodPut, "/v1/agent/check/pass/service:"+url.PathEscape(inst.ID), "", nil, nil)
+}
+
+// registerEtcd keeps the lease of the Instance alive, granting a new one if it expired, and stores the Instance
+// passed under its key.
+func (r *Registrar) registerEtcd(ctx context.Context, inst Instance) error {
+	if r.lease != 0 {
+		var resp struct {
+			Result struct {
+				TTL string `json:"TTL"`
+			} `json:"result"`
+		}
+		if err := r.do(ctx, http.MethodPost, "/v3/lease/keepalive", "", map[string]any{"ID": r.lease}, &resp); err != nil {
+			return err
+		}
+		if ttl, _ := strconv.Atoi(resp.Result.TTL); ttl <= 0 {
+			// The lease expired, together with the key attached to it.
+			r.lease = 0
+		}
+	}
+	if r.lease == 0 {
+		var resp struct {
+			ID string `json:"ID"`
+		}
+		ttl := int64((r.Interval * 3).Seconds())
+		if err := r.do(ctx, http.MethodPost, "/v3/lease/grant", "", map[string]any{"TTL": ttl}, &resp); err != nil {
+			return err
+		}
+		id, err := strconv.ParseInt(resp.ID, 10, 64)
+		if err != nil || id == 0 {
+			return fmt.Errorf("invalid lease ID %q", resp.ID)
+		}
+		r.lease = id
+	}
+	value, _ := json.Marshal(inst)
+	return r.do(ctx, http.MethodPost, "/v3/kv/put", "", map[string]any{
+		"key":   base64.StdEncoding.EncodeToString([]byte(strings.TrimSuffix(r.Key, "/") + "/" + inst.ID)),
+		"value": base64.StdEncoding.EncodeToString(value),
+		"lease": r.lease,
+	}, nil)
+}
+
+// annotate sets the annotations of the pod to the fields passed, prefixed with Key. Fields with an empty value are
+// removed.
+func (r *Registrar) annotate(ctx context.Context, fields map[string]string) error {
+	annotations := make(map[string]any, len(fields))
+	for k, v := range fields {
+		if v == "" {
+			annotations[r.Key+"/"+k] = nil
+			continue
+		}
+		annotations[r.Key+"/"+k] = v
+	}
+	path := "/api/v1/namespaces/" + url.PathEscape(r.Namespace) + "/pods/" + url.PathEscape(r.Pod)
+	patch := map[string]any{"metadata": map[string]any{"annotations": annotations}}
+	return r.do(ctx, http.MethodPatch, path, "application/merge-patch+json", patch, nil)
+}
+
+// do sends a request with the JSON encoded body passed, if not nil, to the path passed relative to URL, decoding the
+// JSON response into v if not nil.
+func (r *Registrar) do(ctx context.Context, method, path, contentType string, body, v any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+		if contentType == "" {
+			contentType = "application/json"
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(r.URL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if r.Token != "" {
+		if r.Kind == KindConsul {
+			req.Header.Set("X-Consul-Token", r.Token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+r.Token)
+		}
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%v %v: unexpected status %v: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response to %v %v: %w", method, path, err)
+	}
+	return nil
+}
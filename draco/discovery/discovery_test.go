@@ -0,0 +1,182 @@
+package discovery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testInstance = Instance{ID: "node-1", Address: "0.0.0.0:19132", Capacity: 100, Players: 3, MinProtocol: 486, MaxProtocol: 503, MinVersion: "1.18.10", MaxVersion: "1.19.1"}
+
+func TestRegisterConsul(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+		service  map[string]any
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/v1/agent/service/register" {
+			_ = json.NewDecoder(r.Body).Decode(&service)
+		}
+	}))
+	defer srv.Close()
+
+	r := &Registrar{Kind: KindConsul, URL: srv.URL, Key: "draco", Token: "secret", Interval: time.Second}
+	if err := r.Validate(); err != nil {
+		t.Fatalf("expected the registrar to be valid: %v", err)
+	}
+	if err := r.Register(context.Background(), testInstance); err != nil {
+		t.Fatalf("error registering: %v", err)
+	}
+	if err := r.Deregister(context.Background()); err != nil {
+		t.Fatalf("error deregistering: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{"PUT /v1/agent/service/register", "PUT /v1/agent/check/pass/service:node-1", "PUT /v1/agent/service/deregister/node-1"}
+	if len(requests) != len(expected) {
+		t.Fatalf("expected requests %v, got %v", expected, requests)
+	}
+	for i := range expected {
+		if requests[i] != expected[i] {
+			t.Errorf("expected request %v to be %v, got %v", i, expected[i], requests[i])
+		}
+	}
+	if service["Name"] != "draco" || service["Port"] != float64(19132) {
+		t.Errorf("unexpected service %v", service)
+	}
+	if _, ok := service["Address"]; ok {
+		t.Errorf("expected an unspecified address to be left out, got %v", service["Address"])
+	}
+	if meta, _ := service["Meta"].(map[string]any); meta["players"] != "3" || meta["max_protocol"] != "503" {
+		t.Errorf("unexpected metadata %v", service["Meta"])
+	}
+}
+
+func TestRegisterEtcd(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		grants int
+		alive  = true
+		kv     = map[string]string{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/v3/lease/grant":
+			grants++
+			alive = true
+			_, _ = w.Write([]byte(`{"ID":"42","TTL":"3"}`))
+		case "/v3/lease/keepalive":
+			if alive {
+				_, _ = w.Write([]byte(`{"result":{"ID":"42","TTL":"3"}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"result":{"ID":"42"}}`))
+		case "/v3/kv/put":
+			key, _ := base64.StdEncoding.DecodeString(body["key"].(string))
+			value, _ := base64.StdEncoding.DecodeString(body["value"].(string))
+			kv[string(key)] = string(value)
+		case "/v3/lease/revoke":
+			kv = map[string]string{}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	r := &Registrar{Kind: KindEtcd, URL: srv.URL, Key: "/draco/proxies/", Interval: time.Second}
+	for i := 0; i < 2; i++ {
+		if err := r.Register(context.Background(), testInstance); err != nil {
+			t.Fatalf("error registering: %v", err)
+		}
+	}
+	mu.Lock()
+	var inst Instance
+	if err := json.Unmarshal([]byte(kv["/draco/proxies/node-1"]), &inst); err != nil || inst != testInstance {
+		t.Errorf("expected %+v to be stored, got %+v (%v)", testInstance, inst, err)
+	}
+	if grants != 1 {
+		t.Errorf("expected the lease to be kept alive, got %v grants", grants)
+	}
+	alive = false
+	mu.Unlock()
+
+	if err := r.Register(context.Background(), testInstance); err != nil {
+		t.Fatalf("error registering: %v", err)
+	}
+	if err := r.Deregister(context.Background()); err != nil {
+		t.Fatalf("error deregistering: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if grants != 2 {
+		t.Errorf("expected a new lease to be granted after the lease expired, got %v grants", grants)
+	}
+	if len(kv) != 0 {
+		t.Errorf("expected the key to be deleted, got %v", kv)
+	}
+}
+
+func TestRegisterKubernetes(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		annotations = map[string]string{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/api/v1/namespaces/games/pods/draco-0" || r.Header.Get("Content-Type") != "application/merge-patch+json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var patch struct {
+			Metadata struct {
+				Annotations map[string]*string `json:"annotations"`
+			} `json:"metadata"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&patch)
+		mu.Lock()
+		defer mu.Unlock()
+		for k, v := range patch.Metadata.Annotations {
+			if v == nil {
+				delete(annotations, k)
+				continue
+			}
+			annotations[k] = *v
+		}
+	}))
+	defer srv.Close()
+
+	r := &Registrar{Kind: KindKubernetes, URL: srv.URL, Key: "draco", Namespace: "games", Pod: "draco-0", Interval: time.Second}
+	if err := r.Register(context.Background(), testInstance); err != nil {
+		t.Fatalf("error registering: %v", err)
+	}
+	mu.Lock()
+	if annotations["draco/players"] != "3" || annotations["draco/capacity"] != "100" || annotations["draco/draining"] != "false" {
+		t.Errorf("unexpected annotations %v", annotations)
+	}
+	mu.Unlock()
+	if err := r.Deregister(context.Background()); err != nil {
+		t.Fatalf("error deregistering: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(annotations) != 0 {
+		t.Errorf("expected all annotations to be removed, got %v", annotations)
+	}
+}
@@ -0,0 +1,228 @@
+// Package effect translates the IDs of the sound and particle effects that a server plays in the world between
+// protocol versions. The IDs of sounds played using LevelSoundEvent packets, the types of LevelEvent packets, the
+// particle types of legacy particle level events and the identifiers of particle effects spawned using
+// SpawnParticleEffect packets are registered by name for every version, and are translated by looking up their name
+// in the version translated from and their ID in the version translated to. Effects that a version lacks are played
+// as a similar effect that exists in it, or not at all.
+package effect
+
+import (
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/fallback"
+	"github.com/cqdetdev/draco/draco/state"
+)
+
+// Layout holds the sound and particle effects of a single version, keyed by their name. IDs not present in a Layout
+// are assumed to be identical in all versions and are translated as they are, so a Layout only has to hold the
+// effects that differ from other versions and the effects that those fall back to.
+type Layout struct {
+	// Sounds holds the IDs of the sounds played using LevelSoundEvent packets, such as "raid_horn".
+	Sounds map[string]uint32
+	// LevelEvents holds the types of LevelEvent packets, such as "sculk_charge".
+	LevelEvents map[string]int32
+	// Particles holds the particle types shown using LevelEvent packets with the type
+	// packet.LevelEventParticleLegacyEvent combined with the particle type, such as "soul".
+	Particles map[string]int32
+	// Effects holds the identifiers of the particle effects spawned using SpawnParticleEffect packets, such as
+	// "minecraft:soul_particle". Effects are identified by name in every version, so Effects only holds the effects
+	// that other versions lack and those that they fall back to.
+	Effects []string
+}
+
+// Fallbacks holds the names of effects that effects missing in a version are played as, keyed by the name of the
+// missing effect, for every kind of effect held by a Layout.
+type Fallbacks struct {
+	Sounds, LevelEvents, Particles, Effects map[string]string
+}
+
+// layout is a Layout with lookups from the IDs of effects back to their names.
+type layout struct {
+	Layout
+	soundNames                     map[uint32]string
+	levelEventNames, particleNames map[int32]string
+	effects                        map[string]struct{}
+}
+
+var (
+	// layoutMu guards layouts and fallbacks.
+	layoutMu sync.RWMutex
+	// layouts holds the layouts registered using Register, keyed by their version.
+	layouts = map[state.Version]layout{}
+	// fallbacks holds the effects that effects are played as in versions that don't have them.
+	fallbacks = Fallbacks{
+		Sounds: map[string]string{
+			"sculk_charge": "sculk_catalyst_bloom",
+			"sculk_spread": "sculk_catalyst_bloom",
+		},
+		LevelEvents: map[string]string{
+			"sculk_charge": "sculk_catalyst_bloom",
+		},
+		Particles: map[string]string{
+			"sculk_soul": "soul",
+		},
+		Effects: map[string]string{
+			"minecraft:sculk_soul_particle": "minecraft:soul_particle",
+		},
+	}
+)
+
+func init() {
+	// Goat horns play their sound in a variant of one of four instruments, which older versions don't have. The raid
+	// horn is the closest sound that they do have.
+	for _, instrument := range []string{"call", "harmony", "melody", "bass"} {
+		for i := '0'; i <= '9'; i++ {
+			fallbacks.Sounds["goat_"+instrument+"_"+string(i)] = "raid_horn"
+		}
+	}
+}
+
+// Register registers the Layout of the version passed. Registering a Layout for a version that already has one
+// replaces it. Layouts are generally registered in the init function of the package holding them.
+func Register(v state.Version, l Layout) {
+	r := layout{Layout: l, effects: make(map[string]struct{}, len(l.Effects))}
+	r.soundNames = names(l.Sounds)
+	r.levelEventNames = names(l.LevelEvents)
+	r.particleNames = names(l.Particles)
+	for _, e := range l.Effects {
+		r.effects[e] = struct{}{}
+	}
+	layoutMu.Lock()
+	defer layoutMu.Unlock()
+	layouts[v] = r
+}
+
+// RegisterFallbacks registers the fallbacks passed, adding them to the fallbacks already registered. An effect is
+// played as its fallback in versions that don't have it, or as the fallback of its fallback if that doesn't exist
+// either. Registering the fallback of an effect that already has one replaces it.
+func RegisterFallbacks(f Fallbacks) {
+	layoutMu.Lock()
+	defer layoutMu.Unlock()
+	for name, fallback := range f.Sounds {
+		fallbacks.Sounds[name] = fallback
+	}
+	for name, fallback := range f.LevelEvents {
+		fallbacks.LevelEvents[name] = fallback
+	}
+	for name, fallback := range f.Particles {
+		fallbacks.Particles[name] = fallback
+	}
+	for name, fallback := range f.Effects {
+		fallbacks.Effects[name] = fallback
+	}
+}
+
+// layoutsOf returns the layouts registered for the two versions passed. False is returned if either version has no
+// Layout registered.
+func layoutsOf(from, to state.Version) (layout, layout, bool) {
+	f, ok := layouts[from]
+	if !ok {
+		return layout{}, layout{}, false
+	}
+	t, ok := layouts[to]
+	return f, t, ok
+}
+
+// Sound returns the ID of the LevelSoundEvent sound with the name passed in the version passed. False is returned if
+// the version has no Layout registered or if its Layout doesn't hold the sound.
+func Sound(v state.Version, name string) (uint32, bool) {
+	layoutMu.RLock()
+	defer layoutMu.RUnlock()
+	id, ok := layouts[v].Sounds[name]
+	return id, ok
+}
+
+// TranslateSound translates the ID of a LevelSoundEvent sound of the version from to the ID of the sound played in the
+// version to. False is returned if the version to has neither the sound nor any of its fallbacks, or if the ID
+// passed is unknown to the version from but means another sound in the version to, in which case no sound should be
+// played. The ID is translated as it is if either version has no Layout registered.
+func TranslateSound(from, to state.Version, id uint32) (uint32, bool) {
+	if from == to {
+		return id, true
+	}
+	layoutMu.RLock()
+	defer layoutMu.RUnlock()
+	f, t, ok := layoutsOf(from, to)
+	if !ok {
+		return id, true
+	}
+	return translate(f.soundNames, t.soundNames, t.Sounds, fallbacks.Sounds, id)
+}
+
+// TranslateLevelEvent translates the type of a LevelEvent of the version from to the type of the event in the
+// version to, translating the particle type of legacy particle events. False is returned if the version to has neither
+// the event nor any of its fallbacks, or if the type passed is unknown to the version from but means another event in
+// the version to, in which case the event should not be sent. The type is translated as it is if either version has
+// no Layout registered.
+func TranslateLevelEvent(from, to state.Version, eventType int32) (int32, bool) {
+	if from == to {
+		return eventType, true
+	}
+	layoutMu.RLock()
+	defer layoutMu.RUnlock()
+	f, t, ok := layoutsOf(from, to)
+	if !ok {
+		return eventType, true
+	}
+	if eventType&particleLegacyEvent != 0 {
+		particle, ok := translate(f.particleNames, t.particleNames, t.Particles, fallbacks.Particles, eventType&^particleLegacyEvent)
+		return particle | particleLegacyEvent, ok
+	}
+	return translate(f.levelEventNames, t.levelEventNames, t.LevelEvents, fallbacks.LevelEvents, eventType)
+}
+
+// TranslateParticleEffect translates the identifier of a particle effect of the version from to the identifier of
+// the effect spawned in the version to. Effects that the version to has are translated as they are, and so are custom
+// effects defined by resource packs, which are absent from the Layout of the version from. Other effects are
+// translated to their fallback. False is returned if neither the effect nor any of its fallbacks exist in the version
+// to, in which case the effect should not be spawned.
+func TranslateParticleEffect(from, to state.Version, identifier string) (string, bool) {
+	if from == to {
+		return identifier, true
+	}
+	layoutMu.RLock()
+	defer layoutMu.RUnlock()
+	f, t, ok := layoutsOf(from, to)
+	if !ok {
+		return identifier, true
+	}
+	if _, ok := t.effects[identifier]; ok {
+		return identifier, true
+	}
+	if _, ok := f.effects[identifier]; !ok {
+		return identifier, true
+	}
+	return fallback.Resolve(identifier, fallbacks.Effects, func(name string) (string, bool) {
+		_, ok := t.effects[name]
+		return name, ok
+	})
+}
+
+// particleLegacyEvent is the bit set in the type of LevelEvents that show a particle, mirroring
+// packet.LevelEventParticleLegacyEvent.
+const particleLegacyEvent = 0x4000
+
+// translate translates the ID passed using the names of the IDs of the version translated from, the names and IDs of
+// the version translated to and the fallbacks passed.
+func translate[T uint32 | int32](fromNames, toNames map[T]string, to map[string]T, fallbacks map[string]string, id T) (T, bool) {
+	name, ok := fromNames[id]
+	if !ok {
+		// The ID is unknown, so it is assumed to be identical in both versions, unless it means something else in the
+		// version translated to.
+		_, taken := toNames[id]
+		return id, !taken
+	}
+	return fallback.Resolve(name, fallbacks, func(name string) (T, bool) {
+		translated, ok := to[name]
+		return translated, ok
+	})
+}
+
+// names returns a map of the IDs in the map passed to their names.
+func names[T uint32 | int32](m map[string]T) map[T]string {
+	r := make(map[T]string, len(m))
+	for name, id := range m {
+		r[id] = name
+	}
+	return r
+}
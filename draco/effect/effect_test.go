@@ -0,0 +1,58 @@
+package effect
+
+import "testing"
+
+func TestTranslate(t *testing.T) {
+	Register(-1, Layout{
+		Sounds:      map[string]uint32{"new_horn": 10, "horn": 11, "removed": 12},
+		LevelEvents: map[string]int32{"new_event": 20},
+		Particles:   map[string]int32{"new_particle": 5},
+		Effects:     []string{"minecraft:new_effect", "minecraft:removed_effect"},
+	})
+	Register(-2, Layout{
+		Sounds:      map[string]uint32{"horn": 10},
+		LevelEvents: map[string]int32{"old_event": 21},
+		Particles:   map[string]int32{"old_particle": 3},
+		Effects:     []string{"minecraft:old_effect"},
+	})
+	RegisterFallbacks(Fallbacks{
+		Sounds:      map[string]string{"new_horn": "horn"},
+		LevelEvents: map[string]string{"new_event": "old_event"},
+		Particles:   map[string]string{"new_particle": "old_particle"},
+		Effects:     map[string]string{"minecraft:new_effect": "minecraft:old_effect"},
+	})
+
+	for id, expected := range map[uint32]uint32{10: 10, 11: 10, 1: 1} {
+		if translated, ok := TranslateSound(-1, -2, id); !ok || translated != expected {
+			t.Errorf("sound %v translated to %v (found: %v), expected %v", id, translated, ok, expected)
+		}
+	}
+	if _, ok := TranslateSound(-1, -2, 12); ok {
+		t.Error("sound without equivalent was translated")
+	}
+	if _, ok := TranslateSound(-2, -1, 12); ok {
+		// Sound 12 is unknown to the version translated from, but means another sound in the version translated to.
+		t.Error("unknown sound taken by another sound was translated")
+	}
+	if translated, ok := TranslateLevelEvent(-1, -2, 20); !ok || translated != 21 {
+		t.Errorf("level event translated to %v (found: %v), expected 21", translated, ok)
+	}
+	if translated, ok := TranslateLevelEvent(-1, -2, particleLegacyEvent|5); !ok || translated != particleLegacyEvent|3 {
+		t.Errorf("particle event translated to %v (found: %v), expected %v", translated, ok, particleLegacyEvent|3)
+	}
+	for identifier, expected := range map[string]string{
+		"minecraft:new_effect": "minecraft:old_effect",
+		// Custom effects are defined by resource packs and exist in every version.
+		"custom:sparkles": "custom:sparkles",
+	} {
+		if translated, ok := TranslateParticleEffect(-1, -2, identifier); !ok || translated != expected {
+			t.Errorf("%v translated to %v (found: %v), expected %v", identifier, translated, ok, expected)
+		}
+	}
+	if _, ok := TranslateParticleEffect(-1, -2, "minecraft:removed_effect"); ok {
+		t.Error("effect without equivalent was translated")
+	}
+	if id, ok := TranslateSound(-1, -3, 11); !ok || id != 11 {
+		t.Error("sound translated to a version without layout was changed")
+	}
+}
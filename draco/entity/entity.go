@@ -0,0 +1,140 @@
+// Package entity holds the entity identifiers of every protocol version known to draco, such as "minecraft:zombie",
+// and translates them between versions. Entities added in a newer version are shown to clients of older versions as
+// a similar entity that existed before them, as older clients don't show entities they don't know.
+package entity
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/fallback"
+	"github.com/cqdetdev/draco/draco/state"
+)
+
+var (
+	// registryMu guards registries and fallbacks.
+	registryMu sync.RWMutex
+	// registries holds the entity identifiers registered using Register, keyed by their version.
+	registries = map[state.Version]map[string]struct{}{}
+	// fallbacks maps the identifiers of entities to the identifiers of similar entities that existed before them.
+	// Entities are translated to their fallback for versions that don't have them.
+	fallbacks = map[string]string{
+		"minecraft:frog":                          "minecraft:rabbit",
+		"minecraft:tadpole":                       "minecraft:cod",
+		"minecraft:warden":                        "minecraft:iron_golem",
+		"minecraft:allay":                         "minecraft:vex",
+		"minecraft:chest_boat":                    "minecraft:boat",
+		"minecraft:camel":                         "minecraft:horse",
+		"minecraft:sniffer":                       "minecraft:panda",
+		"minecraft:armadillo":                     "minecraft:rabbit",
+		"minecraft:breeze":                        "minecraft:blaze",
+		"minecraft:wind_charge_projectile":        "minecraft:snowball",
+		"minecraft:breeze_wind_charge_projectile": "minecraft:snowball",
+		"minecraft:bogged":                        "minecraft:skeleton",
+		"minecraft:creaking":                      "minecraft:zombie",
+	}
+)
+
+// Default is the identifier of the entity that entities without an equivalent or fallback are translated to. Armor
+// stands have no AI and accept any metadata, so clients show them whatever the entity they stand in for.
+const Default = "minecraft:armor_stand"
+
+// Register registers the identifiers of the entities of the version passed. Registering the entities of a version
+// that already has entities replaces them. Entities are generally registered in the init function of the package
+// holding them.
+func Register(v state.Version, identifiers []string) {
+	r := make(map[string]struct{}, len(identifiers))
+	for _, id := range identifiers {
+		r[id] = struct{}{}
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registries[v] = r
+}
+
+// RegisterFallbacks registers the fallbacks of entities, keyed by the identifier of the entity. An entity is
+// translated to its fallback for versions that don't have it, or to the fallback of its fallback if that doesn't
+// exist either. Registering the fallback of an entity that already has one replaces it.
+func RegisterFallbacks(f map[string]string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for id, fallback := range f {
+		fallbacks[id] = fallback
+	}
+}
+
+// Fallback returns the fallback registered for the entity with the identifier passed. False is returned if the
+// entity has no fallback.
+func Fallback(id string) (string, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	fallback, ok := fallbacks[id]
+	return fallback, ok
+}
+
+// Registered checks if the version passed has entities registered.
+func Registered(v state.Version) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := registries[v]
+	return ok
+}
+
+// Exists checks if the entity with the identifier passed exists in the version passed.
+func Exists(v state.Version, id string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := registries[v][id]
+	return ok
+}
+
+// Identifiers returns the identifiers of all entities of the version passed, sorted. It returns nil if the version
+// has no entities registered.
+func Identifiers(v state.Version) []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	r, ok := registries[v]
+	if !ok {
+		return nil
+	}
+	ids := make([]string, 0, len(r))
+	for id := range r {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Translate translates the identifier of an entity of the version from to the identifier that the entity is shown as
+// in the version to. Entities that exist in the version to are translated as they are, and so are custom entities
+// defined by behaviour packs, which are neither registered for the version from nor have a fallback. Other entities
+// are translated to their fallback, as registered using RegisterFallbacks. False is returned if either version has no
+// entities registered, or if neither the entity nor any of its fallbacks exist in the version to, in which case the
+// entity should be shown as Default.
+func Translate(from, to state.Version, id string) (string, bool) {
+	if from == to {
+		return id, true
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	src, ok := registries[from]
+	if !ok {
+		return "", false
+	}
+	dst, ok := registries[to]
+	if !ok {
+		return "", false
+	}
+	if _, ok := dst[id]; ok {
+		return id, true
+	}
+	_, vanilla := src[id]
+	if _, ok := fallbacks[id]; !vanilla && !ok {
+		// The entity is custom, so the client knows it from the identifiers the server sent.
+		return id, true
+	}
+	return fallback.Resolve(id, fallbacks, func(name string) (string, bool) {
+		_, ok := dst[name]
+		return name, ok
+	})
+}
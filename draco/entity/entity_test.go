@@ -0,0 +1,36 @@
+package entity
+
+import "testing"
+
+func TestTranslate(t *testing.T) {
+	Register(-1, []string{"minecraft:pig", "minecraft:new_mob", "minecraft:removed"})
+	Register(-2, []string{"minecraft:pig", "minecraft:old_mob"})
+	RegisterFallbacks(map[string]string{"minecraft:newer_mob": "minecraft:new_mob", "minecraft:new_mob": "minecraft:old_mob"})
+
+	for id, expected := range map[string]string{
+		"minecraft:pig": "minecraft:pig",
+		// Entities without an equivalent are translated to their fallback, or the fallback of their fallback.
+		"minecraft:new_mob":   "minecraft:old_mob",
+		"minecraft:newer_mob": "minecraft:old_mob",
+		// Custom entities are known to the client from the identifiers sent by the server.
+		"custom:dragon": "custom:dragon",
+	} {
+		if translated, ok := Translate(-1, -2, id); !ok || translated != expected {
+			t.Errorf("%v translated to %v (found: %v), expected %v", id, translated, ok, expected)
+		}
+	}
+	if _, ok := Translate(-1, -2, "minecraft:removed"); ok {
+		t.Error("entity without equivalent was translated")
+	}
+	if _, ok := Translate(-1, -3, "minecraft:pig"); ok {
+		t.Error("entity translated to a version without entities")
+	}
+	if ids := Identifiers(-2); len(ids) != 2 || ids[0] != "minecraft:old_mob" {
+		t.Errorf("unexpected identifiers %v", ids)
+	}
+
+	RegisterFallbacks(map[string]string{"minecraft:new_mob": "minecraft:newer_mob"})
+	if _, ok := Translate(-1, -2, "minecraft:new_mob"); ok {
+		t.Error("entity with looping fallbacks was translated")
+	}
+}
@@ -0,0 +1,21 @@
+// Package fallback resolves the fallbacks of the identifiers of blocks, biomes, entities and effects that don't exist
+// in the version translated to.
+package fallback
+
+// Resolve looks up the name passed using lookup, following the fallbacks passed until a name is found that lookup
+// knows. The amount of fallbacks followed is limited, so that fallbacks pointing to each other can't loop forever.
+// False is returned if neither the name nor any of its fallbacks are known.
+func Resolve[T any](name string, fallbacks map[string]string, lookup func(name string) (T, bool)) (T, bool) {
+	for i := 0; i <= len(fallbacks); i++ {
+		if v, ok := lookup(name); ok {
+			return v, true
+		}
+		next, ok := fallbacks[name]
+		if !ok {
+			break
+		}
+		name = next
+	}
+	var zero T
+	return zero, false
+}
@@ -0,0 +1,25 @@
+package fallback
+
+import "testing"
+
+func TestResolve(t *testing.T) {
+	known := map[string]int{"stone": 1, "dirt": 2}
+	lookup := func(name string) (int, bool) {
+		id, ok := known[name]
+		return id, ok
+	}
+	fallbacks := map[string]string{"deepslate": "tuff", "tuff": "stone", "a": "b", "b": "a"}
+
+	if id, ok := Resolve("dirt", fallbacks, lookup); !ok || id != 2 {
+		t.Errorf("expected a known name to resolve to itself, got %v (%v)", id, ok)
+	}
+	if id, ok := Resolve("deepslate", fallbacks, lookup); !ok || id != 1 {
+		t.Errorf("expected a chain of fallbacks to be followed, got %v (%v)", id, ok)
+	}
+	if _, ok := Resolve("missing", fallbacks, lookup); ok {
+		t.Error("expected a name without fallbacks to be unresolved")
+	}
+	if _, ok := Resolve("a", fallbacks, lookup); ok {
+		t.Error("expected fallbacks pointing to each other to be unresolved")
+	}
+}
@@ -0,0 +1,219 @@
+// Package forward attaches metadata known only to the proxy, such as the address of a player, to the login
+// request sent to a backend. The metadata is held by a claim signed with a secret shared with the backend, so that
+// backends that trust the proxy can read it from the login request instead of looking it up elsewhere.
+package forward
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// ClaimName is the name of the field in the JSON object of the login request that holds the signed claim. Clients
+// never send this field, and backends that don't know about it ignore it.
+const ClaimName = "draco"
+
+// claimTTL is the duration for which a claim is valid after the login request holding it was sent.
+const claimTTL = time.Minute
+
+// Metadata is the metadata attached to the login request of a player.
+type Metadata struct {
+	// Address is the address that the player connected to the proxy from.
+	Address string `json:"address"`
+	// Node is the ID of the proxy that the player is connected to.
+	Node string `json:"node,omitempty"`
+	// Joined is the time at which the player joined the proxy.
+	Joined time.Time `json:"joined"`
+	// Role is the role of the player on the proxy, such as "member" or "guest".
+	Role string `json:"role"`
+}
+
+// claims are the claims of the signed claim.
+type claims struct {
+	jwt.Claims
+	Metadata
+	// Key is the public key that the client data of the login request was signed with. It binds the claim to the
+	// login request, so that it can't be copied into another one.
+	Key string `json:"key"`
+}
+
+// Protocol is the minecraft.Protocol of a connection to a backend that attaches Metadata to the login request sent
+// over it. It should be set as the Protocol of the minecraft.Dialer used to connect a single player to a backend.
+// Apart from the login request, packets are written and read as in the latest protocol.
+type Protocol struct {
+	// Secret is the secret shared with the backend that the claim is signed with.
+	Secret []byte
+	// Metadata is the Metadata attached to the login request.
+	Metadata Metadata
+}
+
+// ID ...
+func (Protocol) ID() int32 {
+	return protocol.CurrentProtocol
+}
+
+// Ver ...
+func (Protocol) Ver() string {
+	return protocol.CurrentVersion
+}
+
+// Packets ...
+func (Protocol) Packets() packet.Pool {
+	return packet.NewPool()
+}
+
+// ConvertToLatest ...
+func (Protocol) ConvertToLatest(pk packet.Packet) packet.Packet {
+	return pk
+}
+
+// ConvertFromLatest attaches the Metadata of the Protocol to a packet.Login. If it fails to do so, the login request
+// is sent without it.
+func (p Protocol) ConvertFromLatest(pk packet.Packet) packet.Packet {
+	if login, ok := pk.(*packet.Login); ok {
+		if request, err := Attach(login.ConnectionRequest, p.Secret, p.Metadata); err == nil {
+			login.ConnectionRequest = request
+		}
+	}
+	return pk
+}
+
+// Attach returns a copy of the login request passed with the Metadata passed attached in a claim signed with the
+// secret passed.
+func Attach(request, secret []byte, m Metadata) ([]byte, error) {
+	chainData, rawToken, err := split(request)
+	if err != nil {
+		return nil, err
+	}
+	key, err := clientKey(rawToken)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: secret}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create signer: %w", err)
+	}
+	now := time.Now()
+	claim, err := jwt.Signed(signer).Claims(claims{
+		Claims: jwt.Claims{
+			IssuedAt: jwt.NewNumericDate(now),
+			Expiry:   jwt.NewNumericDate(now.Add(claimTTL)),
+		},
+		Metadata: m,
+		Key:      key,
+	}).CompactSerialize()
+	if err != nil {
+		return nil, fmt.Errorf("sign claim: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(chainData, &fields); err != nil {
+		return nil, fmt.Errorf("decode chain: %w", err)
+	}
+	fields[ClaimName], _ = json.Marshal(claim)
+	chainData, err = json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("encode chain: %w", err)
+	}
+	return join(chainData, rawToken), nil
+}
+
+// Parse returns the Metadata attached to the login request passed, as found in the ConnectionRequest field of a
+// packet.Login. It should be called by backends after the login request itself is verified. An error is returned
+// if the request holds no claim, or if the claim was not signed with the secret passed, has expired or was
+// attached to a different login request.
+func Parse(request, secret []byte) (Metadata, error) {
+	chainData, rawToken, err := split(request)
+	if err != nil {
+		return Metadata{}, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(chainData, &fields); err != nil {
+		return Metadata{}, fmt.Errorf("decode chain: %w", err)
+	}
+	var claim string
+	if err := json.Unmarshal(fields[ClaimName], &claim); err != nil || claim == "" {
+		return Metadata{}, errors.New("login request holds no claim")
+	}
+	tok, err := jwt.ParseSigned(claim)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("parse claim: %w", err)
+	}
+	if len(tok.Headers) != 1 || tok.Headers[0].Algorithm != string(jose.HS256) {
+		return Metadata{}, errors.New("claim must be signed using HS256")
+	}
+	var c claims
+	if err := tok.Claims(secret, &c); err != nil {
+		return Metadata{}, fmt.Errorf("verify claim: %w", err)
+	}
+	if err := c.Validate(jwt.Expected{Time: time.Now()}); err != nil {
+		return Metadata{}, fmt.Errorf("validate claim: %w", err)
+	}
+	key, err := clientKey(rawToken)
+	if err != nil {
+		return Metadata{}, err
+	}
+	if c.Key != key {
+		return Metadata{}, errors.New("claim was attached to a different login request")
+	}
+	return c.Metadata, nil
+}
+
+// clientKey returns the public key in the x5u header of the raw token passed, which holds the client data of a
+// login request.
+func clientKey(rawToken []byte) (string, error) {
+	tok, err := jwt.ParseSigned(string(rawToken))
+	if err != nil {
+		return "", fmt.Errorf("parse client data: %w", err)
+	}
+	if len(tok.Headers) != 1 {
+		return "", errors.New("client data must have exactly one header")
+	}
+	key, _ := tok.Headers[0].ExtraHeaders["x5u"].(string)
+	if key == "" {
+		return "", errors.New("client data has no x5u header")
+	}
+	return key, nil
+}
+
+// split splits a login request into the JSON object holding its chain and the raw token holding its client data.
+func split(request []byte) (chainData, rawToken []byte, err error) {
+	buf := bytes.NewBuffer(request)
+	if chainData, err = readField(buf); err != nil {
+		return nil, nil, fmt.Errorf("read chain: %w", err)
+	}
+	if rawToken, err = readField(buf); err != nil {
+		return nil, nil, fmt.Errorf("read client data: %w", err)
+	}
+	return chainData, rawToken, nil
+}
+
+// readField reads a byte slice prefixed with its length as a little endian int32 from the buffer passed.
+func readField(buf *bytes.Buffer) ([]byte, error) {
+	var length int32
+	if err := binary.Read(buf, binary.LittleEndian, &length); err != nil {
+		return nil, err
+	}
+	if length < 0 || int(length) > buf.Len() {
+		return nil, fmt.Errorf("invalid length %v", length)
+	}
+	return buf.Next(int(length)), nil
+}
+
+// join is the opposite of split.
+func join(chainData, rawToken []byte) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, len(chainData)+len(rawToken)+8))
+	_ = binary.Write(buf, binary.LittleEndian, int32(len(chainData)))
+	buf.Write(chainData)
+	_ = binary.Write(buf, binary.LittleEndian, int32(len(rawToken)))
+	buf.Write(rawToken)
+	return buf.Bytes()
+}
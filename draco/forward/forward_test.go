@@ -0,0 +1,147 @@
+package forward
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// testRequest returns a login request with an empty chain and client data signed by a new key.
+func testRequest(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := (&jose.SignerOptions{}).WithHeader("x5u", base64.StdEncoding.EncodeToString(data))
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES384, Key: key}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawToken, err := jwt.Signed(signer).Claims(map[string]any{"ThirdPartyName": "Steve"}).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return join([]byte(`{"chain":[]}`), []byte(rawToken))
+}
+
+// claimOf returns the claim attached to the login request passed.
+func claimOf(t *testing.T, request []byte) string {
+	chainData, _, err := split(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]string
+	_ = json.Unmarshal(chainData, &fields)
+	return fields[ClaimName]
+}
+
+// withClaim returns a copy of the login request passed with the claim passed attached.
+func withClaim(t *testing.T, request []byte, claim string) []byte {
+	chainData, rawToken, err := split(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(chainData, &fields); err != nil {
+		t.Fatal(err)
+	}
+	fields[ClaimName], _ = json.Marshal(claim)
+	chainData, _ = json.Marshal(fields)
+	return join(chainData, rawToken)
+}
+
+func TestAttachParse(t *testing.T) {
+	secret := []byte("secret")
+	request := testRequest(t)
+	m := Metadata{Address: "1.2.3.4:19132", Node: "eu-1", Joined: time.Now().Truncate(time.Second).UTC(), Role: "member"}
+	attached, err := Attach(request, secret, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(attached, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Joined.Equal(m.Joined) {
+		t.Errorf("expected joined %v, got %v", m.Joined, parsed.Joined)
+	}
+	parsed.Joined = m.Joined
+	if parsed != m {
+		t.Errorf("expected metadata %+v, got %+v", m, parsed)
+	}
+	if _, _, err := split(attached); err != nil {
+		t.Errorf("expected the login request to remain readable, got %v", err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	secret := []byte("secret")
+	request := testRequest(t)
+	attached, err := Attach(request, secret, Metadata{Address: "1.2.3.4:19132", Role: "member"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claim := claimOf(t, attached)
+
+	// The payload of the claim is replaced by one claiming another address, keeping the signature.
+	parts := strings.Split(claim, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(payload), "1.2.3.4", "5.6.7.8", 1)))
+	tampered := withClaim(t, attached, strings.Join(parts, "."))
+
+	sign := func(c claims) []byte {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: secret}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := jwt.Signed(signer).Claims(c).CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return withClaim(t, request, raw)
+	}
+	_, rawToken, _ := split(request)
+	key, err := clientKey(rawToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := sign(claims{Claims: jwt.Claims{Expiry: jwt.NewNumericDate(time.Now().Add(-time.Minute))}, Key: key})
+
+	// The claim attached to one login request is copied into another one, signed by another client.
+	copied := withClaim(t, testRequest(t), claim)
+
+	tests := []struct {
+		name    string
+		request []byte
+		secret  []byte
+		err     string
+	}{
+		{"tampered payload", tampered, secret, "verify claim"},
+		{"wrong secret", attached, []byte("other secret"), "verify claim"},
+		{"expired claim", expired, secret, "validate claim"},
+		{"missing claim", request, secret, "holds no claim"},
+		{"other client key", copied, secret, "different login request"},
+		{"malformed request", []byte{1, 2}, secret, "read chain"},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.request, test.secret)
+		if err == nil {
+			t.Errorf("%v: expected an error containing %q, got none", test.name, test.err)
+		} else if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: expected an error containing %q, got %v", test.name, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Package gdpr exports and purges all data that the proxy stores about a player, such as its bans, its last known
+// position, the packets captured of it and the lines logged about it, so that networks can answer the requests of
+// data subjects under the GDPR. Every part of the proxy storing data about players registers a Store, and the data
+// of all stores is exported as a single Bundle, encoded as JSON.
+package gdpr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/proxy"
+)
+
+// Store holds data about players. Players are identified by their XUID.
+type Store struct {
+	// Export returns the data stored about the player with the XUID passed, which must be encodable as JSON. It
+	// returns nil if no data is stored about the player.
+	Export func(xuid string) (any, error)
+	// Purge removes all data stored about the player with the XUID passed and returns the amount of records removed.
+	Purge func(xuid string) (int, error)
+}
+
+var (
+	// mu guards stores.
+	mu sync.RWMutex
+	// stores holds the stores registered using Register, keyed by their name.
+	stores = map[string]Store{}
+)
+
+// Register registers a Store under the name passed, such as "bans", replacing the Store registered under the same
+// name before.
+func Register(name string, s Store) {
+	mu.Lock()
+	defer mu.Unlock()
+	stores[name] = s
+}
+
+// Unregister unregisters the Store registered under the name passed, such as once the Store was closed.
+func Unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(stores, name)
+}
+
+// registered returns the names of all stores registered, sorted, and the stores themselves.
+func registered() ([]string, map[string]Store) {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(stores))
+	all := make(map[string]Store, len(stores))
+	for name, s := range stores {
+		names = append(names, name)
+		all[name] = s
+	}
+	sort.Strings(names)
+	return names, all
+}
+
+// errNoXUID is returned by Export and Purge if the XUID passed is empty, which would otherwise match the data of
+// guests, or all lines of a log file.
+var errNoXUID = errors.New("no xuid")
+
+// Bundle holds all data stored about a player.
+type Bundle struct {
+	// XUID is the XBOX Live user ID of the player.
+	XUID string `json:"xuid"`
+	// Exported is the time at which the Bundle was exported.
+	Exported time.Time `json:"exported"`
+	// Data holds the data of every Store that holds data about the player, keyed by the name of the Store. Stores
+	// without any data about the player are left out.
+	Data map[string]any `json:"data"`
+}
+
+// Export exports the data that all stores registered hold about the player with the XUID passed. An error is
+// returned if any of the stores fails to export its data, as a partial Bundle would be incomplete.
+func Export(xuid string) (Bundle, error) {
+	if xuid == "" {
+		return Bundle{}, errNoXUID
+	}
+	b := Bundle{XUID: xuid, Exported: time.Now(), Data: map[string]any{}}
+	names, all := registered()
+	for _, name := range names {
+		data, err := all[name].Export(xuid)
+		if err != nil {
+			return Bundle{}, fmt.Errorf("export %v: %w", name, err)
+		}
+		if data != nil {
+			b.Data[name] = data
+		}
+	}
+	return b, nil
+}
+
+// Purge removes the data that all stores registered hold about the player with the XUID passed and returns the amount
+// of records removed by every Store, keyed by its name. Stores are purged even if another Store failed to be purged,
+// after which the first error encountered is returned.
+func Purge(xuid string) (map[string]int, error) {
+	if xuid == "" {
+		return nil, errNoXUID
+	}
+	removed := map[string]int{}
+	var err error
+	names, all := registered()
+	for _, name := range names {
+		n, e := all[name].Purge(xuid)
+		if e != nil && err == nil {
+			err = fmt.Errorf("purge %v: %w", name, e)
+		}
+		removed[name] = n
+	}
+	return removed, err
+}
+
+// Handler returns an http.Handler serving the data of players. It supports the following requests:
+//
+//	GET    /?xuid=<xuid>   responds with the Bundle of the player as a JSON attachment
+//	DELETE /?xuid=<xuid>   purges all data of the player, responding with the amount of records removed per store
+//
+// Players online can't be purged, as data about them is recorded again while they play, so they must be disconnected
+// first.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		xuid := r.URL.Query().Get("xuid")
+		if xuid == "" {
+			http.Error(w, "no xuid", http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			b, err := Export(xuid)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", xuid+".json"))
+			_ = json.NewEncoder(w).Encode(b)
+		case http.MethodDelete:
+			if _, ok := proxy.SessionByXUID(xuid); ok {
+				http.Error(w, "player online", http.StatusConflict)
+				return
+			}
+			removed, err := Purge(xuid)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// The XUID is deliberately left out, so that the log doesn't hold data about the player once purged.
+			logging.Default().Info("purged data of player", "records", removed)
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(removed)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
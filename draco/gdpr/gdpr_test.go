@@ -0,0 +1,74 @@
+package gdpr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	data := map[string][]string{"1": {"banned"}, "2": {"muted"}}
+	Register("test", Store{
+		Export: func(xuid string) (any, error) {
+			if d, ok := data[xuid]; ok {
+				return d, nil
+			}
+			return nil, nil
+		},
+		Purge: func(xuid string) (int, error) {
+			n := len(data[xuid])
+			delete(data, xuid)
+			return n, nil
+		},
+	})
+	defer Unregister("test")
+
+	h := Handler()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/gdpr?xuid=1", nil))
+	var b Bundle
+	if err := json.NewDecoder(rec.Body).Decode(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.XUID != "1" || len(b.Data) != 1 {
+		t.Fatalf("unexpected bundle %+v", b)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/gdpr?xuid=1", nil))
+	var removed map[string]int
+	if err := json.NewDecoder(rec.Body).Decode(&removed); err != nil {
+		t.Fatal(err)
+	}
+	if removed["test"] != 1 {
+		t.Errorf("expected 1 record to be removed, got %v", removed)
+	}
+	if b, _ := Export("1"); len(b.Data) != 0 {
+		t.Errorf("expected no data after purging, got %+v", b.Data)
+	}
+	if _, ok := data["2"]; !ok {
+		t.Error("data of another player was purged")
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/gdpr", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected purging without XUID to fail, got status %v", rec.Code)
+	}
+}
+
+func TestExportError(t *testing.T) {
+	Register("failing", Store{
+		Export: func(string) (any, error) { return nil, errors.New("unavailable") },
+		Purge:  func(string) (int, error) { return 0, errors.New("unavailable") },
+	})
+	defer Unregister("failing")
+	if _, err := Export("1"); err == nil {
+		t.Error("expected an export with a failing store to fail")
+	}
+	if _, err := Purge("1"); err == nil {
+		t.Error("expected a purge with a failing store to fail")
+	}
+}
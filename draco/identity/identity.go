@@ -0,0 +1,239 @@
+package identity
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// mojangPublicKey is the public key of Mojang, which signs all but the first token of an XBOX Live authenticated
+// login chain. It is a variable so that tests may sign chains with a key of their own.
+var mojangPublicKey = `MHYwEAYHKoZIzj0CAQYFK4EEACIDYgAE8ELkixyLcwlZryUQcu1TvPOmI2B7vX83ndnWRUaXm74wFfa5f/lwQNTfrLVHa2PmenpGI6JhIMUJaWZrjmMj90NoKNFSNBuKdm8rYiXsfaz3K36x/1U26HpG0ZxK/V1V`
+
+// resultTTL is the duration after which the result of verifying the login of a client that never finished
+// joining is forgotten.
+const resultTTL = time.Minute
+
+// Config is the configuration of a Verifier.
+type Config struct {
+	// Strict, if true, requires every login chain to be signed by Mojang and to hold an XUID and title ID. If
+	// false, the chain is only required to be consistent and unexpired, and an XUID must only be present if it
+	// claims to be signed by Mojang.
+	Strict bool
+	// ClockSkew is the difference in time between the clocks of clients and the proxy that is tolerated when
+	// checking if a token is expired or not yet valid.
+	ClockSkew time.Duration
+}
+
+// Verifier verifies the login identity chain of clients joining the proxy, independently of the verification
+// done by gophertunnel. The Packet method of a Verifier must be set as the PacketFunc of the minecraft.Listener
+// that players join through, after which Check may be used to check if the client at an address passed
+// verification.
+// Verifier is safe for concurrent use.
+type Verifier struct {
+	conf Config
+
+	mu      sync.Mutex
+	results map[string]result
+}
+
+// result is the result of verifying the login of a client.
+type result struct {
+	err  error
+	time time.Time
+}
+
+// New returns a Verifier using the Config passed.
+func New(conf Config) *Verifier {
+	return &Verifier{conf: conf, results: map[string]result{}}
+}
+
+// Packet inspects a packet read by a minecraft.Listener, verifying the login chain of the client if it is a Login
+// packet. Packet has the signature of minecraft.ListenConfig.PacketFunc.
+func (v *Verifier) Packet(header packet.Header, payload []byte, src, _ net.Addr) {
+	if header.PacketID != packet.IDLogin {
+		return
+	}
+	pk := &packet.Login{}
+	err := func() (err error) {
+		defer func() {
+			if recover() != nil {
+				err = errors.New("malformed login packet")
+			}
+		}()
+		pk.Unmarshal(protocol.NewReader(bytes.NewReader(payload), 0))
+		return v.verify(pk.ConnectionRequest, time.Now())
+	}()
+
+	now := time.Now()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for addr, r := range v.results {
+		if now.Sub(r.time) > resultTTL {
+			delete(v.results, addr)
+		}
+	}
+	v.results[src.String()] = result{err: err, time: now}
+}
+
+// Check returns an error if the login chain of the client at the address passed failed verification, or if no Login
+// packet was seen from it. Check should be called once for every connection accepted, as the result is forgotten
+// after the call.
+func (v *Verifier) Check(addr net.Addr) error {
+	v.mu.Lock()
+	r, ok := v.results[addr.String()]
+	delete(v.results, addr.String())
+	v.mu.Unlock()
+
+	if !ok {
+		r.err = errors.New("no login request seen")
+	}
+	if r.err != nil {
+		metrics.Add("identity_rejected", 1)
+		logging.Default().Info("identity verification failed", "address", addr.String(), "err", r.err)
+		return fmt.Errorf("verify identity: %w", r.err)
+	}
+	metrics.Add("identity_verified", 1)
+	return nil
+}
+
+// claims holds the claims of a token in the login chain that are verified.
+type claims struct {
+	jwt.Claims
+	IdentityPublicKey string `json:"identityPublicKey"`
+	ExtraData         struct {
+		XUID        string `json:"XUID"`
+		Identity    string `json:"identity"`
+		DisplayName string `json:"displayName"`
+		TitleID     string `json:"titleId"`
+	} `json:"extraData"`
+}
+
+// verify verifies the connection request of a Login packet at the time passed.
+func (v *Verifier) verify(request []byte, t time.Time) error {
+	chain, err := decodeChain(request)
+	if err != nil {
+		return err
+	}
+	if len(chain) != 1 && len(chain) != 3 {
+		return fmt.Errorf("unexpected login chain length %v", len(chain))
+	}
+	if v.conf.Strict && len(chain) != 3 {
+		return errors.New("login chain is not signed by mojang")
+	}
+
+	tok, err := jwt.ParseSigned(chain[0])
+	if err != nil {
+		return fmt.Errorf("parse token 0: %w", err)
+	}
+	if len(tok.Headers) == 0 {
+		return errors.New("token 0 has no headers")
+	}
+	// The first token is self-signed: It is signed with the key held in its own x5u header.
+	key, err := parseKey(tok.Headers[0].ExtraHeaders["x5u"])
+	if err != nil {
+		return fmt.Errorf("parse x5u of token 0: %w", err)
+	}
+
+	var last claims
+	mojangSigned := false
+	for i, raw := range chain {
+		tok, err := jwt.ParseSigned(raw)
+		if err != nil {
+			return fmt.Errorf("parse token %v: %w", i, err)
+		}
+		var c claims
+		if err := tok.Claims(key, &c); err != nil {
+			return fmt.Errorf("verify token %v: %w", i, err)
+		}
+		expected := jwt.Expected{Time: t}
+		if len(chain) == 3 && i > 0 {
+			expected.Issuer = "Mojang"
+		}
+		if err := c.ValidateWithLeeway(expected, v.conf.ClockSkew); err != nil {
+			return fmt.Errorf("validate token %v: %w", i, err)
+		}
+		if i == 0 && len(chain) == 3 {
+			// The first token of an authenticated chain hands over to the key of Mojang, which signs the rest.
+			mojangSigned = c.IdentityPublicKey == mojangPublicKey
+			if v.conf.Strict && !mojangSigned {
+				return errors.New("login chain is not signed by mojang")
+			}
+		}
+		if c.IdentityPublicKey != "" {
+			if key, err = parseKey(c.IdentityPublicKey); err != nil {
+				return fmt.Errorf("parse identity public key of token %v: %w", i, err)
+			}
+		}
+		last = c
+	}
+
+	data := last.ExtraData
+	if data.DisplayName == "" || data.Identity == "" {
+		return errors.New("identity data has no display name or identity")
+	}
+	if mojangSigned {
+		if data.XUID == "" || data.TitleID == "" {
+			return errors.New("identity data signed by mojang has no XUID or title ID")
+		}
+		for _, r := range data.XUID {
+			if r < '0' || r > '9' {
+				return fmt.Errorf("identity data has invalid XUID %q", data.XUID)
+			}
+		}
+	} else if data.XUID != "" {
+		return errors.New("identity data not signed by mojang has an XUID")
+	}
+	return nil
+}
+
+// decodeChain decodes the tokens of the login chain in the connection request passed.
+func decodeChain(request []byte) ([]string, error) {
+	buf := bytes.NewBuffer(request)
+	var length int32
+	if err := binary.Read(buf, binary.LittleEndian, &length); err != nil {
+		return nil, fmt.Errorf("read chain length: %w", err)
+	}
+	if length < 0 || int(length) > buf.Len() {
+		return nil, fmt.Errorf("invalid chain length %v", length)
+	}
+	var req struct {
+		Chain []string `json:"chain"`
+	}
+	if err := json.Unmarshal(buf.Next(int(length)), &req); err != nil {
+		return nil, fmt.Errorf("decode chain: %w", err)
+	}
+	return req.Chain, nil
+}
+
+// parseKey parses a base64 encoded ECDSA public key, as found in the x5u header and the identityPublicKey claim.
+func parseKey(v any) (*ecdsa.PublicKey, error) {
+	s, _ := v.(string)
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode key: %w", err)
+	}
+	k, err := x509.ParsePKIXPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse key: %w", err)
+	}
+	key, ok := k.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("expected ECDSA key, got %T", k)
+	}
+	return key, nil
+}
@@ -0,0 +1,183 @@
+package identity
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// newKey generates an ECDSA key of the kind used in login chains and returns it with its public key encoded like
+// in the x5u header and identityPublicKey claim.
+func newKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, base64.StdEncoding.EncodeToString(data)
+}
+
+// sign returns a token holding the claims passed, signed with the key passed, which is also set as x5u header.
+func sign(t *testing.T, key *ecdsa.PrivateKey, c claims) string {
+	data, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := (&jose.SignerOptions{}).WithHeader("x5u", base64.StdEncoding.EncodeToString(data))
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES384, Key: key}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := jwt.Signed(signer).Claims(c).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// request encodes a connection request holding the chain passed.
+func request(chain ...string) []byte {
+	data, _ := json.Marshal(map[string]any{"chain": chain})
+	buf := bytes.NewBuffer(nil)
+	_ = binary.Write(buf, binary.LittleEndian, int32(len(data)))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// chainKeys holds the keys used to sign an XBOX Live authenticated login chain.
+type chainKeys struct {
+	client, mojang, xbox          *ecdsa.PrivateKey
+	clientPub, mojangPub, xboxPub string
+}
+
+// authenticatedChain returns the tokens of a login chain signed like one authenticated by XBOX Live, valid at the time
+// passed. The modify function is called with the claims of every token before it is signed.
+func authenticatedChain(t *testing.T, k chainKeys, now time.Time, modify func(i int, c *claims)) []string {
+	validity := jwt.Claims{NotBefore: jwt.NewNumericDate(now.Add(-time.Hour)), Expiry: jwt.NewNumericDate(now.Add(time.Hour))}
+
+	first := claims{Claims: validity, IdentityPublicKey: k.mojangPub}
+	second := claims{Claims: validity, IdentityPublicKey: k.xboxPub}
+	second.Issuer = "Mojang"
+	third := claims{Claims: validity, IdentityPublicKey: k.clientPub}
+	third.Issuer = "Mojang"
+	third.ExtraData.XUID, third.ExtraData.Identity = "2535400000000000", "c0ffee00-0000-0000-0000-000000000000"
+	third.ExtraData.DisplayName, third.ExtraData.TitleID = "Steve", "896928775"
+
+	all := []*claims{&first, &second, &third}
+	signers := []*ecdsa.PrivateKey{k.client, k.mojang, k.xbox}
+	chain := make([]string, len(all))
+	for i, c := range all {
+		if modify != nil {
+			modify(i, c)
+		}
+		chain[i] = sign(t, signers[i], *c)
+	}
+	return chain
+}
+
+// offlineChain returns the token of a login chain that is not authenticated, valid at the time passed.
+func offlineChain(t *testing.T, key *ecdsa.PrivateKey, pub string, now time.Time) string {
+	c := claims{Claims: jwt.Claims{NotBefore: jwt.NewNumericDate(now.Add(-time.Hour)), Expiry: jwt.NewNumericDate(now.Add(time.Hour))}, IdentityPublicKey: pub}
+	c.ExtraData.Identity, c.ExtraData.DisplayName = "c0ffee00-0000-0000-0000-000000000000", "Steve"
+	return sign(t, key, c)
+}
+
+func TestVerify(t *testing.T) {
+	var k chainKeys
+	k.client, k.clientPub = newKey(t)
+	k.mojang, k.mojangPub = newKey(t)
+	k.xbox, k.xboxPub = newKey(t)
+	other, otherPub := newKey(t)
+
+	previous := mojangPublicKey
+	mojangPublicKey = k.mojangPub
+	defer func() {
+		mojangPublicKey = previous
+	}()
+
+	now := time.Now()
+	valid := authenticatedChain(t, k, now, nil)
+
+	forged := authenticatedChain(t, k, now, nil)
+	// The last token is replaced by one holding another XUID, signed by a key other than the one the chain hands over
+	// to.
+	forged[2] = authenticatedChain(t, chainKeys{client: k.client, mojang: k.mojang, xbox: other, clientPub: k.clientPub, mojangPub: k.mojangPub, xboxPub: otherPub}, now, func(i int, c *claims) {
+		c.ExtraData.XUID = "2535411111111111"
+	})[2]
+
+	expired := authenticatedChain(t, k, now, func(i int, c *claims) {
+		if i == 2 {
+			c.Expiry = jwt.NewNumericDate(now.Add(-time.Minute))
+		}
+	})
+
+	// The chain is consistently signed, but hands over to a key other than that of Mojang.
+	selfSigned := authenticatedChain(t, chainKeys{client: k.client, mojang: other, xbox: k.xbox, clientPub: k.clientPub, mojangPub: otherPub, xboxPub: k.xboxPub}, now, nil)
+
+	offline := offlineChain(t, k.client, k.clientPub, now)
+	offlineWithXUID := func() string {
+		c := claims{Claims: jwt.Claims{Expiry: jwt.NewNumericDate(now.Add(time.Hour))}, IdentityPublicKey: k.clientPub}
+		c.ExtraData.XUID, c.ExtraData.Identity, c.ExtraData.DisplayName = "2535400000000000", "c0ffee00-0000-0000-0000-000000000000", "Steve"
+		return sign(t, k.client, c)
+	}()
+
+	tests := []struct {
+		name    string
+		conf    Config
+		request []byte
+		// err is a part of the error expected, or empty if the request is expected to be valid.
+		err string
+	}{
+		{"valid chain", Config{}, request(valid...), ""},
+		{"valid chain strict", Config{Strict: true}, request(valid...), ""},
+		{"forged signature", Config{}, request(forged...), "verify token 2"},
+		{"expired chain", Config{}, request(expired...), "validate token 2"},
+		{"expired chain within clock skew", Config{ClockSkew: 2 * time.Minute}, request(expired...), ""},
+		{"not rooted in mojang key", Config{}, request(selfSigned...), "not signed by mojang has an XUID"},
+		{"not rooted in mojang key strict", Config{Strict: true}, request(selfSigned...), "not signed by mojang"},
+		{"offline chain", Config{}, request(offline), ""},
+		{"offline chain strict", Config{Strict: true}, request(offline), "not signed by mojang"},
+		{"offline chain with XUID", Config{}, request(offlineWithXUID), "not signed by mojang has an XUID"},
+		{"unexpected chain length", Config{}, request(valid[:2]...), "unexpected login chain length"},
+		{"invalid chain length", Config{}, []byte{0xff, 0xff, 0, 0, '{', '}'}, "invalid chain length"},
+		{"malformed chain", Config{}, append([]byte{2, 0, 0, 0}, "{["...), "decode chain"},
+		{"malformed token", Config{}, request("not.a.token"), "parse token 0"},
+	}
+	for _, test := range tests {
+		err := New(test.conf).verify(test.request, now)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%v: expected no error, got %v", test.name, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%v: expected an error containing %q, got none", test.name, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%v: expected an error containing %q, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	_, pub := newKey(t)
+	if _, err := parseKey(pub); err != nil {
+		t.Errorf("expected a valid key to be parsed, got %v", err)
+	}
+	for _, invalid := range []any{nil, 5, "not base64!", base64.StdEncoding.EncodeToString([]byte("not a key"))} {
+		if _, err := parseKey(invalid); err == nil {
+			t.Errorf("expected an error parsing %v", invalid)
+		}
+	}
+}
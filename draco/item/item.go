@@ -0,0 +1,216 @@
+// Package item translates item runtime IDs, also known as network IDs, between the item palettes of different
+// protocol versions. Palettes are registered per version by the packages holding them, and items are translated by
+// their name, like block states are translated by the state package.
+package item
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/policy"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/cqdetdev/draco/draco/telemetry"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Palette holds all items of a single version, which is the list of items that the StartGame packet requires the
+// client to know.
+type Palette struct {
+	ids   map[string]int32
+	names map[int32]string
+	// aliases maps from the names of items in other versions to the names of the same items in the Palette.
+	aliases map[string]string
+}
+
+// NewPalette creates a Palette holding the items passed, keyed by their name. aliases maps from the names that items
+// have in other versions to their names in the Palette, so that renamed items are translated too. aliases may be nil.
+func NewPalette(items map[string]int32, aliases map[string]string) *Palette {
+	p := &Palette{ids: make(map[string]int32, len(items)), names: make(map[int32]string, len(items)), aliases: aliases}
+	for name, rid := range items {
+		p.ids[name] = rid
+		p.names[rid] = name
+	}
+	return p
+}
+
+// RuntimeID looks up the runtime ID of the item with the name passed, which may be the name of the item in another
+// version.
+func (p *Palette) RuntimeID(name string) (int32, bool) {
+	if alias, ok := p.aliases[name]; ok {
+		name = alias
+	}
+	rid, ok := p.ids[name]
+	return rid, ok
+}
+
+// Name looks up the name of the item with the runtime ID passed.
+func (p *Palette) Name(rid int32) (string, bool) {
+	name, ok := p.names[rid]
+	return name, ok
+}
+
+// Entries returns all items of the Palette as the item entries sent in the StartGame packet, ordered by runtime ID.
+func (p *Palette) Entries() []protocol.ItemEntry {
+	entries := make([]protocol.ItemEntry, 0, len(p.ids))
+	for name, rid := range p.ids {
+		entries = append(entries, protocol.ItemEntry{Name: name, RuntimeID: int16(rid)})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].RuntimeID < entries[j].RuntimeID
+	})
+	return entries
+}
+
+var (
+	// paletteMu guards palettes.
+	paletteMu sync.RWMutex
+	// palettes holds all palettes registered using RegisterPalette, keyed by their version.
+	palettes = map[state.Version]*Palette{}
+)
+
+// RegisterPalette registers the Palette of the version passed. Registering a Palette for a version that already has
+// one replaces it. Palettes are generally registered in the init function of the package holding them.
+func RegisterPalette(v state.Version, p *Palette) {
+	paletteMu.Lock()
+	defer paletteMu.Unlock()
+	palettes[v] = p
+}
+
+// PaletteOf returns the Palette registered for the version passed. False is returned if none is registered.
+func PaletteOf(v state.Version) (*Palette, bool) {
+	paletteMu.RLock()
+	defer paletteMu.RUnlock()
+	p, ok := palettes[v]
+	return p, ok
+}
+
+// TranslateRuntimeID translates an item runtime ID of the version from to the runtime ID of the same item in the
+// version to. False is returned if either version has no Palette registered, or if the item doesn't exist in the
+// version to.
+func TranslateRuntimeID(from, to state.Version, rid int32) (int32, bool) {
+	if from == to {
+		return rid, true
+	}
+	f, ok := PaletteOf(from)
+	if !ok {
+		return 0, false
+	}
+	t, ok := PaletteOf(to)
+	if !ok {
+		return 0, false
+	}
+	name, ok := f.Name(rid)
+	if !ok {
+		return 0, false
+	}
+	return t.RuntimeID(name)
+}
+
+// TranslateName translates the name of an item of the version from, such as "minecraft:stick", to the name of the
+// same item in the version to, which differs for items that were renamed. False is returned if either version has no
+// Palette registered, or if the item doesn't exist in either version.
+func TranslateName(from, to state.Version, name string) (string, bool) {
+	f, ok := PaletteOf(from)
+	if !ok {
+		return "", false
+	}
+	t, ok := PaletteOf(to)
+	if !ok {
+		return "", false
+	}
+	rid, ok := f.RuntimeID(name)
+	if !ok {
+		return "", false
+	}
+	if rid, ok = TranslateRuntimeID(from, to, rid); !ok {
+		return "", false
+	}
+	return t.Name(rid)
+}
+
+// TranslateStack translates the item and the block runtime ID of an item stack of the version from to the version
+// to, translating the block runtime ID using the palettes of the state.Registry passed. False is returned if either
+// of them can't be translated.
+func TranslateStack(blocks *state.Registry, from, to state.Version, st protocol.ItemStack) (protocol.ItemStack, bool) {
+	if st.BlockRuntimeID > 0 {
+		rid, ok := blocks.TranslateRuntimeID(from, to, uint32(st.BlockRuntimeID))
+		if !ok {
+			return st, false
+		}
+		st.BlockRuntimeID = int32(rid)
+	}
+	if st.HasNetworkID {
+		rid, ok := TranslateRuntimeID(from, to, st.NetworkID)
+		if !ok {
+			return st, false
+		}
+		st.NetworkID = rid
+	}
+	return st, true
+}
+
+// recordGap records the part of the item stack passed that TranslateStack couldn't translate from the version from to
+// the version to in the telemetry: its block runtime ID, or otherwise its item, identified by name if the version
+// from has it.
+func recordGap(blocks *state.Registry, from, to state.Version, st protocol.ItemStack) {
+	if st.BlockRuntimeID > 0 {
+		if _, ok := blocks.TranslateRuntimeID(from, to, uint32(st.BlockRuntimeID)); !ok {
+			telemetry.RecordBlock(from, to, uint32(st.BlockRuntimeID))
+			return
+		}
+	}
+	id := strconv.Itoa(int(st.NetworkID))
+	if p, ok := PaletteOf(from); ok {
+		if name, ok := p.Name(st.NetworkID); ok {
+			id = name
+		}
+	}
+	telemetry.Record(telemetry.KindItem, from, to, id)
+}
+
+// Translate translates all item stacks in the packet passed from the version from to the version to. The packets
+// translated are InventoryContent, InventorySlot, MobEquipment, CraftingData and CreativeContent. Other packets are
+// left unchanged. Under the strict and drop decode policies, an error is returned if any of the items can't be
+// translated, in which case the packet may be translated partially. Under the lenient policy, such items are replaced
+// with air, and recipes holding them are left out. Block runtime IDs of items are translated using the palettes of the
+// state.Registry passed.
+func Translate(blocks *state.Registry, from, to state.Version, pk packet.Packet) error {
+	strict := !policy.Current().Substitutes()
+	stack := func(st *protocol.ItemStack) error {
+		translated, ok := TranslateStack(blocks, from, to, *st)
+		if !ok {
+			recordGap(blocks, from, to, *st)
+			if !strict {
+				*st = protocol.ItemStack{}
+				return nil
+			}
+			return fmt.Errorf("translate item %v (block runtime ID %v) from version %v to %v: no such item", st.NetworkID, st.BlockRuntimeID, from, to)
+		}
+		*st = translated
+		return nil
+	}
+	switch pk := pk.(type) {
+	case *packet.InventoryContent:
+		for i := range pk.Content {
+			if err := stack(&pk.Content[i].Stack); err != nil {
+				return err
+			}
+		}
+	case *packet.InventorySlot:
+		return stack(&pk.NewItem.Stack)
+	case *packet.MobEquipment:
+		return stack(&pk.NewItem.Stack)
+	case *packet.CreativeContent:
+		for i := range pk.Items {
+			if err := stack(&pk.Items[i].Item); err != nil {
+				return err
+			}
+		}
+	case *packet.CraftingData:
+		return translateCraftingData(blocks, from, to, pk, strict)
+	}
+	return nil
+}
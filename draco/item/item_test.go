@@ -0,0 +1,83 @@
+package item
+
+import (
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/policy"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestTranslate(t *testing.T) {
+	RegisterPalette(-1, NewPalette(map[string]int32{"minecraft:stick": 1, "minecraft:old_name": 2}, nil))
+	RegisterPalette(-2, NewPalette(map[string]int32{"minecraft:stick": 5, "minecraft:new_name": 6}, map[string]string{"minecraft:old_name": "minecraft:new_name"}))
+
+	pk := &packet.InventoryContent{Content: []protocol.ItemInstance{
+		{Stack: protocol.ItemStack{ItemType: protocol.ItemType{NetworkID: 1}, HasNetworkID: true}},
+		{Stack: protocol.ItemStack{ItemType: protocol.ItemType{NetworkID: 2}, HasNetworkID: true}},
+		{},
+	}}
+	if err := Translate(state.Default(), -1, -2, pk); err != nil {
+		t.Fatal(err)
+	}
+	if rid := pk.Content[0].Stack.NetworkID; rid != 5 {
+		t.Errorf("stick translated to %v, expected 5", rid)
+	}
+	if rid := pk.Content[1].Stack.NetworkID; rid != 6 {
+		t.Errorf("renamed item translated to %v, expected 6", rid)
+	}
+
+	defer policy.Set(policy.Current())
+	policy.Set(policy.Strict)
+	slot := &packet.InventorySlot{NewItem: protocol.ItemInstance{Stack: protocol.ItemStack{ItemType: protocol.ItemType{NetworkID: 6}, HasNetworkID: true}}}
+	if err := Translate(state.Default(), -2, -1, slot); err == nil {
+		t.Error("expected error translating an item that doesn't exist in the other version")
+	}
+	policy.Set(policy.Lenient)
+	slot = &packet.InventorySlot{NewItem: protocol.ItemInstance{Stack: protocol.ItemStack{ItemType: protocol.ItemType{NetworkID: 6}, HasNetworkID: true}}}
+	if err := Translate(state.Default(), -2, -1, slot); err != nil {
+		t.Fatal(err)
+	}
+	if slot.NewItem.Stack.HasNetworkID {
+		t.Errorf("item that doesn't exist in the other version translated to %v, expected air", slot.NewItem.Stack.NetworkID)
+	}
+}
+
+func TestTranslateCraftingData(t *testing.T) {
+	RegisterPalette(-3, NewPalette(map[string]int32{"minecraft:stick": 1, "minecraft:planks": 2, "minecraft:removed": 3}, nil))
+	RegisterPalette(-4, NewPalette(map[string]int32{"minecraft:stick": 5, "minecraft:planks": 6}, nil))
+
+	pk := &packet.CraftingData{
+		Recipes: []protocol.Recipe{
+			&protocol.ShapedRecipe{
+				Input:           []protocol.RecipeIngredientItem{{NetworkID: 2, Count: 1}, {}, {NetworkID: 2, Count: 1}},
+				Output:          []protocol.ItemStack{{ItemType: protocol.ItemType{NetworkID: 1}, Count: 4}},
+				RecipeNetworkID: 7,
+			},
+			&protocol.FurnaceRecipe{InputType: protocol.ItemType{NetworkID: 3}, Output: protocol.ItemStack{ItemType: protocol.ItemType{NetworkID: 1}}},
+			&protocol.FurnaceRecipe{InputType: protocol.ItemType{NetworkID: 2}, Output: protocol.ItemStack{ItemType: protocol.ItemType{NetworkID: 1}}},
+		},
+		PotionContainerChangeRecipes: []protocol.PotionContainerChangeRecipe{{InputItemID: 1, ReagentItemID: 3, OutputItemID: 2}},
+	}
+	if err := Translate(state.Default(), -3, -4, pk); err != nil {
+		t.Fatal(err)
+	}
+	if len(pk.Recipes) != 2 || len(pk.PotionContainerChangeRecipes) != 0 {
+		t.Fatalf("expected the recipes with removed items to be left out, got %v recipes and %v container changes", len(pk.Recipes), len(pk.PotionContainerChangeRecipes))
+	}
+	shaped := pk.Recipes[0].(*protocol.ShapedRecipe)
+	if shaped.Input[0].NetworkID != 6 || shaped.Input[1].NetworkID != 0 || shaped.Output[0].NetworkID != 5 || shaped.RecipeNetworkID != 7 {
+		t.Errorf("shaped recipe translated incorrectly: %+v", shaped)
+	}
+	if furnace := pk.Recipes[1].(*protocol.FurnaceRecipe); furnace.InputType.NetworkID != 6 || furnace.Output.NetworkID != 5 {
+		t.Errorf("furnace recipe translated incorrectly: %+v", furnace)
+	}
+
+	defer policy.Set(policy.Current())
+	policy.Set(policy.Strict)
+	pk = &packet.CraftingData{PotionRecipes: []protocol.PotionRecipe{{InputPotionID: 3, ReagentItemID: 1, OutputPotionID: 1}}}
+	if err := Translate(state.Default(), -3, -4, pk); err == nil {
+		t.Error("expected error translating a potion recipe with an item that doesn't exist in the other version")
+	}
+}
@@ -0,0 +1,160 @@
+package item
+
+import (
+	"fmt"
+
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// recipeTranslator translates the items of recipes from one version to another, translating block runtime IDs using
+// the palettes of blocks. The first item that can't be translated is held in err.
+type recipeTranslator struct {
+	blocks   *state.Registry
+	from, to state.Version
+	err      error
+}
+
+// id translates the item runtime ID passed. 0, air, is kept as it is.
+func (t *recipeTranslator) id(rid *int32) {
+	if *rid == 0 || t.err != nil {
+		return
+	}
+	translated, ok := TranslateRuntimeID(t.from, t.to, *rid)
+	if !ok {
+		t.err = fmt.Errorf("translate recipe item %v from version %v to %v: no such item", *rid, t.from, t.to)
+		return
+	}
+	*rid = translated
+}
+
+// ingredients translates the recipe ingredients passed. Empty ingredients, which are the gaps of shaped recipes, are
+// kept as they are.
+func (t *recipeTranslator) ingredients(input []protocol.RecipeIngredientItem) {
+	for i := range input {
+		if input[i].Count > 0 {
+			t.id(&input[i].NetworkID)
+		}
+	}
+}
+
+// stacks translates the item stacks passed. Item stacks in recipes are decoded without HasNetworkID set, so their
+// network ID is translated unless it is air.
+func (t *recipeTranslator) stacks(output []protocol.ItemStack) {
+	for i := range output {
+		if output[i].NetworkID == 0 || t.err != nil {
+			continue
+		}
+		st := output[i]
+		st.HasNetworkID = true
+		translated, ok := TranslateStack(t.blocks, t.from, t.to, st)
+		if !ok {
+			t.err = fmt.Errorf("translate recipe output %v (block runtime ID %v) from version %v to %v: no such item", st.NetworkID, st.BlockRuntimeID, t.from, t.to)
+			return
+		}
+		translated.HasNetworkID = output[i].HasNetworkID
+		output[i] = translated
+	}
+}
+
+// recipe translates the recipe passed.
+func (t *recipeTranslator) recipe(r protocol.Recipe) {
+	switch r := r.(type) {
+	case *protocol.ShapedRecipe:
+		t.ingredients(r.Input)
+		t.stacks(r.Output)
+	case *protocol.ShapedChemistryRecipe:
+		t.ingredients(r.Input)
+		t.stacks(r.Output)
+	case *protocol.ShapelessRecipe:
+		t.ingredients(r.Input)
+		t.stacks(r.Output)
+	case *protocol.ShapelessChemistryRecipe:
+		t.ingredients(r.Input)
+		t.stacks(r.Output)
+	case *protocol.ShulkerBoxRecipe:
+		t.ingredients(r.Input)
+		t.stacks(r.Output)
+	case *protocol.FurnaceRecipe:
+		t.id(&r.InputType.NetworkID)
+		r.Output.NetworkID, r.Output.BlockRuntimeID = t.output(r.Output)
+	case *protocol.FurnaceDataRecipe:
+		t.id(&r.InputType.NetworkID)
+		r.Output.NetworkID, r.Output.BlockRuntimeID = t.output(r.Output)
+	}
+}
+
+// output translates the single output of a furnace recipe, returning its network ID and block runtime ID.
+func (t *recipeTranslator) output(st protocol.ItemStack) (int32, int32) {
+	output := []protocol.ItemStack{st}
+	t.stacks(output)
+	return output[0].NetworkID, output[0].BlockRuntimeID
+}
+
+// translateCraftingData translates the items of all recipes, potion mixes, potion container changes and material
+// reducers of the CraftingData packet passed from the version from to the version to, like Translate. Recipes keep
+// their network ID, as clients refer to them by it when crafting. Under the strict decode policy, an error is returned
+// if any of the items can't be translated. Under the lenient policy, recipes with items that can't be translated are
+// left out, so that clients aren't shown recipes with ingredients missing.
+func translateCraftingData(blocks *state.Registry, from, to state.Version, pk *packet.CraftingData, strict bool) error {
+	// keep translates a recipe using the function passed and reports if it should be kept.
+	var err error
+	keep := func(f func(t *recipeTranslator)) bool {
+		t := &recipeTranslator{blocks: blocks, from: from, to: to}
+		f(t)
+		if t.err != nil && strict && err == nil {
+			err = t.err
+		}
+		return t.err == nil
+	}
+
+	recipes := pk.Recipes[:0]
+	for _, r := range pk.Recipes {
+		if keep(func(t *recipeTranslator) { t.recipe(r) }) {
+			recipes = append(recipes, r)
+		}
+	}
+	pk.Recipes = recipes
+
+	potions := pk.PotionRecipes[:0]
+	for _, r := range pk.PotionRecipes {
+		r := r
+		if keep(func(t *recipeTranslator) {
+			t.id(&r.InputPotionID)
+			t.id(&r.ReagentItemID)
+			t.id(&r.OutputPotionID)
+		}) {
+			potions = append(potions, r)
+		}
+	}
+	pk.PotionRecipes = potions
+
+	containers := pk.PotionContainerChangeRecipes[:0]
+	for _, r := range pk.PotionContainerChangeRecipes {
+		r := r
+		if keep(func(t *recipeTranslator) {
+			t.id(&r.InputItemID)
+			t.id(&r.ReagentItemID)
+			t.id(&r.OutputItemID)
+		}) {
+			containers = append(containers, r)
+		}
+	}
+	pk.PotionContainerChangeRecipes = containers
+
+	reducers := pk.MaterialReducers[:0]
+	for _, r := range pk.MaterialReducers {
+		r := r
+		if keep(func(t *recipeTranslator) {
+			t.id(&r.InputItem.NetworkID)
+			for i := range r.Outputs {
+				t.id(&r.Outputs[i].NetworkID)
+			}
+		}) {
+			reducers = append(reducers, r)
+		}
+	}
+	pk.MaterialReducers = reducers
+	return err
+}
@@ -0,0 +1,137 @@
+package lang
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml"
+)
+
+// DefaultLocale is the locale used when no message could be found for the locale of a client.
+const DefaultLocale = "en_US"
+
+//go:embed locales/*.toml
+var embedded embed.FS
+
+// Bundle holds the messages of the proxy for any number of locales. Messages are looked up using a fallback chain:
+// first the exact locale of the client is tried, then any other locale of the same language, and finally the
+// DefaultLocale. If none of these hold the message, its key is returned.
+// Bundle is safe for concurrent use.
+type Bundle struct {
+	mu       sync.RWMutex
+	messages map[string]map[string]string
+}
+
+// NewBundle returns a Bundle holding the messages embedded in draco for all locales.
+func NewBundle() *Bundle {
+	b := &Bundle{messages: map[string]map[string]string{}}
+	files, _ := embedded.ReadDir("locales")
+	for _, f := range files {
+		data, _ := embedded.ReadFile("locales/" + f.Name())
+		if err := b.Load(localeOf(f.Name()), data); err != nil {
+			// The embedded files are part of the binary, so this can only happen if one of them is broken.
+			panic(err)
+		}
+	}
+	return b
+}
+
+// Load loads the TOML encoded messages passed for a locale into the Bundle, overriding any messages with the same
+// key that were already present.
+func (b *Bundle) Load(locale string, data []byte) error {
+	m := map[string]string{}
+	if err := toml.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("decode messages for locale %v: %w", locale, err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.messages[locale] == nil {
+		b.messages[locale] = map[string]string{}
+	}
+	for k, v := range m {
+		b.messages[locale][k] = v
+	}
+	return nil
+}
+
+// LoadDir loads all files with the .toml extension in the directory passed into the Bundle. Every file is named
+// after the locale it holds messages for, such as en_US.toml. A directory that does not exist is ignored.
+func (b *Bundle) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return fmt.Errorf("read messages: %w", err)
+		}
+		if err := b.Load(localeOf(filepath.Base(f)), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Translate looks up the message with the key passed for the locale passed and formats it with the arguments
+// passed, in the same way as fmt.Sprintf.
+func (b *Bundle) Translate(locale, key string, args ...any) string {
+	msg, ok := b.lookup(locale, key)
+	if !ok {
+		msg = key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// lookup finds the message with a key for a locale using the fallback chain described in the Bundle documentation.
+func (b *Bundle) lookup(locale, key string) (string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if msg, ok := b.messages[locale][key]; ok {
+		return msg, true
+	}
+	if language, _, ok := strings.Cut(locale, "_"); ok {
+		locales := make([]string, 0, len(b.messages))
+		for l := range b.messages {
+			if strings.HasPrefix(l, language+"_") {
+				locales = append(locales, l)
+			}
+		}
+		// Sort the locales so that the same message is returned every time.
+		sort.Strings(locales)
+		for _, l := range locales {
+			if msg, ok := b.messages[l][key]; ok {
+				return msg, true
+			}
+		}
+	}
+	msg, ok := b.messages[DefaultLocale][key]
+	return msg, ok
+}
+
+// localeOf returns the locale of a messages file by stripping its extension.
+func localeOf(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// defaultBundle is the Bundle used by the package level functions.
+var defaultBundle = NewBundle()
+
+// Default returns the Bundle used by the proxy for all messages it sends to clients.
+func Default() *Bundle {
+	return defaultBundle
+}
+
+// Translate translates a message using the default Bundle. It is a shortcut for Default().Translate.
+func Translate(locale, key string, args ...any) string {
+	return defaultBundle.Translate(locale, key, args...)
+}
@@ -0,0 +1,26 @@
+package latestmappings
+
+import "github.com/cqdetdev/draco/draco/command"
+
+// commandArgTypes holds the IDs of the command argument types of 1.18.30.
+var commandArgTypes = map[string]uint32{
+	"int":              1,
+	"float":            3,
+	"value":            4,
+	"wildcard_int":     5,
+	"operator":         6,
+	"compare_operator": 7,
+	"target":           8,
+	"wildcard_target":  10,
+	"filepath":         17,
+	"integer_range":    23,
+	"equipment_slots":  43,
+	command.String:     44,
+	"block_position":   52,
+	"position":         53,
+	"message":          55,
+	"raw_text":         58,
+	"json":             62,
+	"block_states":     71,
+	"command":          74,
+}
@@ -0,0 +1,90 @@
+package latestmappings
+
+import "github.com/cqdetdev/draco/draco/effect"
+
+// effects holds the sound and particle effects of 1.18.30 that differ from other versions, and those that they fall
+// back to. Sounds before "block_click" have the same ID in all versions supported.
+var effects = effect.Layout{
+	Sounds: map[string]uint32{
+		"raid_horn":             272,
+		"block_click":           363,
+		"block_click_fail":      364,
+		"sculk_catalyst_bloom":  365,
+		"sculk_shrieker_shriek": 366,
+		"warden_nearby_close":   367,
+		"warden_nearby_closer":  368,
+		"warden_nearby_closest": 369,
+		"warden_slightly_angry": 370,
+		"record_otherside":      371,
+		"tongue":                372,
+		"crack_iron_golem":      373,
+		"repair_iron_golem":     374,
+		"listening":             375,
+		"heartbeat":             376,
+		"horn_break":            377,
+		"sculk_place":           378,
+		"sculk_spread":          379,
+		"sculk_charge":          380,
+		"sculk_sensor_place":    381,
+		"sculk_shrieker_place":  382,
+		"goat_call_0":           383,
+		"goat_call_1":           384,
+		"goat_call_2":           385,
+		"goat_call_3":           386,
+		"goat_call_4":           387,
+		"goat_call_5":           388,
+		"goat_call_6":           389,
+		"goat_call_7":           390,
+		"goat_call_8":           391,
+		"goat_call_9":           392,
+		"goat_harmony_0":        393,
+		"goat_harmony_1":        394,
+		"goat_harmony_2":        395,
+		"goat_harmony_3":        396,
+		"goat_harmony_4":        397,
+		"goat_harmony_5":        398,
+		"goat_harmony_6":        399,
+		"goat_harmony_7":        400,
+		"goat_harmony_8":        401,
+		"goat_harmony_9":        402,
+		"goat_melody_0":         403,
+		"goat_melody_1":         404,
+		"goat_melody_2":         405,
+		"goat_melody_3":         406,
+		"goat_melody_4":         407,
+		"goat_melody_5":         408,
+		"goat_melody_6":         409,
+		"goat_melody_7":         410,
+		"goat_melody_8":         411,
+		"goat_melody_9":         412,
+		"goat_bass_0":           413,
+		"goat_bass_1":           414,
+		"goat_bass_2":           415,
+		"goat_bass_3":           416,
+		"goat_bass_4":           417,
+		"goat_bass_5":           418,
+		"goat_bass_6":           419,
+		"goat_bass_7":           420,
+		"goat_bass_8":           421,
+		"goat_bass_9":           422,
+		"undefined":             423,
+	},
+	LevelEvents: map[string]int32{
+		"particle_sculk_shriek": 2035,
+		"sculk_catalyst_bloom":  2036,
+		"sculk_charge":          2037,
+		"sculk_charge_pop":      2038,
+	},
+	Particles: map[string]int32{
+		"soul":       71,
+		"shriek":     81,
+		"sculk_soul": 82,
+	},
+	Effects: []string{
+		"minecraft:soul_particle",
+		"minecraft:sculk_soul_particle",
+		"minecraft:sculk_charge_particle",
+		"minecraft:sculk_charge_pop_particle",
+		"minecraft:shriek_particle",
+	},
+}
@@ -0,0 +1,44 @@
+package latestmappings
+
+import "github.com/cqdetdev/draco/draco/metadata"
+
+// actorMetadata holds the actor metadata keys and actor flags of 1.18.30 that are translated between versions.
+var actorMetadata = metadata.Layout{
+	Keys: map[string]uint32{
+		metadata.FlagsKey:         0,
+		"health":                  1,
+		"variant":                 2,
+		"colour":                  3,
+		"name_tag":                4,
+		"owner_runtime_id":        5,
+		"target_runtime_id":       6,
+		"air":                     7,
+		"potion_colour":           8,
+		"potion_ambient":          9,
+		"custom_display":          18,
+		"potion_aux_value":        36,
+		"scale":                   38,
+		"bounding_box_width":      53,
+		"bounding_box_height":     54,
+		"always_show_name_tag":    81,
+		"score_tag":               84,
+		metadata.FlagsExtendedKey: 92,
+	},
+	Flags: map[string]uint32{
+		"on_fire":              0,
+		"sneaking":             1,
+		"riding":               2,
+		"sprinting":            3,
+		"using_item":           4,
+		"invisible":            5,
+		"critical":             13,
+		"can_show_name_tag":    14,
+		"always_show_name_tag": 15,
+		"no_ai":                16,
+		"can_climb":            19,
+		"breathing":            35,
+		"affected_by_gravity":  48,
+		"enchanted":            51,
+		"swimming":             56,
+	},
+}
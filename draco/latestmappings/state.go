@@ -1,8 +1,13 @@
 package latestmappings
 
 import (
-	"bytes"
 	_ "embed"
+	"github.com/cqdetdev/draco/draco/biome"
+	"github.com/cqdetdev/draco/draco/command"
+	"github.com/cqdetdev/draco/draco/effect"
+	"github.com/cqdetdev/draco/draco/entity"
+	"github.com/cqdetdev/draco/draco/item"
+	"github.com/cqdetdev/draco/draco/metadata"
 	"github.com/cqdetdev/draco/draco/state"
 	"github.com/sandertv/gophertunnel/minecraft/nbt"
 )
@@ -10,10 +15,18 @@ import (
 var (
 	//go:embed block_states.nbt
 	blockStateData []byte
-	// stateRuntimeIDs holds a map for looking up the runtime ID of a block by the stateHash it produces.
-	stateRuntimeIDs = map[state.Hash]uint32{}
-	// runtimeIDToState holds a map for looking up the blockState of a block by its runtime ID.
-	runtimeIDToState = map[uint32]state.Block{}
+)
+
+// Version is the protocol version of the block states held by the package, 1.18.30.
+const Version state.Version = 503
+
+// Ordering is the order that 1.18.30 clients sort their block palette in, which determines the runtime IDs of block
+// states once custom blocks are merged into the palette.
+const Ordering = state.OrderByNameHash
+
+var (
+	//go:embed biome_ids.nbt
+	biomeIDData []byte
 )
 
 var (
@@ -36,31 +49,62 @@ func init() {
 		itemRuntimeIDsToNames[rid] = name
 	}
 
-	dec := nbt.NewDecoder(bytes.NewBuffer(blockStateData))
-
 	// Register all block states present in the block_states.nbt file. These are all possible options registered
 	// blocks may encode to.
-	var s state.Block
-	for {
-		if err := dec.Decode(&s); err != nil {
-			break
-		}
-		rid := uint32(len(stateRuntimeIDs))
-		stateRuntimeIDs[state.HashBlock(s)] = rid
-		runtimeIDToState[rid] = s
+	states, err := state.DecodePalette(blockStateData)
+	if err != nil {
+		panic(err)
 	}
+	palette, err := state.NewPalette(states, nil)
+	if err != nil {
+		panic(err)
+	}
+	state.RegisterPalette(Version, palette)
+	var biomes map[string]int32
+	if err := nbt.Unmarshal(biomeIDData, &biomes); err != nil {
+		panic(err)
+	}
+	biome.Register(Version, biomes)
+	item.RegisterPalette(Version, item.NewPalette(itemNamesToRuntimeIDs, nil))
+	metadata.Register(Version, actorMetadata)
+	entity.Register(Version, entityIdentifiers)
+	command.Register(Version, commandArgTypes)
+	effect.Register(Version, effects)
 }
 
-// StateToRuntimeID converts a name and its state properties to a runtime ID.
+// StateToRuntimeID converts a name and its state properties to a runtime ID, using the palette registered for Version
+// in the default state.Registry. Properties of types that can't be part of a block state are never found.
 func StateToRuntimeID(name string, properties map[string]any) (runtimeID uint32, found bool) {
-	rid, ok := stateRuntimeIDs[state.HashBlock(state.Block{Name: name, Properties: properties})]
-	return rid, ok
+	return blockPalette().RuntimeID(name, properties)
 }
 
-// RuntimeIDToState converts a runtime ID to a name and its state properties.
+// RuntimeIDToState converts a runtime ID to a name and its state properties, using the palette registered for Version
+// in the default state.Registry.
 func RuntimeIDToState(runtimeID uint32) (name string, properties map[string]any, found bool) {
-	s := runtimeIDToState[runtimeID]
-	return s.Name, s.Properties, true
+	s, ok := blockPalette().State(runtimeID)
+	return s.Name, s.Properties, ok
+}
+
+// StateCount returns the amount of block states that are registered. Runtime IDs of block states range from 0 up to,
+// but not including, the amount returned.
+func StateCount() uint32 {
+	return blockPalette().Len()
+}
+
+// blockPalette returns the block palette registered for Version in the default state.Registry.
+func blockPalette() *state.Palette {
+	p, _ := state.PaletteOf(Version)
+	return p
+}
+
+// Items returns a map of the string IDs of all registered items to their runtime IDs. The map may be freely
+// modified by the caller.
+func Items() map[string]int32 {
+	m := make(map[string]int32, len(itemNamesToRuntimeIDs))
+	for name, rid := range itemNamesToRuntimeIDs {
+		m[name] = rid
+	}
+	return m
 }
 
 // ItemRuntimeIDToName converts an item runtime ID to a string ID.
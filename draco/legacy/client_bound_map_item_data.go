@@ -0,0 +1,164 @@
+package legacy
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// ClientBoundMapItemData is sent by the server to the client to update the data of a map shown to the client.
+// Unlike packet.ClientBoundMapItemData, it writes the amount of decorations rather than the amount of tracked
+// objects before the decorations, and it never panics when encoding a texture update with invalid dimensions:
+// such updates are written without their texture instead.
+type ClientBoundMapItemData struct {
+	// MapID is the unique identifier that represents the map that is updated over network. It remains
+	// consistent across sessions.
+	MapID int64
+	// UpdateFlags is a combination of packet.MapUpdateFlag values that indicate what parts of the map should be
+	// updated client-side.
+	UpdateFlags uint32
+	// Dimension is the dimension of the map that should be updated, for example the overworld (0), the nether
+	// (1) or the end (2).
+	Dimension byte
+	// LockedMap specifies if the map that was updated was a locked map, which may be done using a cartography
+	// table.
+	LockedMap bool
+	// Scale is the scale of the map as it is shown in-game.
+	Scale byte
+	// MapsIncludedIn holds an array of map IDs that the map updated is included in.
+	MapsIncludedIn []int64
+	// TrackedObjects is a list of tracked objects on the map, which may either be entities or blocks.
+	TrackedObjects []protocol.MapTrackedObject
+	// Decorations is a list of fixed decorations located on the map.
+	Decorations []protocol.MapDecoration
+	// Height is the height of the texture area that was updated.
+	Height int32
+	// Width is the width of the texture area that was updated.
+	Width int32
+	// XOffset is the X offset in pixels at which the updated texture area starts.
+	XOffset int32
+	// YOffset is the Y offset in pixels at which the updated texture area starts.
+	YOffset int32
+	// Pixels is a list of pixel colours for the new texture of the map, indexed as Pixels[y][x].
+	Pixels [][]color.RGBA
+}
+
+// ID ...
+func (*ClientBoundMapItemData) ID() uint32 {
+	return packet.IDClientBoundMapItemData
+}
+
+// Marshal ...
+func (pk *ClientBoundMapItemData) Marshal(w *protocol.Writer) {
+	if pk.UpdateFlags&packet.MapUpdateFlagTexture != 0 && !pk.validTexture() {
+		pk.UpdateFlags &^= packet.MapUpdateFlagTexture
+	}
+	w.Varint64(&pk.MapID)
+	w.Varuint32(&pk.UpdateFlags)
+	w.Uint8(&pk.Dimension)
+	w.Bool(&pk.LockedMap)
+
+	if pk.UpdateFlags&packet.MapUpdateFlagInitialisation != 0 {
+		l := uint32(len(pk.MapsIncludedIn))
+		w.Varuint32(&l)
+		for _, mapID := range pk.MapsIncludedIn {
+			w.Varint64(&mapID)
+		}
+	}
+	if pk.UpdateFlags&(packet.MapUpdateFlagInitialisation|packet.MapUpdateFlagDecoration|packet.MapUpdateFlagTexture) != 0 {
+		w.Uint8(&pk.Scale)
+	}
+	if pk.UpdateFlags&packet.MapUpdateFlagDecoration != 0 {
+		l := uint32(len(pk.TrackedObjects))
+		w.Varuint32(&l)
+		for _, obj := range pk.TrackedObjects {
+			protocol.MapTrackedObj(w, &obj)
+		}
+		l = uint32(len(pk.Decorations))
+		w.Varuint32(&l)
+		for _, decoration := range pk.Decorations {
+			protocol.MapDeco(w, &decoration)
+		}
+	}
+	if pk.UpdateFlags&packet.MapUpdateFlagTexture != 0 {
+		w.Varint32(&pk.Width)
+		w.Varint32(&pk.Height)
+		w.Varint32(&pk.XOffset)
+		w.Varint32(&pk.YOffset)
+
+		l := uint32(pk.Width * pk.Height)
+		w.Varuint32(&l)
+		for y := int32(0); y < pk.Height; y++ {
+			for x := int32(0); x < pk.Width; x++ {
+				w.VarRGBA(&pk.Pixels[y][x])
+			}
+		}
+	}
+}
+
+// Unmarshal ...
+func (pk *ClientBoundMapItemData) Unmarshal(r *protocol.Reader) {
+	r.Varint64(&pk.MapID)
+	r.Varuint32(&pk.UpdateFlags)
+	r.Uint8(&pk.Dimension)
+	r.Bool(&pk.LockedMap)
+
+	var count uint32
+	if pk.UpdateFlags&packet.MapUpdateFlagInitialisation != 0 {
+		r.Varuint32(&count)
+		pk.MapsIncludedIn = make([]int64, count)
+		for i := uint32(0); i < count; i++ {
+			r.Varint64(&pk.MapsIncludedIn[i])
+		}
+	}
+	if pk.UpdateFlags&(packet.MapUpdateFlagInitialisation|packet.MapUpdateFlagDecoration|packet.MapUpdateFlagTexture) != 0 {
+		r.Uint8(&pk.Scale)
+	}
+	if pk.UpdateFlags&packet.MapUpdateFlagDecoration != 0 {
+		r.Varuint32(&count)
+		pk.TrackedObjects = make([]protocol.MapTrackedObject, count)
+		for i := uint32(0); i < count; i++ {
+			protocol.MapTrackedObj(r, &pk.TrackedObjects[i])
+		}
+		r.Varuint32(&count)
+		pk.Decorations = make([]protocol.MapDecoration, count)
+		for i := uint32(0); i < count; i++ {
+			protocol.MapDeco(r, &pk.Decorations[i])
+		}
+	}
+	if pk.UpdateFlags&packet.MapUpdateFlagTexture != 0 {
+		r.Varint32(&pk.Width)
+		r.Varint32(&pk.Height)
+		r.Varint32(&pk.XOffset)
+		r.Varint32(&pk.YOffset)
+		r.Varuint32(&count)
+
+		r.LimitInt32(pk.Width, 0, math.MaxInt16)
+		r.LimitInt32(pk.Height, 0, math.MaxInt16)
+		r.LimitInt32(pk.Width*pk.Height, int32(count), int32(count))
+
+		pk.Pixels = make([][]color.RGBA, pk.Height)
+		for y := int32(0); y < pk.Height; y++ {
+			pk.Pixels[y] = make([]color.RGBA, pk.Width)
+			for x := int32(0); x < pk.Width; x++ {
+				r.VarRGBA(&pk.Pixels[y][x])
+			}
+		}
+	}
+}
+
+// validTexture checks if the texture update of the packet has a positive width and height and holds exactly
+// Height rows of Width pixels.
+func (pk *ClientBoundMapItemData) validTexture() bool {
+	if pk.Width <= 0 || pk.Height <= 0 || len(pk.Pixels) != int(pk.Height) {
+		return false
+	}
+	for _, row := range pk.Pixels {
+		if len(row) != int(pk.Width) {
+			return false
+		}
+	}
+	return true
+}
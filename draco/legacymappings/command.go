@@ -0,0 +1,27 @@
+package legacymappings
+
+import "github.com/cqdetdev/draco/draco/command"
+
+// commandArgTypes holds the IDs of the command argument types of 1.18.10. The types following the equipment slots
+// have lower IDs than in 1.18.30, which added argument types before them.
+var commandArgTypes = map[string]uint32{
+	"int":              1,
+	"float":            3,
+	"value":            4,
+	"wildcard_int":     5,
+	"operator":         6,
+	"compare_operator": 7,
+	"target":           8,
+	"wildcard_target":  10,
+	"filepath":         17,
+	"integer_range":    23,
+	"equipment_slots":  38,
+	command.String:     39,
+	"block_position":   47,
+	"position":         48,
+	"message":          51,
+	"raw_text":         53,
+	"json":             57,
+	"block_states":     67,
+	"command":          70,
+}
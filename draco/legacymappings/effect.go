@@ -0,0 +1,35 @@
+package legacymappings
+
+import "github.com/cqdetdev/draco/draco/effect"
+
+// effects holds the sound and particle effects of 1.18.10 that differ from other versions, and those that effects of
+// other versions fall back to. Sounds before "block_click" have the same ID in all versions supported.
+var effects = effect.Layout{
+	Sounds: map[string]uint32{
+		"raid_horn":             272,
+		"block_click":           363,
+		"block_click_fail":      364,
+		"sculk_catalyst_bloom":  365,
+		"sculk_shrieker_shriek": 366,
+		"warden_nearby_close":   367,
+		"warden_nearby_closer":  368,
+		"warden_nearby_closest": 369,
+		"warden_slightly_angry": 370,
+		"record_otherside":      371,
+		"tongue":                372,
+		"crack_iron_golem":      373,
+		"repair_iron_golem":     374,
+		"listening":             375,
+		"undefined":             376,
+	},
+	LevelEvents: map[string]int32{
+		"particle_sculk_shriek": 2035,
+		"sculk_catalyst_bloom":  2036,
+	},
+	Particles: map[string]int32{
+		"soul": 71,
+	},
+	Effects: []string{
+		"minecraft:soul_particle",
+	},
+}
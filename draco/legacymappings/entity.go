@@ -0,0 +1,31 @@
+package legacymappings
+
+// entityIdentifiers holds the identifiers of the vanilla entities of 1.18.10.
+var entityIdentifiers = []string{
+	"minecraft:agent", "minecraft:area_effect_cloud", "minecraft:armor_stand", "minecraft:arrow", "minecraft:axolotl",
+	"minecraft:balloon", "minecraft:bat", "minecraft:bee", "minecraft:blaze", "minecraft:boat", "minecraft:cat",
+	"minecraft:cave_spider", "minecraft:chalkboard", "minecraft:chest_minecart", "minecraft:chicken", "minecraft:cod",
+	"minecraft:command_block_minecart", "minecraft:cow", "minecraft:creeper", "minecraft:dolphin", "minecraft:donkey",
+	"minecraft:dragon_fireball", "minecraft:drowned", "minecraft:egg", "minecraft:elder_guardian",
+	"minecraft:elder_guardian_ghost", "minecraft:ender_crystal", "minecraft:ender_dragon", "minecraft:ender_pearl",
+	"minecraft:enderman", "minecraft:endermite", "minecraft:evocation_fang", "minecraft:evocation_illager",
+	"minecraft:eye_of_ender_signal", "minecraft:falling_block", "minecraft:fireball", "minecraft:fireworks_rocket",
+	"minecraft:fishing_hook", "minecraft:fox", "minecraft:ghast", "minecraft:glow_squid", "minecraft:goat",
+	"minecraft:guardian", "minecraft:hoglin", "minecraft:hopper_minecart", "minecraft:horse", "minecraft:husk",
+	"minecraft:ice_bomb", "minecraft:iron_golem", "minecraft:item", "minecraft:leash_knot",
+	"minecraft:lightning_bolt", "minecraft:lingering_potion", "minecraft:llama", "minecraft:llama_spit",
+	"minecraft:magma_cube", "minecraft:minecart", "minecraft:mooshroom", "minecraft:moving_block", "minecraft:mule",
+	"minecraft:npc", "minecraft:ocelot", "minecraft:painting", "minecraft:panda", "minecraft:parrot",
+	"minecraft:phantom", "minecraft:pig", "minecraft:piglin", "minecraft:piglin_brute", "minecraft:pillager",
+	"minecraft:player", "minecraft:polar_bear", "minecraft:pufferfish", "minecraft:rabbit", "minecraft:ravager",
+	"minecraft:salmon", "minecraft:sheep", "minecraft:shield", "minecraft:shulker", "minecraft:shulker_bullet",
+	"minecraft:silverfish", "minecraft:skeleton", "minecraft:skeleton_horse", "minecraft:slime",
+	"minecraft:small_fireball", "minecraft:snow_golem", "minecraft:snowball", "minecraft:spider",
+	"minecraft:splash_potion", "minecraft:squid", "minecraft:stray", "minecraft:strider", "minecraft:thrown_trident",
+	"minecraft:tnt", "minecraft:tnt_minecart", "minecraft:tripod_camera", "minecraft:tropicalfish",
+	"minecraft:turtle", "minecraft:vex", "minecraft:villager", "minecraft:villager_v2", "minecraft:vindicator",
+	"minecraft:wandering_trader", "minecraft:witch", "minecraft:wither", "minecraft:wither_skeleton",
+	"minecraft:wither_skull", "minecraft:wither_skull_dangerous", "minecraft:wolf", "minecraft:xp_bottle",
+	"minecraft:xp_orb", "minecraft:zoglin", "minecraft:zombie", "minecraft:zombie_horse", "minecraft:zombie_pigman",
+	"minecraft:zombie_villager", "minecraft:zombie_villager_v2",
+}
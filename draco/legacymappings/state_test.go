@@ -0,0 +1,59 @@
+package legacymappings
+
+import (
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/state"
+)
+
+func TestStateHashesUnique(t *testing.T) {
+	if StateCount() == 0 {
+		t.Fatal("no block states loaded")
+	}
+	seen := make(map[state.Hash]uint32, StateCount())
+	for rid := uint32(0); rid < StateCount(); rid++ {
+		s, _ := blockPalette().State(rid)
+		h := state.HashBlock(s)
+		if other, ok := seen[h]; ok {
+			t.Fatalf("block states %v (%v) and %v produce the same hash", rid, s, other)
+		}
+		seen[h] = rid
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	for rid := uint32(0); rid < StateCount(); rid++ {
+		name, properties, ok := RuntimeIDToState(rid)
+		if !ok {
+			t.Fatalf("no block state with runtime ID %v", rid)
+		}
+		if back, ok := StateToRuntimeID(name, properties); !ok || back != rid {
+			t.Fatalf("block state %v%v (%v) maps back to runtime ID %v (found: %v)", name, properties, rid, back, ok)
+		}
+	}
+}
+
+func TestOrdering(t *testing.T) {
+	p, _ := state.PaletteOf(Version)
+	custom, err := p.WithCustomBlocks([]state.Block{{Name: "draco:test"}}, Ordering)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The palette is already sorted by its Ordering, so vanilla block states keep their relative order.
+	last := int64(-1)
+	for rid := uint32(0); rid < p.Len(); rid++ {
+		s, _ := p.State(rid)
+		other, ok := custom.RuntimeID(s.Name, s.Properties)
+		if !ok || int64(other) <= last {
+			t.Fatalf("block state %v (%v) moved to runtime ID %v after merging a custom block (found: %v)", s.Name, rid, other, ok)
+		}
+		last = int64(other)
+	}
+}
+
+func TestRuntimeIDToStateUnknown(t *testing.T) {
+	rid := StateCount()
+	if name, _, ok := RuntimeIDToState(rid); ok {
+		t.Fatalf("expected no block state with runtime ID %v, got %q", rid, name)
+	}
+}
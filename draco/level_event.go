@@ -0,0 +1,86 @@
+package draco
+
+import (
+	"strconv"
+
+	"github.com/cqdetdev/draco/draco/effect"
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/legacymappings"
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/cqdetdev/draco/draco/telemetry"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// levelEventDataDowngrades holds functions that translate the EventData field of a 1.18.30 LevelEvent to its
+// 1.18.12 equivalent, keyed by the event type. Many events, such as block breaking and cracking particles,
+// embed a block runtime ID in their data, which differs between the two versions. Event types that are not
+// present in this table carry data that is identical across versions and are forwarded unchanged.
+var levelEventDataDowngrades = map[int32]func(blocks *state.Registry, data int32) int32{
+	packet.LevelEventParticlesDestroyBlock:        downgradeBlockEventData,
+	packet.LevelEventParticlesDestroyBlockNoSound: downgradeBlockEventData,
+	packet.LevelEventParticlesCrackBlock:          downgradeFacedBlockEventData,
+}
+
+// downgradeLevelEventData translates the data of a 1.18.30 LevelEvent with the event type passed to the data
+// expected by a 1.18.12 client, translating block runtime IDs using the palettes of the state.Registry passed.
+func downgradeLevelEventData(blocks *state.Registry, eventType, data int32) int32 {
+	if f, ok := levelEventDataDowngrades[eventType]; ok {
+		return f(blocks, data)
+	}
+	return data
+}
+
+// downgradeBlockEventData translates event data that consists solely of a block runtime ID.
+func downgradeBlockEventData(blocks *state.Registry, data int32) int32 {
+	return int32(downgradeBlockRuntimeID(blocks, uint32(data)))
+}
+
+// downgradeFacedBlockEventData translates event data that holds a block runtime ID in its lower 24 bits and the
+// face of the block that the event applies to in its upper 8 bits.
+func downgradeFacedBlockEventData(blocks *state.Registry, data int32) int32 {
+	face, rid := data>>24, uint32(data&0xffffff)
+	return int32(downgradeBlockRuntimeID(blocks, rid)) | face<<24
+}
+
+// noLevelEvent is a LevelEvent type that no version uses. Packets can't be dropped while being translated, so events
+// that a 1.18.10 client doesn't have are sent with this type, which the client ignores.
+const noLevelEvent = 0
+
+// downgradeLevelEventType translates the type of a 1.18.30 LevelEvent, including the particle type of legacy particle
+// events, to the type that 1.18.10 clients play. Events that 1.18.10 has no equivalent for are sent as noLevelEvent
+// and counted in the "effects_dropped" metrics group.
+func downgradeLevelEventType(eventType int32) int32 {
+	translated, ok := effect.TranslateLevelEvent(latestmappings.Version, legacymappings.Version, eventType)
+	if !ok {
+		metrics.AddTo("effects_dropped", "level_event", 1)
+		telemetry.Record(telemetry.KindLevelEvent, latestmappings.Version, legacymappings.Version, strconv.Itoa(int(eventType)))
+		return noLevelEvent
+	}
+	return translated
+}
+
+// translateSound translates the ID of a LevelSoundEvent sound of the version from to the version to. Sounds that the
+// version to has no equivalent for are played as its "undefined" sound, which is silent, and counted in the
+// "effects_dropped" metrics group.
+func translateSound(from, to state.Version, id uint32) uint32 {
+	translated, ok := effect.TranslateSound(from, to, id)
+	if !ok {
+		metrics.AddTo("effects_dropped", "sound", 1)
+		telemetry.Record(telemetry.KindSound, from, to, strconv.FormatUint(uint64(id), 10))
+		translated, _ = effect.Sound(to, "undefined")
+	}
+	return translated
+}
+
+// downgradeParticleEffect translates the identifier of a particle effect spawned using a 1.18.30 SpawnParticleEffect
+// packet to the effect spawned for 1.18.10 clients. Effects that 1.18.10 has no equivalent for are spawned without
+// an identifier, which clients ignore, and counted in the "effects_dropped" metrics group.
+func downgradeParticleEffect(identifier string) string {
+	translated, ok := effect.TranslateParticleEffect(latestmappings.Version, legacymappings.Version, identifier)
+	if !ok {
+		metrics.AddTo("effects_dropped", "particle_effect", 1)
+		telemetry.Record(telemetry.KindParticleEffect, latestmappings.Version, legacymappings.Version, identifier)
+	}
+	return translated
+}
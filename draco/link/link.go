@@ -0,0 +1,146 @@
+package link
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// codeAlphabet holds the characters that codes are made of. Characters that are easily confused, such as 0 and O,
+// are left out, as players have to type the code on the website.
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// codeLength is the amount of characters in a code.
+const codeLength = 6
+
+// Account is the account of a player that a code was issued to.
+type Account struct {
+	// XUID is the XBOX Live user ID of the player.
+	XUID string `json:"xuid"`
+	// Name is the display name of the player at the time the code was issued.
+	Name string `json:"name"`
+}
+
+// code is a code issued to a player that has not yet been verified.
+type code struct {
+	account Account
+	expiry  time.Time
+}
+
+// Linker issues one-time codes to players in-game, which may then be verified by a website to link the account of
+// the player to an account on that website, without requiring a plugin on the backend.
+// A typical flow has the player request a code in-game (through Issue, or a request to the HTTP API made by the
+// website), type it on the website, after which the website verifies it using the HTTP API, learning the XUID of
+// the player.
+// Linker is safe for concurrent use.
+type Linker struct {
+	ttl    time.Duration
+	secret string
+
+	mu    sync.Mutex
+	codes map[string]code
+}
+
+// New returns a Linker issuing codes that remain valid for the duration passed. The secret passed must be sent as
+// a bearer token in the Authorization header of all HTTP requests made to the Linker.
+func New(ttl time.Duration, secret string) *Linker {
+	return &Linker{ttl: ttl, secret: secret, codes: map[string]code{}}
+}
+
+// Issue issues a new code to the player of the Session passed and shows it to them in a title. Any code previously
+// issued to the player is invalidated.
+func (l *Linker) Issue(s *proxy.Session) string {
+	acc := Account{XUID: s.XUID(), Name: s.Name()}
+
+	l.mu.Lock()
+	for c, pending := range l.codes {
+		if pending.account.XUID == acc.XUID || time.Now().After(pending.expiry) {
+			delete(l.codes, c)
+		}
+	}
+	c := newCode()
+	for _, ok := l.codes[c]; ok; _, ok = l.codes[c] {
+		c = newCode()
+	}
+	l.codes[c] = code{account: acc, expiry: time.Now().Add(l.ttl)}
+	l.mu.Unlock()
+
+	_ = s.Client().WritePacket(&packet.SetTitle{ActionType: packet.TitleActionSetTitle, Text: s.Translate("link.title")})
+	_ = s.Client().WritePacket(&packet.SetTitle{ActionType: packet.TitleActionSetSubtitle, Text: s.Translate("link.subtitle", c)})
+	_ = s.Client().WritePacket(&packet.Text{TextType: packet.TextTypeRaw, Message: s.Translate("link.message", c, int(l.ttl.Minutes()))})
+	return c
+}
+
+// Verify verifies a code, returning the Account it was issued to. A code may only be verified once: false is
+// returned if the code was never issued, has expired or was already verified.
+func (l *Linker) Verify(c string) (Account, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	pending, ok := l.codes[c]
+	if !ok {
+		return Account{}, false
+	}
+	delete(l.codes, c)
+	if time.Now().After(pending.expiry) {
+		return Account{}, false
+	}
+	return pending.account, true
+}
+
+// ServeHTTP serves the HTTP API of the Linker. It supports two endpoints:
+//
+//	POST /link/request?xuid=<xuid>  issues a code to the online player with the XUID passed
+//	GET  /link/verify?code=<code>   verifies a code and responds with the JSON encoded Account it was issued to
+func (l *Linker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+l.secret)) != 1 {
+		http.Error(w, "unauthorised", http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/link/request":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s, ok := proxy.SessionByXUID(r.URL.Query().Get("xuid"))
+		if !ok {
+			http.Error(w, "player not online", http.StatusNotFound)
+			return
+		}
+		// The code is deliberately not returned: the player must read it in-game and enter it on the website,
+		// proving that they own the account.
+		l.Issue(s)
+		w.WriteHeader(http.StatusNoContent)
+	case "/link/verify":
+		acc, ok := l.Verify(r.URL.Query().Get("code"))
+		if !ok {
+			http.Error(w, "invalid code", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(acc)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newCode generates a new random code.
+func newCode() string {
+	b := make([]byte, codeLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(codeAlphabet))))
+		if err != nil {
+			panic(err)
+		}
+		b[i] = codeAlphabet[n.Int64()]
+	}
+	return string(b)
+}
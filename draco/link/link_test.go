@@ -0,0 +1,148 @@
+package link
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// playerConn is a proxy.ClientConn of a player that records the packets written to it and sends nothing until it is
+// closed.
+type playerConn struct {
+	xuid, name string
+	closed     chan struct{}
+
+	mu      sync.Mutex
+	written []packet.Packet
+}
+
+func newPlayerConn(xuid, name string) *playerConn {
+	return &playerConn{xuid: xuid, name: name, closed: make(chan struct{})}
+}
+
+func (c *playerConn) ReadPacket() (packet.Packet, error) {
+	<-c.closed
+	return nil, errors.New("closed")
+}
+func (c *playerConn) WritePacket(pk packet.Packet) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written = append(c.written, pk)
+	return nil
+}
+func (c *playerConn) IdentityData() login.IdentityData {
+	return login.IdentityData{XUID: c.xuid, DisplayName: c.name}
+}
+func (c *playerConn) ClientData() login.ClientData { return login.ClientData{LanguageCode: "en_US"} }
+func (c *playerConn) Latency() time.Duration       { return 0 }
+func (c *playerConn) Disconnect(string) error      { return c.Close() }
+func (c *playerConn) Close() error {
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	return nil
+}
+
+// serve serves a request with the method, path and secret passed using the Linker passed, returning the response.
+// No Authorization header is set if secret is empty.
+func serve(l *Linker, method, path, secret string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	if secret != "" {
+		r.Header.Set("Authorization", "Bearer "+secret)
+	}
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, r)
+	return w
+}
+
+func TestIssueVerify(t *testing.T) {
+	conn := newPlayerConn("2535400000000001", "Steve")
+	s := proxy.NewSession(conn, newPlayerConn("", ""), proxy.Backend{Name: "lobby"})
+	l := New(time.Minute, "secret")
+
+	first := l.Issue(s)
+	c := l.Issue(s)
+	if len(c) != codeLength {
+		t.Fatalf("issued code %q, expected %v characters", c, codeLength)
+	}
+	conn.mu.Lock()
+	shown := len(conn.written)
+	conn.mu.Unlock()
+	if shown == 0 {
+		t.Error("code was not shown to the player")
+	}
+	if _, ok := l.Verify(first); ok {
+		t.Error("code issued before the last one to the player was still valid")
+	}
+	acc, ok := l.Verify(c)
+	if !ok || acc != (Account{XUID: "2535400000000001", Name: "Steve"}) {
+		t.Errorf("code verified as %+v (%v), expected the account of the player", acc, ok)
+	}
+	if _, ok := l.Verify(c); ok {
+		t.Error("code was verified twice")
+	}
+	if _, ok := l.Verify("UNKNWN"); ok {
+		t.Error("code that was never issued was verified")
+	}
+	expired := New(-time.Minute, "secret")
+	if _, ok := expired.Verify(expired.Issue(s)); ok {
+		t.Error("expired code was verified")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	conn := newPlayerConn("2535400000000002", "Alex")
+	s := proxy.NewSession(conn, newPlayerConn("", ""), proxy.Backend{Name: "lobby"})
+	s.Start()
+	defer conn.Close()
+	for i := 0; i < 100; i++ {
+		if _, ok := proxy.SessionByXUID("2535400000000002"); ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	l := New(time.Minute, "secret")
+	c := l.Issue(s)
+	tests := []struct {
+		name, method, path, secret string
+		status                     int
+	}{
+		{"missing secret", http.MethodPost, "/link/request?xuid=2535400000000002", "", http.StatusUnauthorized},
+		{"wrong secret", http.MethodGet, "/link/verify?code=" + c, "wrong", http.StatusUnauthorized},
+		{"request using GET", http.MethodGet, "/link/request?xuid=2535400000000002", "secret", http.StatusMethodNotAllowed},
+		{"request for player offline", http.MethodPost, "/link/request?xuid=1", "secret", http.StatusNotFound},
+		{"request", http.MethodPost, "/link/request?xuid=2535400000000002", "secret", http.StatusNoContent},
+		{"unknown code", http.MethodGet, "/link/verify?code=UNKNWN", "secret", http.StatusNotFound},
+		{"unknown path", http.MethodGet, "/link/other", "secret", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		if w := serve(l, test.method, test.path, test.secret); w.Code != test.status {
+			t.Errorf("%v: got status %v, expected %v", test.name, w.Code, test.status)
+		}
+	}
+
+	// The request above invalidated the code issued before it, so a new one is issued to verify.
+	c = l.Issue(s)
+	w := serve(l, http.MethodGet, "/link/verify?code="+c, "secret")
+	var acc Account
+	if err := json.NewDecoder(w.Body).Decode(&acc); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("verify: got status %v and error %v, expected %v", w.Code, err, http.StatusOK)
+	}
+	if acc != (Account{XUID: "2535400000000002", Name: "Alex"}) {
+		t.Errorf("code verified as %+v, expected the account of the player", acc)
+	}
+	if w := serve(l, http.MethodGet, "/link/verify?code="+c, "secret"); w.Code != http.StatusNotFound {
+		t.Errorf("second verification: got status %v, expected %v", w.Code, http.StatusNotFound)
+	}
+}
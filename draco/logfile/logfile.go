@@ -0,0 +1,246 @@
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config holds the settings of a Writer.
+type Config struct {
+	// Path is the path of the log file written to. Rotated files are placed next to it, with the time of rotation
+	// appended to their name.
+	Path string
+	// MaxSize is the size in bytes after which the log file is rotated. If 0, the file is never rotated because of
+	// its size.
+	MaxSize int64
+	// MaxAge is the duration after which the log file is rotated. If 0, the file is never rotated because of its
+	// age.
+	MaxAge time.Duration
+	// MaxBackups is the maximum amount of rotated log files kept. The oldest files are removed once this amount is
+	// exceeded. If 0, rotated files are never removed.
+	MaxBackups int
+}
+
+// Writer is an io.Writer that writes to a log file, rotating it once it grows too large or too old. A Writer may
+// be reopened using Reopen, which is typically done after receiving SIGHUP once an external tool such as
+// logrotate moved the file away.
+// Writer is safe for concurrent use.
+type Writer struct {
+	conf Config
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// Open opens the log file described by the Config passed, creating it if it does not yet exist, and returns a
+// Writer writing to it.
+func Open(conf Config) (*Writer, error) {
+	w := &Writer{conf: conf}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write writes b to the log file, rotating the file first if it has grown too large or too old.
+func (w *Writer) Write(b []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.shouldRotate(len(b)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err = w.f.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Reopen closes the log file and opens the file at the same path again. If the file was moved away, a new file
+// is created.
+func (w *Writer) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_ = w.f.Close()
+	return w.open()
+}
+
+// Rotate rotates the log file immediately, regardless of its size or age.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotate()
+}
+
+// Close closes the log file. The Writer must not be used after it is closed.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+// Lines returns the lines of the log file and of the rotated log files kept that contain the string passed, such as
+// the XUID of a player, oldest first.
+func (w *Writer) Lines(substr string) ([]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var lines []string
+	for _, path := range w.files() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, line := range strings.SplitAfter(string(data), "\n") {
+			if strings.Contains(line, substr) {
+				lines = append(lines, strings.TrimSuffix(line, "\n"))
+			}
+		}
+	}
+	return lines, nil
+}
+
+// Purge removes the lines containing the string passed, such as the XUID of a player, from the log file and from the
+// rotated log files kept, and returns the amount of lines removed. Files holding such lines are rewritten.
+func (w *Writer) Purge(substr string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	removed := 0
+	for _, path := range w.files() {
+		current := path == w.conf.Path
+		if current {
+			// The log file is rewritten, so it is closed first and opened again once it was.
+			_ = w.f.Close()
+		}
+		n, err := purgeFile(path, substr)
+		removed += n
+		if current {
+			opened := w.opened
+			if oerr := w.open(); err == nil {
+				err = oerr
+			}
+			w.opened = opened
+		}
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// purgeFile removes the lines containing the string passed from the file at the path passed, replacing the file
+// atomically if any were found. It returns the amount of lines removed.
+func purgeFile(path, substr string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var (
+		kept    strings.Builder
+		removed int
+	)
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if strings.Contains(line, substr) {
+			removed++
+			continue
+		}
+		kept.WriteString(line)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	// The temporary file is hidden so that it is never mistaken for a rotated log file.
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tmp.WriteString(kept.String()); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return 0, err
+	}
+	return removed, nil
+}
+
+// files returns the paths of the rotated log files kept, oldest first, followed by the path of the log file.
+func (w *Writer) files() []string {
+	backups, _ := filepath.Glob(w.conf.Path + ".*")
+	// The rotation time suffix sorts lexicographically, so the oldest backups come first.
+	sort.Strings(backups)
+	return append(backups, w.conf.Path)
+}
+
+// shouldRotate checks if the log file should be rotated before writing n more bytes to it.
+func (w *Writer) shouldRotate(n int) bool {
+	if w.conf.MaxSize > 0 && w.size > 0 && w.size+int64(n) > w.conf.MaxSize {
+		return true
+	}
+	return w.conf.MaxAge > 0 && time.Since(w.opened) > w.conf.MaxAge
+}
+
+// open opens the log file at the configured path for appending.
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.conf.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	w.f, w.size, w.opened = f, info.Size(), time.Now()
+	return nil
+}
+
+// rotate moves the current log file away, opens a new one and removes old rotated files exceeding the maximum
+// amount of backups.
+func (w *Writer) rotate() error {
+	_ = w.f.Close()
+	rotated := fmt.Sprintf("%v.%v", w.conf.Path, time.Now().Format("2006-01-02T15-04-05.000"))
+	if err := os.Rename(w.conf.Path, rotated); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	if w.conf.MaxBackups > 0 {
+		w.prune()
+	}
+	return nil
+}
+
+// prune removes the oldest rotated log files until at most MaxBackups remain.
+func (w *Writer) prune() {
+	backups, err := filepath.Glob(w.conf.Path + ".*")
+	if err != nil || len(backups) <= w.conf.MaxBackups {
+		return
+	}
+	// The rotation time suffix sorts lexicographically, so the oldest backups come first.
+	sort.Strings(backups)
+	for _, b := range backups[:len(backups)-w.conf.MaxBackups] {
+		_ = os.Remove(b)
+	}
+}
@@ -0,0 +1,109 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// backups returns the contents of the rotated log files of the Writer passed, oldest first.
+func backups(t *testing.T, w *Writer) []string {
+	files := w.files()
+	var contents []string
+	for _, path := range files[:len(files)-1] {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, string(data))
+	}
+	return contents
+}
+
+// write writes the lines passed to the Writer passed. It waits between lines, so that files rotated by them are
+// never given the same name.
+func write(t *testing.T, w *Writer, lines ...string) {
+	for _, line := range lines {
+		if _, err := w.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+func TestRotateBySize(t *testing.T) {
+	w, err := Open(Config{Path: filepath.Join(t.TempDir(), "draco.log"), MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Every line fills up the file, so that the next line is written to a new file.
+	write(t, w, "line one", "line two", "line 3", "line 4")
+	got := backups(t, w)
+	if want := []string{"line two\n", "line 3\n"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got backups %q, expected the %v most recent ones: %q", got, len(want), want)
+	}
+	if data, _ := os.ReadFile(w.conf.Path); string(data) != "line 4\n" {
+		t.Errorf("log file holds %q, expected the last line only", data)
+	}
+}
+
+func TestRotateByInterval(t *testing.T) {
+	w, err := Open(Config{Path: filepath.Join(t.TempDir(), "draco.log"), MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	write(t, w, "first", "second")
+	if got := backups(t, w); len(got) != 0 {
+		t.Fatalf("log file younger than MaxAge was rotated into %q", got)
+	}
+	w.opened = time.Now().Add(-2 * time.Hour)
+	write(t, w, "third")
+	if got := backups(t, w); len(got) != 1 || got[0] != "first\nsecond\n" {
+		t.Errorf("got backups %q, expected a single backup holding the lines written before", got)
+	}
+	if data, _ := os.ReadFile(w.conf.Path); string(data) != "third\n" {
+		t.Errorf("log file holds %q, expected the line written after rotating only", data)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	w, err := Open(Config{Path: filepath.Join(t.TempDir(), "draco.log")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	write(t, w, "join xuid=1", "join xuid=2")
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "chat xuid=1", "chat xuid=2")
+	if lines, _ := w.Lines("xuid=1"); strings.Join(lines, "|") != "join xuid=1|chat xuid=1" {
+		t.Errorf("got lines %q of player before purging", lines)
+	}
+
+	n, err := w.Purge("xuid=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("purged %v lines, expected 2", n)
+	}
+	if got := backups(t, w); len(got) != 1 || got[0] != "join xuid=2\n" {
+		t.Errorf("got backups %q after purging, expected the lines of the other player only", got)
+	}
+	// The file is opened again after purging, so lines written later must still end up in it.
+	write(t, w, "quit xuid=2")
+	if data, _ := os.ReadFile(w.conf.Path); string(data) != "chat xuid=2\nquit xuid=2\n" {
+		t.Errorf("log file holds %q after purging, expected the lines of the other player only", data)
+	}
+	if lines, _ := w.Lines("xuid=1"); len(lines) != 0 {
+		t.Errorf("got lines %q of player after purging", lines)
+	}
+}
@@ -0,0 +1,246 @@
+// Package logging implements structured logging: every line logged holds a level, a message and a list of fields,
+// such as the XUID and address of the player that the line is about, and is written as text or as JSON, so that logs
+// may be searched and collected by log aggregation tools. The standard logger may be redirected to the package using
+// Writer, so that lines logged by other packages are written in the same format.
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Level is the severity of a line logged. Lines below the Level set in the Config are not written.
+type Level int
+
+// The levels that lines may be logged at, from least to most severe.
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// levelNames holds the names of all levels, as written in lines logged and as parsed by ParseLevel.
+var levelNames = map[Level]string{LevelDebug: "DEBUG", LevelInfo: "INFO", LevelWarn: "WARN", LevelError: "ERROR"}
+
+// String ...
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+}
+
+// ParseLevel parses a Level by its name, such as "debug" or "warn", ignoring case. An empty name results in
+// LevelInfo.
+func ParseLevel(name string) (Level, error) {
+	if name == "" {
+		return LevelInfo, nil
+	}
+	for l, n := range levelNames {
+		if strings.EqualFold(n, name) {
+			return l, nil
+		}
+	}
+	if strings.EqualFold(name, "warning") {
+		return LevelWarn, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// Config configures how lines are logged.
+type Config struct {
+	// Level is the minimum Level of lines written. Lines of a lower level are discarded.
+	Level Level
+	// JSON specifies if lines are written as JSON objects, one per line, rather than as text.
+	JSON bool
+	// Output is the io.Writer that lines are written to. If nil, lines are written to os.Stderr.
+	Output io.Writer
+}
+
+var (
+	// mu guards conf and serialises writes to conf.Output, so that lines logged concurrently don't interleave.
+	mu sync.Mutex
+	// conf is the Config set using Configure.
+	conf = Config{Output: os.Stderr}
+)
+
+// Configure sets the Config that all lines are logged with.
+func Configure(c Config) {
+	if c.Output == nil {
+		c.Output = os.Stderr
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	conf = c
+}
+
+// Logger logs lines with a list of fields attached. The zero Logger logs lines without fields. A Logger is safe for
+// concurrent use.
+type Logger struct {
+	fields []any
+}
+
+// Default returns a Logger without fields.
+func Default() *Logger {
+	return &Logger{}
+}
+
+// With returns a Logger that logs lines with the fields of the Logger and the fields passed, which are key-value
+// pairs, such as With("xuid", xuid, "address", addr).
+func (l *Logger) With(fields ...any) *Logger {
+	return &Logger{fields: append(append(make([]any, 0, len(l.fields)+len(fields)), l.fields...), fields...)}
+}
+
+// Enabled checks if lines of the Level passed are written.
+func (l *Logger) Enabled(level Level) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return level >= conf.Level
+}
+
+// Debug logs a line with the message and fields passed at LevelDebug.
+func (l *Logger) Debug(msg string, fields ...any) { l.Log(LevelDebug, msg, fields...) }
+
+// Info logs a line with the message and fields passed at LevelInfo.
+func (l *Logger) Info(msg string, fields ...any) { l.Log(LevelInfo, msg, fields...) }
+
+// Warn logs a line with the message and fields passed at LevelWarn.
+func (l *Logger) Warn(msg string, fields ...any) { l.Log(LevelWarn, msg, fields...) }
+
+// Error logs a line with the message and fields passed at LevelError.
+func (l *Logger) Error(msg string, fields ...any) { l.Log(LevelError, msg, fields...) }
+
+// Log logs a line with the message and fields passed at the Level passed, after the fields of the Logger. Fields are
+// key-value pairs: a trailing value without a key is logged with the key "!BADKEY".
+func (l *Logger) Log(level Level, msg string, fields ...any) {
+	mu.Lock()
+	defer mu.Unlock()
+	if level < conf.Level {
+		return
+	}
+	all := append(append(make([]any, 0, len(l.fields)+len(fields)), l.fields...), fields...)
+	if len(all)%2 != 0 {
+		all = append(all[:len(all)-1], "!BADKEY", all[len(all)-1])
+	}
+	var line []byte
+	if conf.JSON {
+		line = encodeJSON(time.Now(), level, msg, all)
+	} else {
+		line = encodeText(time.Now(), level, msg, all)
+	}
+	_, _ = conf.Output.Write(line)
+}
+
+// encodeText encodes a line as text, in the format of the standard logger followed by the level, the message and the
+// fields, such as `2022/05/01 12:00:00 INFO player joined xuid=123 name="Some Player"`.
+func encodeText(t time.Time, level Level, msg string, fields []any) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, 128))
+	buf.WriteString(t.Format("2006/01/02 15:04:05 "))
+	buf.WriteString(level.String())
+	buf.WriteByte(' ')
+	buf.WriteString(msg)
+	for i := 0; i < len(fields); i += 2 {
+		buf.WriteByte(' ')
+		buf.WriteString(fmt.Sprint(fields[i]))
+		buf.WriteByte('=')
+		buf.WriteString(textValue(fields[i+1]))
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// textValue formats a field value for a line logged as text, quoting it if it is empty or holds spaces, quotes, '='
+// or control characters.
+func textValue(v any) string {
+	s := stringValue(v)
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r)
+	}) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// encodeJSON encodes a line as a JSON object holding the time, level and message of the line under "time", "level" and
+// "msg", followed by the fields.
+func encodeJSON(t time.Time, level Level, msg string, fields []any) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, 128))
+	buf.WriteString(`{"time":`)
+	writeJSON(buf, t.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(buf, level.String())
+	buf.WriteString(`,"msg":`)
+	writeJSON(buf, msg)
+	for i := 0; i < len(fields); i += 2 {
+		buf.WriteByte(',')
+		writeJSON(buf, fmt.Sprint(fields[i]))
+		buf.WriteByte(':')
+		switch v := fields[i+1].(type) {
+		case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			writeJSON(buf, v)
+		default:
+			writeJSON(buf, stringValue(v))
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// writeJSON writes the JSON encoding of the value passed to the buffer, without escaping HTML characters.
+func writeJSON(buf *bytes.Buffer, v any) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		// Only floats that are not numbers, such as NaN, can't be encoded.
+		_ = enc.Encode(fmt.Sprint(v))
+	}
+	// Encode terminates the value with a newline.
+	buf.Truncate(buf.Len() - 1)
+}
+
+// stringValue formats a field value as a string.
+func stringValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// Writer returns an io.Writer that logs every line written to it as a message without fields, so that the standard
+// logger, redirected using log.SetOutput, and the loggers of other packages write lines in the same format. Lines
+// are logged at the Level passed, or at LevelError if they start with "error" or "panic". The standard logger
+// should have its flags set to 0, as lines are prefixed with the time already.
+func Writer(level Level) io.Writer {
+	return lineWriter{level: level}
+}
+
+// lineWriter implements the io.Writer returned by Writer.
+type lineWriter struct {
+	level Level
+}
+
+// Write ...
+func (w lineWriter) Write(b []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		level := w.level
+		if lower := strings.ToLower(line); strings.HasPrefix(lower, "error") || strings.HasPrefix(lower, "panic") {
+			level = LevelError
+		}
+		Default().Log(level, line)
+	}
+	return len(b), nil
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	Configure(Config{Level: LevelInfo, Output: buf})
+	defer Configure(Config{})
+
+	l := Default().With("xuid", "123", "name", "Some Player")
+	l.Debug("not written")
+	l.Warn("player joined", "protocol", 486, "err", errors.New("x=y"))
+	line := buf.String()
+	if strings.Contains(line, "not written") {
+		t.Errorf("line below the level was written: %q", line)
+	}
+	if !strings.HasSuffix(line, ` WARN player joined xuid=123 name="Some Player" protocol=486 err="x=y"`+"\n") {
+		t.Errorf("unexpected line %q", line)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	Configure(Config{Level: LevelDebug, JSON: true, Output: buf})
+	defer Configure(Config{})
+
+	Default().With("xuid", "123").Debug("player <joined>", "protocol", int32(486), "odd")
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("line %q is not valid JSON: %v", buf.String(), err)
+	}
+	for k, v := range map[string]any{"level": "DEBUG", "msg": "player <joined>", "xuid": "123", "protocol": 486.0, "!BADKEY": "odd"} {
+		if line[k] != v {
+			t.Errorf("%v is %v, expected %v", k, line[k], v)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	Configure(Config{Level: LevelInfo, Output: buf})
+	defer Configure(Config{})
+
+	std := log.New(Writer(LevelInfo), "", 0)
+	std.Printf("listening on %v", ":19132")
+	std.Printf("error dialing backend: refused")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], " INFO listening on :19132") || !strings.HasSuffix(lines[1], " ERROR error dialing backend: refused") {
+		t.Errorf("unexpected lines %q", lines)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for name, expected := range map[string]Level{"": LevelInfo, "debug": LevelDebug, "WARN": LevelWarn, "warning": LevelWarn, "error": LevelError} {
+		if l, err := ParseLevel(name); err != nil || l != expected {
+			t.Errorf("%q parsed as %v (%v), expected %v", name, l, err, expected)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("expected error parsing an unknown level")
+	}
+}
@@ -0,0 +1,18 @@
+package draco
+
+import (
+	"encoding/binary"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// LoginProtocol returns the protocol that a client logs in with if the packet with the header and payload passed, as
+// passed to minecraft.ListenConfig.PacketFunc, is a Login packet. False is returned for any other packet, or if the
+// payload is too short to hold the protocol.
+func LoginProtocol(header packet.Header, payload []byte) (int32, bool) {
+	if header.PacketID != packet.IDLogin || len(payload) < 4 {
+		return 0, false
+	}
+	// The Login packet starts with the protocol of the client as a big endian int32.
+	return int32(binary.BigEndian.Uint32(payload)), true
+}
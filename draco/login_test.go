@@ -0,0 +1,19 @@
+package draco
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestLoginProtocol(t *testing.T) {
+	if id, ok := LoginProtocol(packet.Header{PacketID: packet.IDLogin}, []byte{0, 0, 0x01, 0xe6, 0xff}); !ok || id != 486 {
+		t.Errorf("expected protocol 486, got %v (%v)", id, ok)
+	}
+	if _, ok := LoginProtocol(packet.Header{PacketID: packet.IDLogin}, []byte{0, 0, 0x01}); ok {
+		t.Error("expected a payload too short to hold the protocol to be ignored")
+	}
+	if _, ok := LoginProtocol(packet.Header{PacketID: packet.IDText}, []byte{0, 0, 0x01, 0xe6}); ok {
+		t.Error("expected packets other than Login to be ignored")
+	}
+}
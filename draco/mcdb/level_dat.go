@@ -0,0 +1,86 @@
+package mcdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// storageVersion is the version of the level.dat format written.
+const storageVersion = 9
+
+// voidLayers are the flat world layers of an exported world: a single layer of air, so that the game doesn't
+// generate terrain around the chunks exported.
+const voidLayers = `{"biome_id":1,"block_layers":[{"block_name":"minecraft:air","count":1}],"encoding_version":6,"structure_options":null}`
+
+// levelDat holds the settings of a world written to its level.dat file. Settings that are not present are set to
+// their defaults by the game. Exported worlds don't cycle the time of day or spawn mobs, so that they stay the way
+// they were exported.
+type levelDat struct {
+	BaseGameVersion                string `nbt:"baseGameVersion"`
+	CommandsEnabled                bool   `nbt:"commandsEnabled"`
+	Difficulty                     int32
+	DoDayLightCycle                bool `nbt:"dodaylightcycle"`
+	DoMobSpawning                  bool `nbt:"domobspawning"`
+	FlatWorldLayers                string
+	GameType                       int32
+	Generator                      int32
+	LastOpenedWithVersion          []int32 `nbt:"lastOpenedWithVersion"`
+	LastPlayed                     int64
+	LevelName                      string
+	MinimumCompatibleClientVersion []int32
+	NetworkVersion                 int32
+	SpawnX, SpawnY, SpawnZ         int32
+	StorageVersion                 int32
+	Time                           int64
+}
+
+// encodeLevelDat encodes the level.dat of the World passed: a header holding the storage version and the length of
+// the data, followed by the settings encoded as little endian NBT.
+func encodeLevelDat(w World) ([]byte, error) {
+	version := gameVersion()
+	data, err := nbt.MarshalEncoding(levelDat{
+		BaseGameVersion:                protocol.CurrentVersion,
+		CommandsEnabled:                true,
+		Difficulty:                     1,
+		FlatWorldLayers:                voidLayers,
+		GameType:                       w.GameType,
+		Generator:                      2,
+		LastOpenedWithVersion:          version,
+		LastPlayed:                     time.Now().Unix(),
+		LevelName:                      w.Name,
+		MinimumCompatibleClientVersion: version,
+		NetworkVersion:                 protocol.CurrentProtocol,
+		SpawnX:                         w.Spawn.X(),
+		SpawnY:                         w.Spawn.Y(),
+		SpawnZ:                         w.Spawn.Z(),
+		StorageVersion:                 storageVersion,
+		Time:                           w.Time,
+	}, nbt.LittleEndian)
+	if err != nil {
+		return nil, fmt.Errorf("encode level.dat: %w", err)
+	}
+	buf := bytes.NewBuffer(nil)
+	_ = binary.Write(buf, binary.LittleEndian, int32(storageVersion))
+	_ = binary.Write(buf, binary.LittleEndian, int32(len(data)))
+	buf.Write(data)
+	return buf.Bytes(), nil
+}
+
+// gameVersion returns the game version that the proxy supports as five numbers, the way it is written to a level.dat.
+func gameVersion() []int32 {
+	v := make([]int32, 5)
+	for i, part := range strings.Split(protocol.CurrentVersion, ".") {
+		if i < len(v) {
+			n, _ := strconv.Atoi(part)
+			v[i] = int32(n)
+		}
+	}
+	return v
+}
@@ -0,0 +1,143 @@
+package mcdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// The database of a world is written as a LevelDB database that only consists of a log. The game replays the log
+// when it opens the world, compacting it into tables, so the proxy doesn't need to implement the table format or
+// compression of LevelDB.
+const (
+	// manifestName and logName are the names of the files holding the manifest and the log of the database.
+	manifestName, logName = "MANIFEST-000001", "000002.log"
+	// logNumber is the file number of the log, and nextFileNumber the first file number that isn't used yet.
+	logNumber, nextFileNumber = 2, 3
+	// comparator is the name of the comparator that the keys of the database are sorted with.
+	comparator = "leveldb.BytewiseComparator"
+)
+
+const (
+	// logBlockSize is the size of the blocks that a log is made up of, and logHeaderSize the size of the header of a
+	// record in a log.
+	logBlockSize, logHeaderSize = 32768, 7
+	// The types of records in a log. A record that doesn't fit in the remainder of a block is split into fragments.
+	recordFull, recordFirst, recordMiddle, recordLast = 1, 2, 3, 4
+)
+
+// The tags of the fields of a version edit that are written to the manifest.
+const (
+	tagComparator     = 1
+	tagLogNumber      = 2
+	tagNextFileNumber = 3
+	tagLastSequence   = 4
+)
+
+// typeValue is the type of the entries of a write batch that put a value.
+const typeValue = 1
+
+// castagnoli is the CRC-32C table used for the checksums of log records.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// database is a LevelDB database that entries are written to in write batches.
+type database struct {
+	log logWriter
+	seq uint64
+}
+
+// put writes the entries passed to the database in a single write batch. Keys and values are alternated in kv.
+func (db *database) put(kv ...[]byte) {
+	if len(kv) == 0 {
+		return
+	}
+	buf := bytes.NewBuffer(nil)
+	_ = binary.Write(buf, binary.LittleEndian, db.seq+1)
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(kv)/2))
+	for i := 0; i+1 < len(kv); i += 2 {
+		buf.WriteByte(typeValue)
+		writeBytes(buf, kv[i])
+		writeBytes(buf, kv[i+1])
+	}
+	db.seq += uint64(len(kv) / 2)
+	db.log.record(buf.Bytes())
+}
+
+// files returns the files of the database, keyed by their name.
+func (db *database) files() map[string][]byte {
+	edit := bytes.NewBuffer(nil)
+	writeUvarint(edit, tagComparator)
+	writeBytes(edit, []byte(comparator))
+	writeUvarint(edit, tagLogNumber)
+	writeUvarint(edit, logNumber)
+	writeUvarint(edit, tagNextFileNumber)
+	writeUvarint(edit, nextFileNumber)
+	writeUvarint(edit, tagLastSequence)
+	writeUvarint(edit, db.seq)
+	var manifest logWriter
+	manifest.record(edit.Bytes())
+
+	return map[string][]byte{
+		"CURRENT":    []byte(manifestName + "\n"),
+		manifestName: manifest.buf.Bytes(),
+		logName:      db.log.buf.Bytes(),
+	}
+}
+
+// logWriter writes records in the log format of LevelDB, which is used for both the log and the manifest.
+type logWriter struct {
+	buf bytes.Buffer
+}
+
+// record writes a record holding the data passed, splitting it into fragments if it doesn't fit in the current block.
+func (l *logWriter) record(data []byte) {
+	for first := true; ; first = false {
+		left := logBlockSize - l.buf.Len()%logBlockSize
+		if left < logHeaderSize {
+			// The remainder of a block that can't hold a header is filled with zeros.
+			l.buf.Write(make([]byte, left))
+			left = logBlockSize
+		}
+		n, last := left-logHeaderSize, false
+		if len(data) <= n {
+			n, last = len(data), true
+		}
+		var t byte
+		switch {
+		case first && last:
+			t = recordFull
+		case first:
+			t = recordFirst
+		case last:
+			t = recordLast
+		default:
+			t = recordMiddle
+		}
+		header := make([]byte, logHeaderSize)
+		binary.LittleEndian.PutUint32(header, maskChecksum(crc32.Update(crc32.Checksum([]byte{t}, castagnoli), castagnoli, data[:n])))
+		binary.LittleEndian.PutUint16(header[4:], uint16(n))
+		header[6] = t
+		l.buf.Write(header)
+		l.buf.Write(data[:n])
+		if data = data[n:]; last {
+			return
+		}
+	}
+}
+
+// maskChecksum masks a checksum the way LevelDB stores it.
+func maskChecksum(c uint32) uint32 {
+	return (c>>15 | c<<17) + 0xa282ead8
+}
+
+// writeUvarint writes a varint to the buffer passed.
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	b := make([]byte, binary.MaxVarintLen64)
+	buf.Write(b[:binary.PutUvarint(b, v)])
+}
+
+// writeBytes writes a byte slice prefixed by its length to the buffer passed.
+func writeBytes(buf *bytes.Buffer, b []byte) {
+	writeUvarint(buf, uint64(len(b)))
+	buf.Write(b)
+}
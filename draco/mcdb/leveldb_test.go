@@ -0,0 +1,139 @@
+package mcdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/chunk"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+func TestLogFragments(t *testing.T) {
+	var l logWriter
+	small, large := []byte("small"), bytes.Repeat([]byte{7}, logBlockSize*2+100)
+	l.record(small)
+	l.record(large)
+	l.record(small)
+	records, err := readLog(l.buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || !bytes.Equal(records[0], small) || !bytes.Equal(records[1], large) || !bytes.Equal(records[2], small) {
+		t.Fatalf("records read differ from those written")
+	}
+}
+
+func TestWriteWorld(t *testing.T) {
+	c := chunk.New(0, cube.Range{-64, 319})
+	c.SetBlock(1, 70, 2, 0, 10)
+	w := World{Name: "lobby", Chunks: []Chunk{{Dimension: 1, Position: protocol.ChunkPos{3, -4}, Chunk: c, BlockEntities: []map[string]any{{"id": "Sign", "x": int32(49), "y": int32(70), "z": int32(-62)}}}}}
+	dir := t.TempDir()
+	if err := w.WriteDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	current, err := ioutil.ReadFile(filepath.Join(dir, "db", "CURRENT"))
+	if err != nil || string(current) != manifestName+"\n" {
+		t.Fatalf("unexpected CURRENT file %q: %v", current, err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "db", logName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := readLog(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected a single write batch, got %v", len(records))
+	}
+	entries, err := readBatch(records[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := string(chunkIndex(1, protocol.ChunkPos{3, -4}))
+	if len(index) != 12 {
+		t.Fatalf("index of chunk outside of the overworld has length %v", len(index))
+	}
+	for _, k := range []string{index + ",", index + "+", index + "6", index + "1", index + "/\x04"} {
+		if _, ok := entries[k]; !ok {
+			t.Errorf("no entry with key %x", k)
+		}
+	}
+	// Sub chunk 8 of the chunk, which holds Y 70, is the only one that isn't empty.
+	if len(entries) != 5 {
+		t.Errorf("expected 5 entries, got %v", len(entries))
+	}
+}
+
+// readBatch reads the entries of a write batch, keyed by their key.
+func readBatch(b []byte) (map[string][]byte, error) {
+	if len(b) < 12 {
+		return nil, fmt.Errorf("write batch too short")
+	}
+	count := binary.LittleEndian.Uint32(b[8:])
+	buf := bytes.NewBuffer(b[12:])
+	entries := map[string][]byte{}
+	for i := uint32(0); i < count; i++ {
+		if t, err := buf.ReadByte(); err != nil || t != typeValue {
+			return nil, fmt.Errorf("invalid entry type")
+		}
+		k, err := readBytes(buf)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readBytes(buf)
+		if err != nil {
+			return nil, err
+		}
+		entries[string(k)] = v
+	}
+	return entries, nil
+}
+
+// readBytes reads a byte slice prefixed by its length.
+func readBytes(buf *bytes.Buffer) ([]byte, error) {
+	n, err := binary.ReadUvarint(buf)
+	if err != nil {
+		return nil, err
+	}
+	if b := buf.Next(int(n)); len(b) == int(n) {
+		return b, nil
+	}
+	return nil, fmt.Errorf("unexpected end of write batch")
+}
+
+// readLog reads all records from a log written by a logWriter, checking their checksums.
+func readLog(data []byte) ([][]byte, error) {
+	var records [][]byte
+	var record []byte
+	for off := 0; off < len(data); {
+		if left := logBlockSize - off%logBlockSize; left < logHeaderSize {
+			off += left
+			continue
+		}
+		if off+logHeaderSize > len(data) {
+			return nil, fmt.Errorf("truncated record header at %v", off)
+		}
+		header := data[off : off+logHeaderSize]
+		n, t := int(binary.LittleEndian.Uint16(header[4:])), header[6]
+		if off+logHeaderSize+n > len(data) {
+			return nil, fmt.Errorf("truncated record at %v", off)
+		}
+		frag := data[off+logHeaderSize : off+logHeaderSize+n]
+		if maskChecksum(crc32.Update(crc32.Checksum([]byte{t}, castagnoli), castagnoli, frag)) != binary.LittleEndian.Uint32(header) {
+			return nil, fmt.Errorf("checksum mismatch of record at %v", off)
+		}
+		record = append(record, frag...)
+		if t == recordFull || t == recordLast {
+			records, record = append(records, record), nil
+		}
+		off += logHeaderSize + n
+	}
+	return records, nil
+}
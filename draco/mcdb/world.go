@@ -0,0 +1,177 @@
+// Package mcdb writes Bedrock worlds in the LevelDB format of the game, such as to export the chunks that the proxy
+// received from a backend.
+package mcdb
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cqdetdev/draco/draco/chunk"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// chunkVersion is the version of the chunks written, that of 1.18.30.
+const chunkVersion = 40
+
+// Keys of the entries of a chunk, which follow the index of the chunk.
+const (
+	keySubChunkData  = '/'
+	keyVersion       = ','
+	keyBlockEntities = '1'
+	keyFinalisation  = '6'
+	key3DData        = '+'
+)
+
+// finalised is the finalisation state of chunks that were fully generated.
+const finalised = 2
+
+// World is a world that may be written using WriteDir or WriteZip.
+type World struct {
+	// Name is the name of the world.
+	Name string
+	// Spawn is the position that players spawn at, Time the time of day and GameType the game mode of players.
+	Spawn    protocol.BlockPos
+	Time     int64
+	GameType int32
+	// Chunks holds all chunks of the world.
+	Chunks []Chunk
+}
+
+// Chunk is a chunk of a World.
+type Chunk struct {
+	// Dimension is the dimension that the chunk is in and Position its position.
+	Dimension int32
+	Position  protocol.ChunkPos
+	// Chunk holds the blocks and biomes of the chunk. Its runtime IDs are those of the latest version.
+	Chunk *chunk.Chunk
+	// BlockEntities holds the block entities in the chunk, such as signs and chests.
+	BlockEntities []map[string]any
+}
+
+// WriteDir writes the World to the directory passed, which is created if it doesn't exist. Worlds are written as a
+// folder that may be placed in the worlds folder of the game or of a server.
+func (w World) WriteDir(dir string) error {
+	files, err := w.files()
+	if err != nil {
+		return err
+	}
+	for _, name := range sortedNames(files) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			return fmt.Errorf("create directory of %v: %w", name, err)
+		}
+		if err := ioutil.WriteFile(path, files[name], 0644); err != nil {
+			return fmt.Errorf("write %v: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// WriteZip writes the World as a .mcworld file to the writer passed: a zip archive holding the folder of the world,
+// which the game imports when opened.
+func (w World) WriteZip(out io.Writer) error {
+	files, err := w.files()
+	if err != nil {
+		return err
+	}
+	z := zip.NewWriter(out)
+	for _, name := range sortedNames(files) {
+		f, err := z.Create(name)
+		if err != nil {
+			return fmt.Errorf("create %v: %w", name, err)
+		}
+		if _, err := f.Write(files[name]); err != nil {
+			return fmt.Errorf("write %v: %w", name, err)
+		}
+	}
+	return z.Close()
+}
+
+// files returns all files of the World, keyed by their path relative to the folder of the world.
+func (w World) files() (map[string][]byte, error) {
+	levelDat, err := encodeLevelDat(w)
+	if err != nil {
+		return nil, err
+	}
+	var db database
+	for _, c := range w.Chunks {
+		kv, err := chunkEntries(c)
+		if err != nil {
+			return nil, fmt.Errorf("encode chunk %v in dimension %v: %w", c.Position, c.Dimension, err)
+		}
+		db.put(kv...)
+	}
+	files := map[string][]byte{"level.dat": levelDat, "levelname.txt": []byte(w.Name)}
+	for name, data := range db.files() {
+		files["db/"+name] = data
+	}
+	return files, nil
+}
+
+// chunkEntries returns the database entries of the Chunk passed, alternating keys and values.
+func chunkEntries(c Chunk) ([][]byte, error) {
+	c.Chunk.Compact()
+	data := chunk.Encode(c.Chunk, chunk.DiskEncoding)
+	index := chunkIndex(c.Dimension, c.Position)
+	key := func(k ...byte) []byte {
+		return append(append([]byte(nil), index...), k...)
+	}
+
+	finalisation := make([]byte, 4)
+	binary.LittleEndian.PutUint32(finalisation, finalised)
+	kv := [][]byte{
+		key(keyVersion), {chunkVersion},
+		// The height map is written as zeros, after which the game calculates it itself.
+		key(key3DData), append(make([]byte, 512), data.Biomes...),
+		key(keyFinalisation), finalisation,
+	}
+	r := c.Chunk.Range()
+	for i, sub := range c.Chunk.Sub() {
+		if sub.Empty() {
+			continue
+		}
+		kv = append(kv, key(keySubChunkData, byte(i+(r[0]>>4))), data.SubChunks[i])
+	}
+	if len(c.BlockEntities) > 0 {
+		buf := bytes.NewBuffer(nil)
+		enc := nbt.NewEncoderWithEncoding(buf, nbt.LittleEndian)
+		for _, b := range c.BlockEntities {
+			if err := enc.Encode(b); err != nil {
+				return nil, fmt.Errorf("encode block entity: %w", err)
+			}
+		}
+		kv = append(kv, key(keyBlockEntities), buf.Bytes())
+	}
+	return kv, nil
+}
+
+// chunkIndex returns the index of a chunk that is the prefix of the keys of all its entries: its coordinates,
+// followed by its dimension if it isn't the overworld.
+func chunkIndex(dimension int32, pos protocol.ChunkPos) []byte {
+	b := make([]byte, 12)
+	binary.LittleEndian.PutUint32(b, uint32(pos.X()))
+	binary.LittleEndian.PutUint32(b[4:], uint32(pos.Z()))
+	if dimension == 0 {
+		return b[:8]
+	}
+	binary.LittleEndian.PutUint32(b[8:], uint32(dimension))
+	return b
+}
+
+// sortedNames returns the names of the files passed in order, so that worlds are always written the same way.
+func sortedNames(files map[string][]byte) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,179 @@
+// Package metadata translates actor metadata, also known as entity metadata or actor data, between protocol
+// versions. The numerical keys of actor metadata and the indices of actor flags may shift between versions, so the
+// keys and flags of every version are registered by name, and metadata is translated by looking up the name of
+// every key and flag in the version translated from and its number in the version translated to.
+package metadata
+
+import (
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/state"
+)
+
+const (
+	// FlagsKey is the name of the key holding the first 64 actor flags as an int64 bit set.
+	FlagsKey = "flags"
+	// FlagsExtendedKey is the name of the key holding the actor flags beyond the first 64 as an int64 bit set.
+	FlagsExtendedKey = "flags_extended"
+)
+
+// Layout holds the actor metadata keys and actor flags of a single version, keyed by their name. Keys and flags not
+// present in a Layout are translated as they are, so a Layout only has to hold the keys and flags that draco or its
+// users depend on, and all keys and flags that differ from other versions.
+type Layout struct {
+	// Keys holds the numerical keys of actor metadata. The keys holding the actor flags must be named FlagsKey and
+	// FlagsExtendedKey.
+	Keys map[string]uint32
+	// Flags holds the indices of actor flags. Flags with an index of 64 or higher are stored in the extended flags.
+	Flags map[string]uint32
+}
+
+// layout is a Layout with lookups from the numbers of keys and flags back to their names.
+type layout struct {
+	Layout
+	keyNames, flagNames map[uint32]string
+}
+
+var (
+	// layoutMu guards layouts.
+	layoutMu sync.RWMutex
+	// layouts holds the layouts registered using Register, keyed by their version.
+	layouts = map[state.Version]layout{}
+)
+
+// Register registers the Layout of the version passed. Registering a Layout for a version that already has one
+// replaces it. Layouts are generally registered in the init function of the package holding them.
+func Register(v state.Version, l Layout) {
+	r := layout{Layout: l, keyNames: make(map[uint32]string, len(l.Keys)), flagNames: make(map[uint32]string, len(l.Flags))}
+	for name, k := range l.Keys {
+		r.keyNames[k] = name
+	}
+	for name, f := range l.Flags {
+		r.flagNames[f] = name
+	}
+	layoutMu.Lock()
+	defer layoutMu.Unlock()
+	layouts[v] = r
+}
+
+// layoutOf returns the layout registered for the version passed.
+func layoutOf(v state.Version) (layout, bool) {
+	layoutMu.RLock()
+	defer layoutMu.RUnlock()
+	l, ok := layouts[v]
+	return l, ok
+}
+
+// Key looks up the numerical key of the actor metadata key with the name passed in the version passed.
+func Key(v state.Version, name string) (uint32, bool) {
+	l, ok := layoutOf(v)
+	if !ok {
+		return 0, false
+	}
+	k, ok := l.Keys[name]
+	return k, ok
+}
+
+// Flag looks up the index of the actor flag with the name passed in the version passed.
+func Flag(v state.Version, name string) (uint32, bool) {
+	l, ok := layoutOf(v)
+	if !ok {
+		return 0, false
+	}
+	f, ok := l.Flags[name]
+	return f, ok
+}
+
+// Translate translates the actor metadata passed from the version from to the version to, replacing its contents.
+// Keys and flags known in the version from but not in the version to are removed, as the client would otherwise
+// interpret them as a different key or flag. Metadata is left unchanged if either version has no Layout
+// registered.
+func Translate(from, to state.Version, metadata map[uint32]any) {
+	if from == to || len(metadata) == 0 {
+		return
+	}
+	f, ok := layoutOf(from)
+	if !ok {
+		return
+	}
+	t, ok := layoutOf(to)
+	if !ok {
+		return
+	}
+
+	translated := make(map[uint32]any, len(metadata))
+	for k, v := range metadata {
+		name, ok := f.keyNames[k]
+		if !ok || name == FlagsKey || name == FlagsExtendedKey {
+			continue
+		}
+		if other, ok := t.Keys[name]; ok {
+			translated[other] = v
+		}
+	}
+	if flags, present := f.flags(metadata); present[0] || present[1] {
+		t.setFlags(translated, f.translateFlags(t, flags), present)
+	}
+	for k, v := range metadata {
+		if _, ok := f.keyNames[k]; ok {
+			continue
+		}
+		// Unknown keys are kept as they are, unless a translated key took their place.
+		if _, taken := translated[k]; !taken {
+			translated[k] = v
+		}
+	}
+	for k := range metadata {
+		delete(metadata, k)
+	}
+	for k, v := range translated {
+		metadata[k] = v
+	}
+}
+
+// flagSet is a set of 128 actor flags, of which the first 64 are stored in the first element.
+type flagSet [2]uint64
+
+// flags reads the actor flags from the metadata passed. present specifies which of the two flag keys the metadata
+// holds.
+func (l layout) flags(metadata map[uint32]any) (set flagSet, present [2]bool) {
+	for i, name := range []string{FlagsKey, FlagsExtendedKey} {
+		k, ok := l.Keys[name]
+		if !ok {
+			continue
+		}
+		if v, ok := metadata[k].(int64); ok {
+			set[i], present[i] = uint64(v), true
+		}
+	}
+	return set, present
+}
+
+// setFlags writes the actor flags passed to the metadata passed. A flag key is only written if the metadata
+// translated held it, or if any of its flags are set, so that metadata updating only some keys doesn't reset the
+// flags of the other key.
+func (l layout) setFlags(metadata map[uint32]any, set flagSet, present [2]bool) {
+	for i, name := range []string{FlagsKey, FlagsExtendedKey} {
+		if k, ok := l.Keys[name]; ok && (present[i] || set[i] != 0) {
+			metadata[k] = int64(set[i])
+		}
+	}
+}
+
+// translateFlags translates the actor flags passed from the layout l to the layout t.
+func (l layout) translateFlags(t layout, set flagSet) flagSet {
+	var translated flagSet
+	for i := uint32(0); i < 128; i++ {
+		if set[i>>6]&(1<<(i&63)) == 0 {
+			continue
+		}
+		index := i
+		if name, ok := l.flagNames[i]; ok {
+			if index, ok = t.Flags[name]; !ok || index >= 128 {
+				continue
+			}
+		}
+		translated[index>>6] |= 1 << (index & 63)
+	}
+	return translated
+}
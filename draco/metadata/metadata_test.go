@@ -0,0 +1,55 @@
+package metadata
+
+import "testing"
+
+func TestTranslate(t *testing.T) {
+	Register(-1, Layout{
+		Keys:  map[string]uint32{FlagsKey: 0, "name_tag": 4, "scale": 38, "removed": 40, FlagsExtendedKey: 92},
+		Flags: map[string]uint32{"sneaking": 1, "invisible": 5, "swimming": 56, "removed": 60, "extended": 70},
+	})
+	Register(-2, Layout{
+		Keys:  map[string]uint32{FlagsKey: 0, "name_tag": 4, "scale": 39, FlagsExtendedKey: 93},
+		Flags: map[string]uint32{"sneaking": 1, "invisible": 6, "swimming": 66, "extended": 71},
+	})
+
+	m := map[uint32]any{
+		0:   int64(1<<1 | 1<<5 | 1<<56 | 1<<60),
+		92:  int64(1 << (70 - 64)),
+		4:   "Steve",
+		38:  float32(2),
+		40:  byte(1),
+		100: int32(7),
+	}
+	Translate(-1, -2, m)
+	if s := m[4]; s != "Steve" {
+		t.Errorf("name tag translated to %v, expected Steve", s)
+	}
+	if sc, ok := m[39]; !ok || sc != float32(2) {
+		t.Errorf("scale was not moved to its new key: %v", m)
+	}
+	if _, ok := m[38]; ok {
+		t.Error("scale was kept at its old key")
+	}
+	if _, ok := m[40]; ok {
+		t.Error("key removed in the new version was kept")
+	}
+	if v := m[100]; v != int32(7) {
+		t.Errorf("unknown key translated to %v, expected 7", v)
+	}
+	if flags := m[0]; flags != int64(1<<1|1<<6) {
+		t.Errorf("flags translated to %b, expected %b", flags, int64(1<<1|1<<6))
+	}
+	if flags := m[93]; flags != int64(1<<(66-64)|1<<(71-64)) {
+		t.Errorf("extended flags translated to %b, expected %b", flags, int64(1<<(66-64)|1<<(71-64)))
+	}
+	if _, ok := m[92]; ok {
+		t.Error("extended flags were kept at their old key")
+	}
+
+	// Metadata that only updates the first flags must not reset the extended flags of the other version.
+	m = map[uint32]any{0: int64(1 << 5)}
+	Translate(-1, -2, m)
+	if _, ok := m[93]; ok {
+		t.Errorf("extended flags were added to metadata that didn't hold them: %v", m)
+	}
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+	"sync"
+)
+
+// counters holds all counters of the proxy. It is published through expvar as "draco", and served as JSON by
+// Handler.
+var counters = expvar.NewMap("draco")
+
+// groupMu serialises the creation of groups by AddTo.
+var groupMu sync.Mutex
+
+// Add adds delta to the counter with the name passed, creating it if it does not yet exist.
+func Add(name string, delta int64) {
+	counters.Add(name, delta)
+}
+
+// Value returns the current value of the counter with the name passed, or 0 if it does not exist.
+func Value(name string) int64 {
+	if v, ok := counters.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// AddTo adds delta to the counter with the name passed in the group passed, creating either if it does not yet exist.
+// Groups hold related counters, such as the amount of players per client version, and are served as nested objects
+// by Handler.
+func AddTo(group, name string, delta int64) {
+	m, ok := counters.Get(group).(*expvar.Map)
+	if !ok {
+		groupMu.Lock()
+		if m, ok = counters.Get(group).(*expvar.Map); !ok {
+			m = new(expvar.Map).Init()
+			counters.Set(group, m)
+		}
+		groupMu.Unlock()
+	}
+	m.Add(name, delta)
+}
+
+// Group returns the current values of all counters in the group passed, keyed by their name.
+func Group(group string) map[string]int64 {
+	values := map[string]int64{}
+	if m, ok := counters.Get(group).(*expvar.Map); ok {
+		m.Do(func(kv expvar.KeyValue) {
+			if v, ok := kv.Value.(*expvar.Int); ok {
+				values[kv.Key] = v.Value()
+			}
+		})
+	}
+	return values
+}
+
+// Handler returns an http.Handler that serves the current value of all counters as a JSON object.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(counters.String()))
+	})
+}
@@ -0,0 +1,194 @@
+// Package mismatch keeps a report of the block states held by chunks that have no equivalent in the version that the
+// chunks were translated to, so that entries missing from the translation tables are found in the chunks that
+// players are sent, rather than from reports of players seeing air where blocks should be.
+package mismatch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/state"
+)
+
+// Entry is a block state without an equivalent in the version it was translated to, along with how often it was
+// found.
+type Entry struct {
+	// From and To are the versions that the block state was translated from and to.
+	From state.Version `json:"from"`
+	To   state.Version `json:"to"`
+	// RuntimeID is the runtime ID of the block state in From.
+	RuntimeID uint32 `json:"runtime_id"`
+	// Name and Properties are the name and properties of the block state in From. Name is empty if RuntimeID is not
+	// in the palette of From at all, which happens when the server sends a corrupted chunk.
+	Name       string         `json:"name,omitempty"`
+	Properties map[string]any `json:"properties,omitempty"`
+	// Count is the amount of sub chunks that the block state was found in.
+	Count uint64 `json:"count"`
+	// FirstSeen and LastSeen are the times that the block state was first and last found at.
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	// seen is the value of changes when the block state was last found, which orders entries found at the same time.
+	seen uint64
+}
+
+// maxEntries is the maximum amount of entries in the report. Once reached, the entry found least recently is
+// removed to make room for a new one, so that the report keeps up with the states currently sent.
+const maxEntries = 4096
+
+// key identifies an Entry.
+type key struct {
+	from, to state.Version
+	rid      uint32
+}
+
+var (
+	// mu guards entries and changes.
+	mu sync.Mutex
+	// entries holds all entries of the report.
+	entries = map[key]*Entry{}
+	// changes is the amount of times that the report changed.
+	changes uint64
+)
+
+// Record records that a sub chunk held the block state with the runtime ID passed, which has no equivalent when
+// translated from the version from to the version to. A warning is logged the first time a block state is recorded.
+func Record(from, to state.Version, rid uint32) {
+	now := time.Now()
+	k := key{from: from, to: to, rid: rid}
+
+	mu.Lock()
+	e, ok := entries[k]
+	if !ok {
+		if len(entries) >= maxEntries {
+			evictLocked()
+		}
+		e = &Entry{From: from, To: to, RuntimeID: rid, FirstSeen: now}
+		if p, ok := state.PaletteOf(from); ok {
+			if b, ok := p.State(rid); ok {
+				e.Name, e.Properties = b.Name, b.Properties
+			}
+		}
+		entries[k] = e
+	}
+	e.Count++
+	e.LastSeen = now
+	changes++
+	e.seen = changes
+	name, properties := e.Name, e.Properties
+	mu.Unlock()
+
+	if !ok {
+		logging.Default().Warn("block state has no equivalent", "from", from, "to", to, "runtime_id", rid, "name", name, "properties", properties)
+	}
+}
+
+// evictLocked removes the entry found least recently from the report. mu must be held.
+func evictLocked() {
+	var (
+		oldest key
+		last   uint64
+	)
+	for k, e := range entries {
+		if last == 0 || e.seen < last {
+			oldest, last = k, e.seen
+		}
+	}
+	delete(entries, oldest)
+}
+
+// Report returns all entries of the report, the block states found most often first.
+func Report() []Entry {
+	mu.Lock()
+	report := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		report = append(report, *e)
+	}
+	mu.Unlock()
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.RuntimeID < b.RuntimeID
+	})
+	return report
+}
+
+// Reset removes all entries from the report, such as once the translation tables were updated.
+func Reset() {
+	mu.Lock()
+	entries = map[key]*Entry{}
+	changes++
+	mu.Unlock()
+}
+
+// WriteFile writes the report to the file passed as JSON, replacing the file atomically.
+func WriteFile(path string) error {
+	data, err := json.MarshalIndent(Report(), "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".mismatch-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// WriteEvery writes the report to the file passed every interval passed, until the process exits. The file is only
+// written if the report changed since it was last written.
+func WriteEvery(path string, interval time.Duration) {
+	go func() {
+		var written uint64
+		for range time.Tick(interval) {
+			mu.Lock()
+			latest := changes
+			mu.Unlock()
+			if latest == written {
+				continue
+			}
+			if err := WriteFile(path); err != nil {
+				logging.Default().Warn("error writing mismatch report", "file", path, "err", err)
+				continue
+			}
+			written = latest
+		}
+	}()
+}
+
+// Handler returns an http.Handler serving the report. GET requests are responded to with the report as a JSON list
+// of entries, and DELETE requests reset it.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(Report())
+		case http.MethodDelete:
+			Reset()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package mismatch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	Reset()
+	Record(1, 2, 10)
+	Record(1, 2, 11)
+	Record(1, 2, 11)
+	report := Report()
+	if len(report) != 2 {
+		t.Fatalf("expected 2 entries, got %v", len(report))
+	}
+	if report[0].RuntimeID != 11 || report[0].Count != 2 || report[1].RuntimeID != 10 || report[1].Count != 1 {
+		t.Errorf("expected the entry found most often first, got %+v", report)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	Reset()
+	for rid := uint32(0); rid <= maxEntries; rid++ {
+		Record(1, 2, rid)
+	}
+	report := Report()
+	if len(report) != maxEntries {
+		t.Fatalf("expected %v entries, got %v", maxEntries, len(report))
+	}
+	for _, e := range report {
+		if e.RuntimeID == 0 {
+			t.Fatal("expected the entry found least recently to be evicted")
+		}
+	}
+}
+
+func TestHandlerReset(t *testing.T) {
+	Reset()
+	Record(1, 2, 10)
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/mismatches", nil))
+	if rec.Code != http.StatusNoContent || len(Report()) != 0 {
+		t.Errorf("expected the report to be reset, got status %v and %v entries", rec.Code, len(Report()))
+	}
+}
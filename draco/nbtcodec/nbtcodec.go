@@ -0,0 +1,108 @@
+// Package nbtcodec encodes and decodes NBT compounds as found in packets and in worlds. Minecraft uses two little
+// endian encodings of NBT: packets, such as BlockActorData and the block entities following the sub chunks of a
+// LevelChunk, encode integers and lengths as varints, while worlds and most files encode them with a fixed size. The
+// package allows handlers rewriting NBT held by packets to use the right encoding without duplicating the subtleties
+// of either.
+package nbtcodec
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// Encoding is an encoding of NBT.
+type Encoding uint8
+
+const (
+	// Network is the encoding of NBT sent over the network, such as the NBT of BlockActorData packets and item stacks,
+	// and of the block entities following the sub chunks of LevelChunk and SubChunk packets. Ints, longs and the
+	// lengths of strings and lists are encoded as varints.
+	Network Encoding = iota
+	// Disk is the encoding of NBT stored on disk, such as in the LevelDB database of a world, level.dat files and
+	// block_states.nbt palettes, and of the block states in the palettes of sub chunks, also when sent over the
+	// network. All values are encoded with a fixed size in little endian.
+	Disk
+)
+
+// String ...
+func (e Encoding) String() string {
+	if e == Disk {
+		return "disk"
+	}
+	return "network"
+}
+
+// nbt returns the nbt.Encoding of the Encoding.
+func (e Encoding) nbt() nbt.Encoding {
+	if e == Disk {
+		return nbt.LittleEndian
+	}
+	return nbt.NetworkLittleEndian
+}
+
+// Decode decodes a single NBT compound from the data passed using the Encoding passed. An error is returned if the
+// data doesn't hold exactly one compound.
+func Decode(data []byte, e Encoding) (map[string]any, error) {
+	buf := bytes.NewBuffer(data)
+	var m map[string]any
+	if err := nbt.NewDecoderWithEncoding(buf, e.nbt()).Decode(&m); err != nil {
+		return nil, fmt.Errorf("decode %v NBT: %w", e, err)
+	}
+	if buf.Len() > 0 {
+		return nil, fmt.Errorf("decode %v NBT: %v unread bytes after compound", e, buf.Len())
+	}
+	return m, nil
+}
+
+// Encode encodes the NBT compound passed using the Encoding passed.
+func Encode(m map[string]any, e Encoding) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := nbt.NewEncoderWithEncoding(buf, e.nbt()).Encode(m); err != nil {
+		return nil, fmt.Errorf("encode %v NBT: %w", e, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeAll decodes all NBT compounds that follow each other in the data passed using the Encoding passed, such as
+// the block entities following the sub chunks of a LevelChunk packet.
+func DecodeAll(data []byte, e Encoding) ([]map[string]any, error) {
+	var compounds []map[string]any
+	buf := bytes.NewBuffer(data)
+	dec := nbt.NewDecoderWithEncoding(buf, e.nbt())
+	for buf.Len() > 0 {
+		var m map[string]any
+		if err := dec.Decode(&m); err != nil {
+			return nil, fmt.Errorf("decode %v NBT compound %v: %w", e, len(compounds), err)
+		}
+		compounds = append(compounds, m)
+	}
+	return compounds, nil
+}
+
+// EncodeAll encodes the NBT compounds passed after each other using the Encoding passed, so that they may be decoded
+// again using DecodeAll.
+func EncodeAll(compounds []map[string]any, e Encoding) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	enc := nbt.NewEncoderWithEncoding(buf, e.nbt())
+	for i, m := range compounds {
+		if err := enc.Encode(m); err != nil {
+			return nil, fmt.Errorf("encode %v NBT compound %v: %w", e, i, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Convert converts all NBT compounds in the data passed from the Encoding from to the Encoding to, such as to store
+// the block entities received in a LevelChunk packet in a world.
+func Convert(data []byte, from, to Encoding) ([]byte, error) {
+	if from == to {
+		return data, nil
+	}
+	compounds, err := DecodeAll(data, from)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeAll(compounds, to)
+}
@@ -0,0 +1,62 @@
+package nbtcodec
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	compounds := []map[string]any{
+		{"id": "Chest", "x": int32(1), "y": int32(-64), "z": int32(300), "isMovable": uint8(1)},
+		{"id": "Sign", "x": int32(2), "y": int32(70), "z": int32(-5), "Text": "hello", "TextOwner": int64(1 << 40)},
+	}
+	for _, e := range []Encoding{Network, Disk} {
+		data, err := EncodeAll(compounds, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := DecodeAll(data, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, compounds) {
+			t.Errorf("%v NBT did not survive a round trip: %v", e, decoded)
+		}
+		single, err := Encode(compounds[0], e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m, err := Decode(single, e); err != nil || !reflect.DeepEqual(m, compounds[0]) {
+			t.Errorf("single %v compound did not survive a round trip: %v, %v", e, m, err)
+		}
+		if _, err := Decode(data, e); err == nil {
+			t.Errorf("expected error decoding two %v compounds as one", e)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	m := map[string]any{"id": "Furnace", "x": int32(-1000), "BurnTime": int16(20)}
+	network, err := Encode(m, Network)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disk, err := Convert(network, Network, Disk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(disk, network) {
+		t.Fatal("network and disk NBT are encoded the same")
+	}
+	decoded, err := Decode(disk, Disk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("compound changed converting it: %v", decoded)
+	}
+	if _, err := Decode(network, Disk); err == nil {
+		t.Error("expected error decoding network NBT as disk NBT")
+	}
+}
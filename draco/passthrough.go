@@ -0,0 +1,98 @@
+package draco
+
+import (
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// PassthroughRange is the amount of protocol IDs above the latest protocol that PassthroughProtocols accepts. New
+// versions of the game raise the protocol ID by less than this, so that the next version released is covered.
+const PassthroughRange = 32
+
+// PassthroughProtocols returns protocols for the protocol IDs above the latest protocol, up to PassthroughRange
+// above it, which may be added to the AcceptedProtocols of a listener so that clients on a version released after
+// the newest version supported may still join on the day of its release, rather than being turned away until it is
+// supported. The packets of these clients are passed through as if they were of the latest protocol, which works as
+// long as the fields of the packets didn't change. Packets that changed are likely to be decoded wrongly, so this is
+// a stopgap, and a warning is logged for every client that joins with such a protocol and for packets unknown to the
+// latest protocol.
+func PassthroughProtocols() []minecraft.Protocol {
+	all := make([]minecraft.Protocol, 0, PassthroughRange)
+	for id := int32(protocol.CurrentProtocol) + PassthroughRange; id > protocol.CurrentProtocol; id-- {
+		all = append(all, passthroughProtocol(id))
+	}
+	return all
+}
+
+// ObservePassthrough inspects a packet read by a minecraft.Listener with the PassthroughProtocols and logs a warning
+// for every client that logs in with one of them. ObservePassthrough has the signature of
+// minecraft.ListenConfig.PacketFunc.
+func ObservePassthrough(header packet.Header, payload []byte, src, _ net.Addr) {
+	id, ok := LoginProtocol(header, payload)
+	if !ok || id <= protocol.CurrentProtocol || id > protocol.CurrentProtocol+PassthroughRange {
+		return
+	}
+	metrics.AddTo("passthrough_logins", strconv.Itoa(int(id)), 1)
+	logging.Default().Warn("client joined with protocol newer than supported, passing its packets through as the latest protocol", "address", src.String(), "protocol", id, "latest", protocol.CurrentProtocol)
+}
+
+// passthroughProtocol is a protocol newer than the latest protocol, of which packets are passed through as they are.
+type passthroughProtocol int32
+
+// ID ...
+func (p passthroughProtocol) ID() int32 {
+	return int32(p)
+}
+
+// Ver ...
+func (passthroughProtocol) Ver() string {
+	return protocol.CurrentVersion
+}
+
+// Packets ...
+func (passthroughProtocol) Packets() packet.Pool {
+	return packet.NewPool()
+}
+
+var (
+	// unknownMu guards unknown.
+	unknownMu sync.Mutex
+	// unknown holds the IDs of the packets unknown to the latest protocol that clients of passthrough protocols sent,
+	// keyed by their protocol, so that a warning is only logged the first time each of them is sent.
+	unknown = map[passthroughProtocol]map[uint32]struct{}{}
+)
+
+// ConvertToLatest ...
+func (p passthroughProtocol) ConvertToLatest(pk packet.Packet) packet.Packet {
+	if pk, ok := pk.(*packet.Unknown); ok {
+		p.warnUnknown(pk.PacketID)
+	}
+	return pk
+}
+
+// ConvertFromLatest ...
+func (passthroughProtocol) ConvertFromLatest(pk packet.Packet) packet.Packet {
+	return pk
+}
+
+// warnUnknown logs a warning the first time that a client of the protocol sends a packet with the ID passed, which
+// the latest protocol doesn't know.
+func (p passthroughProtocol) warnUnknown(id uint32) {
+	unknownMu.Lock()
+	defer unknownMu.Unlock()
+	if _, ok := unknown[p][id]; ok {
+		return
+	}
+	if unknown[p] == nil {
+		unknown[p] = map[uint32]struct{}{}
+	}
+	unknown[p][id] = struct{}{}
+	logging.Default().Warn("client with newer protocol sent packet unknown to the latest protocol", "protocol", int32(p), "packet", id)
+}
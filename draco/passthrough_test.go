@@ -0,0 +1,32 @@
+package draco
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestPassthroughProtocols(t *testing.T) {
+	all := PassthroughProtocols()
+	if len(all) != PassthroughRange {
+		t.Fatalf("expected %v protocols, got %v", PassthroughRange, len(all))
+	}
+	if all[0].ID() != protocol.CurrentProtocol+PassthroughRange || all[len(all)-1].ID() != protocol.CurrentProtocol+1 {
+		t.Errorf("expected protocols %v to %v, newest first, got %v to %v", protocol.CurrentProtocol+PassthroughRange, protocol.CurrentProtocol+1, all[0].ID(), all[len(all)-1].ID())
+	}
+
+	p := all[0]
+	text := &packet.Text{Message: "hello"}
+	if p.ConvertToLatest(text) != packet.Packet(text) || p.ConvertFromLatest(text) != packet.Packet(text) {
+		t.Error("expected packets to be passed through")
+	}
+	p.ConvertToLatest(&packet.Unknown{PacketID: 999})
+	p.ConvertToLatest(&packet.Unknown{PacketID: 999})
+	unknownMu.Lock()
+	_, ok := unknown[p.(passthroughProtocol)][999]
+	unknownMu.Unlock()
+	if !ok {
+		t.Error("expected the unknown packet to be recorded")
+	}
+}
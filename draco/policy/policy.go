@@ -0,0 +1,102 @@
+// Package policy holds the decode policy of the proxy, which decides what happens to packets that can't be decoded or
+// translated. The policy is shared by the translator, the chunk codec and the proxy, so that they apply it the same
+// way.
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Policy is a decode policy, set using Set.
+type Policy uint32
+
+const (
+	// Lenient keeps players connected when a packet can't be decoded or translated: blocks without an equivalent in
+	// the version of the client are replaced with the FallbackBlock and items with air, and packets that can't be
+	// decoded or translated at all are dropped. It is the default, meant for production.
+	Lenient Policy = iota
+	// Strict disconnects players as soon as a packet sent to or by them can't be decoded or translated, so that
+	// translation bugs surface immediately during development.
+	Strict
+	// Drop keeps players connected like Lenient, but drops packets holding blocks or items without an equivalent in
+	// the version of the client rather than sending them with substitutes, so that players never see blocks that the
+	// server didn't place.
+	Drop
+)
+
+var (
+	// current holds the Policy set using Set.
+	current uint32
+	// fallback holds the name of the block set using SetFallbackBlock.
+	fallback atomic.Value
+)
+
+// Set sets the decode Policy of the proxy.
+func Set(p Policy) {
+	atomic.StoreUint32(&current, uint32(p))
+}
+
+// Current returns the decode Policy currently set.
+func Current() Policy {
+	return Policy(atomic.LoadUint32(&current))
+}
+
+// Substitutes reports if blocks and items without an equivalent are substituted under the Policy, which is only the
+// case under Lenient. Under other policies, the packets holding them can't be translated.
+func (p Policy) Substitutes() bool {
+	return p == Lenient
+}
+
+// Disconnects reports if players are disconnected when a packet sent to or by them can't be translated under the
+// Policy, which is only the case under Strict. Under other policies, the packet is dropped.
+func (p Policy) Disconnects() bool {
+	return p == Strict
+}
+
+// String ...
+func (p Policy) String() string {
+	switch p {
+	case Strict:
+		return "strict"
+	case Drop:
+		return "drop"
+	}
+	return "lenient"
+}
+
+// Parse parses a Policy by its name, "lenient", "strict" or "drop", ignoring case. An empty name results in Lenient.
+func Parse(name string) (Policy, error) {
+	switch strings.ToLower(name) {
+	case "", "lenient":
+		return Lenient, nil
+	case "strict":
+		return Strict, nil
+	case "drop":
+		return Drop, nil
+	}
+	return Lenient, fmt.Errorf("unknown decode policy %q", name)
+}
+
+// DefaultFallbackBlock is the block that blocks without an equivalent are substituted with under the lenient policy if
+// no other block is set using SetFallbackBlock.
+const DefaultFallbackBlock = "minecraft:air"
+
+// SetFallbackBlock sets the name of the block, such as "minecraft:info_update", that blocks without an equivalent in
+// the version of a client are substituted with under the lenient policy. The block is substituted with its state
+// without properties, so it must be a block that has no properties. An empty name resets it to DefaultFallbackBlock.
+func SetFallbackBlock(name string) {
+	if name == "" {
+		name = DefaultFallbackBlock
+	}
+	fallback.Store(name)
+}
+
+// FallbackBlock returns the name of the block set using SetFallbackBlock.
+func FallbackBlock() string {
+	if name, ok := fallback.Load().(string); ok {
+		return name
+	}
+	return DefaultFallbackBlock
+}
@@ -0,0 +1,38 @@
+package policy
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	for name, expected := range map[string]Policy{"": Lenient, "lenient": Lenient, "Strict": Strict, "drop": Drop} {
+		p, err := Parse(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p != expected {
+			t.Errorf("%q parsed as %v, expected %v", name, p, expected)
+		}
+		if name != "" {
+			if back, _ := Parse(p.String()); back != p {
+				t.Errorf("%v did not survive a round trip through its name", p)
+			}
+		}
+	}
+	if _, err := Parse("sloppy"); err == nil {
+		t.Error("expected error parsing an unknown policy")
+	}
+}
+
+func TestFallbackBlock(t *testing.T) {
+	defer SetFallbackBlock("")
+	if name := FallbackBlock(); name != DefaultFallbackBlock {
+		t.Errorf("expected %v by default, got %v", DefaultFallbackBlock, name)
+	}
+	SetFallbackBlock("minecraft:info_update")
+	if name := FallbackBlock(); name != "minecraft:info_update" {
+		t.Errorf("expected the fallback block set, got %v", name)
+	}
+	SetFallbackBlock("")
+	if name := FallbackBlock(); name != DefaultFallbackBlock {
+		t.Errorf("expected %v after a reset, got %v", DefaultFallbackBlock, name)
+	}
+}
@@ -0,0 +1,225 @@
+// Package positions persists the last known positions of players, so that staff may look up where a player was when
+// it disconnected.
+package positions
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/proxy"
+)
+
+// saveInterval is the interval at which the positions recorded are saved to the file of a Store.
+const saveInterval = 10 * time.Second
+
+// Entry is the last known position of a player.
+type Entry struct {
+	// XUID is the XBOX Live user ID of the player and Name its gamertag.
+	XUID string `json:"xuid"`
+	Name string `json:"name"`
+	proxy.Position
+	// Online is true if the player is online, in which case the Entry holds its current position. It is never
+	// stored.
+	Online bool `json:"online"`
+	// Quit is the time at which the player left the proxy. It is zero while the player is online.
+	Quit time.Time `json:"quit,omitempty"`
+}
+
+// Store holds the last known positions of the players that left the proxy and persists them to a JSON file. The
+// position of a player is recorded whenever its Session is closed. A Store is safe for concurrent use.
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]Entry
+	dirty   bool
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+// Open opens the Store persisted in the file at the path passed, creating it if it does not exist, and starts
+// recording the positions of players when they leave.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, entries: map[string]Entry{}, closed: make(chan struct{})}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read positions: %w", err)
+	}
+	if len(data) > 0 {
+		var entries []Entry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("decode positions: %w", err)
+		}
+		for _, e := range entries {
+			s.entries[e.XUID] = e
+		}
+	}
+	go s.saveEvery(saveInterval)
+
+	proxy.OnClose(s.Record)
+	return s, nil
+}
+
+// Record records the Position of the Session passed as the last known position of its player. Guests, which have
+// no XUID, are not recorded.
+func (s *Store) Record(sess *proxy.Session) {
+	if sess.XUID() == "" {
+		return
+	}
+	e := Entry{XUID: sess.XUID(), Name: sess.Name(), Position: sess.Position(), Quit: time.Now()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[e.XUID] = e
+	s.dirty = true
+}
+
+// Lookup looks up the position of the player with the XUID passed, or with the gamertag passed, ignoring case, if
+// the XUID is empty. The current position is returned for players that are online, and the position recorded when
+// they left for players that are not. False is returned if the player is not online and never left the proxy.
+func (s *Store) Lookup(xuid, name string) (Entry, bool) {
+	for _, sess := range proxy.Sessions() {
+		if sess.XUID() != "" && ((xuid != "" && sess.XUID() == xuid) || (xuid == "" && strings.EqualFold(sess.Name(), name))) {
+			return Entry{XUID: sess.XUID(), Name: sess.Name(), Position: sess.Position(), Online: true}, true
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if xuid != "" {
+		e, ok := s.entries[xuid]
+		return e, ok
+	}
+	for _, e := range s.entries {
+		if strings.EqualFold(e.Name, name) {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Forget removes the position recorded of the player with the XUID passed, saving the Store right away rather than
+// with the next periodic save. False is returned if no position of the player was recorded.
+func (s *Store) Forget(xuid string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[xuid]; !ok {
+		return false, nil
+	}
+	delete(s.entries, xuid)
+	s.dirty = true
+	return true, s.saveLocked()
+}
+
+// Close saves the positions recorded and stops saving them periodically.
+func (s *Store) Close() error {
+	s.once.Do(func() {
+		close(s.closed)
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveLocked()
+}
+
+// saveEvery saves the positions recorded every interval passed until the Store is closed.
+func (s *Store) saveEvery(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.mu.Lock()
+			_ = s.saveLocked()
+			s.mu.Unlock()
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// saveLocked writes all entries to the file of the Store if any were recorded since it was last saved. The file is
+// replaced atomically, so that it is never left half written. s.mu must be held when calling saveLocked.
+func (s *Store) saveLocked() error {
+	if !s.dirty {
+		return nil
+	}
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Quit.Before(entries[j].Quit)
+	})
+	data, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		return fmt.Errorf("encode positions: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("save positions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save positions: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save positions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("save positions: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// API serves an HTTP API for looking up the positions in a Store. The secret of the API must be sent as a bearer
+// token in the Authorization header of all requests.
+type API struct {
+	store  *Store
+	secret string
+}
+
+// NewAPI returns an API for the Store passed, protected by the secret passed.
+func NewAPI(store *Store, secret string) *API {
+	return &API{store: store, secret: secret}
+}
+
+// ServeHTTP serves the API. It supports the following requests:
+//
+//	GET /positions?xuid=<xuid>|name=<name>  responds with the current position of a player, or its position when it left
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+a.secret)) != 1 {
+		http.Error(w, "unauthorised", http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path != "/positions" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	if q.Get("xuid") == "" && q.Get("name") == "" {
+		http.Error(w, "no xuid or name", http.StatusBadRequest)
+		return
+	}
+	e, ok := a.store.Lookup(q.Get("xuid"), q.Get("name"))
+	if !ok {
+		http.Error(w, "no position known", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(e)
+}
@@ -0,0 +1,67 @@
+package positions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "positions.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := Entry{XUID: "1", Name: "Steve", Position: proxy.Position{Position: mgl32.Vec3{1, 64, 2}, Dimension: 1, Backend: "lobby"}, Quit: time.Now()}
+	s.mu.Lock()
+	s.entries[e.XUID], s.dirty = e, true
+	s.mu.Unlock()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	got, ok := s.Lookup("", "steve")
+	if !ok || got.Position.Position != e.Position.Position || got.Dimension != 1 || got.Backend != "lobby" || got.Online {
+		t.Errorf("position after reopening: got %+v, expected %+v", got, e)
+	}
+	if _, ok := s.Lookup("2", ""); ok {
+		t.Error("found position of player that never joined")
+	}
+}
+
+func TestAPI(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "positions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	a := NewAPI(s, "secret")
+	for _, c := range []struct {
+		path, secret string
+		status       int
+	}{
+		{"/positions?xuid=1", "", http.StatusUnauthorized},
+		{"/positions", "secret", http.StatusBadRequest},
+		{"/positions?name=Nobody", "secret", http.StatusNotFound},
+	} {
+		r := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.secret != "" {
+			r.Header.Set("Authorization", "Bearer "+c.secret)
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("%v: got status %v, expected %v", c.path, w.Code, c.status)
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package draco
 
 import (
-	"bytes"
 	"fmt"
-	"github.com/cqdetdev/draco/draco/chunk"
+	"github.com/cqdetdev/draco/draco/biome"
+	"github.com/cqdetdev/draco/draco/blockentity"
+	"github.com/cqdetdev/draco/draco/item"
 	"github.com/cqdetdev/draco/draco/latestmappings"
-	"github.com/cqdetdev/draco/draco/legacy"
 	"github.com/cqdetdev/draco/draco/legacymappings"
+	"github.com/cqdetdev/draco/draco/metadata"
+	"github.com/cqdetdev/draco/draco/policy"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/cqdetdev/draco/draco/telemetry"
 	"github.com/df-mc/dragonfly/server/block/cube"
 	"github.com/sandertv/gophertunnel/minecraft"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
@@ -16,6 +20,9 @@ import (
 // Protocol is the protocol used to support the Minecraft 1.18.10 protocol (486).
 type Protocol struct {
 	minecraft.Protocol
+	// blocks holds the block palettes that runtime IDs are translated with. If nil, the palettes of state.Default are
+	// used. Protocols translating the custom blocks of a server are returned by WithCustomBlocks.
+	blocks *state.Registry
 }
 
 // ID ...
@@ -39,335 +46,213 @@ var (
 	// worldRange is hardcoded to the overworld world range.
 	// TODO: Dimensions support.
 	worldRange = cube.Range{-64, 319}
+	// identicalBlockPalettes is true if the block palettes of 1.18.30 and 1.18.10 are identical, in which case the
+	// payloads of chunks don't need to be translated and are forwarded verbatim.
+	identicalBlockPalettes = blockPalettesIdentical()
+	// identicalBiomes is true if the biomes of 1.18.30 and 1.18.10 have the same IDs, in which case the biomes of
+	// chunks don't need to be translated.
+	identicalBiomes = biomesIdentical()
 )
 
-// ConvertToLatest ...
-func (p Protocol) ConvertToLatest(pk packet.Packet) packet.Packet {
-	switch latest := pk.(type) {
-	case *packet.MobEquipment:
-		latest.NewItem.Stack = upgradeItemStack(latest.NewItem.Stack)
-	case *packet.PlayerAuthInput:
-		latest.ItemInteractionData.HeldItem.Stack = upgradeItemStack(latest.ItemInteractionData.HeldItem.Stack)
-	case *packet.InventoryTransaction:
-		actions := make([]protocol.InventoryAction, 0, len(latest.Actions))
-		for _, action := range latest.Actions {
-			action.OldItem.Stack = upgradeItemStack(action.OldItem.Stack)
-			action.NewItem.Stack = upgradeItemStack(action.NewItem.Stack)
-			actions = append(actions, action)
-		}
-		latest.Actions = actions
-		switch data := latest.TransactionData.(type) {
-		case *protocol.UseItemTransactionData:
-			data.HeldItem.Stack = upgradeItemStack(data.HeldItem.Stack)
-			data.BlockRuntimeID = upgradeBlockRuntimeID(data.BlockRuntimeID)
-		case *protocol.UseItemOnEntityTransactionData:
-			data.HeldItem.Stack = upgradeItemStack(data.HeldItem.Stack)
+// blockPalettesIdentical checks if every 1.18.30 block runtime ID translates to the same 1.18.10 runtime ID and
+// both palettes hold the same amount of block states.
+func blockPalettesIdentical() bool {
+	if latestmappings.StateCount() != legacymappings.StateCount() {
+		return false
+	}
+	for rid := uint32(0); rid < latestmappings.StateCount(); rid++ {
+		name, properties, _ := latestmappings.RuntimeIDToState(rid)
+		if legacyRID, ok := legacymappings.StateToRuntimeID(name, properties); !ok || legacyRID != rid {
+			return false
 		}
 	}
-	// TODO: more translators?
-	return pk
+	return true
 }
 
-// ConvertFromLatest ...
-func (p Protocol) ConvertFromLatest(pk packet.Packet) packet.Packet {
-	switch latest := pk.(type) {
-	case *packet.PacketViolationWarning:
-		fmt.Printf("Violation %d (%d): %v\n", latest.PacketID, latest.Severity, latest.ViolationContext)
-	case *packet.UpdateBlock:
-		latest.NewBlockRuntimeID = downgradeBlockRuntimeID(latest.NewBlockRuntimeID)
-	case *packet.SetActorData:
-		downgradeEntityMetadata(latest.EntityMetadata)
-	case *packet.AddActor:
-		downgradeEntityMetadata(latest.EntityMetadata)
-	case *packet.CraftingData:
-		recipes := make([]protocol.Recipe, 0, len(latest.Recipes))
-		for _, r := range latest.Recipes {
-			switch r := r.(type) {
-			case *protocol.ShapedRecipe:
-				r.Input, r.Output = downgradeRecipe(r.Input, r.Output)
-			case *protocol.ShapelessRecipe:
-				r.Input, r.Output = downgradeRecipe(r.Input, r.Output)
-			}
-			recipes = append(recipes, r)
-		}
-		latest.Recipes = recipes
-	case *packet.CreativeContent:
-		items := make([]protocol.CreativeItem, 0, len(latest.Items))
-		for _, it := range latest.Items {
-			it.Item = downgradeItemStack(it.Item)
-			items = append(items, it)
-		}
-		latest.Items = items
-	case *packet.InventoryContent:
-		items := make([]protocol.ItemInstance, 0, len(latest.Content))
-		for _, it := range latest.Content {
-			it.Stack = downgradeItemStack(it.Stack)
-			items = append(items, it)
-		}
-		latest.Content = items
-	case *packet.InventorySlot:
-		latest.NewItem.Stack = downgradeItemStack(latest.NewItem.Stack)
-	case *packet.AddPlayer:
-		earlier := &legacy.AddPlayer{
-			UUID:                    latest.UUID,
-			Username:                latest.Username,
-			EntityUniqueID:          latest.EntityUniqueID,
-			EntityRuntimeID:         latest.EntityRuntimeID,
-			PlatformChatID:          latest.PlatformChatID,
-			Position:                latest.Position,
-			Velocity:                latest.Velocity,
-			Pitch:                   latest.Pitch,
-			Yaw:                     latest.Yaw,
-			HeadYaw:                 latest.HeadYaw,
-			HeldItem:                latest.HeldItem,
-			EntityMetadata:          latest.EntityMetadata,
-			Flags:                   latest.Flags,
-			CommandPermissionLevel:  latest.CommandPermissionLevel,
-			ActionPermissions:       latest.ActionPermissions,
-			PermissionLevel:         latest.PermissionLevel,
-			CustomStoredPermissions: latest.CustomStoredPermissions,
-			PlayerUniqueID:          latest.PlayerUniqueID,
-			EntityLinks:             latest.EntityLinks,
-			DeviceID:                latest.DeviceID,
-			BuildPlatform:           latest.BuildPlatform,
-		}
-		earlier.HeldItem.Stack = downgradeItemStack(latest.HeldItem.Stack)
-		return earlier
-	case *packet.StartGame:
-		earlier := &legacy.StartGame{
-			EntityUniqueID:                 latest.EntityUniqueID,
-			EntityRuntimeID:                latest.EntityRuntimeID,
-			PlayerGameMode:                 latest.PlayerGameMode,
-			PlayerPosition:                 latest.PlayerPosition,
-			Pitch:                          latest.Pitch,
-			Yaw:                            latest.Yaw,
-			WorldSeed:                      int32(latest.WorldSeed),
-			SpawnBiomeType:                 latest.SpawnBiomeType,
-			UserDefinedBiomeName:           latest.UserDefinedBiomeName,
-			Dimension:                      latest.Dimension,
-			Generator:                      latest.Generator,
-			WorldGameMode:                  latest.WorldGameMode,
-			Difficulty:                     latest.Difficulty,
-			WorldSpawn:                     latest.WorldSpawn,
-			AchievementsDisabled:           latest.AchievementsDisabled,
-			DayCycleLockTime:               latest.DayCycleLockTime,
-			EducationEditionOffer:          latest.EducationEditionOffer,
-			EducationFeaturesEnabled:       latest.EducationFeaturesEnabled,
-			EducationProductID:             latest.EducationProductID,
-			RainLevel:                      latest.RainLevel,
-			LightningLevel:                 latest.LightningLevel,
-			ConfirmedPlatformLockedContent: latest.ConfirmedPlatformLockedContent,
-			MultiPlayerGame:                latest.MultiPlayerGame,
-			MultiPlayerCorrelationID:       latest.MultiPlayerCorrelationID,
-			LANBroadcastEnabled:            latest.LANBroadcastEnabled,
-			XBLBroadcastMode:               latest.XBLBroadcastMode,
-			CommandsEnabled:                latest.CommandsEnabled,
-			TexturePackRequired:            latest.TexturePackRequired,
-			GameRules:                      latest.GameRules,
-			Experiments:                    latest.Experiments,
-			ExperimentsPreviouslyToggled:   latest.ExperimentsPreviouslyToggled,
-			BonusChestEnabled:              latest.BonusChestEnabled,
-			StartWithMapEnabled:            latest.StartWithMapEnabled,
-			PlayerPermissions:              latest.PlayerPermissions,
-			ServerChunkTickRadius:          latest.ServerChunkTickRadius,
-			HasLockedBehaviourPack:         latest.HasLockedBehaviourPack,
-			HasLockedTexturePack:           latest.HasLockedTexturePack,
-			FromLockedWorldTemplate:        latest.FromLockedWorldTemplate,
-			MSAGamerTagsOnly:               latest.MSAGamerTagsOnly,
-			FromWorldTemplate:              latest.FromWorldTemplate,
-			WorldTemplateSettingsLocked:    latest.WorldTemplateSettingsLocked,
-			OnlySpawnV1Villagers:           latest.OnlySpawnV1Villagers,
-			BaseGameVersion:                latest.BaseGameVersion,
-			LimitedWorldWidth:              latest.LimitedWorldWidth,
-			LimitedWorldDepth:              latest.LimitedWorldDepth,
-			NewNether:                      latest.NewNether,
-			EducationSharedResourceURI:     latest.EducationSharedResourceURI,
-			ForceExperimentalGameplay:      latest.ForceExperimentalGameplay,
-			LevelID:                        latest.LevelID,
-			WorldName:                      latest.WorldName,
-			TemplateContentIdentity:        latest.TemplateContentIdentity,
-			Trial:                          latest.Trial,
-			PlayerMovementSettings:         latest.PlayerMovementSettings,
-			Time:                           latest.Time,
-			EnchantmentSeed:                latest.EnchantmentSeed,
-			Blocks:                         latest.Blocks,
-			ServerAuthoritativeInventory:   latest.ServerAuthoritativeInventory,
-			GameVersion:                    latest.GameVersion,
-			ServerBlockStateChecksum:       latest.ServerBlockStateChecksum,
-		}
-		for _, i := range latest.Items {
-			if oldRuntimeID, ok := legacymappings.ItemNameToRuntimeID(i.Name); ok {
-				earlier.Items = append(earlier.Items, protocol.ItemEntry{
-					Name:           i.Name,
-					RuntimeID:      int16(oldRuntimeID),
-					ComponentBased: i.ComponentBased,
-				})
-			}
+// biomesIdentical checks if every 1.18.30 biome has the same ID in 1.18.10 and both versions have the same amount of
+// biomes.
+func biomesIdentical() bool {
+	latest, earlier := biome.Biomes(latestmappings.Version), biome.Biomes(legacymappings.Version)
+	if len(latest) != len(earlier) {
+		return false
+	}
+	for _, b := range latest {
+		if id, ok := biome.ID(legacymappings.Version, b.Name); !ok || id != b.ID {
+			return false
 		}
-		return earlier
-	case *packet.LevelChunk:
-		if latest.SubChunkRequestMode == protocol.SubChunkRequestModeLegacy {
-			readBuf := bytes.NewBuffer(latest.RawPayload)
-			c, err := chunk.NetworkDecode(air, readBuf, int(latest.SubChunkCount), worldRange)
-			if err != nil {
-				panic(err)
-			}
-			for _, s := range c.Sub() {
-				downgradeSubChunk(s)
-			}
-
-			writeBuf, data := bytes.NewBuffer(nil), chunk.Encode(c, chunk.NetworkEncoding)
-			for i := range data.SubChunks {
-				_, _ = writeBuf.Write(data.SubChunks[i])
-			}
-			_, _ = writeBuf.Write(data.Biomes)
+	}
+	return true
+}
 
-			latest.RawPayload = append(writeBuf.Bytes(), readBuf.Bytes()...)
-		}
-	case *packet.SubChunk:
-		entries := make([]protocol.SubChunkEntry, 0, len(latest.SubChunkEntries))
-		for _, e := range latest.SubChunkEntries {
-			if e.Result == protocol.SubChunkResultSuccess {
-				var ind uint8
-				buf := bytes.NewBuffer(e.RawPayload)
-				s, err := chunk.DecodeSubChunk(air, worldRange, buf, &ind, chunk.NetworkEncoding)
-				if err != nil {
-					panic(err)
-				}
-				downgradeSubChunk(s)
-				serialisedSubChunk := chunk.EncodeSubChunk(s, chunk.NetworkEncoding, worldRange, int(ind))
-				e.RawPayload = append(serialisedSubChunk, buf.Bytes()...)
-			}
-			entries = append(entries, e)
-		}
-		latest.SubChunkEntries = entries
-	case *packet.AddVolumeEntity:
-		return &legacy.AddVolumeEntity{
-			EntityRuntimeID:    latest.EntityRuntimeID,
-			EntityMetadata:     latest.EntityMetadata,
-			EncodingIdentifier: latest.EncodingIdentifier,
-			InstanceIdentifier: latest.InstanceIdentifier,
-			EngineVersion:      latest.EngineVersion,
-		}
-	case *packet.RemoveVolumeEntity:
-		return &legacy.RemoveVolumeEntity{EntityRuntimeID: latest.EntityRuntimeID}
-	case *packet.SpawnParticleEffect:
-		return &legacy.SpawnParticleEffect{
-			Dimension:      latest.Dimension,
-			EntityUniqueID: latest.EntityUniqueID,
-			Position:       latest.Position,
-			ParticleName:   latest.ParticleName,
-		}
+// registry returns the state.Registry holding the block palettes that the Protocol translates runtime IDs with.
+func (p Protocol) registry() *state.Registry {
+	if p.blocks == nil {
+		return state.Default()
 	}
-	return pk
+	return p.blocks
 }
 
-// dataKeyVariant is used for falling blocks and fake texts. This is necessary for falling block runtime ID translation.
-const dataKeyVariant = 2
+// palettesIdentical checks if block runtime IDs translated using the state.Registry passed stay the same, which is
+// only known for the palettes of state.Default: custom blocks are sorted differently by both versions.
+func palettesIdentical(blocks *state.Registry) bool {
+	return identicalBlockPalettes && blocks == state.Default()
+}
+
+// IdenticalBlockPalettes returns true if chunks are forwarded without being translated, because the block palettes
+// and biomes of both versions are identical and no block entities need to be translated. Chunks of servers with
+// custom blocks are translated regardless, as both versions sort custom blocks differently.
+func IdenticalBlockPalettes() bool {
+	return identicalBlockPalettes && identicalBiomes && !translatesBlockEntities()
+}
 
-// downgradeSubChunk translates a 1.18.30 sub-chunk to a 1.18.12 one, updating all palette entries with the appropriate
-// runtime IDs.
-func downgradeSubChunk(s *chunk.SubChunk) {
-	for _, l := range s.Layers() {
-		l.Palette().Replace(downgradeBlockRuntimeID)
+// translatesBlockEntities checks if the block entities of 1.18.30 chunks must be translated to 1.18.10, in which case
+// chunks can't be forwarded verbatim even if the block palettes are identical.
+func translatesBlockEntities() bool {
+	return blockentity.Translates(latestmappings.Version, legacymappings.Version)
+}
+
+// MalformedPacket is returned by Protocol.ConvertToLatest in place of a packet sent by a client that can't be
+// translated, so that the proxy can apply the decode policy to it with the context of the client, rather than
+// gophertunnel dropping it silently.
+type MalformedPacket struct {
+	// Packet is the packet that couldn't be translated, which may be translated partially.
+	packet.Packet
+	// Err is the reason why the packet couldn't be translated.
+	Err error
+}
+
+// ConvertToLatest ...
+func (p Protocol) ConvertToLatest(pk packet.Packet) (converted packet.Packet) {
+	defer func() {
+		if r := recover(); r != nil {
+			converted = &MalformedPacket{Packet: pk, Err: fmt.Errorf("translate %T to latest protocol: %v", pk, r)}
+		}
+	}()
+	if t, ok := translator(pk.ID(), p.ID(), protocol.CurrentProtocol); ok {
+		return t(pk)
 	}
+	return translateUnits(pk, true, p.registry())
 }
 
-// downgradeBlockRuntimeID translates a 1.18.30 runtime ID to a 1.18.12 one.
-func downgradeBlockRuntimeID(latestRID uint32) uint32 {
-	name, properties, found := latestmappings.RuntimeIDToState(latestRID)
-	if !found {
-		panic(fmt.Errorf("downgrade block runtime id: could not find name for runtime id: %v", latestRID))
+// ConvertFromLatest ...
+func (p Protocol) ConvertFromLatest(pk packet.Packet) packet.Packet {
+	if t, ok := translator(pk.ID(), protocol.CurrentProtocol, p.ID()); ok {
+		return t(pk)
 	}
-	earlierRuntimeID, found := legacymappings.StateToRuntimeID(name, properties)
+	return translateUnits(pk, false, p.registry())
+}
+
+// dataKeyVariant is used for falling blocks and fake texts. This is necessary for falling block runtime ID translation.
+const dataKeyVariant = 2
+
+// downgradeBlockRuntimeID translates a 1.18.30 runtime ID to a 1.18.12 one using the palettes of the state.Registry
+// passed. Block states without an equivalent are translated to the fallback block under the lenient decode policy.
+func downgradeBlockRuntimeID(blocks *state.Registry, latestRID uint32) uint32 {
+	earlierRuntimeID, found := blocks.TranslateRuntimeID(latestmappings.Version, legacymappings.Version, latestRID)
 	if !found {
-		panic(fmt.Errorf("downgrade block runtime id: could not find runtime id for name: %v", name))
+		telemetry.RecordBlock(latestmappings.Version, legacymappings.Version, latestRID)
+		if policy.Current().Substitutes() {
+			fallback, _ := blocks.Fallback(legacymappings.Version)
+			return fallback
+		}
+		name := stateName(blocks, latestmappings.Version, latestRID)
+		panic(fmt.Errorf("downgrade block runtime id: could not find runtime id for runtime id %v (%v)", latestRID, name))
 	}
 	return earlierRuntimeID
 }
 
-// upgradeBlockRuntimeID translates a 1.18.12 block runtime ID to a 1.18.30 one.
-func upgradeBlockRuntimeID(id uint32) uint32 {
-	name, properties, found := legacymappings.RuntimeIDToState(id)
+// upgradeBlockRuntimeID translates a 1.18.12 block runtime ID to a 1.18.30 one using the palettes of the
+// state.Registry passed. Block states without an equivalent are translated to the fallback block under the lenient
+// decode policy.
+func upgradeBlockRuntimeID(blocks *state.Registry, id uint32) uint32 {
+	latestRuntimeID, found := blocks.TranslateRuntimeID(legacymappings.Version, latestmappings.Version, id)
 	if !found {
-		panic(fmt.Errorf("upgrade block runtime id: could not find name for runtime id: %v", id))
-	}
-	latestRuntimeID, found := latestmappings.StateToRuntimeID(name, properties)
-	if !found {
-		panic(fmt.Errorf("upgrade block runtime id: could not find runtime id for name: %v", name))
+		telemetry.RecordBlock(legacymappings.Version, latestmappings.Version, id)
+		if policy.Current().Substitutes() {
+			fallback, _ := blocks.Fallback(latestmappings.Version)
+			return fallback
+		}
+		name := stateName(blocks, legacymappings.Version, id)
+		panic(fmt.Errorf("upgrade block runtime id: could not find runtime id for runtime id %v (%v)", id, name))
 	}
 	return latestRuntimeID
 }
 
-// downgradeEntityMetadata translates a 1.18.30 entity metadata to a 1.18.12 one.
-func downgradeEntityMetadata(metadata map[uint32]any) {
-	if latestRID, ok := metadata[dataKeyVariant]; ok {
-		metadata[dataKeyVariant] = int32(downgradeBlockRuntimeID(uint32(latestRID.(int32))))
+// stateName returns the name of the block state with the runtime ID passed in the palette of the version passed, or
+// an empty string if the palette has no such block state.
+func stateName(blocks *state.Registry, v state.Version, rid uint32) string {
+	if p, ok := blocks.PaletteOf(v); ok {
+		if b, ok := p.State(rid); ok {
+			return b.Name
+		}
 	}
+	return ""
 }
 
-// downgradeRecipe downgrades a 1.18.30 recipe to a 1.18.12 one.
-func downgradeRecipe(latestInput []protocol.RecipeIngredientItem, latestOutput []protocol.ItemStack) ([]protocol.RecipeIngredientItem, []protocol.ItemStack) {
-	input := make([]protocol.RecipeIngredientItem, 0, len(latestInput))
-	output := make([]protocol.ItemStack, 0, len(latestOutput))
-	for _, i := range latestInput {
-		if i.Count > 0 {
-			i.NetworkID = downgradeItemRuntimeID(i.NetworkID)
-		}
-		input = append(input, i)
+// downgradeEntityMetadata translates a 1.18.30 entity metadata to a 1.18.12 one.
+func downgradeEntityMetadata(blocks *state.Registry, m map[uint32]any) {
+	if latestRID, ok := m[dataKeyVariant]; ok {
+		m[dataKeyVariant] = int32(downgradeBlockRuntimeID(blocks, uint32(latestRID.(int32))))
 	}
-	for _, o := range latestOutput {
-		output = append(output, downgradeItemStack(o))
+	metadata.Translate(latestmappings.Version, legacymappings.Version, m)
+}
+
+// upgradeEntityMetadata translates a 1.18.12 entity metadata to a 1.18.30 one.
+func upgradeEntityMetadata(blocks *state.Registry, m map[uint32]any) {
+	metadata.Translate(legacymappings.Version, latestmappings.Version, m)
+	if earlierRID, ok := m[dataKeyVariant]; ok {
+		m[dataKeyVariant] = int32(upgradeBlockRuntimeID(blocks, uint32(earlierRID.(int32))))
 	}
-	return input, output
 }
 
 // downgradeItemStack translates a 1.18.30 item stack to a 1.18.12 one, updating all palette entries with the appropriate
-// runtime IDs.
-func downgradeItemStack(st protocol.ItemStack) protocol.ItemStack {
-	if st.BlockRuntimeID > 0 {
-		st.BlockRuntimeID = int32(downgradeBlockRuntimeID(uint32(st.BlockRuntimeID)))
-	}
-	if st.HasNetworkID {
-		st.NetworkID = downgradeItemRuntimeID(st.NetworkID)
+// runtime IDs. Items without an equivalent are translated to air under the lenient decode policy.
+func downgradeItemStack(blocks *state.Registry, st protocol.ItemStack) protocol.ItemStack {
+	earlier, ok := item.TranslateStack(blocks, latestmappings.Version, legacymappings.Version, st)
+	if !ok {
+		if policy.Current().Substitutes() {
+			return protocol.ItemStack{}
+		}
+		panic(fmt.Errorf("downgrade item stack: could not translate item %v (block runtime id %v)", st.NetworkID, st.BlockRuntimeID))
 	}
-	return st
+	return earlier
 }
 
 // upgradeItemStack translates a 1.18.12 item stack to a 1.18.30 one, updating all palette entries with the appropriate
-// runtime IDs.
-func upgradeItemStack(st protocol.ItemStack) protocol.ItemStack {
-	if st.BlockRuntimeID > 0 {
-		st.BlockRuntimeID = int32(upgradeBlockRuntimeID(uint32(st.BlockRuntimeID)))
-	}
-	if st.HasNetworkID {
-		st.NetworkID = upgradeItemRuntimeID(st.NetworkID)
+// runtime IDs. Items without an equivalent are translated to air under the lenient decode policy.
+func upgradeItemStack(blocks *state.Registry, st protocol.ItemStack) protocol.ItemStack {
+	latest, ok := item.TranslateStack(blocks, legacymappings.Version, latestmappings.Version, st)
+	if !ok {
+		if policy.Current().Substitutes() {
+			return protocol.ItemStack{}
+		}
+		panic(fmt.Errorf("upgrade item stack: could not translate item %v (block runtime id %v)", st.NetworkID, st.BlockRuntimeID))
 	}
-	return st
+	return latest
 }
 
-// upgradeItemRuntimeID translates a 1.18.12 item runtime ID to a 1.18.30 one.
-func upgradeItemRuntimeID(latestRID int32) int32 {
-	name, found := legacymappings.ItemRuntimeIDToName(latestRID)
-	if !found {
-		panic(fmt.Errorf("upgrade item runtime id: could not find name for runtime id: %v", latestRID))
-	}
-	earlierRuntimeID, found := latestmappings.ItemNameToRuntimeID(name)
+// upgradeItemRuntimeID translates a 1.18.12 item runtime ID to a 1.18.30 one, or to air under the lenient decode
+// policy if the item has no equivalent.
+func upgradeItemRuntimeID(rid int32) int32 {
+	latestRuntimeID, found := item.TranslateRuntimeID(legacymappings.Version, latestmappings.Version, rid)
 	if !found {
-		panic(fmt.Errorf("upgrade item runtime id: could not find runtime id for name: %v", name))
+		if policy.Current().Substitutes() {
+			return 0
+		}
+		panic(fmt.Errorf("upgrade item runtime id: could not find runtime id for runtime id: %v", rid))
 	}
-	return earlierRuntimeID
+	return latestRuntimeID
 }
 
-// downgradeItemRuntimeID translates a 1.18.30 item runtime ID to a 1.18.12 one.
+// downgradeItemRuntimeID translates a 1.18.30 item runtime ID to a 1.18.12 one, or to air under the lenient decode
+// policy if the item has no equivalent.
 func downgradeItemRuntimeID(latestRID int32) int32 {
-	name, found := latestmappings.ItemRuntimeIDToName(latestRID)
+	earlierRuntimeID, found := item.TranslateRuntimeID(latestmappings.Version, legacymappings.Version, latestRID)
 	if !found {
-		panic(fmt.Errorf("downgrade item runtime id: could not find name for runtime id: %v", latestRID))
-	}
-	earlierRuntimeID, found := legacymappings.ItemNameToRuntimeID(name)
-	if !found {
-		panic(fmt.Errorf("downgrade item runtime id: could not find runtime id for name: %v", name))
+		if policy.Current().Substitutes() {
+			return 0
+		}
+		panic(fmt.Errorf("downgrade item runtime id: could not find runtime id for runtime id: %v", latestRID))
 	}
 	return earlierRuntimeID
 }
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Abilities holds the abilities of the player of a Session, as granted by the server that the Session is attached to
+// and as used by the client. The two may disagree: a server may allow a player to fly without the player flying.
+type Abilities struct {
+	// Flags holds the AdventureFlag flags granted by the server, such as packet.AdventureFlagAllowFlight.
+	Flags uint32
+	// CommandPermissionLevel, ActionPermissions and PermissionLevel hold the permissions granted by the server, before
+	// the Permissions of the Role of the player are applied.
+	CommandPermissionLevel, ActionPermissions, PermissionLevel uint32
+	// Flying is true if the client last reported that the player is flying.
+	Flying bool
+}
+
+// abilities is the authoritative view of the abilities of the player of a Session. Clients ignore the abilities that
+// a server sends while they are changing dimension, which the proxy does when transferring a Session, so that a
+// player would lose the ability to fly granted by the new server. The abilities of the server are therefore re-sent
+// once the client finished changing dimension.
+type abilities struct {
+	mu sync.Mutex
+	// server holds the last AdventureSettings of the player sent by the server, or nil if the server didn't send any
+	// yet. pending is true if the client ignored them.
+	server  *packet.AdventureSettings
+	pending bool
+	// flying is true if the client last reported that the player is flying.
+	flying bool
+}
+
+// Abilities returns the Abilities of the player of the Session. False is returned if the server that the Session is
+// attached to has not yet sent the abilities of the player.
+func (s *Session) Abilities() (Abilities, bool) {
+	s.abilities.mu.Lock()
+	defer s.abilities.mu.Unlock()
+	pk := s.abilities.server
+	if pk == nil {
+		return Abilities{Flying: s.abilities.flying}, false
+	}
+	return Abilities{
+		Flags:                  pk.Flags,
+		CommandPermissionLevel: pk.CommandPermissionLevel,
+		ActionPermissions:      pk.ActionPermissions,
+		PermissionLevel:        pk.PermissionLevel,
+		Flying:                 s.abilities.flying,
+	}, true
+}
+
+func init() {
+	Handle(ServerToClient, func(s *Session, pk *packet.AdventureSettings) Action {
+		if pk.PlayerUniqueID != s.entityIDs().clientUniqueID {
+			// The abilities of other players are only used to show their permission level in the player list.
+			return Forward
+		}
+		settings := *pk
+		s.abilities.mu.Lock()
+		defer s.abilities.mu.Unlock()
+		s.abilities.server = &settings
+		s.abilities.pending = s.world.changing()
+		return Forward
+	})
+	Handle(ClientToServer, func(s *Session, pk *packet.AdventureSettings) Action {
+		// Clients send their abilities when they start or stop flying.
+		s.abilities.mu.Lock()
+		defer s.abilities.mu.Unlock()
+		s.abilities.flying = pk.Flags&packet.AdventureFlagFlying != 0
+		return Forward
+	})
+}
+
+// reset forgets the abilities of the previous server that the Session was attached to. The client keeps flying until
+// the new server sends its abilities, as it would if it stayed on the same server.
+func (a *abilities) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.server, a.pending = nil, false
+}
+
+// changedWorld re-sends the abilities of the server to the client of the Session passed if the client ignored them
+// while it was changing dimension. It is called once the client finished all dimension changes of the proxy.
+func (a *abilities) changedWorld(s *Session) {
+	a.mu.Lock()
+	if !a.pending || a.server == nil {
+		a.mu.Unlock()
+		return
+	}
+	pk := *a.server
+	a.pending = false
+	a.mu.Unlock()
+
+	overridePermissions(s.Role(), &pk)
+	_ = s.client.WritePacket(&pk)
+}
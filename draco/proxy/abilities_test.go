@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestAbilitiesAfterTransfer(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	handle(s, ClientToServer, &packet.AdventureSettings{Flags: packet.AdventureFlagAllowFlight | packet.AdventureFlagFlying})
+	if a, ok := s.Abilities(); ok || !a.Flying {
+		t.Errorf("unexpected abilities %#v (known: %v), expected only flying", a, ok)
+	}
+
+	s.attached()
+	s.changeWorld(minecraft.GameData{Dimension: packet.DimensionOverworld})
+	handle(s, ServerToClient, &packet.AdventureSettings{Flags: packet.AdventureFlagAllowFlight})
+	if a, ok := s.Abilities(); !ok || a.Flags != packet.AdventureFlagAllowFlight {
+		t.Errorf("unexpected abilities %#v (known: %v) of the server", a, ok)
+	}
+
+	conn.packets = nil
+	done := &packet.PlayerAction{ActionType: protocol.PlayerActionDimensionChangeDone}
+	handle(s, ClientToServer, done)
+	if len(conn.packets) != 0 {
+		t.Fatalf("abilities re-sent before the client finished changing dimension")
+	}
+	handle(s, ClientToServer, done)
+	if len(conn.packets) != 1 {
+		t.Fatalf("%v packets sent after changing dimension, expected the abilities of the server", len(conn.packets))
+	}
+	if pk, ok := conn.packets[0].(*packet.AdventureSettings); !ok || pk.Flags != packet.AdventureFlagAllowFlight {
+		t.Errorf("unexpected packet %#v re-sent", conn.packets[0])
+	}
+
+	// Abilities received once the client finished changing dimension are not ignored, so they are not re-sent.
+	handle(s, ServerToClient, &packet.AdventureSettings{})
+	s.abilities.changedWorld(s)
+	if len(conn.packets) != 1 {
+		t.Errorf("abilities re-sent although the client received them")
+	}
+}
@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// AdaptiveView configures the adaptive view distance, which reduces the chunk radius of players whose connection to
+// the proxy degrades and raises it again once it recovers, so that the game stays responsive on poor connections
+// without players having to enable potato mode themselves.
+type AdaptiveView struct {
+	// Enabled specifies if the view distance of players is adapted to the quality of their connection.
+	Enabled bool
+	// MinRadius is the chunk radius that the view distance of a player is never reduced below. If 0, it is 4.
+	MinRadius int32
+	// MaxLoss is the share of datagrams sent to a player over the last minute, from 0 to 1, that may have to be sent
+	// again before the connection is considered degraded. If 0, it is 0.05.
+	MaxLoss float64
+	// MaxInFlight is the amount of datagrams sent to a player that may be unacknowledged before the connection is
+	// considered degraded, which is the case if the bandwidth of the player can't keep up. If 0, it is 512.
+	MaxInFlight int
+}
+
+const (
+	// adaptiveDegradedSamples is the amount of consecutive samples of a degraded connection after which the view
+	// distance is reduced, and adaptiveHealthySamples the amount of consecutive samples of a healthy connection after
+	// which it is raised again.
+	adaptiveDegradedSamples = 3
+	adaptiveHealthySamples  = 30
+	// adaptiveStep is the amount of chunks that the view distance is reduced by at once.
+	adaptiveStep = 2
+)
+
+var (
+	// adaptiveMu guards adaptiveConf.
+	adaptiveMu sync.RWMutex
+	// adaptiveConf is the AdaptiveView currently used.
+	adaptiveConf = AdaptiveView{MinRadius: 4, MaxLoss: 0.05, MaxInFlight: 512}
+)
+
+// SetAdaptiveView sets the AdaptiveView used for all sessions.
+func SetAdaptiveView(a AdaptiveView) {
+	if a.MinRadius == 0 {
+		a.MinRadius = 4
+	}
+	if a.MaxLoss == 0 {
+		a.MaxLoss = 0.05
+	}
+	if a.MaxInFlight == 0 {
+		a.MaxInFlight = 512
+	}
+	adaptiveMu.Lock()
+	defer adaptiveMu.Unlock()
+	adaptiveConf = a
+}
+
+// adaptiveView returns the AdaptiveView currently used.
+func adaptiveView() AdaptiveView {
+	adaptiveMu.RLock()
+	defer adaptiveMu.RUnlock()
+	return adaptiveConf
+}
+
+// adaptiveRadius holds the adaptive view distance state of a single Session.
+type adaptiveRadius struct {
+	mu sync.Mutex
+	// max is the chunk radius that the view distance of the player is currently reduced to, or 0 if it isn't.
+	max int32
+	// degraded and healthy are the amounts of consecutive samples of the connection that were degraded and healthy.
+	degraded, healthy int
+	// limit is the chunk radius set using Session.SetMaxChunkRadius, or 0 if none was set.
+	limit int32
+}
+
+// AdaptiveRadius returns the chunk radius that the view distance of the player of the Session was reduced to because
+// of the quality of its connection. False is returned if the view distance is not currently reduced.
+func (s *Session) AdaptiveRadius() (int32, bool) {
+	s.adaptive.mu.Lock()
+	defer s.adaptive.mu.Unlock()
+	return s.adaptive.max, s.adaptive.max != 0
+}
+
+// SetMaxChunkRadius limits the chunk radius of the player of the Session to the radius passed, regardless of the
+// view distance it requests, such as for players assigned a view distance by a Rule. If 0, the chunk radius is no
+// longer limited. The chunk radius is requested from the server again if the client already requested one.
+func (s *Session) SetMaxChunkRadius(radius int32) {
+	s.adaptive.mu.Lock()
+	s.adaptive.limit = radius
+	s.adaptive.mu.Unlock()
+
+	s.potato.mu.Lock()
+	requested := s.potato.radius
+	s.potato.mu.Unlock()
+	if requested == 0 {
+		return
+	}
+	if radius := s.chunkRadius(requested); radius != 0 {
+		_ = s.Server().WritePacket(&packet.RequestChunkRadius{ChunkRadius: radius})
+	}
+}
+
+// adapt adapts the view distance of the player of the Session to the LinkStats of its connection passed, which are
+// sampled every qualityInterval. The chunk radius is requested from the server again if it changed.
+func (s *Session) adapt(stats LinkStats) {
+	conf := adaptiveView()
+	if !conf.Enabled {
+		return
+	}
+	s.potato.mu.Lock()
+	requested := s.potato.radius
+	s.potato.mu.Unlock()
+	if requested == 0 {
+		// The client didn't request a chunk radius yet, so there is nothing to adapt.
+		return
+	}
+	if !s.adaptive.observe(stats, requested, conf) {
+		return
+	}
+	if radius := s.chunkRadius(requested); radius != 0 {
+		_ = s.Server().WritePacket(&packet.RequestChunkRadius{ChunkRadius: radius})
+	}
+}
+
+// observe updates the maximum chunk radius of the adaptiveRadius for a connection with the LinkStats passed, of a
+// client that requested the chunk radius passed. True is returned if the maximum changed.
+func (a *adaptiveRadius) observe(stats LinkStats, requested int32, conf AdaptiveView) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	current := requested
+	if a.max != 0 && a.max < requested {
+		current = a.max
+	}
+	switch {
+	case stats.Loss > conf.MaxLoss || stats.InFlight > conf.MaxInFlight:
+		a.degraded, a.healthy = a.degraded+1, 0
+		if a.degraded < adaptiveDegradedSamples || current <= conf.MinRadius {
+			return false
+		}
+		// The loss is measured over the last minute, so it only drops slowly once the view distance was reduced.
+		// The view distance is therefore only reduced further after the same amount of samples again.
+		a.degraded = 0
+		a.max = current - adaptiveStep
+		if a.max < conf.MinRadius {
+			a.max = conf.MinRadius
+		}
+		metrics.AddTo("adaptive_view_changes", "reduced", 1)
+		return true
+	case stats.Loss <= conf.MaxLoss/2 && stats.InFlight <= conf.MaxInFlight/2:
+		a.degraded, a.healthy = 0, a.healthy+1
+		if a.max == 0 || a.healthy < adaptiveHealthySamples {
+			return false
+		}
+		a.healthy = 0
+		if a.max++; a.max >= requested {
+			a.max = 0
+		}
+		metrics.AddTo("adaptive_view_changes", "raised", 1)
+		return true
+	}
+	// The connection is neither degraded nor healthy enough to raise the view distance again.
+	a.degraded, a.healthy = 0, 0
+	return false
+}
+
+// chunkRadius returns the chunk radius requested from the server for a client that requested the chunk radius
+// passed, reduced by potato mode and the adaptive view distance. The caller must not hold the mutex of either.
+func (s *Session) chunkRadius(requested int32) int32 {
+	s.potato.mu.Lock()
+	enabled := s.potato.enabled
+	s.potato.mu.Unlock()
+	return s.limitChunkRadius(requested, enabled)
+}
+
+// limitChunkRadius reduces the chunk radius passed to the maximum of potato mode if enabled is true, to the maximum
+// of the adaptive view distance and to the maximum set using SetMaxChunkRadius.
+func (s *Session) limitChunkRadius(radius int32, potatoEnabled bool) int32 {
+	s.adaptive.mu.Lock()
+	limit := s.adaptive.limit
+	s.adaptive.mu.Unlock()
+	if limit > 0 && (radius == 0 || radius > limit) {
+		radius = limit
+	}
+	if max := potatoMode().ChunkRadius; potatoEnabled && (radius == 0 || radius > max) {
+		radius = max
+	}
+	if max, ok := s.AdaptiveRadius(); ok && (radius == 0 || radius > max) {
+		radius = max
+	}
+	return radius
+}
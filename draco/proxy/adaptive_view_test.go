@@ -0,0 +1,57 @@
+package proxy
+
+import "testing"
+
+func TestAdaptiveRadius(t *testing.T) {
+	conf := AdaptiveView{Enabled: true, MinRadius: 4, MaxLoss: 0.05, MaxInFlight: 512}
+	var a adaptiveRadius
+	degraded, healthy := LinkStats{Loss: 0.2}, LinkStats{Loss: 0.01}
+
+	for i := 0; i < adaptiveDegradedSamples-1; i++ {
+		if a.observe(degraded, 10, conf) {
+			t.Fatal("view distance reduced before the connection was degraded long enough")
+		}
+	}
+	if !a.observe(degraded, 10, conf) || a.max != 8 {
+		t.Fatalf("expected view distance to be reduced to 8, got %v", a.max)
+	}
+	for i := 0; i < 10*adaptiveDegradedSamples; i++ {
+		a.observe(LinkStats{InFlight: 1000}, 10, conf)
+	}
+	if a.max != conf.MinRadius {
+		t.Fatalf("expected view distance to be reduced to the minimum of %v, got %v", conf.MinRadius, a.max)
+	}
+
+	a.observe(LinkStats{Loss: 0.04}, 10, conf)
+	for i := 0; i < adaptiveHealthySamples-1; i++ {
+		if a.observe(healthy, 10, conf) {
+			t.Fatal("view distance raised before the connection was healthy long enough")
+		}
+	}
+	if !a.observe(healthy, 10, conf) || a.max != 5 {
+		t.Fatalf("expected view distance to be raised to 5, got %v", a.max)
+	}
+	for i := 0; i < 10*adaptiveHealthySamples; i++ {
+		a.observe(healthy, 10, conf)
+	}
+	if a.max != 0 {
+		t.Fatalf("expected view distance to be restored, got a maximum of %v", a.max)
+	}
+}
+
+func TestLimitChunkRadius(t *testing.T) {
+	s := &Session{}
+	if r := s.limitChunkRadius(12, false); r != 12 {
+		t.Errorf("chunk radius limited to %v without potato mode or adaptive view distance", r)
+	}
+	if r := s.limitChunkRadius(12, true); r != potatoMode().ChunkRadius {
+		t.Errorf("chunk radius limited to %v in potato mode, expected %v", r, potatoMode().ChunkRadius)
+	}
+	s.adaptive.max = 6
+	if r := s.limitChunkRadius(12, false); r != 6 {
+		t.Errorf("chunk radius limited to %v, expected the adaptive maximum of 6", r)
+	}
+	if r := s.limitChunkRadius(12, true); r != potatoMode().ChunkRadius {
+		t.Errorf("chunk radius limited to %v in potato mode, expected %v", r, potatoMode().ChunkRadius)
+	}
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Atmosphere overrides the fog and weather that clients render, regardless of what their backend sends, such as to
+// keep the sky clear in a lobby. Atmospheres are applied as persistent UI, so they survive transfers.
+type Atmosphere struct {
+	// Backend is the name of the backend that the Atmosphere applies to, ignoring case. If empty, it applies to all
+	// backends.
+	Backend string
+	// Roles holds the roles of the players that the Atmosphere applies to. If empty, it applies to all players.
+	Roles []Role
+	// Fog is the fog stack shown to players, such as ["minecraft:fog_ocean"], which replaces the fog stack sent by
+	// the backend. Fog identifiers are defined by resource packs, so a resource pack defining a fog without any
+	// distance fog can clear the fog entirely. If empty, the fog of the backend is kept.
+	Fog []string
+	// ClearWeather stops rain and thunder for players and drops the weather events sent by the backend.
+	ClearWeather bool
+}
+
+var (
+	// atmosphereMu guards atmospheres.
+	atmosphereMu sync.RWMutex
+	// atmospheres holds the atmospheres set using SetAtmospheres.
+	atmospheres []Atmosphere
+)
+
+// SetAtmospheres sets the atmospheres applied to players, replacing those set before. Only the first Atmosphere that
+// applies to the backend and role of a player is applied to it. Players online are updated the next time their UI
+// is refreshed, such as when they transfer or when Session.RefreshUI is called.
+func SetAtmospheres(a []Atmosphere) {
+	atmosphereMu.Lock()
+	defer atmosphereMu.Unlock()
+	atmospheres = append([]Atmosphere(nil), a...)
+}
+
+// atmosphere returns the Atmosphere applied to the Session passed. False is returned if none applies.
+func (s *Session) atmosphere() (Atmosphere, bool) {
+	backend, role := s.Backend().Name, s.Role()
+	atmosphereMu.RLock()
+	defer atmosphereMu.RUnlock()
+	for _, a := range atmospheres {
+		if a.Backend != "" && !strings.EqualFold(a.Backend, backend) {
+			continue
+		}
+		if len(a.Roles) > 0 && !hasRole(a.Roles, role) {
+			continue
+		}
+		return a, true
+	}
+	return Atmosphere{}, false
+}
+
+// hasRole checks if the roles passed hold the Role r.
+func hasRole(roles []Role, r Role) bool {
+	for _, role := range roles {
+		if role == r {
+			return true
+		}
+	}
+	return false
+}
+
+// atmosphereState holds the Atmosphere last applied to a single Session.
+type atmosphereState struct {
+	mu sync.Mutex
+	// overridden specifies if the fog stack of the client was overridden, so that it is reset once no Atmosphere
+	// with a fog applies anymore.
+	overridden bool
+}
+
+// atmospherePackets returns the packets that apply the Atmosphere of the Session to its client.
+func (s *Session) atmospherePackets() []packet.Packet {
+	a, _ := s.atmosphere()
+	s.fog.mu.Lock()
+	defer s.fog.mu.Unlock()
+	var pks []packet.Packet
+	switch {
+	case len(a.Fog) > 0:
+		pks = append(pks, &packet.PlayerFog{Stack: append([]string(nil), a.Fog...)})
+		s.fog.overridden = true
+	case s.fog.overridden:
+		// The client keeps the fog stack sent last, so it is reset to the default fog.
+		pks = append(pks, &packet.PlayerFog{})
+		s.fog.overridden = false
+	}
+	if a.ClearWeather {
+		pks = append(pks, &packet.LevelEvent{EventType: packet.LevelEventStopRaining}, &packet.LevelEvent{EventType: packet.LevelEventStopThunderstorm})
+	}
+	return pks
+}
+
+func init() {
+	RegisterUI("atmosphere", (*Session).atmospherePackets)
+
+	Handle(ServerToClient, func(s *Session, pk *packet.PlayerFog) Action {
+		if a, ok := s.atmosphere(); ok && len(a.Fog) > 0 {
+			return Drop
+		}
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.LevelEvent) Action {
+		switch pk.EventType {
+		case packet.LevelEventStartRaining, packet.LevelEventStartThunderstorm:
+			if a, ok := s.atmosphere(); ok && a.ClearWeather {
+				return Drop
+			}
+		}
+		return Forward
+	})
+}
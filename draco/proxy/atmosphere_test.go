@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestAtmosphere(t *testing.T) {
+	SetAtmospheres([]Atmosphere{
+		{Backend: "Lobby", Fog: []string{"test:clear"}, ClearWeather: true},
+		{Roles: []Role{RoleGuest}, ClearWeather: true},
+	})
+	defer SetAtmospheres(nil)
+
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{Name: "lobby"})
+	defer s.close()
+	pks := s.atmospherePackets()
+	if len(pks) != 3 {
+		t.Fatalf("expected the fog and two weather events to be sent, got %v packets", len(pks))
+	}
+	if fog, ok := pks[0].(*packet.PlayerFog); !ok || len(fog.Stack) != 1 || fog.Stack[0] != "test:clear" {
+		t.Errorf("expected the fog of the atmosphere to be sent, got %#v", pks[0])
+	}
+	if handle(s, ServerToClient, &packet.PlayerFog{Stack: []string{"minecraft:fog_hell"}}) != Drop {
+		t.Error("expected the fog of the backend to be dropped")
+	}
+	if handle(s, ServerToClient, &packet.LevelEvent{EventType: packet.LevelEventStartRaining}) != Drop {
+		t.Error("expected rain of the backend to be dropped")
+	}
+
+	// On a backend without an atmosphere, the fog of the client is reset.
+	s.connMu.Lock()
+	s.backend = Backend{Name: "game"}
+	s.connMu.Unlock()
+	pks = s.atmospherePackets()
+	if len(pks) != 1 {
+		t.Fatalf("expected the fog to be reset, got %v packets", len(pks))
+	}
+	if fog, ok := pks[0].(*packet.PlayerFog); !ok || len(fog.Stack) != 0 {
+		t.Errorf("expected an empty fog stack, got %#v", pks[0])
+	}
+	if len(s.atmospherePackets()) != 0 {
+		t.Error("expected no packets once the fog was reset")
+	}
+	if handle(s, ServerToClient, &packet.PlayerFog{}) != Forward || handle(s, ServerToClient, &packet.LevelEvent{EventType: packet.LevelEventStartRaining}) != Forward {
+		t.Error("expected fog and weather of a backend without an atmosphere to be forwarded")
+	}
+}
@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Source is a source of packets that a Session may be attached to instead of a backend server, such as a
+// minigame engine running inside the process embedding the proxy or a player replaying a recorded session.
+// Packets returned by ReadPacket are sent to the client and packets sent by the client are passed to WritePacket,
+// after passing through the handlers registered using Handle like the packets of a backend. A Source may receive
+// packets it does not know, such as the probes of the proxy, which it should ignore.
+type Source interface {
+	// ReadPacket reads the next packet to send to the client, blocking until one is available.
+	ReadPacket() (packet.Packet, error)
+	// WritePacket handles a packet sent by the client. It may be called from multiple goroutines simultaneously.
+	WritePacket(pk packet.Packet) error
+	// Close closes the Source. It is called when the Session is closed or attached to another server, and must
+	// make any pending call to ReadPacket return.
+	Close() error
+}
+
+// errSessionClosed is returned by Attach if the Session was closed.
+var errSessionClosed = errors.New("session is closed")
+
+// Attach attaches the Session to the connection to a backend server passed, which must already be spawned, so that
+// packets are forwarded between the client and the new server from then on. The connection to the server that the
+// Session was attached to before is closed, and its slot on the previous Backend is released. ErrBackendFull is
+// returned if the Backend passed reached its maximum amount of players, in which case the Session stays attached to
+// its current server.
+//
+// The entities, player list entries and scoreboard objectives of the previous server are removed from the client,
+// but the client is not told that it changed servers otherwise: the caller is responsible for making the world of
+// the client consistent with the new server, for example by changing the dimension of the client to clear the chunks
+// of the previous server. Transfer does so for backends.
+func (s *Session) Attach(server Conn, b Backend) error {
+	limitMu.Lock()
+	err := reserveBackend(b)
+	limitMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.connMu.Lock()
+	select {
+	case <-s.closed:
+		s.connMu.Unlock()
+		limitMu.Lock()
+		releaseBackend(b)
+		limitMu.Unlock()
+		return errSessionClosed
+	default:
+	}
+	previous, previousBackend := s.server, s.backend
+	clock := newWorldClock(b, server)
+	s.server, s.backend, s.clock, s.chunks = server, b, clock, newChunkTranslator(b, server)
+	s.ids = newEntityIDs(s.client, server)
+	s.breaking = newBreakingBridge(s.client, server)
+	s.connMu.Unlock()
+	if data, ok := gameData(server); ok {
+		// The client keeps the custom blocks of the server it was started with, so the runtime IDs of the custom
+		// blocks of the new server are translated to those.
+		if err := SetServerBlocks(s.client, data.CustomBlocks); err != nil {
+			s.Logger().Error("error applying custom blocks of backend", "backend", b.Name, "err", err)
+		}
+	}
+
+	// The server is swapped before the previous one is closed, so that the forwarding goroutines know to continue
+	// with the new server rather than closing the Session.
+	_ = previous.Close()
+	limitMu.Lock()
+	releaseBackend(previousBackend)
+	limitMu.Unlock()
+	if clock != nil {
+		go clock.run(s)
+	}
+	emit(TransferEvent{Session: s, From: previousBackend, To: b})
+	return nil
+}
+
+// AttachSource attaches the Session to the Source passed, like Attach. name is the name of the Backend that the
+// Session reports being connected to while attached to the Source.
+func (s *Session) AttachSource(src Source, name string) error {
+	return s.Attach(&sourceConn{Source: src, client: s.client}, Backend{Name: name})
+}
+
+// attached resets the state that the Session kept for the previous server it was attached to. It is called by the
+// goroutine forwarding packets from the server once it notices that the Session was attached to another one.
+func (s *Session) attached() {
+	s.updates.flush()
+	for id := range s.bossBars {
+		// Boss bars of the previous server would otherwise never be hidden.
+		_ = s.client.WritePacket(&packet.BossEvent{BossEntityUniqueID: id, EventType: packet.BossEventHide})
+		delete(s.bossBars, id)
+	}
+	s.probe.reset()
+	s.pings.backend.reset()
+	s.world.clear(s)
+	s.abilities.reset()
+	s.inventory.reset()
+	if data, ok := s.takeTransfer(); ok {
+		s.changeWorld(data)
+	}
+	s.restoreUI()
+}
+
+// reset resets the backendProbe for a new backend. The new backend is not considered dead until it answers a
+// probe, like a backend that a Session was started with.
+func (p *backendProbe) reset() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = map[int64]time.Time{}
+	p.answered, p.latency, p.lastSeen = false, 0, time.Now()
+}
+
+// sourceConn implements Conn for a Source. The player logged in over it is the player of the client.
+type sourceConn struct {
+	Source
+	client ClientConn
+}
+
+// IdentityData ...
+func (c *sourceConn) IdentityData() login.IdentityData {
+	return c.client.IdentityData()
+}
+
+// ClientData ...
+func (c *sourceConn) ClientData() login.ClientData {
+	return c.client.ClientData()
+}
+
+// Latency ...
+func (c *sourceConn) Latency() time.Duration {
+	return 0
+}
@@ -0,0 +1,45 @@
+package proxy
+
+// Backend is a server that the proxy forwards players to.
+type Backend struct {
+	// Name is the name that the backend is identified by.
+	Name string
+	// Address is the address of the backend, such as "127.0.0.1:19132".
+	Address string
+	// MaxPlayers is the maximum amount of players that the proxy forwards to the backend at the same time. Players
+	// joining the backend once the limit is reached are disconnected. If 0, the proxy imposes no limit.
+	MaxPlayers int
+	// Offline specifies if the backend runs without XBOX Live authentication, such as a development server. Players
+	// are forwarded to it without an XBL token, with the identity they logged in to the proxy with.
+	Offline bool
+	// Accounts holds the names of the XBOX Live accounts that the proxy signs in to the backend with, which are
+	// configured in Accounts of config.toml. Players are spread over the accounts in turn, which allows a pool of
+	// accounts to be used for a backend. If empty, the default account is used. Accounts is ignored if the backend is
+	// Offline.
+	Accounts []string
+	// Time controls the time of day that players see on the backend. By default, the time of the backend is shown
+	// as it is.
+	Time WorldTime
+	// Chunks specifies the way that chunks of the backend are sent to players, which allows chunks to be sent using
+	// sub chunk requests even if the backend sends full chunks, or the other way around. By default, chunks are
+	// sent the way the backend sends them.
+	Chunks ChunkMode
+	// CacheChunks specifies if the proxy keeps the chunks that the backend sends to players, so that its world can be
+	// exported using CachedWorld, such as to capture the build of a lobby.
+	CacheChunks bool
+	// ProxyProtocol specifies if the address that players connected to the proxy from is sent to the backend using
+	// version 2 of the HAProxy PROXY protocol, which prefixes every datagram sent to the backend with a header. The
+	// backend must expect these headers. Pings sent to the backend to check if it is up are sent without them.
+	ProxyProtocol bool
+	// ClientCache specifies if the client blob cache is enabled for the backend, for players whose client has it
+	// enabled. The backend then sends sub chunks and biomes as blobs that clients keep across sessions, so that they
+	// only download the parts of the world that changed. Chunks sent using the cache are not cached or re-chunked by
+	// the proxy, so Chunks and CacheChunks have no effect on them.
+	ClientCache bool
+	// ResourcePacks is a list of paths to resource packs that the proxy sends to clients for the backend, in addition
+	// to Connection.ResourcePacks of config.toml. Clients only accept resource packs when they join the proxy, before
+	// a backend is dialed for them, and keep them until they leave, so the packs are sent to the clients joining
+	// listeners that route players to the backend, and other clients can't be transferred to it. Changes only apply
+	// once the proxy is restarted.
+	ResourcePacks []string
+}
@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// healthDecay is the weight of a new observation in the moving averages of the round trip time and error rate of a
+	// backend, so that a backend that recovers is trusted again after a few successful dials.
+	healthDecay = 0.2
+	// referenceRTT is the round trip time at which the weight of a backend is halved compared to a backend next to
+	// the proxy.
+	referenceRTT = 50 * time.Millisecond
+	// minWeight is the minimum weight of a backend, so that a backend with many errors still receives an occasional
+	// player, through which its recovery is noticed.
+	minWeight = 0.01
+)
+
+// backendHealth holds the moving averages of the round trip time from the proxy to a backend and of the share of
+// dials and probes of the backend that failed.
+type backendHealth struct {
+	rtt       time.Duration
+	errorRate float64
+}
+
+var (
+	// healthMu guards health.
+	healthMu sync.Mutex
+	// health holds the backendHealth of all backends observed using ObserveBackend, keyed by their lowercase name.
+	health = map[string]*backendHealth{}
+)
+
+// ObserveBackend records an observation of the connection from the proxy to the Backend passed, such as the outcome
+// of dialing it: err is the error that the connection failed with, or nil if it succeeded, in which case rtt is the
+// round trip time measured, or 0 if none was. The observations of a backend weigh it when players are spread over
+// backends using Balance.
+func ObserveBackend(b Backend, rtt time.Duration, err error) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	key := strings.ToLower(b.Name)
+	h, ok := health[key]
+	if !ok {
+		h = &backendHealth{rtt: rtt}
+		health[key] = h
+	}
+	if err != nil {
+		h.errorRate += (1 - h.errorRate) * healthDecay
+		return
+	}
+	h.errorRate -= h.errorRate * healthDecay
+	if rtt > 0 {
+		h.rtt += time.Duration(float64(rtt-h.rtt) * healthDecay)
+	}
+}
+
+// weight returns the weight of the Backend passed when spreading players over backends. A backend that was never
+// observed has the weight of a healthy backend next to the proxy.
+func weight(b Backend) float64 {
+	healthMu.Lock()
+	h, ok := health[strings.ToLower(b.Name)]
+	var hv backendHealth
+	if ok {
+		hv = *h
+	}
+	healthMu.Unlock()
+	w := 1 / (1 + float64(hv.rtt)/float64(referenceRTT)) * math.Pow(1-hv.errorRate, 2)
+	return math.Max(w, minWeight)
+}
+
+// Balance picks the Backend that a new player joins out of the backends passed, preferring backends with a low round
+// trip time from the proxy and few recent errors, as observed using ObserveBackend. Backends are picked at random by
+// their weight rather than always picking the best one, so that players are still spread over backends of equal
+// health. Backends that reached their MaxPlayers are skipped. False is returned if no backends are passed or all of
+// them are full.
+func Balance(backends []Backend) (Backend, bool) {
+	return balance(backends, rand.Float64())
+}
+
+// balance picks a Backend like Balance, using the random number in [0, 1) passed.
+func balance(backends []Backend, r float64) (Backend, bool) {
+	weights := make([]float64, len(backends))
+	var total float64
+	limitMu.Lock()
+	for i, b := range backends {
+		if b.MaxPlayers > 0 && backendPlayers[b.Name] >= b.MaxPlayers {
+			continue
+		}
+		weights[i] = weight(b)
+		total += weights[i]
+	}
+	limitMu.Unlock()
+	if total == 0 {
+		return Backend{}, false
+	}
+	r *= total
+	for i, w := range weights {
+		if r -= w; w > 0 && r < 0 {
+			return backends[i], true
+		}
+	}
+	// Rounding may leave a tiny remainder, in which case the last backend that isn't full is picked.
+	for i := len(backends) - 1; i >= 0; i-- {
+		if weights[i] > 0 {
+			return backends[i], true
+		}
+	}
+	return Backend{}, false
+}
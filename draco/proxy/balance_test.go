@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBalance(t *testing.T) {
+	near, far, failing := Backend{Name: "balance-near"}, Backend{Name: "balance-far"}, Backend{Name: "balance-failing"}
+	ObserveBackend(near, 10*time.Millisecond, nil)
+	ObserveBackend(far, 200*time.Millisecond, nil)
+	for i := 0; i < 20; i++ {
+		ObserveBackend(failing, 0, errors.New("dial failed"))
+	}
+
+	picked := map[string]int{}
+	backends := []Backend{near, far, failing}
+	for i := 0; i < 1000; i++ {
+		b, ok := balance(backends, float64(i)/1000)
+		if !ok {
+			t.Fatal("no backend picked")
+		}
+		picked[b.Name]++
+	}
+	if picked[near.Name] <= picked[far.Name] || picked[far.Name] <= picked[failing.Name] {
+		t.Errorf("unexpected spread of players %v", picked)
+	}
+	if picked[failing.Name] == 0 {
+		t.Errorf("failing backend never picked, so its recovery can't be noticed")
+	}
+
+	// Backends that were never observed are picked like healthy ones.
+	if b, ok := balance([]Backend{{Name: "balance-unknown"}}, 0.5); !ok || b.Name != "balance-unknown" {
+		t.Errorf("backend never observed not picked")
+	}
+	if _, ok := balance([]Backend{{Name: "balance-full", MaxPlayers: 1}}, 0.5); !ok {
+		t.Errorf("backend with slots left not picked")
+	}
+	limitMu.Lock()
+	backendPlayers["balance-full"] = 1
+	limitMu.Unlock()
+	defer func() {
+		limitMu.Lock()
+		delete(backendPlayers, "balance-full")
+		limitMu.Unlock()
+	}()
+	if _, ok := balance([]Backend{{Name: "balance-full", MaxPlayers: 1}}, 0.5); ok {
+		t.Errorf("full backend picked")
+	}
+}
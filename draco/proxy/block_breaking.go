@@ -0,0 +1,200 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// breakingBridge bridges block breaking between a client and a server that disagree on how blocks are broken. With
+// server authoritative block breaking, the client sends the blocks it breaks as block actions in its PlayerAuthInput
+// packets. Without it, the client sends them using PlayerAction packets and an InventoryTransaction once a block is
+// broken. The client keeps the mode of the first server it joined, so after a transfer to a server with the other
+// mode, blocks can't be broken unless the packets of one mode are translated to the other.
+type breakingBridge struct {
+	// authoritative is true if the server breaks blocks authoritatively and the client does not, and false if the
+	// client does and the server does not.
+	authoritative bool
+	// runtimeID is the entity runtime ID of the player as known by the client, which the PlayerAction packets sent
+	// to the server are created with.
+	runtimeID uint64
+
+	mu sync.Mutex
+	// pending holds the block actions read from PlayerAction packets of the client that are sent to an authoritative
+	// server with the next PlayerAuthInput of the client, and broken the transaction of the block broken last, if
+	// not yet sent.
+	pending []protocol.PlayerBlockAction
+	broken  *protocol.UseItemTransactionData
+	// slot and held are the hot bar slot and the item that the client selected last, which the transactions of
+	// blocks broken are sent with to servers that don't break blocks authoritatively.
+	slot byte
+	held protocol.ItemInstance
+}
+
+// newBreakingBridge returns the breakingBridge of a Session with the client and server passed, or nil if they agree
+// on how blocks are broken, or if it isn't known how either breaks them, such as for Sources. Clients that move
+// without PlayerAuthInput packets can't send block actions at all, so no bridge is returned for them either.
+func newBreakingBridge(client, server Conn) *breakingBridge {
+	c, ok := gameData(client)
+	if !ok {
+		return nil
+	}
+	srv, ok := gameData(server)
+	if !ok {
+		return nil
+	}
+	clientAuthoritative := c.PlayerMovementSettings.ServerAuthoritativeBlockBreaking
+	serverAuthoritative := srv.PlayerMovementSettings.ServerAuthoritativeBlockBreaking
+	if clientAuthoritative == serverAuthoritative {
+		return nil
+	}
+	if serverAuthoritative && c.PlayerMovementSettings.MovementType == protocol.PlayerMovementModeClient {
+		return nil
+	}
+	return &breakingBridge{authoritative: serverAuthoritative, runtimeID: c.EntityRuntimeID}
+}
+
+// breakingBridge returns the breakingBridge of the server that the Session is currently attached to, or nil if the
+// client and the server agree on how blocks are broken.
+func (s *Session) breakingBridge() *breakingBridge {
+	s.connMu.RLock()
+	defer s.connMu.RUnlock()
+	return s.breaking
+}
+
+// breakingAction checks if the PlayerAction type passed is part of breaking a block, and may thus be sent as a block
+// action of a PlayerAuthInput packet.
+func breakingAction(action int32) bool {
+	switch action {
+	case protocol.PlayerActionStartBreak, protocol.PlayerActionAbortBreak, protocol.PlayerActionStopBreak,
+		protocol.PlayerActionCrackBreak, protocol.PlayerActionPredictDestroyBlock, protocol.PlayerActionContinueDestroyBlock:
+		return true
+	}
+	return false
+}
+
+func init() {
+	Handle(ClientToServer, func(s *Session, pk *packet.MobEquipment) Action {
+		if b := s.breakingBridge(); b != nil && !b.authoritative {
+			b.mu.Lock()
+			b.slot, b.held = pk.HotBarSlot, pk.NewItem
+			b.mu.Unlock()
+		}
+		return Forward
+	})
+	Handle(ClientToServer, func(s *Session, pk *packet.PlayerAction) Action {
+		b := s.breakingBridge()
+		if b == nil || !b.authoritative || !breakingAction(pk.ActionType) {
+			return Forward
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.pending = append(b.pending, protocol.PlayerBlockAction{Action: pk.ActionType, BlockPos: pk.BlockPosition, Face: pk.BlockFace})
+		return Drop
+	})
+	Handle(ClientToServer, func(s *Session, pk *packet.InventoryTransaction) Action {
+		b := s.breakingBridge()
+		if b == nil || !b.authoritative {
+			return Forward
+		}
+		data, ok := pk.TransactionData.(*protocol.UseItemTransactionData)
+		if !ok || data.ActionType != protocol.UseItemActionBreakBlock {
+			return Forward
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.pending = append(b.pending, protocol.PlayerBlockAction{Action: protocol.PlayerActionPredictDestroyBlock, BlockPos: data.BlockPosition, Face: data.BlockFace})
+		b.broken = data
+		return Drop
+	})
+	Handle(ClientToServer, func(s *Session, pk *packet.PlayerAuthInput) Action {
+		b := s.breakingBridge()
+		if b == nil {
+			return Forward
+		}
+		if b.authoritative {
+			b.attach(pk)
+			return Forward
+		}
+		for _, p := range b.detach(pk) {
+			s.writeServer(p)
+		}
+		return Forward
+	})
+}
+
+// attach adds the block actions and the transaction of the block broken that were read from the client since its
+// last PlayerAuthInput packet to the PlayerAuthInput packet passed, which is sent to an authoritative server.
+func (b *breakingBridge) attach(pk *packet.PlayerAuthInput) {
+	b.mu.Lock()
+	pending, broken := b.pending, b.broken
+	b.pending, b.broken = nil, nil
+	b.mu.Unlock()
+
+	if len(pending) > 0 {
+		pk.InputData |= packet.InputFlagPerformBlockActions
+		pk.BlockActions = append(pk.BlockActions, pending...)
+	}
+	if broken != nil && pk.InputData&packet.InputFlagPerformItemInteraction == 0 {
+		pk.InputData |= packet.InputFlagPerformItemInteraction
+		pk.ItemInteractionData = *broken
+	}
+}
+
+// detach removes the block actions from the PlayerAuthInput packet passed, which is sent to a server that doesn't
+// break blocks authoritatively, and returns the PlayerAction and InventoryTransaction packets that a client breaking
+// blocks that way would have sent instead, in order.
+func (b *breakingBridge) detach(pk *packet.PlayerAuthInput) []packet.Packet {
+	if pk.InputData&packet.InputFlagPerformBlockActions == 0 {
+		return nil
+	}
+	var (
+		packets []packet.Packet
+		broken  *protocol.UseItemTransactionData
+	)
+	if pk.InputData&packet.InputFlagPerformItemInteraction != 0 && pk.ItemInteractionData.ActionType == protocol.UseItemActionBreakBlock {
+		data := pk.ItemInteractionData
+		broken = &data
+		pk.InputData &^= packet.InputFlagPerformItemInteraction
+		pk.ItemInteractionData = protocol.UseItemTransactionData{}
+	}
+	for _, a := range pk.BlockActions {
+		switch a.Action {
+		case protocol.PlayerActionPredictDestroyBlock:
+			if broken == nil {
+				b.mu.Lock()
+				broken = &protocol.UseItemTransactionData{
+					ActionType:    protocol.UseItemActionBreakBlock,
+					BlockPosition: a.BlockPos,
+					BlockFace:     a.Face,
+					HotBarSlot:    int32(b.slot),
+					HeldItem:      b.held,
+					Position:      pk.Position,
+				}
+				b.mu.Unlock()
+			}
+			packets = append(packets, &packet.InventoryTransaction{TransactionData: broken})
+			packets = append(packets, &packet.PlayerAction{EntityRuntimeID: b.runtimeID, ActionType: protocol.PlayerActionStopBreak, BlockPosition: a.BlockPos, BlockFace: a.Face})
+			broken = nil
+		case protocol.PlayerActionContinueDestroyBlock:
+			// Clients breaking blocks without block actions start breaking the next block rather than continuing.
+			packets = append(packets, &packet.PlayerAction{EntityRuntimeID: b.runtimeID, ActionType: protocol.PlayerActionStartBreak, BlockPosition: a.BlockPos, BlockFace: a.Face})
+		default:
+			packets = append(packets, &packet.PlayerAction{EntityRuntimeID: b.runtimeID, ActionType: a.Action, BlockPosition: a.BlockPos, BlockFace: a.Face})
+		}
+	}
+	if broken != nil {
+		packets = append(packets, &packet.InventoryTransaction{TransactionData: broken})
+	}
+	pk.InputData &^= packet.InputFlagPerformBlockActions
+	pk.BlockActions = nil
+	return packets
+}
+
+// writeServer writes a packet created by the proxy on behalf of the client to the server that the Session is
+// attached to, swapping the entity IDs of the player like those of packets forwarded from the client.
+func (s *Session) writeServer(pk packet.Packet) {
+	s.entityIDs().swap(pk)
+	_ = s.Server().WritePacket(pk)
+}
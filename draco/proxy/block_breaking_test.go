@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// gameConn is a recordConn with game data.
+type gameConn struct {
+	recordConn
+	data minecraft.GameData
+}
+
+func (c *gameConn) GameData() minecraft.GameData { return c.data }
+
+// breakingConns returns a client and a server that break blocks authoritatively as specified.
+func breakingConns(clientAuthoritative, serverAuthoritative bool) (*gameConn, *gameConn) {
+	client, server := &gameConn{}, &gameConn{}
+	client.data.EntityRuntimeID, server.data.EntityRuntimeID = 1, 1
+	client.data.PlayerMovementSettings = protocol.PlayerMovementSettings{MovementType: protocol.PlayerMovementModeServer, ServerAuthoritativeBlockBreaking: clientAuthoritative}
+	server.data.PlayerMovementSettings = protocol.PlayerMovementSettings{MovementType: protocol.PlayerMovementModeServer, ServerAuthoritativeBlockBreaking: serverAuthoritative}
+	return client, server
+}
+
+func TestBreakingBridgeAgree(t *testing.T) {
+	client, server := breakingConns(true, true)
+	s := NewSession(client, server, Backend{})
+	defer s.close()
+	if s.breakingBridge() != nil {
+		t.Fatal("expected no bridge for a client and server that agree")
+	}
+}
+
+func TestBreakingBridgeToAuthoritative(t *testing.T) {
+	client, server := breakingConns(false, true)
+	s := NewSession(client, server, Backend{})
+	defer s.close()
+
+	pos := protocol.BlockPos{1, 2, 3}
+	if handle(s, ClientToServer, &packet.PlayerAction{ActionType: protocol.PlayerActionStartBreak, BlockPosition: pos, BlockFace: 1}) != Drop {
+		t.Error("expected the start of breaking to be dropped")
+	}
+	if handle(s, ClientToServer, &packet.PlayerAction{ActionType: protocol.PlayerActionRespawn}) != Forward {
+		t.Error("expected other actions to be forwarded")
+	}
+	tx := &packet.InventoryTransaction{TransactionData: &protocol.UseItemTransactionData{ActionType: protocol.UseItemActionBreakBlock, BlockPosition: pos, BlockFace: 1}}
+	if handle(s, ClientToServer, tx) != Drop {
+		t.Error("expected the transaction of the block broken to be dropped")
+	}
+
+	input := &packet.PlayerAuthInput{}
+	if handle(s, ClientToServer, input) != Forward {
+		t.Fatal("expected the input to be forwarded")
+	}
+	if input.InputData&packet.InputFlagPerformBlockActions == 0 || len(input.BlockActions) != 2 {
+		t.Fatalf("expected 2 block actions to be attached, got %+v", input.BlockActions)
+	}
+	if a := input.BlockActions[1]; a.Action != protocol.PlayerActionPredictDestroyBlock || a.BlockPos != pos {
+		t.Errorf("expected the block to be destroyed, got %+v", a)
+	}
+	if input.InputData&packet.InputFlagPerformItemInteraction == 0 || input.ItemInteractionData.ActionType != protocol.UseItemActionBreakBlock {
+		t.Error("expected the transaction to be attached")
+	}
+
+	next := &packet.PlayerAuthInput{}
+	handle(s, ClientToServer, next)
+	if next.InputData != 0 || len(next.BlockActions) != 0 {
+		t.Errorf("expected block actions to be attached once, got %+v", next.BlockActions)
+	}
+}
+
+func TestBreakingBridgeToLegacy(t *testing.T) {
+	client, server := breakingConns(true, false)
+	s := NewSession(client, server, Backend{})
+	defer s.close()
+
+	item := protocol.ItemInstance{Stack: protocol.ItemStack{ItemType: protocol.ItemType{NetworkID: 5}, Count: 1}}
+	handle(s, ClientToServer, &packet.MobEquipment{NewItem: item, HotBarSlot: 3})
+
+	pos := protocol.BlockPos{1, 2, 3}
+	input := &packet.PlayerAuthInput{
+		InputData: packet.InputFlagPerformBlockActions,
+		BlockActions: []protocol.PlayerBlockAction{
+			{Action: protocol.PlayerActionStartBreak, BlockPos: pos, Face: 1},
+			{Action: protocol.PlayerActionPredictDestroyBlock, BlockPos: pos, Face: 1},
+		},
+	}
+	if handle(s, ClientToServer, input) != Forward {
+		t.Fatal("expected the input to be forwarded")
+	}
+	if input.InputData != 0 || input.BlockActions != nil {
+		t.Errorf("expected the block actions to be removed, got %+v", input.BlockActions)
+	}
+	if len(server.packets) != 3 {
+		t.Fatalf("expected 3 packets to be sent to the server, got %+v", server.packets)
+	}
+	if pk, ok := server.packets[0].(*packet.PlayerAction); !ok || pk.ActionType != protocol.PlayerActionStartBreak || pk.BlockPosition != pos || pk.EntityRuntimeID != 1 {
+		t.Errorf("expected breaking to start, got %+v", server.packets[0])
+	}
+	pk, ok := server.packets[1].(*packet.InventoryTransaction)
+	if !ok {
+		t.Fatalf("expected a transaction, got %+v", server.packets[1])
+	}
+	if data := pk.TransactionData.(*protocol.UseItemTransactionData); data.ActionType != protocol.UseItemActionBreakBlock || data.HotBarSlot != 3 || data.HeldItem.Stack.NetworkID != 5 {
+		t.Errorf("unexpected transaction %+v", data)
+	}
+	if pk, ok := server.packets[2].(*packet.PlayerAction); !ok || pk.ActionType != protocol.PlayerActionStopBreak {
+		t.Errorf("expected breaking to stop, got %+v", server.packets[2])
+	}
+}
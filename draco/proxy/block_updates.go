@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// coalesceWindow is the duration for which UpdateBlock packets are held back to be coalesced with the ones that
+// follow them. It is the duration of a single tick.
+const coalesceWindow = time.Second / 20
+
+var (
+	// coalesceMu guards coalesce.
+	coalesceMu sync.Mutex
+	// coalesce specifies if block updates are coalesced in sessions.
+	coalesce bool
+)
+
+// SetBlockUpdateCoalescing sets if UpdateBlock packets sent by the backend are coalesced in sessions started
+// after the call. If enabled, updates sent within a tick are held back, and updates of the same sub chunk are sent
+// to the client as a single UpdateSubChunkBlocks packet, which greatly reduces the amount of packets sent during
+// explosions and world edits.
+func SetBlockUpdateCoalescing(enabled bool) {
+	coalesceMu.Lock()
+	defer coalesceMu.Unlock()
+	coalesce = enabled
+}
+
+// blockUpdates coalesces the UpdateBlock packets sent to a single client.
+type blockUpdates struct {
+	write func(pk packet.Packet) error
+
+	mu      sync.Mutex
+	pending []*packet.UpdateBlock
+	timer   *time.Timer
+}
+
+// newBlockUpdates returns the blockUpdates of a new Session writing packets using the function passed, or nil if
+// block updates are not coalesced.
+func newBlockUpdates(write func(pk packet.Packet) error) *blockUpdates {
+	coalesceMu.Lock()
+	defer coalesceMu.Unlock()
+	if !coalesce {
+		return nil
+	}
+	return &blockUpdates{write: write}
+}
+
+// add holds back the UpdateBlock packet passed until the end of the current coalesceWindow. It returns false if
+// the packet is not held back and should be written immediately.
+func (b *blockUpdates) add(pk packet.Packet) bool {
+	if b == nil {
+		return false
+	}
+	update, ok := pk.(*packet.UpdateBlock)
+	if !ok {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, update)
+	if b.timer == nil {
+		b.timer = time.AfterFunc(coalesceWindow, b.flush)
+	}
+	return true
+}
+
+// flush writes all block updates held back. It must be called before writing any other packet, so that the order
+// of the block updates relative to other packets is preserved.
+func (b *blockUpdates) flush() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return
+	}
+
+	// Group the updates by sub chunk, keeping them in the order they were sent in.
+	var order []protocol.SubChunkPos
+	groups := map[protocol.SubChunkPos][]*packet.UpdateBlock{}
+	for _, update := range b.pending {
+		pos := protocol.SubChunkPos{update.Position[0] >> 4, update.Position[1] >> 4, update.Position[2] >> 4}
+		if _, ok := groups[pos]; !ok {
+			order = append(order, pos)
+		}
+		groups[pos] = append(groups[pos], update)
+	}
+	b.pending = b.pending[:0]
+
+	for _, pos := range order {
+		updates := groups[pos]
+		if len(updates) == 1 {
+			_ = b.write(updates[0])
+			continue
+		}
+		pk := &packet.UpdateSubChunkBlocks{Position: pos}
+		for _, update := range updates {
+			e := protocol.BlockChangeEntry{BlockPos: update.Position, BlockRuntimeID: update.NewBlockRuntimeID, Flags: update.Flags}
+			if update.Layer == 0 {
+				pk.Blocks = append(pk.Blocks, e)
+			} else {
+				pk.Extra = append(pk.Extra, e)
+			}
+		}
+		_ = b.write(pk)
+	}
+}
@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// bossBars keeps track of the boss bars currently shown to a client, keyed by the unique ID of the boss entity.
+// Updates for boss bars that were never shown to the client, for example because the Show event was sent
+// before a transfer, would leave orphaned progress bars on the client and are dropped instead.
+type bossBars map[int64]struct{}
+
+// track updates the boss bars tracked using the packet passed and reports if the packet should be forwarded
+// to the client.
+func (b bossBars) track(pk packet.Packet) bool {
+	switch pk := pk.(type) {
+	case *packet.BossEvent:
+		switch pk.EventType {
+		case packet.BossEventShow:
+			b[pk.BossEntityUniqueID] = struct{}{}
+		case packet.BossEventHide:
+			if _, ok := b[pk.BossEntityUniqueID]; !ok {
+				return false
+			}
+			delete(b, pk.BossEntityUniqueID)
+		case packet.BossEventHealthPercentage, packet.BossEventTitle, packet.BossEventAppearanceProperties, packet.BossEventTexture:
+			if _, ok := b[pk.BossEntityUniqueID]; !ok {
+				return false
+			}
+		}
+	case *packet.RemoveActor:
+		// The client removes the boss bar of an entity together with the entity itself.
+		delete(b, pk.EntityUniqueID)
+	}
+	return true
+}
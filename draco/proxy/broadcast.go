@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Group is a group of sessions that the proxy, or code embedding it, may broadcast packets to, such as all players
+// watching an event hosted by the proxy. Packets broadcast to a Group are encoded only once for every protocol
+// that its members joined with, rather than once for every member. Sessions are removed from a Group once they are
+// closed. A Group is safe for concurrent use.
+type Group struct {
+	mu      sync.RWMutex
+	members map[*Session]struct{}
+}
+
+// NewGroup returns a new Group without any members.
+func NewGroup() *Group {
+	return &Group{members: map[*Session]struct{}{}}
+}
+
+// Add adds the Session passed to the Group. Adding a Session that is already a member has no effect.
+func (g *Group) Add(s *Session) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.members[s] = struct{}{}
+}
+
+// Remove removes the Session passed from the Group.
+func (g *Group) Remove(s *Session) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.members, s)
+}
+
+// Members returns all sessions currently in the Group.
+func (g *Group) Members() []*Session {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	all := make([]*Session, 0, len(g.members))
+	for s := range g.members {
+		all = append(all, s)
+	}
+	return all
+}
+
+// encodingKey identifies the encoding of packets written to a client. Clients that were sent custom blocks have the
+// state.Registry holding them in blocks, as the runtime IDs of their blocks differ from those of other clients.
+type encodingKey struct {
+	protocol int32
+	shieldID int32
+	blocks   *state.Registry
+}
+
+// encodingConn is a ClientConn that serialised packets may be written to directly.
+type encodingConn interface {
+	encoding() (minecraft.Protocol, int32, bool)
+	Write(b []byte) (n int, err error)
+}
+
+// Broadcast sends the packets passed to all members of the Group, like the Broadcast function. Members that were
+// closed are removed from the Group.
+func (g *Group) Broadcast(pks ...packet.Packet) {
+	members := g.Members()
+	open := members[:0]
+	for _, s := range members {
+		select {
+		case <-s.closed:
+			g.Remove(s)
+		default:
+			open = append(open, s)
+		}
+	}
+	Broadcast(open, pks...)
+}
+
+// Broadcast sends the packets passed to the clients of all sessions passed, in the order they are passed in. Rather
+// than encoding the packets for every client, they are encoded once for every protocol that the clients joined
+// with, after which the encoded packets are written to all clients on that protocol. The packets are sent to the
+// clients directly, without passing through the handlers registered using Handle, and must not be modified after
+// the call. Sessions that were closed are skipped.
+func Broadcast(sessions []*Session, pks ...packet.Packet) {
+	encoded := map[encodingKey][][]byte{}
+	for _, s := range sessions {
+		select {
+		case <-s.closed:
+			continue
+		default:
+		}
+		c, ok := s.client.(encodingConn)
+		if !ok {
+			writeAll(s, pks)
+			continue
+		}
+		p, shieldID, ok := c.encoding()
+		if !ok {
+			writeAll(s, pks)
+			continue
+		}
+		k := encodingKey{protocol: protocol.CurrentProtocol, shieldID: shieldID, blocks: clientBlocks(s.client)}
+		if p != nil {
+			k.protocol = p.ID()
+		}
+		data, ok := encoded[k]
+		if !ok {
+			data = encodePackets(p, shieldID, pks)
+			encoded[k] = data
+		}
+		for _, b := range data {
+			_, _ = c.Write(b)
+		}
+	}
+}
+
+// writeAll writes the packets passed to the client of the Session passed one by one.
+func writeAll(s *Session, pks []packet.Packet) {
+	for _, pk := range pks {
+		_ = s.client.WritePacket(pk)
+	}
+}
+
+// encodePackets serialises the packets passed, including their header, like a minecraft.Conn using the protocol
+// and shield runtime ID passed would. A nil protocol encodes the packets using the latest protocol.
+func encodePackets(p minecraft.Protocol, shieldID int32, pks []packet.Packet) [][]byte {
+	data := make([][]byte, len(pks))
+	for i, pk := range pks {
+		if p != nil {
+			pk = copyPacket(shieldID, pk)
+		}
+		data[i] = encodePacket(p, shieldID, pk)
+	}
+	return data
+}
+
+// encodePacket serialises the packet passed like encodePackets, without copying it first, so the packet may have been
+// converted in place once it returns, as it would be by a minecraft.Conn.
+func encodePacket(p minecraft.Protocol, shieldID int32, pk packet.Packet) []byte {
+	buf := bytes.NewBuffer(nil)
+	// Like a minecraft.Conn, the header holds the ID of the packet in the latest protocol.
+	hdr := packet.Header{PacketID: pk.ID()}
+	_ = hdr.Write(buf)
+	if p != nil {
+		pk = p.ConvertFromLatest(pk)
+	}
+	pk.Marshal(protocol.NewWriter(buf, shieldID))
+	return buf.Bytes()
+}
+
+// latestPool holds all packets of the latest protocol. It is only read from, so that it may be shared between
+// broadcasts.
+var latestPool = packet.NewPool()
+
+// copyPacket returns a copy of the packet passed, so that protocols converting packets in place don't change the
+// packet encoded for other protocols.
+func copyPacket(shieldID int32, pk packet.Packet) packet.Packet {
+	f, ok := latestPool[pk.ID()]
+	if !ok {
+		return pk
+	}
+	buf := bytes.NewBuffer(nil)
+	pk.Marshal(protocol.NewWriter(buf, shieldID))
+	cp := f()
+	cp.Unmarshal(protocol.NewReader(buf, shieldID))
+	return cp
+}
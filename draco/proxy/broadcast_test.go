@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// benchConn is a ClientConn that encodes packets written to it like a minecraft.Conn, and discards them.
+type benchConn struct {
+	proto minecraft.Protocol
+	sent  [][]byte
+}
+
+func (c *benchConn) ReadPacket() (packet.Packet, error)          { select {} }
+func (c *benchConn) IdentityData() login.IdentityData            { return login.IdentityData{} }
+func (c *benchConn) ClientData() login.ClientData                { return login.ClientData{} }
+func (c *benchConn) Latency() time.Duration                      { return 0 }
+func (c *benchConn) Close() error                                { return nil }
+func (c *benchConn) Disconnect(string) error                     { return nil }
+func (c *benchConn) encoding() (minecraft.Protocol, int32, bool) { return c.proto, 0, true }
+
+func (c *benchConn) WritePacket(pk packet.Packet) error {
+	buf := bytes.NewBuffer(nil)
+	hdr := packet.Header{PacketID: pk.ID()}
+	_ = hdr.Write(buf)
+	if c.proto != nil {
+		pk = c.proto.ConvertFromLatest(pk)
+	}
+	pk.Marshal(protocol.NewWriter(buf, 0))
+	c.sent = append(c.sent, buf.Bytes())
+	return nil
+}
+
+func (c *benchConn) Write(b []byte) (int, error) {
+	c.sent = append(c.sent, b)
+	return len(b), nil
+}
+
+// benchSessions returns n sessions, half of which are on the latest protocol and half on the protocol of draco.
+func benchSessions(n int) ([]*Session, []*benchConn) {
+	sessions, conns := make([]*Session, n), make([]*benchConn, n)
+	for i := range sessions {
+		conns[i] = &benchConn{}
+		if i%2 == 1 {
+			conns[i].proto = draco.Protocol{}
+		}
+		sessions[i] = NewSession(conns[i], conns[i], Backend{})
+	}
+	return sessions, conns
+}
+
+// benchPackets returns the packets of a countdown shown to the players of an event.
+func benchPackets() []packet.Packet {
+	return []packet.Packet{
+		&packet.SetTitle{ActionType: packet.TitleActionSetTitle, Text: "§l§63"},
+		&packet.SetTitle{ActionType: packet.TitleActionSetSubtitle, Text: "The show starts in a few seconds"},
+		&packet.Text{TextType: packet.TextTypeRaw, Message: "§7Welcome to the fireworks show!"},
+		&packet.PlaySound{SoundName: "firework.launch", Position: mgl32.Vec3{0, 80, 0}, Volume: 1, Pitch: 1},
+		&packet.SpawnParticleEffect{Position: mgl32.Vec3{0, 100, 0}, ParticleName: "minecraft:huge_explosion_emitter", EntityUniqueID: -1},
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	sessions, conns := benchSessions(4)
+	pks := benchPackets()
+	Broadcast(sessions, pks...)
+	for i, c := range conns {
+		want := encodePackets(c.proto, 0, pks)
+		if len(c.sent) != len(want) {
+			t.Fatalf("client %v received %v packets, expected %v", i, len(c.sent), len(want))
+		}
+		for j := range want {
+			if !bytes.Equal(c.sent[j], want[j]) {
+				t.Errorf("packet %v of client %v was not encoded like it would have been by WritePacket", j, i)
+			}
+		}
+	}
+
+	// Clients are on two protocols, so the encoded packets are shared between clients on the same protocol.
+	if &conns[0].sent[0][0] != &conns[2].sent[0][0] {
+		t.Error("packets were encoded for every client on the same protocol")
+	}
+	if &conns[0].sent[0][0] == &conns[1].sent[0][0] {
+		t.Error("packets were shared between clients on different protocols")
+	}
+}
+
+func TestBroadcastHeader(t *testing.T) {
+	data := encodePackets(nil, 0, []packet.Packet{&packet.Text{TextType: packet.TextTypeRaw, Message: "hi"}})
+	buf := bytes.NewBuffer(data[0])
+	var hdr packet.Header
+	if err := hdr.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	if hdr.PacketID != packet.IDText {
+		t.Fatalf("packet encoded with ID %v, expected %v", hdr.PacketID, packet.IDText)
+	}
+	pk := &packet.Text{}
+	pk.Unmarshal(protocol.NewReader(buf, 0))
+	if pk.Message != "hi" {
+		t.Errorf("packet decoded with message %q, expected %q", pk.Message, "hi")
+	}
+}
+
+func BenchmarkBroadcast(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		sessions, conns := benchSessions(n)
+		pks := benchPackets()
+		b.Run(fmt.Sprintf("WritePacket/%v", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for j, s := range sessions {
+					conns[j].sent = conns[j].sent[:0]
+					for _, pk := range pks {
+						_ = s.Client().WritePacket(pk)
+					}
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("Broadcast/%v", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, c := range conns {
+					c.sent = c.sent[:0]
+				}
+				Broadcast(sessions, pks...)
+			}
+		})
+	}
+}
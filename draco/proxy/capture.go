@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/capture"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+var (
+	// captureMu guards captureWriter and captureID.
+	captureMu sync.Mutex
+	// captureWriter is the capture.Writer set using SetCapture, or nil if packets are not captured.
+	captureWriter *capture.Writer
+	// captureID is the ID of the last session captured.
+	captureID uint32
+)
+
+// SetCapture sets the capture.Writer that all packets forwarded by sessions started after the call are written to,
+// so that they can be replayed through the translation layer offline using the replay command. Packets are captured
+// as read from the client and the server, before packet handlers run, encoded in the latest protocol. If the Writer
+// is nil, packets are no longer captured.
+func SetCapture(w *capture.Writer) {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	captureWriter = w
+}
+
+// sessionCapture holds the capture state of a single Session.
+type sessionCapture struct {
+	w  *capture.Writer
+	id uint32
+}
+
+// startCapture starts capturing the packets of the Session if a capture.Writer was set using SetCapture.
+func (s *Session) startCapture() {
+	captureMu.Lock()
+	w := captureWriter
+	if w == nil {
+		captureMu.Unlock()
+		return
+	}
+	captureID++
+	id := captureID
+	captureMu.Unlock()
+
+	proto, _ := ClientProtocol(s.client)
+	err := w.WriteSession(capture.Session{
+		ID:       id,
+		Name:     s.Name(),
+		XUID:     s.XUID(),
+		Backend:  s.Backend().Name,
+		Protocol: proto,
+		Time:     time.Now(),
+	})
+	if err != nil {
+		s.Logger().Error("error capturing session", "err", err)
+		return
+	}
+	s.capture = sessionCapture{w: w, id: id}
+}
+
+// capturePacket writes the packet passed, travelling in the Direction passed, to the capture of the Session if its
+// packets are captured.
+func (s *Session) capturePacket(d Direction, pk packet.Packet) {
+	if _, ok := pk.(*draco.MalformedPacket); ok || s.capture.w == nil {
+		// Packets that couldn't be translated are not of the latest protocol, so they can't be encoded.
+		return
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, 64))
+	hdr := packet.Header{PacketID: pk.ID()}
+	_ = hdr.Write(buf)
+	pk.Marshal(protocol.NewWriter(buf, 0))
+	_ = s.capture.w.WritePacket(capture.Packet{Session: s.capture.id, ClientToServer: d == ClientToServer, Time: time.Now(), Data: buf.Bytes()})
+}
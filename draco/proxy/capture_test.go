@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/capture"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestCapture(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	w, err := capture.NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetCapture(w)
+	defer SetCapture(nil)
+
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{Name: "lobby"})
+	defer s.close()
+	s.startCapture()
+	s.capturePacket(ServerToClient, &packet.Text{TextType: packet.TextTypeRaw, Message: "hello"})
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := capture.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, ok := record.(capture.Session)
+	if !ok || session.Backend != "lobby" {
+		t.Fatalf("expected a session on lobby, got %+v", record)
+	}
+	if record, err = r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	pk, ok := record.(capture.Packet)
+	if !ok || pk.Session != session.ID || pk.ClientToServer || len(pk.Data) == 0 || pk.Data[0] != packet.IDText {
+		t.Errorf("expected the Text packet of the session, got %+v", record)
+	}
+}
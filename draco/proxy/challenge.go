@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/lang"
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Challenge configures the challenge that suspicious players must pass before a backend is dialed for them, which
+// filters floods of bots that connect without ever playing. Challenged players are spawned by the proxy in a world
+// like the limbo and must move within the timeout. By default, no players are challenged.
+type Challenge struct {
+	// Always specifies if every player joining is challenged, rather than only suspicious ones.
+	Always bool
+	// JoinRate is the amount of players joining per minute above which joins are suspicious, as during a bot flood.
+	// If 0, joins are never suspicious.
+	JoinRate int
+	// Timeout is the duration within which challenged players must move. If 0, it is ten seconds.
+	Timeout time.Duration
+}
+
+const (
+	// challengeDistance is the distance in blocks that a challenged player must move to pass the challenge.
+	challengeDistance = 1
+	// challengePassTTL is the duration after passing a challenge during which a player joining from the same IP is
+	// not challenged again.
+	challengePassTTL = time.Hour
+)
+
+// ErrChallengeFailed is returned by RunChallenge if the client did not pass the challenge.
+var ErrChallengeFailed = errors.New("challenge failed")
+
+var (
+	// challengeMu guards the fields below.
+	challengeMu sync.Mutex
+	// challengeConf is the Challenge set using SetChallenge.
+	challengeConf Challenge
+	// joins holds the times of the joins in the last minute, oldest first.
+	joins []time.Time
+	// passed holds the IPs that passed a challenge with the time at which they passed.
+	passed = map[string]time.Time{}
+	// challengeData is the game data last observed using ObserveGameData, which challenged players are spawned with.
+	challengeData *minecraft.GameData
+)
+
+// SetChallenge sets the Challenge that suspicious players must pass.
+func SetChallenge(c Challenge) {
+	if c.Timeout == 0 {
+		c.Timeout = time.Second * 10
+	}
+	challengeMu.Lock()
+	defer challengeMu.Unlock()
+	challengeConf = c
+}
+
+// ObserveGameData records the game data that a backend spawned a player with. Challenged players are spawned with
+// the items and blocks of the game data last observed, so that they may be attached to any backend afterwards, like
+// players transferred using Transfer. Until game data is observed, no players are challenged.
+func ObserveGameData(data minecraft.GameData) {
+	challengeMu.Lock()
+	defer challengeMu.Unlock()
+	challengeData = &data
+}
+
+// Suspicious records a player joining from the address passed and checks if it should be challenged using
+// RunChallenge before a backend is dialed for it. If so, the game data that the client should be started with is
+// returned.
+func Suspicious(addr net.Addr) (minecraft.GameData, bool) {
+	challengeMu.Lock()
+	defer challengeMu.Unlock()
+	now := time.Now()
+	for len(joins) > 0 && now.Sub(joins[0]) > time.Minute {
+		joins = joins[1:]
+	}
+	joins = append(joins, now)
+
+	flood := challengeConf.JoinRate > 0 && len(joins) > challengeConf.JoinRate
+	if challengeData == nil || (!challengeConf.Always && !flood) {
+		return minecraft.GameData{}, false
+	}
+	if t, ok := passed[ip(addr)]; ok && now.Sub(t) < challengePassTTL {
+		return minecraft.GameData{}, false
+	}
+	data := *challengeData
+	data.Dimension = packet.DimensionEnd
+	data.PlayerPosition = limboPosition
+	data.PlayerGameMode = packet.GameTypeAdventure
+	return data, true
+}
+
+// RunChallenge challenges the client passed, joining from the address passed, which must have been started with the
+// game data returned by Suspicious, blocking until it passes the challenge by moving. If it doesn't move within the
+// timeout of the Challenge, the client is disconnected and ErrChallengeFailed is returned. The client may be used for a
+// Session once it passed, after which ChangeWorld must be called to move it to the world of its backend.
+func RunChallenge(client ClientConn, addr net.Addr) error {
+	challengeMu.Lock()
+	timeout := challengeConf.Timeout
+	challengeMu.Unlock()
+
+	w := newHeldWorld(limboPosition, 0)
+	write := func(pk packet.Packet) {
+		_ = client.WritePacket(pk)
+	}
+	for _, pk := range limboChunks(clientBlocks(client), packet.DimensionEnd) {
+		write(pk)
+	}
+	write(&packet.SetTitle{ActionType: packet.TitleActionSetDurations, RemainDuration: limboTitleTicks, FadeOutDuration: 10})
+	write(&packet.SetTitle{ActionType: packet.TitleActionSetTitle, Text: lang.Translate(client.ClientData().LanguageCode, "challenge.message")})
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		t := time.NewTicker(keepAliveInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				for _, pk := range w.keepAlive() {
+					write(pk)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	failed := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() {
+		close(failed)
+		// Disconnecting the client makes the pending call to ReadPacket return.
+		_ = client.Disconnect(lang.Translate(client.ClientData().LanguageCode, "disconnect.challenge_failed"))
+	})
+	for {
+		pk, err := client.ReadPacket()
+		if err != nil {
+			timer.Stop()
+			metrics.Add("challenges_failed", 1)
+			return ErrChallengeFailed
+		}
+		if answer := w.answer(pk); answer != nil {
+			write(answer)
+		}
+		if !moved(pk) {
+			continue
+		}
+		if !timer.Stop() {
+			// The timeout expired while the packet was read, so the client is already being disconnected.
+			<-failed
+			metrics.Add("challenges_failed", 1)
+			return ErrChallengeFailed
+		}
+		challengeMu.Lock()
+		now := time.Now()
+		for k, t := range passed {
+			if now.Sub(t) >= challengePassTTL {
+				delete(passed, k)
+			}
+		}
+		passed[ip(addr)] = now
+		challengeMu.Unlock()
+		metrics.Add("challenges_passed", 1)
+		write(&packet.SetTitle{ActionType: packet.TitleActionClear})
+		return nil
+	}
+}
+
+// moved checks if the packet passed, sent by a challenged client, holds a position at least challengeDistance away
+// from where the client was spawned, horizontally.
+func moved(pk packet.Packet) bool {
+	var x, z float32
+	switch pk := pk.(type) {
+	case *packet.PlayerAuthInput:
+		x, z = pk.Position[0], pk.Position[2]
+	case *packet.MovePlayer:
+		x, z = pk.Position[0], pk.Position[2]
+	default:
+		return false
+	}
+	dx, dz := x-limboPosition[0], z-limboPosition[2]
+	return dx*dx+dz*dz >= challengeDistance*challengeDistance
+}
+
+// ip returns the IP of the address passed, without its port.
+func ip(addr net.Addr) string {
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		return host
+	}
+	return addr.String()
+}
+
+// ChangeWorld moves the client of the Session to the world of its server, as if it had joined it with the game data
+// passed. It must be called before Start for clients that were started with other game data, such as after passing
+// a challenge.
+func (s *Session) ChangeWorld(data minecraft.GameData) {
+	s.changeWorld(data)
+}
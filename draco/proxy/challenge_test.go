@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// scriptConn is a ClientConn that returns the packets sent on its channel from ReadPacket until it is
+// disconnected.
+type scriptConn struct {
+	benchConn
+	packets      chan packet.Packet
+	disconnected chan struct{}
+}
+
+func newScriptConn() *scriptConn {
+	return &scriptConn{packets: make(chan packet.Packet, 8), disconnected: make(chan struct{})}
+}
+
+func (c *scriptConn) ReadPacket() (packet.Packet, error) {
+	select {
+	case pk := <-c.packets:
+		return pk, nil
+	case <-c.disconnected:
+		return nil, net.ErrClosed
+	}
+}
+
+func (c *scriptConn) Disconnect(string) error {
+	close(c.disconnected)
+	return nil
+}
+
+// resetChallenge resets the state of challenges to that of a proxy that was just started.
+func resetChallenge() {
+	challengeMu.Lock()
+	defer challengeMu.Unlock()
+	joins, passed, challengeData = nil, map[string]time.Time{}, nil
+	challengeConf = Challenge{Timeout: time.Second * 10}
+}
+
+func TestSuspicious(t *testing.T) {
+	resetChallenge()
+	defer resetChallenge()
+	addr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 50000}
+
+	SetChallenge(Challenge{Always: true})
+	if _, ok := Suspicious(addr); ok {
+		t.Error("join suspicious before any game data was observed")
+	}
+	ObserveGameData(minecraft.GameData{Dimension: packet.DimensionOverworld, EntityRuntimeID: 3})
+	data, ok := Suspicious(addr)
+	if !ok {
+		t.Fatal("join not suspicious although all joins are challenged")
+	}
+	if data.Dimension != packet.DimensionEnd || data.PlayerPosition != limboPosition || data.EntityRuntimeID != 3 {
+		t.Errorf("unexpected challenge game data: %#v", data)
+	}
+
+	resetChallenge()
+	ObserveGameData(minecraft.GameData{})
+	SetChallenge(Challenge{JoinRate: 2})
+	for i := 0; i < 2; i++ {
+		if _, ok := Suspicious(addr); ok {
+			t.Errorf("join %v suspicious below the join rate", i)
+		}
+	}
+	if _, ok := Suspicious(addr); !ok {
+		t.Error("join not suspicious above the join rate")
+	}
+	challengeMu.Lock()
+	passed["10.0.0.1"] = time.Now()
+	challengeMu.Unlock()
+	if _, ok := Suspicious(addr); ok {
+		t.Error("join suspicious from an IP that passed a challenge")
+	}
+}
+
+func TestRunChallenge(t *testing.T) {
+	resetChallenge()
+	defer resetChallenge()
+	addr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 50000}
+
+	conn := newScriptConn()
+	conn.packets <- &packet.RequestChunkRadius{ChunkRadius: 4}
+	conn.packets <- &packet.PlayerAuthInput{Position: limboPosition.Add(mgl32.Vec3{0.2, 1.62, 0})}
+	conn.packets <- &packet.PlayerAuthInput{Position: limboPosition.Add(mgl32.Vec3{1.5, 1.62, 0})}
+	if err := RunChallenge(conn, addr); err != nil {
+		t.Fatalf("client that moved failed challenge: %v", err)
+	}
+	challengeMu.Lock()
+	_, ok := passed["10.0.0.2"]
+	challengeMu.Unlock()
+	if !ok {
+		t.Error("IP that passed challenge was not remembered")
+	}
+
+	SetChallenge(Challenge{Timeout: time.Millisecond * 50})
+	conn = newScriptConn()
+	conn.packets <- &packet.PlayerAuthInput{Position: limboPosition}
+	if err := RunChallenge(conn, addr); err != ErrChallengeFailed {
+		t.Errorf("client that didn't move returned %v, expected %v", err, ErrChallengeFailed)
+	}
+}
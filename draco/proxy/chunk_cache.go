@@ -0,0 +1,227 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/chunk"
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/mcdb"
+	"github.com/cqdetdev/draco/draco/nbtcodec"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// maxCachedChunks is the maximum amount of chunks cached of a single backend. Chunks at other positions than those
+// cached are no longer cached once it is reached.
+const maxCachedChunks = 16384
+
+// ErrNoCachedChunks is returned by CachedWorld if no chunks of the Backend passed were cached.
+var ErrNoCachedChunks = errors.New("no chunks cached")
+
+var (
+	// chunkCacheMu guards chunkCaches.
+	chunkCacheMu sync.Mutex
+	// chunkCaches holds the chunkCache of every backend that chunks were cached of, keyed by its lowercase name.
+	chunkCaches = map[string]*chunkCache{}
+)
+
+// chunkCache holds the chunks that a backend with CacheChunks set sent to players.
+type chunkCache struct {
+	mu     sync.Mutex
+	chunks map[cachedChunkPos]*cachedChunk
+	// spawn, time and gameType are the position, time of day and game mode of the first player that chunks were
+	// cached for.
+	spawn    protocol.BlockPos
+	time     int64
+	gameType int32
+}
+
+// cachedChunkPos is the position of a cached chunk, including its dimension.
+type cachedChunkPos struct {
+	dimension int32
+	pos       protocol.ChunkPos
+}
+
+// cachedChunk is a chunk cached in the network encoding in which it was sent, split into its sub chunks.
+type cachedChunk struct {
+	// subs holds the payloads of the sub chunks received, keyed by their index in the chunk. biomes holds the
+	// remainder of the chunk: its biomes and border blocks.
+	subs   map[int][]byte
+	biomes []byte
+}
+
+// cacheOf returns the chunkCache of the Backend that the Session passed is attached to, or nil if it doesn't cache
+// chunks.
+func cacheOf(s *Session) *chunkCache {
+	b := s.Backend()
+	if !b.CacheChunks {
+		return nil
+	}
+	chunkCacheMu.Lock()
+	defer chunkCacheMu.Unlock()
+	c, ok := chunkCaches[strings.ToLower(b.Name)]
+	if !ok {
+		c = &chunkCache{chunks: map[cachedChunkPos]*cachedChunk{}}
+		if data, ok := gameData(s.Server()); ok {
+			pos := data.PlayerPosition
+			c.spawn = protocol.BlockPos{int32(pos[0]), int32(pos[1]), int32(pos[2])}
+			c.time, c.gameType = data.Time, data.WorldGameMode
+		}
+		chunkCaches[strings.ToLower(b.Name)] = c
+	}
+	return c
+}
+
+// The chunks are cached before the handlers of chunk_mode.go change the way that they are sent to the client.
+func init() {
+	Handle(ServerToClient, func(s *Session, pk *packet.LevelChunk) Action {
+		c := cacheOf(s)
+		if c == nil || pk.CacheEnabled {
+			// Chunks sent using the blob cache only hold the hashes of their sub chunks.
+			return Forward
+		}
+		dimension := s.dimension()
+		if pk.SubChunkRequestMode != protocol.SubChunkRequestModeLegacy {
+			// The sub chunks of the chunk are cached once the backend sends them.
+			c.store(cachedChunkPos{dimension: dimension, pos: pk.Position}, nil, append([]byte(nil), pk.RawPayload...))
+			return Forward
+		}
+		subs, biomes, err := chunk.SplitLevelChunk(pk.RawPayload, int(pk.SubChunkCount), dimensionRange(dimension))
+		if err != nil {
+			s.Logger().Error("error caching chunk", "chunk", fmt.Sprint(pk.Position), "err", err)
+			return Forward
+		}
+		c.store(cachedChunkPos{dimension: dimension, pos: pk.Position}, subs, biomes)
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.SubChunk) Action {
+		c := cacheOf(s)
+		if c == nil || pk.CacheEnabled {
+			return Forward
+		}
+		r := dimensionRange(pk.Dimension)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, e := range pk.SubChunkEntries {
+			pos := cachedChunkPos{dimension: pk.Dimension, pos: protocol.ChunkPos{pk.Position.X() + int32(e.Offset[0]), pk.Position.Z() + int32(e.Offset[2])}}
+			cached, ok := c.chunks[pos]
+			if !ok {
+				// The chunk itself wasn't cached, so its biomes are not known.
+				continue
+			}
+			i := int(pk.Position.Y()+int32(e.Offset[1])) - r[0]>>4
+			if i < 0 || i > r.Height()>>4 {
+				continue
+			}
+			switch e.Result {
+			case protocol.SubChunkResultSuccess:
+				cached.subs[i] = append([]byte(nil), e.RawPayload...)
+			case protocol.SubChunkResultSuccessAllAir:
+				delete(cached.subs, i)
+			}
+		}
+		return Forward
+	})
+}
+
+// dimension returns the dimension that the client of the Session is in.
+func (s *Session) dimension() int32 {
+	s.world.mu.Lock()
+	defer s.world.mu.Unlock()
+	return s.world.dimension
+}
+
+// store caches the chunk at the position passed. The sub chunks of the chunk cached before are kept if subs is nil.
+func (c *chunkCache) store(pos cachedChunkPos, subs [][]byte, biomes []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.chunks[pos]
+	if !ok {
+		if len(c.chunks) >= maxCachedChunks {
+			return
+		}
+		cached = &cachedChunk{subs: map[int][]byte{}}
+		c.chunks[pos] = cached
+	}
+	cached.biomes = biomes
+	if subs != nil {
+		cached.subs = make(map[int][]byte, len(subs))
+		for i, sub := range subs {
+			cached.subs[i] = sub
+		}
+	}
+}
+
+// CachedWorld returns the world made up of the chunks cached of the Backend passed, which must have CacheChunks set.
+// Changes made to chunks after they were sent, such as blocks placed, are not part of the world until the chunks are
+// sent again. ErrNoCachedChunks is returned if no chunks of the Backend were cached.
+func CachedWorld(b Backend) (mcdb.World, error) {
+	chunkCacheMu.Lock()
+	c, ok := chunkCaches[strings.ToLower(b.Name)]
+	chunkCacheMu.Unlock()
+	if !ok {
+		return mcdb.World{}, ErrNoCachedChunks
+	}
+
+	c.mu.Lock()
+	w := mcdb.World{Name: b.Name, Spawn: c.spawn, Time: c.time, GameType: c.gameType}
+	chunks := make(map[cachedChunkPos]cachedChunk, len(c.chunks))
+	for pos, cached := range c.chunks {
+		subs := make(map[int][]byte, len(cached.subs))
+		for i, sub := range cached.subs {
+			subs[i] = sub
+		}
+		chunks[pos] = cachedChunk{subs: subs, biomes: cached.biomes}
+	}
+	c.mu.Unlock()
+
+	air, _ := latestmappings.StateToRuntimeID("minecraft:air", nil)
+	for pos, cached := range chunks {
+		decoded, err := decodeCachedChunk(air, pos, cached)
+		if err != nil {
+			log.Printf("error exporting chunk %v of backend %v: %v", pos.pos, b.Name, err)
+			continue
+		}
+		w.Chunks = append(w.Chunks, decoded)
+	}
+	if len(w.Chunks) == 0 {
+		return mcdb.World{}, ErrNoCachedChunks
+	}
+	return w, nil
+}
+
+// decodeCachedChunk decodes a chunk cached at the position passed, including its block entities.
+func decodeCachedChunk(air uint32, pos cachedChunkPos, cached cachedChunk) (mcdb.Chunk, error) {
+	r := dimensionRange(pos.dimension)
+	subs := make([][]byte, (r.Height()>>4)+1)
+	for i, sub := range cached.subs {
+		if i >= 0 && i < len(subs) {
+			subs[i] = sub
+		}
+	}
+	payload, err := chunk.JoinSubChunks(subs, cached.biomes, r)
+	if err != nil {
+		return mcdb.Chunk{}, err
+	}
+	buf := bytes.NewBuffer(payload)
+	c, err := chunk.NetworkDecode(air, buf, len(subs), r)
+	if err != nil {
+		return mcdb.Chunk{}, err
+	}
+	// The biomes are followed by the border blocks and the block entities of the chunk.
+	border, err := buf.ReadByte()
+	if err != nil {
+		return mcdb.Chunk{}, fmt.Errorf("read border blocks: %w", err)
+	}
+	_ = buf.Next(int(border))
+	blockEntities, err := nbtcodec.DecodeAll(buf.Bytes(), nbtcodec.Network)
+	if err != nil {
+		return mcdb.Chunk{}, fmt.Errorf("decode block entities: %w", err)
+	}
+	return mcdb.Chunk{Dimension: pos.dimension, Position: pos.pos, Chunk: c, BlockEntities: blockEntities}, nil
+}
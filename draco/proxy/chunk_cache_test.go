@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/chunk"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestCachedWorld(t *testing.T) {
+	b := Backend{Name: "cached", CacheChunks: true, Chunks: ChunkModeRequest}
+	defer func() {
+		chunkCacheMu.Lock()
+		delete(chunkCaches, "cached")
+		chunkCacheMu.Unlock()
+	}()
+	if _, err := CachedWorld(b); !errors.Is(err, ErrNoCachedChunks) {
+		t.Fatalf("expected ErrNoCachedChunks before any chunk was sent, got %v", err)
+	}
+
+	conn := &recordConn{}
+	s := NewSession(conn, conn, b)
+	// The full chunk is cached before the proxy splits it into sub chunks for the client.
+	handle(s, ServerToClient, &packet.LevelChunk{Position: protocol.ChunkPos{1, 2}, SubChunkCount: 2, RawPayload: testChunkPayload()})
+
+	// Chunks sent using sub chunk requests are cached once their sub chunks are.
+	subs, biomes, err := chunk.SplitLevelChunk(testChunkPayload(), 2, dimensionRange(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle(s, ServerToClient, &packet.LevelChunk{Position: protocol.ChunkPos{5, 5}, SubChunkRequestMode: protocol.SubChunkRequestModeLimited, HighestSubChunk: 2, RawPayload: biomes})
+	handle(s, ServerToClient, &packet.SubChunk{Position: protocol.SubChunkPos{5, -4, 5}, SubChunkEntries: []protocol.SubChunkEntry{
+		{Result: protocol.SubChunkResultSuccess, RawPayload: subs[0]},
+		{Offset: [3]int8{0, 1, 0}, Result: protocol.SubChunkResultSuccessAllAir},
+	}})
+
+	w, err := CachedWorld(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %v", len(w.Chunks))
+	}
+	for _, c := range w.Chunks {
+		if v := c.Chunk.Block(0, -60, 0, 0); v != 5 {
+			t.Errorf("chunk %v holds block %v rather than 5", c.Position, v)
+		}
+	}
+}
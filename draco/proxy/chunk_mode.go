@@ -0,0 +1,320 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/chunk"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// ChunkMode specifies the way that the chunks of a Backend are sent to clients.
+type ChunkMode string
+
+const (
+	// ChunkModeBackend sends chunks to clients the way the backend sends them.
+	ChunkModeBackend ChunkMode = ""
+	// ChunkModeFull sends chunks to clients as full LevelChunk packets. Chunks that the backend sends using sub
+	// chunk requests are requested by the proxy and joined into full chunks.
+	ChunkModeFull ChunkMode = "full"
+	// ChunkModeRequest sends chunks to clients using sub chunk requests. Full chunks sent by the backend are split
+	// by the proxy, which answers the sub chunk requests of the client itself.
+	ChunkModeRequest ChunkMode = "request"
+)
+
+const (
+	// maxSplitChunks is the maximum amount of split chunks kept for a client to request the sub chunks of. It is well
+	// above the amount of chunks within the maximum chunk radius.
+	maxSplitChunks = 8192
+	// maxPendingChunks is the maximum amount of chunks kept while waiting for the backend to answer the sub chunks
+	// requested of them. Chunks of which the backend never answers all sub chunks are forgotten once it is reached.
+	maxPendingChunks = 8192
+)
+
+// chunkTranslator translates the chunks sent to a single client to the ChunkMode of its Backend.
+type chunkTranslator struct {
+	mode ChunkMode
+
+	mu        sync.Mutex
+	dimension int32
+	// pending holds the chunks joined in ChunkModeFull that the proxy is waiting for the sub chunks of.
+	// pendingOrder holds the positions of the chunks in pending from the oldest to the newest.
+	pending      map[protocol.ChunkPos]*pendingChunk
+	pendingOrder []protocol.ChunkPos
+	// split holds the chunks split in ChunkModeRequest until all their sub chunks were requested by the client.
+	// order holds the positions of the chunks in split from the oldest to the newest.
+	split map[protocol.ChunkPos]*splitChunk
+	order []protocol.ChunkPos
+}
+
+// pendingChunk is a chunk sent using sub chunk requests by the backend, of which the sub chunks were requested.
+type pendingChunk struct {
+	pk *packet.LevelChunk
+	// subs holds the payloads received of all sub chunks requested, and answered specifies which of them were
+	// answered by the backend. received is the amount of sub chunks answered.
+	subs     [][]byte
+	answered []bool
+	received int
+}
+
+// splitChunk is a full chunk sent by the backend, split into the payloads of its sub chunks.
+type splitChunk struct {
+	// subs holds the payloads of all sub chunks of the chunk, and requested specifies which of them were requested
+	// by the client. remaining is the amount of sub chunks that were not requested yet.
+	subs      [][]byte
+	requested []bool
+	remaining int
+}
+
+// newChunkTranslator returns the chunkTranslator of a Session connected to the Backend and server passed, or nil if
+// chunks are sent the way the backend sends them.
+func newChunkTranslator(b Backend, server Conn) *chunkTranslator {
+	if b.Chunks == ChunkModeBackend {
+		return nil
+	}
+	t := &chunkTranslator{mode: b.Chunks, pending: map[protocol.ChunkPos]*pendingChunk{}, split: map[protocol.ChunkPos]*splitChunk{}}
+	if data, ok := gameData(server); ok {
+		t.dimension = data.Dimension
+	}
+	return t
+}
+
+// chunkTranslator returns the chunkTranslator of the backend that the Session is currently attached to, or nil if
+// its chunks are not translated.
+func (s *Session) chunkTranslator() *chunkTranslator {
+	s.connMu.RLock()
+	defer s.connMu.RUnlock()
+	return s.chunks
+}
+
+func init() {
+	Handle(ServerToClient, func(s *Session, pk *packet.LevelChunk) Action {
+		t := s.chunkTranslator()
+		if t == nil || pk.CacheEnabled {
+			// Chunks sent using the blob cache only hold the hashes of their sub chunks, which can't be translated.
+			return Forward
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.mode == ChunkModeFull && pk.SubChunkRequestMode != protocol.SubChunkRequestModeLegacy {
+			return t.request(s, pk)
+		}
+		if t.mode == ChunkModeRequest && pk.SubChunkRequestMode == protocol.SubChunkRequestModeLegacy {
+			t.splitChunk(pk)
+		}
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.SubChunk) Action {
+		t := s.chunkTranslator()
+		if t == nil || t.mode != ChunkModeFull {
+			return Forward
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return t.join(s, pk)
+	})
+	Handle(ClientToServer, func(s *Session, pk *packet.SubChunkRequest) Action {
+		t := s.chunkTranslator()
+		if t == nil || t.mode != ChunkModeRequest {
+			return Forward
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return t.answer(s, pk)
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.ChangeDimension) Action {
+		if t := s.chunkTranslator(); t != nil {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// The client forgets all chunks of the previous dimension.
+			t.dimension = pk.Dimension
+			t.pending, t.pendingOrder = map[protocol.ChunkPos]*pendingChunk{}, nil
+			t.split, t.order = map[protocol.ChunkPos]*splitChunk{}, nil
+		}
+		return Forward
+	})
+}
+
+// request requests all sub chunks of a chunk sent using sub chunk requests from the backend, so that they can be
+// joined into a full chunk once the backend answered. t.mu must be held when calling request.
+func (t *chunkTranslator) request(s *Session, pk *packet.LevelChunk) Action {
+	r := dimensionRange(t.dimension)
+	count := (r.Height() >> 4) + 1
+	if pk.SubChunkRequestMode == protocol.SubChunkRequestModeLimited && int(pk.HighestSubChunk) < count {
+		count = int(pk.HighestSubChunk)
+	}
+	c := &pendingChunk{pk: pk, subs: make([][]byte, count), answered: make([]bool, count)}
+	if count == 0 {
+		t.send(s, c)
+		return Drop
+	}
+	offsets := make([][3]int8, count)
+	for i := range offsets {
+		offsets[i] = [3]int8{0, int8(i), 0}
+	}
+	if _, ok := t.pending[pk.Position]; !ok {
+		t.pendingOrder = append(t.pendingOrder, pk.Position)
+	}
+	t.pending[pk.Position] = c
+	for len(t.pendingOrder) > maxPendingChunks {
+		delete(t.pending, t.pendingOrder[0])
+		t.pendingOrder = t.pendingOrder[1:]
+	}
+	req := &packet.SubChunkRequest{
+		Dimension: t.dimension,
+		Position:  protocol.SubChunkPos{pk.Position.X(), int32(r[0] >> 4), pk.Position.Z()},
+		Offsets:   offsets,
+	}
+	if err := s.Server().WritePacket(req); err != nil {
+		t.forgetPending(pk.Position)
+	}
+	return Drop
+}
+
+// join adds the sub chunks of a SubChunk packet sent by the backend to the pending chunks they belong to, sending
+// every chunk of which all sub chunks were answered to the client. t.mu must be held when calling join.
+func (t *chunkTranslator) join(s *Session, pk *packet.SubChunk) Action {
+	r := dimensionRange(t.dimension)
+	handled := false
+	for _, e := range pk.SubChunkEntries {
+		pos := protocol.ChunkPos{pk.Position.X() + int32(e.Offset[0]), pk.Position.Z() + int32(e.Offset[2])}
+		c, ok := t.pending[pos]
+		if !ok {
+			continue
+		}
+		handled = true
+		i := int(pk.Position.Y()+int32(e.Offset[1])) - r[0]>>4
+		if i < 0 || i >= len(c.subs) || c.answered[i] {
+			continue
+		}
+		if e.Result == protocol.SubChunkResultSuccess && !pk.CacheEnabled {
+			// Other results, such as sub chunks consisting of air only, are sent as air.
+			c.subs[i] = e.RawPayload
+		}
+		c.answered[i] = true
+		if c.received++; c.received == len(c.subs) {
+			t.forgetPending(pos)
+			t.send(s, c)
+		}
+	}
+	if !handled {
+		// The sub chunks were requested by the client itself, for chunks that were not joined.
+		return Forward
+	}
+	return Drop
+}
+
+// send joins the sub chunks of a pendingChunk and sends the full chunk to the client.
+func (t *chunkTranslator) send(s *Session, c *pendingChunk) {
+	payload, err := chunk.JoinSubChunks(c.subs, c.pk.RawPayload, dimensionRange(t.dimension))
+	if err != nil {
+		s.Logger().Error("error joining sub chunks", "chunk", fmt.Sprint(c.pk.Position), "err", err)
+		return
+	}
+	full := &packet.LevelChunk{
+		Position:            c.pk.Position,
+		SubChunkRequestMode: protocol.SubChunkRequestModeLegacy,
+		SubChunkCount:       uint32(len(c.subs)),
+		RawPayload:          payload,
+	}
+	if s.client.WritePacket(full) == nil {
+		s.known.observe(full)
+	}
+}
+
+// splitChunk splits a full chunk sent by the backend into its sub chunks, changing the packet passed to have the
+// client request them. t.mu must be held when calling splitChunk.
+func (t *chunkTranslator) splitChunk(pk *packet.LevelChunk) {
+	subs, biomes, err := chunk.SplitLevelChunk(pk.RawPayload, int(pk.SubChunkCount), dimensionRange(t.dimension))
+	if err != nil {
+		log.Printf("error splitting chunk %v: %v", pk.Position, err)
+		return
+	}
+	if _, ok := t.split[pk.Position]; !ok {
+		t.order = append(t.order, pk.Position)
+	}
+	t.split[pk.Position] = &splitChunk{subs: subs, requested: make([]bool, len(subs)), remaining: len(subs)}
+	for len(t.order) > maxSplitChunks {
+		delete(t.split, t.order[0])
+		t.order = t.order[1:]
+	}
+	pk.SubChunkRequestMode, pk.HighestSubChunk, pk.SubChunkCount, pk.RawPayload = protocol.SubChunkRequestModeLimited, uint16(len(subs)), 0, biomes
+}
+
+// answer answers a sub chunk request of the client using the sub chunks of the split chunks. Requests for chunks
+// that were not split are forwarded to the backend. t.mu must be held when calling answer.
+func (t *chunkTranslator) answer(s *Session, pk *packet.SubChunkRequest) Action {
+	if _, ok := t.split[protocol.ChunkPos{pk.Position.X(), pk.Position.Z()}]; !ok {
+		return Forward
+	}
+	r := dimensionRange(t.dimension)
+	entries := make([]protocol.SubChunkEntry, 0, len(pk.Offsets))
+	for _, offset := range pk.Offsets {
+		e := protocol.SubChunkEntry{Offset: offset, Result: protocol.SubChunkResultChunkNotFound, HeightMapType: protocol.HeightMapDataNone}
+		pos := protocol.ChunkPos{pk.Position.X() + int32(offset[0]), pk.Position.Z() + int32(offset[2])}
+		i := int(pk.Position.Y()+int32(offset[1])) - r[0]>>4
+		if c, ok := t.split[pos]; ok {
+			switch {
+			case i < 0 || i > r.Height()>>4:
+				e.Result = protocol.SubChunkResultIndexOutOfBounds
+			case i >= len(c.subs):
+				e.Result = protocol.SubChunkResultSuccessAllAir
+			default:
+				e.Result, e.RawPayload = protocol.SubChunkResultSuccess, c.subs[i]
+				if !c.requested[i] {
+					c.requested[i] = true
+					c.remaining--
+				}
+			}
+		}
+		entries = append(entries, e)
+	}
+	for _, offset := range pk.Offsets {
+		// Chunks are only forgotten once the entire request was answered, as it may request any sub chunk twice.
+		pos := protocol.ChunkPos{pk.Position.X() + int32(offset[0]), pk.Position.Z() + int32(offset[2])}
+		if c, ok := t.split[pos]; ok && c.remaining == 0 {
+			t.forget(pos)
+		}
+	}
+	answer := &packet.SubChunk{Dimension: pk.Dimension, Position: pk.Position, SubChunkEntries: entries}
+	if s.client.WritePacket(answer) == nil {
+		s.known.observe(answer)
+	}
+	return Drop
+}
+
+// forget forgets the split chunk at the position passed. t.mu must be held when calling forget.
+func (t *chunkTranslator) forget(pos protocol.ChunkPos) {
+	delete(t.split, pos)
+	for i, other := range t.order {
+		if other == pos {
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// forgetPending forgets the pending chunk at the position passed. t.mu must be held when calling forgetPending.
+func (t *chunkTranslator) forgetPending(pos protocol.ChunkPos) {
+	delete(t.pending, pos)
+	for i, other := range t.pendingOrder {
+		if other == pos {
+			t.pendingOrder = append(t.pendingOrder[:i], t.pendingOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// dimensionRange returns the range of blocks of the dimension with the ID passed.
+func dimensionRange(dimension int32) cube.Range {
+	switch dimension {
+	case 1:
+		return cube.Range{0, 127}
+	case 2:
+		return cube.Range{0, 255}
+	}
+	return cube.Range{-64, 319}
+}
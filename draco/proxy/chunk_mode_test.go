@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/chunk"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// recordConn is a ClientConn that records the packets written to it.
+type recordConn struct {
+	benchConn
+	packets []packet.Packet
+}
+
+func (c *recordConn) WritePacket(pk packet.Packet) error {
+	c.packets = append(c.packets, pk)
+	return nil
+}
+
+// testChunkPayload returns the payload of a full chunk with two sub chunks and a block in the first one.
+func testChunkPayload() []byte {
+	c := chunk.New(0, dimensionRange(0))
+	c.SetBlock(0, -60, 0, 0, 5)
+	data := chunk.Encode(c, chunk.NetworkEncoding)
+	buf := bytes.NewBuffer(nil)
+	for _, sub := range data.SubChunks[:2] {
+		buf.Write(sub)
+	}
+	buf.Write(data.Biomes)
+	buf.WriteByte(0)
+	return buf.Bytes()
+}
+
+func TestChunkModeRequest(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{Chunks: ChunkModeRequest})
+
+	pk := &packet.LevelChunk{Position: protocol.ChunkPos{1, 2}, SubChunkCount: 2, RawPayload: testChunkPayload()}
+	if handle(s, ServerToClient, pk) != Forward {
+		t.Fatal("split chunk was not forwarded")
+	}
+	if pk.SubChunkRequestMode != protocol.SubChunkRequestModeLimited || pk.HighestSubChunk != 2 {
+		t.Fatalf("chunk forwarded with request mode %v and highest sub chunk %v", pk.SubChunkRequestMode, pk.HighestSubChunk)
+	}
+
+	req := &packet.SubChunkRequest{Position: protocol.SubChunkPos{1, -4, 2}, Offsets: [][3]int8{{0, 0, 0}, {0, 1, 0}, {0, 2, 0}, {0, 30, 0}, {1, 0, 0}}}
+	if handle(s, ClientToServer, req) != Drop {
+		t.Fatal("request answered by the proxy was forwarded")
+	}
+	if len(conn.packets) != 1 {
+		t.Fatalf("%v packets sent to the client, expected 1", len(conn.packets))
+	}
+	results := []byte{
+		protocol.SubChunkResultSuccess,
+		protocol.SubChunkResultSuccess,
+		protocol.SubChunkResultSuccessAllAir,
+		protocol.SubChunkResultIndexOutOfBounds,
+		protocol.SubChunkResultChunkNotFound,
+	}
+	for i, e := range conn.packets[0].(*packet.SubChunk).SubChunkEntries {
+		if e.Result != results[i] {
+			t.Errorf("sub chunk %v answered with result %v, expected %v", e.Offset, e.Result, results[i])
+		}
+	}
+}
+
+func TestChunkModeFull(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{Chunks: ChunkModeFull})
+	subs, biomes, err := chunk.SplitLevelChunk(testChunkPayload(), 2, dimensionRange(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pk := &packet.LevelChunk{Position: protocol.ChunkPos{1, 2}, SubChunkRequestMode: protocol.SubChunkRequestModeLimited, HighestSubChunk: 2, RawPayload: biomes}
+	if handle(s, ServerToClient, pk) != Drop {
+		t.Fatal("chunk to be joined was forwarded")
+	}
+	// The session uses the same connection for the client and the server, so the request is recorded too.
+	req, ok := conn.packets[0].(*packet.SubChunkRequest)
+	if !ok || len(req.Offsets) != 2 {
+		t.Fatalf("sub chunks of the chunk were not requested: %#v", conn.packets[0])
+	}
+
+	answer := &packet.SubChunk{Position: req.Position, SubChunkEntries: []protocol.SubChunkEntry{
+		{Offset: req.Offsets[0], Result: protocol.SubChunkResultSuccess, RawPayload: subs[0]},
+		{Offset: req.Offsets[1], Result: protocol.SubChunkResultSuccessAllAir},
+	}}
+	if handle(s, ServerToClient, answer) != Drop {
+		t.Fatal("sub chunks requested by the proxy were forwarded")
+	}
+	joined, ok := conn.packets[1].(*packet.LevelChunk)
+	if !ok || joined.SubChunkRequestMode != protocol.SubChunkRequestModeLegacy || joined.SubChunkCount != 2 {
+		t.Fatalf("joined chunk was not sent: %#v", conn.packets[1])
+	}
+	c, err := chunk.NetworkDecode(0, bytes.NewBuffer(joined.RawPayload), 2, dimensionRange(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rid := c.Block(0, -60, 0, 0); rid != 5 {
+		t.Errorf("block of joined chunk is %v, expected 5", rid)
+	}
+}
+
+func TestChunkModeFullPartialAnswer(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{Chunks: ChunkModeFull})
+	_, biomes, err := chunk.SplitLevelChunk(testChunkPayload(), 2, dimensionRange(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The backend only ever answers the first of the two sub chunks requested of every chunk.
+	for x := int32(0); x < maxPendingChunks+10; x++ {
+		pk := &packet.LevelChunk{Position: protocol.ChunkPos{x, 0}, SubChunkRequestMode: protocol.SubChunkRequestModeLimited, HighestSubChunk: 2, RawPayload: biomes}
+		if handle(s, ServerToClient, pk) != Drop {
+			t.Fatal("chunk to be joined was forwarded")
+		}
+		req := conn.packets[len(conn.packets)-1].(*packet.SubChunkRequest)
+		answer := &packet.SubChunk{Position: req.Position, SubChunkEntries: []protocol.SubChunkEntry{
+			{Offset: req.Offsets[0], Result: protocol.SubChunkResultSuccessAllAir},
+		}}
+		if handle(s, ServerToClient, answer) != Drop {
+			t.Fatal("sub chunks requested by the proxy were forwarded")
+		}
+	}
+	if n := len(s.chunks.pending); n != maxPendingChunks || len(s.chunks.pendingOrder) != n {
+		t.Fatalf("%v chunks pending (%v ordered), expected %v", n, len(s.chunks.pendingOrder), maxPendingChunks)
+	}
+	if _, ok := s.chunks.pending[protocol.ChunkPos{0, 0}]; ok {
+		t.Error("oldest pending chunk was not forgotten")
+	}
+
+	// Answering the rest of a chunk still pending sends it and forgets it.
+	last := protocol.ChunkPos{maxPendingChunks + 9, 0}
+	answer := &packet.SubChunk{Position: protocol.SubChunkPos{last.X(), -4, last.Z()}, SubChunkEntries: []protocol.SubChunkEntry{
+		{Offset: [3]int8{0, 1, 0}, Result: protocol.SubChunkResultSuccessAllAir},
+	}}
+	handle(s, ServerToClient, answer)
+	if _, ok := conn.packets[len(conn.packets)-1].(*packet.LevelChunk); !ok {
+		t.Fatal("chunk answered in full was not sent")
+	}
+	if _, ok := s.chunks.pending[last]; ok || len(s.chunks.pendingOrder) != maxPendingChunks-1 {
+		t.Error("chunk answered in full was not forgotten")
+	}
+}
@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+var (
+	// chunkWorkerMu guards chunkWorkers.
+	chunkWorkerMu sync.Mutex
+	// chunkWorkers is the pool started using SetChunkWorkers, or nil if chunks are translated inline.
+	chunkWorkers *chunkWorkerPool
+)
+
+// SetChunkWorkers sets the amount of goroutines that translate the chunks sent to clients on older protocols, which
+// are decoded, remapped and encoded again. Rather than translating them on the goroutine forwarding the packets of a
+// player, which can't forward other packets meanwhile, they are translated by these workers while the packets of
+// the player keep being read. Packets are still written to the client in the order the backend sent them. If n is 0
+// or less, chunks are translated inline, which is the default. Only sessions started after the call are affected
+// if the pool is enabled or disabled.
+func SetChunkWorkers(n int) {
+	chunkWorkerMu.Lock()
+	defer chunkWorkerMu.Unlock()
+	if chunkWorkers != nil && chunkWorkers.size == n {
+		return
+	}
+	if chunkWorkers != nil {
+		close(chunkWorkers.stop)
+		chunkWorkers = nil
+	}
+	if n > 0 {
+		chunkWorkers = newChunkWorkerPool(n)
+	}
+}
+
+// chunkWorkerPool is a fixed amount of goroutines running chunkJobs.
+type chunkWorkerPool struct {
+	size int
+	jobs chan *chunkJob
+	stop chan struct{}
+}
+
+// newChunkWorkerPool starts a chunkWorkerPool with n workers.
+func newChunkWorkerPool(n int) *chunkWorkerPool {
+	p := &chunkWorkerPool{size: n, jobs: make(chan *chunkJob), stop: make(chan struct{})}
+	for i := 0; i < n; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// work runs the jobs submitted to the pool until it is stopped.
+func (p *chunkWorkerPool) work() {
+	for {
+		select {
+		case j := <-p.jobs:
+			j.run()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// submitChunkJob runs the job passed on the current chunkWorkerPool, blocking while all of its workers are busy. If
+// no pool is running, or it is stopped meanwhile, the job is run by the caller.
+func submitChunkJob(j *chunkJob) {
+	chunkWorkerMu.Lock()
+	p := chunkWorkers
+	chunkWorkerMu.Unlock()
+	if p == nil {
+		j.run()
+		return
+	}
+	// The jobs channel is unbuffered, so a job is either received by a worker or run here, never left behind in
+	// the channel of a stopped pool.
+	select {
+	case p.jobs <- j:
+	case <-p.stop:
+		j.run()
+	}
+}
+
+// chunkJob is the translation of a packet to the protocol of a client, encoded as the client reads it.
+type chunkJob struct {
+	pk       packet.Packet
+	proto    minecraft.Protocol
+	shieldID int32
+
+	// data and err are set once done is closed. err is set if the packet couldn't be translated.
+	data []byte
+	err  error
+	done chan struct{}
+}
+
+// run translates and encodes the packet of the chunkJob.
+func (j *chunkJob) run() {
+	defer close(j.done)
+	defer func() {
+		if r := recover(); r != nil {
+			j.err = fmt.Errorf("translate %T: %v", j.pk, r)
+		}
+	}()
+	j.data = encodePacket(j.proto, j.shieldID, j.pk)
+}
+
+// translatedAsync checks if the packet passed is translated by the chunk workers: these are the packets holding
+// chunks, whose translation is expensive.
+func translatedAsync(pk packet.Packet) bool {
+	switch pk.(type) {
+	case *packet.LevelChunk, *packet.SubChunk, *packet.ClientCacheMissResponse:
+		return true
+	}
+	return false
+}
+
+// chunkPipeline passes the packets that a backend sends to a Session on to the goroutine writing them to the
+// client in order, while the chunks among them are translated by the chunk workers.
+type chunkPipeline struct {
+	conn     encodingConn
+	proto    minecraft.Protocol
+	shieldID int32
+	packets  chan pipelinedPacket
+
+	mu sync.Mutex
+	// jobs holds the chunkJobs of the packets that were not yet written to the client.
+	jobs map[packet.Packet]*chunkJob
+}
+
+// pipelinedPacket is a packet in a chunkPipeline, together with the time at which the proxy received it and its
+// chunkJob, if it is translated by the chunk workers.
+type pipelinedPacket struct {
+	pk    packet.Packet
+	start time.Time
+	job   *chunkJob
+}
+
+// newChunkPipeline returns a chunkPipeline for the client passed, or nil if chunk workers are disabled or the
+// client is on the latest protocol, in which case chunks don't have to be translated.
+func newChunkPipeline(client ClientConn) *chunkPipeline {
+	chunkWorkerMu.Lock()
+	enabled := chunkWorkers != nil
+	chunkWorkerMu.Unlock()
+	c, ok := client.(encodingConn)
+	if !enabled || !ok {
+		return nil
+	}
+	proto, shieldID, ok := c.encoding()
+	if !ok || proto == nil {
+		return nil
+	}
+	return &chunkPipeline{
+		conn:     c,
+		proto:    proto,
+		shieldID: shieldID,
+		packets:  make(chan pipelinedPacket, maxQueuedPackets),
+		jobs:     map[packet.Packet]*chunkJob{},
+	}
+}
+
+// push passes the packet passed, received at the time passed, on to the pipeline, submitting a chunkJob first if it
+// holds a chunk. It blocks while the pipeline is full. False is returned if the Session was closed.
+func (p *chunkPipeline) push(s *Session, pk packet.Packet, start time.Time) bool {
+	var j *chunkJob
+	if translatedAsync(pk) {
+		j = &chunkJob{pk: pk, proto: p.proto, shieldID: p.shieldID, done: make(chan struct{})}
+		p.mu.Lock()
+		p.jobs[pk] = j
+		p.mu.Unlock()
+		submitChunkJob(j)
+	}
+	select {
+	case p.packets <- pipelinedPacket{pk: pk, start: start, job: j}:
+		return true
+	case <-s.closed:
+		return false
+	}
+}
+
+// take returns the finished chunkJob of the packet passed and forgets it. False is returned if the packet was not
+// translated by the chunk workers.
+func (p *chunkPipeline) take(pk packet.Packet) (*chunkJob, bool) {
+	if p == nil {
+		return nil, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, ok := p.jobs[pk]
+	delete(p.jobs, pk)
+	return j, ok
+}
+
+// forwardPipelined forwards the packets in the chunkPipeline of the Session to the client in the order they were
+// pushed, waiting for every chunk to be translated before forwarding it, until the Session is closed.
+func (s *Session) forwardPipelined() {
+	for {
+		select {
+		case p := <-s.pipeline.packets:
+			if p.job != nil {
+				<-p.job.done
+			}
+			if !s.forwardServerPacket(p.pk, p.start) {
+				return
+			}
+		case <-s.closed:
+			return
+		}
+	}
+}
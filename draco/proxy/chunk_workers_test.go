@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// slowProtocol is a protocol that takes a while to convert the chunk at 0, 0 and leaves all packets unchanged.
+type slowProtocol struct {
+	draco.Protocol
+}
+
+func (slowProtocol) ConvertFromLatest(pk packet.Packet) packet.Packet {
+	if c, ok := pk.(*packet.LevelChunk); ok && c.Position == (protocol.ChunkPos{}) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	return pk
+}
+
+// syncConn is a benchConn that may be written to from multiple goroutines.
+type syncConn struct {
+	benchConn
+	mu sync.Mutex
+}
+
+func (c *syncConn) WritePacket(pk packet.Packet) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.benchConn.WritePacket(pk)
+}
+
+func (c *syncConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.benchConn.Write(b)
+}
+
+// packetIDs returns the IDs of the packets written to the syncConn.
+func (c *syncConn) packetIDs() []uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids := make([]uint32, 0, len(c.sent))
+	for _, b := range c.sent {
+		var hdr packet.Header
+		_ = hdr.Read(bytes.NewBuffer(b))
+		ids = append(ids, hdr.PacketID)
+	}
+	return ids
+}
+
+func TestChunkWorkersKeepOrder(t *testing.T) {
+	SetChunkWorkers(2)
+	defer SetChunkWorkers(0)
+
+	conn := &syncConn{benchConn: benchConn{proto: slowProtocol{}}}
+	s := NewSession(conn, conn, Backend{})
+	if s.pipeline == nil {
+		t.Fatal("expected chunks of a client on an older protocol to be translated by the chunk workers")
+	}
+	defer s.close()
+	go s.forwardPipelined()
+
+	pks := []packet.Packet{
+		&packet.LevelChunk{Position: protocol.ChunkPos{0, 0}},
+		&packet.Text{Message: "after the slow chunk"},
+		&packet.LevelChunk{Position: protocol.ChunkPos{1, 0}},
+		&packet.SubChunk{},
+	}
+	for _, pk := range pks {
+		if !s.pipeline.push(s, pk, time.Now()) {
+			t.Fatal("pipeline closed")
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(conn.packetIDs()) < len(pks) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	ids := conn.packetIDs()
+	if len(ids) != len(pks) {
+		t.Fatalf("%v packets written, expected %v", len(ids), len(pks))
+	}
+	for i, pk := range pks {
+		if ids[i] != pk.ID() {
+			t.Errorf("packet %v written has ID %v, expected %v", i, ids[i], pk.ID())
+		}
+	}
+	if _, ok := s.pipeline.take(pks[0]); ok {
+		t.Error("expected the job of a written chunk to be forgotten")
+	}
+}
+
+func TestChunkWorkersLatestProtocol(t *testing.T) {
+	SetChunkWorkers(1)
+	defer SetChunkWorkers(0)
+	conn := &syncConn{}
+	if s := NewSession(conn, conn, Backend{}); s.pipeline != nil {
+		t.Error("expected chunks of a client on the latest protocol not to be pipelined")
+	}
+}
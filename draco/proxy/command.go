@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/command"
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Command is a command that is run by the proxy itself rather than forwarded to the backend.
+type Command struct {
+	// Name is the name of the command, such as "potato", which players run as /potato.
+	Name string
+	// Aliases holds other names that the command may be run with.
+	Aliases []string
+	// Description is a short description of what the command does.
+	Description string
+	// Overloads holds the usages of the command shown to clients, of which the argument types are those of the
+	// latest protocol version, such as looked up using command.ArgType. If empty, the command is shown with a single
+	// optional parameter accepting any text.
+	Overloads []protocol.CommandOverload
+	// Run runs the command for the Session passed, with the arguments that followed the name of the command.
+	Run func(s *Session, args []string)
+}
+
+var (
+	// proxyCommandMu guards proxyCommands.
+	proxyCommandMu sync.RWMutex
+	// proxyCommands holds the commands registered using RegisterCommand, keyed by their name and aliases.
+	proxyCommands = map[string]Command{}
+)
+
+// RegisterCommand registers a Command run by the proxy. Commands run by players with the name or any of the
+// aliases of the Command are no longer forwarded to the backend, unless they are blocked using BlockCommand.
+// Registering a Command with the name of an existing one replaces it.
+func RegisterCommand(c Command) {
+	proxyCommandMu.Lock()
+	defer proxyCommandMu.Unlock()
+	for _, name := range append([]string{c.Name}, c.Aliases...) {
+		proxyCommands[strings.ToLower(name)] = c
+	}
+}
+
+// Commands returns all commands registered using RegisterCommand, sorted by name.
+func Commands() []Command {
+	proxyCommandMu.RLock()
+	defer proxyCommandMu.RUnlock()
+	commands := make([]Command, 0, len(proxyCommands))
+	for name, c := range proxyCommands {
+		if strings.EqualFold(name, c.Name) {
+			commands = append(commands, c)
+		}
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	return commands
+}
+
+// mergeCommands adds the commands registered using RegisterCommand to the commands in the AvailableCommands packet
+// passed, so that clients suggest them. Commands of the backend with the name or an alias of one of these commands
+// are left out, as the proxy runs them in place of the backend.
+func mergeCommands(pk *packet.AvailableCommands) {
+	commands := Commands()
+	if len(commands) == 0 {
+		return
+	}
+	names := map[string]struct{}{}
+	for _, c := range commands {
+		for _, name := range append([]string{c.Name}, c.Aliases...) {
+			names[strings.ToLower(name)] = struct{}{}
+		}
+	}
+	merged := make([]protocol.Command, 0, len(pk.Commands)+len(commands))
+	for _, c := range pk.Commands {
+		if _, ok := names[strings.ToLower(c.Name)]; !ok {
+			merged = append(merged, c)
+		}
+	}
+	for _, c := range commands {
+		overloads := c.Overloads
+		if len(overloads) == 0 {
+			overloads = []protocol.CommandOverload{{Parameters: []protocol.CommandParameter{{Name: "args", Type: protocol.CommandArgValid | rawTextArgType(), Optional: true}}}}
+		}
+		merged = append(merged, protocol.Command{
+			Name:        strings.ToLower(c.Name),
+			Description: c.Description,
+			Aliases:     c.Aliases,
+			Overloads:   overloads,
+		})
+	}
+	pk.Commands = merged
+}
+
+// rawTextArgType returns the ID of the argument type of parameters accepting any text in the latest protocol.
+func rawTextArgType() uint32 {
+	if id, ok := command.ArgType(latestmappings.Version, "raw_text"); ok {
+		return id
+	}
+	return protocol.CommandArgTypeRawText
+}
+
+func init() {
+	Handle(ServerToClient, func(s *Session, pk *packet.AvailableCommands) Action {
+		mergeCommands(pk)
+		return Forward
+	})
+	Handle(ClientToServer, func(s *Session, pk *packet.CommandRequest) Action {
+		name := commandName(pk.CommandLine)
+		if blockCommandRequest(s, name) == Drop || s.coolDown(true) == Drop {
+			return Drop
+		}
+
+		proxyCommandMu.RLock()
+		c, ok := proxyCommands[name]
+		proxyCommandMu.RUnlock()
+		if !ok {
+			return Forward
+		}
+		c.Run(s, strings.Fields(pk.CommandLine)[1:])
+		return Drop
+	})
+}
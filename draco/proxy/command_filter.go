@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// blockedCommand is a command blocked using BlockCommand.
+type blockedCommand struct {
+	roles   map[Role]struct{}
+	message string
+}
+
+var (
+	// commandMu guards blockedCommands.
+	commandMu sync.RWMutex
+	// blockedCommands holds the commands blocked using BlockCommand, keyed by their name.
+	blockedCommands = map[string]blockedCommand{}
+)
+
+// BlockCommand blocks the command with the name passed, such as "me", for players with any of the roles passed.
+// Commands run by these players are never forwarded to the backend, and the message passed is sent to the player
+// instead. If the message is empty, a translated default message is sent.
+// Commands are matched regardless of case and namespace, so blocking "me" also blocks "/minecraft:me".
+func BlockCommand(name, message string, roles ...Role) {
+	b := blockedCommand{roles: make(map[Role]struct{}, len(roles)), message: message}
+	for _, r := range roles {
+		b.roles[r] = struct{}{}
+	}
+	commandMu.Lock()
+	defer commandMu.Unlock()
+	blockedCommands[strings.ToLower(name)] = b
+}
+
+// UnblockCommands unblocks all commands blocked using BlockCommand.
+func UnblockCommands() {
+	commandMu.Lock()
+	defer commandMu.Unlock()
+	blockedCommands = map[string]blockedCommand{}
+}
+
+// commandName returns the name of the command in the command line passed, without a leading slash or namespace.
+func commandName(line string) string {
+	name := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(line), "/"))
+	if i := strings.IndexAny(name, " \t"); i != -1 {
+		name = name[:i]
+	}
+	if i := strings.LastIndexByte(name, ':'); i != -1 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// blockCommandRequest drops the command with the name passed if it is blocked for the Role of the Session passed,
+// notifying the player that they are not allowed to use it.
+func blockCommandRequest(s *Session, name string) Action {
+	commandMu.RLock()
+	b, ok := blockedCommands[name]
+	commandMu.RUnlock()
+	if !ok {
+		return Forward
+	}
+	if _, blocked := b.roles[s.Role()]; !blocked {
+		return Forward
+	}
+	message := b.message
+	if message == "" {
+		message = s.Translate("command.blocked", name)
+	}
+	_ = s.Client().WritePacket(&packet.Text{TextType: packet.TextTypeRaw, Message: message})
+	return Drop
+}
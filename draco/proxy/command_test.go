@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestMergeCommands(t *testing.T) {
+	RegisterCommand(Command{Name: "hub", Aliases: []string{"lobby"}, Description: "Sends you to the hub"})
+	defer func() {
+		proxyCommandMu.Lock()
+		delete(proxyCommands, "hub")
+		delete(proxyCommands, "lobby")
+		proxyCommandMu.Unlock()
+	}()
+
+	pk := &packet.AvailableCommands{Commands: []protocol.Command{{Name: "give"}, {Name: "lobby"}}}
+	mergeCommands(pk)
+	found := map[string]protocol.Command{}
+	for _, c := range pk.Commands {
+		if _, ok := found[c.Name]; ok {
+			t.Errorf("command %v listed twice", c.Name)
+		}
+		found[c.Name] = c
+	}
+	if _, ok := found["lobby"]; ok {
+		t.Error("expected the command of the backend run by the proxy to be left out")
+	}
+	if _, ok := found["give"]; !ok {
+		t.Error("expected the other commands of the backend to be kept")
+	}
+	hub, ok := found["hub"]
+	if !ok || len(hub.Overloads) != 1 || !hub.Overloads[0].Parameters[0].Optional {
+		t.Errorf("expected the proxy command to be listed with a default overload, got %+v", hub)
+	}
+}
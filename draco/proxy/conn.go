@@ -0,0 +1,353 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Conn is a Minecraft connection that a Session forwards packets from and to. The proxy only uses connections
+// through Conn and ClientConn, so that the rest of the proxy does not depend on the connection types of the
+// gophertunnel version in use, and only this file has to be changed when they change. *minecraft.Conn implements
+// Conn.
+type Conn interface {
+	// ReadPacket reads the next packet from the connection, blocking until one is available.
+	ReadPacket() (packet.Packet, error)
+	// WritePacket writes a packet to the connection. It may be called from multiple goroutines simultaneously.
+	WritePacket(pk packet.Packet) error
+	// IdentityData returns the identity data of the player logged in over the connection.
+	IdentityData() login.IdentityData
+	// ClientData returns the client data of the player logged in over the connection.
+	ClientData() login.ClientData
+	// Latency returns the current round trip time of the connection.
+	Latency() time.Duration
+	// Close closes the connection.
+	Close() error
+}
+
+// ClientConn is the Conn of a client connected to the proxy. Unlike a connection to a backend, it may be closed
+// with a message shown to the player.
+type ClientConn interface {
+	Conn
+	// Disconnect closes the connection, showing the message passed to the player.
+	Disconnect(message string) error
+}
+
+// NewClientConn returns a ClientConn for a connection accepted by the listener passed.
+func NewClientConn(listener *minecraft.Listener, conn *minecraft.Conn) ClientConn {
+	loginMu.Lock()
+	l, ok := logins[conn.RemoteAddr().String()]
+	delete(logins, conn.RemoteAddr().String())
+	loginMu.Unlock()
+	c := listenerConn{Conn: conn, listener: listener, protocol: l.protocol, protocolKnown: ok, custom: &customBlocks{}}
+	if DevMode() {
+		identity := DevIdentity(conn.IdentityData())
+		c.identity = &identity
+	}
+	return c
+}
+
+// listenerConn implements ClientConn for a *minecraft.Conn accepted by a *minecraft.Listener.
+type listenerConn struct {
+	*minecraft.Conn
+	listener *minecraft.Listener
+	// protocol is the protocol ID that the client logged in with, as seen by ObserveLogin. protocolKnown is false
+	// if no Login packet was seen from the client.
+	protocol      int32
+	protocolKnown bool
+	// identity is the identity assigned to the client in dev mode, which replaces the identity it claims. It is nil
+	// if the proxy doesn't run in dev mode.
+	identity *login.IdentityData
+	// custom holds the encoding of the client translating the custom blocks of servers. It is shared by all copies of
+	// the listenerConn, so that the encoding may be changed once the client is attached to another server.
+	custom *customBlocks
+}
+
+// maxClientPacket is the size of the buffer that packets sent by a client with custom blocks are read into: the
+// largest batch that RakNet reassembles, 256 fragments of at most 1500 bytes. Only packets crafted to compress well
+// are larger once decompressed, so larger packets are skipped.
+const maxClientPacket = 256 * 1500
+
+// customBlocks holds the encoding of a client that was sent the custom blocks of a server, or that is attached to a
+// server with custom blocks.
+type customBlocks struct {
+	// enc holds the *customEncoding of the client. It holds nothing as long as neither the client nor its server
+	// had custom blocks, and packets are encoded by the *minecraft.Conn until then.
+	enc atomic.Value
+	// mu guards started.
+	mu sync.Mutex
+	// started holds the custom blocks that the client was started with, set by SetCustomBlocks.
+	started []protocol.BlockEntry
+	// buf is the buffer that packets are read into. ReadPacket is never called simultaneously, so it needs no mutex.
+	buf []byte
+}
+
+// customEncoding is the encoding of packets sent to and by a client with custom blocks. The protocol of a
+// *minecraft.Conn is shared by all clients on the same protocol version, so packets of such a client are encoded and
+// decoded by the proxy instead, using a protocol translating the runtime IDs of the custom blocks.
+type customEncoding struct {
+	proto    minecraft.Protocol
+	pool     packet.Pool
+	blocks   *state.Registry
+	shieldID int32
+}
+
+// encoding returns the *customEncoding of the client, or nil if packets are encoded by the *minecraft.Conn.
+func (b *customBlocks) encoding() *customEncoding {
+	if b == nil {
+		return nil
+	}
+	e, _ := b.enc.Load().(*customEncoding)
+	return e
+}
+
+// set sets the encoding of the client to translate runtime IDs of the protocol passed with the custom blocks of the
+// client and of its server passed, using draco.WithServerBlocks.
+func (b *customBlocks) set(proto minecraft.Protocol, shieldID int32, client, server []protocol.BlockEntry) error {
+	proto, blocks, err := draco.WithServerBlocks(proto, client, server)
+	if err != nil {
+		return fmt.Errorf("custom blocks: %w", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.started = client
+	if blocks == state.Default() && b.encoding() == nil {
+		return nil
+	}
+	// Once set, the encoding is never reset to that of the *minecraft.Conn, as ReadPacket may be reading the next
+	// packet of the client with it.
+	b.enc.Store(&customEncoding{proto: proto, pool: proto.Packets(), blocks: blocks, shieldID: shieldID})
+	return nil
+}
+
+// SetCustomBlocks makes the client passed encode packets with the custom blocks held by the game data passed, which
+// must be the game data that the client is started with. Clients merge the custom blocks of the StartGame packet
+// into their block palette, which changes the runtime IDs of other block states too, so packets written to and read
+// from the client must be translated with a palette holding the same custom blocks. Nothing is done if the client is
+// not accepted by a *minecraft.Listener or its protocol is not known, or if it is on the latest protocol, whose
+// packets are not translated.
+func SetCustomBlocks(c ClientConn, data minecraft.GameData) error {
+	lc, ok := c.(listenerConn)
+	if !ok {
+		return nil
+	}
+	proto, _, ok := lc.encoding()
+	if !ok || proto == nil {
+		return nil
+	}
+	return lc.custom.set(proto, shieldRuntimeID(data.Items), data.CustomBlocks, data.CustomBlocks)
+}
+
+// SetServerBlocks makes the client passed translate the runtime IDs of a server holding the custom blocks passed, as
+// sent by the server in its StartGame packet, after the client was started with other game data, like a client that
+// was challenged or transferred. The runtime IDs of the client are still those of the custom blocks that it was
+// started with (see SetCustomBlocks), so custom blocks of the server that the client doesn't know are translated to
+// the fallback block, and other blocks to the same block. Like SetCustomBlocks, clients on the latest protocol are
+// left unchanged.
+func SetServerBlocks(c ClientConn, custom []protocol.BlockEntry) error {
+	lc, ok := c.(listenerConn)
+	if !ok {
+		return nil
+	}
+	proto, shieldID, ok := lc.encoding()
+	if !ok || proto == nil {
+		return nil
+	}
+	lc.custom.mu.Lock()
+	started := lc.custom.started
+	lc.custom.mu.Unlock()
+	return lc.custom.set(proto, shieldID, started, custom)
+}
+
+// WritePacket ...
+func (c listenerConn) WritePacket(pk packet.Packet) error {
+	e := c.custom.encoding()
+	if e == nil {
+		return c.Conn.WritePacket(pk)
+	}
+	_, err := c.Conn.Write(encodePacket(e.proto, e.shieldID, pk))
+	return err
+}
+
+// ReadPacket ...
+func (c listenerConn) ReadPacket() (packet.Packet, error) {
+	if c.custom.encoding() == nil {
+		return c.Conn.ReadPacket()
+	}
+	if c.custom.buf == nil {
+		c.custom.buf = make([]byte, maxClientPacket)
+	}
+	for {
+		n, err := c.Conn.Read(c.custom.buf)
+		if bufferTooSmall(err) {
+			logging.Default().Warn("skipping packet of client larger than buffer", "address", c.RemoteAddr().String(), "size", maxClientPacket)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The encoding is looked up again, as the client may have been attached to another server in the meantime.
+		pk, err := c.custom.encoding().decode(c.custom.buf[:n])
+		if err != nil {
+			// Like a *minecraft.Conn, packets that can't be decoded are logged and skipped.
+			logging.Default().Warn("error decoding packet of client", "address", c.RemoteAddr().String(), "err", err)
+			continue
+		}
+		return pk, nil
+	}
+}
+
+// decode decodes a packet, including its header, read from the client, and converts it to the latest protocol.
+func (e *customEncoding) decode(data []byte) (pk packet.Packet, err error) {
+	buf := bytes.NewBuffer(data)
+	var hdr packet.Header
+	if err := hdr.Read(buf); err != nil {
+		return nil, fmt.Errorf("read packet header: %w", err)
+	}
+	if f, ok := e.pool[hdr.PacketID]; ok {
+		pk = f()
+	} else {
+		pk = &packet.Unknown{PacketID: hdr.PacketID}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%T: %v", pk, r)
+		}
+	}()
+	pk.Unmarshal(protocol.NewReader(buf, e.shieldID))
+	if buf.Len() != 0 {
+		return nil, fmt.Errorf("%T: %v unread bytes left", pk, buf.Len())
+	}
+	return e.proto.ConvertToLatest(pk), nil
+}
+
+// bufferTooSmall checks if the error passed, as returned by (*minecraft.Conn).Read, was returned because the packet
+// read was larger than the buffer passed. gophertunnel doesn't export the error, so it is recognised by its message.
+func bufferTooSmall(err error) bool {
+	var op *net.OpError
+	return errors.As(err, &op) && op.Err != nil && strings.HasPrefix(op.Err.Error(), "a message sent was larger than the buffer")
+}
+
+// clientBlocks returns the state.Registry holding the block palettes that packets written to the client passed are
+// translated with: state.Default, unless the client or its server have custom blocks (see SetCustomBlocks and
+// SetServerBlocks).
+func clientBlocks(c ClientConn) *state.Registry {
+	if c, ok := c.(listenerConn); ok {
+		if e := c.custom.encoding(); e != nil {
+			return e.blocks
+		}
+	}
+	return state.Default()
+}
+
+// IdentityData ...
+func (c listenerConn) IdentityData() login.IdentityData {
+	if c.identity != nil {
+		return *c.identity
+	}
+	return c.Conn.IdentityData()
+}
+
+// Disconnect ...
+func (c listenerConn) Disconnect(message string) error {
+	return c.listener.Disconnect(c.Conn, message)
+}
+
+// encoding returns the protocol that packets written to the connection are encoded with, and the runtime ID of
+// the shield item that the client knows. A nil protocol is returned for clients on the latest protocol, whose
+// packets don't have to be converted. False is returned if the protocol of the client is not known.
+func (c listenerConn) encoding() (minecraft.Protocol, int32, bool) {
+	if e := c.custom.encoding(); e != nil {
+		return e.proto, e.shieldID, true
+	}
+	if !c.protocolKnown {
+		return nil, 0, false
+	}
+	shieldID := shieldRuntimeID(c.GameData().Items)
+	if c.protocol == protocol.CurrentProtocol {
+		return nil, shieldID, true
+	}
+	p, ok := draco.ProtocolByID(c.protocol)
+	return p, shieldID, ok
+}
+
+// shieldRuntimeID returns the runtime ID of the shield among the items passed, which clients need to decode item
+// stacks, or 0 if the items hold no shield.
+func shieldRuntimeID(items []protocol.ItemEntry) int32 {
+	for _, it := range items {
+		if it.Name == "minecraft:shield" {
+			return int32(it.RuntimeID)
+		}
+	}
+	return 0
+}
+
+// loginTTL is the duration after which the protocol of a client that never finished joining is forgotten.
+const loginTTL = time.Minute
+
+// loginProtocol is the protocol that a client logged in with.
+type loginProtocol struct {
+	protocol int32
+	time     time.Time
+}
+
+var (
+	// loginMu guards logins.
+	loginMu sync.Mutex
+	// logins holds the protocols that clients logged in with, keyed by their address, until NewClientConn is called
+	// for their connection.
+	logins = map[string]loginProtocol{}
+)
+
+// ObserveLogin inspects a packet read by a minecraft.Listener, remembering the protocol that the client logged in
+// with if it is a Login packet, so that packets broadcast to a Group are encoded once for all clients on the same
+// protocol. ObserveLogin has the signature of minecraft.ListenConfig.PacketFunc. Clients that joined a listener
+// without it receive broadcasts like any other packet.
+func ObserveLogin(header packet.Header, payload []byte, src, _ net.Addr) {
+	id, ok := draco.LoginProtocol(header, payload)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	loginMu.Lock()
+	defer loginMu.Unlock()
+	for addr, l := range logins {
+		if now.Sub(l.time) > loginTTL {
+			delete(logins, addr)
+		}
+	}
+	logins[src.String()] = loginProtocol{protocol: id, time: now}
+}
+
+// disconnectMessage returns the message that a connection was closed with by the other end, if err was returned
+// because it was disconnected.
+func disconnectMessage(err error) (string, bool) {
+	var disconnect minecraft.DisconnectError
+	if errors.As(err, &disconnect) {
+		return disconnect.Error(), true
+	}
+	return "", false
+}
+
+// gameData returns the game data that the backend sent over the Conn passed when the player spawned. False is
+// returned if the Conn does not hold game data, which is only the case for connections that are not a
+// *minecraft.Conn.
+func gameData(c Conn) (minecraft.GameData, bool) {
+	if c, ok := c.(interface{ GameData() minecraft.GameData }); ok {
+		return c.GameData(), true
+	}
+	return minecraft.GameData{}, false
+}
@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/legacymappings"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestCustomEncoding(t *testing.T) {
+	entries := []protocol.BlockEntry{{Name: "draco:custom"}}
+	proto, blocks, err := draco.WithCustomBlocks(draco.Protocol{}, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &customEncoding{proto: proto, pool: proto.Packets(), blocks: blocks}
+	legacy, _ := blocks.PaletteOf(legacymappings.Version)
+	latest, _ := blocks.PaletteOf(latestmappings.Version)
+	legacyRID, _ := legacy.RuntimeID("draco:custom", nil)
+	latestRID, _ := latest.RuntimeID("draco:custom", nil)
+
+	// The client places the custom block, referring to it by its runtime ID in its own palette.
+	buf := bytes.NewBuffer(nil)
+	_ = (&packet.Header{PacketID: packet.IDInventoryTransaction}).Write(buf)
+	(&packet.InventoryTransaction{TransactionData: &protocol.UseItemTransactionData{BlockRuntimeID: legacyRID}}).Marshal(protocol.NewWriter(buf, 0))
+	pk, err := e.decode(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, ok := pk.(*packet.InventoryTransaction).TransactionData.(*protocol.UseItemTransactionData)
+	if !ok || data.BlockRuntimeID != latestRID {
+		t.Errorf("custom block of client read as %#v, expected runtime ID %v", pk, latestRID)
+	}
+	if _, err := e.decode(append(buf.Bytes(), 0)); err == nil {
+		t.Error("expected a packet with bytes left to fail to decode")
+	}
+
+	conn := &recordConn{}
+	if err := SetCustomBlocks(conn, minecraft.GameData{CustomBlocks: entries}); err != nil {
+		t.Errorf("expected a connection not accepted by a listener to be left unchanged, got %v", err)
+	}
+	if clientBlocks(conn) != state.Default() {
+		t.Error("expected a connection without custom blocks to use the default registry")
+	}
+}
+
+func TestSetServerBlocks(t *testing.T) {
+	a, b := []protocol.BlockEntry{{Name: "draco:a"}}, []protocol.BlockEntry{{Name: "draco:b"}}
+	// serverRID returns the runtime ID that a server with the custom blocks passed sends the block with the name
+	// passed with, and clientRID the runtime ID that a client started with the custom blocks passed knows it by.
+	serverRID := func(custom []protocol.BlockEntry, name string) uint32 {
+		_, blocks, _ := draco.WithCustomBlocks(draco.Protocol{}, custom)
+		p, _ := blocks.PaletteOf(latestmappings.Version)
+		rid, _ := p.RuntimeID(name, nil)
+		return rid
+	}
+	clientRID := func(custom []protocol.BlockEntry, name string) uint32 {
+		_, blocks, _ := draco.WithCustomBlocks(draco.Protocol{}, custom)
+		p, _ := blocks.PaletteOf(legacymappings.Version)
+		rid, _ := p.RuntimeID(name, nil)
+		return rid
+	}
+	// fallback returns the runtime ID of the fallback block in the palette of a client started with the custom
+	// blocks passed.
+	fallback := func(custom []protocol.BlockEntry) uint32 {
+		_, blocks, _ := draco.WithCustomBlocks(draco.Protocol{}, custom)
+		rid, _ := blocks.Fallback(legacymappings.Version)
+		return rid
+	}
+
+	tests := []struct {
+		name           string
+		client, server []protocol.BlockEntry
+		block          string
+		expected       uint32
+	}{
+		// Challenged clients are started with the custom blocks of the challenge, which may differ from those of
+		// the backend they are attached to.
+		{"challenged without custom blocks", nil, a, "minecraft:info_update", clientRID(nil, "minecraft:info_update")},
+		{"challenged without custom blocks", nil, a, "draco:a", fallback(nil)},
+		// Transferred clients keep the custom blocks of the backend they first joined.
+		{"transferred", a, b, "minecraft:info_update", clientRID(a, "minecraft:info_update")},
+		{"transferred", a, b, "draco:b", fallback(a)},
+		{"transferred back", a, a, "draco:a", clientRID(a, "draco:a")},
+		{"transferred to backend without custom blocks", a, nil, "minecraft:info_update", clientRID(a, "minecraft:info_update")},
+	}
+	for _, test := range tests {
+		c := listenerConn{custom: &customBlocks{}}
+		if err := c.custom.set(draco.Protocol{}, 0, test.client, test.client); err != nil {
+			t.Fatal(err)
+		}
+		if test.client != nil {
+			// Clients started with custom blocks are attached to other servers using SetServerBlocks.
+			if err := SetServerBlocks(c, test.server); err != nil {
+				t.Fatalf("%v: %v", test.name, err)
+			}
+		} else if err := c.custom.set(draco.Protocol{}, 0, nil, test.server); err != nil {
+			t.Fatal(err)
+		}
+		e := c.custom.encoding()
+		if e == nil {
+			t.Fatalf("%v: no encoding set", test.name)
+		}
+		pk := e.proto.ConvertFromLatest(&packet.UpdateBlock{NewBlockRuntimeID: serverRID(test.server, test.block)})
+		if rid := pk.(*packet.UpdateBlock).NewBlockRuntimeID; rid != test.expected {
+			t.Errorf("%v: %v of server sent to client as %v, expected %v", test.name, test.block, rid, test.expected)
+		}
+		if clientBlocks(c) != e.blocks {
+			t.Errorf("%v: chunks are not translated with the registry of the encoding", test.name)
+		}
+	}
+}
+
+func TestBufferTooSmall(t *testing.T) {
+	tooSmall := &net.OpError{Op: "read", Net: "minecraft", Err: errors.New("a message sent was larger than the buffer used to receive the message into")}
+	if !bufferTooSmall(tooSmall) {
+		t.Error("expected a packet larger than the buffer to be recognised")
+	}
+	if bufferTooSmall(&net.OpError{Op: "read", Net: "minecraft", Err: net.ErrClosed}) || bufferTooSmall(nil) {
+		t.Error("expected other errors not to be taken for a packet larger than the buffer")
+	}
+}
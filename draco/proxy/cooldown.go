@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Cooldowns holds the minimum durations between the chat messages and between the commands of a player, which are
+// enforced at the proxy, so that the messages and commands of players spamming never reach the backend.
+type Cooldowns struct {
+	// Chat is the minimum duration between two chat messages of a player, and Commands that between two commands,
+	// including the commands of the proxy. If 0, there is no cooldown.
+	Chat, Commands time.Duration
+	// Bypass holds the roles of the players that the cooldowns don't apply to.
+	Bypass []Role
+}
+
+var (
+	// cooldownMu guards cooldowns.
+	cooldownMu sync.RWMutex
+	// cooldowns holds the Cooldowns set using SetCooldowns.
+	cooldowns Cooldowns
+)
+
+// SetCooldowns sets the cooldowns of the chat messages and commands of players. Messages and commands sent before
+// their cooldown passed are dropped, and the player is told how long to wait instead.
+func SetCooldowns(c Cooldowns) {
+	cooldownMu.Lock()
+	defer cooldownMu.Unlock()
+	cooldowns = c
+}
+
+// cooldown holds the times that the client of a Session last sent a chat message and a command that were forwarded.
+// It is only used by the goroutine reading from the client.
+type cooldown struct {
+	chat, command time.Time
+}
+
+func init() {
+	Handle(ClientToServer, func(s *Session, pk *packet.Text) Action {
+		if pk.TextType != packet.TextTypeChat {
+			return Forward
+		}
+		return s.coolDown(false)
+	})
+}
+
+// coolDown checks if the cooldown of the chat messages of the Session passed, or of its commands if command is true,
+// has passed. If not, the message or command must be dropped, and the player is told how long to wait. Otherwise,
+// the cooldown starts again.
+func (s *Session) coolDown(command bool) Action {
+	cooldownMu.RLock()
+	c := cooldowns
+	cooldownMu.RUnlock()
+	d, last, key, kind := c.Chat, &s.cooldown.chat, "cooldown.chat", "chat"
+	if command {
+		d, last, key, kind = c.Commands, &s.cooldown.command, "cooldown.command", "command"
+	}
+	if d <= 0 {
+		return Forward
+	}
+	for _, r := range c.Bypass {
+		if s.Role() == r {
+			return Forward
+		}
+	}
+	now := time.Now()
+	if wait := last.Add(d).Sub(now); wait > 0 {
+		metrics.AddTo("cooldown_drops", kind, 1)
+		_ = s.Client().WritePacket(&packet.Text{TextType: packet.TextTypeRaw, Message: s.Translate(key, waitSeconds(wait))})
+		return Drop
+	}
+	*last = now
+	return Forward
+}
+
+// waitSeconds returns the duration passed in whole seconds, rounded up, so that players are never told to wait 0
+// seconds.
+func waitSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
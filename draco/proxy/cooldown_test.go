@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestCooldowns(t *testing.T) {
+	SetCooldowns(Cooldowns{Chat: time.Minute, Commands: time.Minute, Bypass: []Role{RoleGuest}})
+	defer SetCooldowns(Cooldowns{})
+
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{Name: "lobby"})
+	chat := func() Action {
+		return handle(s, ClientToServer, &packet.Text{TextType: packet.TextTypeChat, Message: "hi"})
+	}
+	if chat() != Forward {
+		t.Fatal("first chat message was dropped")
+	}
+	if chat() != Drop {
+		t.Fatal("chat message sent during the cooldown was forwarded")
+	}
+	if len(conn.packets) != 1 || !strings.Contains(conn.packets[0].(*packet.Text).Message, "60 seconds") {
+		t.Errorf("player was not told how long to wait: %v", conn.packets)
+	}
+	// Chat messages and commands have cooldowns of their own.
+	if handle(s, ClientToServer, &packet.CommandRequest{CommandLine: "/say hi"}) != Forward {
+		t.Error("command was dropped during the cooldown of chat messages")
+	}
+	if handle(s, ClientToServer, &packet.CommandRequest{CommandLine: "/say hi"}) != Drop {
+		t.Error("command sent during the cooldown was forwarded")
+	}
+
+	s.cooldown.chat = time.Now().Add(-time.Minute)
+	if chat() != Forward {
+		t.Error("chat message was dropped once the cooldown passed")
+	}
+
+	bypass := NewSession(conn, conn, Backend{Name: "lobby"})
+	bypass.role = RoleGuest
+	for i := 0; i < 2; i++ {
+		if bypass.coolDown(false) != Forward {
+			t.Error("cooldown applied to a role bypassing it")
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+)
+
+// devMode is 1 if the proxy runs in dev mode, as set using SetDevMode.
+var devMode uint32
+
+// devNamespace is the namespace of the UUIDs of the identities returned by DevIdentity.
+var devNamespace = uuid.MustParse("5c3b2f0e-7d1a-4b8e-9f6a-2e4d8c1b0a97")
+
+// SetDevMode sets if the proxy runs in dev mode, in which clients join without XBOX Live and are assigned the
+// identity returned by DevIdentity for the name they claim, so that the proxy and its backends may be run in tests
+// without any Microsoft account.
+func SetDevMode(enabled bool) {
+	var v uint32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreUint32(&devMode, v)
+}
+
+// DevMode checks if the proxy runs in dev mode, as set using SetDevMode.
+func DevMode() bool {
+	return atomic.LoadUint32(&devMode) == 1
+}
+
+// DevIdentity returns the fake identity of a player that claims the identity passed in dev mode. The XUID and UUID
+// of the identity are derived from the name claimed, ignoring case, so that a player joining with the same name is
+// always assigned the same identity, which bans, positions and other data stored by XUID rely on. The XUIDs have the
+// same length as real ones, but never start with the digits of those.
+func DevIdentity(claimed login.IdentityData) login.IdentityData {
+	name := strings.ToLower(claimed.DisplayName)
+	sum := sha256.Sum256([]byte("draco:" + name))
+	n := strconv.FormatUint(binary.BigEndian.Uint64(sum[:8])%1e12, 10)
+	return login.IdentityData{
+		XUID:        "9999" + strings.Repeat("0", 12-len(n)) + n,
+		Identity:    uuid.NewSHA1(devNamespace, []byte(name)).String(),
+		DisplayName: claimed.DisplayName,
+		TitleID:     claimed.TitleID,
+	}
+}
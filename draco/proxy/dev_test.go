@@ -0,0 +1,20 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+)
+
+func TestDevIdentity(t *testing.T) {
+	a := DevIdentity(login.IdentityData{DisplayName: "Steve", XUID: "claimed", Identity: "claimed"})
+	if a.DisplayName != "Steve" || len(a.XUID) != 16 || a.XUID == "claimed" || a.Identity == "claimed" {
+		t.Fatalf("unexpected identity %+v", a)
+	}
+	if b := DevIdentity(login.IdentityData{DisplayName: "steve"}); b.XUID != a.XUID || b.Identity != a.Identity {
+		t.Errorf("expected the same identity for the same name in another case, got %+v and %+v", a, b)
+	}
+	if b := DevIdentity(login.IdentityData{DisplayName: "Alex"}); b.XUID == a.XUID || b.Identity == a.Identity {
+		t.Errorf("expected different identities for different names, got %+v and %+v", a, b)
+	}
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+)
+
+// ErrDialLimited is returned by AcquireDial if a dial to a backend could not be started within the MaxWait of the
+// DialLimit.
+var ErrDialLimited = errors.New("too many connections to backend at once")
+
+// DialLimit limits the dials to every backend, so that a storm of players reconnecting at once, such as after a
+// restart of the proxy, can't flood a small backend with connections. Joins exceeding the limit wait until a dial
+// may be started.
+type DialLimit struct {
+	// MaxConcurrent is the maximum amount of dials to a single backend in progress at the same time, which lasts
+	// until the player logged in to it. If 0 or less, there is no limit.
+	MaxConcurrent int
+	// PerSecond is the amount of dials per second that may be started to a single backend, of which Burst may be
+	// started at once. If PerSecond is 0 or less, there is no limit. If Burst is 0, it is PerSecond.
+	PerSecond float64
+	Burst     int
+	// MaxWait is the longest that a join waits until a dial may be started. If 0, it is five seconds.
+	MaxWait time.Duration
+}
+
+var (
+	// dialMu guards dialConf and dialers.
+	dialMu sync.Mutex
+	// dialConf is the DialLimit currently used.
+	dialConf = DialLimit{MaxWait: 5 * time.Second}
+	// dialers holds the dials to every backend, keyed by the address of the backend.
+	dialers = map[string]*dialBucket{}
+)
+
+// dialBucket holds the dials to a single backend. It is a token bucket that is refilled at DialLimit.PerSecond.
+type dialBucket struct {
+	tokens float64
+	last   time.Time
+	active int
+	// released is closed and replaced when a dial finished, which wakes up the joins waiting for it.
+	released chan struct{}
+}
+
+// SetDialLimit sets the DialLimit applied to the dials to every backend.
+func SetDialLimit(l DialLimit) {
+	if l.MaxWait == 0 {
+		l.MaxWait = 5 * time.Second
+	}
+	dialMu.Lock()
+	defer dialMu.Unlock()
+	dialConf = l
+}
+
+// AcquireDial waits until a dial to the Backend passed may be started under the DialLimit set using SetDialLimit.
+// The function returned must be called once the dial finished, whether it succeeded or not. ErrDialLimited is
+// returned if the dial could not be started within the MaxWait of the DialLimit.
+func AcquireDial(b Backend) (release func(), err error) {
+	start := time.Now()
+	dialMu.Lock()
+	conf := dialConf
+	deadline := start.Add(conf.MaxWait)
+	for {
+		d, ok := dialers[b.Address]
+		if !ok {
+			d = &dialBucket{tokens: conf.burst(), last: start, released: make(chan struct{})}
+			dialers[b.Address] = d
+		}
+		now := time.Now()
+		d.refill(now, conf)
+		if (conf.MaxConcurrent <= 0 || d.active < conf.MaxConcurrent) && (conf.PerSecond <= 0 || d.tokens >= 1) {
+			d.active++
+			if conf.PerSecond > 0 {
+				d.tokens--
+			}
+			dialMu.Unlock()
+			if now.Sub(start) > time.Millisecond {
+				// The join had to wait for the dial.
+				metrics.AddTo("dials_queued", b.Name, 1)
+			}
+			var once sync.Once
+			return func() { once.Do(func() { d.release(b) }) }, nil
+		}
+		wait := deadline.Sub(now)
+		if wait <= 0 {
+			dialMu.Unlock()
+			metrics.AddTo("dials_limited", b.Name, 1)
+			return nil, ErrDialLimited
+		}
+		if conf.PerSecond > 0 && d.tokens < 1 {
+			if refilled := time.Duration((1 - d.tokens) / conf.PerSecond * float64(time.Second)); refilled < wait {
+				wait = refilled
+			}
+		}
+		released := d.released
+		dialMu.Unlock()
+
+		t := time.NewTimer(wait)
+		select {
+		case <-released:
+		case <-t.C:
+		}
+		t.Stop()
+		dialMu.Lock()
+	}
+}
+
+// burst returns the maximum amount of tokens in a dialBucket.
+func (l DialLimit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	if l.PerSecond < 1 {
+		return 1
+	}
+	return l.PerSecond
+}
+
+// refill adds the tokens refilled since the bucket was last refilled. dialMu must be held when calling refill.
+func (d *dialBucket) refill(now time.Time, conf DialLimit) {
+	if conf.PerSecond > 0 {
+		d.tokens += now.Sub(d.last).Seconds() * conf.PerSecond
+		if max := conf.burst(); d.tokens > max {
+			d.tokens = max
+		}
+	}
+	d.last = now
+}
+
+// release releases a dial to the Backend passed that finished, waking up the joins waiting for it.
+func (d *dialBucket) release(b Backend) {
+	dialMu.Lock()
+	defer dialMu.Unlock()
+	d.active--
+	d.refill(time.Now(), dialConf)
+	close(d.released)
+	d.released = make(chan struct{})
+	if d.active == 0 && d.tokens >= dialConf.burst() {
+		// The bucket is full again and no dials are in progress, so it may be forgotten.
+		delete(dialers, b.Address)
+	}
+}
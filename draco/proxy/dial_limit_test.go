@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDialLimitConcurrent(t *testing.T) {
+	SetDialLimit(DialLimit{MaxConcurrent: 1, MaxWait: 50 * time.Millisecond})
+	defer SetDialLimit(DialLimit{})
+	b := Backend{Name: "small", Address: "127.0.0.1:1"}
+
+	release, err := AcquireDial(b)
+	if err != nil {
+		t.Fatalf("first dial: %v", err)
+	}
+	if _, err := AcquireDial(b); !errors.Is(err, ErrDialLimited) {
+		t.Fatalf("second dial while the first is in progress: got %v, expected ErrDialLimited", err)
+	}
+	if _, err := AcquireDial(Backend{Address: "127.0.0.1:2"}); err != nil {
+		t.Errorf("dial to another backend: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		release, err := AcquireDial(b)
+		if err == nil {
+			release()
+		}
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	release()
+	release()
+	if err := <-done; err != nil {
+		t.Errorf("dial queued until the first finished: %v", err)
+	}
+}
+
+func TestDialLimitPerSecond(t *testing.T) {
+	SetDialLimit(DialLimit{PerSecond: 20, Burst: 2, MaxWait: time.Second})
+	defer SetDialLimit(DialLimit{})
+	b := Backend{Name: "small", Address: "127.0.0.1:3"}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := AcquireDial(b)
+		if err != nil {
+			t.Fatalf("dial %v: %v", i, err)
+		}
+		release()
+	}
+	// Two dials may be started at once, after which the third waits for a token, which takes 50ms at 20 per second.
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("three dials started within %v, expected the third to wait for the bucket to refill", elapsed)
+	}
+}
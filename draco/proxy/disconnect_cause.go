@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+)
+
+// DisconnectCause classifies why a Session was closed, so that the causes of disconnects may be counted to find
+// out what players struggle with the most.
+type DisconnectCause string
+
+const (
+	// CauseClientQuit is the cause of Sessions whose client closed the connection, such as when the player left.
+	CauseClientQuit DisconnectCause = "client_quit"
+	// CauseTimeout is the cause of Sessions whose client stopped responding. RakNet doesn't tell a timed out
+	// connection apart from one that was closed, so clients are considered timed out if they sent nothing for
+	// clientTimeoutGap before their connection was closed, as clients in game send packets every tick.
+	CauseTimeout DisconnectCause = "timeout"
+	// CauseBackendKick is the cause of Sessions whose backend disconnected the player with a message.
+	CauseBackendKick DisconnectCause = "backend_kick"
+	// CauseBackendLost is the cause of Sessions whose connection to the backend was lost without a message, and
+	// that could not fall back to another backend.
+	CauseBackendLost DisconnectCause = "backend_lost"
+	// CauseTranslationError is the cause of Sessions closed because a packet couldn't be translated.
+	CauseTranslationError DisconnectCause = "translation_error"
+	// CauseProxyKick is the cause of Sessions disconnected by the proxy itself, such as using Session.Disconnect.
+	CauseProxyKick DisconnectCause = "proxy_kick"
+	// CauseMigrated is the cause of Sessions that were migrated to another node of the cluster.
+	CauseMigrated DisconnectCause = "migrated"
+	// CauseUnknown is the cause of Sessions closed for any other reason.
+	CauseUnknown DisconnectCause = "unknown"
+)
+
+// clientTimeoutGap is the duration that a client must not have sent any packet for before its connection was closed
+// for it to be considered timed out.
+const clientTimeoutGap = time.Second * 5
+
+// DisconnectCause returns the cause of the Session being closed. False is returned if the Session is not closed
+// yet and no cause was recorded.
+func (s *Session) DisconnectCause() (DisconnectCause, bool) {
+	s.disconnectMu.Lock()
+	defer s.disconnectMu.Unlock()
+	return s.cause, s.cause != ""
+}
+
+// setCause records the cause passed as the cause of the Session being closed, unless a cause was already recorded:
+// the first cause is the one that set off closing the Session, while the others are its consequences.
+func (s *Session) setCause(c DisconnectCause) {
+	s.disconnectMu.Lock()
+	defer s.disconnectMu.Unlock()
+	if s.cause == "" {
+		s.cause = c
+	}
+}
+
+// clientActivity records when the client of a Session last sent a packet.
+type clientActivity struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// seen records that the client sent a packet just now.
+func (a *clientActivity) seen() {
+	a.mu.Lock()
+	a.last = time.Now()
+	a.mu.Unlock()
+}
+
+// idle returns the duration since the client last sent a packet, or zero if it never sent one.
+func (a *clientActivity) idle() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.last.IsZero() {
+		return 0
+	}
+	return time.Since(a.last)
+}
+
+// clientCause returns the cause of the connection to the client of the Session passed failing with the error passed,
+// which is CauseTimeout if the client was idle for clientTimeoutGap or CauseClientQuit otherwise.
+func clientCause(s *Session, err error) DisconnectCause {
+	if errors.Is(err, context.DeadlineExceeded) || s.activity.idle() >= clientTimeoutGap {
+		return CauseTimeout
+	}
+	return CauseClientQuit
+}
+
+// serverCause returns the cause of the connection to the server of a Session failing with the error passed.
+func serverCause(err error) DisconnectCause {
+	if _, ok := disconnectMessage(err); ok {
+		return CauseBackendKick
+	}
+	return CauseBackendLost
+}
+
+// countDisconnect counts the disconnect of the Session passed with the DisconnectCause passed in the "disconnects"
+// metrics group, keyed by cause, the "disconnects_by_backend" group, keyed by the backend and the cause, such as
+// "lobby/client_quit", and the "disconnects_by_version" group, keyed by the game version of the client and the cause,
+// such as "1.18.10/timeout".
+func countDisconnect(s *Session, c DisconnectCause) {
+	version := s.client.ClientData().GameVersion
+	if !validGameVersion(version) {
+		version = "unknown"
+	}
+	metrics.AddTo("disconnects", string(c), 1)
+	metrics.AddTo("disconnects_by_backend", s.Backend().Name+"/"+string(c), 1)
+	metrics.AddTo("disconnects_by_version", version+"/"+string(c), 1)
+}
+
+// DisconnectBreakdown holds the amount of disconnects counted since the proxy started, by DisconnectCause.
+type DisconnectBreakdown struct {
+	// Causes holds the amount of disconnects by cause.
+	Causes map[DisconnectCause]int64 `json:"causes"`
+	// Backends and Versions hold the amount of disconnects by cause, by the name of the backend and the game version
+	// of the client respectively.
+	Backends map[string]map[DisconnectCause]int64 `json:"backends"`
+	Versions map[string]map[DisconnectCause]int64 `json:"versions"`
+	// Top holds the causes in Causes ordered by the amount of disconnects they caused, most first, so that the causes
+	// to work on first are easily found.
+	Top []DisconnectCause `json:"top"`
+}
+
+// Disconnects returns the DisconnectBreakdown of all disconnects counted since the proxy started.
+func Disconnects() DisconnectBreakdown {
+	b := DisconnectBreakdown{Causes: map[DisconnectCause]int64{}, Backends: breakdown("disconnects_by_backend"), Versions: breakdown("disconnects_by_version")}
+	for c, n := range metrics.Group("disconnects") {
+		b.Causes[DisconnectCause(c)] = n
+		b.Top = append(b.Top, DisconnectCause(c))
+	}
+	sort.Slice(b.Top, func(i, j int) bool {
+		if b.Causes[b.Top[i]] != b.Causes[b.Top[j]] {
+			return b.Causes[b.Top[i]] > b.Causes[b.Top[j]]
+		}
+		return b.Top[i] < b.Top[j]
+	})
+	return b
+}
+
+// breakdown splits the keys of the metrics group passed, such as "lobby/client_quit", into their name and cause.
+func breakdown(group string) map[string]map[DisconnectCause]int64 {
+	m := map[string]map[DisconnectCause]int64{}
+	for key, n := range metrics.Group(group) {
+		// Causes never hold a slash, unlike the names of backends may.
+		i := strings.LastIndexByte(key, '/')
+		if i < 0 {
+			continue
+		}
+		name, c := key[:i], DisconnectCause(key[i+1:])
+		if m[name] == nil {
+			m[name] = map[DisconnectCause]int64{}
+		}
+		m[name][c] = n
+	}
+	return m
+}
@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// closingConn is a connection that fails reading packets with err once closed or after the packets in it were read.
+type closingConn struct {
+	recordConn
+	version string
+	pks     chan packet.Packet
+	err     error
+	closed  chan struct{}
+}
+
+func newClosingConn(err error, pks ...packet.Packet) *closingConn {
+	c := &closingConn{pks: make(chan packet.Packet, len(pks)), err: err, closed: make(chan struct{})}
+	for _, pk := range pks {
+		c.pks <- pk
+	}
+	return c
+}
+
+func (c *closingConn) ClientData() login.ClientData { return login.ClientData{GameVersion: c.version} }
+
+func (c *closingConn) ReadPacket() (packet.Packet, error) {
+	select {
+	case pk := <-c.pks:
+		return pk, nil
+	default:
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	<-c.closed
+	return nil, io.EOF
+}
+
+func (c *closingConn) Close() error {
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	return nil
+}
+
+// quitCause starts a Session with the client and server passed and returns the cause that it was closed with.
+func quitCause(t *testing.T, client, server *closingConn, b Backend) DisconnectCause {
+	t.Helper()
+	quits := make(chan QuitEvent, 1)
+	s := NewSession(client, server, b)
+	Subscribe(func(e QuitEvent) {
+		if e.Session == s {
+			quits <- e
+		}
+	})
+	s.Start()
+	select {
+	case e := <-quits:
+		if c, ok := s.DisconnectCause(); !ok || c != e.Cause {
+			t.Errorf("session closed with cause %v, but quit event emitted with cause %v", c, e.Cause)
+		}
+		return e.Cause
+	case <-time.After(time.Second):
+		t.Fatal("session was not closed")
+	}
+	return ""
+}
+
+func TestDisconnectCause(t *testing.T) {
+	client, server := newClosingConn(io.EOF, &packet.Text{}), newClosingConn(nil)
+	client.version = "1.18.10"
+	if c := quitCause(t, client, server, Backend{Name: "lobby"}); c != CauseClientQuit {
+		t.Errorf("client that left closed with cause %v", c)
+	}
+	if c := quitCause(t, newClosingConn(nil), newClosingConn(minecraft.DisconnectError("banned")), Backend{Name: "lobby"}); c != CauseBackendKick {
+		t.Errorf("player kicked by the backend closed with cause %v", c)
+	}
+	if c := quitCause(t, newClosingConn(nil), newClosingConn(io.EOF), Backend{Name: "lobby"}); c != CauseBackendLost {
+		t.Errorf("player of a backend gone down closed with cause %v", c)
+	}
+
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{Name: "lobby"})
+	s.setCause(CauseTimeout)
+	s.Disconnect("bye")
+	if c, _ := s.DisconnectCause(); c != CauseTimeout {
+		t.Errorf("disconnecting a session replaced its cause with %v", c)
+	}
+
+	if n := metrics.Group("disconnects_by_version")["1.18.10/"+string(CauseClientQuit)]; n != 1 {
+		t.Errorf("%v client quits counted for 1.18.10, expected 1", n)
+	}
+	b := Disconnects()
+	if b.Backends["lobby"][CauseBackendKick] < 1 || b.Versions["unknown"][CauseBackendLost] < 1 {
+		t.Errorf("disconnects not broken down by backend and version: %+v", b)
+	}
+	if len(b.Top) != len(b.Causes) {
+		t.Errorf("top causes %v don't hold all causes %v", b.Top, b.Causes)
+	}
+	for i := 1; i < len(b.Top); i++ {
+		if b.Causes[b.Top[i-1]] < b.Causes[b.Top[i]] {
+			t.Errorf("top causes not ordered: %v", b.Top)
+		}
+	}
+}
+
+func TestClientCause(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	s.activity.seen()
+	if c := clientCause(s, io.EOF); c != CauseClientQuit {
+		t.Errorf("active client closed with cause %v", c)
+	}
+	s.activity.last = time.Now().Add(-clientTimeoutGap)
+	if c := clientCause(s, io.EOF); c != CauseTimeout {
+		t.Errorf("idle client closed with cause %v", c)
+	}
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"errors"
+	"sync"
+)
+
+// DuplicateLoginPolicy specifies what happens when a player joins the proxy with the XUID of a player that is
+// already connected.
+type DuplicateLoginPolicy string
+
+const (
+	// DuplicateLoginKickOld disconnects the player already connected, telling them they logged in elsewhere, before
+	// the new player is admitted.
+	DuplicateLoginKickOld DuplicateLoginPolicy = ""
+	// DuplicateLoginReject rejects the new player, keeping the player already connected.
+	DuplicateLoginReject DuplicateLoginPolicy = "reject"
+)
+
+// ErrDuplicateLogin is returned by ClaimIdentity if a player with the same XUID is already connected or
+// connecting, and the DuplicateLoginReject policy is used.
+var ErrDuplicateLogin = errors.New("player is already logged in")
+
+var (
+	// identityMu guards duplicatePolicy and identities.
+	identityMu sync.Mutex
+	// duplicatePolicy is the DuplicateLoginPolicy used by ClaimIdentity.
+	duplicatePolicy DuplicateLoginPolicy
+	// identities holds the amount of players connected or connecting with an XUID, keyed by the XUID.
+	identities = map[string]int{}
+)
+
+// SetDuplicateLoginPolicy sets the DuplicateLoginPolicy applied to players joining the proxy.
+func SetDuplicateLoginPolicy(p DuplicateLoginPolicy) {
+	identityMu.Lock()
+	defer identityMu.Unlock()
+	duplicatePolicy = p
+}
+
+// ClaimIdentity claims the XUID passed for a player joining the proxy, applying the DuplicateLoginPolicy if a player
+// with the same XUID is already connected or connecting. It should be called before the player is connected to a
+// backend, so that the backend never sees the same player twice. An empty XUID, as held by guests, is never claimed.
+// A claim obtained must be released using ReleaseIdentity if no Session is started for it: a Session started holds
+// on to the claim and releases it once it is closed.
+func ClaimIdentity(xuid string) error {
+	if xuid == "" {
+		return nil
+	}
+	identityMu.Lock()
+	if duplicatePolicy == DuplicateLoginReject && identities[xuid] > 0 {
+		identityMu.Unlock()
+		return ErrDuplicateLogin
+	}
+	identities[xuid]++
+	policy := duplicatePolicy
+	identityMu.Unlock()
+
+	if policy != DuplicateLoginReject {
+		kickDuplicates(xuid, nil)
+	}
+	return nil
+}
+
+// ReleaseIdentity releases a claim on the XUID passed previously obtained using ClaimIdentity.
+func ReleaseIdentity(xuid string) {
+	if xuid == "" {
+		return
+	}
+	identityMu.Lock()
+	defer identityMu.Unlock()
+	if identities[xuid]--; identities[xuid] <= 0 {
+		delete(identities, xuid)
+	}
+}
+
+// kickDuplicates disconnects all sessions with the XUID passed other than the Session except, telling them that they
+// logged in elsewhere. Disconnect closes the connection to the backend before returning, so the backend has seen the
+// player leave once kickDuplicates returns.
+func kickDuplicates(xuid string, except *Session) {
+	if xuid == "" {
+		return
+	}
+	for _, s := range Sessions() {
+		if s != except && s.XUID() == xuid {
+			s.Disconnect(s.Translate("disconnect.logged_in_elsewhere"))
+		}
+	}
+}
+
+// startedDuplicate applies the DuplicateLoginPolicy to a Session that was just started. Players with the same XUID
+// that join at nearly the same time may both pass ClaimIdentity before either Session is started, in which case the
+// Session started last is kept if the DuplicateLoginKickOld policy is used.
+func startedDuplicate(s *Session) {
+	identityMu.Lock()
+	policy := duplicatePolicy
+	identityMu.Unlock()
+	if policy != DuplicateLoginReject {
+		kickDuplicates(s.XUID(), s)
+	}
+}
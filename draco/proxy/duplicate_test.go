@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+)
+
+// identityConn is a ClientConn of a player with an XUID.
+type identityConn struct {
+	recordConn
+	xuid         string
+	disconnected bool
+}
+
+func (c *identityConn) IdentityData() login.IdentityData { return login.IdentityData{XUID: c.xuid} }
+func (c *identityConn) Disconnect(string) error          { c.disconnected = true; return nil }
+
+func TestClaimIdentity(t *testing.T) {
+	defer SetDuplicateLoginPolicy(DuplicateLoginKickOld)
+
+	SetDuplicateLoginPolicy(DuplicateLoginReject)
+	if err := ClaimIdentity("1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ClaimIdentity("1"); err != ErrDuplicateLogin {
+		t.Errorf("second claim returned %v, expected %v", err, ErrDuplicateLogin)
+	}
+	ReleaseIdentity("1")
+	if err := ClaimIdentity("1"); err != nil {
+		t.Errorf("claim after release returned %v", err)
+	}
+	ReleaseIdentity("1")
+
+	SetDuplicateLoginPolicy(DuplicateLoginKickOld)
+	old := &identityConn{xuid: "2"}
+	if err := ClaimIdentity("2"); err != nil {
+		t.Fatal(err)
+	}
+	s := NewSession(old, old, Backend{})
+	s.Start()
+	defer s.close()
+	if err := ClaimIdentity("2"); err != nil {
+		t.Fatal(err)
+	}
+	defer ReleaseIdentity("2")
+	if !old.disconnected {
+		t.Error("old session was not kicked")
+	}
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"strings"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// educationGameRules holds the names of game rules that only exist in Minecraft: Education Edition. Regular
+// clients may crash or misbehave if a backend enables them.
+var educationGameRules = map[string]struct{}{
+	"allowdestructiveobjects": {},
+	"allowmobs":               {},
+	"codebuilder":             {},
+	"globalmute":              {},
+}
+
+// StripEducationFeatures registers handlers that strip education edition game rules from GameRulesChanged packets
+// and drop packets only used in Minecraft: Education Edition, so that backends enabling education features do
+// not crash regular clients. StripEducationGameData should additionally be used on the game data of the
+// backend before starting the game for the client.
+func StripEducationFeatures() {
+	Handle[packet.GameRulesChanged](ServerToClient, func(_ *Session, pk *packet.GameRulesChanged) Action {
+		pk.GameRules = stripEducationGameRules(pk.GameRules)
+		return Forward
+	})
+	drop[packet.EducationSettings](ServerToClient)
+	drop[packet.EducationResourceURI](ServerToClient)
+	drop[packet.CodeBuilder](ServerToClient)
+	drop[packet.CodeBuilderSource](ClientToServer)
+	drop[packet.LabTable](ServerToClient)
+	drop[packet.AgentAction](ServerToClient)
+}
+
+// StripEducationGameData removes all education edition game rules from the game data passed, which is sent to the
+// client in the StartGame packet.
+func StripEducationGameData(data minecraft.GameData) minecraft.GameData {
+	data.GameRules = stripEducationGameRules(data.GameRules)
+	return data
+}
+
+// stripEducationGameRules returns the game rules passed without any of the education edition game rules.
+func stripEducationGameRules(rules []protocol.GameRule) []protocol.GameRule {
+	stripped := make([]protocol.GameRule, 0, len(rules))
+	for _, rule := range rules {
+		if _, ok := educationGameRules[strings.ToLower(rule.Name)]; !ok {
+			stripped = append(stripped, rule)
+		}
+	}
+	return stripped
+}
@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/metrics"
+)
+
+// EventType is the type of an Event.
+type EventType uint8
+
+const (
+	// EventJoin is the type of a JoinEvent.
+	EventJoin EventType = iota
+	// EventQuit is the type of a QuitEvent.
+	EventQuit
+	// EventTransfer is the type of a TransferEvent.
+	EventTransfer
+	// EventPacketError is the type of a PacketErrorEvent.
+	EventPacketError
+)
+
+// String ...
+func (t EventType) String() string {
+	switch t {
+	case EventJoin:
+		return "join"
+	case EventQuit:
+		return "quit"
+	case EventTransfer:
+		return "transfer"
+	case EventPacketError:
+		return "packet error"
+	}
+	return "unknown"
+}
+
+// Event is an event of a Session, which functions registered using Subscribe are called with. It is one of
+// JoinEvent, QuitEvent, TransferEvent and PacketErrorEvent.
+type Event interface {
+	// Type returns the EventType of the Event.
+	Type() EventType
+}
+
+// JoinEvent is emitted when a player joined the proxy and its Session was started.
+type JoinEvent struct {
+	Session *Session
+	// Backend is the Backend that the player joined.
+	Backend Backend
+}
+
+// QuitEvent is emitted when a Session was closed, because the player left or was disconnected.
+type QuitEvent struct {
+	Session *Session
+	// Reason is the message that the player was disconnected with, which is the message for a lost connection if
+	// the player left by itself.
+	Reason string
+	// Cause is the DisconnectCause of the Session being closed.
+	Cause DisconnectCause
+}
+
+// TransferEvent is emitted when a Session was attached to another server, such as after a transfer or when the
+// player was moved to the limbo.
+type TransferEvent struct {
+	Session *Session
+	// From and To are the Backends that the Session was attached to before and is attached to now.
+	From, To Backend
+}
+
+// PacketErrorEvent is emitted when a packet could not be translated between the version of the player and the
+// version of its backend.
+type PacketErrorEvent struct {
+	Session *Session
+	// Direction is the Direction in which the packet travelled.
+	Direction Direction
+	// Err is the reason why the packet could not be translated.
+	Err error
+	// Disconnected specifies if the player was disconnected as a result, which is the case under the strict decode
+	// policy. Otherwise the packet was dropped.
+	Disconnected bool
+	// Count is the amount of packets of the Session that couldn't be translated in the Direction so far, including
+	// this one, as returned by Session.TranslationErrors.
+	Count uint64
+}
+
+// Type ...
+func (JoinEvent) Type() EventType { return EventJoin }
+
+// Type ...
+func (QuitEvent) Type() EventType { return EventQuit }
+
+// Type ...
+func (TransferEvent) Type() EventType { return EventTransfer }
+
+// Type ...
+func (PacketErrorEvent) Type() EventType { return EventPacketError }
+
+// eventConstraint is the constraint of the type of the events that Subscribe subscribes to.
+type eventConstraint interface {
+	JoinEvent | QuitEvent | TransferEvent | PacketErrorEvent
+	Event
+}
+
+// maxQueuedEvents is the maximum amount of events waiting to be passed to subscribers. Events emitted while it is
+// reached are dropped, so that slow subscribers never hold up the sessions emitting them.
+const maxQueuedEvents = 4096
+
+var (
+	// subscriberMu guards subscribers.
+	subscriberMu sync.RWMutex
+	// subscribers holds the functions registered using Subscribe, keyed by the EventType they subscribed to.
+	subscribers = map[EventType][]func(e Event){}
+	// events holds the events waiting to be passed to subscribers.
+	events = make(chan Event, maxQueuedEvents)
+)
+
+// Subscribe registers a function that is called with every Event of type E, such as to build logging, webhooks or
+// analytics on top of the proxy. Functions are called in the order the events were emitted, one at a time, on a
+// goroutine separate from the sessions, so they may take a while without delaying players. Events emitted while too
+// many are waiting are dropped.
+//
+//	proxy.Subscribe(func(e proxy.QuitEvent) {
+//		log.Printf("%v left: %v", e.Session.Name(), e.Reason)
+//	})
+func Subscribe[E eventConstraint](f func(e E)) {
+	var e E
+	subscriberMu.Lock()
+	defer subscriberMu.Unlock()
+	subscribers[e.Type()] = append(subscribers[e.Type()], func(e Event) {
+		f(e.(E))
+	})
+}
+
+// emit emits the Event passed to all functions subscribed to its type.
+func emit(e Event) {
+	subscriberMu.RLock()
+	n := len(subscribers[e.Type()])
+	subscriberMu.RUnlock()
+	if n == 0 {
+		return
+	}
+	select {
+	case events <- e:
+	default:
+		metrics.Add("events_dropped", 1)
+	}
+}
+
+func init() {
+	go func() {
+		for e := range events {
+			subscriberMu.RLock()
+			subs := subscribers[e.Type()]
+			subscriberMu.RUnlock()
+			for _, f := range subs {
+				callSubscriber(f, e)
+			}
+		}
+	}()
+}
+
+// callSubscriber calls the subscriber passed with the Event passed. A panicking subscriber is logged rather than
+// taking down the proxy.
+func callSubscriber(f func(e Event), e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			logging.Default().Error("panic in event subscriber", "event", e.Type(), "panic", fmt.Sprint(r))
+		}
+	}()
+	f(e)
+}
@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{Name: "hub"})
+
+	errs, quits := make(chan PacketErrorEvent, 1), make(chan QuitEvent, 1)
+	Subscribe(func(e PacketErrorEvent) {
+		if e.Session == s {
+			errs <- e
+		}
+	})
+	Subscribe(func(e QuitEvent) {
+		if e.Session == s {
+			quits <- e
+		}
+	})
+
+	s.malformed(ServerToClient, errors.New("bad packet"))
+	select {
+	case e := <-errs:
+		if e.Direction != ServerToClient || e.Err == nil || e.Err.Error() != "bad packet" {
+			t.Errorf("unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no packet error event emitted")
+	}
+
+	s.Disconnect("bye")
+	select {
+	case e := <-quits:
+		if e.Reason != "bye" {
+			t.Errorf("quit event emitted with reason %q, expected %q", e.Reason, "bye")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no quit event emitted")
+	}
+}
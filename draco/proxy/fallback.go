@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Fallback configures what happens to players whose connection to their backend drops. By default, they are
+// disconnected.
+type Fallback struct {
+	// Backend is the name of a backend set using SetBackends that players are transferred to if the connection to
+	// their backend drops. If empty, or if it can't be joined, Limbo applies.
+	Backend string
+	// Limbo specifies if players are held in a limbo served by the proxy while the backend they were connected to is
+	// retried with exponential backoff, rather than being disconnected.
+	Limbo bool
+	// Timeout is the duration for which the backend is retried while players are held in limbo, after which they are
+	// disconnected. If 0, it is one minute.
+	Timeout time.Duration
+	// OnKick specifies if players also fall back if their backend disconnected them with a message, rather than only
+	// if the connection was lost. Note that backends send a message both when kicking players and when shutting
+	// down.
+	OnKick bool
+}
+
+// LimboBackend is the name of the Backend that sessions held in limbo are attached to.
+const LimboBackend = "limbo"
+
+const (
+	// minBackoff and maxBackoff are the minimum and maximum delay between two attempts to reconnect a player held in
+	// limbo to its backend.
+	minBackoff, maxBackoff = time.Second, time.Second * 16
+)
+
+var (
+	// fallbackMu guards fallbackConf.
+	fallbackMu sync.RWMutex
+	// fallbackConf is the Fallback set using SetFallback.
+	fallbackConf Fallback
+)
+
+// SetFallback sets the Fallback applied to players whose connection to their backend drops.
+func SetFallback(f Fallback) {
+	if f.Timeout == 0 {
+		f.Timeout = time.Minute
+	}
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+	fallbackConf = f
+}
+
+// fallback returns the Fallback currently used.
+func fallback() Fallback {
+	fallbackMu.RLock()
+	defer fallbackMu.RUnlock()
+	return fallbackConf
+}
+
+// recovers checks if players may fall back at all, in which case a Session survives the connection to its server
+// being closed.
+func recovers() bool {
+	f := fallback()
+	return f.Backend != "" || f.Limbo
+}
+
+// fallBack applies the Fallback to the Session passed after the connection to its server was closed with the error
+// passed. It returns true if the Session was attached to another server or held in limbo, or false if it should be
+// disconnected.
+// fallBack is called by the goroutine reading from the server, so backends are only dialed on it if the player is
+// not held in limbo in the meantime.
+func (s *Session) fallBack(err error) bool {
+	f, dropped := fallback(), s.Backend()
+	if _, kicked := disconnectMessage(err); kicked && !f.OnKick {
+		return false
+	}
+	select {
+	case <-s.closed:
+		return false
+	default:
+	}
+	b, ok := BackendByName(f.Backend)
+	ok = ok && !strings.EqualFold(b.Name, dropped.Name)
+	fromLimbo := ok && limboWorld().DuringTransfers
+	if ok && !fromLimbo {
+		if s.moveTo(b, dropped) {
+			return true
+		}
+	}
+	if (!f.Limbo && !fromLimbo) || dropped.Name == LimboBackend {
+		return false
+	}
+	l, err := s.enterLimbo()
+	if err != nil {
+		return false
+	}
+	go func() {
+		if fromLimbo && s.moveTo(b, dropped) {
+			return
+		}
+		if !f.Limbo {
+			s.setCause(CauseBackendLost)
+			s.Disconnect(s.Translate("fallback.gave_up", dropped.Name))
+			return
+		}
+		s.message("fallback.limbo", dropped.Name)
+		s.reconnect(l, dropped, f.Timeout)
+	}()
+	return true
+}
+
+// moveTo transfers the Session to the fallback Backend passed after the Backend dropped went down, reporting if it
+// succeeded.
+func (s *Session) moveTo(b, dropped Backend) bool {
+	if err := s.Transfer(b); err != nil {
+		s.Logger().Error("error moving to fallback backend", "fallback", b.Name, "err", err)
+		return false
+	}
+	s.message("fallback.moved", dropped.Name, b.Name)
+	return true
+}
+
+// reconnect reconnects the Session held in the limbo passed to the Backend passed, retrying with exponential backoff
+// until the timeout passed expires, after which the Session is disconnected. It stops if the Session is closed or
+// attached to another server in the meantime.
+func (s *Session) reconnect(l *limbo, b Backend, timeout time.Duration) {
+	deadline, backoff := time.Now().Add(timeout), minBackoff
+	for {
+		select {
+		case <-time.After(backoff):
+		case <-s.closed:
+			return
+		case <-l.closed:
+			// The Session was attached to another server, such as by the player running /server.
+			return
+		}
+		err := s.Transfer(b)
+		if err == nil {
+			s.message("fallback.reconnected", b.Name)
+			return
+		}
+		if errors.Is(err, ErrAlreadyConnected) {
+			return
+		}
+		if !time.Now().Add(backoff).Before(deadline) {
+			s.Logger().Error("error reconnecting to backend, giving up", "to", b.Name, "err", err)
+			s.setCause(CauseBackendLost)
+			s.Disconnect(s.Translate("fallback.gave_up", b.Name))
+			return
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// message sends the message with the key passed, translated to the language of the client, to the client.
+func (s *Session) message(key string, args ...any) {
+	_ = s.client.WritePacket(&packet.Text{TextType: packet.TextTypeRaw, Message: s.Translate(key, args...)})
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestFallbackBackend(t *testing.T) {
+	lobby := &recordConn{}
+	SetBackends([]Backend{{Name: "game"}, {Name: "lobby"}})
+	SetDialer(func(s *Session, b Backend) (Conn, error) {
+		if b.Name != "lobby" {
+			return nil, errors.New("unexpected backend")
+		}
+		return lobby, nil
+	})
+	SetFallback(Fallback{Backend: "lobby"})
+	defer SetBackends(nil)
+	defer SetDialer(nil)
+	defer SetFallback(Fallback{})
+
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{Name: "game"})
+	if s.fallBack(minecraft.DisconnectError("kicked")) {
+		t.Fatalf("kicked player fell back although OnKick is false")
+	}
+	if !s.fallBack(io.EOF) {
+		t.Fatalf("player did not fall back after losing the connection")
+	}
+	if s.Backend().Name != "lobby" || s.Server() != lobby {
+		t.Errorf("player attached to %v rather than the fallback backend", s.Backend().Name)
+	}
+}
+
+func TestFallbackLimbo(t *testing.T) {
+	SetDialer(func(s *Session, b Backend) (Conn, error) { return nil, errors.New("backend down") })
+	SetFallback(Fallback{Limbo: true, Timeout: time.Minute})
+	defer SetDialer(nil)
+	defer SetFallback(Fallback{})
+
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{Name: "game"})
+	defer s.close()
+	if !s.fallBack(io.EOF) {
+		t.Fatalf("player was not held in limbo")
+	}
+	if s.Backend().Name != LimboBackend {
+		t.Fatalf("player attached to %v rather than limbo", s.Backend().Name)
+	}
+
+	// Packets that the client expects an answer to are answered by the limbo.
+	if err := s.Server().WritePacket(&packet.TickSync{ClientRequestTimestamp: 5}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(time.Second)
+	for {
+		pk, err := s.Server().ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pk, ok := pk.(*packet.TickSync); ok {
+			if pk.ClientRequestTimestamp != 5 {
+				t.Errorf("unexpected tick sync %#v", pk)
+			}
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("tick sync was not answered")
+		default:
+		}
+	}
+}
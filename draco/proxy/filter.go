@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Filter is a rule applied to the packets forwarded by the filters Middleware, which allows dropping, logging and
+// rewriting packets from the config, without writing packet handlers.
+type Filter struct {
+	// Direction is the direction of the packets filtered: "client" for packets sent by clients, "server" for packets
+	// sent by servers, or empty for both.
+	Direction string
+	// Packets holds the packets filtered, by their ID, such as "9", or the name of their type, such as "Text",
+	// ignoring case.
+	Packets []string
+	// Action is what happens to the packets filtered: "drop" drops them, "log" logs them and "rewrite" sets the
+	// Fields of them.
+	Action string
+	// Fields holds the values that fields of packets rewritten are set to, keyed by their name, such as
+	// {Message = "[hidden]"}. Only fields holding strings, booleans and numbers may be set.
+	Fields map[string]any
+}
+
+// filterAction is the Action of a Filter parsed.
+type filterAction uint8
+
+const (
+	filterDrop filterAction = iota
+	filterLog
+	filterRewrite
+)
+
+// compiledFilter is a Filter parsed by SetFilters, applied to the packets of a single ID.
+type compiledFilter struct {
+	action filterAction
+	// typ is the type of packets with the ID filtered. Only packets of this type are rewritten, so that packets
+	// that could not be decoded, which are passed as *packet.Unknown, are left alone.
+	typ    reflect.Type
+	fields []fieldValue
+}
+
+// fieldValue is a value that a field of a packet is set to by a Filter.
+type fieldValue struct {
+	index []int
+	value reflect.Value
+}
+
+var (
+	// filterMu guards filters.
+	filterMu sync.RWMutex
+	// filters holds the filters set using SetFilters, indexed by the Direction they apply to and keyed by the ID of
+	// the packets they apply to, in the order they were set in.
+	filters = [2]map[uint32][]compiledFilter{{}, {}}
+)
+
+// SetFilters sets the filters applied to packets forwarded, replacing those set before. Filters are applied in the
+// order passed, and no further filters are applied to a packet once one of them drops it. An error is returned if
+// any of the filters is invalid, in which case the filters set before are kept.
+func SetFilters(f []Filter) error {
+	compiled := [2]map[uint32][]compiledFilter{{}, {}}
+	for i, filter := range f {
+		if err := compileFilter(filter, &compiled); err != nil {
+			return fmt.Errorf("filter %v: %w", i+1, err)
+		}
+	}
+	filterMu.Lock()
+	defer filterMu.Unlock()
+	filters = compiled
+	return nil
+}
+
+// compileFilter parses the Filter passed and adds it to the filters passed.
+func compileFilter(f Filter, compiled *[2]map[uint32][]compiledFilter) error {
+	var directions []Direction
+	switch strings.ToLower(f.Direction) {
+	case "":
+		directions = []Direction{ClientToServer, ServerToClient}
+	case "client":
+		directions = []Direction{ClientToServer}
+	case "server":
+		directions = []Direction{ServerToClient}
+	default:
+		return fmt.Errorf("unknown direction %q", f.Direction)
+	}
+	var action filterAction
+	switch strings.ToLower(f.Action) {
+	case "drop":
+		action = filterDrop
+	case "log":
+		action = filterLog
+	case "rewrite":
+		action = filterRewrite
+		if len(f.Fields) == 0 {
+			return fmt.Errorf("rewrite without fields")
+		}
+	default:
+		return fmt.Errorf("unknown action %q", f.Action)
+	}
+	if len(f.Packets) == 0 {
+		return fmt.Errorf("no packets")
+	}
+	for _, name := range f.Packets {
+		id, typ, ok := parsePacket(name)
+		if !ok {
+			return fmt.Errorf("unknown packet %q", name)
+		}
+		c := compiledFilter{action: action, typ: typ}
+		if action == filterRewrite {
+			if typ == nil {
+				return fmt.Errorf("packet %v can't be rewritten, as it is not known", name)
+			}
+			for field, v := range f.Fields {
+				fv, err := compileField(typ, field, v)
+				if err != nil {
+					return fmt.Errorf("rewrite %v: %w", typ.Elem().Name(), err)
+				}
+				c.fields = append(c.fields, fv)
+			}
+		}
+		for _, d := range directions {
+			compiled[d][id] = append(compiled[d][id], c)
+		}
+	}
+	return nil
+}
+
+// parsePacket parses a packet by its ID or the name of its type, returning its ID and its type. The type is nil for
+// IDs of packets that gophertunnel doesn't know.
+func parsePacket(name string) (uint32, reflect.Type, bool) {
+	pool := packet.NewPool()
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		if f, ok := pool[uint32(id)]; ok {
+			return uint32(id), reflect.TypeOf(f()), true
+		}
+		return uint32(id), nil, true
+	}
+	for id, f := range pool {
+		if typ := reflect.TypeOf(f()); strings.EqualFold(typ.Elem().Name(), strings.TrimPrefix(name, "packet.")) {
+			return id, typ, true
+		}
+	}
+	return 0, nil, false
+}
+
+// compileField looks up the field with the name passed, ignoring case, in the packet type passed and converts the
+// value passed, as decoded from TOML, to the type of the field.
+func compileField(typ reflect.Type, name string, v any) (fieldValue, error) {
+	f, ok := typ.Elem().FieldByNameFunc(func(field string) bool {
+		return strings.EqualFold(field, name)
+	})
+	if !ok || f.PkgPath != "" {
+		return fieldValue{}, fmt.Errorf("no field %v", name)
+	}
+	value := reflect.New(f.Type).Elem()
+	rv := reflect.ValueOf(v)
+	switch k := f.Type.Kind(); {
+	case k == reflect.String && rv.Kind() == reflect.String, k == reflect.Bool && rv.Kind() == reflect.Bool:
+		value.Set(rv.Convert(f.Type))
+	case k >= reflect.Int && k <= reflect.Int64 && rv.Kind() == reflect.Int64:
+		if value.OverflowInt(rv.Int()) {
+			return fieldValue{}, fmt.Errorf("value %v of field %v out of range", v, f.Name)
+		}
+		value.SetInt(rv.Int())
+	case k >= reflect.Uint && k <= reflect.Uint64 && rv.Kind() == reflect.Int64:
+		if rv.Int() < 0 || value.OverflowUint(uint64(rv.Int())) {
+			return fieldValue{}, fmt.Errorf("value %v of field %v out of range", v, f.Name)
+		}
+		value.SetUint(uint64(rv.Int()))
+	case (k == reflect.Float32 || k == reflect.Float64) && (rv.Kind() == reflect.Float64 || rv.Kind() == reflect.Int64):
+		value.Set(rv.Convert(f.Type))
+	default:
+		return fieldValue{}, fmt.Errorf("field %v of type %v can't be set to %v", f.Name, f.Type, v)
+	}
+	return fieldValue{index: f.Index, value: value}, nil
+}
+
+// maxFilterLogLength is the maximum length of a packet logged by a Filter. Longer packets, such as chunks, are cut
+// off.
+const maxFilterLogLength = 512
+
+// filter applies the filters set using SetFilters to a packet travelling in the Direction passed.
+func (s *Session) filter(d Direction, pk packet.Packet) Action {
+	filterMu.RLock()
+	fs := filters[d][pk.ID()]
+	filterMu.RUnlock()
+	for _, f := range fs {
+		switch f.action {
+		case filterDrop:
+			return Drop
+		case filterLog:
+			text := fmt.Sprintf("%+v", pk)
+			if len(text) > maxFilterLogLength {
+				text = text[:maxFilterLogLength] + "..."
+			}
+			s.Logger().Info("packet filtered", "direction", d, "packet", fmt.Sprintf("%T", pk), "id", pk.ID(), "content", text)
+		case filterRewrite:
+			if reflect.TypeOf(pk) != f.typ {
+				continue
+			}
+			v := reflect.ValueOf(pk).Elem()
+			for _, field := range f.fields {
+				v.FieldByIndex(field.index).Set(field.value)
+			}
+		}
+	}
+	return Forward
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestFilters(t *testing.T) {
+	defer func() {
+		_ = SetFilters(nil)
+	}()
+	err := SetFilters([]Filter{
+		{Direction: "server", Packets: []string{"text"}, Action: "rewrite", Fields: map[string]any{"Message": "[hidden]", "textType": int64(packet.TextTypeRaw)}},
+		{Direction: "client", Packets: []string{"Text", "77"}, Action: "drop"},
+		{Packets: []string{"SetTime"}, Action: "log"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	defer s.close()
+
+	pk := &packet.Text{TextType: packet.TextTypeChat, Message: "secret"}
+	if s.filter(ServerToClient, pk) != Forward || pk.Message != "[hidden]" || pk.TextType != packet.TextTypeRaw {
+		t.Errorf("expected the Text packet of the server to be rewritten, got %+v", pk)
+	}
+	if s.filter(ClientToServer, &packet.Text{}) != Drop {
+		t.Error("expected the Text packet of the client to be dropped")
+	}
+	if s.filter(ClientToServer, &packet.CommandRequest{}) != Drop {
+		t.Error("expected packet 77 of the client to be dropped")
+	}
+	if s.filter(ClientToServer, &packet.SetTime{}) != Forward || s.filter(ServerToClient, &packet.MovePlayer{}) != Forward {
+		t.Error("expected packets that are not dropped to be forwarded")
+	}
+
+	for _, invalid := range []Filter{
+		{Direction: "sideways", Packets: []string{"Text"}, Action: "drop"},
+		{Packets: []string{"NoSuchPacket"}, Action: "drop"},
+		{Packets: []string{"Text"}, Action: "explode"},
+		{Packets: []string{"Text"}, Action: "rewrite"},
+		{Packets: []string{"Text"}, Action: "rewrite", Fields: map[string]any{"NoSuchField": "x"}},
+		{Packets: []string{"Text"}, Action: "rewrite", Fields: map[string]any{"Message": int64(1)}},
+		{Packets: []string{"Text"}, Action: "rewrite", Fields: map[string]any{"TextType": int64(1000)}},
+	} {
+		if err := SetFilters([]Filter{invalid}); err == nil {
+			t.Errorf("expected an error setting filter %+v", invalid)
+		}
+	}
+	if s.filter(ClientToServer, &packet.Text{}) != Drop {
+		t.Error("expected the filters to be kept after setting invalid filters")
+	}
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// ConnectionLimits limits the connections that the proxy admits, protecting backends from floods of bots joining
+// through the proxy. By default, there are no limits.
+type ConnectionLimits struct {
+	// PerIP is the maximum amount of logins per minute from a single IP address. Clients logging in more often are
+	// turned away. If 0, there is no limit.
+	PerIP int
+	// LoginTimeout is the maximum duration between a client sending its login and completing the login sequence,
+	// which includes downloading resource packs. Clients that take longer are turned away. If 0, there is no
+	// timeout.
+	LoginTimeout time.Duration
+}
+
+var (
+	// ErrRateLimited is returned by Admit if too many logins were sent from the IP of a client.
+	ErrRateLimited = errors.New("too many logins from address")
+	// ErrLoginTimeout is returned by Admit if a client took too long to log in.
+	ErrLoginTimeout = errors.New("login timed out")
+)
+
+// loginStartTTL is the duration after which the time that a client sent its login is forgotten if Admit was never
+// called for it, such as because its login failed.
+const loginStartTTL = time.Minute * 10
+
+var (
+	// floodMu guards the fields below.
+	floodMu sync.Mutex
+	// floodConf is the ConnectionLimits set using SetConnectionLimits.
+	floodConf ConnectionLimits
+	// attempts holds the times of the logins sent in the last minute, oldest first, keyed by IP.
+	attempts = map[string][]time.Time{}
+	// loginStarts holds the times at which clients sent their login, keyed by their address, until Admit is called
+	// for their connection.
+	loginStarts = map[string]time.Time{}
+)
+
+// SetConnectionLimits sets the ConnectionLimits applied to connections passed to Admit.
+func SetConnectionLimits(l ConnectionLimits) {
+	floodMu.Lock()
+	defer floodMu.Unlock()
+	floodConf = l
+}
+
+// ObserveConnection inspects a packet read by a minecraft.Listener, recording when and from where clients send their
+// login, which Admit checks once they completed it. ObserveConnection has the signature of
+// minecraft.ListenConfig.PacketFunc.
+func ObserveConnection(header packet.Header, _ []byte, src, _ net.Addr) {
+	if header.PacketID != packet.IDLogin {
+		return
+	}
+	now := time.Now()
+	floodMu.Lock()
+	defer floodMu.Unlock()
+	for addr, t := range loginStarts {
+		if now.Sub(t) > loginStartTTL {
+			delete(loginStarts, addr)
+		}
+	}
+	for host, times := range attempts {
+		for len(times) > 0 && now.Sub(times[0]) > time.Minute {
+			times = times[1:]
+		}
+		if len(times) == 0 {
+			delete(attempts, host)
+			continue
+		}
+		attempts[host] = times
+	}
+	loginStarts[src.String()] = now
+	attempts[ip(src)] = append(attempts[ip(src)], now)
+}
+
+// Admit checks if the connection of a client from the address passed, which completed its login on a listener that
+// passed its packets to ObserveConnection, may be handled rather than being turned away, which must happen before a
+// backend is dialed for it. ErrRateLimited or ErrLoginTimeout is returned if it exceeded the ConnectionLimits.
+// Connections that never complete their login can't be closed by the proxy, as gophertunnel doesn't expose them
+// until they did: the amount of them is limited using ListenConfig.MaximumPlayers instead, which includes clients
+// that are logging in.
+func Admit(addr net.Addr) error {
+	floodMu.Lock()
+	defer floodMu.Unlock()
+	start, ok := loginStarts[addr.String()]
+	delete(loginStarts, addr.String())
+	if floodConf.PerIP > 0 && len(attempts[ip(addr)]) > floodConf.PerIP {
+		metrics.Add("logins_rate_limited", 1)
+		return ErrRateLimited
+	}
+	if floodConf.LoginTimeout > 0 && ok && time.Since(start) > floodConf.LoginTimeout {
+		metrics.Add("logins_timed_out", 1)
+		return ErrLoginTimeout
+	}
+	return nil
+}
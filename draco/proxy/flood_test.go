@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestAdmit(t *testing.T) {
+	SetConnectionLimits(ConnectionLimits{PerIP: 2, LoginTimeout: time.Minute})
+	defer SetConnectionLimits(ConnectionLimits{})
+
+	login := func(addr net.Addr) {
+		ObserveConnection(packet.Header{PacketID: packet.IDLogin}, nil, addr, nil)
+	}
+	first, second := &net.UDPAddr{IP: net.IPv4(10, 1, 0, 1), Port: 1}, &net.UDPAddr{IP: net.IPv4(10, 1, 0, 1), Port: 2}
+	login(first)
+	login(second)
+	if err := Admit(first); err != nil {
+		t.Fatalf("first login was not admitted: %v", err)
+	}
+	login(&net.UDPAddr{IP: net.IPv4(10, 1, 0, 1), Port: 3})
+	if err := Admit(second); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("third login from the same IP resulted in %v, expected %v", err, ErrRateLimited)
+	}
+
+	other := &net.UDPAddr{IP: net.IPv4(10, 1, 0, 2), Port: 1}
+	login(other)
+	floodMu.Lock()
+	loginStarts[other.String()] = time.Now().Add(-time.Minute * 2)
+	floodMu.Unlock()
+	if err := Admit(other); !errors.Is(err, ErrLoginTimeout) {
+		t.Fatalf("slow login resulted in %v, expected %v", err, ErrLoginTimeout)
+	}
+}
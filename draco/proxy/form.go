@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// FormHandler handles the response of a player to a form sent by the proxy using Session.SendForm. response holds
+// the JSON data of the response, as described by the type of the form, and is nil if the player closed the form.
+type FormHandler func(s *Session, response []byte)
+
+// proxyFormBase is the first ID of the forms owned by the proxy. It is high enough not to conflict with the IDs of
+// forms sent by backends in practice. Forms of a backend that do have the ID of a form of the proxy are sent to
+// the client with another ID.
+const proxyFormBase = 0x7fd2ac00
+
+// forms keeps track of the forms open on the client of a Session, so that the responses to forms of the proxy are
+// never forwarded to the backend and forms of the proxy and the backend never share an ID.
+type forms struct {
+	mu sync.Mutex
+	// next is the ID that the next form of the proxy is tried with, relative to proxyFormBase.
+	next uint32
+	// proxy holds the handlers of the forms of the proxy that the client has not responded to yet, keyed by the ID
+	// of the form.
+	proxy map[uint32]FormHandler
+	// server holds the IDs of the forms of the server that the client has not responded to yet, mapping the ID
+	// known by the client to the ID known by the server. The IDs only differ for forms of the server that had the
+	// ID of a form of the proxy.
+	server map[uint32]uint32
+}
+
+// register registers the FormHandler passed for a new form of the proxy and returns the ID of the form.
+func (f *forms) register(h FormHandler) uint32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.proxy == nil {
+		f.proxy = map[uint32]FormHandler{}
+	}
+	id := f.freeLocked()
+	f.proxy[id] = h
+	return id
+}
+
+// freeLocked returns an ID in the range of the proxy that no form open on the client has. f.mu must be held.
+func (f *forms) freeLocked() uint32 {
+	for {
+		id := proxyFormBase + f.next
+		f.next++
+		_, proxy := f.proxy[id]
+		_, server := f.server[id]
+		if !proxy && !server {
+			return id
+		}
+	}
+}
+
+// serverForm tracks a form of the server sent to the client, changing the ID passed if another form open on the
+// client already has it.
+func (f *forms) serverForm(id *uint32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.server == nil {
+		f.server = map[uint32]uint32{}
+	}
+	clientID := *id
+	if _, ok := f.proxy[clientID]; ok {
+		clientID = f.freeLocked()
+	} else if _, ok := f.server[clientID]; ok && f.server[clientID] != *id {
+		clientID = f.freeLocked()
+	}
+	f.server[clientID] = *id
+	*id = clientID
+}
+
+// response handles a response of the client to the form with the ID passed. If the form is one of the proxy, its
+// FormHandler is returned and true. Otherwise the ID is changed to the ID known by the server.
+func (f *forms) response(id *uint32) (FormHandler, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if h, ok := f.proxy[*id]; ok {
+		delete(f.proxy, *id)
+		return h, true
+	}
+	if serverID, ok := f.server[*id]; ok {
+		delete(f.server, *id)
+		*id = serverID
+	}
+	return nil, false
+}
+
+// SendForm sends the form with the JSON data passed to the client of the Session, such as a form with the type
+// "form", "modal" or "custom_form". The response of the player is passed to the FormHandler and never reaches the
+// backend. Forms of the proxy keep their ID when the Session is attached to another server, so that the player may
+// still respond to them after a transfer.
+//
+//	s.SendForm([]byte(`{"type": "modal", "title": "Vote", "content": "Skip the night?", "button1": "Yes", "button2": "No"}`), func(s *proxy.Session, response []byte) {
+//		if string(response) == "true" {
+//			vote(s)
+//		}
+//	})
+func (s *Session) SendForm(data []byte, h FormHandler) error {
+	id := s.forms.register(h)
+	if err := s.client.WritePacket(&packet.ModalFormRequest{FormID: id, FormData: data}); err != nil {
+		s.forms.response(&id)
+		return err
+	}
+	return nil
+}
+
+func init() {
+	Handle(ServerToClient, func(s *Session, pk *packet.ModalFormRequest) Action {
+		s.forms.serverForm(&pk.FormID)
+		return Forward
+	})
+	Handle(ClientToServer, func(s *Session, pk *packet.ModalFormResponse) Action {
+		h, ok := s.forms.response(&pk.FormID)
+		if !ok {
+			return Forward
+		}
+		response := pk.ResponseData
+		if r := bytes.TrimSpace(response); len(r) == 0 || bytes.Equal(r, []byte("null")) {
+			response = nil
+		}
+		h(s, response)
+		return Drop
+	})
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestSendForm(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+
+	var responses [][]byte
+	if err := s.SendForm([]byte(`{"type": "modal"}`), func(_ *Session, response []byte) {
+		responses = append(responses, response)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	proxyID := conn.packets[0].(*packet.ModalFormRequest).FormID
+
+	// A form of the server with the ID of the form of the proxy is sent to the client with another ID.
+	serverForm := &packet.ModalFormRequest{FormID: proxyID}
+	if handle(s, ServerToClient, serverForm) != Forward {
+		t.Fatal("form of the server was not forwarded")
+	}
+	if serverForm.FormID == proxyID {
+		t.Fatal("form of the server was sent with the ID of a form of the proxy")
+	}
+
+	if handle(s, ClientToServer, &packet.ModalFormResponse{FormID: proxyID, ResponseData: []byte("true\n")}) != Drop {
+		t.Fatal("response to a form of the proxy was forwarded to the server")
+	}
+	if len(responses) != 1 || string(responses[0]) != "true\n" {
+		t.Fatalf("unexpected responses %q", responses)
+	}
+
+	resp := &packet.ModalFormResponse{FormID: serverForm.FormID, ResponseData: []byte("null")}
+	if handle(s, ClientToServer, resp) != Forward {
+		t.Fatal("response to a form of the server was dropped")
+	}
+	if resp.FormID != proxyID {
+		t.Errorf("response forwarded with form ID %v, expected %v", resp.FormID, proxyID)
+	}
+	if handle(s, ClientToServer, &packet.ModalFormResponse{FormID: proxyID, ResponseData: []byte("null")}) != Forward {
+		t.Error("second response to a form of the proxy was dropped")
+	}
+	if len(responses) != 1 {
+		t.Errorf("form handler called %v times, expected once", len(responses))
+	}
+}
+
+func TestProxyBossBar(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	s.ShowBossBar("Event", 0.5)
+	s.attached()
+	s.HideBossBar()
+	s.attached()
+
+	var events []uint32
+	for _, pk := range conn.packets {
+		if pk, ok := pk.(*packet.BossEvent); ok {
+			events = append(events, pk.EventType)
+		}
+	}
+	expected := []uint32{packet.BossEventShow, packet.BossEventShow, packet.BossEventHide}
+	if len(events) != len(expected) {
+		t.Fatalf("boss events %v sent, expected %v", events, expected)
+	}
+	for i, e := range expected {
+		if events[i] != e {
+			t.Fatalf("boss events %v sent, expected %v", events, expected)
+		}
+	}
+}
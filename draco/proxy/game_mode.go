@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// GameMode overrides the game mode that clients are shown, regardless of the game mode that their backend sends,
+// such as to show adventure mode to visitors of a build server that runs them in survival. Only the client is
+// changed: the backend still treats players with the game mode it set, so it must enforce what players may do
+// itself.
+type GameMode struct {
+	// Backend is the name of the backend that the GameMode applies to, ignoring case. If empty, it applies to all
+	// backends.
+	Backend string
+	// Roles holds the roles of the players that the GameMode applies to. If empty, it applies to all players.
+	Roles []Role
+	// Mode is the game mode shown to players: "survival", "creative", "adventure" or "spectator".
+	Mode string
+}
+
+// gameTypes holds the game types of the names of game modes used in GameMode.
+var gameTypes = map[string]int32{
+	"survival":  packet.GameTypeSurvival,
+	"creative":  packet.GameTypeCreative,
+	"adventure": packet.GameTypeAdventure,
+	"spectator": packet.GameTypeSpectator,
+}
+
+// ValidGameMode checks if the name passed is the name of a game mode that may be used in GameMode.
+func ValidGameMode(name string) bool {
+	_, ok := gameTypes[strings.ToLower(name)]
+	return ok
+}
+
+// gameModeOverride is a parsed GameMode.
+type gameModeOverride struct {
+	GameMode
+	gameType int32
+}
+
+var (
+	// gameModeMu guards gameModes.
+	gameModeMu sync.RWMutex
+	// gameModes holds the game modes set using SetGameModes.
+	gameModes []gameModeOverride
+)
+
+// SetGameModes sets the game modes shown to players, replacing those set before. Only the first GameMode that
+// applies to the backend and role of a player is applied to it. An error is returned if any of the game modes is
+// unknown, in which case the game modes set before are kept. Players online are shown the game mode the next time
+// their backend changes it or when they transfer.
+func SetGameModes(m []GameMode) error {
+	all := make([]gameModeOverride, 0, len(m))
+	for _, mode := range m {
+		t, ok := gameTypes[strings.ToLower(mode.Mode)]
+		if !ok {
+			return fmt.Errorf("unknown game mode %v", mode.Mode)
+		}
+		all = append(all, gameModeOverride{GameMode: mode, gameType: t})
+	}
+	gameModeMu.Lock()
+	defer gameModeMu.Unlock()
+	gameModes = all
+	return nil
+}
+
+// gameMode returns the game type shown to players with the Role passed on the Backend passed. False is returned if
+// no GameMode applies.
+func gameMode(b Backend, r Role) (int32, bool) {
+	gameModeMu.RLock()
+	defer gameModeMu.RUnlock()
+	for _, m := range gameModes {
+		if m.Backend != "" && !strings.EqualFold(m.Backend, b.Name) {
+			continue
+		}
+		if len(m.Roles) > 0 && !hasRole(m.Roles, r) {
+			continue
+		}
+		return m.gameType, true
+	}
+	return 0, false
+}
+
+// GameModeGameData changes the game data passed, which is sent to the client in the StartGame packet, to hold the
+// game mode shown to players with the Role passed on the Backend passed.
+func GameModeGameData(data minecraft.GameData, b Backend, r Role) minecraft.GameData {
+	if t, ok := gameMode(b, r); ok {
+		data.PlayerGameMode = t
+	}
+	return data
+}
+
+func init() {
+	Handle(ServerToClient, func(s *Session, pk *packet.SetPlayerGameType) Action {
+		if t, ok := gameMode(s.Backend(), s.Role()); ok {
+			pk.GameType = t
+		}
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.UpdatePlayerGameType) Action {
+		// UpdatePlayerGameType is also sent for other players, whose game modes are shown as they are.
+		ids := s.entityIDs()
+		if pk.PlayerUniqueID != ids.clientUniqueID && pk.PlayerUniqueID != ids.serverUniqueID {
+			return Forward
+		}
+		if t, ok := gameMode(s.Backend(), s.Role()); ok {
+			pk.GameType = t
+		}
+		return Forward
+	})
+}
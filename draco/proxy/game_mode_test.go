@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestGameMode(t *testing.T) {
+	if err := SetGameModes([]GameMode{{Mode: "hardcore"}}); err == nil {
+		t.Error("expected an error setting an unknown game mode")
+	}
+	if err := SetGameModes([]GameMode{{Backend: "Build", Roles: []Role{RoleGuest}, Mode: "adventure"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetGameModes(nil)
+
+	conn := &recordConn{}
+	s := NewGuestSession(conn, conn, Backend{Name: "build"}, "Guest")
+	defer s.close()
+	pk := &packet.SetPlayerGameType{GameType: packet.GameTypeSurvival}
+	if handle(s, ServerToClient, pk) != Forward || pk.GameType != packet.GameTypeAdventure {
+		t.Errorf("expected adventure mode to be shown to guests, got game type %v", pk.GameType)
+	}
+	other := &packet.UpdatePlayerGameType{GameType: packet.GameTypeCreative, PlayerUniqueID: 12345}
+	if handle(s, ServerToClient, other); other.GameType != packet.GameTypeCreative {
+		t.Error("expected the game mode of another player to be kept")
+	}
+	if data := GameModeGameData(minecraft.GameData{}, Backend{Name: "build"}, RoleGuest); data.PlayerGameMode != packet.GameTypeAdventure {
+		t.Errorf("expected adventure mode in the game data, got %v", data.PlayerGameMode)
+	}
+
+	member := NewSession(conn, conn, Backend{Name: "build"})
+	defer member.close()
+	pk = &packet.SetPlayerGameType{GameType: packet.GameTypeSurvival}
+	if handle(member, ServerToClient, pk); pk.GameType != packet.GameTypeSurvival {
+		t.Error("expected the game mode of members to be kept")
+	}
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// maxGuestNameLength is the maximum length of the name of a guest, including its prefix. It matches the maximum
+// length of an XBOX Live gamertag.
+const maxGuestNameLength = 16
+
+// NewGuestSession creates a Session like NewSession, but for a client that joined through a listener with
+// authentication disabled. The Session has the RoleGuest role and the name passed, which should be obtained using
+// GuestName, rather than the name the client claims to have.
+func NewGuestSession(client ClientConn, server Conn, backend Backend, name string) *Session {
+	s := NewSession(client, server, backend)
+	s.role, s.name = RoleGuest, name
+	return s
+}
+
+// GuestName returns the name that a guest claiming the name passed is assigned. Any characters not allowed in an
+// XBOX Live gamertag are removed from the name, after which the prefix is prepended and the result is shortened to
+// fit in a gamertag. If the name is already in use by an active Session, a number is appended to it.
+func GuestName(prefix, name string) string {
+	name = prefix + strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return -1
+	}, name)
+	if len(name) > maxGuestNameLength {
+		name = name[:maxGuestNameLength]
+	}
+
+	assigned := name
+	for i := 2; nameInUse(assigned); i++ {
+		suffix := strconv.Itoa(i)
+		if len(name)+len(suffix) > maxGuestNameLength {
+			assigned = name[:maxGuestNameLength-len(suffix)] + suffix
+			continue
+		}
+		assigned = name + suffix
+	}
+	return assigned
+}
+
+// nameInUse checks if any active Session has the name passed, ignoring case.
+func nameInUse(name string) bool {
+	sessionMu.RLock()
+	defer sessionMu.RUnlock()
+	for s := range sessions {
+		if strings.EqualFold(s.Name(), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	Handle(ClientToServer, func(s *Session, pk *packet.Text) Action {
+		return restrictGuest(s)
+	})
+	Handle(ClientToServer, func(s *Session, pk *packet.CommandRequest) Action {
+		return restrictGuest(s)
+	})
+}
+
+// restrictGuest drops a packet if the Session passed has the RoleGuest role, notifying the guest that they are not
+// allowed to perform the action.
+func restrictGuest(s *Session) Action {
+	if s.Role() != RoleGuest {
+		return Forward
+	}
+	_ = s.Client().WritePacket(&packet.Text{TextType: packet.TextTypeRaw, Message: s.Translate("guest.restricted")})
+	return Drop
+}
@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Direction is the direction in which a packet travels through the proxy.
+type Direction uint8
+
+const (
+	// ClientToServer is the direction of packets sent by the client to the backend server.
+	ClientToServer Direction = iota
+	// ServerToClient is the direction of packets sent by the backend server to the client.
+	ServerToClient
+)
+
+// String ...
+func (d Direction) String() string {
+	if d == ClientToServer {
+		return "client->server"
+	}
+	return "server->client"
+}
+
+// Action is returned by a packet handler to decide what should happen with the packet it handled.
+type Action uint8
+
+const (
+	// Forward forwards the packet to the other end of the Session, possibly after it was modified by the
+	// handler.
+	Forward Action = iota
+	// Drop drops the packet, so that it never reaches the other end of the Session.
+	Drop
+)
+
+// handlerFunc is a packet handler with its packet type erased, as stored in the handler registry.
+type handlerFunc func(s *Session, pk packet.Packet) Action
+
+// registeredHandler is a handlerFunc together with the order in which it was registered.
+type registeredHandler struct {
+	seq int
+	h   handlerFunc
+}
+
+var (
+	// handlerMu guards handlers, allHandlers and handlerSeq.
+	handlerMu sync.RWMutex
+	// handlers holds all packet handlers registered using Handle, indexed by the Direction they were registered
+	// for and keyed by the packet ID they handle.
+	handlers = [2]map[uint32][]registeredHandler{{}, {}}
+	// allHandlers holds all packet handlers registered using HandleAll, indexed by the Direction they were
+	// registered for.
+	allHandlers [2][]registeredHandler
+	// handlerSeq is the amount of handlers registered so far.
+	handlerSeq int
+)
+
+// Handle registers a handler for packets of type T travelling in the Direction passed. The packet passed to the
+// handler may be modified, in which case the modified packet is forwarded. Handlers are called in the order they
+// were registered, and no further handlers are called once one of them returns Drop.
+// Handle may be called at any time, also while sessions are active. Packets passed to handlers are always of the
+// latest protocol version, regardless of the version of the client.
+//
+//	proxy.Handle[packet.Text](proxy.ClientToServer, func(s *proxy.Session, pk *packet.Text) proxy.Action {
+//		if strings.Contains(pk.Message, "badword") {
+//			return proxy.Drop
+//		}
+//		return proxy.Forward
+//	})
+func Handle[T any, P interface {
+	*T
+	packet.Packet
+}](d Direction, h func(s *Session, pk P) Action) {
+	id := P(new(T)).ID()
+
+	handlerMu.Lock()
+	defer handlerMu.Unlock()
+	handlerSeq++
+	handlers[d][id] = append(handlers[d][id], registeredHandler{seq: handlerSeq, h: func(s *Session, pk packet.Packet) Action {
+		if pk, ok := pk.(P); ok {
+			return h(s, pk)
+		}
+		// The packet had the right ID but a different type, which happens for packets that could not be decoded
+		// and are passed as *packet.Unknown. These are not passed to typed handlers.
+		return Forward
+	}})
+}
+
+// HandleAll registers a handler for all packets travelling in the Direction passed, including packets that could
+// not be decoded, which are passed as *packet.Unknown. It is useful for middleware that inspects every packet, such
+// as loggers. HandleAll handlers are called in the same order as handlers registered using Handle: a handler
+// registered using HandleAll before a typed handler is called before it, and vice versa.
+//
+//	proxy.HandleAll(proxy.ServerToClient, func(s *proxy.Session, pk packet.Packet) proxy.Action {
+//		log.Printf("%v: %T", s.Name(), pk)
+//		return proxy.Forward
+//	})
+func HandleAll(d Direction, h func(s *Session, pk packet.Packet) Action) {
+	handlerMu.Lock()
+	defer handlerMu.Unlock()
+	handlerSeq++
+	allHandlers[d] = append(allHandlers[d], registeredHandler{seq: handlerSeq, h: h})
+}
+
+// drop registers a handler that drops all packets of type T travelling in the Direction passed.
+func drop[T any, P interface {
+	*T
+	packet.Packet
+}](d Direction) {
+	Handle[T, P](d, func(*Session, P) Action { return Drop })
+}
+
+// handle passes a packet travelling in the Direction passed to all handlers registered for it and returns the
+// resulting Action.
+func handle(s *Session, d Direction, pk packet.Packet) Action {
+	handlerMu.RLock()
+	hs, all := handlers[d][pk.ID()], allHandlers[d]
+	handlerMu.RUnlock()
+
+	// Both slices are sorted by registration order, so merging them calls all handlers in the order they were
+	// registered in.
+	for len(hs) > 0 || len(all) > 0 {
+		var h registeredHandler
+		if len(all) == 0 || (len(hs) > 0 && hs[0].seq < all[0].seq) {
+			h, hs = hs[0], hs[1:]
+		} else {
+			h, all = all[0], all[1:]
+		}
+		if h.h(s, pk) == Drop {
+			return Drop
+		}
+	}
+	return Forward
+}
@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// InventoryResync configures the detection of players whose inventory no longer matches the inventory that their
+// server holds for them, which happens most often to players on older versions, whose item stack requests may be
+// translated into requests that the server rejects. Players whose inventory is found to be out of sync have it sent
+// again.
+type InventoryResync struct {
+	// Failures is the amount of item stack requests rejected by the server and inventory mismatches reported by the
+	// client within Window after which the inventory of a player is considered out of sync. If 0, inventories are
+	// never sent again.
+	Failures int
+	// Window is the duration over which failures are counted. An inventory is sent again at most once per Window.
+	Window time.Duration
+}
+
+var (
+	// inventoryResyncMu guards inventoryResync.
+	inventoryResyncMu sync.RWMutex
+	// inventoryResync is the InventoryResync set using SetInventoryResync.
+	inventoryResync InventoryResync
+)
+
+// SetInventoryResync sets the InventoryResync applied to all sessions, including those already online.
+func SetInventoryResync(r InventoryResync) {
+	inventoryResyncMu.Lock()
+	defer inventoryResyncMu.Unlock()
+	inventoryResync = r
+}
+
+// inventorySync detects that the inventory of the client of a Session went out of sync with that of its server and
+// sends it to the client again. It holds the last contents of every window that the server sent, so that these may
+// be sent without waiting for the server.
+type inventorySync struct {
+	mu sync.Mutex
+	// failures holds the times of the failures within the current window, oldest first.
+	failures []time.Time
+	// last is the time the inventory was last sent again.
+	last time.Time
+	// contents holds the last InventoryContent sent by the server, keyed by window ID, with the InventorySlot
+	// packets sent since applied.
+	contents map[uint32]*packet.InventoryContent
+}
+
+func init() {
+	Handle(ServerToClient, func(s *Session, pk *packet.InventoryContent) Action {
+		s.inventory.store(pk)
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.InventorySlot) Action {
+		s.inventory.storeSlot(pk)
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.ItemStackResponse) Action {
+		for _, r := range pk.Responses {
+			if r.Status != protocol.ItemStackResponseStatusOK {
+				s.inventoryFailed("item_stack_response")
+			}
+		}
+		return Forward
+	})
+	Handle(ClientToServer, func(s *Session, pk *packet.InventoryTransaction) Action {
+		if _, ok := pk.TransactionData.(*protocol.MismatchTransactionData); ok {
+			s.inventoryFailed("client_mismatch")
+		}
+		return Forward
+	})
+}
+
+// store stores a copy of an InventoryContent packet sent by the server.
+func (i *inventorySync) store(pk *packet.InventoryContent) {
+	content := &packet.InventoryContent{WindowID: pk.WindowID, Content: append([]protocol.ItemInstance(nil), pk.Content...)}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.contents == nil {
+		i.contents = map[uint32]*packet.InventoryContent{}
+	}
+	i.contents[pk.WindowID] = content
+}
+
+// storeSlot applies an InventorySlot packet sent by the server to the contents stored of its window.
+func (i *inventorySync) storeSlot(pk *packet.InventorySlot) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if content, ok := i.contents[pk.WindowID]; ok && int(pk.Slot) < len(content.Content) {
+		content.Content[pk.Slot] = pk.NewItem
+	}
+}
+
+// reset forgets the failures and contents of the previous server that the Session was attached to.
+func (i *inventorySync) reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.failures, i.contents, i.last = nil, nil, time.Time{}
+}
+
+// fail records a failure at the time passed and reports if the inventory should be sent again, in which case the
+// contents to send are returned.
+func (i *inventorySync) fail(r InventoryResync, now time.Time) ([]packet.Packet, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	n := 0
+	for _, t := range i.failures {
+		if now.Sub(t) < r.Window {
+			i.failures[n] = t
+			n++
+		}
+	}
+	i.failures = append(i.failures[:n], now)
+	if len(i.failures) < r.Failures || now.Sub(i.last) < r.Window {
+		return nil, false
+	}
+	i.failures, i.last = nil, now
+
+	contents := make([]packet.Packet, 0, len(i.contents))
+	for _, content := range i.contents {
+		contents = append(contents, &packet.InventoryContent{WindowID: content.WindowID, Content: append([]protocol.ItemInstance(nil), content.Content...)})
+	}
+	return contents, true
+}
+
+// inventoryFailed records that an item stack request of the client of the Session was rejected, or that the client
+// reported its inventory to be out of sync, as described by the reason passed. Once enough failures were recorded, the
+// contents of its windows last sent by the server are sent to the client again, and the server is told that the
+// inventory is out of sync, upon which it sends the inventory itself.
+func (s *Session) inventoryFailed(reason string) {
+	inventoryResyncMu.RLock()
+	r := inventoryResync
+	inventoryResyncMu.RUnlock()
+	if r.Failures <= 0 {
+		return
+	}
+	metrics.AddTo("inventory_failures", reason, 1)
+	contents, ok := s.inventory.fail(r, time.Now())
+	if !ok {
+		return
+	}
+	metrics.Add("inventory_resyncs", 1)
+	s.Logger().Debug("inventory out of sync, sending it again", "reason", reason, "windows", len(contents))
+	for _, pk := range contents {
+		_ = s.client.WritePacket(pk)
+	}
+	s.writeServer(&packet.InventoryTransaction{TransactionData: &protocol.MismatchTransactionData{}})
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestInventoryResync(t *testing.T) {
+	SetInventoryResync(InventoryResync{Failures: 2, Window: time.Minute})
+	defer SetInventoryResync(InventoryResync{})
+
+	client, server := &recordConn{}, &recordConn{}
+	s := NewSession(client, server, Backend{})
+	defer s.close()
+
+	stick := protocol.ItemInstance{Stack: protocol.ItemStack{ItemType: protocol.ItemType{NetworkID: 5}, Count: 1}}
+	handle(s, ServerToClient, &packet.InventoryContent{WindowID: protocol.WindowIDInventory, Content: make([]protocol.ItemInstance, 3)})
+	handle(s, ServerToClient, &packet.InventorySlot{WindowID: protocol.WindowIDInventory, Slot: 1, NewItem: stick})
+
+	failed := &packet.ItemStackResponse{Responses: []protocol.ItemStackResponse{{Status: protocol.ItemStackResponseStatusError}}}
+	handle(s, ServerToClient, failed)
+	if len(client.packets) != 0 || len(server.packets) != 0 {
+		t.Fatal("expected no resync after a single failure")
+	}
+	if handle(s, ClientToServer, &packet.InventoryTransaction{TransactionData: &protocol.MismatchTransactionData{}}) != Forward {
+		t.Fatal("expected the mismatch of the client to be forwarded")
+	}
+	if len(client.packets) != 1 {
+		t.Fatalf("expected the inventory to be sent to the client, got %+v", client.packets)
+	}
+	content, ok := client.packets[0].(*packet.InventoryContent)
+	if !ok || content.WindowID != protocol.WindowIDInventory || len(content.Content) != 3 || content.Content[1].Stack.NetworkID != 5 {
+		t.Errorf("expected the last inventory of the server, got %+v", client.packets[0])
+	}
+	if len(server.packets) != 1 {
+		t.Fatalf("expected the server to be asked for the inventory, got %+v", server.packets)
+	}
+	if pk, ok := server.packets[0].(*packet.InventoryTransaction); !ok {
+		t.Errorf("expected an inventory transaction, got %+v", server.packets[0])
+	} else if _, ok := pk.TransactionData.(*protocol.MismatchTransactionData); !ok {
+		t.Errorf("expected a mismatch transaction, got %+v", pk.TransactionData)
+	}
+
+	handle(s, ServerToClient, failed)
+	handle(s, ServerToClient, failed)
+	if len(client.packets) != 1 {
+		t.Error("expected the inventory to be sent again at most once per window")
+	}
+}
+
+func TestInventorySyncWindow(t *testing.T) {
+	var i inventorySync
+	r := InventoryResync{Failures: 2, Window: time.Second}
+	now := time.Now()
+	if _, ok := i.fail(r, now); ok {
+		t.Fatal("expected no resync after a single failure")
+	}
+	if _, ok := i.fail(r, now.Add(time.Second*2)); ok {
+		t.Fatal("expected failures outside of the window to be forgotten")
+	}
+	if _, ok := i.fail(r, now.Add(time.Second*2+time.Millisecond)); !ok {
+		t.Fatal("expected a resync after two failures within the window")
+	}
+}
@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// ChunkSnapshot is the set of chunk columns that the client of a Session had loaded at a point in time, as returned
+// by Session.KnownChunks.
+type ChunkSnapshot struct {
+	// Dimension is the dimension that the client was in.
+	Dimension int32
+	// Chunks holds the positions of the chunks loaded, sorted by their X and then their Z coordinate.
+	Chunks []protocol.ChunkPos
+}
+
+// Has checks if the chunk at the position passed in the dimension of the ChunkSnapshot was loaded.
+func (c ChunkSnapshot) Has(pos protocol.ChunkPos) bool {
+	i := sort.Search(len(c.Chunks), func(i int) bool { return !chunkPosLess(c.Chunks[i], pos) })
+	return i < len(c.Chunks) && c.Chunks[i] == pos
+}
+
+// Missing returns the positions passed of the chunks in the dimension passed that were not loaded, which must be
+// sent to a client that still has the chunks of the ChunkSnapshot. All positions are returned if the dimension is
+// not that of the ChunkSnapshot.
+func (c ChunkSnapshot) Missing(dimension int32, positions []protocol.ChunkPos) []protocol.ChunkPos {
+	if dimension != c.Dimension {
+		return positions
+	}
+	missing := make([]protocol.ChunkPos, 0, len(positions))
+	for _, pos := range positions {
+		if !c.Has(pos) {
+			missing = append(missing, pos)
+		}
+	}
+	return missing
+}
+
+// chunkPosLess checks if the chunk position a is ordered before b in a ChunkSnapshot.
+func chunkPosLess(a, b protocol.ChunkPos) bool {
+	if a[0] != b[0] {
+		return a[0] < b[0]
+	}
+	return a[1] < b[1]
+}
+
+// knownChunks tracks the chunk columns that the client of a Session has loaded: the chunks written to it within
+// the radius of the last chunk publisher update written to it. The client unloads chunks outside that radius, and
+// all of its chunks when it changes dimension.
+type knownChunks struct {
+	mu        sync.Mutex
+	dimension int32
+	// center and radius are the position and radius in chunks of the last chunk publisher update. If hasCenter is
+	// false, no update was written yet and chunks are not unloaded.
+	center    protocol.ChunkPos
+	radius    int32
+	hasCenter bool
+	chunks    map[protocol.ChunkPos]struct{}
+}
+
+// newKnownChunks returns the knownChunks of the client passed, which has no chunks loaded yet.
+func newKnownChunks(client Conn) *knownChunks {
+	k := &knownChunks{chunks: map[protocol.ChunkPos]struct{}{}}
+	if data, ok := gameData(client); ok {
+		k.dimension = data.Dimension
+	}
+	return k
+}
+
+// observe updates the chunks known by the client after the packet passed was written to it.
+func (k *knownChunks) observe(pk packet.Packet) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	switch pk := pk.(type) {
+	case *packet.LevelChunk:
+		k.add(pk.Position)
+	case *packet.SubChunk:
+		if pk.Dimension != k.dimension {
+			return
+		}
+		for _, e := range pk.SubChunkEntries {
+			if e.Result == protocol.SubChunkResultSuccess || e.Result == protocol.SubChunkResultSuccessAllAir {
+				k.add(protocol.ChunkPos{pk.Position.X() + int32(e.Offset[0]), pk.Position.Z() + int32(e.Offset[2])})
+			}
+		}
+	case *packet.NetworkChunkPublisherUpdate:
+		k.center = protocol.ChunkPos{pk.Position.X() >> 4, pk.Position.Z() >> 4}
+		k.radius, k.hasCenter = int32(pk.Radius>>4), true
+		for pos := range k.chunks {
+			if !k.inRange(pos) {
+				delete(k.chunks, pos)
+			}
+		}
+	case *packet.ChangeDimension:
+		k.resetLocked(pk.Dimension)
+	}
+}
+
+// add adds the chunk at the position passed if it is within the radius of the last chunk publisher update, as the
+// client discards it otherwise. k.mu must be held when calling add.
+func (k *knownChunks) add(pos protocol.ChunkPos) {
+	if k.inRange(pos) {
+		k.chunks[pos] = struct{}{}
+	}
+}
+
+// inRange checks if the chunk at the position passed is within the radius of the last chunk publisher update. k.mu
+// must be held when calling inRange.
+func (k *knownChunks) inRange(pos protocol.ChunkPos) bool {
+	if !k.hasCenter {
+		return true
+	}
+	dx, dz := int64(pos[0]-k.center[0]), int64(pos[1]-k.center[1])
+	return dx*dx+dz*dz <= int64(k.radius)*int64(k.radius)
+}
+
+// reset forgets all chunks after the client was moved to the dimension passed.
+func (k *knownChunks) reset(dimension int32) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.resetLocked(dimension)
+}
+
+// resetLocked resets the knownChunks like reset. k.mu must be held when calling resetLocked.
+func (k *knownChunks) resetLocked(dimension int32) {
+	k.dimension, k.chunks = dimension, map[protocol.ChunkPos]struct{}{}
+}
+
+// KnownChunks returns a ChunkSnapshot of the chunk columns that the client of the Session currently has loaded, as
+// far as the proxy can tell from the chunks and chunk publisher updates written to it.
+func (s *Session) KnownChunks() ChunkSnapshot {
+	s.known.mu.Lock()
+	defer s.known.mu.Unlock()
+	c := ChunkSnapshot{Dimension: s.known.dimension, Chunks: make([]protocol.ChunkPos, 0, len(s.known.chunks))}
+	for pos := range s.known.chunks {
+		c.Chunks = append(c.Chunks, pos)
+	}
+	sort.Slice(c.Chunks, func(i, j int) bool { return chunkPosLess(c.Chunks[i], c.Chunks[j]) })
+	return c
+}
+
+// RestoreKnownChunks replaces the chunk columns that the client of the Session is known to have loaded with those of
+// the ChunkSnapshot passed. It should be called when the client is known to still have the chunks of a snapshot
+// taken earlier, such as after the Session was handed back from a Source that kept the client in the same world and
+// only sent the chunks it changed, so that ChunkSnapshot.Missing reports the chunks that must be sent again.
+func (s *Session) RestoreKnownChunks(c ChunkSnapshot) {
+	s.known.mu.Lock()
+	defer s.known.mu.Unlock()
+	s.known.resetLocked(c.Dimension)
+	for _, pos := range c.Chunks {
+		s.known.chunks[pos] = struct{}{}
+	}
+}
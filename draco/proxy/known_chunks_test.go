@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestKnownChunks(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	defer s.close()
+
+	for _, pk := range []packet.Packet{
+		&packet.LevelChunk{Position: protocol.ChunkPos{0, 0}},
+		&packet.LevelChunk{Position: protocol.ChunkPos{5, 0}},
+		&packet.SubChunk{Position: protocol.SubChunkPos{0, 0, 1}, SubChunkEntries: []protocol.SubChunkEntry{
+			{Offset: [3]int8{0, 0, 0}, Result: protocol.SubChunkResultSuccess},
+			{Offset: [3]int8{1, 0, 0}, Result: protocol.SubChunkResultChunkNotFound},
+		}},
+		// The chunk at 5, 0 is outside the radius of the update, so the client unloads it.
+		&packet.NetworkChunkPublisherUpdate{Position: protocol.BlockPos{8, 64, 8}, Radius: 2 << 4},
+		&packet.LevelChunk{Position: protocol.ChunkPos{-3, 0}},
+	} {
+		if err := s.writeClient(pk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshot := s.KnownChunks()
+	if want := []protocol.ChunkPos{{0, 0}, {0, 1}}; !reflect.DeepEqual(snapshot.Chunks, want) {
+		t.Fatalf("known chunks %v, expected %v", snapshot.Chunks, want)
+	}
+	if missing := snapshot.Missing(0, []protocol.ChunkPos{{0, 0}, {1, 0}}); !reflect.DeepEqual(missing, []protocol.ChunkPos{{1, 0}}) {
+		t.Fatalf("missing chunks %v, expected [[1 0]]", missing)
+	}
+	if missing := snapshot.Missing(packet.DimensionNether, []protocol.ChunkPos{{0, 0}}); len(missing) != 1 {
+		t.Fatal("chunks of another dimension were reported as known")
+	}
+
+	_ = s.writeClient(&packet.ChangeDimension{Dimension: packet.DimensionEnd})
+	if c := s.KnownChunks(); c.Dimension != packet.DimensionEnd || len(c.Chunks) != 0 {
+		t.Fatalf("chunks %v in dimension %v known after changing dimension", c.Chunks, c.Dimension)
+	}
+	s.RestoreKnownChunks(snapshot)
+	if c := s.KnownChunks(); !reflect.DeepEqual(c, snapshot) {
+		t.Fatalf("restored chunks %v, expected %v", c, snapshot)
+	}
+}
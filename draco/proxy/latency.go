@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// LatencyReport reports the latency that the proxy added to the packets forwarded in one Direction for a Session:
+// the time between a packet being read from one end and it being written to the other, which includes running the
+// handlers of the packet, translating it to the protocol of the client and encoding it. Packets sent by the client
+// are translated while they are read, so for those the time spent translating is not included.
+type LatencyReport struct {
+	// Packets is the amount of packets forwarded.
+	Packets int64 `json:"packets"`
+	// P50 and P99 are the median and the 99th percentile of the latency added to the packets. They are approximated
+	// to within a fifth of their value.
+	P50 time.Duration `json:"p50"`
+	P99 time.Duration `json:"p99"`
+	// Total is the latency added to all packets together, and Translation the part of it spent writing packets to
+	// the other end, which consists of translating and encoding them.
+	Total       time.Duration `json:"total"`
+	Translation time.Duration `json:"translation"`
+	// Classes holds the latency added per packet class, ordered by their share of Total from large to small.
+	Classes []ClassLatency `json:"classes"`
+}
+
+// ClassLatency reports the latency that the proxy added to the packets of one class, such as LevelChunk.
+type ClassLatency struct {
+	// Class is the name of the packet type.
+	Class string `json:"class"`
+	// Packets is the amount of packets of the class forwarded.
+	Packets int64 `json:"packets"`
+	// Total is the latency added to all packets of the class and Translation the part of it spent writing them.
+	Total       time.Duration `json:"total"`
+	Translation time.Duration `json:"translation"`
+	// Share is the share of the latency added to all packets forwarded that the class accounts for, from 0 to 1.
+	Share float64 `json:"share"`
+}
+
+const (
+	// latencyBucketsPerDoubling is the amount of histogram buckets for every doubling of the latency, which
+	// determines how closely percentiles are approximated.
+	latencyBucketsPerDoubling = 4
+	// latencyBuckets is the amount of histogram buckets. The first holds latencies below a microsecond and the last
+	// holds latencies above 2^24 microseconds, about 17 seconds.
+	latencyBuckets = 24*latencyBucketsPerDoubling + 2
+)
+
+// latencyStats records the latency added to the packets forwarded in a single Direction.
+type latencyStats struct {
+	mu sync.Mutex
+	// buckets is a histogram of the latency added, in which bucket i holds latencies up to latencyBound(i).
+	buckets     [latencyBuckets]int64
+	packets     int64
+	total       time.Duration
+	translation time.Duration
+	classes     map[uint32]*ClassLatency
+}
+
+// record records the latency added to the packet passed and the part of it spent writing the packet.
+func (l *latencyStats) record(pk packet.Packet, total, write time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buckets[latencyBucket(total)]++
+	l.packets++
+	l.total += total
+	l.translation += write
+
+	if l.classes == nil {
+		l.classes = map[uint32]*ClassLatency{}
+	}
+	c, ok := l.classes[pk.ID()]
+	if !ok {
+		c = &ClassLatency{Class: strings.TrimPrefix(fmt.Sprintf("%T", pk), "*packet.")}
+		l.classes[pk.ID()] = c
+	}
+	c.Packets++
+	c.Total += total
+	c.Translation += write
+}
+
+// report returns a LatencyReport of the latency recorded so far.
+func (l *latencyStats) report() LatencyReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r := LatencyReport{
+		Packets:     l.packets,
+		P50:         l.percentile(0.5),
+		P99:         l.percentile(0.99),
+		Total:       l.total,
+		Translation: l.translation,
+		Classes:     make([]ClassLatency, 0, len(l.classes)),
+	}
+	for _, c := range l.classes {
+		class := *c
+		if l.total > 0 {
+			class.Share = float64(c.Total) / float64(l.total)
+		}
+		r.Classes = append(r.Classes, class)
+	}
+	sort.Slice(r.Classes, func(i, j int) bool {
+		return r.Classes[i].Total > r.Classes[j].Total
+	})
+	return r
+}
+
+// percentile returns the upper bound of the histogram bucket holding the percentile p, from 0 to 1, of the latency
+// recorded. l.mu must be held when calling percentile.
+func (l *latencyStats) percentile(p float64) time.Duration {
+	if l.packets == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(p * float64(l.packets)))
+	var n int64
+	for i, count := range l.buckets {
+		if n += count; n >= rank {
+			return latencyBound(i)
+		}
+	}
+	return latencyBound(latencyBuckets - 1)
+}
+
+// latencyBucket returns the index of the histogram bucket that holds the latency passed.
+func latencyBucket(d time.Duration) int {
+	us := float64(d) / float64(time.Microsecond)
+	if us <= 1 {
+		return 0
+	}
+	i := int(math.Ceil(math.Log2(us)*latencyBucketsPerDoubling)) + 1
+	if i >= latencyBuckets {
+		return latencyBuckets - 1
+	}
+	return i
+}
+
+// latencyBound returns the upper bound of the histogram bucket with the index passed.
+func latencyBound(i int) time.Duration {
+	return time.Duration(math.Pow(2, float64(i-1)/latencyBucketsPerDoubling) * float64(time.Microsecond))
+}
+
+// Latency returns a LatencyReport of the latency that the proxy added to the packets forwarded in the Direction
+// passed since the Session was started.
+func (s *Session) Latency(d Direction) LatencyReport {
+	return s.latency[d].report()
+}
+
+var (
+	// latencyReportMu guards latencyReports.
+	latencyReportMu sync.RWMutex
+	// latencyReports specifies if the LatencyReports of sessions are logged when they close.
+	latencyReports bool
+)
+
+// SetLatencyReports sets if a report of the latency that the proxy added to the packets of a Session is logged when
+// it closes, so that the operators of backends can see how much latency the proxy adds and where.
+func SetLatencyReports(enabled bool) {
+	latencyReportMu.Lock()
+	defer latencyReportMu.Unlock()
+	latencyReports = enabled
+}
+
+// maxReportedClasses is the maximum amount of packet classes included in a logged latency report.
+const maxReportedClasses = 5
+
+func init() {
+	OnClose(func(s *Session) {
+		latencyReportMu.RLock()
+		enabled := latencyReports
+		latencyReportMu.RUnlock()
+		if !enabled {
+			return
+		}
+		for _, d := range []Direction{ServerToClient, ClientToServer} {
+			r := s.Latency(d)
+			if r.Packets == 0 {
+				continue
+			}
+			s.Logger().Info("latency added", "direction", d, "report", r)
+		}
+	})
+}
+
+// String summarises the LatencyReport, including the packet classes that account for most of the latency added.
+func (r LatencyReport) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%v packets, p50 %v, p99 %v, translation %.1f%%", r.Packets, r.P50, r.P99, share(r.Translation, r.Total)*100)
+	for i, c := range r.Classes {
+		if i == maxReportedClasses {
+			break
+		}
+		sep := ", "
+		if i == 0 {
+			sep = "; "
+		}
+		fmt.Fprintf(b, "%v%v %.1f%% (%v packets, translation %.1f%%)", sep, c.Class, c.Share*100, c.Packets, share(c.Translation, c.Total)*100)
+	}
+	return b.String()
+}
+
+// share returns the share of total that part is, or 0 if total is 0.
+func share(part, total time.Duration) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
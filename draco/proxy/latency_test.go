@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestLatencyReport(t *testing.T) {
+	var l latencyStats
+	for i := 0; i < 98; i++ {
+		l.record(&packet.MovePlayer{}, time.Millisecond, 0)
+	}
+	l.record(&packet.LevelChunk{}, time.Second, time.Second/2)
+	l.record(&packet.LevelChunk{}, time.Second, time.Second/2)
+
+	r := l.report()
+	if r.Packets != 100 {
+		t.Fatalf("expected 100 packets, got %v", r.Packets)
+	}
+	// Percentiles are approximated by the upper bound of their histogram bucket.
+	if r.P50 < time.Millisecond || r.P50 > time.Millisecond*6/5 {
+		t.Errorf("p50 %v is not close to 1ms", r.P50)
+	}
+	if r.P99 < time.Second || r.P99 > time.Second*6/5 {
+		t.Errorf("p99 %v is not close to 1s", r.P99)
+	}
+	if len(r.Classes) != 2 || r.Classes[0].Class != "LevelChunk" || r.Classes[0].Packets != 2 {
+		t.Fatalf("unexpected classes %+v", r.Classes)
+	}
+	if c := r.Classes[0]; c.Translation != time.Second || c.Share < 0.95 {
+		t.Errorf("unexpected latency of chunks %+v", c)
+	}
+}
+
+func TestLatencyBucket(t *testing.T) {
+	for _, d := range []time.Duration{0, time.Microsecond / 2, time.Microsecond * 3, time.Millisecond * 7, time.Second * 3} {
+		i := latencyBucket(d)
+		if latencyBound(i) < d || (i > 0 && latencyBound(i-1) >= d) {
+			t.Errorf("latency %v in bucket %v with bound %v", d, i, latencyBound(i))
+		}
+	}
+	if i := latencyBucket(time.Hour); i != latencyBuckets-1 {
+		t.Errorf("latency of an hour in bucket %v rather than the last", i)
+	}
+}
@@ -0,0 +1,277 @@
+package proxy
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/chunk"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// LimboWorld configures the world that the proxy serves to players held in limbo, such as while their backend is
+// down. The world is empty apart from an invisible floor that players stand on.
+type LimboWorld struct {
+	// Message is the message shown to players in limbo. If empty, the "limbo.message" message is shown in the
+	// language of the player.
+	Message string
+	// BossBar specifies if the message is shown on a boss bar rather than as a title.
+	BossBar bool
+	// DuringTransfers specifies if players are held in limbo while the backend they are transferred to is dialed,
+	// rather than staying on their current backend until the new one is joined. Players whose transfer fails are
+	// reconnected to their previous backend.
+	DuringTransfers bool
+}
+
+var (
+	// limboMu guards limboConf.
+	limboMu sync.RWMutex
+	// limboConf is the LimboWorld set using SetLimboWorld.
+	limboConf LimboWorld
+)
+
+// SetLimboWorld sets the LimboWorld served to players held in limbo.
+func SetLimboWorld(w LimboWorld) {
+	limboMu.Lock()
+	defer limboMu.Unlock()
+	limboConf = w
+}
+
+// limboWorld returns the LimboWorld currently used.
+func limboWorld() LimboWorld {
+	limboMu.RLock()
+	defer limboMu.RUnlock()
+	return limboConf
+}
+
+const (
+	// limboChunkRadius is the radius of the chunks around the player that the limbo sends. The client doesn't let
+	// the player walk into chunks that are not loaded, so the player can't walk off the floor.
+	limboChunkRadius = 2
+	// limboTitleTicks is the amount of ticks that a title shown by the limbo stays on the screen. The title is sent
+	// again every keepAliveInterval, so it disappears shortly after the player leaves the limbo.
+	limboTitleTicks = 40
+)
+
+// limboPosition is the position of players held in limbo. The floor is right below it.
+var limboPosition = mgl32.Vec3{0.5, 100, 0.5}
+
+// enterLimbo attaches the Session to a new limbo, moving the client to the world of the limbo.
+func (s *Session) enterLimbo() (*limbo, error) {
+	conf := limboWorld()
+	message := conf.Message
+	if message == "" {
+		message = s.Translate("limbo.message")
+	}
+	// The player itself is the boss entity of the boss bar, as the client only shows boss bars of entities it knows.
+	bossID := s.playerUniqueID()
+
+	s.world.mu.Lock()
+	// The limbo is in another dimension than the client is in, so that the client clears the chunks of the previous
+	// server.
+	dimension := int32(packet.DimensionEnd)
+	if s.world.dimension == packet.DimensionEnd {
+		dimension = packet.DimensionOverworld
+	}
+	t := s.world.time
+	s.world.mu.Unlock()
+
+	l := newLimbo(newHeldWorld(limboPosition, t))
+	l.title = message
+	if conf.BossBar {
+		l.title = ""
+	}
+	l.send(&packet.ChangeDimension{Dimension: dimension, Position: limboPosition})
+	for _, pk := range limboChunks(clientBlocks(s.client), dimension) {
+		l.send(pk)
+	}
+	l.send(&packet.PlayStatus{Status: packet.PlayStatusPlayerSpawn})
+	if conf.BossBar {
+		l.send(&packet.BossEvent{BossEntityUniqueID: bossID, EventType: packet.BossEventShow, BossBarTitle: message, HealthPercentage: 1})
+	} else {
+		l.send(&packet.SetTitle{ActionType: packet.TitleActionSetDurations, RemainDuration: limboTitleTicks, FadeOutDuration: 10})
+	}
+	go l.keepAlive()
+
+	if err := s.AttachSource(l, LimboBackend); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// limboChunks returns the chunks of the limbo in the dimension passed: empty chunks holding a floor of barriers
+// below limboPosition. The chunks are sent in the latest protocol, like all packets written to clients, using the
+// block palette of the state.Registry passed.
+func limboChunks(blocks *state.Registry, dimension int32) []packet.Packet {
+	var air, barrier uint32
+	floor := false
+	if p, ok := blocks.PaletteOf(state.Version(protocol.CurrentProtocol)); ok {
+		air, _ = p.RuntimeID("minecraft:air", nil)
+		barrier, floor = p.RuntimeID("minecraft:barrier", nil)
+	}
+	c := chunk.New(air, dimensionRange(dimension))
+	if floor {
+		for x := uint8(0); x < 16; x++ {
+			for z := uint8(0); z < 16; z++ {
+				c.SetBlock(x, int16(limboPosition[1])-1, z, 0, barrier)
+			}
+		}
+	}
+	data := chunk.Encode(c, chunk.NetworkEncoding)
+	// The sub chunks above the floor only hold air, so they are not sent.
+	count := data.SubChunkCount()
+	buf := bytes.NewBuffer(nil)
+	for _, sub := range data.SubChunks[:count] {
+		buf.Write(sub)
+	}
+	buf.Write(data.Biomes)
+	// The chunk has no border blocks.
+	buf.WriteByte(0)
+	payload := buf.Bytes()
+
+	pks := make([]packet.Packet, 0, (limboChunkRadius*2+1)*(limboChunkRadius*2+1)+1)
+	pks = append(pks, &packet.NetworkChunkPublisherUpdate{
+		Position: protocol.BlockPos{int32(limboPosition[0]), int32(limboPosition[1]), int32(limboPosition[2])},
+		Radius:   uint32(limboChunkRadius) << 4,
+	})
+	for x := int32(-limboChunkRadius); x <= limboChunkRadius; x++ {
+		for z := int32(-limboChunkRadius); z <= limboChunkRadius; z++ {
+			pks = append(pks, &packet.LevelChunk{
+				Position:      protocol.ChunkPos{x, z},
+				SubChunkCount: uint32(count),
+				RawPayload:    payload,
+			})
+		}
+	}
+	return pks
+}
+
+// keepAliveInterval is the interval at which a heldWorld sends the chunk publisher position and time to the client.
+const keepAliveInterval = time.Second
+
+// heldWorld is the world that a client held by the proxy while no backend is attached believes it is in, such as the
+// limbo or the world of a challenge. It answers the packets that the client expects a server to answer and produces
+// the minimum traffic needed to prevent the client from timing out or desyncing.
+type heldWorld struct {
+	// position is the position of the player. The chunks around it are kept loaded by the client.
+	position mgl32.Vec3
+	// time is the time of day in the world when the client is first held. It advances by 20 every second.
+	time  int32
+	start time.Time
+
+	mu     sync.Mutex
+	radius int32
+}
+
+// newHeldWorld returns a heldWorld with the player at the position passed and the time of day passed, starting now.
+func newHeldWorld(position mgl32.Vec3, t int32) *heldWorld {
+	return &heldWorld{position: position, time: t, start: time.Now(), radius: 8}
+}
+
+// ticks returns the amount of ticks that passed since the client was first held.
+func (w *heldWorld) ticks() int64 {
+	return int64(time.Since(w.start) / (time.Second / 20))
+}
+
+// answer returns the answer to a packet sent by a held client, or nil if the packet needs no answer.
+func (w *heldWorld) answer(pk packet.Packet) packet.Packet {
+	switch pk := pk.(type) {
+	case *packet.TickSync:
+		return &packet.TickSync{ClientRequestTimestamp: pk.ClientRequestTimestamp, ServerReceptionTimestamp: w.ticks()}
+	case *packet.RequestChunkRadius:
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.radius = pk.ChunkRadius
+		return &packet.ChunkRadiusUpdated{ChunkRadius: w.radius}
+	}
+	return nil
+}
+
+// keepAlive returns the packets sent to a held client every keepAliveInterval: the chunk publisher position and time.
+func (w *heldWorld) keepAlive() []packet.Packet {
+	w.mu.Lock()
+	radius := w.radius
+	w.mu.Unlock()
+	pos := protocol.BlockPos{int32(w.position[0]), int32(w.position[1]), int32(w.position[2])}
+	return []packet.Packet{
+		&packet.NetworkChunkPublisherUpdate{Position: pos, Radius: uint32(radius) << 4},
+		&packet.SetTime{Time: w.time + int32(w.ticks())},
+	}
+}
+
+// limbo is a Source served by the proxy that keeps a client connected while no backend is available.
+type limbo struct {
+	world   *heldWorld
+	packets chan packet.Packet
+	// title is the title shown to the client every keepAliveInterval. If empty, no title is shown.
+	title string
+
+	once   sync.Once
+	closed chan struct{}
+}
+
+// newLimbo returns a limbo holding the client in the world passed. keepAlive must be called to start sending the
+// client the packets that keep it connected.
+func newLimbo(w *heldWorld) *limbo {
+	// The queue fits all packets sent when the client enters the limbo.
+	return &limbo{world: w, packets: make(chan packet.Packet, 64), closed: make(chan struct{})}
+}
+
+// ReadPacket ...
+func (l *limbo) ReadPacket() (packet.Packet, error) {
+	select {
+	case pk := <-l.packets:
+		return pk, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// WritePacket answers the packets sent by the client that need an answer and discards all others.
+func (l *limbo) WritePacket(pk packet.Packet) error {
+	if answer := l.world.answer(pk); answer != nil {
+		l.send(answer)
+	}
+	return nil
+}
+
+// Close ...
+func (l *limbo) Close() error {
+	l.once.Do(func() {
+		close(l.closed)
+	})
+	return nil
+}
+
+// send queues a packet to be read using ReadPacket. If the queue is full, because the Session is not reading from
+// the limbo yet, the packet is dropped, so that the goroutine forwarding packets of the client never blocks.
+func (l *limbo) send(pk packet.Packet) {
+	select {
+	case l.packets <- pk:
+	default:
+	}
+}
+
+// keepAlive queues the packets that keep the client alive every keepAliveInterval until the limbo is closed.
+func (l *limbo) keepAlive() {
+	t := time.NewTicker(keepAliveInterval)
+	defer t.Stop()
+	for {
+		for _, pk := range l.world.keepAlive() {
+			l.send(pk)
+		}
+		if l.title != "" {
+			l.send(&packet.SetTitle{ActionType: packet.TitleActionSetTitle, Text: l.title})
+		}
+		select {
+		case <-t.C:
+		case <-l.closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/chunk"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestEnterLimbo(t *testing.T) {
+	SetLimboWorld(LimboWorld{Message: "please wait"})
+	defer SetLimboWorld(LimboWorld{})
+
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{Name: "game"})
+	defer s.close()
+	if _, err := s.enterLimbo(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Backend().Name != LimboBackend {
+		t.Fatalf("player attached to %v rather than limbo", s.Backend().Name)
+	}
+
+	read := func() packet.Packet {
+		pk, err := s.Server().ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pk
+	}
+	if pk, ok := read().(*packet.ChangeDimension); !ok || pk.Dimension != packet.DimensionEnd {
+		t.Fatalf("limbo did not start with a dimension change to the end: %#v", pk)
+	}
+	if _, ok := read().(*packet.NetworkChunkPublisherUpdate); !ok {
+		t.Fatalf("limbo did not send a chunk publisher update")
+	}
+	p, _ := state.PaletteOf(state.Version(protocol.CurrentProtocol))
+	air, _ := p.RuntimeID("minecraft:air", nil)
+	barrier, _ := p.RuntimeID("minecraft:barrier", nil)
+	for i := 0; i < (limboChunkRadius*2+1)*(limboChunkRadius*2+1); i++ {
+		pk, ok := read().(*packet.LevelChunk)
+		if !ok {
+			t.Fatalf("expected chunk %v of the limbo", i)
+		}
+		r := dimensionRange(packet.DimensionEnd)
+		c, err := chunk.NetworkDecode(air, bytes.NewBuffer(pk.RawPayload), int(pk.SubChunkCount), r)
+		if err != nil {
+			t.Fatalf("error decoding chunk %v: %v", pk.Position, err)
+		}
+		y := int16(limboPosition[1])
+		if c.Block(3, y-1, 7, 0) != barrier || c.Block(3, y, 7, 0) != air {
+			t.Fatalf("chunk %v has no floor below the player", pk.Position)
+		}
+	}
+	if pk, ok := read().(*packet.PlayStatus); !ok || pk.Status != packet.PlayStatusPlayerSpawn {
+		t.Fatalf("limbo did not spawn the player")
+	}
+	if _, ok := read().(*packet.SetTitle); !ok {
+		t.Fatalf("limbo did not set the title durations")
+	}
+}
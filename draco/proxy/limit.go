@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+)
+
+var (
+	// ErrProxyFull is returned by Reserve if the maximum amount of players on the proxy was reached.
+	ErrProxyFull = errors.New("proxy is full")
+	// ErrBackendFull is returned by Reserve if the maximum amount of players on a backend was reached.
+	ErrBackendFull = errors.New("backend is full")
+)
+
+var (
+	// limitMu guards the fields below.
+	limitMu sync.Mutex
+	// maxPlayers is the maximum amount of players on the proxy. If 0, there is no limit.
+	maxPlayers int
+	// players is the amount of players currently holding a slot on the proxy.
+	players int
+	// backendPlayers holds the amount of players holding a slot on a backend, keyed by the name of the backend.
+	backendPlayers = map[string]int{}
+)
+
+// SetMaxPlayers sets the maximum amount of players connected to the proxy at the same time, regardless of the
+// backend they are connected to. If n is 0, there is no limit.
+func SetMaxPlayers(n int) {
+	limitMu.Lock()
+	defer limitMu.Unlock()
+	maxPlayers = n
+}
+
+// PlayerCount returns the amount of players currently connected or connecting to the proxy.
+func PlayerCount() int {
+	limitMu.Lock()
+	defer limitMu.Unlock()
+	return players
+}
+
+// BackendPlayerCount returns the amount of players currently connected or connecting to the Backend with the name
+// passed.
+func BackendPlayerCount(name string) int {
+	limitMu.Lock()
+	defer limitMu.Unlock()
+	return backendPlayers[name]
+}
+
+// Reserve reserves a slot for a player joining the Backend passed. ErrProxyFull or ErrBackendFull is returned if
+// no more players may join. A slot obtained must be released using Release if no Session is started for it: a
+// Session started holds on to the slot and releases it once it is closed.
+func Reserve(b Backend) error {
+	limitMu.Lock()
+	defer limitMu.Unlock()
+
+	if maxPlayers > 0 && players >= maxPlayers {
+		return ErrProxyFull
+	}
+	if err := reserveBackend(b); err != nil {
+		return err
+	}
+	players++
+	return nil
+}
+
+// Release releases a slot on the Backend passed previously obtained using Reserve.
+func Release(b Backend) {
+	limitMu.Lock()
+	defer limitMu.Unlock()
+
+	players--
+	releaseBackend(b)
+}
+
+// reserveBackend reserves a slot on the Backend passed only, for a player that already holds a slot on the proxy.
+// limitMu must be held when calling reserveBackend.
+func reserveBackend(b Backend) error {
+	if b.MaxPlayers > 0 && backendPlayers[b.Name] >= b.MaxPlayers {
+		return ErrBackendFull
+	}
+	backendPlayers[b.Name]++
+	return nil
+}
+
+// releaseBackend releases a slot on the Backend passed obtained using reserveBackend. limitMu must be held when
+// calling releaseBackend.
+func releaseBackend(b Backend) {
+	if backendPlayers[b.Name]--; backendPlayers[b.Name] <= 0 {
+		delete(backendPlayers, b.Name)
+	}
+}
+
+// LimitStatusProvider wraps around a minecraft.ServerStatusProvider and reports the maximum amount of players set
+// using SetMaxPlayers in the server list, showing the server as full once the limit is reached.
+type LimitStatusProvider struct {
+	minecraft.ServerStatusProvider
+}
+
+// ServerStatus ...
+func (p LimitStatusProvider) ServerStatus(playerCount, listenerMaxPlayers int) minecraft.ServerStatus {
+	status := p.ServerStatusProvider.ServerStatus(playerCount, listenerMaxPlayers)
+
+	limitMu.Lock()
+	defer limitMu.Unlock()
+	if maxPlayers > 0 {
+		status.MaxPlayers = maxPlayers
+		if players >= maxPlayers || status.PlayerCount > maxPlayers {
+			status.PlayerCount = maxPlayers
+		}
+	}
+	return status
+}
@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"net"
+
+	"github.com/cqdetdev/draco/draco/logging"
+)
+
+// ClientLogger returns a logging.Logger that logs lines with the fields of the client passed, which joined with the
+// name and XUID passed: "xuid", "name", "address", "version", the game version reported by the client, and
+// "protocol", the protocol ID that the client logged in with, if known.
+func ClientLogger(c ClientConn, name, xuid string) *logging.Logger {
+	fields := []any{"xuid", xuid, "name", name}
+	if a, ok := c.(interface{ RemoteAddr() net.Addr }); ok {
+		fields = append(fields, "address", a.RemoteAddr().String())
+	}
+	fields = append(fields, "version", c.ClientData().GameVersion)
+	if p, ok := ClientProtocol(c); ok {
+		fields = append(fields, "protocol", p)
+	}
+	return logging.Default().With(fields...)
+}
+
+// ClientProtocol returns the protocol ID that the client passed logged in with. False is returned if the protocol is
+// not known, such as for clients of a listener that doesn't pass its packets to ObserveLogin.
+func ClientProtocol(c ClientConn) (int32, bool) {
+	if c, ok := c.(listenerConn); ok && c.protocolKnown {
+		return c.protocol, true
+	}
+	return 0, false
+}
+
+// Logger returns a logging.Logger that logs lines with the fields of the player of the Session, like ClientLogger,
+// and the name of the backend that the Session is attached to under "backend".
+func (s *Session) Logger() *logging.Logger {
+	return ClientLogger(s.client, s.Name(), s.XUID()).With("backend", s.Backend().Name)
+}
@@ -0,0 +1,22 @@
+package proxy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/logging"
+)
+
+func TestSessionLogger(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logging.Configure(logging.Config{Output: buf})
+	defer logging.Configure(logging.Config{})
+
+	conn := &recordConn{}
+	s := NewGuestSession(conn, conn, Backend{Name: "game"}, "Guest Steve")
+	s.Logger().Warn("malformed packet", "direction", ClientToServer)
+	if line := buf.String(); !strings.HasSuffix(line, ` WARN malformed packet xuid="" name="Guest Steve" version="" backend=game direction=`+ClientToServer.String()+"\n") {
+		t.Errorf("unexpected line %q", line)
+	}
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/cqdetdev/draco/draco/policy"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// errMalformed is returned by writeClient if the client was disconnected for a packet that couldn't be translated.
+var errMalformed = errors.New("malformed packet")
+
+// malformed applies the decode policy to a packet sent in the Direction passed that couldn't be translated, with the
+// error passed. The packet is dropped under the lenient and drop policies, while the client is disconnected under the
+// strict policy, in which case true is returned. Other sessions are never affected. Packets that gophertunnel itself
+// can't decode never reach the Session: they are logged and dropped by gophertunnel regardless of the policy.
+func (s *Session) malformed(d Direction, err error) bool {
+	metrics.Add("malformed_packets", 1)
+	metrics.AddTo("malformed_packets_by_direction", d.String(), 1)
+	count := atomic.AddUint64(&s.translationErrors[d], 1)
+	p := policy.Current()
+	s.Logger().Warn("malformed packet", "direction", d, "policy", p, "count", count, "err", err)
+	emit(PacketErrorEvent{Session: s, Direction: d, Err: err, Disconnected: p.Disconnects(), Count: count})
+	if !p.Disconnects() {
+		return false
+	}
+	s.setCause(CauseTranslationError)
+	s.disconnect(s.Translate("disconnect.malformed_packet"))
+	return true
+}
+
+// TranslationErrors returns the amount of packets sent in the Direction passed that couldn't be translated since the
+// Session joined, regardless of whether they were dropped or the Session was disconnected for them.
+func (s *Session) TranslationErrors(d Direction) uint64 {
+	return atomic.LoadUint64(&s.translationErrors[d])
+}
+
+// writeClient writes a packet to the client. The client translates the packet to its protocol while writing it,
+// which panics if the packet can't be translated, in which case the decode policy is applied to it. errMalformed is
+// returned if the client was disconnected as a result.
+func (s *Session) writeClient(pk packet.Packet) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if s.malformed(ServerToClient, fmt.Errorf("translate %T: %v", pk, r)) {
+				err = errMalformed
+			}
+		}
+	}()
+	if j, ok := s.pipeline.take(pk); ok {
+		// The packet was already translated by the chunk workers.
+		if j.err != nil {
+			if s.malformed(ServerToClient, j.err) {
+				return errMalformed
+			}
+			return nil
+		}
+		if _, err := s.pipeline.conn.Write(j.data); err != nil {
+			s.setCause(clientCause(s, err))
+			return err
+		}
+	} else if err := s.client.WritePacket(pk); err != nil {
+		s.setCause(clientCause(s, err))
+		return err
+	}
+	s.known.observe(pk)
+	return nil
+}
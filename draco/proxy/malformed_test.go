@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/policy"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// untranslatableConn is a connection that panics writing any packet, like a client does writing a packet that can't
+// be translated to its protocol.
+type untranslatableConn struct {
+	benchConn
+}
+
+func (untranslatableConn) WritePacket(pk packet.Packet) error {
+	panic("no such block state")
+}
+
+func TestWriteClientPolicy(t *testing.T) {
+	defer policy.Set(policy.Current())
+	conn := &untranslatableConn{}
+	s := NewSession(conn, conn, Backend{Name: "game"})
+	defer s.close()
+
+	policy.Set(policy.Lenient)
+	if err := s.writeClient(&packet.UpdateBlock{}); err != nil {
+		t.Fatalf("untranslatable packet was not dropped under the lenient policy: %v", err)
+	}
+	if s.disconnected {
+		t.Fatal("player was disconnected under the lenient policy")
+	}
+	policy.Set(policy.Drop)
+	if err := s.writeClient(&packet.UpdateBlock{}); err != nil {
+		t.Fatalf("untranslatable packet was not dropped under the drop policy: %v", err)
+	}
+	if s.disconnected {
+		t.Fatal("player was disconnected under the drop policy")
+	}
+	if n := s.TranslationErrors(ServerToClient); n != 2 {
+		t.Errorf("expected 2 translation errors, got %v", n)
+	}
+	policy.Set(policy.Strict)
+	if err := s.writeClient(&packet.UpdateBlock{}); err != errMalformed {
+		t.Fatalf("writing an untranslatable packet under the strict policy returned %v", err)
+	}
+	if !s.disconnected {
+		t.Fatal("player was not disconnected under the strict policy")
+	}
+}
@@ -0,0 +1,244 @@
+package proxy
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// MemoryWatch configures the accounting of the memory held by the state that the proxy keeps for sessions, such as
+// the entities spawned, the chunks known and the packets queued.
+type MemoryWatch struct {
+	// Limit is the estimated amount of bytes that the state of a single Session may hold before a warning is logged
+	// for it. If 0, no warnings are logged for sessions online.
+	Limit int
+	// Interval is the interval at which the footprints of sessions are checked, and at which sessions closed are
+	// checked for leaks. If 0, sessions are not watched at all.
+	Interval time.Duration
+}
+
+// Footprint is an estimate of the memory held by the state that the proxy keeps for a Session, in bytes per part
+// of the state. It covers the state that grows while a player is online, rather than the fixed size of a Session.
+type Footprint struct {
+	// World is held by the entities, player list entries and scoreboard objectives that the client was sent.
+	World int `json:"world"`
+	// KnownChunks is held by the positions of the chunks that the client has loaded.
+	KnownChunks int `json:"known_chunks"`
+	// Chunks is held by the chunks that the proxy holds back while re-chunking them.
+	Chunks int `json:"chunks"`
+	// Queue is held by the packets queued for the client.
+	Queue int `json:"queue"`
+	// BlockUpdates is held by the block updates held back to be coalesced.
+	BlockUpdates int `json:"block_updates"`
+}
+
+// Total returns the total amount of bytes of the Footprint.
+func (f Footprint) Total() int {
+	return f.World + f.KnownChunks + f.Chunks + f.Queue + f.BlockUpdates
+}
+
+const (
+	// entryBytes is the estimated size in bytes of a single entry of a map or slice held by a Session, excluding the
+	// data it refers to.
+	entryBytes = 48
+	// packetBytes is the estimated size in bytes of a packet of which the size isn't known otherwise.
+	packetBytes = 128
+	// leakGrace is the duration after which a Session closed that was not garbage collected is considered leaked.
+	// The runtime collects garbage at least every two minutes, so a Session that is still reachable after this
+	// duration is being held on to.
+	leakGrace = time.Minute * 5
+)
+
+// Footprint returns an estimate of the memory held by the state of the Session.
+func (s *Session) Footprint() Footprint {
+	var f Footprint
+	s.world.mu.Lock()
+	f.World = (len(s.world.entities) + len(s.world.players)) * entryBytes
+	for name := range s.world.objectives {
+		f.World += entryBytes + len(name)
+	}
+	s.world.mu.Unlock()
+
+	s.known.mu.Lock()
+	f.KnownChunks = len(s.known.chunks) * entryBytes
+	s.known.mu.Unlock()
+
+	if t := s.chunkTranslator(); t != nil {
+		t.mu.Lock()
+		for _, c := range t.pending {
+			f.Chunks += entryBytes + len(c.pk.RawPayload)
+			for _, sub := range c.subs {
+				f.Chunks += len(sub)
+			}
+		}
+		for _, c := range t.split {
+			f.Chunks += entryBytes
+			for _, sub := range c.subs {
+				f.Chunks += len(sub)
+			}
+		}
+		t.mu.Unlock()
+	}
+	if q := s.queue; q != nil {
+		q.mu.Lock()
+		for _, level := range q.levels {
+			for _, p := range level {
+				f.Queue += packetSize(p.pk)
+			}
+		}
+		q.mu.Unlock()
+	}
+	if u := s.updates; u != nil {
+		u.mu.Lock()
+		f.BlockUpdates = len(u.pending) * entryBytes
+		u.mu.Unlock()
+	}
+	return f
+}
+
+// packetSize returns an estimate of the size in bytes of the packet passed.
+func packetSize(pk packet.Packet) int {
+	switch pk := pk.(type) {
+	case *packet.LevelChunk:
+		return packetBytes + len(pk.RawPayload)
+	case *packet.SubChunk:
+		n := packetBytes
+		for _, e := range pk.SubChunkEntries {
+			n += entryBytes + len(e.RawPayload)
+		}
+		return n
+	}
+	return packetBytes
+}
+
+// sessionMemory holds the state used to watch the memory of a single Session.
+type sessionMemory struct {
+	// sentinel is only referenced by the Session, so that it is garbage collected together with it. Finalizers
+	// can't be set on the Session itself, as it is part of reference cycles, which are not collected if they hold
+	// a finalizer.
+	sentinel *memorySentinel
+	// over specifies if the Footprint of the Session exceeded the Limit when it was last checked. It is only
+	// accessed by the watch goroutine.
+	over bool
+}
+
+// memorySentinel is the object of which the finalizer reports that a Session was garbage collected.
+type memorySentinel struct {
+	id uint64
+}
+
+// closedSession is a Session that was closed, but not yet garbage collected.
+type closedSession struct {
+	name     string
+	xuid     string
+	closed   time.Time
+	reported bool
+}
+
+var (
+	// watchMu guards watch and watching.
+	watchMu sync.Mutex
+	// watch is the MemoryWatch set using SetMemoryWatch.
+	watch MemoryWatch
+	// watching specifies if the goroutine watching sessions was started.
+	watching bool
+
+	// sentinelID is the ID of the last memorySentinel created.
+	sentinelID uint64
+	// closedMu guards closedSessions.
+	closedMu sync.Mutex
+	// closedSessions holds the sessions that were closed but not yet garbage collected, keyed by the ID of their
+	// sentinel.
+	closedSessions = map[uint64]*closedSession{}
+)
+
+// SetMemoryWatch sets the MemoryWatch that sessions are watched with. A warning is logged for every Session of which
+// the Footprint exceeds the Limit, and for every Session that is still reachable long after it was closed, which
+// points to a leak. Both are counted in the sessions_over_memory_limit and sessions_leaked metrics.
+func SetMemoryWatch(w MemoryWatch) {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	watch = w
+	if w.Interval > 0 && !watching {
+		watching = true
+		go watchMemory()
+	}
+}
+
+// newSessionMemory returns the sessionMemory of a new Session.
+func newSessionMemory() sessionMemory {
+	sentinel := &memorySentinel{id: atomic.AddUint64(&sentinelID, 1)}
+	runtime.SetFinalizer(sentinel, func(sentinel *memorySentinel) {
+		closedMu.Lock()
+		delete(closedSessions, sentinel.id)
+		closedMu.Unlock()
+	})
+	return sessionMemory{sentinel: sentinel}
+}
+
+// closedMemory registers that the Session passed was closed, so that it is reported if it is not garbage collected.
+func closedMemory(s *Session) {
+	closedMu.Lock()
+	defer closedMu.Unlock()
+	closedSessions[s.memory.sentinel.id] = &closedSession{name: s.Name(), xuid: s.XUID(), closed: time.Now()}
+}
+
+// watchMemory checks the footprints of all sessions and the sessions closed every interval of the current
+// MemoryWatch.
+func watchMemory() {
+	for {
+		watchMu.Lock()
+		w := watch
+		watchMu.Unlock()
+		if w.Interval <= 0 {
+			// The watch was disabled, so sessions are checked again once a second in case it is enabled again.
+			w.Interval = time.Second
+		} else {
+			checkFootprints(w)
+			checkLeaks(time.Now())
+		}
+		time.Sleep(w.Interval)
+	}
+}
+
+// checkFootprints logs a warning for every Session of which the Footprint exceeds the Limit of the MemoryWatch
+// passed, once until it drops below the Limit again.
+func checkFootprints(w MemoryWatch) {
+	if w.Limit <= 0 {
+		return
+	}
+	for _, s := range Sessions() {
+		f := s.Footprint()
+		over := f.Total() > w.Limit
+		if over && !s.memory.over {
+			metrics.Add("sessions_over_memory_limit", 1)
+			s.Logger().Warn("session exceeds memory limit", "bytes", f.Total(), "limit", w.Limit, "world", f.World, "known_chunks", f.KnownChunks, "chunks", f.Chunks, "queue", f.Queue, "block_updates", f.BlockUpdates)
+		}
+		s.memory.over = over
+	}
+}
+
+// checkLeaks logs a warning for every Session closed more than leakGrace before the time passed that was not yet
+// garbage collected, and returns the amount of them.
+func checkLeaks(now time.Time) int {
+	closedMu.Lock()
+	defer closedMu.Unlock()
+	leaked := 0
+	for _, c := range closedSessions {
+		if now.Sub(c.closed) < leakGrace {
+			continue
+		}
+		leaked++
+		if !c.reported {
+			c.reported = true
+			metrics.Add("sessions_leaked", 1)
+			logging.Default().Warn("closed session is still reachable, its state may be leaking", "name", c.name, "xuid", c.xuid, "closed", c.closed.Format(time.RFC3339))
+		}
+	}
+	return leaked
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestFootprint(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	defer s.close()
+	empty := s.Footprint().Total()
+	s.world.spawn(1)
+	s.known.observe(&packet.LevelChunk{Position: protocol.ChunkPos{1, 2}})
+	f := s.Footprint()
+	if f.World == 0 || f.KnownChunks == 0 || f.Total() <= empty {
+		t.Errorf("expected the footprint to grow with the state of the session, got %+v", f)
+	}
+}
+
+func TestLeakDetection(t *testing.T) {
+	// leaked is kept reachable after it is closed, unlike the other Session.
+	conn := &recordConn{}
+	leaked := NewSession(conn, conn, Backend{})
+	leaked.close()
+	var collected uint64
+	func() {
+		s := NewSession(conn, conn, Backend{})
+		collected = s.memory.sentinel.id
+		s.close()
+	}()
+	pending := func(id uint64) bool {
+		closedMu.Lock()
+		defer closedMu.Unlock()
+		_, ok := closedSessions[id]
+		return ok
+	}
+
+	deadline := time.Now().Add(time.Second * 5)
+	for pending(collected) && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(time.Millisecond * 10)
+	}
+	if pending(collected) {
+		t.Error("expected the session no longer reachable to be garbage collected")
+	}
+	if !pending(leaked.memory.sentinel.id) || checkLeaks(time.Now().Add(leakGrace*2)) == 0 {
+		t.Error("expected the session still reachable to be reported")
+	}
+	runtime.KeepAlive(leaked)
+}
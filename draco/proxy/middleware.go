@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Middleware is a stage of the packet pipeline of sessions. Every packet read from one end of a Session passes
+// through the chain of all middleware enabled, in order, before it is written to the other end, unless one of them
+// drops it. The ends of the pipeline are fixed: packets are translated to the latest protocol while they are read,
+// and to the protocol of the client while they are written, and the entity IDs of packets sent by the server are
+// swapped before they enter the chain, while those of packets sent by the client are swapped after it.
+//
+// The middleware of the proxy run in the order below by default, followed by middleware registered using
+// RegisterMiddleware in the order they were registered:
+//
+//	rate_limit  drops packets of clients that exceed the limit set using SetPacketRateLimit
+//	anti_crash  applies the decode policy to packets of clients that couldn't be translated
+//	log         logs packets of sessions that log their packets using Session.LogPackets
+//	capture     writes packets to the capture set using SetCapture
+//	boss_bars   drops updates of boss bars that were never shown to the client
+//	filters     applies the filters set using SetFilters
+//	handlers    passes packets to the handlers registered using Handle and HandleAll
+type Middleware struct {
+	// Name is the name of the middleware, by which it is ordered and disabled using SetMiddleware.
+	Name string
+	// Handle handles a packet travelling in the Direction passed. The packet may be modified, in which case the
+	// modified packet is passed on. No further middleware is called for the packet once Handle returns Drop.
+	Handle func(s *Session, d Direction, pk packet.Packet) Action
+}
+
+// The names of the middleware of the proxy.
+const (
+	MiddlewareRateLimit = "rate_limit"
+	MiddlewareAntiCrash = "anti_crash"
+	MiddlewareLog       = "log"
+	MiddlewareCapture   = "capture"
+	MiddlewareBossBars  = "boss_bars"
+	MiddlewareFilters   = "filters"
+	MiddlewareHandlers  = "handlers"
+)
+
+var (
+	// middlewareMu guards the fields below.
+	middlewareMu sync.RWMutex
+	// middleware holds all middleware, that of the proxy first, in the order they were registered in.
+	middleware []Middleware
+	// middlewareOrder and middlewareDisabled are the order and the disabled middleware set using SetMiddleware.
+	middlewareOrder    []string
+	middlewareDisabled map[string]struct{}
+	// chain is the middleware enabled, in the order they run in.
+	chain []Middleware
+)
+
+// RegisterMiddleware registers a Middleware that runs after the middleware of the proxy, unless it is ordered
+// otherwise using SetMiddleware. Registering a Middleware with the name of an existing one replaces it, keeping its
+// position in the chain.
+func RegisterMiddleware(m Middleware) {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+	for i, existing := range middleware {
+		if existing.Name == m.Name {
+			middleware[i] = m
+			chain = buildChain()
+			return
+		}
+	}
+	middleware = append(middleware, m)
+	chain = buildChain()
+}
+
+// SetMiddleware sets the order of the middleware chain and the middleware that are disabled, by their names. The
+// middleware in order run first, in the order passed, followed by those not in it, in their default order. An error
+// is returned if any name passed is not that of a registered Middleware, in which case the chain is left unchanged.
+func SetMiddleware(order, disabled []string) error {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+	known := make(map[string]struct{}, len(middleware))
+	for _, m := range middleware {
+		known[m.Name] = struct{}{}
+	}
+	seen := make(map[string]struct{}, len(order))
+	for _, name := range order {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("unknown middleware %q", name)
+		}
+		if _, ok := seen[name]; ok {
+			return fmt.Errorf("middleware %q ordered more than once", name)
+		}
+		seen[name] = struct{}{}
+	}
+	off := make(map[string]struct{}, len(disabled))
+	for _, name := range disabled {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("unknown middleware %q", name)
+		}
+		off[name] = struct{}{}
+	}
+	middlewareOrder, middlewareDisabled = append([]string(nil), order...), off
+	chain = buildChain()
+	return nil
+}
+
+// MiddlewareChain returns the names of the middleware enabled, in the order they run in.
+func MiddlewareChain() []string {
+	middlewareMu.RLock()
+	defer middlewareMu.RUnlock()
+	names := make([]string, 0, len(chain))
+	for _, m := range chain {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+// buildChain builds the middleware chain from the middleware registered and the order and disabled middleware set.
+// middlewareMu must be held when calling buildChain.
+func buildChain() []Middleware {
+	byName := make(map[string]Middleware, len(middleware))
+	for _, m := range middleware {
+		byName[m.Name] = m
+	}
+	c := make([]Middleware, 0, len(middleware))
+	ordered := make(map[string]struct{}, len(middlewareOrder))
+	for _, name := range middlewareOrder {
+		if m, ok := byName[name]; ok {
+			c = append(c, m)
+			ordered[name] = struct{}{}
+		}
+	}
+	for _, m := range middleware {
+		if _, ok := ordered[m.Name]; !ok {
+			c = append(c, m)
+		}
+	}
+	enabled := c[:0]
+	for _, m := range c {
+		if _, ok := middlewareDisabled[m.Name]; !ok {
+			enabled = append(enabled, m)
+		}
+	}
+	return enabled
+}
+
+// runMiddleware passes a packet travelling in the Direction passed through the middleware chain and returns the
+// resulting Action. Packets of clients that couldn't be translated are always dropped at the end of the chain, even
+// if anti_crash is disabled, as they can't be written to the server.
+func (s *Session) runMiddleware(d Direction, pk packet.Packet) Action {
+	middlewareMu.RLock()
+	c := chain
+	middlewareMu.RUnlock()
+	for _, m := range c {
+		if m.Handle(s, d, pk) == Drop {
+			return Drop
+		}
+	}
+	if _, ok := pk.(*draco.MalformedPacket); ok {
+		return Drop
+	}
+	return Forward
+}
+
+func init() {
+	RegisterMiddleware(Middleware{Name: MiddlewareRateLimit, Handle: func(s *Session, d Direction, pk packet.Packet) Action {
+		if d == ClientToServer && s.rateLimited() {
+			return Drop
+		}
+		return Forward
+	}})
+	RegisterMiddleware(Middleware{Name: MiddlewareAntiCrash, Handle: func(s *Session, d Direction, pk packet.Packet) Action {
+		if m, ok := pk.(*draco.MalformedPacket); ok {
+			if s.malformed(d, m.Err) {
+				s.close()
+			}
+			return Drop
+		}
+		return Forward
+	}})
+	RegisterMiddleware(Middleware{Name: MiddlewareLog, Handle: func(s *Session, d Direction, pk packet.Packet) Action {
+		s.logPacket(d, pk)
+		return Forward
+	}})
+	RegisterMiddleware(Middleware{Name: MiddlewareCapture, Handle: func(s *Session, d Direction, pk packet.Packet) Action {
+		s.capturePacket(d, pk)
+		return Forward
+	}})
+	RegisterMiddleware(Middleware{Name: MiddlewareBossBars, Handle: func(s *Session, d Direction, pk packet.Packet) Action {
+		if d == ServerToClient && !s.bossBars.track(pk) {
+			return Drop
+		}
+		return Forward
+	}})
+	RegisterMiddleware(Middleware{Name: MiddlewareFilters, Handle: func(s *Session, d Direction, pk packet.Packet) Action {
+		return s.filter(d, pk)
+	}})
+	RegisterMiddleware(Middleware{Name: MiddlewareHandlers, Handle: handle})
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	defer func() {
+		// Middleware can't be unregistered, so the test middleware is disabled for the other tests.
+		_ = SetMiddleware(nil, []string{"test"})
+	}()
+	var calls []string
+	RegisterMiddleware(Middleware{Name: "test", Handle: func(s *Session, d Direction, pk packet.Packet) Action {
+		calls = append(calls, "test")
+		return Drop
+	}})
+	defaults := []string{MiddlewareRateLimit, MiddlewareAntiCrash, MiddlewareLog, MiddlewareCapture, MiddlewareBossBars, MiddlewareFilters, MiddlewareHandlers, "test"}
+	if chain := MiddlewareChain(); !reflect.DeepEqual(chain, defaults) {
+		t.Fatalf("expected default chain %v, got %v", defaults, chain)
+	}
+
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	defer s.close()
+	Handle(ServerToClient, func(s *Session, pk *packet.SetTitle) Action {
+		calls = append(calls, "handler")
+		return Forward
+	})
+	if s.runMiddleware(ServerToClient, &packet.SetTitle{}) != Drop || !reflect.DeepEqual(calls, []string{"handler", "test"}) {
+		t.Errorf("expected the handler to run before the test middleware dropped the packet, got %v", calls)
+	}
+
+	if err := SetMiddleware([]string{"test", MiddlewareRateLimit}, []string{MiddlewareCapture}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"test", MiddlewareRateLimit, MiddlewareAntiCrash, MiddlewareLog, MiddlewareBossBars, MiddlewareFilters, MiddlewareHandlers}
+	if chain := MiddlewareChain(); !reflect.DeepEqual(chain, expected) {
+		t.Errorf("expected chain %v, got %v", expected, chain)
+	}
+	calls = nil
+	if s.runMiddleware(ServerToClient, &packet.SetTitle{}) != Drop || !reflect.DeepEqual(calls, []string{"test"}) {
+		t.Errorf("expected only the test middleware to run, got %v", calls)
+	}
+	if err := SetMiddleware([]string{"unknown"}, nil); err == nil {
+		t.Error("expected an error ordering unknown middleware")
+	}
+	if chain := MiddlewareChain(); !reflect.DeepEqual(chain, expected) {
+		t.Errorf("expected a failed SetMiddleware to leave the chain unchanged, got %v", chain)
+	}
+
+	// Packets that couldn't be translated are never forwarded, even without anti_crash.
+	if err := SetMiddleware(nil, []string{"test", MiddlewareAntiCrash}); err != nil {
+		t.Fatal(err)
+	}
+	if s.runMiddleware(ClientToServer, &draco.MalformedPacket{Packet: &packet.Text{}, Err: errors.New("test")}) != Drop {
+		t.Error("expected a malformed packet to be dropped")
+	}
+}
+
+func TestPacketRateLimit(t *testing.T) {
+	SetPacketRateLimit(3)
+	defer SetPacketRateLimit(0)
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	defer s.close()
+	var forwarded int
+	for i := 0; i < 5; i++ {
+		if s.runMiddleware(ClientToServer, &packet.Text{}) == Forward {
+			forwarded++
+		}
+	}
+	if forwarded != 3 {
+		t.Errorf("expected 3 packets to be forwarded, got %v", forwarded)
+	}
+	if s.runMiddleware(ServerToClient, &packet.Text{}) != Forward {
+		t.Error("expected packets of the server not to be rate limited")
+	}
+}
@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// migrateTimeout is the duration that the client of a Session migrated using Migrate has to leave the proxy, after
+// which its connection is closed.
+const migrateTimeout = time.Second * 10
+
+// Migrate moves the client of the Session to the proxy at the address passed, such as another node of a cluster, by
+// sending it a transfer packet, and closes the Session. The client joins the address as if the player had connected
+// to it directly, so the new proxy has to be told the backend the player was on beforehand for it to be restored.
+// Migrate fails if the address is not a valid host and port, or if the client was already disconnected.
+func (s *Session) Migrate(address string) error {
+	host, p, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(p, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q: %w", p, err)
+	}
+	s.disconnectMu.Lock()
+	if s.disconnected {
+		s.disconnectMu.Unlock()
+		return fmt.Errorf("client already disconnected")
+	}
+	// The client leaves by itself once it receives the transfer, so it must not be sent a disconnect packet when the
+	// Session is closed.
+	s.disconnected, s.reason = true, s.Translate("disconnect.migrated")
+	if s.cause == "" {
+		s.cause = CauseMigrated
+	}
+	s.disconnectMu.Unlock()
+
+	err = s.client.WritePacket(&packet.Transfer{Address: host, Port: uint16(port)})
+	s.close()
+	time.AfterFunc(migrateTimeout, func() {
+		_ = s.client.Close()
+	})
+	return err
+}
@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestMigrate(t *testing.T) {
+	conn := &identityConn{}
+	s := NewSession(conn, conn, Backend{})
+	if err := s.Migrate("play2.example.com"); err == nil {
+		t.Error("migrating to an address without port succeeded")
+	}
+	if err := s.Migrate("play2.example.com:19133"); err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.packets) != 1 {
+		t.Fatalf("got %v packets written, expected 1", len(conn.packets))
+	}
+	if pk, ok := conn.packets[0].(*packet.Transfer); !ok || pk.Address != "play2.example.com" || pk.Port != 19133 {
+		t.Errorf("got %#v written, expected a transfer to play2.example.com:19133", conn.packets[0])
+	}
+	// The client must leave by itself following the transfer, rather than being disconnected.
+	if conn.disconnected {
+		t.Error("client was disconnected")
+	}
+	select {
+	case <-s.closed:
+	default:
+		t.Error("session was not closed")
+	}
+	if err := s.Migrate("play2.example.com:19133"); err == nil {
+		t.Error("migrating a session twice succeeded")
+	}
+}
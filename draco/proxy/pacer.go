@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// ChunkPacing configures the rate at which chunks are sent to clients. Sending chunks faster than a client can
+// receive them, for example when it joins an area with many chunks, fills up the queue of its connection until
+// it times out. Sessions therefore send chunks at a rate that starts at InitialRate, and which is lowered when the
+// latency of the client rises, and raised again while the client keeps up.
+type ChunkPacing struct {
+	// InitialRate is the rate in bytes per second that chunks are sent at when a client joins. If 0, it is the
+	// same as MaxRate.
+	InitialRate int
+	// MinRate and MaxRate are the lowest and highest rates in bytes per second that chunks may be sent at. If
+	// MaxRate is 0, chunks are not paced at all. If MinRate is 0, it is a sixteenth of MaxRate.
+	MinRate, MaxRate int
+}
+
+const (
+	// pacingInterval is the interval at which the rate of a chunkPacer is adjusted.
+	pacingInterval = time.Second
+	// congestionSlack is the latency that a client may have on top of twice its lowest latency before it is
+	// considered to be congested.
+	congestionSlack = 50 * time.Millisecond
+)
+
+var (
+	// pacingMu guards pacing.
+	pacingMu sync.Mutex
+	// pacing is the ChunkPacing used for new sessions.
+	pacing ChunkPacing
+)
+
+// SetChunkPacing sets the ChunkPacing used for sessions started after the call.
+func SetChunkPacing(p ChunkPacing) {
+	if p.InitialRate == 0 || p.InitialRate > p.MaxRate {
+		p.InitialRate = p.MaxRate
+	}
+	if p.MinRate == 0 {
+		p.MinRate = p.MaxRate / 16
+	}
+	if p.MinRate > p.InitialRate {
+		p.MinRate = p.InitialRate
+	}
+	pacingMu.Lock()
+	defer pacingMu.Unlock()
+	pacing = p
+}
+
+// chunkPacer is a token bucket that paces the chunks sent to a single client. The bucket holds up to a second of
+// chunk data at the current rate. Every pacingInterval, the rate is halved if the latency of the client rose far
+// above the lowest latency measured, or increased if the rate limited the chunks sent during the interval.
+type chunkPacer struct {
+	conf    ChunkPacing
+	latency func() time.Duration
+
+	rate, tokens     float64
+	last, lastAdjust time.Time
+	minLatency       time.Duration
+	limited          bool
+}
+
+// newChunkPacer returns a chunkPacer for a client with the latency function passed. If chunks should not be paced
+// according to the current ChunkPacing, nil is returned.
+func newChunkPacer(latency func() time.Duration) *chunkPacer {
+	pacingMu.Lock()
+	conf := pacing
+	pacingMu.Unlock()
+	if conf.MaxRate <= 0 {
+		return nil
+	}
+	now := time.Now()
+	return &chunkPacer{
+		conf:       conf,
+		latency:    latency,
+		rate:       float64(conf.InitialRate),
+		tokens:     float64(conf.InitialRate),
+		last:       now,
+		lastAdjust: now,
+	}
+}
+
+// pace blocks until the packet passed may be sent, if it holds chunk data. Packets other than chunks are never
+// delayed by pace itself, but they are queued behind chunks when pace blocks, so that the order of packets is kept,
+// unless PacketPriorities are set, in which case queued packets of a higher priority are written first.
+func (p *chunkPacer) pace(pk packet.Packet) {
+	if p == nil {
+		return
+	}
+	n := chunkSize(pk)
+	if n == 0 {
+		return
+	}
+	now := time.Now()
+	p.adjust(now)
+
+	p.tokens = math.Min(p.rate, p.tokens+now.Sub(p.last).Seconds()*p.rate)
+	p.last = now
+	p.tokens -= float64(n)
+	if p.tokens < 0 {
+		// Wait until the debt has been refilled. The time slept is added to the bucket the next time pace is
+		// called.
+		p.limited = true
+		time.Sleep(time.Duration(-p.tokens / p.rate * float64(time.Second)))
+	}
+}
+
+// adjust adjusts the rate of the chunkPacer if pacingInterval has passed since it was last adjusted.
+func (p *chunkPacer) adjust(now time.Time) {
+	if now.Sub(p.lastAdjust) < pacingInterval {
+		return
+	}
+	latency := p.latency()
+	if latency > 0 && (p.minLatency == 0 || latency < p.minLatency) {
+		p.minLatency = latency
+	}
+	switch {
+	case p.minLatency > 0 && latency > p.minLatency*2+congestionSlack:
+		// The latency rose far above what the client is capable of, meaning packets are being queued somewhere.
+		p.rate = math.Max(float64(p.conf.MinRate), p.rate/2)
+	case p.limited:
+		p.rate = math.Min(float64(p.conf.MaxRate), p.rate*1.25)
+	}
+	p.limited, p.lastAdjust = false, now
+}
+
+// chunkSize returns the size in bytes of the chunk data held by the packet passed, or 0 if it does not hold any.
+func chunkSize(pk packet.Packet) (n int) {
+	switch pk := pk.(type) {
+	case *packet.LevelChunk:
+		return len(pk.RawPayload)
+	case *packet.SubChunk:
+		for _, e := range pk.SubChunkEntries {
+			n += len(e.RawPayload)
+		}
+	}
+	return n
+}
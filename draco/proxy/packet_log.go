@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// packetLog holds the packet logging state of a single Session, enabled using Session.LogPackets.
+type packetLog struct {
+	mu sync.Mutex
+	// until is the time at which logging stops. Packets are not logged if it is zero or has passed.
+	until time.Time
+	// ids holds the IDs of the packets that are logged. If empty, all packets are logged.
+	ids map[uint32]struct{}
+}
+
+// LogPackets logs the names of all packets sent and received by the Session for the duration passed, so that the
+// issues of a single player may be debugged without logging the packets of all players. If any packet IDs are
+// passed, only packets with those IDs are logged. Logging is stopped if the duration is 0 or less, and calling
+// LogPackets again replaces the previous duration and packet IDs.
+func (s *Session) LogPackets(d time.Duration, ids ...uint32) {
+	s.packetLog.mu.Lock()
+	defer s.packetLog.mu.Unlock()
+	if d <= 0 {
+		s.packetLog.until, s.packetLog.ids = time.Time{}, nil
+		return
+	}
+	s.packetLog.until, s.packetLog.ids = time.Now().Add(d), make(map[uint32]struct{}, len(ids))
+	for _, id := range ids {
+		s.packetLog.ids[id] = struct{}{}
+	}
+}
+
+// LoggingPackets returns the time until which packets of the Session are logged, as enabled using LogPackets. False
+// is returned if packets of the Session are not currently logged.
+func (s *Session) LoggingPackets() (time.Time, bool) {
+	s.packetLog.mu.Lock()
+	defer s.packetLog.mu.Unlock()
+	if s.packetLog.until.IsZero() || time.Now().After(s.packetLog.until) {
+		return time.Time{}, false
+	}
+	return s.packetLog.until, true
+}
+
+// logPacket logs the packet passed, travelling in the Direction passed, if packets of the Session are logged.
+func (s *Session) logPacket(d Direction, pk packet.Packet) {
+	if !s.packetLog.logs(pk.ID()) {
+		return
+	}
+	s.Logger().Info("packet", "direction", d, "packet", fmt.Sprintf("%T", pk), "id", pk.ID())
+}
+
+// logs checks if packets with the ID passed are currently logged. An expired duration is reset, so that later calls
+// return early.
+func (l *packetLog) logs(id uint32) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.until.IsZero() {
+		return false
+	}
+	if time.Now().After(l.until) {
+		l.until, l.ids = time.Time{}, nil
+		return false
+	}
+	if len(l.ids) == 0 {
+		return true
+	}
+	_, ok := l.ids[id]
+	return ok
+}
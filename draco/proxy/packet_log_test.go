@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestLogPackets(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	if s.packetLog.logs(packet.IDText) {
+		t.Fatalf("packets logged before logging was enabled")
+	}
+
+	s.LogPackets(time.Minute, packet.IDText)
+	if !s.packetLog.logs(packet.IDText) {
+		t.Errorf("packet with a filtered ID not logged")
+	}
+	if s.packetLog.logs(packet.IDMovePlayer) {
+		t.Errorf("packet without a filtered ID logged")
+	}
+	if _, ok := s.LoggingPackets(); !ok {
+		t.Errorf("session not reported to be logging packets")
+	}
+
+	s.LogPackets(time.Minute)
+	if !s.packetLog.logs(packet.IDMovePlayer) {
+		t.Errorf("packet not logged without a filter")
+	}
+
+	s.packetLog.until = time.Now().Add(-time.Second)
+	if s.packetLog.logs(packet.IDText) {
+		t.Errorf("packet logged after the duration passed")
+	}
+	if _, ok := s.LoggingPackets(); ok {
+		t.Errorf("session reported to be logging packets after the duration passed")
+	}
+
+	s.LogPackets(time.Minute)
+	s.LogPackets(0)
+	if s.packetLog.logs(packet.IDText) {
+		t.Errorf("packet logged after logging was stopped")
+	}
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Permissions overrides the permissions that the backend grants players of a Role, as shown to the client. Only
+// what the client shows is changed, such as the operator gamemode switcher, while the backend still enforces its
+// own permissions.
+type Permissions struct {
+	// PermissionLevel is the permission level shown to the client: "visitor", "member", "operator" or "custom".
+	// If empty, the permission level granted by the backend is kept.
+	PermissionLevel string
+	// CommandPermissionLevel is the command permission level shown to the client: "normal", "game_directors",
+	// "admin", "host", "owner" or "internal". If empty, the command permission level granted by the backend is
+	// kept.
+	CommandPermissionLevel string
+}
+
+// permissionLevels and commandPermissionLevels hold the values of the names of permission levels and command
+// permission levels used in Permissions.
+var (
+	permissionLevels = map[string]uint32{
+		"visitor":  packet.PermissionLevelVisitor,
+		"member":   packet.PermissionLevelMember,
+		"operator": packet.PermissionLevelOperator,
+		"custom":   packet.PermissionLevelCustom,
+	}
+	commandPermissionLevels = map[string]uint32{
+		"normal":         packet.CommandPermissionLevelNormal,
+		"game_directors": packet.CommandPermissionLevelGameDirectors,
+		"admin":          packet.CommandPermissionLevelAdmin,
+		"host":           packet.CommandPermissionLevelHost,
+		"owner":          packet.CommandPermissionLevelOwner,
+		"internal":       packet.CommandPermissionLevelInternal,
+	}
+)
+
+// permissionOverride is a parsed Permissions.
+type permissionOverride struct {
+	level, commandLevel       uint32
+	setLevel, setCommandLevel bool
+}
+
+var (
+	// permissionMu guards permissions.
+	permissionMu sync.RWMutex
+	// permissions holds the permissionOverride set for every Role using SetPermissions.
+	permissions = map[Role]permissionOverride{}
+)
+
+// SetPermissions sets the Permissions shown to clients of players with the Role passed. An error is returned if
+// any of the permission levels is unknown.
+func SetPermissions(r Role, p Permissions) error {
+	var o permissionOverride
+	if p.PermissionLevel != "" {
+		level, ok := permissionLevels[strings.ToLower(p.PermissionLevel)]
+		if !ok {
+			return fmt.Errorf("unknown permission level %v", p.PermissionLevel)
+		}
+		o.level, o.setLevel = level, true
+	}
+	if p.CommandPermissionLevel != "" {
+		level, ok := commandPermissionLevels[strings.ToLower(p.CommandPermissionLevel)]
+		if !ok {
+			return fmt.Errorf("unknown command permission level %v", p.CommandPermissionLevel)
+		}
+		o.commandLevel, o.setCommandLevel = level, true
+	}
+	permissionMu.Lock()
+	defer permissionMu.Unlock()
+	permissions[r] = o
+	return nil
+}
+
+func init() {
+	// The StartGame packet sent to clients always holds the visitor permission level, so the permissions of a
+	// client are set by the AdventureSettings packets sent by the backend afterwards.
+	Handle(ServerToClient, func(s *Session, pk *packet.AdventureSettings) Action {
+		overridePermissions(s.Role(), pk)
+		return Forward
+	})
+}
+
+// overridePermissions applies the Permissions set for the Role passed to the AdventureSettings passed.
+func overridePermissions(r Role, pk *packet.AdventureSettings) {
+	permissionMu.RLock()
+	o, ok := permissions[r]
+	permissionMu.RUnlock()
+	if !ok {
+		return
+	}
+	if o.setLevel {
+		pk.PermissionLevel = o.level
+	}
+	if o.setCommandLevel {
+		pk.CommandPermissionLevel = o.commandLevel
+	}
+}
@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// PingReport reports the round trip times of both legs of the connection of a Session, so that it can be told if lag
+// is caused by the connection of the player to the proxy or by the connection of the proxy to the backend.
+type PingReport struct {
+	// Client holds the round trip time between the client and the proxy, and Backend the round trip time between
+	// the proxy and the backend. The latter is reset when the Session is attached to another server.
+	Client  LegPing `json:"client"`
+	Backend LegPing `json:"backend"`
+}
+
+// LegPing reports the round trip time of one leg of the connection of a Session. The round trip time is measured in
+// two ways: by RakNet, which measures the time until datagrams are acknowledged, and by sending NetworkStackLatency
+// packets, which measures the time until the game answers them and so includes the time until the next tick of the
+// other end.
+type LegPing struct {
+	// RakNet is the round trip time as measured by RakNet.
+	RakNet time.Duration `json:"raknet"`
+	// Last is the round trip time of the last NetworkStackLatency packet answered and Average the moving average of
+	// those. Both are 0 if no packet was answered yet, which is the case for backends that don't answer them.
+	Last    time.Duration `json:"last"`
+	Average time.Duration `json:"average"`
+	// Samples is the amount of NetworkStackLatency packets answered.
+	Samples int64 `json:"samples"`
+}
+
+const (
+	// pingInterval is the interval at which NetworkStackLatency packets are sent over both legs of a Session.
+	pingInterval = 5 * time.Second
+	// pingDecay is the weight of a new round trip time in the moving average of a leg.
+	pingDecay = 0.2
+)
+
+// Ping returns a PingReport of the round trip times of the connection of the Session.
+func (s *Session) Ping() PingReport {
+	r := PingReport{Client: s.pings.client.report(), Backend: s.pings.backend.report()}
+	r.Client.RakNet = s.client.Latency()
+	if server := s.Server(); server != nil {
+		r.Backend.RakNet = server.Latency()
+	}
+	return r
+}
+
+// sessionPings measures the round trip times of both legs of a Session.
+type sessionPings struct {
+	client, backend legPing
+}
+
+// legPing measures the round trip time of one leg of a Session using NetworkStackLatency packets.
+type legPing struct {
+	mu sync.Mutex
+	// pending holds the times at which the NetworkStackLatency packets not yet answered were sent, keyed by their
+	// timestamp.
+	pending       map[int64]time.Time
+	last, average time.Duration
+	samples       int64
+}
+
+func init() {
+	Handle(ClientToServer, func(s *Session, pk *packet.NetworkStackLatency) Action {
+		if s.pings.client.answered(pk, "client") {
+			return Drop
+		}
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.NetworkStackLatency) Action {
+		if s.pings.backend.answered(pk, "backend") {
+			return Drop
+		}
+		return Forward
+	})
+}
+
+// run sends a NetworkStackLatency packet over both legs of the Session passed every pingInterval until the Session
+// is closed.
+func (p *sessionPings) run(s *Session) {
+	t := time.NewTicker(pingInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-s.closed:
+			return
+		}
+		now := time.Now()
+		_ = s.client.WritePacket(&packet.NetworkStackLatency{Timestamp: p.client.send(now), NeedsResponse: true})
+		if server := s.Server(); server != nil {
+			_ = server.WritePacket(&packet.NetworkStackLatency{Timestamp: p.backend.send(now), NeedsResponse: true})
+		}
+	}
+}
+
+// send registers a NetworkStackLatency packet sent at the time passed and returns its timestamp. Packets that were
+// not answered within a few intervals are forgotten, as the other end doesn't answer them.
+func (p *legPing) send(now time.Time) int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending == nil {
+		p.pending = map[int64]time.Time{}
+	}
+	for ts, sent := range p.pending {
+		if now.Sub(sent) > pingInterval*3 {
+			delete(p.pending, ts)
+		}
+	}
+	// Timestamps are sent in milliseconds, as clients multiply them by 1000 in their answer, which would overflow
+	// a timestamp in nanoseconds.
+	ts := now.UnixMilli()
+	p.pending[ts] = now
+	return ts
+}
+
+// answered checks if the NetworkStackLatency packet passed, received over the leg with the name passed, answers a
+// packet sent using send. If so, its round trip time is recorded and true is returned, in which case the packet must
+// not be forwarded. Packets that the other end of the Session sent itself are left alone.
+func (p *legPing) answered(pk *packet.NetworkStackLatency, leg string) bool {
+	if pk.NeedsResponse {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Some implementations multiply the timestamp by 1000 in their answer.
+	for _, ts := range []int64{pk.Timestamp, pk.Timestamp / 1000} {
+		sent, ok := p.pending[ts]
+		if !ok {
+			continue
+		}
+		delete(p.pending, ts)
+		rtt := time.Since(sent)
+		p.last = rtt
+		if p.samples == 0 {
+			p.average = rtt
+		} else {
+			p.average += time.Duration(float64(rtt-p.average) * pingDecay)
+		}
+		p.samples++
+		metrics.AddTo("ping_samples", leg, 1)
+		metrics.AddTo("ping_rtt_ms", leg, rtt.Milliseconds())
+		return true
+	}
+	return false
+}
+
+// report returns the LegPing of the round trip times measured so far, without the RakNet round trip time.
+func (p *legPing) report() LegPing {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return LegPing{Last: p.last, Average: p.average, Samples: p.samples}
+}
+
+// reset forgets the round trip times measured, such as when the Session is attached to another server.
+func (p *legPing) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending, p.last, p.average, p.samples = nil, 0, 0, 0
+}
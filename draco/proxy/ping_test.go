@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestLegPing(t *testing.T) {
+	var p legPing
+	now := time.Now()
+	first := p.send(now.Add(-40 * time.Millisecond))
+	if p.answered(&packet.NetworkStackLatency{Timestamp: first + 1}, "client") {
+		t.Fatal("answer to a packet never sent was consumed")
+	}
+	if p.answered(&packet.NetworkStackLatency{Timestamp: first, NeedsResponse: true}, "client") {
+		t.Fatal("request of the other end was consumed")
+	}
+	if !p.answered(&packet.NetworkStackLatency{Timestamp: first}, "client") {
+		t.Fatal("answer was not recognised")
+	}
+	if r := p.report(); r.Samples != 1 || r.Last < 40*time.Millisecond || r.Average != r.Last {
+		t.Fatalf("unexpected report after the first answer: %+v", r)
+	}
+	if p.answered(&packet.NetworkStackLatency{Timestamp: first}, "client") {
+		t.Fatal("packet was answered twice")
+	}
+
+	// Clients multiply the timestamp by 1000 in their answer.
+	second := p.send(now)
+	if !p.answered(&packet.NetworkStackLatency{Timestamp: second * 1000}, "client") {
+		t.Fatal("answer with a timestamp multiplied by 1000 was not recognised")
+	}
+	r := p.report()
+	if r.Samples != 2 || r.Last >= 40*time.Millisecond || r.Average <= r.Last {
+		t.Fatalf("unexpected report after the second answer: %+v", r)
+	}
+
+	p.reset()
+	if r := p.report(); r.Samples != 0 || r.Average != 0 {
+		t.Fatalf("report not reset: %+v", r)
+	}
+}
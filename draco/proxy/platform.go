@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"strings"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+)
+
+// deviceNames holds the names of the device operating systems that clients report in their client data.
+var deviceNames = map[protocol.DeviceOS]string{
+	protocol.DeviceAndroid:   "android",
+	protocol.DeviceIOS:       "ios",
+	protocol.DeviceOSX:       "osx",
+	protocol.DeviceFireOS:    "fireos",
+	protocol.DeviceGearVR:    "gearvr",
+	protocol.DeviceHololens:  "hololens",
+	protocol.DeviceWin10:     "win10",
+	protocol.DeviceWin32:     "win32",
+	protocol.DeviceDedicated: "dedicated",
+	protocol.DeviceTVOS:      "tvos",
+	protocol.DeviceOrbis:     "playstation",
+	protocol.DeviceNX:        "switch",
+	protocol.DeviceXBOX:      "xbox",
+	protocol.DeviceWP:        "windows_phone",
+}
+
+// inputModeNames holds the names of the input modes that clients report in their client data.
+var inputModeNames = map[int]string{
+	1: "mouse",
+	2: "touch",
+	3: "gamepad",
+	4: "motion_controller",
+}
+
+func init() {
+	OnStart(func(s *Session) { countPlatform(s, 1) })
+	OnClose(func(s *Session) { countPlatform(s, -1) })
+}
+
+// countPlatform adds delta to the platform statistics of the client of the Session passed. The statistics are
+// anonymous counters of the operating systems, input modes and game versions of players, served by the metrics
+// handler in the "clients_online" group, holding the players currently online, and the "client_joins" group, holding
+// all players that joined. They are heuristic: clients report them themselves, and modified clients may report
+// whatever they like.
+func countPlatform(s *Session, delta int64) {
+	for _, key := range platformKeys(s.client.ClientData()) {
+		metrics.AddTo("clients_online", key, delta)
+		if delta > 0 {
+			metrics.AddTo("client_joins", key, delta)
+		}
+	}
+}
+
+// platformKeys returns the keys of the platform statistics counting the client data passed, such as "os.android",
+// "input.touch" and "version.1.18.10". Values that are not known are counted as "unknown", so that clients can't
+// create an unbounded amount of counters.
+func platformKeys(d login.ClientData) []string {
+	os, ok := deviceNames[d.DeviceOS]
+	if !ok {
+		os = "unknown"
+	}
+	input, ok := inputModeNames[d.CurrentInputMode]
+	if !ok {
+		input = "unknown"
+	}
+	version := d.GameVersion
+	if !validGameVersion(version) {
+		version = "unknown"
+	}
+	return []string{"os." + os, "input." + input, "version." + version}
+}
+
+// validGameVersion checks if the game version passed looks like a real version, such as "1.18.10": at most four
+// numbers separated by dots.
+func validGameVersion(v string) bool {
+	parts := strings.Split(v, ".")
+	if len(parts) < 2 || len(parts) > 4 {
+		return false
+	}
+	for _, p := range parts {
+		if len(p) == 0 || len(p) > 4 || strings.Trim(p, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
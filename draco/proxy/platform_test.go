@@ -0,0 +1,26 @@
+package proxy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+)
+
+func TestPlatformKeys(t *testing.T) {
+	tests := []struct {
+		data login.ClientData
+		keys []string
+	}{
+		{login.ClientData{DeviceOS: protocol.DeviceAndroid, CurrentInputMode: 2, GameVersion: "1.18.10"}, []string{"os.android", "input.touch", "version.1.18.10"}},
+		{login.ClientData{DeviceOS: protocol.DeviceWin10, CurrentInputMode: 1, GameVersion: "1.18.31.04"}, []string{"os.win10", "input.mouse", "version.1.18.31.04"}},
+		{login.ClientData{DeviceOS: 99, CurrentInputMode: 0, GameVersion: "1.18.10-hacked"}, []string{"os.unknown", "input.unknown", "version.unknown"}},
+		{login.ClientData{GameVersion: "1..10"}, []string{"os.unknown", "input.unknown", "version.unknown"}},
+	}
+	for _, test := range tests {
+		if keys := platformKeys(test.data); !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("keys of %+v: got %v, expected %v", test.data, keys, test.keys)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Position is the last known position of the player of a Session.
+type Position struct {
+	// Position is the position of the player, as last sent by the client, in the Dimension that the server last
+	// moved the player to: 0 for the overworld, 1 for the nether and 2 for the end.
+	Position  mgl32.Vec3 `json:"position"`
+	Dimension int32      `json:"dimension"`
+	// Backend is the name of the Backend that the player is on.
+	Backend string `json:"backend"`
+	// Updated is the time at which the client last sent its position. It is zero if the player didn't move yet, in
+	// which case Position is the position that the player spawned at.
+	Updated time.Time `json:"updated"`
+}
+
+// Position returns the last known Position of the player of the Session, which is also available once the Session
+// was closed, such as to tell where a player was when it disconnected.
+func (s *Session) Position() Position {
+	p := Position{Backend: s.Backend().Name}
+	s.world.mu.Lock()
+	defer s.world.mu.Unlock()
+	p.Position, p.Dimension, p.Updated = s.world.position, s.world.dimension, s.world.moved
+	return p
+}
@@ -0,0 +1,22 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestPosition(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{Name: "survival"})
+	if p := s.Position(); !p.Updated.IsZero() {
+		t.Errorf("position updated before the player moved: %+v", p)
+	}
+	handle(s, ServerToClient, &packet.ChangeDimension{Dimension: 1})
+	handle(s, ClientToServer, &packet.PlayerAuthInput{Position: mgl32.Vec3{10, 70, -5}})
+	p := s.Position()
+	if p.Position != (mgl32.Vec3{10, 70, -5}) || p.Dimension != 1 || p.Backend != "survival" || p.Updated.IsZero() {
+		t.Errorf("unexpected position %+v", p)
+	}
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// PotatoMode configures the low bandwidth profile that players may enable for themselves using /potato, which is
+// useful for players on metered connections. While enabled, the view distance of the player is reduced, sounds and
+// particles are thinned out and purely cosmetic packets are not sent at all.
+type PotatoMode struct {
+	// ChunkRadius is the maximum chunk radius of players with potato mode enabled. If 0, it is 4.
+	ChunkRadius int32
+	// KeepOneIn specifies that only one in every KeepOneIn sounds and particles is sent to players with potato
+	// mode enabled. If 0, it is 4.
+	KeepOneIn int
+}
+
+var (
+	// potatoMu guards potatoConf.
+	potatoMu sync.RWMutex
+	// potatoConf is the PotatoMode currently used.
+	potatoConf = PotatoMode{ChunkRadius: 4, KeepOneIn: 4}
+)
+
+// SetPotatoMode sets the PotatoMode used for all sessions.
+func SetPotatoMode(p PotatoMode) {
+	if p.ChunkRadius == 0 {
+		p.ChunkRadius = 4
+	}
+	if p.KeepOneIn == 0 {
+		p.KeepOneIn = 4
+	}
+	potatoMu.Lock()
+	defer potatoMu.Unlock()
+	potatoConf = p
+}
+
+// potatoMode returns the PotatoMode currently used.
+func potatoMode() PotatoMode {
+	potatoMu.RLock()
+	defer potatoMu.RUnlock()
+	return potatoConf
+}
+
+// potato holds the potato mode state of a single Session.
+type potato struct {
+	mu      sync.Mutex
+	enabled bool
+	// radius is the chunk radius last requested by the client.
+	radius int32
+	// count is the amount of sounds and particles that were considered for sending since potato mode was enabled.
+	count int
+}
+
+func init() {
+	RegisterCommand(Command{
+		Name:        "potato",
+		Description: "Toggles the low bandwidth mode",
+		Run: func(s *Session, args []string) {
+			enabled := !s.Potato()
+			s.SetPotato(enabled)
+			key := "potato.disabled"
+			if enabled {
+				key = "potato.enabled"
+			}
+			_ = s.client.WritePacket(&packet.Text{TextType: packet.TextTypeRaw, Message: s.Translate(key)})
+		},
+	})
+
+	Handle(ClientToServer, func(s *Session, pk *packet.RequestChunkRadius) Action {
+		s.potato.mu.Lock()
+		defer s.potato.mu.Unlock()
+		s.potato.radius = pk.ChunkRadius
+		pk.ChunkRadius = s.limitChunkRadius(pk.ChunkRadius, s.potato.enabled)
+		return Forward
+	})
+
+	strip := func(s *Session) Action {
+		if s.Potato() {
+			return Drop
+		}
+		return Forward
+	}
+	Handle(ServerToClient, func(s *Session, pk *packet.SpawnParticleEffect) Action { return strip(s) })
+	Handle(ServerToClient, func(s *Session, pk *packet.AnimateEntity) Action { return strip(s) })
+	Handle(ServerToClient, func(s *Session, pk *packet.Emote) Action { return strip(s) })
+
+	Handle(ServerToClient, func(s *Session, pk *packet.LevelSoundEvent) Action { return s.potato.thin() })
+	Handle(ServerToClient, func(s *Session, pk *packet.PlaySound) Action { return s.potato.thin() })
+	Handle(ServerToClient, func(s *Session, pk *packet.LevelEvent) Action {
+		if (pk.EventType >= packet.LevelEventParticlesShoot && pk.EventType < 3000) || pk.EventType&packet.LevelEventParticleLegacyEvent != 0 {
+			return s.potato.thin()
+		}
+		return Forward
+	})
+}
+
+// SetPotato enables or disables potato mode for the Session. The chunk radius of the client is requested from the
+// backend again, so that it is reduced or restored right away.
+func (s *Session) SetPotato(enabled bool) {
+	s.potato.mu.Lock()
+	if s.potato.enabled == enabled {
+		s.potato.mu.Unlock()
+		return
+	}
+	s.potato.enabled, s.potato.count = enabled, 0
+	radius := s.potato.radius
+	s.potato.mu.Unlock()
+
+	if radius = s.limitChunkRadius(radius, enabled); radius != 0 {
+		_ = s.Server().WritePacket(&packet.RequestChunkRadius{ChunkRadius: radius})
+	}
+}
+
+// Potato checks if potato mode is enabled for the Session.
+func (s *Session) Potato() bool {
+	s.potato.mu.Lock()
+	defer s.potato.mu.Unlock()
+	return s.potato.enabled
+}
+
+// thin drops all but one in every PotatoMode.KeepOneIn packets passed to it if potato mode is enabled.
+func (p *potato) thin() Action {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.enabled {
+		return Forward
+	}
+	keep := p.count%potatoMode().KeepOneIn == 0
+	p.count++
+	if keep {
+		return Forward
+	}
+	return Drop
+}
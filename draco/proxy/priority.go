@@ -0,0 +1,222 @@
+package proxy
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// PacketClass is a class of the packets that backends send to clients, which share a priority in PacketPriorities.
+type PacketClass string
+
+// The classes that packets sent to clients are divided in.
+const (
+	// ClassMovement holds the packets that move the player and other entities.
+	ClassMovement PacketClass = "movement"
+	// ClassBlockUpdates holds chunks and the packets that change blocks in them.
+	ClassBlockUpdates PacketClass = "block_updates"
+	// ClassChat holds chat messages.
+	ClassChat PacketClass = "chat"
+	// ClassCosmetics holds sounds, particles and animations.
+	ClassCosmetics PacketClass = "cosmetics"
+	// ClassOther holds all other packets.
+	ClassOther PacketClass = "other"
+)
+
+// PacketPriorities holds the priority of every PacketClass. Packets that the backend sends to a client are queued
+// while the client can't keep up, such as while its chunks are paced according to the ChunkPacing, and queued
+// packets are written in order of the priority of their class, from high to low. Packets of classes with the same
+// priority are written in the order they were sent. Classes missing from PacketPriorities have the priority of
+// ClassOther.
+type PacketPriorities map[PacketClass]int
+
+// DefaultPacketPriorities are the PacketPriorities that favour the packets needed to play over others: movement is
+// written first, followed by block updates and other packets, chat and cosmetics. Chunks and block updates have the
+// priority of other packets, so that the packets of a world are written in the order the backend sent them.
+var DefaultPacketPriorities = PacketPriorities{
+	ClassMovement:     3,
+	ClassBlockUpdates: 2,
+	ClassOther:        2,
+	ClassChat:         1,
+	ClassCosmetics:    0,
+}
+
+// maxQueuedPackets is the maximum amount of packets queued for a client. Once reached, packets of the backend are
+// no longer read until the client catches up.
+const maxQueuedPackets = 4096
+
+var (
+	// priorityMu guards priorities.
+	priorityMu sync.Mutex
+	// priorities is the PacketPriorities set using SetPacketPriorities.
+	priorities PacketPriorities
+)
+
+// SetPacketPriorities sets the PacketPriorities used for sessions started after the call. If nil, packets are
+// written to clients in the order they were sent, as by default. An error is returned if a class is unknown.
+func SetPacketPriorities(p PacketPriorities) error {
+	for class := range p {
+		switch class {
+		case ClassMovement, ClassBlockUpdates, ClassChat, ClassCosmetics, ClassOther:
+		default:
+			return fmt.Errorf("unknown packet class %q", class)
+		}
+	}
+	priorityMu.Lock()
+	defer priorityMu.Unlock()
+	priorities = p
+	return nil
+}
+
+// classOf returns the PacketClass of a packet sent to a client.
+func classOf(pk packet.Packet) PacketClass {
+	switch pk.(type) {
+	case *packet.MovePlayer, *packet.MoveActorAbsolute, *packet.MoveActorDelta, *packet.SetActorMotion,
+		*packet.CorrectPlayerMovePrediction, *packet.MotionPredictionHints:
+		return ClassMovement
+	case *packet.LevelChunk, *packet.SubChunk, *packet.NetworkChunkPublisherUpdate, *packet.UpdateBlock,
+		*packet.UpdateSubChunkBlocks, *packet.UpdateBlockSynced, *packet.BlockActorData:
+		return ClassBlockUpdates
+	case *packet.Text:
+		return ClassChat
+	case *packet.LevelSoundEvent, *packet.PlaySound, *packet.StopSound, *packet.LevelEvent, *packet.LevelEventGeneric,
+		*packet.SpawnParticleEffect, *packet.Animate, *packet.AnimateEntity, *packet.ActorEvent:
+		return ClassCosmetics
+	}
+	return ClassOther
+}
+
+// writeQueue queues the packets written to a client by priority. Packets are pushed by the goroutines forwarding
+// the packets of the server and popped by a single goroutine writing them to the client.
+type writeQueue struct {
+	// rank holds the index in levels of the packets of every PacketClass, where lower indices have a higher
+	// priority.
+	rank map[PacketClass]int
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	levels [][]queuedPacket
+	n      int
+	closed bool
+}
+
+// queuedPacket is a packet queued in a writeQueue together with the time at which the proxy received it.
+type queuedPacket struct {
+	pk    packet.Packet
+	start time.Time
+}
+
+// newWriteQueue returns a writeQueue for the current PacketPriorities, or nil if packets should be written in the
+// order they were sent.
+func newWriteQueue() *writeQueue {
+	priorityMu.Lock()
+	p := priorities
+	priorityMu.Unlock()
+	if p == nil {
+		return nil
+	}
+	classes := []PacketClass{ClassMovement, ClassBlockUpdates, ClassChat, ClassCosmetics, ClassOther}
+	of := func(class PacketClass) int {
+		if v, ok := p[class]; ok {
+			return v
+		}
+		return p[ClassOther]
+	}
+	values := make([]int, 0, len(classes))
+	for _, class := range classes {
+		values = append(values, of(class))
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(values)))
+
+	q := &writeQueue{rank: map[PacketClass]int{}}
+	q.cond = sync.NewCond(&q.mu)
+	for _, class := range classes {
+		q.rank[class] = sort.Search(len(values), func(i int) bool { return values[i] <= of(class) })
+	}
+	q.levels = make([][]queuedPacket, len(values))
+	return q
+}
+
+// push queues the packet passed, received at the time passed, blocking while the queue is full. False is returned if
+// the queue was closed.
+func (q *writeQueue) push(pk packet.Packet, start time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.n >= maxQueuedPackets && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return false
+	}
+	r := q.rank[classOf(pk)]
+	q.levels[r] = append(q.levels[r], queuedPacket{pk: pk, start: start})
+	q.n++
+	q.cond.Broadcast()
+	return true
+}
+
+// write queues the packet passed like push. It is used to write packets that the proxy holds back itself.
+func (q *writeQueue) write(pk packet.Packet) error {
+	if !q.push(pk, time.Now()) {
+		return errSessionClosed
+	}
+	return nil
+}
+
+// pop returns the queued packet of the highest priority, blocking until a packet is queued. False is returned if the
+// queue was closed.
+func (q *writeQueue) pop() (queuedPacket, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.n == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return queuedPacket{}, false
+	}
+	for i, level := range q.levels {
+		if len(level) == 0 {
+			continue
+		}
+		p := level[0]
+		level[0] = queuedPacket{}
+		q.levels[i] = level[1:]
+		q.n--
+		q.cond.Broadcast()
+		return p, true
+	}
+	panic("unreachable")
+}
+
+// close closes the writeQueue, discarding all packets queued and making pending calls to push and pop return.
+func (q *writeQueue) close() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// writeQueued writes the packets queued for the client in order of priority until the Session is closed or a packet
+// can't be written.
+func (s *Session) writeQueued() {
+	for {
+		p, ok := s.queue.pop()
+		if !ok {
+			return
+		}
+		s.pacer.pace(p.pk)
+		write := time.Now()
+		err := s.writeClient(p.pk)
+		s.latency[ServerToClient].record(p.pk, time.Since(p.start), time.Since(write))
+		if err != nil {
+			s.queue.close()
+			return
+		}
+	}
+}
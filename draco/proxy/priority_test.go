@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestWriteQueue(t *testing.T) {
+	defer SetPacketPriorities(nil)
+	if err := SetPacketPriorities(PacketPriorities{"emotes": 1}); err == nil {
+		t.Error("priorities with an unknown class were set")
+	}
+	if err := SetPacketPriorities(DefaultPacketPriorities); err != nil {
+		t.Fatal(err)
+	}
+	q := newWriteQueue()
+	for _, pk := range []packet.Packet{
+		&packet.LevelSoundEvent{},
+		&packet.LevelChunk{},
+		&packet.Text{},
+		&packet.AddActor{},
+		&packet.MovePlayer{},
+		&packet.UpdateBlock{},
+	} {
+		q.push(pk, time.Now())
+	}
+	var order []uint32
+	for i := 0; i < 6; i++ {
+		p, ok := q.pop()
+		if !ok {
+			t.Fatal("queue closed")
+		}
+		order = append(order, p.pk.ID())
+	}
+	// Chunks, block updates and other packets share a priority, so they keep the order they were pushed in.
+	expected := []uint32{packet.IDMovePlayer, packet.IDLevelChunk, packet.IDAddActor, packet.IDUpdateBlock, packet.IDText, packet.IDLevelSoundEvent}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("packets popped in order %v, expected %v", order, expected)
+	}
+
+	q.close()
+	if _, ok := q.pop(); ok {
+		t.Error("packet popped from closed queue")
+	}
+	if q.push(&packet.Text{}, time.Now()) {
+		t.Error("packet pushed to closed queue")
+	}
+
+	if err := SetPacketPriorities(nil); err != nil {
+		t.Fatal(err)
+	}
+	if newWriteQueue() != nil {
+		t.Error("write queue created without priorities")
+	}
+}
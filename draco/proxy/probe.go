@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// BackendProbe configures the probes that sessions send to their backend to detect that it stopped responding
+// sooner than the underlying RakNet connection would time out.
+type BackendProbe struct {
+	// Interval is the interval at which NetworkStackLatency probes are sent to the backend. If 0, no probes are
+	// sent.
+	Interval time.Duration
+	// Timeout is the duration after which a backend that sent no packets at all is considered dead, after which
+	// the connection to it is closed. If 0, it is five times Interval.
+	Timeout time.Duration
+}
+
+var (
+	// probeMu guards probeConf.
+	probeMu sync.Mutex
+	// probeConf is the BackendProbe used for new sessions.
+	probeConf BackendProbe
+)
+
+// SetBackendProbe sets the BackendProbe used for sessions started after the call.
+func SetBackendProbe(p BackendProbe) {
+	if p.Timeout == 0 {
+		p.Timeout = p.Interval * 5
+	}
+	probeMu.Lock()
+	defer probeMu.Unlock()
+	probeConf = p
+}
+
+// errBackendTimeout is observed for backends that stopped responding to the probes of a Session.
+var errBackendTimeout = errors.New("backend stopped responding")
+
+// backendProbe probes the backend of a single Session. Backends are not required to answer the probes, so the
+// connection is only considered dead if the backend answered a probe before: any packet received from the
+// backend counts as a sign of life, and the probes merely make sure that an idle backend sends packets.
+type backendProbe struct {
+	conf BackendProbe
+
+	mu       sync.Mutex
+	pending  map[int64]time.Time
+	answered bool
+	lastSeen time.Time
+	latency  time.Duration
+}
+
+// newBackendProbe returns the backendProbe of a new Session, or nil if backends are not probed.
+func newBackendProbe() *backendProbe {
+	probeMu.Lock()
+	conf := probeConf
+	probeMu.Unlock()
+	if conf.Interval == 0 {
+		return nil
+	}
+	return &backendProbe{conf: conf, pending: map[int64]time.Time{}, lastSeen: time.Now()}
+}
+
+// BackendLatency returns the round trip time of the last probe answered by the backend of the Session. If backends
+// are not probed, it is the average round trip time of the NetworkStackLatency packets answered by the backend (see
+// Session.Ping). It is 0 if the backend answered neither.
+func (s *Session) BackendLatency() time.Duration {
+	if s.probe == nil {
+		return s.pings.backend.report().Average
+	}
+	s.probe.mu.Lock()
+	defer s.probe.mu.Unlock()
+	return s.probe.latency
+}
+
+// seen registers that the packet passed was received from the backend. It returns true if the packet is the
+// answer to a probe, in which case it must not be forwarded to the client.
+func (p *backendProbe) seen(pk packet.Packet) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastSeen = time.Now()
+
+	latency, ok := pk.(*packet.NetworkStackLatency)
+	if !ok || latency.NeedsResponse {
+		return false
+	}
+	// Some implementations multiply the timestamp by 1000 in their answer.
+	for _, ts := range []int64{latency.Timestamp, latency.Timestamp / 1000} {
+		if sent, ok := p.pending[ts]; ok {
+			delete(p.pending, ts)
+			p.answered, p.latency = true, time.Since(sent)
+			return true
+		}
+	}
+	return false
+}
+
+// run sends a probe to the backend of the Session passed every interval until the Session is closed, closing the
+// connection to the backend if it stopped responding.
+func (p *backendProbe) run(s *Session) {
+	t := time.NewTicker(p.conf.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-s.closed:
+			return
+		}
+		now := time.Now()
+		p.mu.Lock()
+		dead := p.answered && now.Sub(p.lastSeen) > p.conf.Timeout
+		for ts, sent := range p.pending {
+			if now.Sub(sent) > p.conf.Timeout {
+				delete(p.pending, ts)
+			}
+		}
+		ts := now.UnixNano()
+		p.pending[ts] = now
+		p.mu.Unlock()
+
+		if dead {
+			metrics.Add("backend_timeouts", 1)
+			ObserveBackend(s.Backend(), 0, errBackendTimeout)
+			s.Logger().Warn("backend stopped responding, closing the connection")
+			_ = s.Server().Close()
+			// The Session may fall back to another server, which is probed from then on.
+			p.reset()
+			continue
+		}
+		_ = s.Server().WritePacket(&packet.NetworkStackLatency{Timestamp: ts, NeedsResponse: true})
+	}
+}
+
+func init() {
+	RegisterCommand(Command{
+		Name:        "proxyping",
+		Description: "Shows your ping to the proxy and the ping of the proxy to the server",
+		Run: func(s *Session, args []string) {
+			r := s.Ping()
+			client := r.Client.RakNet
+			if r.Client.Samples > 0 {
+				client = r.Client.Average
+			}
+			if backend := s.BackendLatency(); backend > 0 {
+				s.message("ping.backend", client.Milliseconds(), s.Backend().Name, backend.Milliseconds())
+				return
+			}
+			s.message("ping.proxy", client.Milliseconds())
+		},
+	})
+}
@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	"reflect"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/cqdetdev/draco/draco/sockopt"
+	"github.com/sandertv/go-raknet"
+)
+
+// LinkStats reports the quality of the connection between the proxy and one end of a Session, so that it can be told
+// if problems of a player are caused by the proxy or by their network. go-raknet doesn't expose packet loss, so it is
+// estimated from the datagrams that the proxy had to send again because the other end didn't acknowledge them in
+// time or reported them missing. Datagrams lost on their way to the proxy are not included.
+type LinkStats struct {
+	// RakNet specifies if the connection is a RakNet connection of which the counters below could be read. If false,
+	// only RTT and Jitter are set.
+	RakNet bool `json:"raknet"`
+	// RTT is the current round trip time of the connection, and Jitter the mean difference between two consecutive
+	// round trip times measured over the last minute.
+	RTT    time.Duration `json:"rtt"`
+	Jitter time.Duration `json:"jitter"`
+	// Sent is the amount of datagrams that the proxy sent since the connection was opened, of which Resent were
+	// datagrams sent again.
+	Sent   uint64 `json:"sent"`
+	Resent uint64 `json:"resent"`
+	// InFlight is the amount of datagrams sent that the other end has not yet acknowledged.
+	InFlight int `json:"in_flight"`
+	// Loss is the share of the datagrams sent over the last minute that had to be sent again, from 0 to 1.
+	Loss float64 `json:"loss"`
+}
+
+// ConnectionQuality reports the quality of both connections of a Session.
+type ConnectionQuality struct {
+	// Client holds the LinkStats of the connection to the client and Server those of the connection to the
+	// backend. The latter are reset when the Session is attached to another server.
+	Client LinkStats `json:"client"`
+	Server LinkStats `json:"server"`
+}
+
+const (
+	// qualityInterval is the interval at which the connections of a Session are sampled.
+	qualityInterval = time.Second
+	// qualityWindow is the amount of samples that Jitter and Loss are computed over.
+	qualityWindow = 60
+)
+
+// ConnectionQuality returns the quality of the connections of the Session, as sampled over the last minute.
+func (s *Session) ConnectionQuality() ConnectionQuality {
+	s.quality.mu.Lock()
+	defer s.quality.mu.Unlock()
+	return ConnectionQuality{Client: s.quality.client.stats, Server: s.quality.server.stats}
+}
+
+// connectionQuality samples the quality of both connections of a Session.
+type connectionQuality struct {
+	mu             sync.Mutex
+	client, server linkMonitor
+}
+
+// run samples the connections of the Session passed every qualityInterval until the Session is closed. The view
+// distance of the player is adapted to the quality of its connection after every sample.
+func (q *connectionQuality) run(s *Session) {
+	t := time.NewTicker(qualityInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-s.closed:
+			return
+		}
+		q.mu.Lock()
+		q.client.sample(s.client, "client")
+		q.server.sample(s.Server(), "server")
+		client := q.client.stats
+		q.mu.Unlock()
+		s.adapt(client)
+	}
+}
+
+// linkSample is a single sample of a connection taken by a linkMonitor.
+type linkSample struct {
+	rtt          time.Duration
+	sent, resent uint32
+}
+
+// linkMonitor samples a single connection, keeping the samples of the last qualityWindow intervals.
+type linkMonitor struct {
+	conn Conn
+	rak  *raknet.Conn
+	// seq and messageIndex are the last values read of the counters of the same name of the RakNet connection.
+	seq, messageIndex uint32
+
+	samples [qualityWindow]linkSample
+	n, next int
+	stats   LinkStats
+}
+
+// sample samples the connection passed and updates the LinkStats of the linkMonitor. If the connection differs from
+// the one sampled before, the linkMonitor is reset first. The datagrams counted are added to the metrics of the
+// side passed.
+func (m *linkMonitor) sample(conn Conn, side string) {
+	if conn == nil {
+		return
+	}
+	if !sameConn(conn, m.conn) {
+		*m = linkMonitor{conn: conn, rak: sockopt.Find[raknet.Conn](conn)}
+		if m.rak != nil {
+			m.seq, m.messageIndex, _, m.stats.RakNet = raknetCounters(m.rak)
+		}
+		m.stats.RTT = conn.Latency()
+		return
+	}
+	sample := linkSample{rtt: conn.Latency()}
+	if m.rak != nil {
+		if seq, messageIndex, inFlight, ok := raknetCounters(m.rak); ok {
+			// Every datagram sent increases the sequence number, but only new datagrams increase the message index.
+			// Both are 24-bit integers that wrap around.
+			sample.sent = (seq - m.seq) & 0xffffff
+			sample.resent = sample.sent - (messageIndex-m.messageIndex)&0xffffff
+			m.seq, m.messageIndex = seq, messageIndex
+			m.stats.InFlight = inFlight
+		}
+	}
+	m.samples[m.next] = sample
+	m.next = (m.next + 1) % qualityWindow
+	if m.n < qualityWindow {
+		m.n++
+	}
+
+	m.stats.RTT = sample.rtt
+	m.stats.Sent += uint64(sample.sent)
+	m.stats.Resent += uint64(sample.resent)
+	metrics.AddTo("raknet_datagrams_sent", side, int64(sample.sent))
+	metrics.AddTo("raknet_datagrams_resent", side, int64(sample.resent))
+
+	var jitter time.Duration
+	var sent, resent uint32
+	for i := 0; i < m.n; i++ {
+		smp := m.samples[(m.next-m.n+i+qualityWindow)%qualityWindow]
+		sent, resent = sent+smp.sent, resent+smp.resent
+		if i > 0 {
+			d := smp.rtt - m.samples[(m.next-m.n+i-1+qualityWindow)%qualityWindow].rtt
+			if d < 0 {
+				d = -d
+			}
+			jitter += d
+		}
+	}
+	m.stats.Jitter, m.stats.Loss = 0, 0
+	if m.n > 1 {
+		m.stats.Jitter = jitter / time.Duration(m.n-1)
+	}
+	if sent > 0 {
+		m.stats.Loss = float64(resent) / float64(sent)
+	}
+}
+
+// sameConn checks if the connections passed are the same. Connections of types that can't be compared are never the
+// same.
+func sameConn(a, b Conn) bool {
+	if a == nil || b == nil || reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// raknetFields specifies if raknet.Conn has the unexported fields read by raknetCounters, so that a change of them
+// in another version of go-raknet disables the counters rather than crashing the proxy.
+var raknetFields = func() bool {
+	t := reflect.TypeOf((*raknet.Conn)(nil)).Elem()
+	for name, kind := range map[string]reflect.Kind{"seq": reflect.Uint32, "messageIndex": reflect.Uint32, "retransmission": reflect.Ptr} {
+		if f, ok := t.FieldByName(name); !ok || f.Type.Kind() != kind {
+			return false
+		}
+	}
+	if mu, ok := t.FieldByName("mu"); !ok || mu.Type != reflect.TypeOf((*sync.Mutex)(nil)).Elem() {
+		return false
+	}
+	r, _ := t.FieldByName("retransmission")
+	f, ok := r.Type.Elem().FieldByName("unacknowledged")
+	return ok && f.Type.Kind() == reflect.Map
+}()
+
+// raknetCounters reads the datagram sequence number, the message index and the amount of unacknowledged datagrams of
+// the RakNet connection passed. go-raknet doesn't expose them, so they are read from its unexported fields while
+// holding the mutex that guards them. False is returned if the fields could not be read.
+func raknetCounters(c *raknet.Conn) (seq, messageIndex uint32, inFlight int, ok bool) {
+	if !raknetFields {
+		return 0, 0, 0, false
+	}
+	v := reflect.ValueOf(c).Elem()
+	mu := (*sync.Mutex)(unsafe.Pointer(v.FieldByName("mu").UnsafeAddr()))
+	mu.Lock()
+	defer mu.Unlock()
+	seq, messageIndex = uint32(v.FieldByName("seq").Uint()), uint32(v.FieldByName("messageIndex").Uint())
+	if r := v.FieldByName("retransmission"); !r.IsNil() {
+		inFlight = r.Elem().FieldByName("unacknowledged").Len()
+	}
+	return seq, messageIndex, inFlight, true
+}
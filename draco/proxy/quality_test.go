@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/sandertv/go-raknet"
+)
+
+// rakConn is a Conn backed by a RakNet connection.
+type rakConn struct {
+	benchConn
+	conn *raknet.Conn
+}
+
+func TestLinkMonitorRakNet(t *testing.T) {
+	l, err := raknet.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				b := make([]byte, 1500)
+				for {
+					if _, err := c.Read(b); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	c, err := raknet.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var m linkMonitor
+	conn := &rakConn{conn: c}
+	m.sample(conn, "test")
+	if !m.stats.RakNet {
+		t.Fatal("RakNet counters not found")
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := c.Write([]byte{0xfe, byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.sample(conn, "test")
+	if m.stats.Sent < 10 {
+		t.Errorf("expected at least 10 datagrams sent, got %v", m.stats.Sent)
+	}
+	if m.stats.Resent > m.stats.Sent {
+		t.Errorf("resent %v of %v datagrams sent", m.stats.Resent, m.stats.Sent)
+	}
+}
+
+func TestLinkMonitorOther(t *testing.T) {
+	var m linkMonitor
+	conn := &recordConn{}
+	for i := 0; i < 3; i++ {
+		m.sample(conn, "test")
+	}
+	if m.stats.RakNet || m.stats.Sent != 0 || m.n != 2 {
+		t.Errorf("unexpected stats for a non-RakNet connection: %+v, %v samples", m.stats, m.n)
+	}
+	// A new connection resets the stats.
+	m.sample(&recordConn{}, "test")
+	if m.n != 0 {
+		t.Errorf("expected samples to be reset, got %v", m.n)
+	}
+}
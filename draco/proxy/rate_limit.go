@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+)
+
+var (
+	// packetRateMu guards packetRateLimit.
+	packetRateMu sync.RWMutex
+	// packetRateLimit is the limit set using SetPacketRateLimit.
+	packetRateLimit int
+)
+
+// SetPacketRateLimit sets the maximum amount of packets that a client may send per second. Packets above the limit
+// are dropped by the rate_limit Middleware, so that a client flooding the proxy with packets can't flood its
+// backend. If n is 0, there is no limit.
+func SetPacketRateLimit(n int) {
+	packetRateMu.Lock()
+	defer packetRateMu.Unlock()
+	packetRateLimit = n
+}
+
+// packetRate counts the packets sent by the client of a Session in the current second. It is only used by the
+// goroutine reading from the client.
+type packetRate struct {
+	start time.Time
+	count int
+}
+
+// rateLimited counts a packet sent by the client of the Session and reports if it exceeds the limit set using
+// SetPacketRateLimit, in which case it must be dropped.
+func (s *Session) rateLimited() bool {
+	packetRateMu.RLock()
+	limit := packetRateLimit
+	packetRateMu.RUnlock()
+	if limit <= 0 {
+		return false
+	}
+	if now := time.Now(); now.Sub(s.rate.start) >= time.Second {
+		s.rate.start, s.rate.count = now, 0
+	}
+	s.rate.count++
+	if s.rate.count <= limit {
+		return false
+	}
+	if s.rate.count == limit+1 {
+		// Only the first packet dropped in a second is logged, so that the flood doesn't end up in the logs.
+		s.Logger().Warn("client exceeded packet rate limit, dropping packets", "limit", limit)
+	}
+	metrics.Add("packets_rate_limited", 1)
+	return true
+}
@@ -0,0 +1,37 @@
+package proxy
+
+import "strings"
+
+// Role is the role of the player of a Session. It determines what the player is allowed to do on the proxy.
+type Role uint8
+
+const (
+	// RoleMember is the role of players authenticated with XBOX Live. It is the default role of a Session.
+	RoleMember Role = iota
+	// RoleGuest is the role of players that joined through a listener with authentication disabled. Their
+	// identity can't be verified, so they are not allowed to chat or run commands.
+	RoleGuest
+)
+
+// ParseRole parses a Role from its name, as returned by Role.String. False is returned if no Role has the name
+// passed.
+func ParseRole(name string) (Role, bool) {
+	switch strings.ToLower(name) {
+	case "member":
+		return RoleMember, true
+	case "guest":
+		return RoleGuest, true
+	}
+	return 0, false
+}
+
+// String ...
+func (r Role) String() string {
+	switch r {
+	case RoleMember:
+		return "member"
+	case RoleGuest:
+		return "guest"
+	}
+	return "unknown"
+}
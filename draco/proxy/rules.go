@@ -0,0 +1,302 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+)
+
+// Rule is a rule evaluated when a player joins, which routes the player to a backend, turns it away or configures its
+// session if all of its conditions match the player. Conditions left empty match every player. Rules are evaluated in
+// order, and the actions of all rules that match are applied, those of later rules overriding those of earlier ones,
+// until a rule matches that denies the player or has Stop set.
+type Rule struct {
+	// Names and XUIDs hold the gamertags, ignoring case, and the XUIDs of the players that the rule applies to.
+	Names, XUIDs []string
+	// Networks holds the IP addresses, such as "192.168.1.10", and networks, such as "10.0.0.0/8", that the players
+	// that the rule applies to join from.
+	Networks []string
+	// Locales holds the languages of the clients that the rule applies to, such as "en_US", or the part of them
+	// before the underscore, such as "en".
+	Locales []string
+	// Versions holds the game versions of the clients that the rule applies to, such as "1.19.50", or a part of them
+	// ending before a dot, such as "1.19", which matches all versions of 1.19.
+	Versions []string
+	// Hours is the time of day, in the time zone of the proxy, that the rule applies at, such as "08:00-22:00". The
+	// range may span midnight, such as "22:00-06:00".
+	Hours string
+
+	// Deny turns away the players that the rule applies to with Message, or with a default message if empty.
+	Deny    bool
+	Message string
+	// Backend is the name of the backend that the players join.
+	Backend string
+	// Role is the role that the players are assigned, "member" or "guest". Players assigned the guest role join
+	// like guests, so the backends must accept players without XBOX Live authentication. Guests keep their role, as
+	// their identity is not verified.
+	Role string
+	// ViewDistance is the maximum chunk radius of the players. If 0, it is not limited.
+	ViewDistance int32
+	// Stop stops evaluating the rules after this one if it applies.
+	Stop bool
+}
+
+// Join holds what is known of a player joining the proxy, which rules are evaluated against using EvaluateRules.
+type Join struct {
+	// Identity and Client are the identity and client data of the player. The XUID of the identity must be empty
+	// for players whose identity is not verified, such as guests.
+	Identity login.IdentityData
+	Client   login.ClientData
+	// Addr is the address that the player joined from.
+	Addr net.Addr
+	// Time is the time at which the player joined. If zero, it is the current time.
+	Time time.Time
+}
+
+// Decision is the outcome of evaluating the rules for a player joining.
+type Decision struct {
+	// Deny is true if the player must be turned away, showing it Message if not empty.
+	Deny    bool
+	Message string
+	// Backend is the name of the backend that the player joins, or empty if no rule routes it.
+	Backend string
+	// Role is the Role that the player is assigned if RoleSet is true.
+	Role    Role
+	RoleSet bool
+	// ViewDistance is the maximum chunk radius of the player, or 0 if it is not limited.
+	ViewDistance int32
+	// Matched holds the indices of the rules that applied to the player, in order.
+	Matched []int
+}
+
+// compiledRule is a Rule parsed by SetRules.
+type compiledRule struct {
+	Rule
+	networks []*net.IPNet
+	// from and to are the start and end of Hours in minutes since midnight. hours is false if the rule has no Hours.
+	from, to int
+	hours    bool
+	role     Role
+	roleSet  bool
+}
+
+var (
+	// rulesMu guards rules.
+	rulesMu sync.RWMutex
+	// rules holds the rules set using SetRules.
+	rules []compiledRule
+)
+
+// SetRules sets the rules evaluated when players join, replacing those set before. An error is returned if any of the
+// rules is invalid, in which case the rules set before are kept.
+func SetRules(r []Rule) error {
+	compiled, err := compileRules(r)
+	if err != nil {
+		return err
+	}
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules = compiled
+	return nil
+}
+
+// CheckRules checks if the rules passed are valid without setting them.
+func CheckRules(r []Rule) error {
+	_, err := compileRules(r)
+	return err
+}
+
+// compileRules parses the rules passed.
+func compileRules(r []Rule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(r))
+	for i, rule := range r {
+		c, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %v: %w", i+1, err)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// compileRule parses the Rule passed.
+func compileRule(r Rule) (compiledRule, error) {
+	c := compiledRule{Rule: r}
+	for _, n := range r.Networks {
+		if !strings.Contains(n, "/") {
+			ip := net.ParseIP(n)
+			if ip == nil {
+				return c, fmt.Errorf("invalid IP address %q", n)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			c.networks = append(c.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(n)
+		if err != nil {
+			return c, fmt.Errorf("invalid network %q", n)
+		}
+		c.networks = append(c.networks, network)
+	}
+	if r.Hours != "" {
+		from, to, ok := strings.Cut(r.Hours, "-")
+		var err error
+		if c.from, err = parseClock(from); err != nil || !ok {
+			return c, fmt.Errorf("invalid hours %q: expected a range such as 08:00-22:00", r.Hours)
+		}
+		if c.to, err = parseClock(to); err != nil {
+			return c, fmt.Errorf("invalid hours %q: expected a range such as 08:00-22:00", r.Hours)
+		}
+		c.hours = true
+	}
+	if r.Role != "" {
+		role, ok := ParseRole(r.Role)
+		if !ok {
+			return c, fmt.Errorf("unknown role %q", r.Role)
+		}
+		c.role, c.roleSet = role, true
+	}
+	if r.ViewDistance < 0 {
+		return c, fmt.Errorf("negative view distance %v", r.ViewDistance)
+	}
+	if r.Message != "" && !r.Deny {
+		return c, fmt.Errorf("message set without deny")
+	}
+	return c, nil
+}
+
+// parseClock parses a time of day such as "08:30" into the minutes since midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// EvaluateRules evaluates the rules set using SetRules for the player joining passed and returns the Decision
+// reached.
+func EvaluateRules(j Join) Decision {
+	if j.Time.IsZero() {
+		j.Time = time.Now()
+	}
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	var d Decision
+	for i, r := range rules {
+		if !r.matches(j) {
+			continue
+		}
+		d.Matched = append(d.Matched, i)
+		if r.Deny {
+			d.Deny, d.Message = true, r.Message
+			return d
+		}
+		if r.Backend != "" {
+			d.Backend = r.Backend
+		}
+		if r.roleSet {
+			d.Role, d.RoleSet = r.role, true
+		}
+		if r.ViewDistance != 0 {
+			d.ViewDistance = r.ViewDistance
+		}
+		if r.Stop {
+			break
+		}
+	}
+	return d
+}
+
+// matches checks if all conditions of the rule match the player joining passed.
+func (r compiledRule) matches(j Join) bool {
+	if len(r.Names) > 0 && !containsFold(r.Names, j.Identity.DisplayName) {
+		return false
+	}
+	if len(r.XUIDs) > 0 && (j.Identity.XUID == "" || !contains(r.XUIDs, j.Identity.XUID)) {
+		return false
+	}
+	if len(r.networks) > 0 && !r.inNetworks(j.Addr) {
+		return false
+	}
+	if len(r.Locales) > 0 && !matchesPrefix(r.Locales, j.Client.LanguageCode, "_") {
+		return false
+	}
+	if len(r.Versions) > 0 && !matchesPrefix(r.Versions, j.Client.GameVersion, ".") {
+		return false
+	}
+	if r.hours {
+		now := j.Time.Hour()*60 + j.Time.Minute()
+		if r.from <= r.to {
+			return now >= r.from && now < r.to
+		}
+		// The range spans midnight.
+		return now >= r.from || now < r.to
+	}
+	return true
+}
+
+// inNetworks checks if the IP of the address passed is in any of the networks of the rule.
+func (r compiledRule) inNetworks(addr net.Addr) bool {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.TCPAddr:
+		ip = a.IP
+	default:
+		if addr == nil {
+			return false
+		}
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return false
+		}
+		ip = net.ParseIP(host)
+	}
+	for _, n := range r.networks {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPrefix checks if v equals any of the values passed, ignoring case, or starts with any of them followed by
+// sep.
+func matchesPrefix(values []string, v, sep string) bool {
+	for _, p := range values {
+		if strings.EqualFold(v, p) || (len(v) > len(p) && strings.EqualFold(v[:len(p)], p) && strings.HasPrefix(v[len(p):], sep)) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold checks if any of the values passed equals v, ignoring case.
+func containsFold(values []string, v string) bool {
+	for _, s := range values {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains checks if any of the values passed equals v.
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+)
+
+func TestRules(t *testing.T) {
+	defer func() {
+		_ = SetRules(nil)
+	}()
+	err := SetRules([]Rule{
+		{Networks: []string{"10.0.0.0/8"}, Role: "guest"},
+		{Locales: []string{"de"}, Backend: "lobby-de"},
+		{Versions: []string{"1.18"}, ViewDistance: 6, Stop: true},
+		{Names: []string{"griefer"}, Deny: true, Message: "Go away."},
+		{Hours: "22:00-06:00", Backend: "night"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noon := time.Date(2022, 1, 1, 12, 0, 0, 0, time.Local)
+	join := func(name, locale, version, ip string, at time.Time) Join {
+		return Join{
+			Identity: login.IdentityData{DisplayName: name, XUID: "1"},
+			Client:   login.ClientData{LanguageCode: locale, GameVersion: version},
+			Addr:     &net.UDPAddr{IP: net.ParseIP(ip), Port: 19132},
+			Time:     at,
+		}
+	}
+
+	if d := EvaluateRules(join("Steve", "en_US", "1.19.50", "1.2.3.4", noon)); d.Deny || d.Backend != "" || d.RoleSet || len(d.Matched) != 0 {
+		t.Errorf("expected no rule to apply, got %+v", d)
+	}
+	if d := EvaluateRules(join("Steve", "de_DE", "1.19.50", "10.1.2.3", noon)); d.Backend != "lobby-de" || !d.RoleSet || d.Role != RoleGuest {
+		t.Errorf("expected the first two rules to be combined, got %+v", d)
+	}
+	if d := EvaluateRules(join("Griefer", "de_DE", "1.18.10", "1.2.3.4", noon)); d.Deny || d.ViewDistance != 6 || d.Backend != "lobby-de" {
+		t.Errorf("expected evaluation to stop after the third rule, got %+v", d)
+	}
+	if d := EvaluateRules(join("Griefer", "en_US", "1.19.50", "1.2.3.4", noon)); !d.Deny || d.Message != "Go away." {
+		t.Errorf("expected the player to be denied, got %+v", d)
+	}
+	if d := EvaluateRules(join("Steve", "en_US", "1.180.0", "1.2.3.4", noon.Add(11*time.Hour))); d.ViewDistance != 0 || d.Backend != "night" {
+		t.Errorf("expected only the rule spanning midnight to apply, got %+v", d)
+	}
+
+	for _, invalid := range []Rule{
+		{Networks: []string{"10.0.0.0/33"}},
+		{Hours: "22:00"},
+		{Role: "admin"},
+		{ViewDistance: -1},
+		{Message: "no deny"},
+	} {
+		if err := SetRules([]Rule{invalid}); err == nil {
+			t.Errorf("expected error setting rule %+v", invalid)
+		}
+	}
+}
+
+func TestMaxChunkRadius(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	defer s.close()
+
+	s.SetMaxChunkRadius(6)
+	if r := s.chunkRadius(12); r != 6 {
+		t.Errorf("expected the chunk radius to be limited to 6, got %v", r)
+	}
+	if r := s.chunkRadius(4); r != 4 {
+		t.Errorf("expected a chunk radius below the limit to be kept, got %v", r)
+	}
+	s.SetMaxChunkRadius(0)
+	if r := s.chunkRadius(12); r != 12 {
+		t.Errorf("expected the chunk radius not to be limited, got %v", r)
+	}
+}
@@ -0,0 +1,382 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// ScoreboardLine is a line that the proxy adds to the sidebar of a player using Session.SetScoreboardLines.
+type ScoreboardLine struct {
+	// Text is the text of the line. It may hold placeholders such as {online}, which are replaced with their value
+	// for the player every second, as in the sidebar of the proxy.
+	Text string
+	// Score is the score of the line, which determines its position among the lines of the backend.
+	Score int32
+}
+
+// linesObjective is the name of the scoreboard objective that the proxy displays in the sidebar to show the lines
+// added using Session.SetScoreboardLines while the backend displays no sidebar.
+const linesObjective = "draco:lines"
+
+// scoreboard holds the scoreboard lines and player list entries that the proxy adds to those of the backend for a
+// single Session. The lines are added to the objective that the backend displays in the sidebar, and added again
+// whenever the backend displays another one, so that they survive the scoreboard updates of the backend.
+// The scoreboard owns the sidebar display slot of the client: the sidebar of the proxy covers the slot through it, so
+// that the objective of the backend and the lines are hidden while the sidebar is visible and shown again after.
+type scoreboard struct {
+	once sync.Once
+
+	mu    sync.Mutex
+	title string
+	lines []ScoreboardLine
+	// display is the last sidebar displayed by the backend, if any. objective is the name of the objective that the
+	// lines were last sent to, which is empty if they are not currently shown.
+	display   *packet.SetDisplayObjective
+	objective string
+	// covered is true while the sidebar of the proxy is visible, which takes up the sidebar display slot.
+	covered bool
+	// shownTitle is the title of linesObjective as last displayed, and sent holds the text of each line last sent.
+	shownTitle string
+	sent       []string
+	// deferred is true if the lines must be sent again, but only once the packets of the backend that caused it
+	// were forwarded.
+	deferred bool
+	// entries holds the player list entries of the proxy, keyed by their UUID.
+	entries map[uuid.UUID]*playerListEntry
+}
+
+// playerListEntry is a player list entry added by the proxy.
+type playerListEntry struct {
+	// name is the name of the entry, which may hold placeholders, and sent the name last sent to the client.
+	name, sent string
+}
+
+func init() {
+	Handle(ServerToClient, func(s *Session, pk *packet.SetDisplayObjective) Action {
+		if pk.DisplaySlot != packet.ScoreboardSlotSidebar {
+			return Forward
+		}
+		b := &s.scoreboard
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		display := *pk
+		b.display = &display
+		if b.covered {
+			// The sidebar of the proxy is visible, so the objective is only displayed once it is hidden again.
+			return Drop
+		}
+		if b.objective == linesObjective {
+			// The objective of the backend replaces that of the proxy in the sidebar.
+			_ = s.client.WritePacket(&packet.RemoveObjective{ObjectiveName: linesObjective})
+		}
+		b.objective, b.sent, b.deferred = "", nil, len(b.lines) > 0
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.SetScore) Action {
+		b := &s.scoreboard
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		backend := b.backendObjective()
+		if pk.ActionType != packet.ScoreboardActionModify || backend == "" || b.covered || b.objective == backend || len(b.lines) == 0 {
+			return Forward
+		}
+		for _, e := range pk.Entries {
+			if e.ObjectiveName == backend {
+				// The backend fills the objective it displays, so the lines of the proxy are added to the same packet,
+				// which makes sure the objective exists by the time they arrive.
+				b.objective, b.sent, b.deferred = backend, make([]string, len(b.lines)), false
+				for i, l := range b.lines {
+					b.sent[i] = replacePlaceholders(s, l.Text)
+					pk.Entries = append(pk.Entries, lineEntry(b.objective, i, l.Score, b.sent[i]))
+				}
+				break
+			}
+		}
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.RemoveObjective) Action {
+		b := &s.scoreboard
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if pk.ObjectiveName != b.backendObjective() {
+			return Forward
+		}
+		// The lines of the proxy are removed together with the objective, so they are shown in the objective of the
+		// proxy once the objective is removed.
+		b.display = nil
+		if b.covered {
+			return Forward
+		}
+		b.objective, b.sent, b.deferred = "", nil, len(b.lines) > 0
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.PlayerList) Action {
+		b := &s.scoreboard
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if len(b.entries) == 0 {
+			return Forward
+		}
+		// Entries of the proxy are never changed by the backend, which doesn't know of them.
+		entries := pk.Entries[:0]
+		for _, e := range pk.Entries {
+			if _, ok := b.entries[e.UUID]; !ok {
+				entries = append(entries, e)
+			}
+		}
+		if len(entries) == 0 {
+			return Drop
+		}
+		pk.Entries = entries
+		return Forward
+	})
+}
+
+// SetScoreboardLines adds the lines passed to the sidebar shown to the player of the Session, replacing the lines
+// added previously. The lines are shown together with those of the sidebar that the backend displays, or in a
+// sidebar with the title passed while the backend displays none. Passing no lines removes the lines of the proxy.
+// Lines are not visible while the sidebar of the proxy is shown, which replaces that of the backend, and are shown
+// again once it is hidden.
+func (s *Session) SetScoreboardLines(title string, lines []ScoreboardLine) {
+	b := &s.scoreboard
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.title, b.lines = title, append([]ScoreboardLine(nil), lines...)
+	b.update(s)
+	b.start(s)
+}
+
+// SetPlayerListEntry adds an entry with the UUID and name passed to the player list of the player of the Session,
+// or renames the entry if it was already added. The name may hold placeholders, which are replaced every second.
+// Entries added by the proxy are kept when the Session is attached to another server and can't be removed or
+// replaced by the backend.
+func (s *Session) SetPlayerListEntry(id uuid.UUID, name string) {
+	b := &s.scoreboard
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.entries == nil {
+		b.entries = map[uuid.UUID]*playerListEntry{}
+	}
+	if e, ok := b.entries[id]; ok {
+		e.name = name
+	} else {
+		b.entries[id] = &playerListEntry{name: name}
+	}
+	b.updateEntries(s)
+	b.start(s)
+}
+
+// RemovePlayerListEntry removes the entry with the UUID passed added using SetPlayerListEntry from the player list
+// of the player of the Session.
+func (s *Session) RemovePlayerListEntry(id uuid.UUID) {
+	b := &s.scoreboard
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.entries[id]; !ok {
+		return
+	}
+	delete(b.entries, id)
+	_ = s.client.WritePacket(&packet.PlayerList{ActionType: packet.PlayerListActionRemove, Entries: []protocol.PlayerListEntry{{UUID: id}}})
+}
+
+// start starts updating the placeholders of the lines and entries of the scoreboard of the Session passed, unless
+// it was started already. b.mu must be held when calling start.
+func (b *scoreboard) start(s *Session) {
+	b.once.Do(func() {
+		go b.run(s)
+	})
+}
+
+// run updates the lines and player list entries every sidebarInterval until the Session passed is closed.
+func (b *scoreboard) run(s *Session) {
+	t := time.NewTicker(sidebarInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-s.closed:
+			return
+		}
+		b.mu.Lock()
+		if b.deferred {
+			// The packets of the backend that removed or replaced the lines may not have been forwarded yet, so the
+			// lines are sent again on the next tick.
+			b.deferred = false
+		} else {
+			b.update(s)
+		}
+		b.updateEntries(s)
+		b.mu.Unlock()
+	}
+}
+
+// backendObjective returns the name of the objective that the backend displays in the sidebar, or an empty string if
+// it displays none. b.mu must be held when calling backendObjective.
+func (b *scoreboard) backendObjective() string {
+	if b.display == nil {
+		return ""
+	}
+	return b.display.ObjectiveName
+}
+
+// cover covers the sidebar display slot of the client of the Session passed with the sidebar of the proxy, or
+// uncovers it, after which the sidebar last displayed by the backend is displayed again, although without the scores
+// the backend set while it was covered, together with the lines. The sidebar must be displayed by the caller after
+// covering the slot.
+func (b *scoreboard) cover(s *Session, covered bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.covered == covered {
+		return
+	}
+	b.covered = covered
+	if covered {
+		b.clear(s)
+		return
+	}
+	if b.display != nil {
+		_ = s.client.WritePacket(b.display)
+	}
+	b.objective, b.sent, b.deferred = "", nil, false
+	b.update(s)
+}
+
+// update sends the lines that changed since they were last sent to the client of the Session passed, displaying
+// the objective of the proxy first if the backend displays no sidebar. No lines are sent while the sidebar display
+// slot is covered by the sidebar of the proxy. b.mu must be held when calling update.
+func (b *scoreboard) update(s *Session) {
+	target := b.backendObjective()
+	if target == "" && len(b.lines) > 0 {
+		target = linesObjective
+	}
+	if b.covered {
+		target = ""
+	}
+	if target != b.objective || (target == linesObjective && b.title != b.shownTitle) {
+		b.clear(s)
+		if target == linesObjective {
+			_ = s.client.WritePacket(&packet.SetDisplayObjective{
+				DisplaySlot:   packet.ScoreboardSlotSidebar,
+				ObjectiveName: linesObjective,
+				DisplayName:   b.title,
+				CriteriaName:  "dummy",
+				SortOrder:     packet.ScoreboardSortOrderAscending,
+			})
+			b.shownTitle = b.title
+		}
+		b.objective = target
+	}
+	if b.objective == "" {
+		return
+	}
+
+	var removed, modified []protocol.ScoreboardEntry
+	for i := len(b.lines); i < len(b.sent); i++ {
+		removed = append(removed, lineEntry(b.objective, i, 0, ""))
+	}
+	sent := make([]string, len(b.lines))
+	for i, l := range b.lines {
+		sent[i] = replacePlaceholders(s, l.Text)
+		entry := lineEntry(b.objective, i, l.Score, sent[i])
+		if i < len(b.sent) {
+			if sent[i] == b.sent[i] {
+				continue
+			}
+			// The text of a line is changed by removing it and adding it again.
+			removed = append(removed, entry)
+		}
+		modified = append(modified, entry)
+	}
+	b.sent = sent
+	if len(removed) > 0 {
+		_ = s.client.WritePacket(&packet.SetScore{ActionType: packet.ScoreboardActionRemove, Entries: removed})
+	}
+	if len(modified) > 0 {
+		_ = s.client.WritePacket(&packet.SetScore{ActionType: packet.ScoreboardActionModify, Entries: modified})
+	}
+	if len(b.lines) == 0 && b.objective == b.backendObjective() {
+		b.objective = ""
+	}
+}
+
+// clear removes the lines of the proxy from the objective they were last sent to. b.mu must be held when calling
+// clear.
+func (b *scoreboard) clear(s *Session) {
+	switch b.objective {
+	case "":
+	case linesObjective:
+		_ = s.client.WritePacket(&packet.RemoveObjective{ObjectiveName: linesObjective})
+	default:
+		if len(b.sent) > 0 {
+			pk := &packet.SetScore{ActionType: packet.ScoreboardActionRemove}
+			for i := range b.sent {
+				pk.Entries = append(pk.Entries, lineEntry(b.objective, i, 0, ""))
+			}
+			_ = s.client.WritePacket(pk)
+		}
+	}
+	b.objective, b.sent = "", nil
+}
+
+// updateEntries sends the player list entries of which the name changed since they were last sent to the client of
+// the Session passed. b.mu must be held when calling updateEntries.
+func (b *scoreboard) updateEntries(s *Session) {
+	removed := &packet.PlayerList{ActionType: packet.PlayerListActionRemove}
+	added := &packet.PlayerList{ActionType: packet.PlayerListActionAdd}
+	for id, e := range b.entries {
+		name := replacePlaceholders(s, e.name)
+		if name == e.sent {
+			continue
+		}
+		if e.sent != "" {
+			removed.Entries = append(removed.Entries, protocol.PlayerListEntry{UUID: id})
+		}
+		added.Entries = append(added.Entries, protocol.PlayerListEntry{UUID: id, Username: name, BuildPlatform: -1, Skin: blankSkin})
+		e.sent = name
+	}
+	if len(removed.Entries) > 0 {
+		_ = s.client.WritePacket(removed)
+	}
+	if len(added.Entries) > 0 {
+		_ = s.client.WritePacket(added)
+	}
+}
+
+// reattach sends the lines and player list entries of the proxy again after the Session passed was attached to
+// another server, which removed the objectives and player list entries of the previous server.
+func (b *scoreboard) reattach(s *Session) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.objective != linesObjective {
+		b.objective, b.sent = "", nil
+	}
+	b.display, b.deferred = nil, false
+	b.update(s)
+}
+
+// lineEntry returns the scoreboard entry of the line with the index, score and text passed in the objective passed.
+// The entry IDs of the lines count down from -1, so that they never collide with the entry IDs of the backend, which
+// count up.
+func lineEntry(objective string, index int, score int32, text string) protocol.ScoreboardEntry {
+	return protocol.ScoreboardEntry{
+		EntryID:       -1 - int64(index),
+		ObjectiveName: objective,
+		Score:         score,
+		IdentityType:  protocol.ScoreboardIdentityFakePlayer,
+		DisplayName:   text,
+	}
+}
+
+// blankSkin is the skin of the player list entries of the proxy, which are not players and so have no skin. The
+// client requires a valid skin nonetheless.
+var blankSkin = protocol.Skin{
+	SkinID:            "draco:blank",
+	SkinResourcePatch: []byte(`{"geometry":{"default":"geometry.humanoid.custom"}}`),
+	SkinImageWidth:    64,
+	SkinImageHeight:   32,
+	SkinData:          make([]byte, 64*32*4),
+}
@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestScoreboardLines(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	// The lines are updated by hand, rather than every second.
+	s.scoreboard.once.Do(func() {})
+
+	s.SetScoreboardLines("Proxy", []ScoreboardLine{{Text: "Node: {backend}", Score: 10}})
+	if len(conn.packets) != 2 {
+		t.Fatalf("%v packets sent to show the lines, expected 2", len(conn.packets))
+	}
+	if pk, ok := conn.packets[0].(*packet.SetDisplayObjective); !ok || pk.ObjectiveName != linesObjective || pk.DisplayName != "Proxy" {
+		t.Errorf("expected the objective of the proxy to be displayed, got %#v", conn.packets[0])
+	}
+
+	// The backend displays its own sidebar, to which the lines are added once it fills it.
+	conn.packets = nil
+	if handle(s, ServerToClient, &packet.SetDisplayObjective{DisplaySlot: packet.ScoreboardSlotSidebar, ObjectiveName: "game"}) != Forward {
+		t.Fatal("sidebar of the backend dropped")
+	}
+	if len(conn.packets) != 1 {
+		t.Fatalf("%v packets sent when the backend displayed a sidebar, expected the objective of the proxy removed", len(conn.packets))
+	}
+	scores := &packet.SetScore{ActionType: packet.ScoreboardActionModify, Entries: []protocol.ScoreboardEntry{{EntryID: 1, ObjectiveName: "game", DisplayName: "Kills: 3"}}}
+	handle(s, ServerToClient, scores)
+	if len(scores.Entries) != 2 || scores.Entries[1].ObjectiveName != "game" || scores.Entries[1].EntryID != -1 {
+		t.Fatalf("lines not added to the scores of the backend: %#v", scores.Entries)
+	}
+
+	// Once the backend removes its sidebar, the lines are shown in the objective of the proxy again.
+	handle(s, ServerToClient, &packet.RemoveObjective{ObjectiveName: "game"})
+	conn.packets = nil
+	s.scoreboard.mu.Lock()
+	s.scoreboard.update(s)
+	s.scoreboard.mu.Unlock()
+	if len(conn.packets) != 2 {
+		t.Fatalf("%v packets sent after the backend removed its sidebar, expected 2", len(conn.packets))
+	}
+
+	conn.packets = nil
+	s.SetScoreboardLines("", nil)
+	if pk, ok := conn.packets[0].(*packet.RemoveObjective); len(conn.packets) != 1 || !ok || pk.ObjectiveName != linesObjective {
+		t.Errorf("expected the objective of the proxy to be removed, got %#v", conn.packets)
+	}
+}
+
+func TestPlayerListEntries(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	s.scoreboard.once.Do(func() {})
+
+	id := uuid.New()
+	s.SetPlayerListEntry(id, "Node EU-1")
+	if pk, ok := conn.packets[0].(*packet.PlayerList); len(conn.packets) != 1 || !ok || pk.Entries[0].Username != "Node EU-1" {
+		t.Fatalf("expected the entry to be added, got %#v", conn.packets)
+	}
+	// Renaming the entry removes it and adds it again.
+	s.SetPlayerListEntry(id, "Node EU-2")
+	if len(conn.packets) != 3 {
+		t.Errorf("%v packets sent after renaming the entry, expected 3", len(conn.packets))
+	}
+
+	pk := &packet.PlayerList{ActionType: packet.PlayerListActionRemove, Entries: []protocol.PlayerListEntry{{UUID: id}}}
+	if handle(s, ServerToClient, pk) != Drop {
+		t.Error("expected the backend removing the entry of the proxy to be dropped")
+	}
+	other := uuid.New()
+	pk = &packet.PlayerList{ActionType: packet.PlayerListActionRemove, Entries: []protocol.PlayerListEntry{{UUID: id}, {UUID: other}}}
+	if handle(s, ServerToClient, pk) != Forward || len(pk.Entries) != 1 || pk.Entries[0].UUID != other {
+		t.Errorf("expected only the entries of the backend to be forwarded, got %#v", pk.Entries)
+	}
+
+	conn.packets = nil
+	s.RemovePlayerListEntry(id)
+	if len(conn.packets) != 1 {
+		t.Errorf("%v packets sent to remove the entry, expected 1", len(conn.packets))
+	}
+}
+
+func TestScoreboardLinesWithSidebar(t *testing.T) {
+	SetSidebar(SidebarConfig{Title: "Network", Lines: []string{"Online: {online}"}})
+	defer SetSidebar(SidebarConfig{})
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	s.scoreboard.once.Do(func() {})
+
+	// The sidebar of the proxy is visible, so the lines and the sidebar of the backend are held back.
+	s.SetScoreboardLines("Proxy", []ScoreboardLine{{Text: "Node: {backend}", Score: 10}})
+	if handle(s, ServerToClient, &packet.SetDisplayObjective{DisplaySlot: packet.ScoreboardSlotSidebar, ObjectiveName: "game"}) != Drop {
+		t.Fatal("sidebar of the backend displayed over the sidebar of the proxy")
+	}
+	scores := &packet.SetScore{ActionType: packet.ScoreboardActionModify, Entries: []protocol.ScoreboardEntry{{EntryID: 1, ObjectiveName: "game", DisplayName: "Kills: 3"}}}
+	handle(s, ServerToClient, scores)
+	if len(scores.Entries) != 1 {
+		t.Fatalf("lines added to the hidden sidebar of the backend: %#v", scores.Entries)
+	}
+	for _, pk := range conn.packets {
+		if pk, ok := pk.(*packet.SetDisplayObjective); ok && pk.ObjectiveName != sidebarObjective {
+			t.Fatalf("objective %v displayed while the sidebar of the proxy is visible", pk.ObjectiveName)
+		}
+	}
+
+	// Once the sidebar of the proxy is hidden, that of the backend is displayed with the lines added to it.
+	conn.packets = nil
+	s.ShowSidebar(false)
+	if len(conn.packets) != 3 {
+		t.Fatalf("%v packets sent when hiding the sidebar, expected 3: %#v", len(conn.packets), conn.packets)
+	}
+	if pk, ok := conn.packets[1].(*packet.SetDisplayObjective); !ok || pk.ObjectiveName != "game" {
+		t.Errorf("expected the sidebar of the backend to be displayed again, got %#v", conn.packets[1])
+	}
+	if pk, ok := conn.packets[2].(*packet.SetScore); !ok || len(pk.Entries) != 1 || pk.Entries[0].ObjectiveName != "game" || pk.Entries[0].EntryID != -1 {
+		t.Errorf("expected the lines to be added to the sidebar of the backend, got %#v", conn.packets[2])
+	}
+
+	// Showing the sidebar of the proxy again removes the lines from the objective of the backend.
+	conn.packets = nil
+	s.ShowSidebar(true)
+	if pk, ok := conn.packets[0].(*packet.SetScore); !ok || pk.ActionType != packet.ScoreboardActionRemove || pk.Entries[0].ObjectiveName != "game" {
+		t.Errorf("expected the lines to be removed from the sidebar of the backend, got %#v", conn.packets[0])
+	}
+	if pk, ok := conn.packets[1].(*packet.SetDisplayObjective); !ok || pk.ObjectiveName != sidebarObjective {
+		t.Errorf("expected the sidebar of the proxy to be displayed, got %#v", conn.packets[1])
+	}
+
+	// The backend removes its sidebar while it is covered, so the lines are shown in the objective of the proxy once
+	// the sidebar is hidden.
+	handle(s, ServerToClient, &packet.RemoveObjective{ObjectiveName: "game"})
+	conn.packets = nil
+	s.ShowSidebar(false)
+	if len(conn.packets) != 3 {
+		t.Fatalf("%v packets sent when hiding the sidebar, expected 3: %#v", len(conn.packets), conn.packets)
+	}
+	if pk, ok := conn.packets[1].(*packet.SetDisplayObjective); !ok || pk.ObjectiveName != linesObjective {
+		t.Errorf("expected the objective of the proxy to be displayed, got %#v", conn.packets[1])
+	}
+}
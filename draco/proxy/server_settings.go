@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// SettingsPolicy is the way ServerSettingsRequest packets sent by clients, which they send when opening their
+// settings, are handled.
+type SettingsPolicy uint8
+
+const (
+	// SettingsForward forwards ServerSettingsRequest packets to the backend, which may answer with its own form.
+	// It is the default SettingsPolicy.
+	SettingsForward SettingsPolicy = iota
+	// SettingsProxy answers ServerSettingsRequest packets with a form owned by the proxy. Forms sent by the
+	// backend are dropped.
+	SettingsProxy
+	// SettingsSuppress drops ServerSettingsRequest packets, so that no server settings are shown at all.
+	SettingsSuppress
+)
+
+// ParseSettingsPolicy parses a SettingsPolicy from its name, as returned by SettingsPolicy.String. False is
+// returned if no SettingsPolicy has the name passed.
+func ParseSettingsPolicy(name string) (SettingsPolicy, bool) {
+	switch strings.ToLower(name) {
+	case "forward":
+		return SettingsForward, true
+	case "proxy":
+		return SettingsProxy, true
+	case "suppress":
+		return SettingsSuppress, true
+	}
+	return 0, false
+}
+
+// String ...
+func (p SettingsPolicy) String() string {
+	switch p {
+	case SettingsForward:
+		return "forward"
+	case SettingsProxy:
+		return "proxy"
+	case SettingsSuppress:
+		return "suppress"
+	}
+	return "unknown"
+}
+
+// ServerSettings configures the handling of ServerSettingsRequest packets.
+type ServerSettings struct {
+	// Policy is the way the packets are handled.
+	Policy SettingsPolicy
+	// Title and Text are the title and the text at the top of the form shown with the SettingsProxy policy. If
+	// Title is empty, a translated default title is used.
+	Title, Text string
+}
+
+var (
+	// settingsMu guards settings.
+	settingsMu sync.RWMutex
+	// settings is the ServerSettings currently used.
+	settings ServerSettings
+)
+
+// SetServerSettings sets the ServerSettings used for all sessions.
+func SetServerSettings(s ServerSettings) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	settings = s
+}
+
+// serverSettings returns the ServerSettings currently used.
+func serverSettings() ServerSettings {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return settings
+}
+
+func init() {
+	Handle(ClientToServer, func(s *Session, pk *packet.ServerSettingsRequest) Action {
+		switch conf := serverSettings(); conf.Policy {
+		case SettingsProxy:
+			_ = s.client.WritePacket(&packet.ServerSettingsResponse{FormID: s.forms.register(handleSettings), FormData: settingsForm(s, conf)})
+			return Drop
+		case SettingsSuppress:
+			return Drop
+		}
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.ServerSettingsResponse) Action {
+		if serverSettings().Policy != SettingsForward {
+			return Drop
+		}
+		s.forms.serverForm(&pk.FormID)
+		return Forward
+	})
+}
+
+// handleSettings handles the response of a player to the form shown with the SettingsProxy policy.
+func handleSettings(s *Session, response []byte) {
+	// The response holds a value for every element of the form, which is null for the label.
+	var values []any
+	if err := json.Unmarshal(response, &values); err != nil || len(values) < 2 {
+		// The form was closed or the response is malformed.
+		return
+	}
+	if show, ok := values[1].(bool); ok {
+		s.ShowSidebar(show)
+	}
+}
+
+// settingsForm returns the JSON data of the form shown to the Session passed with the SettingsProxy policy. It
+// holds the text of the ServerSettings and, if a sidebar is configured, a toggle to show or hide it.
+func settingsForm(s *Session, conf ServerSettings) []byte {
+	title := conf.Title
+	if title == "" {
+		title = s.Translate("settings.title")
+	}
+	content := []map[string]any{{"type": "label", "text": conf.Text}}
+	if s.sidebar != nil {
+		content = append(content, map[string]any{"type": "toggle", "text": s.Translate("settings.sidebar"), "default": s.SidebarVisible()})
+	}
+	data, _ := json.Marshal(map[string]any{"type": "custom_form", "title": title, "content": content})
+	return data
+}
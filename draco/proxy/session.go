@@ -0,0 +1,455 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/lang"
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Session is a single player connected to the proxy. It holds the connection of the client and the connection to
+// the backend server the client was forwarded to, and forwards packets between the two, passing them through the
+// handlers registered using Handle.
+type Session struct {
+	client ClientConn
+
+	// connMu guards server, backend, clock, chunks, ids, breaking and transfer, which change when the Session is
+	// attached to another server, and packs.
+	connMu   sync.RWMutex
+	server   Conn
+	backend  Backend
+	clock    *worldClock
+	chunks   *chunkTranslator
+	ids      entityIDs
+	breaking *breakingBridge
+	transfer *minecraft.GameData
+	// packs holds the paths of the resource packs of backends that the client was offered, set using
+	// SetResourcePacks.
+	packs []string
+
+	role Role
+	name string
+
+	bossBars bossBars
+	bossBar  proxyBossBar
+	forms    forms
+	pacer    *chunkPacer
+	queue    *writeQueue
+	pipeline *chunkPipeline
+	sidebar  *sidebar
+	updates  *blockUpdates
+	// scoreboard holds the scoreboard lines and player list entries that the proxy adds to those of the backend.
+	scoreboard scoreboard
+	probe      *backendProbe
+	quality    connectionQuality
+	pings      sessionPings
+	potato     potato
+	adaptive   adaptiveRadius
+	fog        atmosphereState
+	world      *world
+	known      *knownChunks
+
+	abilities abilities
+	inventory inventorySync
+
+	packetLog packetLog
+	capture   sessionCapture
+	memory    sessionMemory
+	rate      packetRate
+	cooldown  cooldown
+	latency   [2]latencyStats
+	// translationErrors holds the amount of packets that couldn't be translated in either Direction. It is accessed
+	// atomically.
+	translationErrors [2]uint64
+
+	// disconnectMu guards disconnected, reason and cause.
+	disconnectMu sync.Mutex
+	disconnected bool
+	reason       string
+	cause        DisconnectCause
+
+	activity clientActivity
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	values sync.Map
+
+	once   sync.Once
+	closed chan struct{}
+}
+
+var (
+	// sessionMu guards sessions.
+	sessionMu sync.RWMutex
+	// sessions holds all sessions that are currently started.
+	sessions = map[*Session]struct{}{}
+)
+
+var (
+	// hookMu guards startHooks and closeHooks.
+	hookMu sync.RWMutex
+	// startHooks and closeHooks hold the functions registered using OnStart and OnClose respectively.
+	startHooks, closeHooks []func(s *Session)
+)
+
+// OnStart registers a function that is called with every Session right after it is started.
+func OnStart(f func(s *Session)) {
+	hookMu.Lock()
+	defer hookMu.Unlock()
+	startHooks = append(startHooks, f)
+}
+
+// OnClose registers a function that is called with every Session right after it is closed.
+func OnClose(f func(s *Session)) {
+	hookMu.Lock()
+	defer hookMu.Unlock()
+	closeHooks = append(closeHooks, f)
+}
+
+// runHooks calls all hooks passed with the Session passed.
+func runHooks(s *Session, hooks *[]func(s *Session)) {
+	hookMu.RLock()
+	hs := *hooks
+	hookMu.RUnlock()
+	for _, h := range hs {
+		h(s)
+	}
+}
+
+// Sessions returns all sessions currently active on the proxy.
+func Sessions() []*Session {
+	sessionMu.RLock()
+	defer sessionMu.RUnlock()
+	all := make([]*Session, 0, len(sessions))
+	for s := range sessions {
+		all = append(all, s)
+	}
+	return all
+}
+
+// SessionByXUID looks up an active Session by the XUID of its player. If no session with that XUID is active,
+// false is returned.
+func SessionByXUID(xuid string) (*Session, bool) {
+	if xuid == "" {
+		return nil, false
+	}
+	sessionMu.RLock()
+	defer sessionMu.RUnlock()
+	for s := range sessions {
+		if s.XUID() == xuid {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// NewSession creates a Session for a client connected to the proxy and a connection to the Backend passed. Both
+// connections must already be spawned, and a slot must have been reserved for the client using Reserve. Start must be
+// called to start forwarding packets.
+func NewSession(client ClientConn, server Conn, backend Backend) *Session {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Session{
+		client:   client,
+		server:   server,
+		backend:  backend,
+		name:     client.IdentityData().DisplayName,
+		bossBars: bossBars{},
+		pacer:    newChunkPacer(client.Latency),
+		queue:    newWriteQueue(),
+		pipeline: newChunkPipeline(client),
+		sidebar:  newSidebar(),
+		updates:  newBlockUpdates(client.WritePacket),
+		probe:    newBackendProbe(),
+		clock:    newWorldClock(backend, server),
+		chunks:   newChunkTranslator(backend, server),
+		ids:      newEntityIDs(client, server),
+		breaking: newBreakingBridge(client, server),
+		world:    newWorld(client),
+		known:    newKnownChunks(client),
+		memory:   newSessionMemory(),
+		ctx:      ctx,
+		cancel:   cancel,
+		closed:   make(chan struct{}),
+	}
+	// A sidebar visible from the start covers the sidebar display slot before the backend displays anything in it.
+	s.scoreboard.covered = s.sidebar != nil && s.sidebar.visible
+	if s.queue != nil {
+		// Block updates held back are queued like other packets, so that they are never written ahead of the
+		// chunks they belong to.
+		s.updates = newBlockUpdates(s.queue.write)
+	}
+	return s
+}
+
+// XUID returns the XBOX Live user ID of the player of the Session. It is empty if the player was not
+// authenticated, which is always the case for guests.
+func (s *Session) XUID() string {
+	if s.role == RoleGuest {
+		// The identity of guests isn't verified, so they could claim the XUID of any other player.
+		return ""
+	}
+	return s.client.IdentityData().XUID
+}
+
+// Name returns the display name of the player of the Session.
+func (s *Session) Name() string {
+	return s.name
+}
+
+// Role returns the Role of the player of the Session.
+func (s *Session) Role() Role {
+	return s.role
+}
+
+// Client returns the connection of the client of the Session.
+func (s *Session) Client() ClientConn {
+	return s.client
+}
+
+// Server returns the connection to the backend server of the Session.
+func (s *Session) Server() Conn {
+	s.connMu.RLock()
+	defer s.connMu.RUnlock()
+	return s.server
+}
+
+// Backend returns the Backend that the Session is connected to.
+func (s *Session) Backend() Backend {
+	s.connMu.RLock()
+	defer s.connMu.RUnlock()
+	return s.backend
+}
+
+// Context returns the context of the Session. It is cancelled once the Session is closed, so that work started
+// by packet handlers for the Session, such as requests to external services, can be stopped when the player
+// leaves.
+func (s *Session) Context() context.Context {
+	return s.ctx
+}
+
+// Value returns the value stored in the Session under the key passed using SetValue, or nil if no value is set.
+// Packet handlers may use values to keep state per Session. Like with context values, keys should be of an
+// unexported type to avoid collisions between packages.
+func (s *Session) Value(key any) any {
+	v, _ := s.values.Load(key)
+	return v
+}
+
+// SetValue stores a value in the Session under the key passed. Setting a nil value removes the key. SetValue may
+// be called from any goroutine.
+func (s *Session) SetValue(key, value any) {
+	if value == nil {
+		s.values.Delete(key)
+		return
+	}
+	s.values.Store(key, value)
+}
+
+// Locale returns the language code of the client of the Session, such as en_US.
+func (s *Session) Locale() string {
+	return s.client.ClientData().LanguageCode
+}
+
+// Translate translates the message with the key passed to the locale of the client of the Session, formatting it
+// with the arguments passed. All messages sent by the proxy itself should be translated using Translate.
+func (s *Session) Translate(key string, args ...any) string {
+	return lang.Translate(s.Locale(), key, args...)
+}
+
+// Start starts forwarding packets between the client and the server of the Session. It returns immediately, and
+// the Session is closed once either of the two connections is closed.
+func (s *Session) Start() {
+	sessionMu.Lock()
+	sessions[s] = struct{}{}
+	sessionMu.Unlock()
+	startedDuplicate(s)
+	s.startCapture()
+
+	go s.supervise(ClientToServer, s.forwardClientPackets)
+	go s.supervise(ServerToClient, s.forwardServerPackets)
+	if s.queue != nil {
+		go s.supervise(ServerToClient, s.writeQueued)
+	}
+	if s.pipeline != nil {
+		go s.supervise(ServerToClient, s.forwardPipelined)
+	}
+	if s.probe != nil {
+		go s.probe.run(s)
+	}
+	go s.quality.run(s)
+	go s.pings.run(s)
+	if c := s.worldClock(); c != nil {
+		go c.run(s)
+	}
+	runHooks(s, &startHooks)
+	emit(JoinEvent{Session: s, Backend: s.Backend()})
+}
+
+// Disconnect disconnects the client of the Session, showing it the message passed, and closes the Session. Only the
+// first message that the client is disconnected with is shown: if the Session was already disconnected, for example
+// because the backend kicked the player, the message passed is discarded.
+func (s *Session) Disconnect(message string) {
+	s.setCause(CauseProxyKick)
+	s.disconnect(message)
+	s.close()
+}
+
+// DisconnectReason returns the message that the client of the Session was disconnected with. False is returned if
+// the client was not disconnected yet.
+func (s *Session) DisconnectReason() (string, bool) {
+	s.disconnectMu.Lock()
+	defer s.disconnectMu.Unlock()
+	return s.reason, s.disconnected
+}
+
+// disconnect disconnects the client with the message passed, unless it was already disconnected. Both forwarding
+// goroutines may try to disconnect the client at the same time with different messages, in which case only the
+// first one is sent, so that the player doesn't see an arbitrary one of them.
+func (s *Session) disconnect(message string) {
+	s.disconnectMu.Lock()
+	defer s.disconnectMu.Unlock()
+	if s.disconnected {
+		return
+	}
+	s.disconnected, s.reason = true, message
+	_ = s.client.Disconnect(message)
+}
+
+// close closes both connections of the Session and releases its slot and identity on the proxy. Calling close more than
+// once has no effect.
+func (s *Session) close() {
+	s.once.Do(func() {
+		close(s.closed)
+		s.cancel()
+		s.queue.close()
+		_ = s.Server().Close()
+		s.disconnect(s.Translate("disconnect.connection_lost"))
+		Release(s.Backend())
+		ReleaseIdentity(s.XUID())
+
+		sessionMu.Lock()
+		delete(sessions, s)
+		sessionMu.Unlock()
+		closedMemory(s)
+		runHooks(s, &closeHooks)
+		s.setCause(CauseUnknown)
+		reason, _ := s.DisconnectReason()
+		cause, _ := s.DisconnectCause()
+		countDisconnect(s, cause)
+		emit(QuitEvent{Session: s, Reason: reason, Cause: cause})
+	})
+}
+
+// supervise runs a function forwarding packets in the Direction passed and closes the Session once it returns. If
+// the function panics, for example because a packet could not be translated, the panic is logged together with
+// the Session it occurred in, so that it only closes that Session rather than the entire proxy.
+func (s *Session) supervise(d Direction, forward func()) {
+	defer s.close()
+	defer func() {
+		if r := recover(); r != nil {
+			metrics.Add("session_panics", 1)
+			s.setCause(CauseTranslationError)
+			s.Logger().Error("panic forwarding packets", "direction", d, "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+		}
+	}()
+	forward()
+}
+
+// forwardClientPackets reads packets from the client and writes them to the server until either of the two
+// connections is closed.
+func (s *Session) forwardClientPackets() {
+	for {
+		pk, err := s.client.ReadPacket()
+		if err != nil {
+			s.setCause(clientCause(s, err))
+			return
+		}
+		s.activity.seen()
+		start := time.Now()
+		if s.runMiddleware(ClientToServer, pk) == Drop {
+			continue
+		}
+		s.entityIDs().swap(pk)
+		server, write := s.Server(), time.Now()
+		err = server.WritePacket(pk)
+		s.latency[ClientToServer].record(pk, time.Since(start), time.Since(write))
+		if err != nil {
+			if s.Server() != server || recovers() {
+				// The Session was attached to another server, which closed the connection to this one, or it may
+				// fall back to another server, which the goroutine reading from the server takes care of.
+				continue
+			}
+			s.setCause(serverCause(err))
+			if message, ok := disconnectMessage(err); ok {
+				s.disconnect(message)
+			}
+			return
+		}
+	}
+}
+
+// forwardServerPackets reads packets from the server and writes them to the client until either of the two
+// connections is closed.
+func (s *Session) forwardServerPackets() {
+	server := s.Server()
+	for {
+		pk, err := server.ReadPacket()
+		if current := s.Server(); current != server {
+			// The Session was attached to another server, so the packets of the previous one are discarded.
+			server = current
+			s.attached()
+			continue
+		}
+		if err != nil {
+			if s.fallBack(err) {
+				continue
+			}
+			s.setCause(serverCause(err))
+			if message, ok := disconnectMessage(err); ok {
+				s.disconnect(message)
+			}
+			return
+		}
+		if s.probe.seen(pk) {
+			ObserveBackend(s.Backend(), s.BackendLatency(), nil)
+			continue
+		}
+		start := time.Now()
+		s.entityIDs().swap(pk)
+		if s.runMiddleware(ServerToClient, pk) == Drop {
+			continue
+		}
+		if s.pipeline != nil {
+			if !s.pipeline.push(s, pk, start) {
+				return
+			}
+			continue
+		}
+		if !s.forwardServerPacket(pk, start) {
+			return
+		}
+	}
+}
+
+// forwardServerPacket forwards a packet of the server, received at the time passed, to the client once it passed
+// the middleware. False is returned if the client can no longer be written to.
+func (s *Session) forwardServerPacket(pk packet.Packet, start time.Time) bool {
+	if s.updates.add(pk) {
+		return true
+	}
+	s.updates.flush()
+	if s.queue != nil {
+		return s.queue.push(pk, start)
+	}
+	s.pacer.pace(pk)
+	write := time.Now()
+	err := s.writeClient(pk)
+	s.latency[ServerToClient].record(pk, time.Since(start), time.Since(write))
+	return err == nil
+}
@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// SidebarConfig configures the sidebar that the proxy shows to players. Its title and lines may hold placeholders
+// such as {ping}, which are replaced with their value for the player every second. See RegisterPlaceholder for the
+// placeholders available.
+type SidebarConfig struct {
+	// Title is the title shown above the lines of the sidebar.
+	Title string
+	// Lines holds the lines of the sidebar, from top to bottom. If empty, no sidebar is shown.
+	Lines []string
+	// Hidden specifies if the sidebar is hidden for new sessions until it is shown using Session.ShowSidebar.
+	Hidden bool
+}
+
+const (
+	// sidebarObjective is the name of the scoreboard objective of the sidebar. It is namespaced so that it doesn't
+	// conflict with objectives of the backend.
+	sidebarObjective = "draco:sidebar"
+	// sidebarInterval is the interval at which the lines of a sidebar are updated.
+	sidebarInterval = time.Second
+)
+
+var (
+	// sidebarMu guards sidebarConf and placeholders.
+	sidebarMu sync.RWMutex
+	// sidebarConf is the SidebarConfig used for new sessions.
+	sidebarConf SidebarConfig
+	// placeholders holds the functions registered using RegisterPlaceholder, keyed by the name of the placeholder.
+	placeholders = map[string]func(s *Session) string{
+		"name":    (*Session).Name,
+		"backend": func(s *Session) string { return s.Backend().Name },
+		"ping":    func(s *Session) string { return strconv.FormatInt(s.Client().Latency().Milliseconds(), 10) },
+		"online":  func(s *Session) string { return strconv.Itoa(len(Sessions())) },
+	}
+)
+
+// SetSidebar sets the SidebarConfig used for sessions started after the call.
+func SetSidebar(conf SidebarConfig) {
+	sidebarMu.Lock()
+	defer sidebarMu.Unlock()
+	sidebarConf = conf
+}
+
+// RegisterPlaceholder registers a placeholder that may be used in the sidebar as {name}. It is replaced with the
+// value returned by f for the Session that the sidebar is shown to. The placeholders {name}, {backend}, {ping}
+// and {online} are registered by default. Registering a placeholder with the name of an existing one replaces it.
+func RegisterPlaceholder(name string, f func(s *Session) string) {
+	sidebarMu.Lock()
+	defer sidebarMu.Unlock()
+	placeholders[name] = f
+}
+
+// replacePlaceholders replaces all placeholders in the line passed with their value for the Session passed.
+// Placeholders that are not registered are left as they are.
+func replacePlaceholders(s *Session, line string) string {
+	if !strings.Contains(line, "{") {
+		return line
+	}
+	sidebarMu.RLock()
+	defer sidebarMu.RUnlock()
+	for name, f := range placeholders {
+		if p := "{" + name + "}"; strings.Contains(line, p) {
+			line = strings.ReplaceAll(line, p, f(s))
+		}
+	}
+	return line
+}
+
+// sidebar is the sidebar of a single Session. While it is visible, it covers the sidebar display slot owned by the
+// scoreboard of the Session, so that sidebars displayed by the backend are hidden until it is hidden again.
+type sidebar struct {
+	conf SidebarConfig
+
+	mu      sync.Mutex
+	visible bool
+	title   string
+	lines   []string
+}
+
+// newSidebar returns the sidebar of a new Session, or nil if no sidebar is configured.
+func newSidebar() *sidebar {
+	sidebarMu.RLock()
+	conf := sidebarConf
+	sidebarMu.RUnlock()
+	if len(conf.Lines) == 0 {
+		return nil
+	}
+	return &sidebar{conf: conf, visible: !conf.Hidden}
+}
+
+func init() {
+	OnStart(func(s *Session) {
+		if s.sidebar != nil {
+			go s.sidebar.run(s)
+		}
+	})
+}
+
+// ShowSidebar shows or hides the sidebar of the Session. When it is hidden, the last sidebar displayed by the
+// backend is displayed again, although without the scores the backend set while it was hidden, together with the
+// lines added using SetScoreboardLines. ShowSidebar has no effect if no sidebar is configured.
+func (s *Session) ShowSidebar(show bool) {
+	if s.sidebar == nil {
+		return
+	}
+	s.sidebar.mu.Lock()
+	defer s.sidebar.mu.Unlock()
+	if s.sidebar.visible == show {
+		return
+	}
+	s.sidebar.visible = show
+	if show {
+		s.scoreboard.cover(s, true)
+		s.sidebar.update(s)
+		return
+	}
+	_ = s.client.WritePacket(&packet.RemoveObjective{ObjectiveName: sidebarObjective})
+	s.sidebar.title, s.sidebar.lines = "", nil
+	s.scoreboard.cover(s, false)
+}
+
+// SidebarVisible checks if the sidebar of the Session is currently shown.
+func (s *Session) SidebarVisible() bool {
+	if s.sidebar == nil {
+		return false
+	}
+	s.sidebar.mu.Lock()
+	defer s.sidebar.mu.Unlock()
+	return s.sidebar.visible
+}
+
+// run updates the sidebar every sidebarInterval until the Session passed is closed.
+func (b *sidebar) run(s *Session) {
+	t := time.NewTicker(sidebarInterval)
+	defer t.Stop()
+	for {
+		b.mu.Lock()
+		if b.visible {
+			b.update(s)
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-t.C:
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// update sends the lines of the sidebar that changed since the last update to the client of the Session passed,
+// displaying the sidebar first if it isn't displayed yet or its title changed. b.mu must be held when calling
+// update.
+func (b *sidebar) update(s *Session) {
+	title := replacePlaceholders(s, b.conf.Title)
+	if b.lines == nil || title != b.title {
+		if b.lines != nil {
+			_ = s.client.WritePacket(&packet.RemoveObjective{ObjectiveName: sidebarObjective})
+		}
+		_ = s.client.WritePacket(&packet.SetDisplayObjective{
+			DisplaySlot:   packet.ScoreboardSlotSidebar,
+			ObjectiveName: sidebarObjective,
+			DisplayName:   title,
+			CriteriaName:  "dummy",
+			SortOrder:     packet.ScoreboardSortOrderAscending,
+		})
+		b.title, b.lines = title, make([]string, len(b.conf.Lines))
+		for i := range b.lines {
+			// Force every line to be sent, as the objective was just created.
+			b.lines[i] = "\x00"
+		}
+	}
+
+	var removed, modified []protocol.ScoreboardEntry
+	for i, l := range b.conf.Lines {
+		line := replacePlaceholders(s, l)
+		if line == b.lines[i] {
+			continue
+		}
+		// Every line is an entry of a fake player with the line as its name, ordered by its score.
+		entry := protocol.ScoreboardEntry{
+			EntryID:       int64(i + 1),
+			ObjectiveName: sidebarObjective,
+			Score:         int32(i),
+			IdentityType:  protocol.ScoreboardIdentityFakePlayer,
+			DisplayName:   line,
+		}
+		removed, modified = append(removed, entry), append(modified, entry)
+		b.lines[i] = line
+	}
+	if len(modified) == 0 {
+		return
+	}
+	_ = s.client.WritePacket(&packet.SetScore{ActionType: packet.ScoreboardActionRemove, Entries: removed})
+	_ = s.client.WritePacket(&packet.SetScore{ActionType: packet.ScoreboardActionModify, Entries: modified})
+}
+
+// reattach displays the sidebar of the proxy again after the Session passed was attached to another server.
+func (b *sidebar) reattach(s *Session) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.visible && b.lines != nil {
+		// The title is reset to one that never matches the title of the sidebar, so that it is displayed again.
+		b.title = "\x00"
+		b.update(s)
+	}
+}
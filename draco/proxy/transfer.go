@@ -0,0 +1,250 @@
+package proxy
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Dialer dials the Backend passed for the player of the Session passed, returning a connection that is already
+// spawned. It is set using SetDialer and used by Transfer.
+type Dialer func(s *Session, b Backend) (Conn, error)
+
+var (
+	// ErrNoDialer is returned by Transfer if no Dialer was set using SetDialer.
+	ErrNoDialer = errors.New("no dialer set")
+	// ErrAlreadyConnected is returned by Transfer if the Session is already attached to the Backend passed.
+	ErrAlreadyConnected = errors.New("already connected to backend")
+	// ErrResourcePacks is returned by Transfer if the Backend passed has resource packs that the client of the
+	// Session was not offered when it joined the proxy.
+	ErrResourcePacks = errors.New("backend has resource packs that the client was not offered")
+)
+
+var (
+	// transferMu guards dialer and backends.
+	transferMu sync.RWMutex
+	// dialer is the Dialer set using SetDialer.
+	dialer Dialer
+	// backends holds the backends set using SetBackends, keyed by their lowercase name.
+	backends = map[string]Backend{}
+)
+
+// SetDialer sets the Dialer used to dial backends that sessions are transferred to using Transfer.
+func SetDialer(d Dialer) {
+	transferMu.Lock()
+	defer transferMu.Unlock()
+	dialer = d
+}
+
+// SetBackends sets the backends that players may transfer themselves to using /server, replacing those set before.
+func SetBackends(b []Backend) {
+	transferMu.Lock()
+	defer transferMu.Unlock()
+	backends = make(map[string]Backend, len(b))
+	for _, backend := range b {
+		backends[strings.ToLower(backend.Name)] = backend
+	}
+}
+
+// Backends returns the backends set using SetBackends, sorted by name.
+func Backends() []Backend {
+	transferMu.RLock()
+	defer transferMu.RUnlock()
+	all := make([]Backend, 0, len(backends))
+	for _, b := range backends {
+		all = append(all, b)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
+
+// BackendByName looks up a Backend set using SetBackends by its name, ignoring case. False is returned if no Backend
+// has the name passed.
+func BackendByName(name string) (Backend, bool) {
+	transferMu.RLock()
+	defer transferMu.RUnlock()
+	b, ok := backends[strings.ToLower(name)]
+	return b, ok
+}
+
+// Transfer transfers the Session to the Backend passed without disconnecting the client. The Backend is dialed using
+// the Dialer set using SetDialer and the Session is attached to it: the entities, player list entries and scoreboard
+// objectives of the previous server are removed, and the client is moved to the dimension and position of the new
+// server, which also clears its chunks. The client keeps the custom blocks and items of the server it first joined, so
+// backends must agree on these: custom blocks of the Backend that the client doesn't know are shown as the fallback
+// block. Clients only accept resource packs when they join the proxy, so ErrResourcePacks is returned if the Backend
+// has ResourcePacks that the client was not offered (see SetResourcePacks). If the Backend can't be dialed or is full,
+// the Session stays attached to its current server, unless the LimboWorld holds players in limbo during transfers, in
+// which case it is reconnected to its previous server from the limbo.
+func (s *Session) Transfer(b Backend) error {
+	if strings.EqualFold(s.Backend().Name, b.Name) {
+		return ErrAlreadyConnected
+	}
+	if !s.offered(b.ResourcePacks) {
+		return ErrResourcePacks
+	}
+	transferMu.RLock()
+	d := dialer
+	transferMu.RUnlock()
+	if d == nil {
+		return ErrNoDialer
+	}
+	var l *limbo
+	if previous := s.Backend(); limboWorld().DuringTransfers && previous.Name != LimboBackend && s.Server() != nil {
+		var err error
+		if l, err = s.enterLimbo(); err != nil {
+			return err
+		}
+		defer func() {
+			if s.Backend().Name == LimboBackend {
+				// The transfer failed, so the player is reconnected to the backend it was on before.
+				go s.reconnect(l, previous, fallback().Timeout)
+			}
+		}()
+	}
+	conn, err := d(s, b)
+	if err != nil {
+		return err
+	}
+	if data, ok := gameData(conn); ok {
+		data = GameModeGameData(WorldTimeGameData(data, b), b, s.Role())
+		s.connMu.Lock()
+		s.transfer = &data
+		s.connMu.Unlock()
+	}
+	if err := s.Attach(conn, b); err != nil {
+		s.connMu.Lock()
+		s.transfer = nil
+		s.connMu.Unlock()
+		_ = conn.Close()
+		return err
+	}
+	return nil
+}
+
+// SetResourcePacks sets the paths of the resource packs of backends that the client of the Session was offered when
+// it joined the proxy. Transfer refuses to transfer the Session to backends with other ResourcePacks.
+func (s *Session) SetResourcePacks(paths []string) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	s.packs = append([]string(nil), paths...)
+}
+
+// offered checks if all resource packs at the paths passed were offered to the client of the Session.
+func (s *Session) offered(paths []string) bool {
+	s.connMu.RLock()
+	defer s.connMu.RUnlock()
+	for _, path := range paths {
+		found := false
+		for _, offered := range s.packs {
+			if offered == path {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// takeTransfer returns the game data of the server that the Session was transferred to using Transfer, if it was
+// not yet applied to the client.
+func (s *Session) takeTransfer() (minecraft.GameData, bool) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	data := s.transfer
+	s.transfer = nil
+	if data == nil {
+		return minecraft.GameData{}, false
+	}
+	return *data, true
+}
+
+// changeWorld moves the client of the Session to the world of the server passed, as if it had joined it. The client
+// only clears its chunks when changing to another dimension, so if it is already in the dimension of the new server,
+// it is first moved to a temporary dimension.
+func (s *Session) changeWorld(data minecraft.GameData) {
+	var pks []packet.Packet
+	s.world.mu.Lock()
+	if s.world.dimension == data.Dimension {
+		temp := int32(packet.DimensionNether)
+		if data.Dimension == packet.DimensionNether {
+			temp = packet.DimensionOverworld
+		}
+		pks = append(pks, &packet.ChangeDimension{Dimension: temp, Position: data.PlayerPosition}, &packet.PlayStatus{Status: packet.PlayStatusPlayerSpawn})
+		s.world.acks++
+	}
+	s.world.acks++
+	s.world.dimension = data.Dimension
+	s.world.mu.Unlock()
+	s.known.reset(data.Dimension)
+
+	ids := s.entityIDs()
+	pks = append(pks,
+		&packet.ChangeDimension{Dimension: data.Dimension, Position: data.PlayerPosition},
+		&packet.PlayStatus{Status: packet.PlayStatusPlayerSpawn},
+		&packet.SetPlayerGameType{GameType: data.PlayerGameMode},
+		&packet.SetDifficulty{Difficulty: uint32(data.Difficulty)},
+		&packet.GameRulesChanged{GameRules: data.GameRules},
+		&packet.SetTime{Time: int32(data.Time)},
+		&packet.MovePlayer{
+			EntityRuntimeID: ids.clientRuntimeID,
+			Position:        data.PlayerPosition,
+			Pitch:           data.Pitch,
+			Yaw:             data.Yaw,
+			HeadYaw:         data.Yaw,
+			Mode:            packet.MoveModeTeleport,
+		},
+	)
+	for _, pk := range pks {
+		_ = s.client.WritePacket(pk)
+	}
+}
+
+func init() {
+	RegisterCommand(Command{
+		Name:        "server",
+		Aliases:     []string{"transfer"},
+		Description: "Lists the servers or transfers you to another server",
+		Run: func(s *Session, args []string) {
+			if len(args) == 0 {
+				all := Backends()
+				names := make([]string, 0, len(all))
+				for _, b := range all {
+					names = append(names, b.Name)
+				}
+				s.message("server.list", s.Backend().Name, strings.Join(names, ", "))
+				return
+			}
+			b, ok := BackendByName(args[0])
+			if !ok {
+				s.message("server.unknown", args[0])
+				return
+			}
+			s.message("server.connecting", b.Name)
+			// Transfer blocks until the backend is dialed, so it runs in its own goroutine to keep the packets of the
+			// client flowing.
+			go func() {
+				switch err := s.Transfer(b); {
+				case errors.Is(err, ErrAlreadyConnected):
+					s.message("server.already_connected", b.Name)
+				case errors.Is(err, ErrBackendFull):
+					s.message("server.full", b.Name)
+				case errors.Is(err, ErrResourcePacks):
+					s.message("server.resource_packs", b.Name)
+				case err != nil:
+					s.Logger().Error("error transferring to backend", "to", b.Name, "err", err)
+					s.message("server.failed", b.Name)
+				}
+			}()
+		},
+	})
+}
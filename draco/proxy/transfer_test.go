@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestSwapEntityIDs(t *testing.T) {
+	ids := entityIDs{clientRuntimeID: 1, serverRuntimeID: 7, clientUniqueID: -1, serverUniqueID: -7}
+
+	move := &packet.MovePlayer{EntityRuntimeID: 7, RiddenEntityRuntimeID: 3}
+	ids.swap(move)
+	if move.EntityRuntimeID != 1 || move.RiddenEntityRuntimeID != 3 {
+		t.Errorf("unexpected runtime IDs after swapping: %v, %v", move.EntityRuntimeID, move.RiddenEntityRuntimeID)
+	}
+	// Entities of the server with the IDs known by the client get the IDs of the player on the server, so that IDs
+	// never collide.
+	ids.swap(move)
+	if move.EntityRuntimeID != 7 {
+		t.Errorf("runtime ID not swapped back: %v", move.EntityRuntimeID)
+	}
+
+	list := &packet.PlayerList{ActionType: packet.PlayerListActionAdd, Entries: []protocol.PlayerListEntry{{EntityUniqueID: -7}, {EntityUniqueID: 5}}}
+	ids.swap(list)
+	if list.Entries[0].EntityUniqueID != -1 || list.Entries[1].EntityUniqueID != 5 {
+		t.Errorf("unexpected unique IDs after swapping: %v, %v", list.Entries[0].EntityUniqueID, list.Entries[1].EntityUniqueID)
+	}
+
+	same := entityIDs{clientRuntimeID: 1, serverRuntimeID: 1}
+	move = &packet.MovePlayer{EntityRuntimeID: 1}
+	same.swap(move)
+	if move.EntityRuntimeID != 1 {
+		t.Errorf("runtime ID swapped although the IDs are the same")
+	}
+}
+
+func TestAttachClearsWorld(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	id := uuid.New()
+	handle(s, ServerToClient, &packet.AddActor{EntityUniqueID: 3})
+	handle(s, ServerToClient, &packet.AddActor{EntityUniqueID: 4})
+	handle(s, ServerToClient, &packet.RemoveActor{EntityUniqueID: 4})
+	handle(s, ServerToClient, &packet.PlayerList{ActionType: packet.PlayerListActionAdd, Entries: []protocol.PlayerListEntry{{UUID: id}}})
+	handle(s, ServerToClient, &packet.SetDisplayObjective{ObjectiveName: "kills"})
+
+	s.attached()
+	var removed, players, objectives int
+	for _, pk := range conn.packets {
+		switch pk := pk.(type) {
+		case *packet.RemoveActor:
+			if pk.EntityUniqueID != 3 {
+				t.Errorf("removed entity %v, expected 3", pk.EntityUniqueID)
+			}
+			removed++
+		case *packet.PlayerList:
+			if pk.ActionType != packet.PlayerListActionRemove || len(pk.Entries) != 1 || pk.Entries[0].UUID != id {
+				t.Errorf("unexpected player list %#v", pk)
+			}
+			players++
+		case *packet.RemoveObjective:
+			objectives++
+		case *packet.ChangeDimension:
+			t.Errorf("dimension changed although the session was not transferred")
+		}
+	}
+	if removed != 1 || players != 1 || objectives != 1 {
+		t.Errorf("%v entities, %v player lists and %v objectives removed, expected 1 of each", removed, players, objectives)
+	}
+}
+
+func TestChangeWorldAcknowledgements(t *testing.T) {
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	s.changeWorld(minecraft.GameData{Dimension: packet.DimensionOverworld})
+
+	var dimensions []int32
+	for _, pk := range conn.packets {
+		if pk, ok := pk.(*packet.ChangeDimension); ok {
+			dimensions = append(dimensions, pk.Dimension)
+		}
+	}
+	if len(dimensions) != 2 || dimensions[0] != packet.DimensionNether || dimensions[1] != packet.DimensionOverworld {
+		t.Fatalf("unexpected dimension changes %v", dimensions)
+	}
+	done := &packet.PlayerAction{ActionType: protocol.PlayerActionDimensionChangeDone}
+	for i := 0; i < 2; i++ {
+		if handle(s, ClientToServer, done) != Drop {
+			t.Errorf("acknowledgement %v of a dimension change of the proxy forwarded", i)
+		}
+	}
+	if handle(s, ClientToServer, done) != Forward {
+		t.Errorf("acknowledgement of a dimension change of the server dropped")
+	}
+}
+
+func TestTransferResourcePacks(t *testing.T) {
+	skywars := &recordConn{}
+	SetDialer(func(s *Session, b Backend) (Conn, error) { return skywars, nil })
+	defer SetDialer(nil)
+
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{Name: "lobby"})
+	b := Backend{Name: "skywars", ResourcePacks: []string{"packs/skywars"}}
+	if err := s.Transfer(b); !errors.Is(err, ErrResourcePacks) {
+		t.Fatalf("transfer to backend with packs not offered returned %v, expected %v", err, ErrResourcePacks)
+	}
+	if s.Backend().Name != "lobby" {
+		t.Errorf("player attached to %v after refused transfer", s.Backend().Name)
+	}
+	s.SetResourcePacks([]string{"packs/lobby", "packs/skywars"})
+	if err := s.Transfer(b); err != nil {
+		t.Fatalf("transfer to backend with packs offered failed: %v", err)
+	}
+	if s.Backend().Name != "skywars" || s.Server() != skywars {
+		t.Errorf("player attached to %v rather than skywars", s.Backend().Name)
+	}
+}
@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// uiProvider is a provider of persistent UI registered using RegisterUI.
+type uiProvider struct {
+	name string
+	f    func(s *Session) []packet.Packet
+}
+
+var (
+	// uiMu guards uiProviders.
+	uiMu sync.RWMutex
+	// uiProviders holds the providers registered using RegisterUI, in the order they were registered in.
+	uiProviders []uiProvider
+)
+
+// RegisterUI registers a provider of persistent UI, such as boss bars or titles derived from the Role of a player.
+// f returns the packets that show the UI to the Session passed. They are sent when a Session is started and again
+// every time it is attached to another server, as the UI of the previous server is removed and the new server
+// doesn't know about it, so that plugins don't need to restore their UI themselves. The sidebar of the proxy is
+// restored after a transfer as well. Registering a provider with the name of an existing one replaces it.
+func RegisterUI(name string, f func(s *Session) []packet.Packet) {
+	uiMu.Lock()
+	defer uiMu.Unlock()
+	for i, p := range uiProviders {
+		if p.name == name {
+			uiProviders[i].f = f
+			return
+		}
+	}
+	uiProviders = append(uiProviders, uiProvider{name: name, f: f})
+}
+
+// RefreshUI sends the persistent UI registered using RegisterUI to the client of the Session again, for example
+// after something the UI is derived from changed.
+func (s *Session) RefreshUI() {
+	uiMu.RLock()
+	providers := append([]uiProvider(nil), uiProviders...)
+	uiMu.RUnlock()
+	for _, p := range providers {
+		for _, pk := range p.f(s) {
+			_ = s.client.WritePacket(pk)
+		}
+	}
+}
+
+func init() {
+	OnStart((*Session).RefreshUI)
+}
+
+// restoreUI restores the UI of the proxy after the Session was attached to another server.
+func (s *Session) restoreUI() {
+	s.sidebar.reattach(s)
+	s.scoreboard.reattach(s)
+	s.bossBar.restore(s)
+	s.RefreshUI()
+}
+
+// Title is a title shown to a player using Session.SendTitle.
+type Title struct {
+	// Text is the title shown in the middle of the screen. Subtitle is shown below it and ActionBar above the
+	// hotbar. Empty parts are not shown.
+	Text, Subtitle, ActionBar string
+	// FadeIn, Duration and FadeOut are the durations that the title takes to fade in, is shown for and takes to fade
+	// out. If all are zero, the durations of the client are kept.
+	FadeIn, Duration, FadeOut time.Duration
+}
+
+// titleTicks converts the duration passed to the ticks of a SetTitle packet.
+func titleTicks(d time.Duration) int32 {
+	return int32(d / (time.Second / 20))
+}
+
+// SendTitle shows the Title passed to the client of the Session. The backend is not aware of the title, which may be
+// replaced by titles that it sends.
+func (s *Session) SendTitle(t Title) {
+	if t.FadeIn != 0 || t.Duration != 0 || t.FadeOut != 0 {
+		_ = s.client.WritePacket(&packet.SetTitle{
+			ActionType:      packet.TitleActionSetDurations,
+			FadeInDuration:  titleTicks(t.FadeIn),
+			RemainDuration:  titleTicks(t.Duration),
+			FadeOutDuration: titleTicks(t.FadeOut),
+		})
+	}
+	// The subtitle must be sent before the title, as the client only shows it once the title is set.
+	if t.Subtitle != "" {
+		_ = s.client.WritePacket(&packet.SetTitle{ActionType: packet.TitleActionSetSubtitle, Text: t.Subtitle})
+	}
+	if t.Text != "" {
+		_ = s.client.WritePacket(&packet.SetTitle{ActionType: packet.TitleActionSetTitle, Text: t.Text})
+	}
+	if t.ActionBar != "" {
+		_ = s.client.WritePacket(&packet.SetTitle{ActionType: packet.TitleActionSetActionBar, Text: t.ActionBar})
+	}
+}
+
+// SendToast shows a short notification with the title and the content passed to the client of the Session. Clients
+// of this protocol have no toast notifications, so it is shown as a popup above the hotbar instead, with the title
+// on the first line.
+func (s *Session) SendToast(title, content string) {
+	_ = s.client.WritePacket(&packet.Text{TextType: packet.TextTypePopup, Message: title + "\n" + content})
+}
+
+// proxyBossBar is the boss bar shown by the proxy using Session.ShowBossBar. The player itself is the boss entity of
+// the boss bar, as the client only shows boss bars of entities it knows, so a Session has at most one.
+type proxyBossBar struct {
+	mu      sync.Mutex
+	visible bool
+	title   string
+	health  float32
+}
+
+// ShowBossBar shows a boss bar owned by the proxy with the title and the health, between 0 and 1, passed to the client
+// of the Session, or updates it if it is shown already. It is shown again after the Session is attached to another
+// server, until it is hidden using HideBossBar. A boss bar that the backend shows for the player itself replaces it.
+func (s *Session) ShowBossBar(title string, health float32) {
+	s.bossBar.mu.Lock()
+	s.bossBar.visible, s.bossBar.title, s.bossBar.health = true, title, health
+	s.bossBar.mu.Unlock()
+	s.bossBar.restore(s)
+}
+
+// HideBossBar hides the boss bar shown using ShowBossBar.
+func (s *Session) HideBossBar() {
+	s.bossBar.mu.Lock()
+	visible := s.bossBar.visible
+	s.bossBar.visible = false
+	s.bossBar.mu.Unlock()
+	if visible {
+		_ = s.client.WritePacket(&packet.BossEvent{BossEntityUniqueID: s.playerUniqueID(), EventType: packet.BossEventHide})
+	}
+}
+
+// restore shows the proxyBossBar to the client of the Session passed, if it is visible.
+func (b *proxyBossBar) restore(s *Session) {
+	b.mu.Lock()
+	visible, title, health := b.visible, b.title, b.health
+	b.mu.Unlock()
+	if visible {
+		_ = s.client.WritePacket(&packet.BossEvent{BossEntityUniqueID: s.playerUniqueID(), EventType: packet.BossEventShow, BossBarTitle: title, HealthPercentage: health})
+	}
+}
+
+// playerUniqueID returns the entity unique ID of the player as known by its client.
+func (s *Session) playerUniqueID() int64 {
+	if data, ok := gameData(s.client); ok {
+		return data.EntityUniqueID
+	}
+	return 0
+}
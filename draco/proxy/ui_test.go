@@ -0,0 +1,31 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestRestoreUI(t *testing.T) {
+	RegisterUI("test", func(s *Session) []packet.Packet {
+		if s.Role() == RoleGuest {
+			return nil
+		}
+		return []packet.Packet{&packet.BossEvent{BossEntityUniqueID: 1, EventType: packet.BossEventShow, BossBarTitle: "Welcome"}}
+	})
+	defer RegisterUI("test", func(*Session) []packet.Packet { return nil })
+
+	conn := &recordConn{}
+	s := NewSession(conn, conn, Backend{})
+	s.RefreshUI()
+	if len(conn.packets) != 1 {
+		t.Fatalf("%v packets sent to the client, expected 1", len(conn.packets))
+	}
+	s.attached()
+	if len(conn.packets) != 2 {
+		t.Fatalf("UI was not restored after attaching the session to another server: %v packets sent", len(conn.packets))
+	}
+	if pk, ok := conn.packets[1].(*packet.BossEvent); !ok || pk.BossBarTitle != "Welcome" {
+		t.Errorf("unexpected packet %#v sent after attaching", conn.packets[1])
+	}
+}
@@ -0,0 +1,243 @@
+package proxy
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// world tracks what the server that a Session is attached to has shown to the client, so that it can be removed
+// again once the Session is attached to another server.
+type world struct {
+	mu sync.Mutex
+	// entities holds the unique IDs of all entities spawned, players holds the UUIDs of all player list entries
+	// added and objectives holds the names of all scoreboard objectives displayed.
+	entities   map[int64]struct{}
+	players    map[uuid.UUID]struct{}
+	objectives map[string]struct{}
+	// position is the last position of the player sent by the client, moved the time it was sent, and time the last
+	// time of day sent by the server. dimension is the dimension that the client is in.
+	position  mgl32.Vec3
+	moved     time.Time
+	time      int32
+	dimension int32
+	// acks is the amount of dimension changes done by the proxy that the client has yet to acknowledge. The server
+	// doesn't know of these dimension changes, so their acknowledgements are not forwarded to it.
+	acks int
+}
+
+// newWorld returns an empty world of the client passed.
+func newWorld(client Conn) *world {
+	w := &world{entities: map[int64]struct{}{}, players: map[uuid.UUID]struct{}{}, objectives: map[string]struct{}{}}
+	if data, ok := gameData(client); ok {
+		w.position, w.time, w.dimension = data.PlayerPosition, int32(data.Time), data.Dimension
+	}
+	return w
+}
+
+func init() {
+	Handle(ServerToClient, func(s *Session, pk *packet.AddActor) Action { return s.world.spawn(pk.EntityUniqueID) })
+	Handle(ServerToClient, func(s *Session, pk *packet.AddPlayer) Action { return s.world.spawn(pk.EntityUniqueID) })
+	Handle(ServerToClient, func(s *Session, pk *packet.AddItemActor) Action { return s.world.spawn(pk.EntityUniqueID) })
+	Handle(ServerToClient, func(s *Session, pk *packet.AddPainting) Action { return s.world.spawn(pk.EntityUniqueID) })
+	Handle(ServerToClient, func(s *Session, pk *packet.RemoveActor) Action {
+		s.world.mu.Lock()
+		defer s.world.mu.Unlock()
+		delete(s.world.entities, pk.EntityUniqueID)
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.PlayerList) Action {
+		s.world.mu.Lock()
+		defer s.world.mu.Unlock()
+		for _, e := range pk.Entries {
+			if pk.ActionType == packet.PlayerListActionAdd {
+				s.world.players[e.UUID] = struct{}{}
+			} else {
+				delete(s.world.players, e.UUID)
+			}
+		}
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.SetDisplayObjective) Action {
+		s.world.mu.Lock()
+		defer s.world.mu.Unlock()
+		s.world.objectives[pk.ObjectiveName] = struct{}{}
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.RemoveObjective) Action {
+		s.world.mu.Lock()
+		defer s.world.mu.Unlock()
+		delete(s.world.objectives, pk.ObjectiveName)
+		return Forward
+	})
+	Handle(ClientToServer, func(s *Session, pk *packet.PlayerAuthInput) Action { return s.world.move(pk.Position) })
+	Handle(ClientToServer, func(s *Session, pk *packet.MovePlayer) Action { return s.world.move(pk.Position) })
+	Handle(ServerToClient, func(s *Session, pk *packet.SetTime) Action {
+		s.world.mu.Lock()
+		defer s.world.mu.Unlock()
+		s.world.time = pk.Time
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.ChangeDimension) Action {
+		s.world.mu.Lock()
+		defer s.world.mu.Unlock()
+		s.world.dimension = pk.Dimension
+		return Forward
+	})
+	Handle(ClientToServer, func(s *Session, pk *packet.PlayerAction) Action {
+		if pk.ActionType != protocol.PlayerActionDimensionChangeDone {
+			return Forward
+		}
+		s.world.mu.Lock()
+		if s.world.acks == 0 {
+			s.world.mu.Unlock()
+			return Forward
+		}
+		s.world.acks--
+		done := s.world.acks == 0
+		s.world.mu.Unlock()
+		if done {
+			s.abilities.changedWorld(s)
+		}
+		return Drop
+	})
+}
+
+// spawn remembers the entity with the unique ID passed.
+func (w *world) spawn(id int64) Action {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.entities[id] = struct{}{}
+	return Forward
+}
+
+// changing checks if the client is changing dimension because of the proxy, during which it ignores its abilities.
+func (w *world) changing() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.acks > 0
+}
+
+// move remembers the position of the player passed.
+func (w *world) move(pos mgl32.Vec3) Action {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.position, w.moved = pos, time.Now()
+	return Forward
+}
+
+// clear removes all entities, player list entries and scoreboard objectives of the previous server from the client
+// of the Session passed.
+func (w *world) clear(s *Session) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for id := range w.entities {
+		_ = s.client.WritePacket(&packet.RemoveActor{EntityUniqueID: id})
+	}
+	if len(w.players) > 0 {
+		pk := &packet.PlayerList{ActionType: packet.PlayerListActionRemove}
+		for id := range w.players {
+			pk.Entries = append(pk.Entries, protocol.PlayerListEntry{UUID: id})
+		}
+		_ = s.client.WritePacket(pk)
+	}
+	for name := range w.objectives {
+		_ = s.client.WritePacket(&packet.RemoveObjective{ObjectiveName: name})
+	}
+	w.entities, w.players, w.objectives = map[int64]struct{}{}, map[uuid.UUID]struct{}{}, map[string]struct{}{}
+}
+
+// entityIDs holds the entity IDs of the player of a Session as known by the client and by the server that the
+// Session is attached to. The client keeps the IDs of the first server it joined, so after a transfer the IDs of
+// the player on the new server are swapped with those the client knows in all packets.
+type entityIDs struct {
+	clientRuntimeID, serverRuntimeID uint64
+	clientUniqueID, serverUniqueID   int64
+}
+
+// newEntityIDs returns the entityIDs of a Session with the client and server passed. If the IDs of either are not
+// known, such as for Sources, entity IDs are not swapped.
+func newEntityIDs(client, server Conn) entityIDs {
+	c, ok := gameData(client)
+	if !ok {
+		return entityIDs{}
+	}
+	srv, ok := gameData(server)
+	if !ok {
+		return entityIDs{}
+	}
+	return entityIDs{
+		clientRuntimeID: c.EntityRuntimeID, serverRuntimeID: srv.EntityRuntimeID,
+		clientUniqueID: c.EntityUniqueID, serverUniqueID: srv.EntityUniqueID,
+	}
+}
+
+// entityIDs returns the entityIDs of the server that the Session is currently attached to.
+func (s *Session) entityIDs() entityIDs {
+	s.connMu.RLock()
+	defer s.connMu.RUnlock()
+	return s.ids
+}
+
+// swap swaps the entity IDs of the player on the server with those known by the client, in either direction, in
+// the packet passed. Entity IDs are found by the names of the fields of a packet and of the structs and slices in it:
+// fields ending in RuntimeID of type uint64 and fields ending in UniqueID. Entity IDs in entity metadata are not
+// swapped.
+func (ids entityIDs) swap(pk packet.Packet) {
+	if ids.clientRuntimeID == ids.serverRuntimeID && ids.clientUniqueID == ids.serverUniqueID {
+		return
+	}
+	ids.swapValue(reflect.ValueOf(pk))
+}
+
+// swapValue swaps the entity IDs in the value passed.
+func (ids entityIDs) swapValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			ids.swapValue(v.Elem())
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			ids.swapValue(v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			f, name := v.Field(i), t.Field(i).Name
+			if !f.CanSet() {
+				continue
+			}
+			switch {
+			case f.Kind() == reflect.Uint64 && strings.HasSuffix(name, "RuntimeID"):
+				f.SetUint(swapID(f.Uint(), ids.clientRuntimeID, ids.serverRuntimeID))
+			case f.Kind() == reflect.Int64 && strings.HasSuffix(name, "UniqueID"):
+				f.SetInt(swapID(f.Int(), ids.clientUniqueID, ids.serverUniqueID))
+			case f.Kind() == reflect.Uint64 && strings.HasSuffix(name, "UniqueID"):
+				f.SetUint(uint64(swapID(int64(f.Uint()), ids.clientUniqueID, ids.serverUniqueID)))
+			default:
+				ids.swapValue(f)
+			}
+		}
+	}
+}
+
+// swapID returns b if the ID passed is a, a if it is b, or the ID itself otherwise.
+func swapID[T comparable](id, a, b T) T {
+	switch id {
+	case a:
+		return b
+	case b:
+		return a
+	}
+	return id
+}
@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// WorldTime controls the time of day that clients see on a Backend, without changing the time of the backend
+// itself. While the time is controlled, the daylight cycle of the client is disabled and the proxy sends the time
+// to the client itself, so that it never drifts from the time it should show.
+type WorldTime struct {
+	// Freeze freezes the time shown to clients at Time.
+	Freeze bool
+	// Time is the time, in ticks, that the time is frozen at if Freeze is true. 6000 is noon and 18000 is
+	// midnight.
+	Time int64
+	// Scale is the speed at which time passes for clients relative to the backend. A Scale of 0.5 makes days
+	// last twice as long. If 0 or 1, and Freeze is false, the time is not controlled by the proxy.
+	Scale float64
+}
+
+// controlled checks if the WorldTime changes the time shown to clients.
+func (t WorldTime) controlled() bool {
+	return t.Freeze || (t.Scale != 0 && t.Scale != 1)
+}
+
+const (
+	// worldTimeInterval is the interval at which the proxy sends the time to clients of backends with a controlled
+	// WorldTime.
+	worldTimeInterval = time.Second
+	// worldTimeSlack is the amount of ticks that a time sent by the backend may differ from the time it is expected
+	// to have before it is considered to be set, for example using /time set, rather than merely synchronised.
+	worldTimeSlack = 40
+	// tick is the duration of a single tick, during which the time of a world advances by one.
+	tick = time.Second / 20
+	// daylightCycleRule is the name of the game rule that enables the daylight cycle.
+	daylightCycleRule = "dodaylightcycle"
+)
+
+// WorldTimeGameData changes the game data passed, which is sent to the client in the StartGame packet, to hold the
+// time that clients should see on the Backend passed, disabling the daylight cycle of the client if the time of
+// the backend is controlled.
+func WorldTimeGameData(data minecraft.GameData, b Backend) minecraft.GameData {
+	if !b.Time.controlled() {
+		return data
+	}
+	if b.Time.Freeze {
+		data.Time = b.Time.Time
+	}
+	rules := make([]protocol.GameRule, 0, len(data.GameRules)+1)
+	for _, rule := range data.GameRules {
+		if !strings.EqualFold(rule.Name, daylightCycleRule) {
+			rules = append(rules, rule)
+		}
+	}
+	data.GameRules = append(rules, protocol.GameRule{Name: daylightCycleRule, Value: false})
+	return data
+}
+
+// worldClock keeps track of the time of the backend of a single Session and of the time shown to its client. The
+// time of the backend between two SetTime packets is estimated from the time passed since, as long as its
+// daylight cycle is enabled.
+type worldClock struct {
+	conf WorldTime
+
+	mu sync.Mutex
+	// cycle specifies if the daylight cycle of the backend is enabled.
+	cycle bool
+	// backendTime and clientTime are the time of the backend and the time shown to the client at the moment
+	// specified by at.
+	backendTime int64
+	clientTime  float64
+	at          time.Time
+}
+
+// newWorldClock returns the worldClock of a new Session connected to the Backend and server passed, or nil if the
+// time of the backend is not controlled.
+func newWorldClock(b Backend, server Conn) *worldClock {
+	if !b.Time.controlled() {
+		return nil
+	}
+	c := &worldClock{conf: b.Time, cycle: true, at: time.Now()}
+	if data, ok := gameData(server); ok {
+		c.backendTime, c.clientTime = data.Time, float64(data.Time)
+		for _, rule := range data.GameRules {
+			if v, ok := rule.Value.(bool); ok && strings.EqualFold(rule.Name, daylightCycleRule) {
+				c.cycle = v
+			}
+		}
+	}
+	return c
+}
+
+func init() {
+	Handle(ServerToClient, func(s *Session, pk *packet.SetTime) Action {
+		c := s.worldClock()
+		if c == nil {
+			return Forward
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.advance(time.Now())
+		if diff := int64(pk.Time) - c.backendTime; diff > worldTimeSlack || diff < -worldTimeSlack {
+			// The time was set by the backend, so the client jumps to the new time too.
+			c.clientTime = float64(pk.Time)
+		}
+		c.backendTime = int64(pk.Time)
+		pk.Time = int32(c.now())
+		return Forward
+	})
+	Handle(ServerToClient, func(s *Session, pk *packet.GameRulesChanged) Action {
+		c := s.worldClock()
+		if c == nil {
+			return Forward
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, rule := range pk.GameRules {
+			if v, ok := rule.Value.(bool); ok && strings.EqualFold(rule.Name, daylightCycleRule) {
+				c.advance(time.Now())
+				c.cycle = v
+				pk.GameRules[i].Value = false
+			}
+		}
+		return Forward
+	})
+}
+
+// worldClock returns the worldClock of the backend that the Session is currently attached to, or nil if its time
+// is not controlled.
+func (s *Session) worldClock() *worldClock {
+	s.connMu.RLock()
+	defer s.connMu.RUnlock()
+	return s.clock
+}
+
+// advance moves the clock forward to the moment passed. c.mu must be held when calling advance.
+func (c *worldClock) advance(t time.Time) {
+	if !c.cycle {
+		c.at = t
+		return
+	}
+	// Only whole ticks passed are counted, so that the remainder is counted in the next call.
+	ticks := t.Sub(c.at) / tick
+	c.backendTime += int64(ticks)
+	c.clientTime += float64(ticks) * c.conf.Scale
+	c.at = c.at.Add(ticks * tick)
+}
+
+// now returns the time that the client should currently see. c.mu must be held when calling now.
+func (c *worldClock) now() int64 {
+	if c.conf.Freeze {
+		return c.conf.Time
+	}
+	return int64(c.clientTime)
+}
+
+// run sends the time to the client of the Session passed every worldTimeInterval until the Session is closed or
+// attached to another server, so that its time doesn't drift while its own daylight cycle is disabled.
+func (c *worldClock) run(s *Session) {
+	t := time.NewTicker(worldTimeInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-s.closed:
+			return
+		}
+		if s.worldClock() != c {
+			return
+		}
+		c.mu.Lock()
+		c.advance(time.Now())
+		pk := &packet.SetTime{Time: int32(c.now())}
+		c.mu.Unlock()
+		if err := s.client.WritePacket(pk); err != nil {
+			return
+		}
+	}
+}
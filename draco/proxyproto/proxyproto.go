@@ -0,0 +1,189 @@
+// Package proxyproto implements the sending side of version 2 of the HAProxy PROXY protocol for RakNet connections,
+// so that backends see the address that players connected to the proxy from rather than that of the proxy.
+// gophertunnel doesn't expose the socket that it dials backends with, so connections are dialed through a Relay
+// instead, which prefixes every datagram it forwards to the backend with a header holding the address of the player.
+package proxyproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// signature is the signature that every PROXY protocol v2 header starts with.
+var signature = []byte{0x0d, 0x0a, 0x0d, 0x0a, 0x00, 0x0d, 0x0a, 0x51, 0x55, 0x49, 0x54, 0x0a}
+
+const (
+	// cmdLocal and cmdProxy are the version and command bytes of a header: LOCAL headers hold no addresses, PROXY
+	// headers hold those of the original connection.
+	cmdLocal, cmdProxy = 0x20, 0x21
+	// familyIPv4 and familyIPv6 are the address family and protocol bytes of a header holding the UDP addresses of
+	// either family.
+	familyIPv4, familyIPv6 = 0x12, 0x22
+)
+
+// Header returns the PROXY protocol v2 header of a datagram sent from the source address to the destination address
+// passed. If either is not a *net.UDPAddr, a LOCAL header is returned, which backends handle as if no header was
+// sent. If only one of the addresses is an IPv4 address, both are encoded as IPv6 addresses.
+func Header(src, dst net.Addr) []byte {
+	s, ok := src.(*net.UDPAddr)
+	d, ok2 := dst.(*net.UDPAddr)
+	if !ok || !ok2 {
+		return append(append([]byte(nil), signature...), cmdLocal, 0, 0, 0)
+	}
+	family, srcIP, dstIP := byte(familyIPv4), s.IP.To4(), d.IP.To4()
+	if srcIP == nil || dstIP == nil {
+		family, srcIP, dstIP = familyIPv6, ip16(s.IP), ip16(d.IP)
+	}
+	b := append(append([]byte(nil), signature...), cmdProxy, family, 0, 0)
+	b = append(append(b, srcIP...), dstIP...)
+	b = append(b, byte(s.Port>>8), byte(s.Port), byte(d.Port>>8), byte(d.Port))
+	binary.BigEndian.PutUint16(b[14:], uint16(len(b)-16))
+	return b
+}
+
+// ip16 returns the 16-byte form of the IP passed, or the unspecified IPv6 address if it is not a valid IP.
+func ip16(ip net.IP) net.IP {
+	if ip = ip.To16(); ip == nil {
+		return net.IPv6unspecified
+	}
+	return ip
+}
+
+// idleTimeout is the duration after which a Relay stops relaying the datagrams of a connection that neither end
+// sent datagrams over, and after which a Relay without connections closes itself.
+const idleTimeout = time.Second * 10
+
+// maxDatagramSize is the maximum size of datagrams relayed.
+const maxDatagramSize = 1500
+
+// Relay relays the datagrams of the connections dialed to its address to a backend, prefixing every datagram sent
+// to the backend with the PROXY protocol header of a player. Every connection dialed to the address of the Relay is
+// relayed over its own socket, so the ping that gophertunnel sends before dialing is relayed too. A Relay closes
+// itself once no datagrams were relayed for a while, such as after the connection dialed through it was closed.
+type Relay struct {
+	conn    *net.UDPConn
+	backend *net.UDPAddr
+	header  []byte
+
+	mu    sync.Mutex
+	peers map[string]*net.UDPConn
+
+	once   sync.Once
+	closed chan struct{}
+}
+
+// Listen starts a Relay that relays connections to the backend with the address passed on behalf of a player that
+// connected from the address src. Connections relayed should be dialed to the address returned by Relay.Addr.
+func Listen(backend string, src net.Addr) (*Relay, error) {
+	addr, err := net.ResolveUDPAddr("udp", backend)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return nil, err
+	}
+	r := &Relay{
+		conn:    conn,
+		backend: addr,
+		header:  Header(src, addr),
+		peers:   map[string]*net.UDPConn{},
+		closed:  make(chan struct{}),
+	}
+	go r.relay()
+	return r, nil
+}
+
+// Addr returns the address that connections relayed by the Relay must be dialed to.
+func (r *Relay) Addr() string {
+	return r.conn.LocalAddr().String()
+}
+
+// Close stops relaying all connections.
+func (r *Relay) Close() error {
+	r.once.Do(func() {
+		close(r.closed)
+		_ = r.conn.Close()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for addr, upstream := range r.peers {
+			_ = upstream.Close()
+			delete(r.peers, addr)
+		}
+	})
+	return nil
+}
+
+// relay relays the datagrams read from connections dialed to the Relay to the backend until the Relay is closed.
+func (r *Relay) relay() {
+	defer r.Close()
+	b := make([]byte, len(r.header)+maxDatagramSize)
+	copy(b, r.header)
+	for {
+		_ = r.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		n, addr, err := r.conn.ReadFromUDP(b[len(r.header):])
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			r.mu.Lock()
+			idle := len(r.peers) == 0
+			r.mu.Unlock()
+			if idle {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			return
+		}
+		upstream, err := r.upstream(addr)
+		if err != nil {
+			continue
+		}
+		_, _ = upstream.Write(b[:len(r.header)+n])
+	}
+}
+
+// upstream returns the socket that datagrams read from the address passed are relayed to the backend over, dialing
+// one if no datagrams were read from the address before.
+func (r *Relay) upstream(addr *net.UDPAddr) (*net.UDPConn, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if upstream, ok := r.peers[addr.String()]; ok {
+		return upstream, nil
+	}
+	select {
+	case <-r.closed:
+		return nil, net.ErrClosed
+	default:
+	}
+	upstream, err := net.DialUDP("udp", nil, r.backend)
+	if err != nil {
+		return nil, err
+	}
+	r.peers[addr.String()] = upstream
+	go r.receive(addr, upstream)
+	return upstream, nil
+}
+
+// receive relays the datagrams read from the backend over the upstream socket passed back to the address passed,
+// until the backend sends no datagrams for idleTimeout.
+func (r *Relay) receive(addr *net.UDPAddr, upstream *net.UDPConn) {
+	defer func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		_ = upstream.Close()
+		delete(r.peers, addr.String())
+	}()
+	b := make([]byte, maxDatagramSize)
+	for {
+		_ = upstream.SetReadDeadline(time.Now().Add(idleTimeout))
+		n, err := upstream.Read(b)
+		if err != nil {
+			return
+		}
+		_, _ = r.conn.WriteToUDP(b[:n], addr)
+	}
+}
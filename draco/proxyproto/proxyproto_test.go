@@ -0,0 +1,66 @@
+package proxyproto
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHeader(t *testing.T) {
+	h := Header(&net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 19132}, &net.UDPAddr{IP: net.IPv4(5, 6, 7, 8), Port: 19133})
+	want := append(append([]byte(nil), signature...), 0x21, 0x12, 0, 12, 1, 2, 3, 4, 5, 6, 7, 8, 0x4a, 0xbc, 0x4a, 0xbd)
+	if !bytes.Equal(h, want) {
+		t.Fatalf("IPv4 header %x, expected %x", h, want)
+	}
+	h = Header(&net.UDPAddr{IP: net.ParseIP("::1"), Port: 1}, &net.UDPAddr{IP: net.IPv4(5, 6, 7, 8), Port: 2})
+	if h[13] != 0x22 || len(h) != 16+36 {
+		t.Fatalf("mixed header has family %x and length %v, expected IPv6", h[13], len(h))
+	}
+	if h := Header(&net.TCPAddr{}, &net.UDPAddr{}); h[12] != 0x20 || len(h) != 16 {
+		t.Fatalf("header of non-UDP address %x is not a LOCAL header", h)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	backend, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	src := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}
+	r, err := Listen(backend.LocalAddr().String(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	client, err := net.Dial("udp", r.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	_ = client.SetDeadline(time.Now().Add(time.Second * 5))
+	_ = backend.SetDeadline(time.Now().Add(time.Second * 5))
+
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 1500)
+	n, addr, err := backend.ReadFromUDP(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(Header(src, backend.LocalAddr()), "ping"...); !bytes.Equal(b[:n], want) {
+		t.Fatalf("backend read %x, expected %x", b[:n], want)
+	}
+	if _, err := backend.WriteToUDP([]byte("pong"), addr); err != nil {
+		t.Fatal(err)
+	}
+	if n, err = client.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:n]) != "pong" {
+		t.Fatalf("client read %q, expected pong", b[:n])
+	}
+}
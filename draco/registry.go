@@ -0,0 +1,55 @@
+package draco
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+)
+
+var (
+	// protocolMu guards protocols.
+	protocolMu sync.RWMutex
+	// protocols holds all protocols that the proxy accepts connections with, keyed by their protocol ID. The latest
+	// protocol is always accepted by gophertunnel and is not in the registry.
+	protocols = map[int32]minecraft.Protocol{Protocol{}.ID(): Protocol{}}
+)
+
+// RegisterProtocol registers a protocol that clients may join the proxy with, such as a translation layer for an
+// older version. The protocol used for a connection is selected from the registered protocols using the protocol
+// ID that the client sends when logging in. Registering a protocol with the ID of a registered one replaces it.
+// The protocol of draco itself, Protocol, is registered by default. RegisterProtocol must be called before the
+// proxy starts listening.
+func RegisterProtocol(p minecraft.Protocol) {
+	protocolMu.Lock()
+	defer protocolMu.Unlock()
+	protocols[p.ID()] = p
+}
+
+// Protocols returns all protocols registered using RegisterProtocol, ordered from the newest to the oldest. It
+// should be used as the AcceptedProtocols of the listener that clients join. Protocols with a shadow registered using
+// RegisterShadow translate packets with their shadow as well.
+func Protocols() []minecraft.Protocol {
+	protocolMu.RLock()
+	defer protocolMu.RUnlock()
+	all := make([]minecraft.Protocol, 0, len(protocols))
+	for _, p := range protocols {
+		all = append(all, withShadow(p))
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].ID() > all[j].ID()
+	})
+	return all
+}
+
+// ProtocolByID looks up a protocol registered using RegisterProtocol by its protocol ID. False is returned if no
+// protocol with the ID passed is registered.
+func ProtocolByID(id int32) (minecraft.Protocol, bool) {
+	protocolMu.RLock()
+	defer protocolMu.RUnlock()
+	p, ok := protocols[id]
+	if !ok {
+		return nil, false
+	}
+	return withShadow(p), true
+}
@@ -0,0 +1,149 @@
+// Package rejected records the protocols of clients that were turned away because the proxy does not support their
+// version, so that operators can tell when enough players are on a newer version to prioritise translating it.
+package rejected
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// days is the amount of days that the daily counts of a protocol are kept for.
+const days = 30
+
+// Entry holds the rejections of clients with one protocol.
+type Entry struct {
+	// Protocol is the protocol ID that the clients logged in with.
+	Protocol int32 `json:"protocol"`
+	// Newer is true if the protocol is newer than the newest protocol supported by the proxy.
+	Newer bool `json:"newer"`
+	// Total is the amount of clients rejected with the protocol since the proxy started or the report was reset.
+	Total int64 `json:"total"`
+	// FirstSeen and LastSeen are the times at which a client with the protocol was first and last rejected.
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// Daily holds the amount of clients rejected with the protocol per day, in UTC, for the days on which any were
+	// rejected within the last 30 days, oldest first.
+	Daily []Day `json:"daily"`
+}
+
+// Day is the amount of clients rejected with a protocol on one day.
+type Day struct {
+	// Date is the day in UTC, such as "2022-05-01".
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+// entry is the Entry of a protocol as it is recorded, with the daily counts keyed by the day since the Unix epoch.
+type entry struct {
+	total               int64
+	firstSeen, lastSeen time.Time
+	daily               map[int64]int64
+}
+
+var (
+	// mu guards entries.
+	mu sync.Mutex
+	// entries holds the rejections recorded, keyed by protocol ID.
+	entries = map[int32]*entry{}
+)
+
+// Observe returns a function with the signature of minecraft.ListenConfig.PacketFunc that records the protocol of
+// every client that logs in with a protocol other than those accepted passed and the current protocol, which are the
+// protocols that a minecraft.Listener with the accepted protocols as AcceptedProtocols turns clients away for.
+func Observe(accepted []minecraft.Protocol) func(header packet.Header, payload []byte, src, dst net.Addr) {
+	ids := map[int32]struct{}{protocol.CurrentProtocol: {}}
+	for _, p := range accepted {
+		ids[p.ID()] = struct{}{}
+	}
+	return func(header packet.Header, payload []byte, _, _ net.Addr) {
+		id, ok := draco.LoginProtocol(header, payload)
+		if !ok {
+			return
+		}
+		if _, ok := ids[id]; !ok {
+			record(id, time.Now())
+		}
+	}
+}
+
+// record records a client rejected with the protocol passed at the time passed.
+func record(id int32, now time.Time) {
+	metrics.AddTo("rejected_protocols", strconv.Itoa(int(id)), 1)
+
+	mu.Lock()
+	defer mu.Unlock()
+	e, ok := entries[id]
+	if !ok {
+		e = &entry{firstSeen: now, daily: map[int64]int64{}}
+		entries[id] = e
+		if id > protocol.CurrentProtocol {
+			logging.Default().Info("rejected a client with a newer protocol for the first time", "protocol", id)
+		}
+	}
+	day := now.Unix() / 86400
+	for d := range e.daily {
+		if d <= day-days {
+			delete(e.daily, d)
+		}
+	}
+	e.total++
+	e.lastSeen = now
+	e.daily[day]++
+}
+
+// Report returns an Entry for every protocol that clients were rejected with, newest protocol first.
+func Report() []Entry {
+	mu.Lock()
+	defer mu.Unlock()
+	report := make([]Entry, 0, len(entries))
+	for id, e := range entries {
+		r := Entry{Protocol: id, Newer: id > protocol.CurrentProtocol, Total: e.total, FirstSeen: e.firstSeen, LastSeen: e.lastSeen}
+		for d, n := range e.daily {
+			r.Daily = append(r.Daily, Day{Date: time.Unix(d*86400, 0).UTC().Format("2006-01-02"), Count: n})
+		}
+		sort.Slice(r.Daily, func(i, j int) bool {
+			return r.Daily[i].Date < r.Daily[j].Date
+		})
+		report = append(report, r)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Protocol > report[j].Protocol
+	})
+	return report
+}
+
+// Reset forgets all rejections recorded. The counters in the metrics are kept.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	entries = map[int32]*entry{}
+}
+
+// Handler returns an http.Handler serving the report. GET requests are responded to with the report as a JSON list
+// of entries, and DELETE requests reset it.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(Report())
+		case http.MethodDelete:
+			Reset()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package rejected
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestObserve(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	login := func(id int32) []byte {
+		payload := make([]byte, 8)
+		binary.BigEndian.PutUint32(payload, uint32(id))
+		return payload
+	}
+	observe := Observe(nil)
+	observe(packet.Header{PacketID: packet.IDLogin}, login(protocol.CurrentProtocol), nil, nil)
+	observe(packet.Header{PacketID: packet.IDText}, login(protocol.CurrentProtocol+1), nil, nil)
+	before := metrics.Group("rejected_protocols")["9999"]
+	for i := 0; i < 3; i++ {
+		observe(packet.Header{PacketID: packet.IDLogin}, login(9999), nil, nil)
+	}
+	if n := metrics.Group("rejected_protocols")["9999"] - before; n != 3 {
+		t.Errorf("expected 3 rejections to be counted, got %v", n)
+	}
+
+	report := Report()
+	if len(report) != 1 {
+		t.Fatalf("expected only the unsupported protocol to be reported, got %+v", report)
+	}
+	if e := report[0]; e.Protocol != 9999 || !e.Newer || e.Total != 3 || len(e.Daily) != 1 || e.Daily[0].Count != 3 {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
+
+func TestRecordDaily(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	record(400, start)
+	record(400, start.Add(time.Hour*24))
+	record(400, start.Add(time.Hour*25))
+	record(100, start)
+
+	report := Report()
+	if len(report) != 2 || report[0].Protocol != 400 || report[1].Protocol != 100 {
+		t.Fatalf("expected the newest protocol first, got %+v", report)
+	}
+	daily := report[0].Daily
+	if len(daily) != 2 || daily[0] != (Day{Date: "2022-05-01", Count: 1}) || daily[1] != (Day{Date: "2022-05-02", Count: 2}) {
+		t.Errorf("unexpected daily counts %+v", daily)
+	}
+	if report[0].Newer || !report[0].FirstSeen.Equal(start) || !report[0].LastSeen.Equal(start.Add(time.Hour*25)) {
+		t.Errorf("unexpected entry %+v", report[0])
+	}
+
+	// Days older than 30 days are forgotten once another rejection is recorded.
+	record(400, start.Add(time.Hour*24*31))
+	if daily := Report()[0].Daily; len(daily) != 1 || daily[0].Date != "2022-06-01" {
+		t.Errorf("expected old days to be forgotten, got %+v", daily)
+	}
+}
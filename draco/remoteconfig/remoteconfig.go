@@ -0,0 +1,273 @@
+// Package remoteconfig obtains the config of the proxy from a remote endpoint, such as an HTTP server, Consul or
+// etcd, so that a fleet of proxies shares one config without distributing files. Changes to the config are picked up
+// by watching the endpoint, and the config obtained last is cached on disk, so that a proxy still starts if the
+// endpoint can't be reached.
+package remoteconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/logging"
+)
+
+const (
+	// KindHTTP is the Kind of Sources fetched using GET requests to their URL. The body of the response is the
+	// document, and responses are cached using their ETag.
+	KindHTTP = "http"
+	// KindConsul is the Kind of Sources holding the document in the value of Key in the KV store of the Consul agent
+	// at their URL. Changes are watched using blocking queries.
+	KindConsul = "consul"
+	// KindEtcd is the Kind of Sources holding the document in the value of Key in the etcd cluster at their URL,
+	// which is read through the JSON gateway of etcd v3.
+	KindEtcd = "etcd"
+)
+
+// Source is a remote endpoint holding a document, which is the config of the proxy as it is written to config.toml.
+type Source struct {
+	// Kind is the kind of the endpoint: KindHTTP, KindConsul or KindEtcd.
+	Kind string
+	// URL is the URL of the endpoint. For KindConsul and KindEtcd, it is the base URL of the agent or cluster, such
+	// as http://127.0.0.1:8500.
+	URL string
+	// Key is the key holding the document for KindConsul and KindEtcd.
+	Key string
+	// Token authenticates the requests to the endpoint, if set. It is sent as a bearer token, or as the X-Consul-Token
+	// header for KindConsul.
+	Token string
+	// Interval is the interval at which the endpoint is polled for changes. For KindConsul, it is the maximum
+	// duration of a blocking query instead.
+	Interval time.Duration
+	// Client is the HTTP client used to fetch the endpoint. If nil, a client with a timeout of 10 seconds more than
+	// Interval is used.
+	Client *http.Client
+}
+
+// Document is a document of a Source.
+type Document struct {
+	// Data is the content of the document.
+	Data []byte
+	// Version identifies the content of the document, so that it is only fetched again once it changed.
+	Version string
+	// Cached specifies if the document was loaded from the cache, as the Source couldn't be reached.
+	Cached bool
+}
+
+// maxDocumentSize is the maximum size of a Document.
+const maxDocumentSize = 4 << 20
+
+// Validate checks if the Source is valid, returning an error describing the problem if not.
+func (src Source) Validate() error {
+	switch src.Kind {
+	case KindHTTP, KindConsul, KindEtcd:
+	default:
+		return fmt.Errorf("unknown kind %v", src.Kind)
+	}
+	u, err := url.Parse(src.URL)
+	if err != nil {
+		return fmt.Errorf("parse URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("URL must use HTTP or HTTPS")
+	}
+	if src.Kind != KindHTTP && src.Key == "" {
+		return fmt.Errorf("a key must be set for %v", src.Kind)
+	}
+	if src.Interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	return nil
+}
+
+// Fetch fetches the Document of the Source. The Version of the Document fetched last is passed, or an empty string
+// if none was fetched yet. False is returned if the Document didn't change since that version, in which case the
+// Document returned is empty. For KindConsul, Fetch blocks for up to Interval until the Document changes.
+func (src Source) Fetch(ctx context.Context, version string) (Document, bool, error) {
+	if src.Client == nil {
+		src.Client = &http.Client{Timeout: src.Interval + time.Second*10}
+	}
+	var (
+		req *http.Request
+		err error
+	)
+	switch src.Kind {
+	case KindHTTP:
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, src.URL, nil)
+		if err == nil && version != "" {
+			req.Header.Set("If-None-Match", version)
+		}
+	case KindConsul:
+		u := strings.TrimSuffix(src.URL, "/") + "/v1/kv/" + strings.TrimPrefix(src.Key, "/") + "?raw"
+		if version != "" {
+			u += "&index=" + url.QueryEscape(version) + "&wait=" + strconv.Itoa(int(src.Interval/time.Second)) + "s"
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	case KindEtcd:
+		body, _ := json.Marshal(map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(src.Key))})
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(src.URL, "/")+"/v3/kv/range", bytes.NewReader(body))
+	default:
+		return Document{}, false, fmt.Errorf("unknown kind %v", src.Kind)
+	}
+	if err != nil {
+		return Document{}, false, err
+	}
+	if src.Token != "" {
+		if src.Kind == KindConsul {
+			req.Header.Set("X-Consul-Token", src.Token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+src.Token)
+		}
+	}
+	resp, err := src.Client.Do(req)
+	if err != nil {
+		return Document{}, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return Document{}, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Document{}, false, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1))
+	if err != nil {
+		return Document{}, false, err
+	}
+	if len(data) > maxDocumentSize {
+		return Document{}, false, fmt.Errorf("document exceeds %v bytes", maxDocumentSize)
+	}
+
+	doc := Document{Data: data}
+	switch src.Kind {
+	case KindHTTP:
+		doc.Version = resp.Header.Get("ETag")
+	case KindConsul:
+		doc.Version = resp.Header.Get("X-Consul-Index")
+	case KindEtcd:
+		if doc, err = etcdDocument(data); err != nil {
+			return Document{}, false, err
+		}
+	}
+	if doc.Version == "" {
+		// Endpoints without versions are compared by their content.
+		sum := sha256.Sum256(doc.Data)
+		doc.Version = hex.EncodeToString(sum[:])
+	}
+	if doc.Version == version {
+		return Document{}, false, nil
+	}
+	return doc, true, nil
+}
+
+// etcdDocument decodes the Document in the response of the JSON gateway of etcd to a range request passed.
+func etcdDocument(data []byte) (Document, error) {
+	var resp struct {
+		KVs []struct {
+			Value       string `json:"value"`
+			ModRevision string `json:"mod_revision"`
+		} `json:"kvs"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return Document{}, fmt.Errorf("decode etcd response: %w", err)
+	}
+	if len(resp.KVs) == 0 {
+		return Document{}, errors.New("key not found")
+	}
+	value, err := base64.StdEncoding.DecodeString(resp.KVs[0].Value)
+	if err != nil {
+		return Document{}, fmt.Errorf("decode etcd value: %w", err)
+	}
+	return Document{Data: value, Version: resp.KVs[0].ModRevision}, nil
+}
+
+// Load fetches the Document of the Source and stores it in the cache file passed, if not empty. If the Source can't
+// be reached, the Document stored in the cache file is returned instead, with Cached set, and the error fetching it is
+// logged. An error is returned if neither the Source nor the cache file hold a Document.
+func (src Source) Load(ctx context.Context, cache string) (Document, error) {
+	doc, _, err := src.Fetch(ctx, "")
+	if err == nil {
+		if cache != "" {
+			if err := writeCache(cache, doc.Data); err != nil {
+				logging.Default().Warn("error caching remote config", "file", cache, "err", err)
+			}
+		}
+		return doc, nil
+	}
+	if cache == "" {
+		return Document{}, err
+	}
+	data, cacheErr := ioutil.ReadFile(cache)
+	if cacheErr != nil {
+		return Document{}, fmt.Errorf("%w (no cached config: %v)", err, cacheErr)
+	}
+	logging.Default().Warn("error fetching remote config, using the config cached", "source", src.URL, "file", cache, "err", err)
+	// The version is left empty, so that the first change watched replaces the cached Document.
+	return Document{Data: data, Cached: true}, nil
+}
+
+// writeCache atomically writes the data passed to the cache file at the path passed.
+func writeCache(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Watch watches the Source for changes to its Document until ctx is done, calling f with every Document changed
+// since the version passed, which is usually the Version of the Document returned by Load. f is called on the
+// goroutine calling Watch, which blocks. The cache file passed, if not empty, is updated with every change, and
+// errors fetching the Source are logged and retried after Interval.
+func (src Source) Watch(ctx context.Context, version, cache string, f func(doc Document)) {
+	for {
+		doc, changed, err := src.Fetch(ctx, version)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logging.Default().Warn("error watching remote config", "source", src.URL, "err", err)
+		}
+		if changed {
+			version = doc.Version
+			if cache != "" {
+				if err := writeCache(cache, doc.Data); err != nil {
+					logging.Default().Warn("error caching remote config", "file", cache, "err", err)
+				}
+			}
+			f(doc)
+		}
+		if src.Kind == KindConsul && err == nil {
+			// Blocking queries already waited for a change.
+			continue
+		}
+		select {
+		case <-time.After(src.Interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package remoteconfig
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"1"`)
+		_, _ = w.Write([]byte("[Connection]\nMaxPlayers = 10\n"))
+	}))
+	defer srv.Close()
+
+	src := Source{Kind: KindHTTP, URL: srv.URL, Interval: time.Second}
+	if _, _, err := src.Fetch(context.Background(), ""); err == nil {
+		t.Error("expected a request without the token to be rejected")
+	}
+	src.Token = "secret"
+	doc, changed, err := src.Fetch(context.Background(), "")
+	if err != nil || !changed || doc.Version != `"1"` || string(doc.Data) != "[Connection]\nMaxPlayers = 10\n" {
+		t.Fatalf("unexpected document %+v, changed %v, err %v", doc, changed, err)
+	}
+	if _, changed, err := src.Fetch(context.Background(), doc.Version); err != nil || changed {
+		t.Errorf("expected the document not to change, got changed %v, err %v", changed, err)
+	}
+}
+
+func TestFetchConsul(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/draco/config" || r.Header.Get("X-Consul-Token") != "secret" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("index") != "" && r.URL.Query().Get("wait") != "1s" {
+			t.Errorf("unexpected wait %v", r.URL.Query().Get("wait"))
+		}
+		// The key never changes, so blocking queries time out and respond with the same index.
+		w.Header().Set("X-Consul-Index", "7")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer srv.Close()
+
+	src := Source{Kind: KindConsul, URL: srv.URL, Key: "draco/config", Token: "secret", Interval: time.Second}
+	doc, changed, err := src.Fetch(context.Background(), "")
+	if err != nil || !changed || doc.Version != "7" || string(doc.Data) != "data" {
+		t.Fatalf("unexpected document %+v, changed %v, err %v", doc, changed, err)
+	}
+	if _, changed, err := src.Fetch(context.Background(), "7"); err != nil || changed {
+		t.Errorf("expected the document not to change, got changed %v, err %v", changed, err)
+	}
+}
+
+func TestFetchEtcd(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/kv/range" || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"kvs": [{"key": "ZHJhY28=", "value": "` + base64.StdEncoding.EncodeToString([]byte("data")) + `", "mod_revision": "12"}]}`))
+	}))
+	defer srv.Close()
+
+	src := Source{Kind: KindEtcd, URL: srv.URL, Key: "draco", Interval: time.Second}
+	doc, changed, err := src.Fetch(context.Background(), "")
+	if err != nil || !changed || doc.Version != "12" || string(doc.Data) != "data" {
+		t.Fatalf("unexpected document %+v, changed %v, err %v", doc, changed, err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	body := "first"
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = w.Write([]byte(body))
+	}))
+	cache := filepath.Join(t.TempDir(), "config.remote.toml")
+	src := Source{Kind: KindHTTP, URL: srv.URL, Interval: time.Millisecond * 10}
+	doc, err := src.Load(context.Background(), cache)
+	if err != nil || doc.Cached || string(doc.Data) != "first" {
+		t.Fatalf("unexpected document %+v, err %v", doc, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan Document, 1)
+	go src.Watch(ctx, doc.Version, cache, func(doc Document) {
+		changes <- doc
+	})
+	mu.Lock()
+	body = "second"
+	mu.Unlock()
+	select {
+	case doc := <-changes:
+		if string(doc.Data) != "second" {
+			t.Errorf("unexpected document watched %q", doc.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("change not watched")
+	}
+	cancel()
+
+	// The endpoint is unreachable, so the document watched last is loaded from the cache.
+	srv.Close()
+	doc, err = src.Load(context.Background(), cache)
+	if err != nil || !doc.Cached || string(doc.Data) != "second" {
+		t.Errorf("expected the document to be loaded from the cache, got %+v, err %v", doc, err)
+	}
+	if data, _ := ioutil.ReadFile(cache); string(data) != "second" {
+		t.Errorf("unexpected cache %q", data)
+	}
+	if _, err := src.Load(context.Background(), filepath.Join(t.TempDir(), "missing.toml")); err == nil {
+		t.Error("expected an error without an endpoint or a cache")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, src := range []Source{
+		{Kind: "zookeeper", URL: "http://127.0.0.1", Interval: time.Second},
+		{Kind: KindHTTP, URL: "ftp://127.0.0.1", Interval: time.Second},
+		{Kind: KindConsul, URL: "http://127.0.0.1:8500", Interval: time.Second},
+		{Kind: KindHTTP, URL: "http://127.0.0.1"},
+	} {
+		if err := src.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", src)
+		}
+	}
+	if err := (Source{Kind: KindEtcd, URL: "http://127.0.0.1:2379", Key: "draco", Interval: time.Second}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
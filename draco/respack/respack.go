@@ -0,0 +1,139 @@
+// Package respack caches the resource packs that backends send on disk, so that the proxy can offer them to clients.
+// Clients download resource packs from the proxy while they log in, before a backend is dialed for them, so the
+// packs of backends can only be offered to them once the proxy has seen them before.
+package respack
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft/resource"
+)
+
+// Cache is a directory holding resource packs, one file per pack, named after the UUID and the version of the pack.
+// Only the last version stored of every pack is kept. A Cache is safe for concurrent use.
+type Cache struct {
+	dir string
+
+	mu sync.Mutex
+	// stored holds the names of the files of the packs stored, by the UUID of the pack.
+	stored map[string]string
+}
+
+// Open opens the Cache in the directory passed, creating the directory if it doesn't exist.
+func Open(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cache{dir: dir, stored: map[string]string{}}
+	for _, f := range files {
+		if uuid, ok := packUUID(f.Name()); ok {
+			c.stored[uuid] = f.Name()
+		}
+	}
+	return c, nil
+}
+
+// packUUID returns the UUID of the pack stored in the file with the name passed. False is returned if the file
+// doesn't hold a pack.
+func packUUID(file string) (string, bool) {
+	if !strings.HasSuffix(file, ".mcpack") {
+		return "", false
+	}
+	uuid := strings.SplitN(strings.TrimSuffix(file, ".mcpack"), "_", 2)[0]
+	return uuid, uuid != ""
+}
+
+// fileName returns the name of the file that the pack passed is stored in.
+func fileName(p *resource.Pack) string {
+	return p.UUID() + "_" + p.Version() + ".mcpack"
+}
+
+// Store stores the pack passed, replacing any other version of it stored before. The content key of encrypted packs
+// is stored next to them. True is returned if the pack was not stored yet.
+func (c *Cache) Store(p *resource.Pack) (bool, error) {
+	name := fileName(p)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous, ok := c.stored[p.UUID()]
+	if ok && previous == name {
+		return false, nil
+	}
+	path := filepath.Join(c.dir, name)
+	if err := writeFile(path, io.NewSectionReader(p, 0, int64(p.Len()))); err != nil {
+		return false, fmt.Errorf("store pack %v: %w", p.UUID(), err)
+	}
+	if p.Encrypted() {
+		if err := writeFile(path+".key", strings.NewReader(p.ContentKey())); err != nil {
+			return false, fmt.Errorf("store content key of pack %v: %w", p.UUID(), err)
+		}
+	}
+	if ok {
+		_ = os.Remove(filepath.Join(c.dir, previous))
+		_ = os.Remove(filepath.Join(c.dir, previous+".key"))
+	}
+	c.stored[p.UUID()] = name
+	return true, nil
+}
+
+// writeFile writes the content of the io.Reader passed to the file passed, replacing it atomically so that other
+// processes never read a pack half written.
+func writeFile(path string, r io.Reader) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".pack-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Packs loads all packs stored in the Cache, ordered by their UUID. Packs that fail to load are skipped and returned
+// as an error, along with the packs that did load.
+func (c *Cache) Packs() ([]*resource.Pack, error) {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.stored))
+	for _, name := range c.stored {
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+	sort.Strings(names)
+
+	var (
+		packs []*resource.Pack
+		errs  []string
+	)
+	for _, name := range names {
+		path := filepath.Join(c.dir, name)
+		p, err := resource.Compile(path)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", name, err))
+			continue
+		}
+		if key, err := ioutil.ReadFile(path + ".key"); err == nil {
+			p = p.WithContentKey(string(key))
+		}
+		packs = append(packs, p)
+	}
+	if len(errs) > 0 {
+		return packs, fmt.Errorf("load cached packs: %v", strings.Join(errs, "; "))
+	}
+	return packs, nil
+}
@@ -0,0 +1,59 @@
+package respack
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/resource"
+)
+
+// testPack returns a resource pack with the UUID and version passed.
+func testPack(t *testing.T, uuid string, version int) *resource.Pack {
+	buf := bytes.NewBuffer(nil)
+	z := zip.NewWriter(buf)
+	f, err := z.Create("manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = fmt.Fprintf(f, `{"format_version": 2, "header": {"name": "test", "uuid": %q, "version": [1, 0, %v]}, "modules": [{"type": "resources", "uuid": "7e1f6bd6-14b9-4d65-8a2e-1b0f2d8e5a6c", "version": [1, 0, %v]}]}`, uuid, version, version)
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := resource.FromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const uuid = "0f6c8d5e-2a57-4bb4-9a65-0d5a4f2c1e8b"
+	if stored, err := c.Store(testPack(t, uuid, 0)); err != nil || !stored {
+		t.Fatalf("expected the pack to be stored, got %v, %v", stored, err)
+	}
+	if stored, _ := c.Store(testPack(t, uuid, 0)); stored {
+		t.Error("expected a pack stored before not to be stored again")
+	}
+	if stored, _ := c.Store(testPack(t, uuid, 1)); !stored {
+		t.Error("expected a new version of a pack to be stored")
+	}
+
+	// The cache is opened again, as it is when the proxy restarts.
+	if c, err = Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	packs, err := c.Packs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packs) != 1 || packs[0].UUID() != uuid || packs[0].Version() != "1.0.1" {
+		t.Fatalf("expected only the last version of the pack to be kept, got %v", packs)
+	}
+}
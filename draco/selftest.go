@@ -0,0 +1,186 @@
+package draco
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cqdetdev/draco/draco/biome"
+	"github.com/cqdetdev/draco/draco/item"
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/legacymappings"
+	"github.com/cqdetdev/draco/draco/state"
+)
+
+// SelfTestReport is the result of a SelfTest of the translation tables.
+type SelfTestReport struct {
+	// Errors holds inconsistencies in the translation tables that would lead to corrupt data being sent to
+	// clients, such as runtime IDs that translate to runtime IDs that don't exist.
+	Errors []string
+	// UnmappedBlocks, UnmappedItems and UnmappedBiomes hold the block states, items and biomes that exist in one
+	// version but have no equivalent in the other. The proxy can't translate these, so they are reported, but they
+	// don't make the tables inconsistent.
+	UnmappedBlocks, UnmappedItems, UnmappedBiomes []string
+}
+
+// Err returns an error holding a detailed report of all Errors found, or nil if the self-test passed.
+func (r SelfTestReport) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("translation table self-test failed with %v error(s):\n\t%v", len(r.Errors), strings.Join(r.Errors, "\n\t"))
+}
+
+// SelfTest runs consistency checks on the block, item and biome translation tables between 1.18.30 and 1.18.10. It should
+// be called on startup, so that the proxy may fail fast rather than send corrupt data to the first player that
+// joins.
+func SelfTest() SelfTestReport {
+	r := &SelfTestReport{}
+	if latestmappings.StateCount() == 0 || legacymappings.StateCount() == 0 {
+		r.errorf("block state table is empty (1.18.30: %v states, 1.18.10: %v states)", latestmappings.StateCount(), legacymappings.StateCount())
+		return *r
+	}
+	selfTestAir(r)
+	selfTestBlocks(r)
+	selfTestItems(r)
+	selfTestBiomes(r)
+
+	sort.Strings(r.UnmappedBlocks)
+	sort.Strings(r.UnmappedItems)
+	sort.Strings(r.UnmappedBiomes)
+	return *r
+}
+
+// selfTestAir checks if air translates to air in both directions.
+func selfTestAir(r *SelfTestReport) {
+	latestAir, ok := latestmappings.StateToRuntimeID("minecraft:air", nil)
+	if !ok {
+		r.errorf("air is not present in the 1.18.30 block state table")
+		return
+	}
+	legacyAir, ok := legacymappings.StateToRuntimeID("minecraft:air", nil)
+	if !ok {
+		r.errorf("air is not present in the 1.18.10 block state table")
+		return
+	}
+	if latestAir != air {
+		r.errorf("air runtime id %v does not match air in the 1.18.30 block state table (%v)", air, latestAir)
+	}
+	rid, ok := state.TranslateRuntimeID(latestmappings.Version, legacymappings.Version, latestAir)
+	if !ok || rid != legacyAir {
+		r.errorf("1.18.30 air (%v) does not downgrade to 1.18.10 air (%v)", latestAir, legacyAir)
+	}
+	rid, ok = state.TranslateRuntimeID(legacymappings.Version, latestmappings.Version, legacyAir)
+	if !ok || rid != latestAir {
+		r.errorf("1.18.10 air (%v) does not upgrade to 1.18.30 air (%v)", legacyAir, latestAir)
+	}
+}
+
+// selfTestBlocks checks if every block state translates to a valid block state with the same properties in the
+// other version, and if translating it back results in the original block state.
+func selfTestBlocks(r *SelfTestReport) {
+	for rid := uint32(0); rid < latestmappings.StateCount(); rid++ {
+		name, properties, ok := latestmappings.RuntimeIDToState(rid)
+		if !ok {
+			r.errorf("1.18.30 block state table has no state with runtime id %v", rid)
+			continue
+		}
+		legacyRID, ok := legacymappings.StateToRuntimeID(name, properties)
+		if !ok {
+			r.UnmappedBlocks = append(r.UnmappedBlocks, fmt.Sprintf("1.18.30 %v%v", name, properties))
+			continue
+		}
+		if legacyRID >= legacymappings.StateCount() {
+			r.errorf("1.18.30 block %v%v (%v) downgrades to invalid runtime id %v", name, properties, rid, legacyRID)
+			continue
+		}
+		legacyName, legacyProperties, _ := legacymappings.RuntimeIDToState(legacyRID)
+		if back, ok := latestmappings.StateToRuntimeID(legacyName, legacyProperties); ok && back != rid {
+			r.errorf("1.18.30 block %v%v (%v) downgrades to %v%v, which upgrades to %v", name, properties, rid, legacyName, legacyProperties, back)
+		}
+	}
+	for rid := uint32(0); rid < legacymappings.StateCount(); rid++ {
+		name, properties, ok := legacymappings.RuntimeIDToState(rid)
+		if !ok {
+			r.errorf("1.18.10 block state table has no state with runtime id %v", rid)
+			continue
+		}
+		if _, ok := latestmappings.StateToRuntimeID(name, properties); !ok {
+			r.UnmappedBlocks = append(r.UnmappedBlocks, fmt.Sprintf("1.18.10 %v%v", name, properties))
+		}
+	}
+	selfTestTable(r, "1.18.30", latestmappings.Version, legacymappings.Version)
+	selfTestTable(r, "1.18.10", legacymappings.Version, latestmappings.Version)
+}
+
+// selfTestTable checks if every runtime ID of the Version from translates to a runtime ID that exists in the Version
+// to, using the tables of the Default registry that are used to translate chunks. These tables may be loaded from
+// the table cache rather than generated from the palettes checked by selfTestBlocks.
+func selfTestTable(r *SelfTestReport, ver string, from, to state.Version) {
+	f, ok := state.PaletteOf(from)
+	if !ok {
+		r.errorf("no %v block palette registered", ver)
+		return
+	}
+	t, ok := state.PaletteOf(to)
+	if !ok {
+		r.errorf("no block palette registered to translate %v block states to", ver)
+		return
+	}
+	for rid := uint32(0); rid < f.Len(); rid++ {
+		if other, ok := state.TranslateRuntimeID(from, to, rid); ok && other >= t.Len() {
+			r.errorf("%v runtime id %v translates to invalid runtime id %v", ver, rid, other)
+		}
+	}
+}
+
+// selfTestItems checks if items present in both versions are translated one-to-one: An item that is downgraded
+// and upgraded again, or the other way around, must result in the original item.
+func selfTestItems(r *SelfTestReport) {
+	check := func(ver string, items map[string]int32, from, to state.Version) {
+		for name, rid := range items {
+			translated, ok := item.TranslateRuntimeID(from, to, rid)
+			if !ok {
+				r.UnmappedItems = append(r.UnmappedItems, ver+" "+name)
+				continue
+			}
+			if original, ok := item.TranslateRuntimeID(to, from, translated); ok && original != rid {
+				r.errorf("%v item %v (%v) translates to %v, which translates back to %v", ver, name, rid, translated, original)
+			}
+		}
+	}
+	check("1.18.30", latestmappings.Items(), latestmappings.Version, legacymappings.Version)
+	check("1.18.10", legacymappings.Items(), legacymappings.Version, latestmappings.Version)
+}
+
+// selfTestBiomes checks if both versions have biomes registered, including the biome that biomes without an
+// equivalent are translated to, and if every biome translates to a biome that exists in the other version.
+func selfTestBiomes(r *SelfTestReport) {
+	check := func(ver string, from, to state.Version) {
+		biomes := biome.Biomes(from)
+		if len(biomes) == 0 {
+			r.errorf("%v biome table is empty", ver)
+			return
+		}
+		if _, ok := biome.ID(from, biome.Default); !ok {
+			r.errorf("default biome %v is not present in the %v biome table", biome.Default, ver)
+		}
+		for _, b := range biomes {
+			id, ok := biome.TranslateID(from, to, b.ID)
+			if !ok {
+				r.UnmappedBiomes = append(r.UnmappedBiomes, ver+" "+b.Name)
+				continue
+			}
+			if _, ok := biome.Name(to, id); !ok {
+				r.errorf("%v biome %v (%v) translates to invalid id %v", ver, b.Name, b.ID, id)
+			}
+		}
+	}
+	check("1.18.30", latestmappings.Version, legacymappings.Version)
+	check("1.18.10", legacymappings.Version, latestmappings.Version)
+}
+
+// errorf adds a formatted error to the report.
+func (r *SelfTestReport) errorf(format string, a ...any) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, a...))
+}
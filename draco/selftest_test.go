@@ -0,0 +1,25 @@
+package draco
+
+import "testing"
+
+func TestSelfTest(t *testing.T) {
+	r := SelfTest()
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	// Every biome has an equivalent or a fallback in the other version, so none may be translated to the default.
+	if len(r.UnmappedBiomes) != 0 {
+		t.Errorf("biomes can't be translated between versions: %v", r.UnmappedBiomes)
+	}
+}
+
+func TestSelfTestReportErr(t *testing.T) {
+	r := SelfTestReport{UnmappedBlocks: []string{"1.18.30 minecraft:test"}}
+	if err := r.Err(); err != nil {
+		t.Fatalf("expected unmapped blocks not to fail the self-test, got %v", err)
+	}
+	r.errorf("runtime id %v is invalid", 5)
+	if err := r.Err(); err == nil {
+		t.Fatal("expected an error for a report holding errors")
+	}
+}
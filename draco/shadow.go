@@ -0,0 +1,186 @@
+package draco
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+var (
+	// shadowMu guards shadows.
+	shadowMu sync.RWMutex
+	// shadows holds the protocols registered using RegisterShadow, keyed by their protocol ID.
+	shadows = map[int32]minecraft.Protocol{}
+)
+
+// RegisterShadow registers an experimental protocol that runs in the shadow of the protocol registered with the same
+// ID, such as a new version of its translator. Players are served by the registered protocol only: the shadow
+// translates copies of the same packets in the background, and the packets it translates are compared with those of the
+// registered protocol without being sent. Packets that translate differently are counted by their type in the
+// "shadow_divergences" metrics group and the first divergence of every type in either direction is logged, so that a
+// translator can be tested against real traffic before it serves players. Registering a shadow for a protocol ID that
+// already has one replaces it. Like RegisterProtocol, RegisterShadow must be called before the proxy starts listening.
+func RegisterShadow(p minecraft.Protocol) {
+	shadowMu.Lock()
+	defer shadowMu.Unlock()
+	shadows[p.ID()] = p
+}
+
+// withShadow returns the protocol passed, translating packets in the shadow of the protocol registered using
+// RegisterShadow with the same ID, if any.
+func withShadow(p minecraft.Protocol) minecraft.Protocol {
+	shadowMu.RLock()
+	defer shadowMu.RUnlock()
+	if shadow, ok := shadows[p.ID()]; ok {
+		return shadowProtocol{Protocol: p, shadow: shadow}
+	}
+	return p
+}
+
+// shadowProtocol is a protocol that translates copies of all packets it translates with a shadow protocol as well.
+type shadowProtocol struct {
+	minecraft.Protocol
+	shadow minecraft.Protocol
+}
+
+// ConvertToLatest ...
+func (p shadowProtocol) ConvertToLatest(pk packet.Packet) packet.Packet {
+	mirror, ok := mirrorPacket(pk)
+	converted := p.Protocol.ConvertToLatest(pk)
+	if ok {
+		compareShadow(shadowJob{shadow: p.shadow, latest: true, packet: mirror, expected: converted})
+	}
+	return converted
+}
+
+// ConvertFromLatest ...
+func (p shadowProtocol) ConvertFromLatest(pk packet.Packet) packet.Packet {
+	mirror, ok := mirrorPacket(pk)
+	converted := p.Protocol.ConvertFromLatest(pk)
+	if ok {
+		compareShadow(shadowJob{shadow: p.shadow, packet: mirror, expected: converted})
+	}
+	return converted
+}
+
+// shadowJob is a packet to translate using a shadow protocol, to the latest protocol if latest is true, or from the
+// latest protocol otherwise. expected is the packet as translated by the protocol in whose shadow it runs, and
+// encoded the encoding of it, which is set by compareShadow.
+type shadowJob struct {
+	shadow   minecraft.Protocol
+	latest   bool
+	packet   packet.Packet
+	expected packet.Packet
+	encoded  []byte
+}
+
+var (
+	// shadowJobs holds the shadowJobs yet to be translated by the goroutine started by shadowOnce.
+	shadowJobs = make(chan shadowJob, 256)
+	shadowOnce sync.Once
+	// shadowLogged holds the packet types and directions whose first divergence was logged.
+	shadowLogged sync.Map
+)
+
+// compareShadow queues the shadowJob passed to be translated and compared in the background. The packet expected is
+// encoded before compareShadow returns, as it is sent to the client or server afterwards. Jobs are skipped and
+// counted in the "shadow_skipped" metric while the queue is full, so that a slow shadow never holds up players.
+func compareShadow(j shadowJob) {
+	shadowOnce.Do(func() {
+		go func() {
+			for j := range shadowJobs {
+				j.run()
+			}
+		}()
+	})
+	var err error
+	if j.encoded, err = encodeShadowPacket(j.expected); err != nil {
+		// The packet that players are sent can't be encoded, which the connection reports itself.
+		return
+	}
+	select {
+	case shadowJobs <- j:
+	default:
+		metrics.Add("shadow_skipped", 1)
+	}
+}
+
+// run translates the packet of the shadowJob using its shadow protocol and compares the packet translated with the
+// packet expected.
+func (j shadowJob) run() {
+	name := fmt.Sprintf("%T", j.packet)
+	direction := "from latest"
+	if j.latest {
+		direction = "to latest"
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			j.diverged(name, direction, "shadow panicked", fmt.Sprint(r))
+		}
+		metrics.Add("shadow_packets", 1)
+	}()
+	var translated packet.Packet
+	if j.latest {
+		translated = j.shadow.ConvertToLatest(j.packet)
+	} else {
+		translated = j.shadow.ConvertFromLatest(j.packet)
+	}
+	encoded, err := encodeShadowPacket(translated)
+	switch {
+	case err != nil:
+		j.diverged(name, direction, "shadow packet can't be encoded", err.Error())
+	case !bytes.Equal(encoded, j.encoded):
+		j.diverged(name, direction, "shadow packet differs", fmt.Sprintf("expected %+v, got %+v", j.expected, translated))
+	}
+}
+
+// diverged counts a divergence of the shadow protocol for the packet type and direction passed, and logs it if it is
+// the first of the type and direction.
+func (j shadowJob) diverged(name, direction, reason, detail string) {
+	metrics.AddTo("shadow_divergences", name, 1)
+	if _, logged := shadowLogged.LoadOrStore(name+" "+direction, struct{}{}); logged {
+		return
+	}
+	logging.Default().Warn("shadow translator diverged", "protocol", j.shadow.ID(), "packet", name, "direction", direction, "reason", reason, "detail", detail)
+}
+
+// mirrorPacket returns a copy of the packet passed, so that the shadow protocol translates the packet as it was
+// before the protocol in whose shadow it runs translated it in place. False is returned if the packet can't be
+// copied.
+func mirrorPacket(pk packet.Packet) (mirror packet.Packet, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	buf := bytes.NewBuffer(nil)
+	pk.Marshal(protocol.NewWriter(buf, 0))
+	if unknown, ok := pk.(*packet.Unknown); ok {
+		mirror = &packet.Unknown{PacketID: unknown.PacketID}
+	} else {
+		mirror = reflect.New(reflect.TypeOf(pk).Elem()).Interface().(packet.Packet)
+	}
+	mirror.Unmarshal(protocol.NewReader(buf, 0))
+	return mirror, true
+}
+
+// encodeShadowPacket encodes the ID and payload of the packet passed.
+func encodeShadowPacket(pk packet.Packet) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("encode %T: %v", pk, r)
+		}
+	}()
+	buf := bytes.NewBuffer(nil)
+	hdr := packet.Header{PacketID: pk.ID()}
+	_ = hdr.Write(buf)
+	pk.Marshal(protocol.NewWriter(buf, 0))
+	return buf.Bytes(), nil
+}
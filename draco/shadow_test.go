@@ -0,0 +1,52 @@
+package draco
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// testShadowProtocol is a protocol with an unused ID that translates packets like Protocol, except that it shows
+// all messages in uppercase if upper is set.
+type testShadowProtocol struct {
+	Protocol
+	upper bool
+}
+
+// ID ...
+func (testShadowProtocol) ID() int32 {
+	return -1
+}
+
+// ConvertFromLatest ...
+func (p testShadowProtocol) ConvertFromLatest(pk packet.Packet) packet.Packet {
+	if text, ok := pk.(*packet.Text); ok && p.upper {
+		text.Message = "HELLO"
+	}
+	return p.Protocol.ConvertFromLatest(pk)
+}
+
+func TestShadow(t *testing.T) {
+	RegisterShadow(testShadowProtocol{upper: true})
+	p := withShadow(testShadowProtocol{})
+
+	text := &packet.Text{TextType: packet.TextTypeRaw, Message: "hello"}
+	if pk := p.ConvertFromLatest(text).(*packet.Text); pk.Message != "hello" {
+		t.Errorf("shadow changed the packet sent to %q", pk.Message)
+	}
+	p.ConvertFromLatest(&packet.SetTime{Time: 5})
+
+	deadline := time.Now().Add(time.Second)
+	for metrics.Value("shadow_packets") < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	divergences := metrics.Group("shadow_divergences")
+	if divergences["*packet.Text"] != 1 {
+		t.Errorf("%v divergences of text packets, expected 1", divergences["*packet.Text"])
+	}
+	if divergences["*packet.SetTime"] != 0 {
+		t.Errorf("%v divergences of packets translated identically, expected 0", divergences["*packet.SetTime"])
+	}
+}
@@ -0,0 +1,214 @@
+// Package slo tracks the time that players take to join the proxy against a service level objective, such as 95% of
+// joins spawning within 5 seconds, and alerts operators through a webhook once the objective is breached, so that
+// regressions of the translator or of backends are noticed as soon as they slow down joins.
+package slo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/metrics"
+)
+
+// Objective is a service level objective for the time that players take to join.
+type Objective struct {
+	// Target is the fraction of joins, from 0 to 1, that must complete within Threshold, such as 0.95.
+	Target float64
+	// Threshold is the maximum duration of a join that complies with the Objective.
+	Threshold time.Duration
+	// Window is the duration over which compliance with the Objective is measured.
+	Window time.Duration
+	// MinJoins is the minimum amount of joins within the Window for the Objective to be breached, so that a few
+	// slow joins while the proxy is quiet don't raise an alert.
+	MinJoins int
+	// WebhookURL is the URL that alerts are posted to as JSON when the Objective is breached and once it is met
+	// again. The message of an alert is held in the "content" field, so Discord webhooks may be used. If empty,
+	// alerts are only logged.
+	WebhookURL string
+}
+
+// Status is the compliance with the Objective set of the joins within its window.
+type Status struct {
+	// Target, Threshold and Window are those of the Objective set.
+	Target    float64       `json:"target"`
+	Threshold time.Duration `json:"threshold_ns"`
+	Window    time.Duration `json:"window_ns"`
+	// Joins is the amount of joins within the window, and Compliant the amount of those that completed within the
+	// threshold.
+	Joins     int `json:"joins"`
+	Compliant int `json:"compliant"`
+	// Compliance is the fraction of the joins within the window that were compliant, which is 1 if there were no
+	// joins.
+	Compliance float64 `json:"compliance"`
+	// Breached specifies if the Objective is currently breached.
+	Breached bool `json:"breached"`
+}
+
+// maxJoins is the maximum amount of joins kept for the window. Once reached, the oldest joins are forgotten even if
+// they are still within the window.
+const maxJoins = 1 << 16
+
+// join is a join observed using Observe.
+type join struct {
+	at        time.Time
+	compliant bool
+}
+
+var (
+	// mu guards objective, joins and breached.
+	mu sync.Mutex
+	// objective is the Objective set using Set. Joins are not tracked if its Threshold is 0.
+	objective Objective
+	// joins holds the joins within the window of the objective, oldest first.
+	joins []join
+	// breached specifies if the objective is currently breached.
+	breached bool
+
+	// alerts holds the alerts to be posted to the webhook, in order.
+	alerts = make(chan alert, 16)
+	// client is the HTTP client that alerts are posted with.
+	client = &http.Client{Timeout: time.Second * 10}
+)
+
+// Set sets the Objective that joins are tracked against. Joins are no longer tracked if the Threshold of the
+// Objective is 0. The joins observed before are kept.
+func Set(o Objective) {
+	mu.Lock()
+	defer mu.Unlock()
+	objective = o
+	if o.Threshold == 0 {
+		joins, breached = nil, false
+	}
+}
+
+// Observe observes a join that took the duration passed, from the moment the proxy accepted the client up to the
+// client being initialised in the world, and alerts if the Objective was breached or is met again as a result.
+// Compliance is only evaluated when joins are observed, so an Objective breached remains so until players join
+// again.
+func Observe(d time.Duration) {
+	mu.Lock()
+	o := objective
+	if o.Threshold == 0 {
+		mu.Unlock()
+		return
+	}
+	now, compliant := time.Now(), d <= o.Threshold
+	if len(joins) >= maxJoins {
+		joins = joins[1:]
+	}
+	joins = append(joins, join{at: now, compliant: compliant})
+	s := statusLocked(now)
+	changed := s.Breached != breached
+	breached = s.Breached
+	mu.Unlock()
+
+	if compliant {
+		metrics.Add("joins_within_slo", 1)
+	} else {
+		metrics.Add("joins_over_slo", 1)
+	}
+	if changed {
+		alertChange(o, s)
+	}
+}
+
+// Current returns the current Status of the Objective set.
+func Current() Status {
+	mu.Lock()
+	defer mu.Unlock()
+	return statusLocked(time.Now())
+}
+
+// statusLocked removes the joins that fell out of the window of the objective and returns its Status at the time
+// passed. mu must be held.
+func statusLocked(now time.Time) Status {
+	n := 0
+	for n < len(joins) && now.Sub(joins[n].at) > objective.Window {
+		n++
+	}
+	joins = joins[n:]
+
+	s := Status{Target: objective.Target, Threshold: objective.Threshold, Window: objective.Window, Joins: len(joins), Compliance: 1}
+	for _, j := range joins {
+		if j.compliant {
+			s.Compliant++
+		}
+	}
+	if s.Joins > 0 {
+		s.Compliance = float64(s.Compliant) / float64(s.Joins)
+	}
+	s.Breached = s.Joins >= objective.MinJoins && s.Compliance < objective.Target
+	return s
+}
+
+// alert is the body of a request posted to the webhook of the Objective.
+type alert struct {
+	url string
+
+	Content string `json:"content"`
+	Status
+}
+
+// alertChange logs that the Objective passed was breached or is met again, as reported by the Status passed, and
+// queues an alert for its webhook.
+func alertChange(o Objective, s Status) {
+	msg := fmt.Sprintf("Join time objective met again: %.1f%% of %v joins in the last %v spawned within %v.", s.Compliance*100, s.Joins, o.Window, o.Threshold)
+	if s.Breached {
+		msg = fmt.Sprintf("Join time objective breached: only %.1f%% of %v joins in the last %v spawned within %v, below the target of %.1f%%.", s.Compliance*100, s.Joins, o.Window, o.Threshold, o.Target*100)
+		logging.Default().Warn("join time objective breached", "compliance", s.Compliance, "joins", s.Joins, "target", o.Target, "threshold", o.Threshold.String())
+	} else {
+		logging.Default().Info("join time objective met again", "compliance", s.Compliance, "joins", s.Joins)
+	}
+	if o.WebhookURL == "" {
+		return
+	}
+	select {
+	case alerts <- alert{url: o.WebhookURL, Content: msg, Status: s}:
+	default:
+		logging.Default().Warn("dropped join time alert, webhook not keeping up")
+	}
+}
+
+func init() {
+	go postAlerts()
+}
+
+// postAlerts posts the alerts queued to their webhooks, one at a time so that they arrive in order.
+func postAlerts() {
+	for a := range alerts {
+		body, _ := json.Marshal(a)
+		resp, err := client.Post(a.url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			// The URL is not logged, as the URL of a webhook often holds its token.
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			logging.Default().Warn("error posting join time alert", "err", err)
+			continue
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			logging.Default().Warn("error posting join time alert", "status", resp.Status)
+		}
+	}
+}
+
+// Handler returns an http.Handler that serves the current Status as JSON.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Current())
+	})
+}
@@ -0,0 +1,48 @@
+package slo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+	received := make(chan alert, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			t.Error(err)
+		}
+		received <- a
+	}))
+	defer srv.Close()
+	Set(Objective{Target: 0.5, Threshold: time.Second, Window: time.Minute, MinJoins: 2, WebhookURL: srv.URL})
+	defer Set(Objective{})
+
+	Observe(time.Second * 2)
+	if s := Current(); s.Breached {
+		t.Fatalf("expected the objective not to be breached below MinJoins, got %+v", s)
+	}
+	Observe(time.Second * 3)
+	if s := Current(); !s.Breached || s.Joins != 2 || s.Compliance != 0 {
+		t.Fatalf("expected the objective to be breached, got %+v", s)
+	}
+	Observe(time.Millisecond)
+	Observe(time.Millisecond)
+	if s := Current(); s.Breached || s.Compliance != 0.5 {
+		t.Fatalf("expected the objective to be met again, got %+v", s)
+	}
+
+	for _, breached := range []bool{true, false} {
+		select {
+		case a := <-received:
+			if a.Breached != breached || a.Content == "" {
+				t.Errorf("expected alert with breached %v, got %+v", breached, a)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out waiting for alert")
+		}
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package sockopt
+
+import (
+	"net"
+	"syscall"
+)
+
+// setDSCP marks all packets sent over the connection passed with the DSCP passed.
+func setDSCP(conn *net.UDPConn, dscp int) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	ipv6 := false
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		ipv6 = addr.IP.To4() == nil && addr.IP != nil && !addr.IP.IsUnspecified()
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		// The DSCP occupies the upper six bits of the traffic class/ToS byte.
+		if ipv6 {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, dscp<<2)
+			return
+		}
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, dscp<<2)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
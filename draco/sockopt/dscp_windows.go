@@ -0,0 +1,14 @@
+//go:build windows
+
+package sockopt
+
+import (
+	"errors"
+	"net"
+)
+
+// setDSCP always returns an error: Windows ignores the ToS socket option unless QoS policies are used, which must
+// be configured through group policy instead.
+func setDSCP(*net.UDPConn, int) error {
+	return errors.New("DSCP marking is not supported on windows: configure a QoS policy instead")
+}
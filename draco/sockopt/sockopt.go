@@ -0,0 +1,109 @@
+package sockopt
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"unsafe"
+)
+
+// Options holds options applied to the UDP sockets used by the proxy. Zero values leave the respective option at
+// the default of the operating system.
+// The RakNet MTU and resend parameters are not among them: go-raknet negotiates the MTU during the handshake,
+// capped at 1400 bytes, and derives resend delays from the measured round-trip time, and neither gophertunnel nor
+// go-raknet allows configuring them.
+type Options struct {
+	// ReadBuffer is the size in bytes of the receive buffer of the socket. Larger buffers prevent packets from
+	// being dropped under burst load on busy hosts.
+	ReadBuffer int
+	// WriteBuffer is the size in bytes of the send buffer of the socket.
+	WriteBuffer int
+	// DSCP is the Differentiated Services Code Point, ranging from 0 to 63, that packets sent over the socket are
+	// marked with. Routers may use it to prioritise game traffic. A common value for interactive traffic is 46
+	// (Expedited Forwarding).
+	DSCP int
+}
+
+// Apply applies the Options to the UDP socket underlying v, which is typically a *minecraft.Listener or a
+// *minecraft.Conn obtained by dialing. gophertunnel does not expose the sockets it uses, so Apply searches the
+// fields of v for the *net.UDPConn it holds. Note that all connections accepted by a listener share the socket of
+// that listener, so options should be applied to the listener rather than to the connections it accepts.
+func Apply(v any, o Options) error {
+	if o == (Options{}) {
+		return nil
+	}
+	conn := Find[net.UDPConn](v)
+	if conn == nil {
+		return fmt.Errorf("apply socket options: no UDP socket found in %T", v)
+	}
+	if o.ReadBuffer > 0 {
+		if err := conn.SetReadBuffer(o.ReadBuffer); err != nil {
+			return fmt.Errorf("apply socket options: set read buffer: %w", err)
+		}
+	}
+	if o.WriteBuffer > 0 {
+		if err := conn.SetWriteBuffer(o.WriteBuffer); err != nil {
+			return fmt.Errorf("apply socket options: set write buffer: %w", err)
+		}
+	}
+	if o.DSCP != 0 {
+		if o.DSCP < 0 || o.DSCP > 63 {
+			return fmt.Errorf("apply socket options: DSCP must be between 0 and 63, got %v", o.DSCP)
+		}
+		if err := setDSCP(conn, o.DSCP); err != nil {
+			return fmt.Errorf("apply socket options: set DSCP: %w", err)
+		}
+	}
+	return nil
+}
+
+// maxDepth is the maximum depth of nested fields searched by Find.
+const maxDepth = 8
+
+// Find searches v and its (unexported) fields for a *T, following pointers and interfaces, and returns the first one
+// found, or nil if v holds none. It is used to obtain internals that gophertunnel and go-raknet don't expose, such as
+// the socket of a connection.
+func Find[T any](v any) *T {
+	p := find(reflect.ValueOf(v), reflect.TypeOf((*T)(nil)), map[uintptr]struct{}{}, 0)
+	return (*T)(p)
+}
+
+// find searches v for a pointer of the type t like Find.
+func find(v reflect.Value, t reflect.Type, visited map[uintptr]struct{}, depth int) unsafe.Pointer {
+	if !v.IsValid() || depth > maxDepth {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		return find(v.Elem(), t, visited, depth)
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type() == t {
+			return unsafe.Pointer(v.Pointer())
+		}
+		if _, ok := visited[v.Pointer()]; ok {
+			return nil
+		}
+		visited[v.Pointer()] = struct{}{}
+		return find(v.Elem(), t, visited, depth+1)
+	case reflect.Struct:
+		if !v.CanAddr() {
+			// Make the struct addressable, so that its unexported fields may be read below.
+			c := reflect.New(v.Type()).Elem()
+			c.Set(v)
+			v = c
+		}
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Field(i)
+			// Unexported fields cannot be read through reflection directly, so we create a readable copy of the
+			// field value from its address.
+			f = reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+			if p := find(f, t, visited, depth+1); p != nil {
+				return p
+			}
+		}
+	}
+	return nil
+}
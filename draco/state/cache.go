@@ -0,0 +1,112 @@
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// tableMagic is written at the start of every cached table. Its last byte is the version of the format of cached
+// tables, which must be bumped when either the format or the way tables are generated changes.
+var tableMagic = [8]byte{'d', 'r', 'a', 'c', 'o', 't', 'b', 2}
+
+var (
+	// cacheMu guards cacheDir.
+	cacheMu sync.Mutex
+	// cacheDir is the directory that translation tables are cached in. If empty, tables are not cached.
+	cacheDir string
+)
+
+// SetTableCache sets the directory that runtime ID translation tables are cached in. Generated tables are stored in
+// the directory and loaded from it instead of being generated again on subsequent runs, keyed by the hashes of both
+// palettes so that tables of changed palettes are never used. If dir is empty, tables are not cached, which is the
+// default. SetTableCache should be called before any runtime IDs are translated.
+func SetTableCache(dir string) error {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("create table cache directory: %w", err)
+		}
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cacheDir = dir
+	return nil
+}
+
+// tablePath returns the path of the file that the table translating runtime IDs from the Palette from to the
+// Palette to is cached in. False is returned if tables are not cached.
+func tablePath(from, to *Palette) (string, bool) {
+	cacheMu.Lock()
+	dir := cacheDir
+	cacheMu.Unlock()
+	if dir == "" {
+		return "", false
+	}
+	return filepath.Join(dir, hex.EncodeToString(from.hash[:8])+"-"+hex.EncodeToString(to.hash[:8])+".table"), true
+}
+
+// loadTable loads the cached table translating runtime IDs from the Palette from to the Palette to. False is
+// returned if no valid table is cached.
+func loadTable(from, to *Palette) ([]uint32, bool) {
+	path, ok := tablePath(from, to)
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	header := tableHeader(from, to)
+	if len(data) != len(header)+len(from.states)*4 || !bytes.Equal(data[:len(header)], header) {
+		return nil, false
+	}
+	table := make([]uint32, len(from.states))
+	for i := range table {
+		table[i] = binary.LittleEndian.Uint32(data[len(header)+i*4:])
+		if table[i] != unmapped && table[i] >= to.Len() {
+			// The file was corrupted or altered: runtime IDs outside the palette must never be sent to clients.
+			return nil, false
+		}
+	}
+	return table, true
+}
+
+// storeTable caches the table translating runtime IDs from the Palette from to the Palette to. Failing to cache a
+// table is not fatal, so errors are only logged.
+func storeTable(from, to *Palette, table []uint32) {
+	path, ok := tablePath(from, to)
+	if !ok {
+		return
+	}
+	header := tableHeader(from, to)
+	data := make([]byte, len(header)+len(table)*4)
+	copy(data, header)
+	for i, rid := range table {
+		binary.LittleEndian.PutUint32(data[len(header)+i*4:], rid)
+	}
+	// Write to a temporary file first, so that other processes never load a partially written table.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("error caching translation table: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("error caching translation table: %v", err)
+	}
+}
+
+// tableHeader returns the header of the cached table translating runtime IDs from the Palette from to the Palette
+// to, which holds the full hashes of both palettes and the lookup key of the Palette to, whose lookup function
+// generated the table.
+func tableHeader(from, to *Palette) []byte {
+	header := make([]byte, 0, len(tableMagic)+len(from.hash)+len(to.hash)+len(to.lookupKey))
+	header = append(header, tableMagic[:]...)
+	header = append(header, from.hash[:]...)
+	header = append(header, to.hash[:]...)
+	return append(header, to.lookupKey[:]...)
+}
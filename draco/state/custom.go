@@ -0,0 +1,153 @@
+package state
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// Ordering is the order that a version sorts the block states of its palette in. Clients merge the custom blocks
+// sent by a server into their palette and sort it again, so the Ordering determines the runtime IDs of all block
+// states once custom blocks are present.
+type Ordering uint8
+
+const (
+	// OrderByName sorts block states by their name, ignoring case. It is used by versions before 1.18.30.
+	OrderByName Ordering = iota
+	// OrderByNameHash sorts block states by the 64-bit FNV-1 hash of their name. It is used since 1.18.30.
+	OrderByNameHash
+)
+
+// less returns a function reporting if the block state at index i of the states passed is sorted before the one at
+// index j.
+func (o Ordering) less(states []Block) func(i, j int) bool {
+	switch o {
+	case OrderByNameHash:
+		keys := make([]uint64, len(states))
+		for i, s := range states {
+			h := fnv.New64()
+			_, _ = h.Write([]byte(s.Name))
+			keys[i] = h.Sum64()
+		}
+		return func(i, j int) bool { return keys[i] < keys[j] }
+	default:
+		keys := make([]string, len(states))
+		for i, s := range states {
+			keys[i] = strings.ToLower(s.Name)
+		}
+		return func(i, j int) bool { return keys[i] < keys[j] }
+	}
+}
+
+// WithCustomBlocks returns a new Palette holding the block states of the Palette merged with the custom block states
+// passed, sorted like a client of a version with the Ordering passed sorts them. The Palette itself is left
+// unchanged, so that every connection may have a Palette of its own holding the custom blocks of its server. Block
+// states keep their Hash, but their runtime IDs change if custom blocks are sorted before them. An error is returned
+// if a custom block state has the same Hash as a block state of the Palette or can't be hashed.
+func (p *Palette) WithCustomBlocks(custom []Block, o Ordering) (*Palette, error) {
+	if len(custom) == 0 {
+		return p, nil
+	}
+	states := make([]Block, 0, len(p.states)+len(custom))
+	states = append(append(states, p.states...), custom...)
+
+	// The indices of all states are sorted along with them, so that the runtime IDs of the Palette can be mapped to
+	// the runtime IDs of the new one.
+	indices := make([]int, len(states))
+	for i := range indices {
+		indices[i] = i
+	}
+	less := o.less(states)
+	sort.SliceStable(indices, func(i, j int) bool {
+		return less(indices[i], indices[j])
+	})
+	sorted, remap := make([]Block, len(states)), make([]uint32, len(p.states))
+	customIDs := make(map[Hash]uint32, len(custom))
+	for rid, i := range indices {
+		sorted[rid] = states[i]
+		if i < len(p.states) {
+			remap[i] = uint32(rid)
+			continue
+		}
+		s := states[i]
+		if _, ok := p.lookup(s.Name, s.Properties); ok {
+			return nil, fmt.Errorf("custom block state %v collides with a block state of the palette", s.Name)
+		}
+		if err := CheckCollision(customIDs, s, uint32(rid)); err != nil {
+			return nil, err
+		}
+		customIDs[HashBlock(s)] = uint32(rid)
+	}
+	merged, err := NewPalette(sorted, func(name string, properties map[string]any) (uint32, bool) {
+		if rid, ok := p.lookup(name, properties); ok {
+			return remap[rid], true
+		}
+		return lookupHash(customIDs, name, properties)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Block states are looked up using the lookup function of the Palette, so it depends on the same data.
+	merged.lookupKey = p.lookupKey
+	return merged, nil
+}
+
+// CustomBlockStates returns all block states of the custom blocks passed, as sent by a server in the StartGame
+// packet. Every custom block has a block state for every combination of the values of its properties, which are
+// listed under the "properties" key of the block. The states of a block are ordered like the client orders them: by
+// the values of the properties, with the values of the last property changing first.
+func CustomBlockStates(entries []protocol.BlockEntry) []Block {
+	var states []Block
+	for _, e := range entries {
+		combinations := []map[string]any{{}}
+		props, _ := e.Properties["properties"].([]any)
+		for i := len(props) - 1; i >= 0; i-- {
+			prop, _ := props[i].(map[string]any)
+			name, _ := prop["name"].(string)
+			values, _ := prop["enum"].([]any)
+			if name == "" || len(values) == 0 {
+				continue
+			}
+			next := make([]map[string]any, 0, len(combinations)*len(values))
+			for _, v := range values {
+				for _, c := range combinations {
+					m := make(map[string]any, len(c)+1)
+					for k, other := range c {
+						m[k] = other
+					}
+					m[name] = v
+					next = append(next, m)
+				}
+			}
+			combinations = next
+		}
+		for _, c := range combinations {
+			states = append(states, Block{Name: e.Name, Properties: Normalise(c)})
+		}
+	}
+	return states
+}
+
+// Table translates runtime IDs from one Palette to another, like TranslateRuntimeID translates them between the
+// palettes of two versions. Tables are used to translate between palettes that are not registered, such as those
+// returned by Palette.WithCustomBlocks.
+type Table struct {
+	table []uint32
+}
+
+// NewTable generates the Table translating runtime IDs of the Palette from to runtime IDs of the Palette to.
+func NewTable(from, to *Palette) *Table {
+	return &Table{table: translationTable(from, to)}
+}
+
+// Translate translates a runtime ID of the Palette translated from to the runtime ID of the same block state in the
+// Palette translated to. False is returned if the block state has no equivalent.
+func (t *Table) Translate(rid uint32) (uint32, bool) {
+	if rid >= uint32(len(t.table)) || t.table[rid] == unmapped {
+		return 0, false
+	}
+	return t.table[rid], true
+}
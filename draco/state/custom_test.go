@@ -0,0 +1,47 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+func TestWithCustomBlocks(t *testing.T) {
+	p, err := NewPalette([]Block{{Name: "minecraft:air"}, {Name: "minecraft:stone"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := CustomBlockStates([]protocol.BlockEntry{{Name: "minecraft:custom", Properties: map[string]any{
+		"properties": []any{
+			map[string]any{"name": "a", "enum": []any{int32(0), int32(1)}},
+			map[string]any{"name": "b", "enum": []any{"x", "y"}},
+		},
+	}}})
+	if len(states) != 4 || states[1].Properties["b"] != "y" || states[2].Properties["a"] != int32(1) {
+		t.Fatalf("unexpected custom block states %v", states)
+	}
+	custom, err := p.WithCustomBlocks(states, OrderByName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if custom.Len() != 6 || p.Len() != 2 {
+		t.Fatalf("merged palette holds %v block states and the original one %v, expected 6 and 2", custom.Len(), p.Len())
+	}
+	if rid, ok := custom.RuntimeID("minecraft:stone", nil); !ok || rid != 5 {
+		t.Errorf("stone has runtime ID %v (found: %v) after merging, expected 5", rid, ok)
+	}
+	if rid, ok := custom.RuntimeID("minecraft:custom", map[string]any{"a": int32(0), "b": "y"}); !ok || rid != 2 {
+		t.Errorf("custom block state has runtime ID %v (found: %v), expected 2", rid, ok)
+	}
+	if _, err := custom.WithCustomBlocks(states[:1], OrderByName); err == nil {
+		t.Error("expected error merging a custom block state that is already in the palette")
+	}
+
+	table := NewTable(custom, p)
+	if rid, ok := table.Translate(5); !ok || rid != 1 {
+		t.Errorf("stone translated to %v (found: %v), expected 1", rid, ok)
+	}
+	if _, ok := table.Translate(2); ok {
+		t.Error("custom block state translated to a palette without it")
+	}
+}
@@ -0,0 +1,310 @@
+package state
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/policy"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// Version is the protocol version that a Palette belongs to, such as 486 for 1.18.10.
+type Version int32
+
+// Palette is the list of all block states of a single version, indexed by their runtime ID.
+type Palette struct {
+	states []Block
+	lookup func(name string, properties map[string]any) (uint32, bool)
+	// hash is the SHA-256 hash of the hashes of all block states, which identifies the palette in the table cache.
+	hash [sha256.Size]byte
+	// lookupKey is the SHA-256 hash of the data set using SetLookupKey, which identifies the lookup function in the
+	// table cache.
+	lookupKey [sha256.Size]byte
+}
+
+// NewPalette creates a Palette holding the block states passed, indexed by their runtime ID. lookup is used to find
+// the runtime ID of block states of other versions in the Palette, which allows it to resolve renamed blocks. If
+// lookup is nil, block states are looked up by their Hash only. An error is returned if two block states passed
+// produce the same Hash or if a block state can't be hashed.
+func NewPalette(states []Block, lookup func(name string, properties map[string]any) (uint32, bool)) (*Palette, error) {
+	hashes := make([]Hash, len(states))
+	for rid, s := range states {
+		hash, err := HashBlockChecked(s)
+		if err != nil {
+			return nil, fmt.Errorf("hash block state %v: %w", rid, err)
+		}
+		hashes[rid] = hash
+	}
+	if lookup == nil {
+		ids := make(map[Hash]uint32, len(states))
+		for rid, s := range states {
+			if err := CheckCollision(ids, s, uint32(rid)); err != nil {
+				return nil, err
+			}
+			ids[hashes[rid]] = uint32(rid)
+		}
+		lookup = func(name string, properties map[string]any) (uint32, bool) {
+			return lookupHash(ids, name, properties)
+		}
+	}
+	h := sha256.New()
+	for _, hash := range hashes {
+		writeHashString(h, hash.Name)
+		writeHashString(h, hash.Properties)
+	}
+	p := &Palette{states: states, lookup: lookup}
+	h.Sum(p.hash[:0])
+	return p, nil
+}
+
+// SetLookupKey sets the data that the lookup function passed to NewPalette depends on, such as the block aliases
+// that it resolves. Cached tables translating to the Palette were generated using its lookup function, so they are
+// only loaded if the same data was set when they were stored. SetLookupKey must be called before the Palette is
+// registered.
+func (p *Palette) SetLookupKey(data []byte) {
+	p.lookupKey = sha256.Sum256(data)
+}
+
+// DecodePalette decodes the block states of a palette as found in block_states.nbt files: a stream of little endian
+// NBT compounds holding the name and properties of every block state, in the order of their runtime IDs. The
+// properties of the block states are normalised (see Normalise).
+func DecodePalette(data []byte) ([]Block, error) {
+	var states []Block
+	buf := bytes.NewBuffer(data)
+	dec := nbt.NewDecoder(buf)
+	for buf.Len() > 0 {
+		var s Block
+		if err := dec.Decode(&s); err != nil {
+			return nil, fmt.Errorf("decode block state %v: %w", len(states), err)
+		}
+		s.Properties = Normalise(s.Properties)
+		states = append(states, s)
+	}
+	return states, nil
+}
+
+// lookupHash looks up the runtime ID of the block state with the name and properties passed in the map of hashes
+// passed. Block states that can't be hashed are never found.
+func lookupHash(ids map[Hash]uint32, name string, properties map[string]any) (uint32, bool) {
+	hash, err := HashBlockChecked(Block{Name: name, Properties: properties})
+	if err != nil {
+		return 0, false
+	}
+	rid, ok := ids[hash]
+	return rid, ok
+}
+
+// RuntimeID looks up the runtime ID of the block state with the name and properties passed in the Palette.
+func (p *Palette) RuntimeID(name string, properties map[string]any) (uint32, bool) {
+	return p.lookup(name, properties)
+}
+
+// State returns the block state with the runtime ID passed. False is returned if the Palette holds no block state
+// with that runtime ID.
+func (p *Palette) State(rid uint32) (Block, bool) {
+	if rid >= uint32(len(p.states)) {
+		return Block{}, false
+	}
+	return p.states[rid], true
+}
+
+// Len returns the amount of block states in the Palette.
+func (p *Palette) Len() uint32 {
+	return uint32(len(p.states))
+}
+
+// unmapped is the value of block states in a translation table that have no equivalent in the other version.
+const unmapped = math.MaxUint32
+
+// tableKey is the key of the translation table from one version to another.
+type tableKey struct {
+	from, to Version
+}
+
+// Registry holds the palettes of a set of versions and the tables translating runtime IDs between them. The
+// package-level functions, such as RegisterPalette and TranslateRuntimeID, use the default Registry returned by
+// Default, which holds the palettes registered by the mappings packages. Other registries may be created for single
+// connections, for example to hold palettes with the custom blocks of a server (see Palette.WithCustomBlocks).
+// A Registry is safe for concurrent use.
+type Registry struct {
+	// mu guards palettes and tables.
+	mu sync.RWMutex
+	// palettes holds all palettes registered using RegisterPalette, keyed by their version.
+	palettes map[Version]*Palette
+	// tables holds the runtime ID translation tables generated between registered palettes. Every table is indexed
+	// by the runtime ID of the version translated from.
+	tables map[tableKey][]uint32
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{palettes: map[Version]*Palette{}, tables: map[tableKey][]uint32{}}
+}
+
+// defaultRegistry is the Registry used by the package-level functions.
+var defaultRegistry = NewRegistry()
+
+// Default returns the default Registry, which holds the palettes registered using the package-level RegisterPalette.
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Clone returns a new Registry holding the same palettes as the Registry, and the tables generated between them so
+// far. Palettes registered in either Registry afterwards are not registered in the other, so that a connection may
+// start with a Clone of the Default registry and replace the palette of a single version.
+func (r *Registry) Clone() *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c := &Registry{palettes: make(map[Version]*Palette, len(r.palettes)), tables: make(map[tableKey][]uint32, len(r.tables))}
+	for v, p := range r.palettes {
+		c.palettes[v] = p
+	}
+	for k, t := range r.tables {
+		// Tables are never modified once generated, so they may be shared.
+		c.tables[k] = t
+	}
+	return c
+}
+
+// RegisterPalette registers the Palette of the Version passed. The tables to translate runtime IDs between it and
+// other palettes are generated the first time they are used, or loaded from the table cache if one is set using
+// SetTableCache. Registering a Palette for a Version that already has one replaces it.
+func (r *Registry) RegisterPalette(v Version, p *Palette) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.palettes[v] = p
+	for k := range r.tables {
+		if k.from == v || k.to == v {
+			delete(r.tables, k)
+		}
+	}
+}
+
+// PaletteOf returns the Palette registered for the Version passed. False is returned if none is registered.
+func (r *Registry) PaletteOf(v Version) (*Palette, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.palettes[v]
+	return p, ok
+}
+
+// translationTableOf returns the table translating runtime IDs from the Version from to the Version to, generating
+// it if it wasn't used before. False is returned if either version has no Palette registered.
+func (r *Registry) translationTableOf(from, to Version) ([]uint32, bool) {
+	k := tableKey{from: from, to: to}
+	r.mu.RLock()
+	table, ok := r.tables[k]
+	r.mu.RUnlock()
+	if ok {
+		return table, true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if table, ok := r.tables[k]; ok {
+		// The table was generated while the lock was released.
+		return table, true
+	}
+	f, ok := r.palettes[from]
+	if !ok {
+		return nil, false
+	}
+	t, ok := r.palettes[to]
+	if !ok {
+		return nil, false
+	}
+	if table, ok = loadTable(f, t); !ok {
+		table = translationTable(f, t)
+		storeTable(f, t, table)
+	}
+	r.tables[k] = table
+	return table, true
+}
+
+// TranslateRuntimeID translates a block runtime ID of the Version from to the runtime ID of the same block state in
+// the Version to. False is returned if either version has no Palette registered, or if the block state has no
+// equivalent in the Version to.
+func (r *Registry) TranslateRuntimeID(from, to Version, rid uint32) (uint32, bool) {
+	if from == to {
+		return rid, true
+	}
+	table, ok := r.translationTableOf(from, to)
+	if !ok || rid >= uint32(len(table)) || table[rid] == unmapped {
+		return 0, false
+	}
+	return table[rid], true
+}
+
+// ConvertRuntimeID translates a block runtime ID of the Version from to the runtime ID of the same block state in
+// the Version to, like TranslateRuntimeID. Block states without an equivalent are translated to air, so that
+// clients never receive runtime IDs that they don't know.
+func (r *Registry) ConvertRuntimeID(from, to Version, rid uint32) uint32 {
+	if other, ok := r.TranslateRuntimeID(from, to, rid); ok {
+		return other
+	}
+	if p, ok := r.PaletteOf(to); ok {
+		if air, ok := p.RuntimeID("minecraft:air", nil); ok {
+			return air
+		}
+	}
+	return 0
+}
+
+// RegisterPalette registers the Palette of the Version passed in the Default registry, like
+// Registry.RegisterPalette. Palettes are generally registered in the init function of the package holding them.
+func RegisterPalette(v Version, p *Palette) {
+	defaultRegistry.RegisterPalette(v, p)
+}
+
+// PaletteOf returns the Palette registered for the Version passed in the Default registry. False is returned if none
+// is registered.
+func PaletteOf(v Version) (*Palette, bool) {
+	return defaultRegistry.PaletteOf(v)
+}
+
+// Fallback returns the runtime ID of the block that block states without an equivalent in the Version passed are
+// substituted with under the lenient decode policy: the policy.FallbackBlock, or air if the Version has no such
+// block. False is returned if no palette is registered for the Version.
+func (r *Registry) Fallback(v Version) (uint32, bool) {
+	p, ok := r.PaletteOf(v)
+	if !ok {
+		return 0, false
+	}
+	if rid, ok := p.RuntimeID(policy.FallbackBlock(), nil); ok {
+		return rid, true
+	}
+	return p.RuntimeID("minecraft:air", nil)
+}
+
+// Fallback returns the runtime ID of the fallback block of the Version passed in the Default registry, like
+// Registry.Fallback.
+func Fallback(v Version) (uint32, bool) {
+	return defaultRegistry.Fallback(v)
+}
+
+// translationTable generates the table translating runtime IDs of the Palette from to runtime IDs of the Palette to.
+func translationTable(from, to *Palette) []uint32 {
+	table := make([]uint32, len(from.states))
+	for rid, s := range from.states {
+		table[rid] = unmapped
+		if other, ok := to.RuntimeID(s.Name, s.Properties); ok {
+			table[rid] = other
+		}
+	}
+	return table
+}
+
+// TranslateRuntimeID translates a block runtime ID of the Version from to the runtime ID of the same block state in
+// the Version to using the Default registry, like Registry.TranslateRuntimeID.
+func TranslateRuntimeID(from, to Version, rid uint32) (uint32, bool) {
+	return defaultRegistry.TranslateRuntimeID(from, to, rid)
+}
+
+// ConvertRuntimeID translates a block runtime ID of the Version from to the runtime ID of the same block state in
+// the Version to using the Default registry, like Registry.ConvertRuntimeID.
+func ConvertRuntimeID(from, to Version, rid uint32) uint32 {
+	return defaultRegistry.ConvertRuntimeID(from, to, rid)
+}
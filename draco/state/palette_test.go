@@ -0,0 +1,124 @@
+package state
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+)
+
+func TestConvertRuntimeID(t *testing.T) {
+	old, err := NewPalette([]Block{
+		{Name: "minecraft:air"},
+		{Name: "minecraft:stone", Properties: map[string]any{"stone_type": "granite"}},
+		{Name: "minecraft:old_block"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := NewPalette([]Block{
+		{Name: "minecraft:stone", Properties: map[string]any{"stone_type": "granite"}},
+		{Name: "minecraft:new_block"},
+		{Name: "minecraft:air"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterPalette(-1, old)
+	RegisterPalette(-2, latest)
+
+	if rid, ok := TranslateRuntimeID(-1, -2, 1); !ok || rid != 0 {
+		t.Errorf("stone translated to %v (found: %v), expected 0", rid, ok)
+	}
+	if rid, ok := TranslateRuntimeID(-2, -1, 0); !ok || rid != 1 {
+		t.Errorf("stone translated back to %v (found: %v), expected 1", rid, ok)
+	}
+	if _, ok := TranslateRuntimeID(-1, -2, 2); ok {
+		t.Error("block state without equivalent was translated")
+	}
+	if rid := ConvertRuntimeID(-1, -2, 2); rid != 2 {
+		t.Errorf("block state without equivalent converted to %v, expected air (2)", rid)
+	}
+	if rid := ConvertRuntimeID(-2, -1, 1); rid != 0 {
+		t.Errorf("block state without equivalent converted to %v, expected air (0)", rid)
+	}
+}
+
+func TestNewPaletteCollision(t *testing.T) {
+	if _, err := NewPalette([]Block{{Name: "a"}, {Name: "a"}}, nil); err == nil {
+		t.Fatal("expected error for palette with duplicate block states")
+	}
+}
+
+func TestTableCache(t *testing.T) {
+	dir := t.TempDir()
+	if err := SetTableCache(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer SetTableCache("")
+
+	a, _ := NewPalette([]Block{{Name: "minecraft:air"}, {Name: "minecraft:dirt"}}, nil)
+	b, _ := NewPalette([]Block{{Name: "minecraft:dirt"}, {Name: "minecraft:air"}}, nil)
+	RegisterPalette(-3, a)
+	RegisterPalette(-4, b)
+	if rid, ok := TranslateRuntimeID(-3, -4, 1); !ok || rid != 0 {
+		t.Fatalf("dirt translated to %v (found: %v), expected 0", rid, ok)
+	}
+	table, ok := loadTable(a, b)
+	if !ok || len(table) != 2 || table[0] != 1 || table[1] != 0 {
+		t.Fatalf("unexpected cached table %v (found: %v)", table, ok)
+	}
+
+	// A palette with different block states must not use the table cached for the other one.
+	c, _ := NewPalette([]Block{{Name: "minecraft:air"}, {Name: "minecraft:stone"}}, nil)
+	if _, ok := loadTable(c, b); ok {
+		t.Fatal("table of a different palette was loaded from the cache")
+	}
+
+	// A table generated using a different lookup function must not be used either.
+	aliased, _ := NewPalette([]Block{{Name: "minecraft:dirt"}, {Name: "minecraft:air"}}, nil)
+	aliased.SetLookupKey([]byte("aliases"))
+	if _, ok := loadTable(a, aliased); ok {
+		t.Fatal("table generated with a different lookup function was loaded from the cache")
+	}
+
+	// Tables holding runtime IDs outside the palette translated to are never loaded.
+	path, _ := tablePath(a, b)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint32(data[len(tableHeader(a, b)):], 2)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if table, ok := loadTable(a, b); ok {
+		t.Fatalf("table %v with runtime ID out of range was loaded from the cache", table)
+	}
+}
+
+func TestRegistryClone(t *testing.T) {
+	from, _ := NewPalette([]Block{{Name: "minecraft:air"}, {Name: "minecraft:stone"}}, nil)
+	to, _ := NewPalette([]Block{{Name: "minecraft:stone"}, {Name: "minecraft:air"}}, nil)
+	r := NewRegistry()
+	r.RegisterPalette(-30, from)
+	r.RegisterPalette(-40, to)
+	if rid, ok := r.TranslateRuntimeID(-30, -40, 1); !ok || rid != 0 {
+		t.Errorf("stone translated to %v (found: %v), expected 0", rid, ok)
+	}
+	if _, ok := PaletteOf(-30); ok {
+		t.Error("palette registered in a registry was registered in the default registry")
+	}
+
+	custom, err := to.WithCustomBlocks([]Block{{Name: "minecraft:aaa"}}, OrderByName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := r.Clone()
+	c.RegisterPalette(-40, custom)
+	if rid, ok := c.TranslateRuntimeID(-30, -40, 1); !ok || rid != 2 {
+		t.Errorf("stone translated to %v (found: %v) in the clone, expected 2", rid, ok)
+	}
+	if rid, ok := r.TranslateRuntimeID(-30, -40, 1); !ok || rid != 0 {
+		t.Errorf("stone translated to %v (found: %v) after registering a palette in a clone, expected 0", rid, ok)
+	}
+}
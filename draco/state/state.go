@@ -1,7 +1,10 @@
 package state
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash"
+	"math"
 	"sort"
 	"strings"
 	"unsafe"
@@ -23,12 +26,39 @@ type Hash struct {
 	Name, Properties string
 }
 
-// HashBlock produces a Hash for the Block given.
+// Type tags written before the values of properties in a Hash.
+const (
+	typeBool byte = iota
+	typeUint8
+	typeInt32
+	typeString
+	typeInt64
+	typeFloat64
+)
+
+// HashBlock produces a Hash for the Block given. Two blocks produce the same Hash if and only if they have the same
+// name and the same properties, regardless of the order of the properties in the map. Property values of types
+// other than bool, uint8, int32 and string, as found in some third-party palette dumps, are normalised first (see
+// Normalise), so that an int16 property produces the same Hash as the same int32 property. HashBlock panics if a
+// property has a value of any other type, which is meant for palettes embedded in draco. Block states received from
+// the network should be hashed using HashBlockChecked instead.
 func HashBlock(state Block) Hash {
+	hash, err := HashBlockChecked(state)
+	if err != nil {
+		// If block encoding is broken, we want to find out as soon as possible. This saves a lot of time debugging
+		// in-game.
+		panic(err)
+	}
+	return hash
+}
+
+// HashBlockChecked produces a Hash for the Block given, like HashBlock. An error is returned rather than panicking if
+// a property has a value of a type that can't be hashed.
+func HashBlockChecked(state Block) (Hash, error) {
 	hash := Hash{Name: state.Name}
 	if state.Properties == nil {
 		// If the properties are nil, we don't need to hash them.
-		return hash
+		return hash, nil
 	}
 
 	keys := make([]string, 0, len(state.Properties))
@@ -39,29 +69,140 @@ func HashBlock(state Block) Hash {
 		return keys[i] < keys[j]
 	})
 
+	// Every property is encoded as its key, followed by a byte indicating the type of its value and the value
+	// itself. Strings are prefixed with their length, so that no two different sets of properties produce the
+	// same encoding.
 	var b strings.Builder
 	for _, k := range keys {
-		switch v := state.Properties[k].(type) {
+		writeString(&b, k)
+		switch v := normalise(k, state.Properties[k]).(type) {
 		case bool:
+			b.WriteByte(typeBool)
 			if v {
 				b.WriteByte(1)
 			} else {
 				b.WriteByte(0)
 			}
 		case uint8:
+			b.WriteByte(typeUint8)
 			b.WriteByte(v)
 		case int32:
+			b.WriteByte(typeInt32)
 			a := *(*[4]byte)(unsafe.Pointer(&v))
 			b.Write(a[:])
 		case string:
-			b.WriteString(v)
+			b.WriteByte(typeString)
+			writeString(&b, v)
+		case int64:
+			b.WriteByte(typeInt64)
+			a := *(*[8]byte)(unsafe.Pointer(&v))
+			b.Write(a[:])
+		case float64:
+			b.WriteByte(typeFloat64)
+			a := *(*[8]byte)(unsafe.Pointer(&v))
+			b.Write(a[:])
 		default:
-			// If block encoding is broken, we want to find out as soon as possible. This saves a lot of time
-			// debugging in-game.
-			panic(fmt.Sprintf("invalid block property type %T for property %v", v, k))
+			return Hash{}, fmt.Errorf("invalid block property type %T for property %v of block %v", v, k, state.Name)
 		}
 	}
 
 	hash.Properties = b.String()
-	return hash
+	return hash, nil
+}
+
+// Normalise returns a copy of the properties passed with all values converted to the canonical types of block
+// properties: bool, uint8, int32 and string. Integers of other sizes are converted to int32 and floats holding an
+// integer to int32, as long as they fit. Values that don't fit are kept as int64 or float64, which never equal a
+// canonical value. If draco is built with the strictstates build tag, Normalise panics on any value that is not of
+// a canonical type instead, so that broken palettes are found during development.
+func Normalise(properties map[string]any) map[string]any {
+	if properties == nil {
+		return nil
+	}
+	m := make(map[string]any, len(properties))
+	for k, v := range properties {
+		m[k] = normalise(k, v)
+	}
+	return m
+}
+
+// normalise converts the value of the property with the key passed to its canonical type.
+func normalise(k string, v any) any {
+	var i int64
+	switch v := v.(type) {
+	case bool, uint8, int32, string:
+		return v
+	case int8:
+		nonCanonical(k, v)
+		// Bytes are always stored unsigned.
+		return uint8(v)
+	case int16:
+		i = int64(v)
+	case uint16:
+		i = int64(v)
+	case int:
+		i = int64(v)
+	case uint32:
+		i = int64(v)
+	case int64:
+		i = v
+	case float32:
+		nonCanonical(k, v)
+		return normaliseFloat(float64(v))
+	case float64:
+		nonCanonical(k, v)
+		return normaliseFloat(v)
+	default:
+		// Unknown types are left as they are, so that HashBlock panics on them.
+		return v
+	}
+	nonCanonical(k, v)
+	if i < math.MinInt32 || i > math.MaxInt32 {
+		return i
+	}
+	return int32(i)
+}
+
+// normaliseFloat converts a float property value to an int32 if it holds one that fits.
+func normaliseFloat(f float64) any {
+	if f != math.Trunc(f) || f < math.MinInt32 || f > math.MaxInt32 {
+		return f
+	}
+	return int32(f)
+}
+
+// nonCanonical panics if draco is built in strict mode, as the value of the property with the key passed is not of
+// a canonical type.
+func nonCanonical(k string, v any) {
+	if strict {
+		panic(fmt.Sprintf("non-canonical block property type %T for property %v", v, k))
+	}
+}
+
+// writeString writes a string prefixed with its length as a varuint32 to the builder passed.
+func writeString(b *strings.Builder, s string) {
+	var l [binary.MaxVarintLen32]byte
+	b.Write(l[:binary.PutUvarint(l[:], uint64(len(s)))])
+	b.WriteString(s)
+}
+
+// writeHashString writes a string prefixed with its length as a varuint32 to the hash passed.
+func writeHashString(h hash.Hash, s string) {
+	var l [binary.MaxVarintLen32]byte
+	h.Write(l[:binary.PutUvarint(l[:], uint64(len(s)))])
+	h.Write([]byte(s))
+}
+
+// CheckCollision returns an error if the Block passed produces the same Hash as a Block registered with a different
+// runtime ID, or if it can't be hashed. It should be called when loading a palette of block states, as a collision
+// would silently map the runtime IDs of two different block states to one of them.
+func CheckCollision(registered map[Hash]uint32, s Block, rid uint32) error {
+	hash, err := HashBlockChecked(s)
+	if err != nil {
+		return err
+	}
+	if other, ok := registered[hash]; ok && other != rid {
+		return fmt.Errorf("block state %v%v (%v) has the same hash as the block state with runtime ID %v", s.Name, s.Properties, rid, other)
+	}
+	return nil
 }
@@ -0,0 +1,159 @@
+package state
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// randomProperties returns a random set of properties with values of all types supported by HashBlock. Keys and
+// string values are picked from a small set so that different sets of properties often share keys and values.
+func randomProperties(r *rand.Rand) map[string]any {
+	strs := []string{"", "a", "b", "ab", "ba", "\x00", "\x01a", "north", "north_east"}
+	m := map[string]any{}
+	for i, n := 0, r.Intn(5); i < n; i++ {
+		k := strs[r.Intn(len(strs))]
+		switch r.Intn(4) {
+		case 0:
+			m[k] = r.Intn(2) == 1
+		case 1:
+			m[k] = uint8(r.Intn(3))
+		case 2:
+			m[k] = int32(r.Intn(3))
+		case 3:
+			m[k] = strs[r.Intn(len(strs))]
+		}
+	}
+	return m
+}
+
+// properties implements quick.Generator to generate random sets of properties.
+type properties map[string]any
+
+// Generate ...
+func (properties) Generate(r *rand.Rand, _ int) reflect.Value {
+	return reflect.ValueOf(properties(randomProperties(r)))
+}
+
+func TestHashBlockInjective(t *testing.T) {
+	f := func(a, b properties) bool {
+		equal := reflect.DeepEqual(map[string]any(a), map[string]any(b))
+		return equal == (HashBlock(Block{Name: "test", Properties: a}) == HashBlock(Block{Name: "test", Properties: b}))
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 100000}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHashBlockKnownCollisions(t *testing.T) {
+	// Pairs of different properties that produced the same hash when keys, types and string lengths were not
+	// part of it.
+	pairs := [][2]map[string]any{
+		{{"a": "xy", "b": ""}, {"a": "x", "b": "y"}},
+		{{"a": true}, {"a": uint8(1)}},
+		{{"a": int32(0)}, {"a": "\x00\x00\x00\x00"}},
+		{{"a": uint8(1)}, {"b": uint8(1)}},
+		{{"a": "b"}, {"ab": ""}},
+	}
+	for _, p := range pairs {
+		if HashBlock(Block{Properties: p[0]}) == HashBlock(Block{Properties: p[1]}) {
+			t.Errorf("properties %v and %v produce the same hash", p[0], p[1])
+		}
+	}
+}
+
+func TestHashBlockDeterministic(t *testing.T) {
+	f := func(p properties) bool {
+		h := HashBlock(Block{Name: "test", Properties: p})
+		for i := 0; i < 10; i++ {
+			// Build a copy of the map, which has a different iteration order.
+			c := make(map[string]any, len(p))
+			for k, v := range p {
+				c[k] = v
+			}
+			if HashBlock(Block{Name: "test", Properties: c}) != h {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHashBlockName(t *testing.T) {
+	p := map[string]any{"a": uint8(1)}
+	if HashBlock(Block{Name: "a", Properties: p}) == HashBlock(Block{Name: "b", Properties: p}) {
+		t.Fatal("blocks with different names produce the same hash")
+	}
+	if HashBlock(Block{Name: "a"}) != HashBlock(Block{Name: "a", Properties: map[string]any{}}) {
+		t.Fatal("blocks with nil and empty properties produce different hashes")
+	}
+}
+
+func TestCheckCollision(t *testing.T) {
+	a := Block{Name: "a", Properties: map[string]any{"x": uint8(1)}}
+	registered := map[Hash]uint32{HashBlock(a): 0}
+	if err := CheckCollision(registered, a, 0); err != nil {
+		t.Fatalf("block state collides with itself: %v", err)
+	}
+	if err := CheckCollision(registered, a, 1); err == nil {
+		t.Fatal("expected collision of block state registered with a different runtime ID")
+	}
+	if err := CheckCollision(registered, Block{Name: "a", Properties: map[string]any{"x": uint8(2)}}, 1); err != nil {
+		t.Fatalf("unexpected collision: %v", err)
+	}
+}
+
+func TestHashBlockChecked(t *testing.T) {
+	invalid := Block{Name: "a", Properties: map[string]any{"x": []any{int32(1)}}}
+	if _, err := HashBlockChecked(invalid); err == nil {
+		t.Error("expected error hashing a block state with a list property")
+	}
+	if err := CheckCollision(map[Hash]uint32{}, invalid, 0); err == nil {
+		t.Error("expected error checking a block state that can't be hashed for collisions")
+	}
+	if _, err := NewPalette([]Block{{Name: "minecraft:air"}, invalid}, nil); err == nil {
+		t.Error("expected error creating a palette holding a block state that can't be hashed")
+	}
+	p, err := NewPalette([]Block{{Name: "minecraft:air"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.RuntimeID(invalid.Name, invalid.Properties); ok {
+		t.Error("block state that can't be hashed was found in the palette")
+	}
+}
+
+func TestHashBlockNormalise(t *testing.T) {
+	if strict {
+		t.Skip("non-canonical property types are rejected in strict mode")
+	}
+	pairs := [][2]map[string]any{
+		{{"a": int16(3)}, {"a": int32(3)}},
+		{{"a": int64(3)}, {"a": int32(3)}},
+		{{"a": float32(3)}, {"a": int32(3)}},
+		{{"a": 3.0}, {"a": int32(3)}},
+		{{"a": int8(1)}, {"a": uint8(1)}},
+	}
+	for _, p := range pairs {
+		if HashBlock(Block{Properties: p[0]}) != HashBlock(Block{Properties: p[1]}) {
+			t.Errorf("%v and %v produce different hashes", p[0], p[1])
+		}
+	}
+	distinct := [][2]map[string]any{
+		{{"a": 3.5}, {"a": int32(3)}},
+		{{"a": int64(math.MaxInt32 + 1)}, {"a": int32(math.MinInt32)}},
+	}
+	for _, p := range distinct {
+		if HashBlock(Block{Properties: p[0]}) == HashBlock(Block{Properties: p[1]}) {
+			t.Errorf("%v and %v produce the same hash", p[0], p[1])
+		}
+	}
+	if v := Normalise(map[string]any{"a": int16(2)})["a"]; v != int32(2) {
+		t.Errorf("int16 property normalised to %T(%v), expected int32(2)", v, v)
+	}
+}
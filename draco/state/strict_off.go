@@ -0,0 +1,6 @@
+//go:build !strictstates
+
+package state
+
+// strict specifies if block properties of non-canonical types are rejected rather than normalised.
+const strict = false
@@ -0,0 +1,80 @@
+package status
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+)
+
+// Override overrides parts of the status obtained from a Provider. Its fields may hold the placeholders {name},
+// {online} and {max}, which are replaced with the server name, player count and maximum amount of players that the
+// Provider returned, such as those of a backend pinged by Foreign. Empty fields keep the value of the Provider.
+// The sub-MOTD, or level name, shown in the server list can't be overridden, as gophertunnel always shows the same
+// one for the proxy.
+type Override struct {
+	// ServerName is the server name, or MOTD, shown, such as "Draco - {online} playing".
+	ServerName string
+	// PlayerCount and MaxPlayers are the player count and maximum amount of players shown, which must be numbers
+	// after the placeholders are replaced, such as "{max}" or "500".
+	PlayerCount, MaxPlayers string
+}
+
+// apply returns the status passed with the Override applied.
+func (o Override) apply(st minecraft.ServerStatus) (minecraft.ServerStatus, error) {
+	r := strings.NewReplacer("{name}", st.ServerName, "{online}", strconv.Itoa(st.PlayerCount), "{max}", strconv.Itoa(st.MaxPlayers))
+	out := st
+	if o.ServerName != "" {
+		out.ServerName = r.Replace(o.ServerName)
+	}
+	for _, f := range []struct {
+		name, template string
+		v              *int
+	}{{"player count", o.PlayerCount, &out.PlayerCount}, {"max players", o.MaxPlayers, &out.MaxPlayers}} {
+		if f.template == "" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(r.Replace(f.template)))
+		if err != nil {
+			return st, fmt.Errorf("override %v: %q is not a number", f.name, r.Replace(f.template))
+		}
+		*f.v = n
+	}
+	return out, nil
+}
+
+// Cached is a Provider that caches the status of another Provider, such as Foreign, so that the server it pings is
+// pinged at most once per TTL rather than at every interval of a Chain, and applies an Override to it.
+type Cached struct {
+	provider Provider
+	ttl      time.Duration
+	override Override
+
+	mu      sync.Mutex
+	status  minecraft.ServerStatus
+	updated time.Time
+}
+
+// NewCached returns a Cached caching the status of the Provider passed for the TTL passed, applying the Override
+// passed to it. If the TTL is 0, the status is not cached.
+func NewCached(p Provider, ttl time.Duration, o Override) *Cached {
+	return &Cached{provider: p, ttl: ttl, override: o}
+}
+
+// Status returns the status last obtained from the Provider of the Cached with the Override applied, obtaining it
+// again if it was obtained longer than the TTL ago. An error is returned if the Provider fails then.
+func (c *Cached) Status() (minecraft.ServerStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.updated.IsZero() || time.Since(c.updated) >= c.ttl {
+		st, err := c.provider.Status()
+		if err != nil {
+			return minecraft.ServerStatus{}, err
+		}
+		c.status, c.updated = st, time.Now()
+	}
+	return c.override.apply(c.status)
+}
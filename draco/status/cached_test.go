@@ -0,0 +1,45 @@
+package status
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+)
+
+// countingProvider is a Provider that counts how often it was asked for its status.
+type countingProvider struct {
+	calls int
+	err   error
+}
+
+func (p *countingProvider) Status() (minecraft.ServerStatus, error) {
+	p.calls++
+	return minecraft.ServerStatus{ServerName: "Backend", PlayerCount: 10, MaxPlayers: 100}, p.err
+}
+
+func TestCached(t *testing.T) {
+	p := &countingProvider{}
+	c := NewCached(p, time.Hour, Override{ServerName: "Draco ({name}): {online}/{max}", MaxPlayers: "{online}"})
+	for i := 0; i < 3; i++ {
+		st, err := c.Status()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (minecraft.ServerStatus{ServerName: "Draco (Backend): 10/100", PlayerCount: 10, MaxPlayers: 10}); st != want {
+			t.Fatalf("status %+v, expected %+v", st, want)
+		}
+	}
+	if p.calls != 1 {
+		t.Fatalf("provider called %v times within the TTL, expected once", p.calls)
+	}
+
+	p.err = errors.New("unreachable")
+	if _, err := NewCached(p, 0, Override{}).Status(); err == nil {
+		t.Fatal("error of the provider not returned")
+	}
+	if _, err := NewCached(&countingProvider{}, 0, Override{PlayerCount: "{name}"}).Status(); err == nil {
+		t.Fatal("player count that is not a number was not rejected")
+	}
+}
@@ -0,0 +1,181 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/go-raknet"
+	"github.com/sandertv/gophertunnel/minecraft"
+)
+
+// Static is a Provider with a fixed server name. The player count is that of the proxy.
+type Static struct {
+	// ServerName is the name, or MOTD, shown in the server list.
+	ServerName string
+}
+
+// Status ...
+func (s Static) Status() (minecraft.ServerStatus, error) {
+	return minecraft.ServerStatus{ServerName: s.ServerName, PlayerCount: proxy.PlayerCount()}, nil
+}
+
+// Foreign is a Provider that shows the status of another server, such as a backend, by pinging it.
+type Foreign struct {
+	// Address is the address of the server.
+	Address string
+	// Timeout is the maximum duration to wait for the server to respond.
+	Timeout time.Duration
+}
+
+// Status ...
+func (f Foreign) Status() (minecraft.ServerStatus, error) {
+	data, err := raknet.PingTimeout(f.Address, f.Timeout)
+	if err != nil {
+		return minecraft.ServerStatus{}, fmt.Errorf("ping %v: %w", f.Address, err)
+	}
+	return parsePong(data)
+}
+
+// Aggregate is a Provider that shows the combined player counts of a list of servers, such as all backends. The
+// server name is that of the first server in the list that responded.
+type Aggregate struct {
+	// Addresses holds the addresses of the servers.
+	Addresses []string
+	// Timeout is the maximum duration to wait for every server to respond.
+	Timeout time.Duration
+}
+
+// Status pings all servers simultaneously. An error is only returned if none of them responded.
+func (a Aggregate) Status() (minecraft.ServerStatus, error) {
+	statuses, errs := make([]minecraft.ServerStatus, len(a.Addresses)), make([]error, len(a.Addresses))
+	var wg sync.WaitGroup
+	for i, addr := range a.Addresses {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			statuses[i], errs[i] = Foreign{Address: addr, Timeout: a.Timeout}.Status()
+		}(i, addr)
+	}
+	wg.Wait()
+
+	var total minecraft.ServerStatus
+	responded := false
+	for i, st := range statuses {
+		if errs[i] != nil {
+			continue
+		}
+		if !responded {
+			total.ServerName, responded = st.ServerName, true
+		}
+		total.PlayerCount += st.PlayerCount
+		total.MaxPlayers += st.MaxPlayers
+	}
+	if !responded {
+		return total, fmt.Errorf("no server responded: %w", errors.New(joinErrors(errs)))
+	}
+	return total, nil
+}
+
+// Script is a Provider that obtains the status by running a command, which must print a JSON object such as
+// {"server_name": "Draco", "player_count": 10, "max_players": 100} to stdout. If player_count is left out, the
+// player count of the proxy is used.
+type Script struct {
+	// Command holds the name of the command followed by its arguments.
+	Command []string
+	// Timeout is the maximum duration the command may run for.
+	Timeout time.Duration
+}
+
+// Status ...
+func (s Script) Status() (minecraft.ServerStatus, error) {
+	if len(s.Command) == 0 {
+		return minecraft.ServerStatus{}, errors.New("no command set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return minecraft.ServerStatus{}, fmt.Errorf("run %v: %w: %s", s.Command[0], err, strings.TrimSpace(stderr.String()))
+	}
+	var out struct {
+		ServerName  string `json:"server_name"`
+		PlayerCount *int   `json:"player_count"`
+		MaxPlayers  int    `json:"max_players"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return minecraft.ServerStatus{}, fmt.Errorf("decode output of %v: %w", s.Command[0], err)
+	}
+	st := minecraft.ServerStatus{ServerName: out.ServerName, PlayerCount: proxy.PlayerCount(), MaxPlayers: out.MaxPlayers}
+	if out.PlayerCount != nil {
+		st.PlayerCount = *out.PlayerCount
+	}
+	return st, nil
+}
+
+// parsePong parses the data of an unconnected pong, such as
+// MCPE;Dedicated Server;486;1.18.10;0;10;13253860892328930865;Bedrock level;Survival;1;19132;19133;
+func parsePong(data []byte) (minecraft.ServerStatus, error) {
+	frag := splitPong(string(data))
+	if len(frag) < 6 {
+		return minecraft.ServerStatus{}, fmt.Errorf("invalid pong data %q", data)
+	}
+	online, err := strconv.Atoi(frag[4])
+	if err != nil {
+		return minecraft.ServerStatus{}, fmt.Errorf("invalid player count %q", frag[4])
+	}
+	max, err := strconv.Atoi(frag[5])
+	if err != nil {
+		return minecraft.ServerStatus{}, fmt.Errorf("invalid max player count %q", frag[5])
+	}
+	return minecraft.ServerStatus{ServerName: frag[1], PlayerCount: online, MaxPlayers: max}, nil
+}
+
+// splitPong splits the pong data passed by ;, taking into account that ; may be escaped using a backslash.
+func splitPong(s string) []string {
+	var (
+		tokens   []string
+		b        strings.Builder
+		inEscape bool
+	)
+	for _, r := range s {
+		switch {
+		case inEscape:
+			inEscape = false
+			b.WriteRune(r)
+		case r == '\\':
+			inEscape = true
+		case r == ';':
+			tokens = append(tokens, b.String())
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() > 0 {
+		tokens = append(tokens, b.String())
+	}
+	return tokens
+}
+
+// joinErrors joins the messages of all non-nil errors passed.
+func joinErrors(errs []error) string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,96 @@
+package status
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+)
+
+// Provider provides the status of the proxy shown in the server list. Unlike a minecraft.ServerStatusProvider, a
+// Provider may fail, which makes a Chain fall back to the next Provider.
+type Provider interface {
+	// Status returns the current status, or an error if it could not be obtained.
+	Status() (minecraft.ServerStatus, error)
+}
+
+// Chain is a minecraft.ServerStatusProvider that obtains the status from a list of providers. The providers are
+// tried in order at a fixed interval, and the status of the first one that does not return an error is used. If
+// all providers fail, the last status obtained is kept.
+// Providers are called in the background, so that a slow Provider does not delay responses to clients pinging
+// the proxy.
+type Chain struct {
+	// mu guards providers and status.
+	mu        sync.Mutex
+	providers []Provider
+	status    minecraft.ServerStatus
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewChain creates a Chain trying the providers passed in order every interval, until Close is called. The status
+// is obtained once before NewChain returns.
+func NewChain(interval time.Duration, providers ...Provider) *Chain {
+	c := &Chain{providers: providers, closed: make(chan struct{})}
+	c.update()
+	go c.run(interval)
+	return c
+}
+
+// ServerStatus returns the status last obtained from the providers of the Chain.
+func (c *Chain) ServerStatus(int, int) minecraft.ServerStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// SetProviders replaces the providers of the Chain with those passed, such as after the config of the proxy was
+// reloaded, and obtains the status from them before returning.
+func (c *Chain) SetProviders(providers ...Provider) {
+	c.mu.Lock()
+	c.providers = providers
+	c.mu.Unlock()
+	c.update()
+}
+
+// Close stops updating the status of the Chain. Close always returns nil.
+func (c *Chain) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+// run updates the status every interval until the Chain is closed.
+func (c *Chain) run(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			c.update()
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// update obtains the status from the first Provider that does not fail.
+func (c *Chain) update() {
+	c.mu.Lock()
+	providers := c.providers
+	c.mu.Unlock()
+	for _, p := range providers {
+		st, err := p.Status()
+		if err != nil {
+			log.Printf("status provider %T failed: %v", p, err)
+			continue
+		}
+		c.mu.Lock()
+		c.status = st
+		c.mu.Unlock()
+		return
+	}
+}
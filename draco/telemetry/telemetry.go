@@ -0,0 +1,238 @@
+// Package telemetry reports anonymised, aggregate statistics of the gaps of the translator, such as block states,
+// items and sounds without an equivalent in the version they are translated to, to an endpoint chosen by the
+// operator, so that maintainers may prioritise the mapping tables that real traffic needs most. Telemetry is opt-in:
+// nothing is recorded until a Reporter is started. Only the kind of a gap, the versions it was found between, the ID
+// that couldn't be translated and how often it was found are reported, never anything about players, backends or the
+// proxy itself.
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/state"
+)
+
+const (
+	// KindBlock is the Kind of gaps of block states, identified by their name and properties.
+	KindBlock = "block"
+	// KindItem is the Kind of gaps of items, identified by their name, or by their runtime ID if the version
+	// translated from doesn't have the item either.
+	KindItem = "item"
+	// KindSound is the Kind of gaps of sounds played using LevelSoundEvent packets, identified by their ID.
+	KindSound = "sound"
+	// KindLevelEvent is the Kind of gaps of the types of LevelEvent packets, including legacy particles.
+	KindLevelEvent = "level_event"
+	// KindParticleEffect is the Kind of gaps of particle effects, identified by their identifier.
+	KindParticleEffect = "particle_effect"
+)
+
+// Gap is an ID that couldn't be translated between two versions, with how often it was found since it was last
+// reported.
+type Gap struct {
+	// Kind is the kind of the ID, such as KindBlock.
+	Kind string `json:"kind"`
+	// From and To are the protocol versions that the ID was translated from and to.
+	From state.Version `json:"from"`
+	To   state.Version `json:"to"`
+	// ID identifies what couldn't be translated, such as "minecraft:sculk_vein[multi_face_direction_bits=0]".
+	ID string `json:"id"`
+	// Count is the amount of times that the ID was found.
+	Count uint64 `json:"count"`
+}
+
+// Report is the body posted to the endpoint of a Reporter.
+type Report struct {
+	// Gaps holds the gaps found since the last report, found most often first.
+	Gaps []Gap `json:"gaps"`
+	// Dropped is the amount of times that a gap was found that couldn't be recorded, because too many different gaps
+	// were already recorded.
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+// maxGaps is the maximum amount of different gaps recorded between two reports.
+const maxGaps = 4096
+
+// key identifies a Gap.
+type key struct {
+	kind     string
+	from, to state.Version
+	id       string
+}
+
+var (
+	// enabled is 1 while a Reporter is running, so that gaps are only recorded if telemetry is enabled.
+	enabled uint32
+	// mu guards gaps and dropped.
+	mu sync.Mutex
+	// gaps holds the amount of times that each gap was found since the last report.
+	gaps = map[key]uint64{}
+	// dropped is the amount of times that a gap was found while gaps was full.
+	dropped uint64
+)
+
+// Record records that the ID passed, of the Kind passed, couldn't be translated from the version from to the version
+// to. Nothing is recorded unless a Reporter is running.
+func Record(kind string, from, to state.Version, id string) {
+	if atomic.LoadUint32(&enabled) == 0 {
+		return
+	}
+	k := key{kind: kind, from: from, to: to, id: id}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := gaps[k]; !ok && len(gaps) >= maxGaps {
+		dropped++
+		return
+	}
+	gaps[k]++
+}
+
+// RecordBlock records that the block state with the runtime ID passed couldn't be translated from the version from to
+// the version to, identifying it by its name and properties in the version from.
+func RecordBlock(from, to state.Version, rid uint32) {
+	if atomic.LoadUint32(&enabled) == 0 {
+		return
+	}
+	Record(KindBlock, from, to, blockID(from, rid))
+}
+
+// blockID returns the name and properties of the block state with the runtime ID passed in the version passed, such
+// as "minecraft:wool[color=red]", or the runtime ID itself if the version doesn't have the block state.
+func blockID(v state.Version, rid uint32) string {
+	p, ok := state.PaletteOf(v)
+	if !ok {
+		return strconv.FormatUint(uint64(rid), 10)
+	}
+	b, ok := p.State(rid)
+	if !ok {
+		return strconv.FormatUint(uint64(rid), 10)
+	}
+	if len(b.Properties) == 0 {
+		return b.Name
+	}
+	properties := make([]string, 0, len(b.Properties))
+	for k, v := range b.Properties {
+		properties = append(properties, fmt.Sprintf("%v=%v", k, v))
+	}
+	sort.Strings(properties)
+	return b.Name + "[" + strings.Join(properties, ",") + "]"
+}
+
+// take returns a Report of the gaps recorded and forgets them.
+func take() Report {
+	mu.Lock()
+	r := Report{Gaps: make([]Gap, 0, len(gaps)), Dropped: dropped}
+	for k, n := range gaps {
+		r.Gaps = append(r.Gaps, Gap{Kind: k.kind, From: k.from, To: k.to, ID: k.id, Count: n})
+	}
+	gaps, dropped = map[key]uint64{}, 0
+	mu.Unlock()
+
+	sort.Slice(r.Gaps, func(i, j int) bool {
+		a, b := r.Gaps[i], r.Gaps[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.ID < b.ID
+	})
+	return r
+}
+
+// restore records the gaps of a Report that couldn't be posted again, so that they are posted with the next one.
+func restore(r Report) {
+	mu.Lock()
+	defer mu.Unlock()
+	dropped += r.Dropped
+	for _, g := range r.Gaps {
+		k := key{kind: g.Kind, from: g.From, to: g.To, id: g.ID}
+		if _, ok := gaps[k]; !ok && len(gaps) >= maxGaps {
+			dropped += g.Count
+			continue
+		}
+		gaps[k] += g.Count
+	}
+}
+
+// Reporter records the gaps of the translator and posts them to an endpoint on an interval.
+type Reporter struct {
+	url    string
+	client *http.Client
+
+	closed chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Start enables telemetry and starts a Reporter posting a Report of the gaps found to the URL passed as JSON every
+// interval passed. Gaps that fail to be posted are posted with the next Report. Only one Reporter may run at a time.
+func Start(url string, interval time.Duration) *Reporter {
+	r := &Reporter{url: url, client: &http.Client{Timeout: 30 * time.Second}, closed: make(chan struct{}), done: make(chan struct{})}
+	atomic.StoreUint32(&enabled, 1)
+	go r.run(interval)
+	return r
+}
+
+// run posts a Report every interval passed until the Reporter is closed, after which the last Report is posted.
+func (r *Reporter) run(interval time.Duration) {
+	defer close(r.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := r.post(); err != nil {
+				logging.Default().Warn("error posting telemetry", "url", r.url, "err", err)
+			}
+		case <-r.closed:
+			if err := r.post(); err != nil {
+				logging.Default().Warn("error posting telemetry", "url", r.url, "err", err)
+			}
+			return
+		}
+	}
+}
+
+// post posts a Report of the gaps recorded since the last one, unless none were recorded.
+func (r *Reporter) post() error {
+	report := take()
+	if len(report.Gaps) == 0 && report.Dropped == 0 {
+		return nil
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Post(r.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		restore(report)
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		restore(report)
+		return fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	return nil
+}
+
+// Close disables telemetry and posts the gaps found since the last Report before returning.
+func (r *Reporter) Close() error {
+	r.once.Do(func() {
+		atomic.StoreUint32(&enabled, 0)
+		close(r.closed)
+	})
+	<-r.done
+	return nil
+}
@@ -0,0 +1,86 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReporter(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		reports []Report
+		fail    = true
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var report Report
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("error decoding report: %v", err)
+		}
+		reports = append(reports, report)
+	}))
+	defer srv.Close()
+
+	Record(KindSound, 503, 486, "400")
+	if g := take(); len(g.Gaps) != 0 {
+		t.Fatalf("expected nothing to be recorded while telemetry is disabled, got %+v", g)
+	}
+
+	r := Start(srv.URL, time.Hour)
+	Record(KindSound, 503, 486, "400")
+	Record(KindItem, 503, 486, "minecraft:spyglass")
+	Record(KindItem, 503, 486, "minecraft:spyglass")
+	if err := r.post(); err == nil {
+		t.Fatal("expected posting to fail")
+	}
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	// The gaps that failed to be posted are posted together with those recorded since.
+	Record(KindSound, 503, 486, "400")
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	Record(KindSound, 503, 486, "400")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %+v", reports)
+	}
+	expected := []Gap{
+		{Kind: KindItem, From: 503, To: 486, ID: "minecraft:spyglass", Count: 2},
+		{Kind: KindSound, From: 503, To: 486, ID: "400", Count: 2},
+	}
+	if gaps := reports[0].Gaps; len(gaps) != len(expected) || gaps[0] != expected[0] || gaps[1] != expected[1] {
+		t.Errorf("expected gaps %+v, got %+v", expected, gaps)
+	}
+	if g := take(); len(g.Gaps) != 0 {
+		t.Errorf("expected nothing to be recorded once the reporter was closed, got %+v", g)
+	}
+}
+
+func TestRecordLimit(t *testing.T) {
+	r := Start("http://127.0.0.1:0", time.Hour)
+	defer func() {
+		take()
+		_ = r.Close()
+		take()
+	}()
+	for i := 0; i < maxGaps+10; i++ {
+		Record(KindLevelEvent, 503, 486, strconv.Itoa(i))
+	}
+	if report := take(); len(report.Gaps) != maxGaps || report.Dropped != 10 {
+		t.Errorf("expected %v gaps and 10 dropped, got %v gaps and %v dropped", maxGaps, len(report.Gaps), report.Dropped)
+	}
+}
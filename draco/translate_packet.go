@@ -0,0 +1,100 @@
+package draco
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// ParseVersion parses a protocol ID from either a protocol ID, such as "486", or the Minecraft version of a
+// registered protocol, such as "1.18.10". The latest protocol, which is not in the registry, is known as well.
+func ParseVersion(s string) (int32, error) {
+	if s == protocol.CurrentVersion {
+		return protocol.CurrentProtocol, nil
+	}
+	if id, err := strconv.Atoi(s); err == nil {
+		if _, ok := protocolOf(int32(id)); !ok {
+			return 0, fmt.Errorf("unknown protocol %v", id)
+		}
+		return int32(id), nil
+	}
+	for _, p := range Protocols() {
+		if p.Ver() == s {
+			return p.ID(), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown version %v", s)
+}
+
+// protocolOf returns the protocol with the ID passed. The protocol returned is nil for the latest protocol.
+func protocolOf(id int32) (minecraft.Protocol, bool) {
+	if id == protocol.CurrentProtocol {
+		return nil, true
+	}
+	return ProtocolByID(id)
+}
+
+// TranslatePacket decodes the packet passed, which holds the packet header followed by the payload of the packet, as
+// sent by the protocol from, and translates it to the protocol to like the proxy would. Both the packet decoded and
+// the packet translated are returned. Packets that gophertunnel doesn't know are decoded as *packet.Unknown. An error
+// is returned if either protocol is unknown or if the packet can't be decoded or translated under the current decode
+// policy.
+func TranslatePacket(data []byte, from, to int32) (decoded, translated packet.Packet, err error) {
+	fromProto, ok := protocolOf(from)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown protocol %v", from)
+	}
+	toProto, ok := protocolOf(to)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown protocol %v", to)
+	}
+	defer func() {
+		// Packets that can't be decoded or translated make gophertunnel and draco panic.
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	pool := packet.NewPool()
+	if fromProto != nil {
+		pool = fromProto.Packets()
+	}
+	if decoded, err = decodePacket(data, pool); err != nil {
+		return nil, nil, err
+	}
+	// The packet is decoded again for translation, as translating may modify the packet decoded.
+	translated, _ = decodePacket(data, pool)
+	// The packet is converted to the latest protocol first, and from there to the protocol to, like the proxy does.
+	if fromProto != nil {
+		translated = fromProto.ConvertToLatest(translated)
+		if m, ok := translated.(*MalformedPacket); ok {
+			return nil, nil, m.Err
+		}
+	}
+	if toProto != nil {
+		translated = toProto.ConvertFromLatest(translated)
+	}
+	return decoded, translated, nil
+}
+
+// decodePacket decodes the packet header and payload passed using the packets of the pool passed.
+func decodePacket(data []byte, pool packet.Pool) (packet.Packet, error) {
+	buf := bytes.NewBuffer(data)
+	var hdr packet.Header
+	if err := hdr.Read(buf); err != nil {
+		return nil, fmt.Errorf("read packet header: %w", err)
+	}
+	var pk packet.Packet = &packet.Unknown{PacketID: hdr.PacketID}
+	if f, ok := pool[hdr.PacketID]; ok {
+		pk = f()
+	}
+	pk.Unmarshal(protocol.NewReader(buf, 0))
+	if buf.Len() != 0 {
+		return nil, fmt.Errorf("decode packet %T: %v unread bytes", pk, buf.Len())
+	}
+	return pk, nil
+}
@@ -0,0 +1,46 @@
+package draco
+
+import (
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Translator translates a packet sent by one protocol version to the packet expected by another. It may return a
+// different packet than the one passed, as long as it has the same ID. Packets that are not known to gophertunnel
+// are passed as *packet.Unknown, holding their raw payload. Custom packets may be registered with packet.Register
+// to have them decoded into their own type instead.
+type Translator func(pk packet.Packet) packet.Packet
+
+// translatorKey is the key of a Translator registered using RegisterTranslator.
+type translatorKey struct {
+	id       uint32
+	from, to int32
+}
+
+var (
+	// translatorMu guards translators.
+	translatorMu sync.RWMutex
+	// translators holds the translators registered using RegisterTranslator.
+	translators = map[translatorKey]Translator{}
+)
+
+// RegisterTranslator registers a Translator for packets with the ID passed that are sent by the protocol version
+// from to the protocol version to, such as from 503 (1.18.30) to 486 (1.18.10). This allows translating packets
+// that draco doesn't know about, such as custom or experimental packets. A Translator registered for a packet that
+// draco translates itself replaces the translation of draco. Registering a Translator for a key that already has
+// one replaces it.
+func RegisterTranslator(id uint32, from, to int32, t Translator) {
+	translatorMu.Lock()
+	defer translatorMu.Unlock()
+	translators[translatorKey{id: id, from: from, to: to}] = t
+}
+
+// translator looks up the Translator registered for packets with the ID passed sent by the protocol version from to
+// the protocol version to.
+func translator(id uint32, from, to int32) (Translator, bool) {
+	translatorMu.RLock()
+	defer translatorMu.RUnlock()
+	t, ok := translators[translatorKey{id: id, from: from, to: to}]
+	return t, ok
+}
@@ -0,0 +1,115 @@
+package draco
+
+import (
+	"fmt"
+
+	"github.com/cqdetdev/draco/draco/entity"
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/legacy"
+	"github.com/cqdetdev/draco/draco/legacymappings"
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// The entity unit translates the packets of entities: players and other entities being added, their identifiers,
+// their metadata and the events they play.
+func init() {
+	u := newUnit("entity")
+	toLatest(u, func(pk *packet.ActorEvent) packet.Packet {
+		pk.EventData = upgradeActorEventData(pk.EventType, pk.EventData)
+		return pk
+	})
+	toLatestBlocks(u, func(blocks *state.Registry, pk *packet.SetActorData) packet.Packet {
+		upgradeEntityMetadata(blocks, pk.EntityMetadata)
+		return pk
+	})
+	toLatestBlocks(u, func(blocks *state.Registry, pk *packet.AddActor) packet.Packet {
+		upgradeEntityMetadata(blocks, pk.EntityMetadata)
+		return pk
+	})
+
+	fromLatest(u, func(pk *packet.ActorEvent) packet.Packet {
+		pk.EventData = downgradeActorEventData(pk.EventType, pk.EventData)
+		return pk
+	})
+	fromLatestBlocks(u, func(blocks *state.Registry, pk *packet.SetActorData) packet.Packet {
+		downgradeEntityMetadata(blocks, pk.EntityMetadata)
+		return pk
+	})
+	fromLatestBlocks(u, func(blocks *state.Registry, pk *packet.AddActor) packet.Packet {
+		pk.EntityType = downgradeEntityType(pk.EntityType)
+		downgradeEntityMetadata(blocks, pk.EntityMetadata)
+		return pk
+	})
+	fromLatest(u, func(pk *packet.AvailableActorIdentifiers) packet.Packet {
+		pk.SerialisedEntityIdentifiers = downgradeActorIdentifiers(pk.SerialisedEntityIdentifiers)
+		return pk
+	})
+	fromLatestBlocks(u, func(blocks *state.Registry, pk *packet.AddPlayer) packet.Packet {
+		downgradeEntityMetadata(blocks, pk.EntityMetadata)
+		earlier := &legacy.AddPlayer{}
+		// The game type of players was added after the legacy version, which has no field for it.
+		copyFields(earlier, pk, "GameType")
+		earlier.HeldItem.Stack = downgradeItemStack(blocks, pk.HeldItem.Stack)
+		return earlier
+	})
+	fromLatest(u, func(pk *packet.AddVolumeEntity) packet.Packet {
+		return &legacy.AddVolumeEntity{
+			EntityRuntimeID:    pk.EntityRuntimeID,
+			EntityMetadata:     pk.EntityMetadata,
+			EncodingIdentifier: pk.EncodingIdentifier,
+			InstanceIdentifier: pk.InstanceIdentifier,
+			EngineVersion:      pk.EngineVersion,
+		}
+	})
+	fromLatest(u, func(pk *packet.RemoveVolumeEntity) packet.Packet {
+		return &legacy.RemoveVolumeEntity{EntityRuntimeID: pk.EntityRuntimeID}
+	})
+	registerUnit(u)
+}
+
+// downgradeEntityType translates the identifier of an entity of the latest protocol to the identifier that 1.18.10
+// clients show the entity as. Entities that 1.18.10 doesn't have are shown as their fallback, or as entity.Default if
+// none of their fallbacks exist either, and counted in the "entity_substitutions" metrics group by their identifier.
+func downgradeEntityType(id string) string {
+	translated, ok := entity.Translate(latestmappings.Version, legacymappings.Version, id)
+	if !ok {
+		translated = entity.Default
+	}
+	if translated != id {
+		metrics.AddTo("entity_substitutions", id, 1)
+	}
+	return translated
+}
+
+// downgradeActorIdentifiers removes the entities that 1.18.10 doesn't have from the serialised entity identifiers of
+// an AvailableActorIdentifiers packet of the latest protocol passed. These entities are spawned as another entity,
+// which the identifiers already hold.
+func downgradeActorIdentifiers(data []byte) []byte {
+	var identifiers map[string]any
+	if err := nbt.UnmarshalEncoding(data, &identifiers, nbt.NetworkLittleEndian); err != nil {
+		panic(fmt.Errorf("downgrade actor identifiers: %w", err))
+	}
+	list, _ := identifiers["idlist"].([]any)
+	kept := make([]any, 0, len(list))
+	for _, e := range list {
+		if m, ok := e.(map[string]any); ok {
+			id, _ := m["id"].(string)
+			if translated, ok := entity.Translate(latestmappings.Version, legacymappings.Version, id); !ok || translated != id {
+				continue
+			}
+		}
+		kept = append(kept, e)
+	}
+	if len(kept) == len(list) {
+		return data
+	}
+	identifiers["idlist"] = kept
+	encoded, err := nbt.MarshalEncoding(identifiers, nbt.NetworkLittleEndian)
+	if err != nil {
+		panic(fmt.Errorf("downgrade actor identifiers: %w", err))
+	}
+	return encoded
+}
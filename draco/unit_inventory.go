@@ -0,0 +1,58 @@
+package draco
+
+import (
+	"github.com/cqdetdev/draco/draco/item"
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/legacymappings"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// The inventory unit translates the packets holding items, such as the contents of inventories and recipes.
+func init() {
+	u := newUnit("inventory")
+	toLatestBlocks(u, func(blocks *state.Registry, pk *packet.MobEquipment) packet.Packet {
+		if err := item.Translate(blocks, legacymappings.Version, latestmappings.Version, pk); err != nil {
+			panic(err)
+		}
+		return pk
+	})
+	toLatestBlocks(u, func(blocks *state.Registry, pk *packet.InventoryTransaction) packet.Packet {
+		actions := make([]protocol.InventoryAction, 0, len(pk.Actions))
+		for _, action := range pk.Actions {
+			action.OldItem.Stack = upgradeItemStack(blocks, action.OldItem.Stack)
+			action.NewItem.Stack = upgradeItemStack(blocks, action.NewItem.Stack)
+			actions = append(actions, action)
+		}
+		pk.Actions = actions
+		switch data := pk.TransactionData.(type) {
+		case *protocol.UseItemTransactionData:
+			data.HeldItem.Stack = upgradeItemStack(blocks, data.HeldItem.Stack)
+			data.BlockRuntimeID = upgradeBlockRuntimeID(blocks, data.BlockRuntimeID)
+		case *protocol.UseItemOnEntityTransactionData:
+			data.HeldItem.Stack = upgradeItemStack(blocks, data.HeldItem.Stack)
+		}
+		return pk
+	})
+
+	fromLatestBlocks(u, func(blocks *state.Registry, pk *packet.CraftingData) packet.Packet {
+		if err := translateCraftingData(blocks, pk); err != nil {
+			panic(err)
+		}
+		return pk
+	})
+	fromLatestBlocks(u, downgradeItems[*packet.CreativeContent])
+	fromLatestBlocks(u, downgradeItems[*packet.InventoryContent])
+	fromLatestBlocks(u, downgradeItems[*packet.InventorySlot])
+	fromLatestBlocks(u, downgradeItems[*packet.MobEquipment])
+	registerUnit(u)
+}
+
+// downgradeItems translates the items in the packet passed from the latest version to 1.18.10 using item.Translate.
+func downgradeItems[P packet.Packet](blocks *state.Registry, pk P) packet.Packet {
+	if err := item.Translate(blocks, latestmappings.Version, legacymappings.Version, pk); err != nil {
+		panic(err)
+	}
+	return pk
+}
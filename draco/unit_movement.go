@@ -0,0 +1,16 @@
+package draco
+
+import (
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// The movement unit translates the packets that move the player.
+func init() {
+	u := newUnit("movement")
+	toLatestBlocks(u, func(blocks *state.Registry, pk *packet.PlayerAuthInput) packet.Packet {
+		pk.ItemInteractionData.HeldItem.Stack = upgradeItemStack(blocks, pk.ItemInteractionData.HeldItem.Stack)
+		return pk
+	})
+	registerUnit(u)
+}
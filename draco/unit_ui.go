@@ -0,0 +1,25 @@
+package draco
+
+import (
+	"fmt"
+
+	"github.com/cqdetdev/draco/draco/command"
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/legacymappings"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// The UI unit translates the packets shown in the interface of the client, such as the commands it suggests, and
+// the warnings that the client reports for packets it couldn't read.
+func init() {
+	u := newUnit("ui")
+	fromLatest(u, func(pk *packet.AvailableCommands) packet.Packet {
+		command.Translate(latestmappings.Version, legacymappings.Version, pk)
+		return pk
+	})
+	fromLatest(u, func(pk *packet.PacketViolationWarning) packet.Packet {
+		fmt.Printf("Violation %d (%d): %v\n", pk.PacketID, pk.Severity, pk.ViolationContext)
+		return pk
+	})
+	registerUnit(u)
+}
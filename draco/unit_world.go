@@ -0,0 +1,148 @@
+package draco
+
+import (
+	"github.com/cqdetdev/draco/draco/blockentity"
+	"github.com/cqdetdev/draco/draco/chunk"
+	"github.com/cqdetdev/draco/draco/item"
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/legacy"
+	"github.com/cqdetdev/draco/draco/legacymappings"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// The world unit translates the packets of the world: the game data sent when joining, chunks, blocks, block
+// entities, maps and the events and particles of the world.
+func init() {
+	u := newUnit("world")
+	toLatest(u, func(pk *packet.BlockActorData) packet.Packet {
+		// Clients send the NBT of signs they edited.
+		blockentity.Translate(legacymappings.Version, latestmappings.Version, pk.NBTData)
+		return pk
+	})
+	toLatest(u, func(pk *packet.LevelSoundEvent) packet.Packet {
+		// Clients play the sounds of their own actions, such as attacking, for the other players as well.
+		pk.SoundType = translateSound(legacymappings.Version, latestmappings.Version, pk.SoundType)
+		return pk
+	})
+
+	fromLatest(u, func(pk *packet.StartGame) packet.Packet {
+		// StartGame gains fields in nearly every version, so the fields that aren't translated explicitly are copied by
+		// name, which keeps a StartGame of a newer version working until its translation is written.
+		earlier := &legacy.StartGame{}
+		copyFields(earlier, pk, "Items")
+		items, _ := item.PaletteOf(legacymappings.Version)
+		for _, i := range pk.Items {
+			if oldRuntimeID, ok := items.RuntimeID(i.Name); ok {
+				earlier.Items = append(earlier.Items, protocol.ItemEntry{
+					Name:           i.Name,
+					RuntimeID:      int16(oldRuntimeID),
+					ComponentBased: i.ComponentBased,
+				})
+			}
+		}
+		return earlier
+	})
+	fromLatestBlocks(u, func(blocks *state.Registry, pk *packet.LevelChunk) packet.Packet {
+		if pk.CacheEnabled {
+			// The sub chunks and biomes of the chunk are sent as blobs, which are translated once the server sends
+			// them. The border blocks and block entities in the payload are forwarded as they are.
+			rememberBlobs(pk)
+			return pk
+		}
+		if pk.SubChunkRequestMode == protocol.SubChunkRequestModeLegacy && (!palettesIdentical(blocks) || !identicalBiomes || translatesBlockEntities()) {
+			payload, err := chunk.Translate(blocks, pk.RawPayload, int(pk.SubChunkCount), worldRange, latestmappings.Version, legacymappings.Version)
+			if err != nil {
+				panic(err)
+			}
+			pk.RawPayload = payload
+		} else if pk.SubChunkRequestMode != protocol.SubChunkRequestModeLegacy && !identicalBiomes {
+			// Chunks sent using sub chunk requests only hold their biomes and border blocks.
+			payload, err := chunk.TranslateBiomes(pk.RawPayload, worldRange, latestmappings.Version, legacymappings.Version)
+			if err != nil {
+				panic(err)
+			}
+			pk.RawPayload = payload
+		}
+		return pk
+	})
+	fromLatestBlocks(u, func(blocks *state.Registry, pk *packet.SubChunk) packet.Packet {
+		if pk.CacheEnabled {
+			rememberBlobs(pk)
+			return pk
+		}
+		if palettesIdentical(blocks) && !translatesBlockEntities() {
+			return pk
+		}
+		entries := make([]protocol.SubChunkEntry, 0, len(pk.SubChunkEntries))
+		for _, e := range pk.SubChunkEntries {
+			if e.Result == protocol.SubChunkResultSuccess {
+				payload, err := chunk.TranslateSubChunk(blocks, e.RawPayload, worldRange, latestmappings.Version, legacymappings.Version)
+				if err != nil {
+					panic(err)
+				}
+				e.RawPayload = payload
+			}
+			entries = append(entries, e)
+		}
+		pk.SubChunkEntries = entries
+		return pk
+	})
+	fromLatestBlocks(u, func(blocks *state.Registry, pk *packet.ClientCacheMissResponse) packet.Packet {
+		translateBlobs(blocks, pk)
+		return pk
+	})
+	fromLatestBlocks(u, func(blocks *state.Registry, pk *packet.UpdateBlock) packet.Packet {
+		pk.NewBlockRuntimeID = downgradeBlockRuntimeID(blocks, pk.NewBlockRuntimeID)
+		return pk
+	})
+	fromLatestBlocks(u, func(blocks *state.Registry, pk *packet.UpdateSubChunkBlocks) packet.Packet {
+		for i, e := range pk.Blocks {
+			pk.Blocks[i].BlockRuntimeID = downgradeBlockRuntimeID(blocks, e.BlockRuntimeID)
+		}
+		for i, e := range pk.Extra {
+			pk.Extra[i].BlockRuntimeID = downgradeBlockRuntimeID(blocks, e.BlockRuntimeID)
+		}
+		return pk
+	})
+	fromLatest(u, func(pk *packet.BlockActorData) packet.Packet {
+		blockentity.Translate(latestmappings.Version, legacymappings.Version, pk.NBTData)
+		return pk
+	})
+	fromLatestBlocks(u, func(blocks *state.Registry, pk *packet.LevelEvent) packet.Packet {
+		pk.EventData = downgradeLevelEventData(blocks, pk.EventType, pk.EventData)
+		pk.EventType = downgradeLevelEventType(pk.EventType)
+		return pk
+	})
+	fromLatest(u, func(pk *packet.LevelSoundEvent) packet.Packet {
+		pk.SoundType = translateSound(latestmappings.Version, legacymappings.Version, pk.SoundType)
+		return pk
+	})
+	fromLatest(u, func(pk *packet.ClientBoundMapItemData) packet.Packet {
+		return &legacy.ClientBoundMapItemData{
+			MapID:          pk.MapID,
+			UpdateFlags:    pk.UpdateFlags,
+			Dimension:      pk.Dimension,
+			LockedMap:      pk.LockedMap,
+			Scale:          pk.Scale,
+			MapsIncludedIn: pk.MapsIncludedIn,
+			TrackedObjects: pk.TrackedObjects,
+			Decorations:    pk.Decorations,
+			Height:         pk.Height,
+			Width:          pk.Width,
+			XOffset:        pk.XOffset,
+			YOffset:        pk.YOffset,
+			Pixels:         pk.Pixels,
+		}
+	})
+	fromLatest(u, func(pk *packet.SpawnParticleEffect) packet.Packet {
+		return &legacy.SpawnParticleEffect{
+			Dimension:      pk.Dimension,
+			EntityUniqueID: pk.EntityUniqueID,
+			Position:       pk.Position,
+			ParticleName:   downgradeParticleEffect(pk.ParticleName),
+		}
+	})
+	registerUnit(u)
+}
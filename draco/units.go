@@ -0,0 +1,132 @@
+package draco
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// unit translates one family of packets between the latest protocol and 1.18.10, such as the packets of the world or
+// those of entities. Every family lives in its own file, unit_<name>.go, which registers it using registerUnit, so
+// that a family can be maintained without knowing the others. The metrics of every unit are recorded separately:
+// "translated_packets" counts the packets it translated, "translation_errors" those it couldn't translate and
+// "translation_us" the time spent translating, all keyed by the name of the unit.
+type unit struct {
+	// name is the name of the family of packets, such as "world".
+	name string
+	// toLatest and fromLatest hold the functions of the unit translating packets sent by 1.18.10 clients and packets
+	// sent to them respectively, keyed by packet ID.
+	toLatest, fromLatest map[uint32]unitFunc
+}
+
+// unitFunc translates a packet like a Translator, translating block runtime IDs using the palettes of the
+// state.Registry passed.
+type unitFunc func(blocks *state.Registry, pk packet.Packet) packet.Packet
+
+// newUnit returns an empty unit with the name passed.
+func newUnit(name string) *unit {
+	return &unit{name: name, toLatest: map[uint32]unitFunc{}, fromLatest: map[uint32]unitFunc{}}
+}
+
+// toLatest adds a function to the unit passed that translates packets of type P sent by 1.18.10 clients to the
+// latest protocol. It may modify the packet passed and return it, or return another packet with the same ID.
+func toLatest[P packet.Packet](u *unit, f func(pk P) packet.Packet) {
+	toLatestBlocks(u, func(_ *state.Registry, pk P) packet.Packet {
+		return f(pk)
+	})
+}
+
+// toLatestBlocks adds a function to the unit passed that translates packets of type P holding block runtime IDs sent
+// by 1.18.10 clients to the latest protocol, like toLatest. The function is passed the state.Registry holding the
+// block palettes of the connection.
+func toLatestBlocks[P packet.Packet](u *unit, f func(blocks *state.Registry, pk P) packet.Packet) {
+	var pk P
+	u.toLatest[pk.ID()] = func(blocks *state.Registry, pk packet.Packet) packet.Packet {
+		return f(blocks, pk.(P))
+	}
+}
+
+// fromLatest adds a function to the unit passed that translates packets of type P of the latest protocol to be sent
+// to 1.18.10 clients, like toLatest.
+func fromLatest[P packet.Packet](u *unit, f func(pk P) packet.Packet) {
+	fromLatestBlocks(u, func(_ *state.Registry, pk P) packet.Packet {
+		return f(pk)
+	})
+}
+
+// fromLatestBlocks adds a function to the unit passed that translates packets of type P holding block runtime IDs of
+// the latest protocol to be sent to 1.18.10 clients, like toLatestBlocks.
+func fromLatestBlocks[P packet.Packet](u *unit, f func(blocks *state.Registry, pk P) packet.Packet) {
+	var pk P
+	u.fromLatest[pk.ID()] = func(blocks *state.Registry, pk packet.Packet) packet.Packet {
+		return f(blocks, pk.(P))
+	}
+}
+
+// unitTranslator is a function of a unit.
+type unitTranslator struct {
+	u *unit
+	t unitFunc
+}
+
+var (
+	// units holds all units registered using registerUnit.
+	units []*unit
+	// unitsToLatest and unitsFromLatest hold the translators of all units, keyed by packet ID. They are only
+	// written to by registerUnit, which is only called during initialisation, so they need no mutex.
+	unitsToLatest   = map[uint32]unitTranslator{}
+	unitsFromLatest = map[uint32]unitTranslator{}
+)
+
+// registerUnit registers the unit passed. It must only be called from the init function of the file of the unit. It
+// panics if another unit already translates one of its packets, as every packet belongs to exactly one family.
+func registerUnit(u *unit) {
+	add := func(m map[uint32]unitTranslator, translators map[uint32]unitFunc) {
+		for id, t := range translators {
+			if other, ok := m[id]; ok {
+				panic(fmt.Sprintf("packet %v is translated by units %v and %v", id, other.u.name, u.name))
+			}
+			m[id] = unitTranslator{u: u, t: t}
+		}
+	}
+	add(unitsToLatest, u.toLatest)
+	add(unitsFromLatest, u.fromLatest)
+	units = append(units, u)
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].name < units[j].name
+	})
+}
+
+// translate translates the packet passed using the function passed, which belongs to the unit, recording the metrics
+// of the unit. The functions of units panic if a packet can't be translated, in which case the panic is passed on
+// after it was counted.
+func (u *unit) translate(t unitFunc, blocks *state.Registry, pk packet.Packet) packet.Packet {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			metrics.AddTo("translation_errors", u.name, 1)
+			panic(r)
+		}
+		metrics.AddTo("translated_packets", u.name, 1)
+		metrics.AddTo("translation_us", u.name, time.Since(start).Microseconds())
+	}()
+	return t(blocks, pk)
+}
+
+// translateUnits translates the packet passed using the unit that translates it, to the latest protocol if latest is
+// true, or from the latest protocol otherwise, translating block runtime IDs using the palettes of the state.Registry
+// passed. Packets that no unit translates are returned as they are.
+func translateUnits(pk packet.Packet, latest bool, blocks *state.Registry) packet.Packet {
+	m := unitsFromLatest
+	if latest {
+		m = unitsToLatest
+	}
+	if ut, ok := m[pk.ID()]; ok {
+		return ut.u.translate(ut.t, blocks, pk)
+	}
+	return pk
+}
@@ -0,0 +1,153 @@
+package draco
+
+import (
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/effect"
+	"github.com/cqdetdev/draco/draco/entity"
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/legacy"
+	"github.com/cqdetdev/draco/draco/legacymappings"
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestUnitsRegistered(t *testing.T) {
+	names := map[string]bool{}
+	for _, u := range units {
+		names[u.name] = true
+		if len(u.toLatest)+len(u.fromLatest) == 0 {
+			t.Errorf("unit %v translates no packets", u.name)
+		}
+	}
+	for _, name := range []string{"movement", "inventory", "world", "entity", "ui"} {
+		if !names[name] {
+			t.Errorf("unit %v not registered", name)
+		}
+	}
+	if ut, ok := unitsFromLatest[packet.IDLevelChunk]; !ok || ut.u.name != "world" {
+		t.Error("expected chunks to be translated by the world unit")
+	}
+}
+
+func TestUnitDuplicatePacket(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a packet translated by another unit to panic")
+		}
+	}()
+	u := newUnit("duplicate")
+	fromLatest(u, func(pk *packet.LevelChunk) packet.Packet { return pk })
+	registerUnit(u)
+}
+
+func TestUnitMetrics(t *testing.T) {
+	before := metrics.Group("translated_packets")["entity"]
+	pk := Protocol{}.ConvertFromLatest(&packet.RemoveVolumeEntity{EntityRuntimeID: 3})
+	if v, ok := pk.(*legacy.RemoveVolumeEntity); !ok || v.EntityRuntimeID != 3 {
+		t.Fatalf("unexpected packet %#v translated", pk)
+	}
+	if after := metrics.Group("translated_packets")["entity"]; after != before+1 {
+		t.Errorf("translated packets of the entity unit went from %v to %v, expected one more", before, after)
+	}
+
+	failed := metrics.Group("translation_errors")["world"]
+	func() {
+		defer func() { _ = recover() }()
+		Protocol{}.ConvertFromLatest(&packet.LevelChunk{SubChunkCount: 4, RawPayload: []byte{1, 2, 3}})
+	}()
+	if metrics.Group("translation_errors")["world"] != failed+1 {
+		t.Error("expected a malformed chunk to be counted as an error of the world unit")
+	}
+
+}
+
+func TestUnitRegistry(t *testing.T) {
+	custom := []state.Block{{Name: "draco:custom"}}
+	latest, _ := state.PaletteOf(latestmappings.Version)
+	legacy, _ := state.PaletteOf(legacymappings.Version)
+	latestCustom, err := latest.WithCustomBlocks(custom, latestmappings.Ordering)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyCustom, err := legacy.WithCustomBlocks(custom, legacymappings.Ordering)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := state.Default().Clone()
+	blocks.RegisterPalette(latestmappings.Version, latestCustom)
+	blocks.RegisterPalette(legacymappings.Version, legacyCustom)
+
+	latestRID, _ := latestCustom.RuntimeID("draco:custom", nil)
+	expected, _ := legacyCustom.RuntimeID("draco:custom", nil)
+	pk := Protocol{blocks: blocks}.ConvertFromLatest(&packet.UpdateBlock{NewBlockRuntimeID: latestRID}).(*packet.UpdateBlock)
+	if pk.NewBlockRuntimeID != expected {
+		t.Errorf("custom block translated to %v, expected %v", pk.NewBlockRuntimeID, expected)
+	}
+}
+
+func TestEntityIdentifiers(t *testing.T) {
+	entity.RegisterFallbacks(map[string]string{"minecraft:unreleased": "minecraft:also_unreleased"})
+	for id, expected := range map[string]string{
+		"minecraft:zombie":     "minecraft:zombie",
+		"minecraft:warden":     "minecraft:iron_golem",
+		"minecraft:unreleased": entity.Default,
+		"custom:dragon":        "custom:dragon",
+	} {
+		pk := Protocol{}.ConvertFromLatest(&packet.AddActor{EntityType: id})
+		if shown := pk.(*packet.AddActor).EntityType; shown != expected {
+			t.Errorf("%v shown as %v, expected %v", id, shown, expected)
+		}
+	}
+	if !entity.Exists(legacymappings.Version, entity.Default) {
+		t.Error("default entity doesn't exist in 1.18.10")
+	}
+
+	var list []any
+	for _, id := range entity.Identifiers(latestmappings.Version) {
+		list = append(list, map[string]any{"id": id, "bid": "", "hasspawnegg": false, "summonable": true, "rid": int32(len(list))})
+	}
+	data, err := nbt.MarshalEncoding(map[string]any{"idlist": list}, nbt.NetworkLittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := Protocol{}.ConvertFromLatest(&packet.AvailableActorIdentifiers{SerialisedEntityIdentifiers: data}).(*packet.AvailableActorIdentifiers)
+	var identifiers map[string]any
+	if err := nbt.UnmarshalEncoding(pk.SerialisedEntityIdentifiers, &identifiers, nbt.NetworkLittleEndian); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range identifiers["idlist"].([]any) {
+		if id := e.(map[string]any)["id"].(string); !entity.Exists(legacymappings.Version, id) {
+			t.Errorf("identifiers sent to 1.18.10 hold %v", id)
+		}
+	}
+	if n := len(identifiers["idlist"].([]any)); n != len(entity.Identifiers(legacymappings.Version)) {
+		t.Errorf("identifiers sent to 1.18.10 hold %v entities, expected %v", n, len(entity.Identifiers(legacymappings.Version)))
+	}
+}
+
+func TestEffects(t *testing.T) {
+	pk := Protocol{}.ConvertFromLatest(&packet.LevelSoundEvent{SoundType: packet.SoundEventGoatCall0}).(*packet.LevelSoundEvent)
+	if raidHorn, _ := effect.Sound(legacymappings.Version, "raid_horn"); pk.SoundType != raidHorn {
+		t.Errorf("goat horn played as sound %v, expected raid horn %v", pk.SoundType, raidHorn)
+	}
+	pk = Protocol{}.ConvertFromLatest(&packet.LevelSoundEvent{SoundType: packet.SoundEventUndefined}).(*packet.LevelSoundEvent)
+	if undefined, _ := effect.Sound(legacymappings.Version, "undefined"); pk.SoundType != undefined {
+		t.Errorf("undefined sound played as %v, expected %v", pk.SoundType, undefined)
+	}
+	pk = Protocol{}.ConvertToLatest(pk).(*packet.LevelSoundEvent)
+	if pk.SoundType != packet.SoundEventUndefined {
+		t.Errorf("undefined sound of client played as %v, expected %v", pk.SoundType, packet.SoundEventUndefined)
+	}
+
+	ev := Protocol{}.ConvertFromLatest(&packet.LevelEvent{EventType: packet.LevelEventParticleLegacyEvent | 82}).(*packet.LevelEvent)
+	if ev.EventType != packet.LevelEventParticleLegacyEvent|71 {
+		t.Errorf("sculk soul particle shown as %v, expected soul particle", ev.EventType&^packet.LevelEventParticleLegacyEvent)
+	}
+	eff := Protocol{}.ConvertFromLatest(&packet.SpawnParticleEffect{ParticleName: "minecraft:sculk_soul_particle"}).(*legacy.SpawnParticleEffect)
+	if eff.ParticleName != "minecraft:soul_particle" {
+		t.Errorf("sculk soul effect spawned as %v, expected minecraft:soul_particle", eff.ParticleName)
+	}
+}
@@ -0,0 +1,261 @@
+package draco
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cqdetdev/draco/draco/item"
+	"github.com/cqdetdev/draco/draco/latestmappings"
+	"github.com/cqdetdev/draco/draco/legacymappings"
+	"github.com/cqdetdev/draco/draco/policy"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+const (
+	// blobIndexFile and craftingFile are the names of the files in the cache directory that the blob index and the
+	// translated CraftingData packets are saved in.
+	blobIndexFile = "blobs.index"
+	craftingFile  = "crafting.cache"
+	// maxCrafting is the maximum amount of translated CraftingData packets cached. Backends usually send the same
+	// packet to every player, so it only needs to hold one for every backend.
+	maxCrafting = 64
+)
+
+var (
+	// blobMagic and craftingMagic are written at the start of the files that the blob index and the translated
+	// CraftingData packets are saved in. Their last byte is the version of the format of the file, which must be
+	// bumped when the format changes.
+	blobMagic     = [8]byte{'d', 'r', 'a', 'c', 'o', 'b', 'l', 1}
+	craftingMagic = [8]byte{'d', 'r', 'a', 'c', 'o', 'c', 'd', 1}
+)
+
+var (
+	// craftingMu guards crafting.
+	craftingMu sync.Mutex
+	// crafting holds the translated CraftingData packets, encoded, keyed by craftingKey of the packet they were
+	// translated from.
+	crafting = map[[32]byte][]byte{}
+
+	// fingerprintOnce guards fingerprint.
+	fingerprintOnce sync.Once
+	// fingerprint identifies the item and block palettes of both versions, so that packets cached with other
+	// mappings, such as by an older build of the proxy, are never used.
+	fingerprint [32]byte
+)
+
+// mappingsFingerprint returns the fingerprint of the item and block palettes of both versions.
+func mappingsFingerprint() [32]byte {
+	fingerprintOnce.Do(func() {
+		h := sha256.New()
+		for _, v := range []state.Version{latestmappings.Version, legacymappings.Version} {
+			if p, ok := item.PaletteOf(v); ok {
+				for _, e := range p.Entries() {
+					_, _ = fmt.Fprintf(h, "%v=%v;", e.Name, e.RuntimeID)
+				}
+			}
+			if p, ok := state.PaletteOf(v); ok {
+				for rid := uint32(0); rid < p.Len(); rid++ {
+					b, _ := p.State(rid)
+					_, _ = fmt.Fprintf(h, "%v%v;", b.Name, b.Properties)
+				}
+			}
+		}
+		h.Sum(fingerprint[:0])
+	})
+	return fingerprint
+}
+
+// craftingKey returns the key of the translation of the encoded CraftingData packet passed, which depends on the
+// mappings and the decode policy, as untranslatable recipes are only dropped under the lenient policy.
+func craftingKey(data []byte) [32]byte {
+	f := mappingsFingerprint()
+	h := sha256.New()
+	h.Write(f[:])
+	if !policy.Current().Substitutes() {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+	h.Write(data)
+	var key [32]byte
+	h.Sum(key[:0])
+	return key
+}
+
+// translateCraftingData translates the CraftingData packet passed from the latest version to 1.18.10, like
+// item.Translate. Translated packets are cached, so that the recipes of a backend are only translated once rather
+// than for every player that joins it. Packets translated using a state.Registry other than state.Default, which
+// holds the custom blocks of a server, are not cached, as the fingerprint of the cache only covers the default
+// palettes.
+func translateCraftingData(blocks *state.Registry, pk *packet.CraftingData) error {
+	if blocks != state.Default() {
+		return item.Translate(blocks, latestmappings.Version, legacymappings.Version, pk)
+	}
+	buf := bytes.NewBuffer(nil)
+	pk.Marshal(protocol.NewWriter(buf, 0))
+	key := craftingKey(buf.Bytes())
+
+	craftingMu.Lock()
+	cached, ok := crafting[key]
+	craftingMu.Unlock()
+	if ok && decodeCraftingData(cached, pk) {
+		return nil
+	}
+	if err := item.Translate(blocks, latestmappings.Version, legacymappings.Version, pk); err != nil {
+		return err
+	}
+	buf.Reset()
+	pk.Marshal(protocol.NewWriter(buf, 0))
+
+	craftingMu.Lock()
+	defer craftingMu.Unlock()
+	if len(crafting) >= maxCrafting {
+		crafting = map[[32]byte][]byte{}
+	}
+	crafting[key] = buf.Bytes()
+	return nil
+}
+
+// decodeCraftingData decodes the encoded CraftingData packet passed into pk. False is returned if the data is
+// malformed, in which case pk is left unchanged.
+func decodeCraftingData(data []byte, pk *packet.CraftingData) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	decoded := &packet.CraftingData{}
+	decoded.Unmarshal(protocol.NewReader(bytes.NewReader(data), 0))
+	*pk = *decoded
+	return true
+}
+
+// LoadWarmCaches loads the caches saved in the directory passed using SaveWarmCaches: the kinds of the blobs of the
+// client blob cache that chunks were sent with, and the translated CraftingData packets, so that a restarted proxy
+// doesn't have to translate them again while players join. Translation tables between block palettes are cached in
+// the same directory by the state package as soon as they are generated. Missing files are not an error.
+func LoadWarmCaches(dir string) error {
+	var errs []string
+	if err := loadBlobIndex(filepath.Join(dir, blobIndexFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		errs = append(errs, fmt.Sprintf("blob index: %v", err))
+	}
+	if err := loadCrafting(filepath.Join(dir, craftingFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		errs = append(errs, fmt.Sprintf("crafting data: %v", err))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("load warm caches: %v", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// SaveWarmCaches saves the caches loaded by LoadWarmCaches in the directory passed. It should be called when the
+// proxy shuts down.
+func SaveWarmCaches(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create cache directory: %w", err)
+	}
+	blobMu.Lock()
+	index := make([]byte, 0, len(blobMagic)+len(blobs)*9)
+	index = append(index, blobMagic[:]...)
+	for hash, kind := range blobs {
+		var entry [9]byte
+		binary.LittleEndian.PutUint64(entry[:], hash)
+		entry[8] = byte(kind)
+		index = append(index, entry[:]...)
+	}
+	blobMu.Unlock()
+	if err := writeCacheFile(filepath.Join(dir, blobIndexFile), index); err != nil {
+		return fmt.Errorf("save blob index: %w", err)
+	}
+
+	f := mappingsFingerprint()
+	data := append(append([]byte(nil), craftingMagic[:]...), f[:]...)
+	craftingMu.Lock()
+	for key, pk := range crafting {
+		var n [4]byte
+		binary.LittleEndian.PutUint32(n[:], uint32(len(pk)))
+		data = append(append(append(data, key[:]...), n[:]...), pk...)
+	}
+	craftingMu.Unlock()
+	if err := writeCacheFile(filepath.Join(dir, craftingFile), data); err != nil {
+		return fmt.Errorf("save crafting data: %w", err)
+	}
+	return nil
+}
+
+// loadBlobIndex loads the blob index saved in the file passed.
+func loadBlobIndex(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) < len(blobMagic) || !bytes.Equal(data[:len(blobMagic)], blobMagic[:]) || (len(data)-len(blobMagic))%9 != 0 {
+		return errors.New("unknown format")
+	}
+	blobMu.Lock()
+	defer blobMu.Unlock()
+	for data = data[len(blobMagic):]; len(data) > 0 && len(blobs) < maxBlobs; data = data[9:] {
+		if kind := blobKind(data[8]); kind == blobSubChunk || kind == blobBiomes {
+			blobs[binary.LittleEndian.Uint64(data)] = kind
+		}
+	}
+	return nil
+}
+
+// loadCrafting loads the translated CraftingData packets saved in the file passed. Packets saved with other
+// mappings are skipped.
+func loadCrafting(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	header := len(craftingMagic) + sha256.Size
+	if len(data) < header || !bytes.Equal(data[:len(craftingMagic)], craftingMagic[:]) {
+		return errors.New("unknown format")
+	}
+	if f := mappingsFingerprint(); !bytes.Equal(data[len(craftingMagic):header], f[:]) {
+		// The packets were translated with other mappings.
+		return nil
+	}
+	loaded := map[[32]byte][]byte{}
+	for data = data[header:]; len(data) > 0; {
+		if len(data) < 36 {
+			return errors.New("unexpected end of file")
+		}
+		var key [32]byte
+		copy(key[:], data)
+		n := binary.LittleEndian.Uint32(data[32:])
+		if uint32(len(data)-36) < n {
+			return errors.New("unexpected end of file")
+		}
+		loaded[key], data = append([]byte(nil), data[36:36+n]...), data[36+n:]
+	}
+	craftingMu.Lock()
+	defer craftingMu.Unlock()
+	for key, pk := range loaded {
+		if len(crafting) >= maxCrafting {
+			break
+		}
+		crafting[key] = pk
+	}
+	return nil
+}
+
+// writeCacheFile writes the data passed to the file passed, replacing it atomically so that a proxy stopped while
+// writing never loads a file half written.
+func writeCacheFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,69 @@
+package draco
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestWarmCaches(t *testing.T) {
+	blobMu.Lock()
+	blobs[201], blobs[202] = blobSubChunk, blobBiomes
+	blobMu.Unlock()
+	pk := &packet.CraftingData{PotionRecipes: []protocol.PotionRecipe{{InputPotionID: 1, ReagentItemID: 2, OutputPotionID: 3}}, ClearRecipes: true}
+	if err := translateCraftingData(state.Default(), pk); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := SaveWarmCaches(dir); err != nil {
+		t.Fatal(err)
+	}
+	// The caches are loaded again, as they are when the proxy restarts.
+	blobMu.Lock()
+	blobs = map[uint64]blobKind{}
+	blobMu.Unlock()
+	craftingMu.Lock()
+	crafting = map[[32]byte][]byte{}
+	craftingMu.Unlock()
+	if err := LoadWarmCaches(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	blobMu.Lock()
+	sub, biomes := blobs[201], blobs[202]
+	blobMu.Unlock()
+	if sub != blobSubChunk || biomes != blobBiomes {
+		t.Errorf("blob index not restored: got kinds %v and %v", sub, biomes)
+	}
+	craftingMu.Lock()
+	n := len(crafting)
+	craftingMu.Unlock()
+	if n != 1 {
+		t.Fatalf("%v translated CraftingData packets restored, expected 1", n)
+	}
+	again := &packet.CraftingData{PotionRecipes: []protocol.PotionRecipe{{InputPotionID: 1, ReagentItemID: 2, OutputPotionID: 3}}, ClearRecipes: true}
+	if err := translateCraftingData(state.Default(), again); err != nil {
+		t.Fatal(err)
+	}
+	if !again.ClearRecipes || len(again.PotionRecipes) != len(pk.PotionRecipes) {
+		t.Errorf("unexpected packet %#v decoded from the cache", again)
+	}
+}
+
+func TestLoadCorruptWarmCaches(t *testing.T) {
+	dir := t.TempDir()
+	if err := LoadWarmCaches(dir); err != nil {
+		t.Fatalf("expected missing caches not to be an error, got %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, craftingFile), []byte("not a cache"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadWarmCaches(dir); err == nil {
+		t.Error("expected an error loading a corrupt cache")
+	}
+}
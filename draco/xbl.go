@@ -1,66 +1,221 @@
 package draco
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/cqdetdev/draco/draco/logging"
 	"github.com/sandertv/gophertunnel/minecraft/auth"
 	"golang.org/x/oauth2"
 )
 
 var (
+	// TokenSrc is the token source of the default account, set by InitializeToken.
 	TokenSrc oauth2.TokenSource
 )
 
+// DefaultTokenFile is the file that the token of the default account is cached in.
+const DefaultTokenFile = "./token.json"
+
+// Account is an XBOX Live account that the proxy signs in to backends with.
+type Account struct {
+	// Name is the name of the account, by which backends refer to it. The default account has no name.
+	Name string
+	// TokenFile is the file that the token of the account is cached in, which is refreshed automatically. If the
+	// file doesn't exist, the account is signed in to using device auth when it is initialised.
+	TokenFile string
+}
+
+var (
+	// accountMu guards accounts.
+	accountMu sync.RWMutex
+	// accounts holds the token sources of the accounts initialised, keyed by their name.
+	accounts = map[string]oauth2.TokenSource{}
+	// devMode is true if accounts are not signed in to, as set using SetDevMode.
+	devMode bool
+)
+
+// SetDevMode sets if the proxy runs in dev mode, in which InitializeToken and InitializeAccount don't sign in to any
+// account, so that the proxy may be run against backends with XBOX Live authentication disabled without a Microsoft
+// account. The token sources of the accounts are nil in dev mode.
+func SetDevMode(enabled bool) {
+	accountMu.Lock()
+	defer accountMu.Unlock()
+	devMode = enabled
+}
+
 type jsonToken struct {
-	Access  string `json:"access_token"`
-	Type    string `json:"token_type"`
-	Refresh string `json:"refresh_token"`
+	Access  string    `json:"access_token"`
+	Type    string    `json:"token_type"`
+	Refresh string    `json:"refresh_token"`
+	Expiry  time.Time `json:"expiry"`
 }
 
 func CacheTokenNotExists() bool {
-	_, s := os.Stat("./token.json")
+	_, s := os.Stat(DefaultTokenFile)
 	return os.IsNotExist(s)
 }
 
+// InitializeToken initialises the default account, cached in DefaultTokenFile, and sets TokenSrc to its token
+// source.
 func InitializeToken(log *log.Logger) error {
-	if CacheTokenNotExists() {
-		log.Printf("XBL: New Token")
-		var err error
-		Token, err := auth.RequestLiveTokenWriter(log.Writer())
-		if err != nil {
-			panic(err)
+	if err := InitializeAccount(Account{TokenFile: DefaultTokenFile}, log); err != nil {
+		return err
+	}
+	TokenSrc = AccountTokenSource("")
+	return nil
+}
+
+// InitializeAccount initialises the Account passed, so that its token source is returned by AccountTokenSource. If the
+// token file of the account doesn't exist, the account is signed in to using device auth, printing the code to the
+// logger passed. Other messages are logged to logging.Default. The token returned is written to the token file whenever
+// it is refreshed. In dev mode, the account is not signed in to at all.
+func InitializeAccount(a Account, log *log.Logger) error {
+	accountMu.RLock()
+	dev := devMode
+	accountMu.RUnlock()
+	if dev {
+		logging.Default().Info("dev mode, not signing in to XBL account", "account", a.Name)
+		return nil
+	}
+	if a.TokenFile == "" {
+		return fmt.Errorf("account %q has no token file", a.Name)
+	}
+	token, err := readToken(a.TokenFile)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		logging.Default().Info("signing in to XBL account", "account", a.Name)
+		if token, err = auth.RequestLiveTokenWriter(log.Writer()); err != nil {
+			return fmt.Errorf("sign in to account %q: %w", a.Name, err)
 		}
-		_ = WriteToken(Token)
-		TokenSrc = oauth2.StaticTokenSource(Token)
-	} else {
-		con, _ := ioutil.ReadFile("./token.json")
-		data := &jsonToken{}
-		err := json.Unmarshal(con, data)
-		Token := &oauth2.Token{}
-
-		Token.AccessToken = data.Access
-		Token.RefreshToken = data.Refresh
-		Token.TokenType = data.Type
-		Token.Expiry = time.Now().AddDate(100, 0, 0)
-
-		TokenSrc = oauth2.StaticTokenSource(Token)
-		log.Println("Cached XBL Token")
-		if err != nil {
-			return err
+		if err := writeToken(a.TokenFile, token); err != nil {
+			return fmt.Errorf("write token of account %q: %w", a.Name, err)
 		}
+	case err != nil:
+		return fmt.Errorf("read token of account %q: %w", a.Name, err)
+	default:
+		logging.Default().Info("using cached XBL token", "account", a.Name)
+	}
+	src := &persistentTokenSource{file: a.TokenFile, src: auth.RefreshTokenSource(token), last: token.AccessToken}
+
+	accountMu.Lock()
+	defer accountMu.Unlock()
+	accounts[a.Name] = src
+	return nil
+}
+
+// AccountTokenSource returns the token source of the account with the name passed, or of the default account if
+// the name is empty. Nil is returned if the account was not initialised.
+func AccountTokenSource(name string) oauth2.TokenSource {
+	accountMu.RLock()
+	defer accountMu.RUnlock()
+	if src, ok := accounts[name]; ok {
+		return src
 	}
 	return nil
 }
 
+// persistentTokenSource is an oauth2.TokenSource that writes the tokens returned by the source it wraps to a file
+// whenever they are refreshed, so that the account doesn't need to be signed in to again on the next start.
+type persistentTokenSource struct {
+	file string
+	src  oauth2.TokenSource
+
+	mu   sync.Mutex
+	last string
+}
+
+// Token returns a token of the source wrapped, writing it to the file of the source if it was refreshed.
+func (p *persistentTokenSource) Token() (*oauth2.Token, error) {
+	token, err := p.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if token.AccessToken != p.last {
+		if err := writeToken(p.file, token); err != nil {
+			// The token is still valid, so it is returned anyway. It is written again once it is refreshed.
+			log.Printf("error writing XBL token to %v: %v", p.file, err)
+		} else {
+			p.last = token.AccessToken
+		}
+	}
+	return token, nil
+}
+
+// readToken reads a token from the file passed. Tokens cached without an expiry are treated as expired, so that
+// they are refreshed when they are first used.
+func readToken(file string) (*oauth2.Token, error) {
+	con, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	data := &jsonToken{}
+	if err := json.Unmarshal(con, data); err != nil {
+		return nil, fmt.Errorf("decode token: %w", err)
+	}
+	if data.Refresh == "" {
+		return nil, errors.New("token has no refresh token")
+	}
+	token := &oauth2.Token{AccessToken: data.Access, RefreshToken: data.Refresh, TokenType: data.Type, Expiry: data.Expiry}
+	if token.Expiry.IsZero() {
+		token.Expiry = time.Unix(1, 0)
+	}
+	return token, nil
+}
+
 func WriteToken(token *oauth2.Token) error {
-	bytes, err := json.MarshalIndent(*token, "", "	")
+	return writeToken(DefaultTokenFile, token)
+}
+
+// writeToken writes the token passed to the file passed, which is only readable by the current user. The file is
+// replaced atomically, so that it is never left half written.
+func writeToken(file string, token *oauth2.Token) error {
+	bytes, err := json.MarshalIndent(jsonToken{Access: token.AccessToken, Type: token.TokenType, Refresh: token.RefreshToken, Expiry: token.Expiry}, "", "	")
 	if err != nil {
 		return err
 	}
-	_ = ioutil.WriteFile("./token.json", bytes, 0777)
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, bytes, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// CheckToken checks if the cached XBL token of the default account is valid, as CheckAccount does.
+func CheckToken(ctx context.Context) error {
+	return CheckAccount(ctx, Account{TokenFile: DefaultTokenFile})
+}
+
+// CheckAccount checks if the cached XBL token of the Account passed is valid by exchanging it for an XBOX Live
+// token, as is done when connecting to a backend, refreshing it first if it expired. An error is returned if no
+// token is cached or if it was rejected.
+func CheckAccount(ctx context.Context, a Account) error {
+	token, err := readToken(a.TokenFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("no token cached")
+	} else if err != nil {
+		return fmt.Errorf("read token: %w", err)
+	}
+	refreshed, err := auth.RefreshTokenSource(token).Token()
+	if err != nil {
+		return fmt.Errorf("refresh token: %w", err)
+	}
+	if refreshed.AccessToken != token.AccessToken {
+		if err := writeToken(a.TokenFile, refreshed); err != nil {
+			return fmt.Errorf("write token: %w", err)
+		}
+	}
+	if _, err := auth.RequestXBLToken(ctx, refreshed, "https://multiplayer.minecraft.net/"); err != nil {
+		return fmt.Errorf("request XBL token: %w", err)
+	}
 	return nil
 }
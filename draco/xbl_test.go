@@ -0,0 +1,44 @@
+package draco
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestPersistentTokenSource(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token.json")
+	refreshed := &oauth2.Token{AccessToken: "new", RefreshToken: "refresh", TokenType: "bearer", Expiry: time.Now().Add(time.Hour).Round(0)}
+	src := &persistentTokenSource{file: file, src: oauth2.StaticTokenSource(refreshed), last: "old"}
+	if _, err := src.Token(); err != nil {
+		t.Fatal(err)
+	}
+	token, err := readToken(file)
+	if err != nil {
+		t.Fatalf("expected the refreshed token to be written: %v", err)
+	}
+	if token.AccessToken != "new" || token.RefreshToken != "refresh" || !token.Expiry.Equal(refreshed.Expiry) {
+		t.Errorf("expected %+v to be read, got %+v", refreshed, token)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the token to only be readable by the user, got %v", info.Mode())
+	}
+}
+
+func TestReadTokenWithoutExpiry(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token.json")
+	if err := ioutil.WriteFile(file, []byte(`{"access_token": "a", "token_type": "bearer", "refresh_token": "r"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	token, err := readToken(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Valid() {
+		t.Error("expected a token cached without an expiry to be refreshed")
+	}
+}
@@ -0,0 +1,57 @@
+// Command chatfilter embeds draco with a plugin that filters the chat: messages holding blocked words are dropped
+// before they reach the backend, and the player is told why. The plugin is a packet handler added with
+// draco.WithHandler, which is called with every chat message of every player in the latest protocol, regardless of
+// the version of the player.
+//
+//	go run ./examples/chatfilter
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/cqdetdev/draco"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// chatFilter returns an Option adding a handler that drops the chat messages holding any of the words passed,
+// ignoring case.
+func chatFilter(words ...string) draco.Option {
+	return draco.WithHandler[packet.Text](proxy.ClientToServer, func(s *proxy.Session, pk *packet.Text) proxy.Action {
+		if pk.TextType != packet.TextTypeChat {
+			return proxy.Forward
+		}
+		message := strings.ToLower(pk.Message)
+		for _, w := range words {
+			if strings.Contains(message, strings.ToLower(w)) {
+				_ = s.Client().WritePacket(&packet.Text{TextType: packet.TextTypeRaw, Message: "§cYour message was not sent: it holds a blocked word."})
+				return proxy.Drop
+			}
+		}
+		return proxy.Forward
+	})
+}
+
+func main() {
+	c, err := draco.NewConfig(
+		draco.WithBackend(proxy.Backend{Name: "lobby", Address: "127.0.0.1:19133"}),
+		chatFilter("badword", "anotherbadword"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p, err := draco.New(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := p.ListenAndServe(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,40 @@
+// Command embed is a minimal program embedding draco: it builds a config with draco.NewConfig, forwarding players
+// joining on port 19132 to a single backend on port 19133, and runs the proxy until it is interrupted.
+//
+//	go run ./examples/embed
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/cqdetdev/draco"
+	"github.com/cqdetdev/draco/draco/proxy"
+)
+
+func main() {
+	c, err := draco.NewConfig(
+		draco.WithListenAddress("0.0.0.0:19132"),
+		draco.WithBackend(proxy.Backend{Name: "lobby", Address: "127.0.0.1:19133"}),
+		draco.WithMaxPlayers(100),
+		draco.WithConfig(func(c *draco.Config) {
+			c.Status.ServerName = "Embedded draco"
+		}),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p, err := draco.New(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// ListenAndServe closes the Proxy once ctx is done.
+	if err := p.ListenAndServe(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,22 @@
+// Package examples holds example programs embedding draco, each in a directory of its own. The programs are built by
+// the tests of the package, so that they keep compiling as the APIs they use change.
+package examples
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building the examples is slow")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	out, err := exec.Command(gobin, "build", "./...").CombinedOutput()
+	if err != nil {
+		t.Fatalf("examples don't build: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,138 @@
+// Command queue embeds draco with a plugin that queues players for a backend that is full. Players join the lobby and
+// run /queue survival to wait for a slot on the survival backend, which is limited to 50 players. Every second, the
+// player first in line is transferred as soon as a slot frees up. The plugin uses the proxy commands, events and
+// transfers of the proxy package.
+//
+//	go run ./examples/queue
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// queue holds the players waiting for a slot on a backend, first in line first.
+type queue struct {
+	backend string
+
+	mu      sync.Mutex
+	waiting []*proxy.Session
+}
+
+// join adds the Session passed to the end of the queue and returns its position, starting at 1. A Session already in
+// the queue keeps its position.
+func (q *queue) join(s *proxy.Session) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, other := range q.waiting {
+		if other == s {
+			return i + 1
+		}
+	}
+	q.waiting = append(q.waiting, s)
+	return len(q.waiting)
+}
+
+// leave removes the Session passed from the queue.
+func (q *queue) leave(s *proxy.Session) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, other := range q.waiting {
+		if other == s {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return
+		}
+	}
+}
+
+// first returns the Session first in line, or false if the queue is empty.
+func (q *queue) first() (*proxy.Session, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiting) == 0 {
+		return nil, false
+	}
+	return q.waiting[0], true
+}
+
+// run transfers the players in the queue to its backend every interval passed, for as long as the backend has room.
+func (q *queue) run(interval time.Duration) {
+	for range time.Tick(interval) {
+		b, ok := proxy.BackendByName(q.backend)
+		if !ok {
+			continue
+		}
+		for {
+			s, ok := q.first()
+			if !ok {
+				break
+			}
+			err := s.Transfer(b)
+			if errors.Is(err, proxy.ErrBackendFull) {
+				// The backend is still full, so the player keeps its place in line.
+				break
+			}
+			q.leave(s)
+			if err != nil && !errors.Is(err, proxy.ErrAlreadyConnected) {
+				message(s, fmt.Sprintf("§cYou could not be sent to %v: %v", q.backend, err))
+			}
+		}
+	}
+}
+
+// message sends a chat message to the player of the Session passed.
+func message(s *proxy.Session, msg string) {
+	_ = s.Client().WritePacket(&packet.Text{TextType: packet.TextTypeRaw, Message: msg})
+}
+
+// registerQueue registers the /queue command and starts transferring the players queued for the backend passed.
+func registerQueue(backend string) {
+	q := &queue{backend: backend}
+	proxy.RegisterCommand(proxy.Command{
+		Name:        "queue",
+		Description: "Waits for a slot on " + backend,
+		Run: func(s *proxy.Session, args []string) {
+			if len(args) != 1 || args[0] != backend {
+				message(s, "§cUsage: /queue "+backend)
+				return
+			}
+			message(s, fmt.Sprintf("You are number %v in the queue for %v.", q.join(s), backend))
+		},
+	})
+	// Players that leave the proxy give up their place in line.
+	proxy.Subscribe(func(e proxy.QuitEvent) {
+		q.leave(e.Session)
+	})
+	go q.run(time.Second)
+}
+
+func main() {
+	c, err := draco.NewConfig(
+		draco.WithBackend(proxy.Backend{Name: "lobby", Address: "127.0.0.1:19133"}),
+		draco.WithBackend(proxy.Backend{Name: "survival", Address: "127.0.0.1:19134", MaxPlayers: 50}),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	registerQueue("survival")
+
+	p, err := draco.New(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := p.ListenAndServe(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,63 @@
+// Command translator embeds draco with a translator of its own for a custom packet that draco doesn't know. The
+// backend sends the packet with ID 240, which 1.18.30 clients of a resource pack read with a trailing byte holding a
+// flag, but which 1.18.10 clients of the pack read without it. Packets unknown to gophertunnel are passed to
+// translators as *packet.Unknown, so the translators only change its raw payload.
+//
+//	go run ./examples/translator
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/cqdetdev/draco"
+	core "github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// idCustom is the ID of the custom packet translated.
+const idCustom = 240
+
+// downgradeCustom translates the custom packet of the latest protocol to the packet read by 1.18.10 clients by
+// removing its trailing flag.
+func downgradeCustom(pk packet.Packet) packet.Packet {
+	if pk, ok := pk.(*packet.Unknown); ok && len(pk.Payload) > 0 {
+		pk.Payload = pk.Payload[:len(pk.Payload)-1]
+	}
+	return pk
+}
+
+// upgradeCustom translates the custom packet sent by 1.18.10 clients to the packet of the latest protocol by adding
+// the trailing flag, which is unset.
+func upgradeCustom(pk packet.Packet) packet.Packet {
+	if pk, ok := pk.(*packet.Unknown); ok {
+		pk.Payload = append(pk.Payload, 0)
+	}
+	return pk
+}
+
+func main() {
+	legacy := core.Protocol{}.ID()
+	c, err := draco.NewConfig(
+		draco.WithBackend(proxy.Backend{Name: "lobby", Address: "127.0.0.1:19133"}),
+		draco.WithTranslator(idCustom, protocol.CurrentProtocol, legacy, downgradeCustom),
+		draco.WithTranslator(idCustom, legacy, protocol.CurrentProtocol, upgradeCustom),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p, err := draco.New(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := p.ListenAndServe(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,60 @@
+package draco
+
+import (
+	"github.com/cqdetdev/draco/draco/gdpr"
+)
+
+// gdprStores holds the names of the stores that the Proxy registers with the gdpr package.
+var gdprStores = []string{"bans", "positions", "captures"}
+
+// registerGDPR registers the stores of the Proxy that hold data about players with the gdpr package, so that their
+// data may be exported and purged through the admin API.
+func (p *Proxy) registerGDPR() {
+	if bans := p.bans; bans != nil {
+		gdpr.Register("bans", gdpr.Store{
+			Export: func(xuid string) (any, error) {
+				if entries := bans.EntriesOf(xuid); len(entries) > 0 {
+					return entries, nil
+				}
+				return nil, nil
+			},
+			Purge: bans.Purge,
+		})
+	}
+	if positions := p.positions; positions != nil {
+		gdpr.Register("positions", gdpr.Store{
+			Export: func(xuid string) (any, error) {
+				if e, ok := positions.Lookup(xuid, ""); ok {
+					return e, nil
+				}
+				return nil, nil
+			},
+			Purge: func(xuid string) (int, error) {
+				ok, err := positions.Forget(xuid)
+				if ok {
+					return 1, err
+				}
+				return 0, err
+			},
+		})
+	}
+	if w := p.capture; w != nil {
+		gdpr.Register("captures", gdpr.Store{
+			Export: func(xuid string) (any, error) {
+				sessions, err := w.SessionsOf(xuid)
+				if err != nil || len(sessions) == 0 {
+					return nil, err
+				}
+				return sessions, nil
+			},
+			Purge: w.Purge,
+		})
+	}
+}
+
+// unregisterGDPR unregisters the stores registered using registerGDPR, which are closed with the Proxy.
+func (p *Proxy) unregisterGDPR() {
+	for _, name := range gdprStores {
+		gdpr.Unregister(name)
+	}
+}
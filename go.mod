@@ -16,7 +16,7 @@ require (
 	github.com/klauspost/compress v1.15.1 // indirect
 	github.com/muhammadmuzzammil1998/jsonc v1.0.0 // indirect
 	github.com/pelletier/go-toml v1.9.5
-	github.com/sandertv/go-raknet v1.10.6 // indirect
+	github.com/sandertv/go-raknet v1.10.6
 	go.uber.org/atomic v1.9.0 // indirect
 	golang.org/x/crypto v0.0.0-20220411220226-7b82a4e95df4 // indirect
 	golang.org/x/image v0.0.0-20220321031419-a8550c1d254a // indirect
@@ -24,7 +24,7 @@ require (
 	golang.org/x/text v0.3.7 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/protobuf v1.28.0 // indirect
-	gopkg.in/square/go-jose.v2 v2.6.0 // indirect
+	gopkg.in/square/go-jose.v2 v2.6.0
 )
 
 replace github.com/sandertv/gophertunnel => github.com/cqdetdev/gophertunnel v1.19.9-0.20220501233859-f077ad74679d
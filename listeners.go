@@ -0,0 +1,163 @@
+package draco
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// Listener is an address that players may join the proxy on in addition to Connection.LocalAddress, as configured in
+// the Listeners of a Config. Every Listener routes the players joining it to a backend of its own and may show a
+// server name and accept versions of its own, so that a single proxy may serve several networks, such as a lobby for
+// all versions on one port and a game for a single version on another.
+type Listener struct {
+	// Address is the RakNet address listened on, such as "0.0.0.0:19133". Listeners of other transports may be served
+	// by programs embedding the proxy using ListenConfig and Serve.
+	Address string
+	// Backend is the name of the backend that players joining the Listener join first. If empty, or if no backend
+	// with the name exists once the config was reloaded, they join the first backend in Backends.
+	Backend string
+	// MOTD is the server name shown in the server list for the Listener, replacing the name obtained from
+	// Status.Providers. If empty, the name obtained is shown.
+	MOTD string
+	// Protocols lists the versions, such as "1.18.10", or protocol IDs, such as "475", that clients may join the
+	// Listener with. The latest version is always accepted. If empty, all versions supported by the proxy are.
+	Protocols []string
+	// Guest specifies if players join the Listener as guests, like those joining Guest.Address, without being
+	// authenticated with XBOX Live.
+	Guest bool
+}
+
+// protocols returns the protocols that clients may join the Listener with, excluding the latest protocol, which is
+// always accepted.
+func (l Listener) protocols() ([]minecraft.Protocol, error) {
+	if len(l.Protocols) == 0 {
+		return draco.Protocols(), nil
+	}
+	var protocols []minecraft.Protocol
+	for _, v := range l.Protocols {
+		id, err := draco.ParseVersion(v)
+		if err != nil {
+			return nil, err
+		}
+		if id == protocol.CurrentProtocol {
+			continue
+		}
+		p, _ := draco.ProtocolByID(id)
+		protocols = append(protocols, p)
+	}
+	return protocols, nil
+}
+
+// backend returns the Backend in the config passed that players joining the Listener join first. If the Listener
+// has no Backend, a backend in Balance is picked using proxy.Balance.
+func (l Listener) backend(c Config) proxy.Backend {
+	for _, b := range c.Backends {
+		if l.Backend != "" && strings.EqualFold(b.Name, l.Backend) {
+			return b
+		}
+	}
+	if b, ok := proxy.Balance(balancedBackends(c)); ok {
+		return b
+	}
+	return c.Backends[0]
+}
+
+// reachable returns the backends in the config passed that players joining the Listener may join first: the Backend
+// of the Listener, or the backends in Balance or the first backend if it has none, and the backends that Rules route
+// players to. Players migrated from other nodes of a cluster join the backend they were on, so all backends are
+// reachable if Cluster.Nodes is set.
+func (l Listener) reachable(c Config) []proxy.Backend {
+	if len(c.Cluster.Nodes) > 0 {
+		return c.Backends
+	}
+	var names []string
+	for _, b := range c.Backends {
+		if l.Backend != "" && strings.EqualFold(b.Name, l.Backend) {
+			names = append(names, b.Name)
+		}
+	}
+	if len(names) == 0 {
+		for _, b := range balancedBackends(c) {
+			names = append(names, b.Name)
+		}
+	}
+	if len(names) == 0 && len(c.Backends) > 0 {
+		names = append(names, c.Backends[0].Name)
+	}
+	for _, r := range c.Rules {
+		if r.Backend != "" {
+			names = append(names, r.Backend)
+		}
+	}
+	var reachable []proxy.Backend
+	for _, b := range c.Backends {
+		for _, name := range names {
+			if strings.EqualFold(b.Name, name) {
+				reachable = append(reachable, b)
+				break
+			}
+		}
+	}
+	return reachable
+}
+
+// resourcePackPaths returns the paths of the ResourcePacks of the backends that players joining the Listener may join
+// first, which clients joining it are offered.
+func (l Listener) resourcePackPaths(c Config) []string {
+	var paths []string
+	for _, b := range l.reachable(c) {
+		paths = append(paths, b.ResourcePacks...)
+	}
+	return paths
+}
+
+// balancedBackends returns the backends in the config passed that are listed in Balance.
+func balancedBackends(c Config) []proxy.Backend {
+	var backends []proxy.Backend
+	for _, name := range c.Balance.Backends {
+		for _, b := range c.Backends {
+			if strings.EqualFold(b.Name, name) {
+				backends = append(backends, b)
+			}
+		}
+	}
+	return backends
+}
+
+// check checks the settings of the Listener in the config passed, returning an error describing the first one that
+// is invalid.
+func (l Listener) check(c Config) error {
+	if l.Address == "" {
+		return fmt.Errorf("no address set")
+	}
+	if _, err := l.protocols(); err != nil {
+		return fmt.Errorf("protocols: %w", err)
+	}
+	if l.Backend == "" {
+		return nil
+	}
+	for _, b := range c.Backends {
+		if strings.EqualFold(b.Name, l.Backend) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown backend %v", l.Backend)
+}
+
+// motdProvider wraps around a minecraft.ServerStatusProvider and replaces the server name of the status it provides.
+type motdProvider struct {
+	minecraft.ServerStatusProvider
+	name string
+}
+
+// ServerStatus ...
+func (p motdProvider) ServerStatus(playerCount, maxPlayers int) minecraft.ServerStatus {
+	st := p.ServerStatusProvider.ServerStatus(playerCount, maxPlayers)
+	st.ServerName = p.name
+	return st
+}
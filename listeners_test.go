@@ -0,0 +1,164 @@
+package draco
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/cluster"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/go-raknet"
+)
+
+func TestListenerConfig(t *testing.T) {
+	c, err := DecodeConfig([]byte(`
+[Connection]
+Offline = true
+
+[[Backends]]
+Name = "lobby"
+Address = "127.0.0.1:19134"
+
+[[Backends]]
+Name = "skywars"
+Address = "127.0.0.1:19135"
+
+[[Listeners]]
+Address = "0.0.0.0:19133"
+Backend = "SkyWars"
+MOTD = "SkyWars"
+Protocols = ["1.18.10"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Listeners) != 1 {
+		t.Fatalf("decoded %v listeners, expected 1", len(c.Listeners))
+	}
+	l := c.Listeners[0]
+	if err := l.check(c); err != nil {
+		t.Fatal(err)
+	}
+	if b := l.backend(c); b.Name != "skywars" {
+		t.Errorf("players of the listener join %v rather than skywars", b.Name)
+	}
+	if b := (Listener{}).backend(c); b.Name != "lobby" {
+		t.Errorf("players of a listener without backend join %v rather than the first backend", b.Name)
+	}
+	c.Balance.Backends = []string{"SkyWars"}
+	if b := (Listener{}).backend(c); b.Name != "skywars" {
+		t.Errorf("players of a listener without backend join %v rather than the balanced backend", b.Name)
+	}
+	if protocols, err := l.protocols(); err != nil || len(protocols) != 1 || protocols[0].Ver() != "1.18.10" {
+		t.Errorf("unexpected protocols %v, err %v", protocols, err)
+	}
+
+	for _, invalid := range []Listener{{}, {Address: l.Address, Backend: "bedwars"}, {Address: l.Address, Protocols: []string{"0.1"}}} {
+		if err := invalid.check(c); err == nil {
+			t.Errorf("expected %+v to be invalid", invalid)
+		}
+	}
+}
+
+func TestListenerResourcePacks(t *testing.T) {
+	c, err := DecodeConfig([]byte(`
+[Connection]
+Offline = true
+
+[[Backends]]
+Name = "lobby"
+Address = "127.0.0.1:19134"
+ResourcePacks = ["packs/lobby"]
+
+[[Backends]]
+Name = "skywars"
+Address = "127.0.0.1:19135"
+ResourcePacks = ["packs/skywars"]
+
+[[Backends]]
+Name = "bedwars"
+Address = "127.0.0.1:19136"
+ResourcePacks = ["packs/bedwars"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	skywars := Listener{Backend: "SkyWars"}
+	for _, v := range []struct {
+		l        Listener
+		expected []string
+	}{
+		{skywars, []string{"packs/skywars"}},
+		{Listener{}, []string{"packs/lobby"}},
+	} {
+		if paths := v.l.resourcePackPaths(c); !reflect.DeepEqual(paths, v.expected) {
+			t.Errorf("%+v: got resource packs %v, expected %v", v.l, paths, v.expected)
+		}
+	}
+
+	c.Balance.Backends = []string{"skywars", "bedwars"}
+	if paths := (Listener{}).resourcePackPaths(c); !reflect.DeepEqual(paths, []string{"packs/skywars", "packs/bedwars"}) {
+		t.Errorf("got resource packs %v, expected those of the balanced backends", paths)
+	}
+	c.Rules = []proxy.Rule{{Backend: "lobby"}}
+	if paths := skywars.resourcePackPaths(c); !reflect.DeepEqual(paths, []string{"packs/lobby", "packs/skywars"}) {
+		t.Errorf("got resource packs %v, expected those of the backend of the listener and the rule", paths)
+	}
+	c.Cluster.Nodes = []cluster.Node{{Name: "play2", API: "http://127.0.0.1:8081"}}
+	if backends := skywars.reachable(c); len(backends) != 3 {
+		t.Errorf("%v backends reachable in a cluster, expected all 3", len(backends))
+	}
+}
+
+func TestServeListeners(t *testing.T) {
+	c := testConfig(t)
+	c.Listeners = []Listener{{Address: "127.0.0.1:0", MOTD: "SkyWars"}}
+	p := &Proxy{started: c, running: c}
+	if err := p.open(c); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = p.ListenAndServe(ctx)
+		close(done)
+	}()
+	// The Proxy disconnects all sessions when it is closed, so it must be closed before the next test starts any.
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p.mu.Lock()
+		n := len(p.listeners)
+		p.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%v listeners served, expected 2", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	p.mu.Lock()
+	listeners := p.listeners
+	p.mu.Unlock()
+	// Only the listener of the config shows its MOTD, while Connection.LocalAddress shows the status of the proxy.
+	motds := 0
+	for _, li := range listeners {
+		pong, err := raknet.PingTimeout(li.Addr().String(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(pong), ";SkyWars;") {
+			motds++
+		}
+	}
+	if motds != 1 {
+		t.Errorf("%v listeners showed the MOTD of the listener, expected 1", motds)
+	}
+}
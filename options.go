@@ -0,0 +1,183 @@
+package draco
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Option configures the Config built using NewConfig.
+type Option func(o *options) error
+
+// options holds the Config built using NewConfig and the translators, protocols and handlers to register once it
+// was found valid.
+type options struct {
+	c        Config
+	register []func()
+}
+
+// NewConfig builds a Config for programs embedding draco from the options passed, applied in order over an empty
+// config, so that the Config doesn't have to be constructed field by field. The defaults of the settings not set by
+// any option are filled in like DecodeConfig does, after which the Config is checked using CheckConfig. The
+// translators, protocols and handlers of the options are registered only if the Config is valid, so that a Config
+// that fails to build leaves no trace.
+//
+//	c, err := draco.NewConfig(
+//		draco.WithListenAddress("0.0.0.0:19132"),
+//		draco.WithBackend(proxy.Backend{Name: "lobby", Address: "127.0.0.1:19133"}),
+//		draco.WithMetrics("127.0.0.1:8080"),
+//		draco.WithHandler[packet.Text](proxy.ClientToServer, func(s *proxy.Session, pk *packet.Text) proxy.Action {
+//			return proxy.Forward
+//		}),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	p, err := draco.New(c)
+func NewConfig(opts ...Option) (Config, error) {
+	var o options
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return Config{}, err
+		}
+	}
+	if len(o.c.Backends) == 0 && o.c.Connection.RemoteAddress == "" {
+		return Config{}, errors.New("config: no backends: use WithBackend")
+	}
+	fillDefaults(&o.c)
+	if err := checkErr(CheckConfig(o.c)); err != nil {
+		return Config{}, err
+	}
+	for _, f := range o.register {
+		f()
+	}
+	return o.c, nil
+}
+
+// WithConfig applies the function passed to the Config built, so that settings without an Option of their own may be
+// set, such as in c.Log.Level = "debug".
+func WithConfig(f func(c *Config)) Option {
+	return func(o *options) error {
+		f(&o.c)
+		return nil
+	}
+}
+
+// WithListenAddress sets the address that players join the proxy on, such as "0.0.0.0:19132", which is the default.
+func WithListenAddress(address string) Option {
+	return func(o *options) error {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return fmt.Errorf("listen address: %w", err)
+		}
+		o.c.Connection.LocalAddress = address
+		return nil
+	}
+}
+
+// WithBackend adds a backend that players may be forwarded to. Players join the backend added first.
+func WithBackend(b proxy.Backend) Option {
+	return func(o *options) error {
+		if b.Name == "" {
+			return errors.New("backend: no name")
+		}
+		if _, _, err := net.SplitHostPort(b.Address); err != nil {
+			return fmt.Errorf("backend %v: address: %w", b.Name, err)
+		}
+		for _, other := range o.c.Backends {
+			if strings.EqualFold(other.Name, b.Name) {
+				return fmt.Errorf("backend %v: added twice", b.Name)
+			}
+		}
+		o.c.Backends = append(o.c.Backends, b)
+		return nil
+	}
+}
+
+// WithMaxPlayers sets the maximum amount of players connected to the proxy at the same time. If 0, the default, there
+// is no limit.
+func WithMaxPlayers(n int) Option {
+	return func(o *options) error {
+		if n < 0 {
+			return fmt.Errorf("max players: negative amount %v", n)
+		}
+		o.c.Connection.MaxPlayers = n
+		return nil
+	}
+}
+
+// WithMetrics serves the metrics of the proxy over HTTP as JSON on the address passed, such as "127.0.0.1:8080".
+func WithMetrics(address string) Option {
+	return func(o *options) error {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return fmt.Errorf("metrics address: %w", err)
+		}
+		o.c.Metrics.Address = address
+		return nil
+	}
+}
+
+// WithAdmin serves the admin HTTP API on the address passed, protected by the secret passed.
+func WithAdmin(address, secret string) Option {
+	return func(o *options) error {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return fmt.Errorf("admin address: %w", err)
+		}
+		if secret == "" {
+			return errors.New("admin: no secret")
+		}
+		o.c.Admin.Address, o.c.Admin.Secret = address, secret
+		return nil
+	}
+}
+
+// WithProtocol registers an additional protocol that clients may join with using draco.RegisterProtocol.
+func WithProtocol(p minecraft.Protocol) Option {
+	return func(o *options) error {
+		if p == nil {
+			return errors.New("protocol: nil protocol")
+		}
+		o.register = append(o.register, func() {
+			draco.RegisterProtocol(p)
+		})
+		return nil
+	}
+}
+
+// WithTranslator registers a draco.Translator for packets with the ID passed sent by the protocol version from to the
+// protocol version to using draco.RegisterTranslator.
+func WithTranslator(id uint32, from, to int32, t draco.Translator) Option {
+	return func(o *options) error {
+		if t == nil {
+			return fmt.Errorf("translator for packet %v: nil translator", id)
+		}
+		if from == to {
+			return fmt.Errorf("translator for packet %v: translates protocol %v to itself", id, from)
+		}
+		o.register = append(o.register, func() {
+			draco.RegisterTranslator(id, from, to, t)
+		})
+		return nil
+	}
+}
+
+// WithHandler registers a handler for packets of type T travelling in the Direction passed using proxy.Handle.
+func WithHandler[T any, P interface {
+	*T
+	packet.Packet
+}](d proxy.Direction, h func(s *proxy.Session, pk P) proxy.Action) Option {
+	return func(o *options) error {
+		if h == nil {
+			return fmt.Errorf("handler for %T: nil handler", P(new(T)))
+		}
+		o.register = append(o.register, func() {
+			proxy.Handle[T, P](d, h)
+		})
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package draco
+
+import (
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestNewConfig(t *testing.T) {
+	c, err := NewConfig(
+		WithListenAddress("127.0.0.1:19140"),
+		WithBackend(proxy.Backend{Name: "lobby", Address: "127.0.0.1:19141"}),
+		WithBackend(proxy.Backend{Name: "survival", Address: "127.0.0.1:19142"}),
+		WithMaxPlayers(50),
+		WithMetrics("127.0.0.1:8080"),
+		WithConfig(func(c *Config) {
+			c.Connection.Offline = true
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected the config to be valid: %v", err)
+	}
+	if c.Connection.LocalAddress != "127.0.0.1:19140" || c.Connection.MaxPlayers != 50 || c.Metrics.Address != "127.0.0.1:8080" {
+		t.Errorf("options not applied: %+v", c.Connection)
+	}
+	if len(c.Backends) != 2 || c.Backends[0].Name != "lobby" || !c.Backends[1].Offline {
+		t.Errorf("unexpected backends %+v", c.Backends)
+	}
+	if c.Status.ServerName != "Draco" {
+		t.Errorf("expected defaults to be filled in, got server name %q", c.Status.ServerName)
+	}
+}
+
+func TestNewConfigInvalid(t *testing.T) {
+	registered := 0
+	register := func(o *options) error {
+		o.register = append(o.register, func() {
+			registered++
+		})
+		return nil
+	}
+	lobby := WithBackend(proxy.Backend{Name: "lobby", Address: "127.0.0.1:19141"})
+	for name, opts := range map[string][]Option{
+		"no backends":       {register},
+		"duplicate backend": {register, lobby, lobby},
+		"backend address":   {register, WithBackend(proxy.Backend{Name: "lobby", Address: "localhost"})},
+		"listen address":    {register, lobby, WithListenAddress("19132")},
+		"admin secret":      {register, lobby, WithAdmin("127.0.0.1:8081", "")},
+		"nil handler":       {register, lobby, WithHandler[packet.Text](proxy.ClientToServer, nil)},
+		"checked config": {register, lobby, WithConfig(func(c *Config) {
+			c.Status.Interval = "often"
+		})},
+	} {
+		if _, err := NewConfig(opts...); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+	if registered != 0 {
+		t.Errorf("expected nothing to be registered for invalid configs, got %v registrations", registered)
+	}
+	if _, err := NewConfig(register, lobby); err != nil || registered != 1 {
+		t.Errorf("expected registrations of a valid config to be applied, got %v (err %v)", registered, err)
+	}
+}
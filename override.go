@@ -0,0 +1,216 @@
+package draco
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pelletier/go-toml"
+)
+
+// Override overrides a single setting of a Config, such as by an environment variable or a command line flag, so that
+// the proxy may be deployed without a config.toml holding every setting.
+type Override struct {
+	// Setting is the path of the setting overridden, such as "Connection.RemoteAddress". Its parts are matched with
+	// the names of the fields of Config ignoring case.
+	Setting string
+	// Value is the value of the setting, parsed according to the type of the setting. Lists are separated by commas.
+	Value string
+	// Source describes where the Override came from, such as "environment variable DRACO_REMOTE_ADDRESS", which is
+	// included in errors.
+	Source string
+}
+
+// EnvPrefix is the prefix of the environment variables that override settings of a Config.
+const EnvPrefix = "DRACO_"
+
+// EnvOverrides returns the Overrides of the environment variables passed, in the "KEY=value" form returned by
+// os.Environ. Every setting of a Config that isn't a list of tables or a map may be overridden by the environment
+// variable named EnvPrefix followed by the path of the setting in upper snake case, such as
+// DRACO_CONNECTION_REMOTE_ADDRESS for Connection.RemoteAddress. Settings in the Connection section may also be
+// overridden without the name of the section, such as DRACO_REMOTE_ADDRESS. Variables without EnvPrefix are ignored,
+// while an error is returned for variables with EnvPrefix that don't name a setting, so that typos are noticed.
+func EnvOverrides(environ []string) ([]Override, error) {
+	names := envNames()
+	var overrides []Override
+	for _, kv := range environ {
+		key, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, EnvPrefix) {
+			continue
+		}
+		setting, ok := names[key]
+		if !ok {
+			return nil, fmt.Errorf("environment variable %v does not override any setting", key)
+		}
+		overrides = append(overrides, Override{Setting: setting, Value: value, Source: "environment variable " + key})
+	}
+	// The environment is not ordered, while the order of overrides matters if two variables name the same setting.
+	sort.SliceStable(overrides, func(i, j int) bool {
+		return overrides[i].Source < overrides[j].Source
+	})
+	return overrides, nil
+}
+
+// DecodeConfigWithOverrides decodes the contents of config.toml passed like DecodeConfig, applying the Overrides
+// passed in order before the defaults of settings that are not set are filled in, so that an overridden
+// Connection.RemoteAddress is also used as the address of the default backend. An error naming the Source of an
+// Override is returned if it can't be applied.
+func DecodeConfigWithOverrides(data []byte, overrides []Override) (Config, error) {
+	c := Config{}
+	if err := toml.Unmarshal(data, &c); err != nil {
+		return c, err
+	}
+	for _, o := range overrides {
+		if err := applyOverride(&c, o); err != nil {
+			return c, fmt.Errorf("%v: %w", o.Source, err)
+		}
+	}
+	fillDefaults(&c)
+	return c, nil
+}
+
+// applyOverride applies the Override passed to the config passed.
+func applyOverride(c *Config, o Override) error {
+	v := reflect.ValueOf(c).Elem()
+	parts := strings.Split(o.Setting, ".")
+	for i, part := range parts {
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("setting %v has no setting %v", strings.Join(parts[:i], "."), part)
+		}
+		f := v.FieldByNameFunc(func(name string) bool {
+			return strings.EqualFold(name, part)
+		})
+		if !f.IsValid() {
+			return fmt.Errorf("no such setting %v", strings.Join(parts[:i+1], "."))
+		}
+		v = f
+	}
+	if err := setValue(v, o.Value); err != nil {
+		return fmt.Errorf("set %v: %w", o.Setting, err)
+	}
+	return nil
+}
+
+// setValue parses the value passed according to the type of the setting passed and sets it.
+func setValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not an integer of %v bits", value, v.Type().Bits())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not an unsigned integer of %v bits", value, v.Type().Bits())
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if !overridable(v.Type()) {
+			return fmt.Errorf("lists of %v can only be set in config.toml", v.Type().Elem())
+		}
+		var parts []string
+		if value != "" {
+			parts = strings.Split(value, ",")
+		}
+		s := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setValue(s.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	default:
+		return fmt.Errorf("settings of type %v can only be set in config.toml", v.Type())
+	}
+	return nil
+}
+
+// overridable checks if settings of the type passed may be overridden.
+func overridable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Slice && overridable(t.Elem())
+	}
+	return false
+}
+
+// envNames returns the paths of all settings of a Config that may be overridden, keyed by the names of the environment
+// variables overriding them.
+func envNames() map[string]string {
+	names, aliases := map[string]string{}, map[string]string{}
+	var walk func(t reflect.Type, path []string)
+	walk = func(t reflect.Type, path []string) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			p := append(append([]string(nil), path...), f.Name)
+			if f.Type.Kind() == reflect.Struct {
+				walk(f.Type, p)
+				continue
+			}
+			if !overridable(f.Type) {
+				continue
+			}
+			names[envName(p)] = strings.Join(p, ".")
+			if len(p) == 2 && p[0] == "Connection" {
+				aliases[envName(p[1:])] = strings.Join(p, ".")
+			}
+		}
+	}
+	walk(reflect.TypeOf(Config{}), nil)
+	// The full names of settings take precedence over the short names of settings in the Connection section.
+	for name, setting := range aliases {
+		if _, ok := names[name]; !ok {
+			names[name] = setting
+		}
+	}
+	return names
+}
+
+// envName returns the name of the environment variable overriding the setting with the path passed.
+func envName(path []string) string {
+	parts := make([]string, len(path))
+	for i, p := range path {
+		parts[i] = upperSnake(p)
+	}
+	return EnvPrefix + strings.Join(parts, "_")
+}
+
+// upperSnake converts a name in camel case, such as "MaxSizeMB" or "XBLToken", to upper snake case, such as
+// "MAX_SIZE_MB" and "XBL_TOKEN".
+func upperSnake(name string) string {
+	r := []rune(name)
+	var b strings.Builder
+	for i, c := range r {
+		if i > 0 && unicode.IsUpper(c) && (unicode.IsLower(r[i-1]) || unicode.IsDigit(r[i-1]) || (i+1 < len(r) && unicode.IsLower(r[i+1]))) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(c))
+	}
+	return b.String()
+}
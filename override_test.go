@@ -0,0 +1,76 @@
+package draco
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpperSnake(t *testing.T) {
+	for name, expected := range map[string]string{
+		"RemoteAddress": "REMOTE_ADDRESS",
+		"MaxSizeMB":     "MAX_SIZE_MB",
+		"XBLToken":      "XBL_TOKEN",
+		"CodeTTL":       "CODE_TTL",
+		"Connection":    "CONNECTION",
+	} {
+		if s := upperSnake(name); s != expected {
+			t.Errorf("%v converted to %v, expected %v", name, s, expected)
+		}
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	overrides, err := EnvOverrides([]string{
+		"PATH=/usr/bin",
+		"DRACO_REMOTE_ADDRESS=127.0.0.1:19133",
+		"DRACO_CONNECTION_MAX_PLAYERS=50",
+		"DRACO_STATUS_PROVIDERS=static, foreign",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := DecodeConfigWithOverrides([]byte("[Connection]\nMaxPlayers = 10\n"), overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Connection.RemoteAddress != "127.0.0.1:19133" || c.Connection.MaxPlayers != 50 {
+		t.Errorf("connection settings not overridden: %+v", c.Connection)
+	}
+	if c.Backends[0].Address != "127.0.0.1:19133" {
+		t.Errorf("default backend has address %v, expected the overridden remote address", c.Backends[0].Address)
+	}
+	if !reflect.DeepEqual(c.Status.Providers, []string{"static", "foreign"}) {
+		t.Errorf("status providers overridden as %v", c.Status.Providers)
+	}
+
+	if _, err := EnvOverrides([]string{"DRACO_REMOTE_ADRESS=127.0.0.1:19133"}); err == nil {
+		t.Error("expected error for an environment variable that doesn't override any setting")
+	}
+}
+
+func TestOverrideErrors(t *testing.T) {
+	for _, o := range []Override{
+		{Setting: "Connection.MaxPlayers", Value: "many"},
+		{Setting: "Connection.NoSuchSetting", Value: "1"},
+		{Setting: "Connection.MaxPlayers.Value", Value: "1"},
+		{Setting: "Backends", Value: "lobby"},
+	} {
+		o.Source = "flag -set " + o.Setting
+		_, err := DecodeConfigWithOverrides(nil, []Override{o})
+		if err == nil {
+			t.Errorf("expected error overriding %v with %q", o.Setting, o.Value)
+			continue
+		}
+		if !strings.Contains(err.Error(), o.Source) {
+			t.Errorf("error %q does not name the source of the override", err)
+		}
+	}
+	c, err := DecodeConfigWithOverrides(nil, []Override{{Setting: "network.potato.chunkradius", Value: "6"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Network.Potato.ChunkRadius != 6 {
+		t.Errorf("setting matched ignoring case was not overridden")
+	}
+}
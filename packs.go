@@ -0,0 +1,161 @@
+package draco
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/cqdetdev/draco/draco/respack"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"github.com/sandertv/gophertunnel/minecraft/resource"
+)
+
+// prefetchTimeout is the maximum duration to wait for backends to send their resource packs when they are
+// prefetched.
+const prefetchTimeout = time.Second * 30
+
+// openPackCache opens the cache of the resource packs of backends in the config passed, if passthrough is enabled,
+// and prefetches the packs of all backends if configured to.
+func (p *Proxy) openPackCache(c Config) {
+	if !c.ResourcePacks.Passthrough {
+		return
+	}
+	cache, err := respack.Open(c.ResourcePacks.CacheDirectory)
+	if err != nil {
+		logging.Default().Error("error opening resource pack cache", "err", err)
+		return
+	}
+	p.packs = cache
+	if c.ResourcePacks.Prefetch {
+		p.prefetchPacks(c)
+	}
+}
+
+// resourcePacks holds the resource packs that the proxy offers to clients, which are loaded once on start.
+type resourcePacks struct {
+	// common holds the packs in Connection.ResourcePacks, and cached the packs of backends cached if passthrough is
+	// enabled. Both are offered on every listener.
+	common, cached []*resource.Pack
+	// backends holds the packs in the ResourcePacks of every backend, keyed by the lowercase name of the backend.
+	backends map[string][]*resource.Pack
+}
+
+// loadResourcePacks loads the resource packs that the proxy offers to clients in the config passed: the packs in
+// Connection.ResourcePacks and in the ResourcePacks of every backend, and the packs of backends cached, if passthrough
+// is enabled.
+func (p *Proxy) loadResourcePacks(c Config) (resourcePacks, error) {
+	common, err := compileResourcePacks(c.Connection.ResourcePacks)
+	if err != nil {
+		return resourcePacks{}, err
+	}
+	packs := resourcePacks{common: common, backends: make(map[string][]*resource.Pack, len(c.Backends))}
+	for _, b := range c.Backends {
+		backendPacks, err := compileResourcePacks(b.ResourcePacks)
+		if err != nil {
+			return resourcePacks{}, fmt.Errorf("backend %v: %w", b.Name, err)
+		}
+		packs.backends[strings.ToLower(b.Name)] = backendPacks
+	}
+	if p.packs == nil {
+		return packs, nil
+	}
+	if packs.cached, err = p.packs.Packs(); err != nil {
+		logging.Default().Warn("error loading cached resource packs", "err", err)
+	}
+	return packs, nil
+}
+
+// offered returns the resource packs offered to clients that may join the backends passed: the packs in
+// Connection.ResourcePacks, followed by the ResourcePacks of the backends and the packs of backends cached. A pack is
+// only offered once, in the first version found in this order, so that the configured version of a cached pack is
+// sent.
+func (r resourcePacks) offered(backends []proxy.Backend) []*resource.Pack {
+	var packs []*resource.Pack
+	seen := map[string]struct{}{}
+	add := func(more []*resource.Pack) {
+		for _, pack := range more {
+			if _, ok := seen[pack.UUID()]; !ok {
+				seen[pack.UUID()] = struct{}{}
+				packs = append(packs, pack)
+			}
+		}
+	}
+	add(r.common)
+	for _, b := range backends {
+		add(r.backends[strings.ToLower(b.Name)])
+	}
+	add(r.cached)
+	return packs
+}
+
+// offersPacks checks if the resource packs at the paths passed include all ResourcePacks of the backend passed.
+func offersPacks(paths []string, b proxy.Backend) bool {
+	for _, path := range b.ResourcePacks {
+		found := false
+		for _, offered := range paths {
+			if offered == path {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// storeBackendPacks stores the resource packs that the backend passed sent while the connection passed was dialed
+// in the pack cache, if passthrough is enabled. Packs stored for the first time are offered to clients once the
+// proxy is restarted, as the listeners hold the packs they offer from when they were started.
+func (p *Proxy) storeBackendPacks(backend proxy.Backend, conn *minecraft.Conn) {
+	if p.packs == nil {
+		return
+	}
+	for _, pack := range conn.ResourcePacks() {
+		stored, err := p.packs.Store(pack)
+		if err != nil {
+			logging.Default().Warn("error caching resource pack of backend", "backend", backend.Name, "pack", pack.UUID(), "err", err)
+			continue
+		}
+		if stored {
+			logging.Default().Info("cached resource pack of backend, offered to players from the next start", "backend", backend.Name, "pack", pack.Name(), "version", pack.Version())
+		}
+	}
+}
+
+// prefetchPacks dials every backend of the config passed once to download the resource packs that they send, so that
+// they are offered to clients from the first start. Backends that don't respond within prefetchTimeout are skipped.
+// gophertunnel downloads all packs of a backend whenever it is dialed, so prefetching only pays off on the first
+// start with a backend or after its packs were updated, and may be disabled otherwise.
+func (p *Proxy) prefetchPacks(c Config) {
+	var wg sync.WaitGroup
+	for _, b := range c.Backends {
+		wg.Add(1)
+		go func(b proxy.Backend) {
+			defer wg.Done()
+			conn, err := p.dialBackend(c, b, login.IdentityData{DisplayName: "draco"}, login.ClientData{}, &net.UDPAddr{}, "", false)
+			if err != nil {
+				logging.Default().Warn("error prefetching resource packs of backend", "backend", b.Name, "err", err)
+				return
+			}
+			// dialBackend stores the packs of the backend once they were downloaded.
+			_ = conn.Close()
+		}(b)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(prefetchTimeout):
+		logging.Default().Warn("timed out prefetching resource packs of backends", "timeout", prefetchTimeout)
+	}
+}
@@ -0,0 +1,1313 @@
+// Package draco implements a proxy that lets players join servers on the latest version of Minecraft with older
+// clients, translating the packets between the protocols. The proxy is run by the draco command, but may also be
+// embedded in other Go programs using Proxy, which may then be configured programmatically and serve listeners of
+// their own.
+package draco
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/admin"
+	"github.com/cqdetdev/draco/draco/ban"
+	"github.com/cqdetdev/draco/draco/capture"
+	"github.com/cqdetdev/draco/draco/cluster"
+	"github.com/cqdetdev/draco/draco/discord"
+	"github.com/cqdetdev/draco/draco/forward"
+	"github.com/cqdetdev/draco/draco/gdpr"
+	"github.com/cqdetdev/draco/draco/identity"
+	"github.com/cqdetdev/draco/draco/lang"
+	"github.com/cqdetdev/draco/draco/link"
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/metrics"
+	"github.com/cqdetdev/draco/draco/mismatch"
+	"github.com/cqdetdev/draco/draco/policy"
+	"github.com/cqdetdev/draco/draco/positions"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/cqdetdev/draco/draco/proxyproto"
+	"github.com/cqdetdev/draco/draco/rejected"
+	"github.com/cqdetdev/draco/draco/remoteconfig"
+	"github.com/cqdetdev/draco/draco/respack"
+	"github.com/cqdetdev/draco/draco/slo"
+	"github.com/cqdetdev/draco/draco/sockopt"
+	"github.com/cqdetdev/draco/draco/state"
+	"github.com/cqdetdev/draco/draco/status"
+	"github.com/cqdetdev/draco/draco/telemetry"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"github.com/sandertv/gophertunnel/minecraft/resource"
+	"golang.org/x/oauth2"
+)
+
+// ErrClosed is returned by the methods of a Proxy that serve players once it was closed.
+var ErrClosed = errors.New("proxy closed")
+
+// Proxy is a proxy that forwards the players joining its listeners to the backends in its Config, translating the
+// packets of players on older versions. A Proxy is created using New and serves players using ListenAndServe, or
+// using Serve with listeners of its own.
+//
+// The settings that a Proxy applies, such as the backends and the permissions of roles, are shared by the whole
+// process, as the sessions of the proxy package read them, so only one Proxy should be created per process.
+type Proxy struct {
+	// mu guards the fields below.
+	mu sync.Mutex
+	// started is the config that the Proxy was created with, and running the config last applied, which differs from
+	// started once the config is reloaded.
+	started, running Config
+	// source is the function that the config is obtained from when it is reloaded through the admin API.
+	source func() (Config, error)
+	// local is the config last passed to New or Reload, and remote the remote config applied over it if Remote is
+	// set. stopRemote stops watching the remote config.
+	local      Config
+	remote     remoteconfig.Document
+	stopRemote context.CancelFunc
+	// stopDiscovery stops registering the Proxy with the service discovery in Discovery and deregisters it, or is nil
+	// if the Proxy is not registered.
+	stopDiscovery func()
+	// status is the status.Chain showing the status of the proxy in the server list.
+	status *status.Chain
+	// verifier verifies the identities of players if Identity.Verify is set, and is nil otherwise.
+	verifier *identity.Verifier
+	// bans holds the bans and mutes of players. It is nil if no ban file is configured.
+	bans *ban.Store
+	// positions holds the last known positions of players. It is nil if no positions file is configured.
+	positions *positions.Store
+	// packs is the cache that the resource packs of backends are stored in if ResourcePacks.Passthrough is set, or
+	// nil otherwise, and resourcePacks the packs offered to clients.
+	packs         *respack.Cache
+	resourcePacks resourcePacks
+	// capture records all packets if Log.CaptureFile is set, and is nil otherwise.
+	capture *capture.Writer
+	// telemetry posts the gaps of the translator if Telemetry.URL is set, and is nil otherwise.
+	telemetry *telemetry.Reporter
+
+	listeners []*minecraft.Listener
+	servers   []*http.Server
+	closed    bool
+}
+
+// New creates a Proxy with the config passed, which is checked using CheckConfig first. If Remote is set, the remote
+// config is obtained and applied over the config passed, and the Proxy is reloaded whenever it changes. New signs in to
+// the XBOX Live accounts that the backends require, opens the bans and caches in the config and applies its settings,
+// but doesn't listen for players until ListenAndServe or Serve is called. The Proxy must be closed using Close once it
+// is no longer used.
+func New(local Config) (*Proxy, error) {
+	c, doc, err := loadRemoteConfig(local, true)
+	if err != nil {
+		return nil, fmt.Errorf("remote config: %w", err)
+	}
+	if err := checkErr(CheckConfig(c)); err != nil {
+		return nil, err
+	}
+	if err := prepare(c); err != nil {
+		return nil, err
+	}
+	if err := selfTest(); err != nil {
+		return nil, err
+	}
+	if err := initializeAccounts(c, log.New(logging.Writer(logging.LevelInfo), "", 0)); err != nil {
+		return nil, err
+	}
+	p := &Proxy{started: c, running: c, local: local, remote: doc}
+	if err := p.open(c); err != nil {
+		_ = p.Close()
+		return nil, err
+	}
+	if c.Remote.Kind != "" {
+		p.watchRemote(c, doc.Version)
+	}
+	return p, nil
+}
+
+// prepare applies the settings of the config passed that the translation tables and the signing in to accounts
+// depend on, so that they are in effect before the tables are first used and accounts are signed in to.
+func prepare(c Config) error {
+	draco.SetDevMode(c.Connection.DevMode)
+	proxy.SetDevMode(c.Connection.DevMode)
+	if c.Connection.DevMode {
+		logging.Default().Warn("running in dev mode: players are not authenticated and are assigned fake identities")
+	}
+	if c.Connection.StripEducationFeatures {
+		proxy.StripEducationFeatures()
+	}
+	if c.Lang.Directory != "" {
+		if err := lang.Default().LoadDir(c.Lang.Directory); err != nil {
+			return fmt.Errorf("load messages: %w", err)
+		}
+	}
+	if c.Cache.Directory != "" {
+		if err := state.SetTableCache(c.Cache.Directory); err != nil {
+			return err
+		}
+		if err := draco.LoadWarmCaches(c.Cache.Directory); err != nil {
+			logging.Default().Warn("error loading caches", "err", err)
+		}
+	}
+	return nil
+}
+
+// open opens everything in the config passed that the Proxy holds on to and applies the config.
+func (p *Proxy) open(c Config) error {
+	if c.Bans.File != "" {
+		if err := p.openBans(c); err != nil {
+			return err
+		}
+	}
+	// The bans are opened first, so that the chat of muted players is dropped before the bridges could relay it.
+	if err := startDiscordBridges(c); err != nil {
+		return err
+	}
+	if c.Positions.File != "" {
+		s, err := positions.Open(c.Positions.File)
+		if err != nil {
+			return fmt.Errorf("open positions: %w", err)
+		}
+		p.positions = s
+	}
+	if c.Log.CaptureFile != "" {
+		w, err := capture.Create(c.Log.CaptureFile)
+		if err != nil {
+			return fmt.Errorf("create capture file: %w", err)
+		}
+		p.capture = w
+		proxy.SetCapture(w)
+		logging.Default().Info("recording all packets", "file", c.Log.CaptureFile)
+	}
+	p.registerGDPR()
+	if c.Log.MismatchReport != "" {
+		mismatch.WriteEvery(c.Log.MismatchReport, time.Minute)
+	}
+	if c.Telemetry.URL != "" {
+		interval := time.Hour
+		if c.Telemetry.Interval != "" {
+			interval = parseDuration(c.Telemetry.Interval)
+		}
+		p.telemetry = telemetry.Start(c.Telemetry.URL, interval)
+		logging.Default().Info("reporting translator gaps", "url", c.Telemetry.URL, "interval", interval)
+	}
+	if err := p.applyConfig(c); err != nil {
+		return err
+	}
+	p.openPackCache(c)
+	packs, err := p.loadResourcePacks(c)
+	if err != nil {
+		return err
+	}
+	p.resourcePacks = packs
+	p.status = statusProvider(c)
+	if c.Identity.Verify {
+		p.verifier = identity.New(identity.Config{Strict: c.Identity.Strict, ClockSkew: parseDuration(c.Identity.ClockSkew)})
+	}
+	return nil
+}
+
+// ListenAndServe serves the APIs in the config of the Proxy and listens for players on Connection.LocalAddress and the
+// addresses of Listeners, and for guests on Guest.Address if set, serving them until ctx is done or the Proxy is
+// closed. Once ctx is done, the Proxy is closed and the error returned by Close is returned, or ctx.Err() if closing
+// succeeded. An error is returned immediately if any of the addresses can't be listened on.
+func (p *Proxy) ListenAndServe(ctx context.Context) error {
+	c := p.startedConfig()
+	banAddress := c.Bans.Address
+	if p.bans == nil {
+		// The ban API is only served if a ban file is set.
+		banAddress = ""
+	}
+	for _, s := range []struct {
+		name, address string
+		handler       func(c Config) (http.Handler, error)
+	}{
+		{"metrics", c.Metrics.Address, func(Config) (http.Handler, error) { return metrics.Handler(), nil }},
+		{"link API", c.Link.Address, linker},
+		{"ban API", banAddress, p.banAPI},
+		{"admin API", c.Admin.Address, p.adminAPI},
+	} {
+		if s.address == "" {
+			continue
+		}
+		h, err := s.handler(c)
+		if err != nil {
+			return fmt.Errorf("serve %v: %w", s.name, err)
+		}
+		if err := p.serveHTTP(s.name, s.address, h); err != nil {
+			return fmt.Errorf("serve %v: %w", s.name, err)
+		}
+	}
+
+	if c.Guest.Address != "" {
+		guests, err := p.Listen(c.Guest.Address, true)
+		if err != nil {
+			return err
+		}
+		go func() {
+			_ = p.Serve(guests, true)
+		}()
+	}
+	for _, l := range c.Listeners {
+		li, err := p.listen(l)
+		if err != nil {
+			return err
+		}
+		go func(l Listener) {
+			_ = p.serve(li, l)
+		}(l)
+	}
+	li, err := p.Listen(c.Connection.LocalAddress, false)
+	if err != nil {
+		return err
+	}
+	if c.Discovery.Kind != "" {
+		p.register(c)
+	}
+	done, closed := make(chan struct{}), make(chan error, 1)
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			closed <- p.Close()
+		case <-done:
+		}
+	}()
+	err = p.Serve(li, false)
+	if ctx.Err() != nil {
+		if err := <-closed; err != nil {
+			return err
+		}
+		return ctx.Err()
+	}
+	return err
+}
+
+// ListenConfig returns the minecraft.ListenConfig that the Proxy listens for players with, or for guests if guest is
+// true, so that listeners of other networks may be created for the Proxy and passed to Serve. Guests are not
+// required to be authenticated with XBOX Live. The protocols of clients are observed using proxy.ObserveLogin, and
+// their logins verified in the PacketFunc of the config if Identity.Verify is set. Clients on versions newer than
+// supported are accepted if Connection.PassthroughNewest is set.
+func (p *Proxy) ListenConfig(guest bool) minecraft.ListenConfig {
+	// A Listener without Protocols accepts all protocols, so its config can't fail to be created.
+	conf, _ := p.listenConfig(Listener{Guest: guest})
+	return conf
+}
+
+// listenConfig returns the minecraft.ListenConfig that the Proxy listens for players joining the Listener passed
+// with, which only accepts the protocols of the Listener and shows its MOTD.
+func (p *Proxy) listenConfig(l Listener) (minecraft.ListenConfig, error) {
+	p.mu.Lock()
+	c, packs, v, chain := p.started, p.resourcePacks, p.verifier, p.status
+	p.mu.Unlock()
+	protocols, err := l.protocols()
+	if err != nil {
+		return minecraft.ListenConfig{}, err
+	}
+	if c.Connection.PassthroughNewest {
+		protocols = append(protocols, draco.PassthroughProtocols()...)
+	}
+	authDisabled := c.Connection.AuthenticationDisabled
+	if l.Guest {
+		authDisabled, v = true, nil
+	}
+	var provider minecraft.ServerStatusProvider = proxy.LimitStatusProvider{ServerStatusProvider: chain}
+	if l.MOTD != "" {
+		provider = motdProvider{ServerStatusProvider: provider, name: l.MOTD}
+	}
+	conf := minecraft.ListenConfig{
+		AuthenticationDisabled: authDisabled,
+		AcceptedProtocols:      protocols,
+		StatusProvider:         provider,
+		ResourcePacks:          packs.offered(l.reachable(c)),
+		TexturePacksRequired:   c.ResourcePacks.Required,
+		MaximumPlayers:         c.Connection.MaxConnections,
+		ErrorLog:               log.New(logging.Writer(logging.LevelWarn), "", 0),
+	}
+	observeRejected := rejected.Observe(protocols)
+	conf.PacketFunc = func(header packet.Header, payload []byte, src, dst net.Addr) {
+		proxy.ObserveLogin(header, payload, src, dst)
+		observeRejected(header, payload, src, dst)
+		if c.Connection.PassthroughNewest {
+			draco.ObservePassthrough(header, payload, src, dst)
+		}
+		proxy.ObserveConnection(header, payload, src, dst)
+		if v != nil {
+			v.Packet(header, payload, src, dst)
+		}
+	}
+	return conf, nil
+}
+
+// Listen starts listening for players, or guests if guest is true, on the RakNet address passed, using the
+// ListenConfig of the Proxy. The listener returned is closed when the Proxy is closed.
+func (p *Proxy) Listen(address string, guest bool) (*minecraft.Listener, error) {
+	return p.listen(Listener{Address: address, Guest: guest})
+}
+
+// listen starts listening for players on the address of the Listener passed.
+func (p *Proxy) listen(l Listener) (*minecraft.Listener, error) {
+	conf, err := p.listenConfig(l)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %v: %w", l.Address, err)
+	}
+	li, err := conf.Listen("raknet", l.Address)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %v: %w", l.Address, err)
+	}
+	if err := sockopt.Apply(li, p.startedConfig().Network.Listener); err != nil {
+		logging.Default().Warn("error applying listener socket options", "err", err)
+	}
+	return li, nil
+}
+
+// Serve accepts players from the listener passed until it is closed, handling them as guests if guest is true. The
+// listener should be created using the ListenConfig of the Proxy, and is closed when the Proxy is closed. Players
+// are handled with the config last applied, so that they join the backends of a config reloaded. ErrClosed is
+// returned once the Proxy is closed.
+func (p *Proxy) Serve(li *minecraft.Listener, guest bool) error {
+	return p.serve(li, Listener{Guest: guest})
+}
+
+// serve accepts players from the listener passed, which listens on the address of the Listener passed, until it is
+// closed, routing them to the backend of the Listener.
+func (p *Proxy) serve(li *minecraft.Listener, l Listener) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		_ = li.Close()
+		return ErrClosed
+	}
+	p.listeners = append(p.listeners, li)
+	p.mu.Unlock()
+	for {
+		conn, err := li.Accept()
+		if err != nil {
+			// Accept only fails once the listener is closed.
+			logging.Default().Info("listener closed", "address", li.Addr().String(), "err", err)
+			if p.isClosed() {
+				return ErrClosed
+			}
+			return err
+		}
+
+		go p.handleConn(conn.(*minecraft.Conn), li, p.runningConfig(), l)
+	}
+}
+
+// serveHTTP serves the handler passed on the TCP address passed until the Proxy is closed.
+func (p *Proxy) serveHTTP(name, address string, h http.Handler) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: h}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		_ = l.Close()
+		return ErrClosed
+	}
+	p.servers = append(p.servers, srv)
+	p.mu.Unlock()
+	go func() {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Default().Error("error serving "+name, "err", err)
+		}
+	}()
+	return nil
+}
+
+// Close deregisters the Proxy from service discovery, closes its listeners and APIs, disconnects all players, closes
+// the bans, the positions and the capture file and saves the warm caches in Cache.Directory, if set. The Discord
+// bridges and the mismatch report keep running until the process exits. The first error encountered is returned, after
+// everything was closed.
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	listeners, servers, stopDiscovery := p.listeners, p.servers, p.stopDiscovery
+	p.mu.Unlock()
+	if p.stopRemote != nil {
+		p.stopRemote()
+	}
+	if stopDiscovery != nil {
+		// The Proxy is deregistered first, so that no more players are steered to it while it closes.
+		stopDiscovery()
+	}
+
+	var err error
+	setErr := func(e error) {
+		if err == nil {
+			err = e
+		}
+	}
+	for _, li := range listeners {
+		_ = li.Close()
+	}
+	for _, srv := range servers {
+		setErr(srv.Close())
+	}
+	for _, s := range proxy.Sessions() {
+		s.Disconnect(lang.Translate(s.Client().ClientData().LanguageCode, "disconnect.draining"))
+	}
+	p.unregisterGDPR()
+	if p.bans != nil {
+		setErr(p.bans.Close())
+	}
+	if p.positions != nil {
+		setErr(p.positions.Close())
+	}
+	if p.capture != nil {
+		proxy.SetCapture(nil)
+		setErr(p.capture.Close())
+	}
+	if p.telemetry != nil {
+		setErr(p.telemetry.Close())
+	}
+	if dir := p.started.Cache.Directory; dir != "" {
+		if e := draco.SaveWarmCaches(dir); e != nil {
+			setErr(fmt.Errorf("save caches: %w", e))
+		} else {
+			logging.Default().Info("saved caches", "dir", dir)
+		}
+	}
+	return err
+}
+
+// isClosed checks if the Proxy was closed.
+func (p *Proxy) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// requiredAccounts returns the names of the XBOX Live accounts that the backends in the config passed sign players
+// in with, the default account being named "". Offline backends don't require any account.
+func requiredAccounts(c Config) []string {
+	var names []string
+	seen := map[string]struct{}{}
+	for _, b := range c.Backends {
+		if b.Offline {
+			continue
+		}
+		accounts := b.Accounts
+		if len(accounts) == 0 {
+			accounts = []string{""}
+		}
+		for _, name := range accounts {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// account returns the account with the name passed from the config passed, the default account being named "".
+// False is returned if no account with the name is configured.
+func account(c Config, name string) (draco.Account, bool) {
+	if name == "" {
+		return draco.Account{TokenFile: draco.DefaultTokenFile}, true
+	}
+	for _, a := range c.Accounts {
+		if a.Name == name {
+			if a.TokenFile == "" {
+				a.TokenFile = "./token-" + a.Name + ".json"
+			}
+			return a, true
+		}
+	}
+	return draco.Account{}, false
+}
+
+// initializeAccounts signs in to the XBOX Live accounts required by the backends of the config passed, using the
+// tokens cached if they exist.
+func initializeAccounts(c Config, l *log.Logger) error {
+	for _, name := range requiredAccounts(c) {
+		if name == "" {
+			if err := draco.InitializeToken(l); err != nil {
+				return err
+			}
+			continue
+		}
+		a, ok := account(c, name)
+		if !ok {
+			return fmt.Errorf("unknown account %q", name)
+		}
+		if err := draco.InitializeAccount(a, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// accountTurn is incremented for every player signed in to a backend with more than one account, to spread players
+// over the accounts in turn.
+var accountTurn uint32
+
+// backendTokenSource returns the token source of the account that the next player is signed in to the backend passed
+// with.
+func backendTokenSource(b proxy.Backend) oauth2.TokenSource {
+	switch len(b.Accounts) {
+	case 0:
+		return draco.TokenSrc
+	case 1:
+		return draco.AccountTokenSource(b.Accounts[0])
+	}
+	return draco.AccountTokenSource(b.Accounts[atomic.AddUint32(&accountTurn, 1)%uint32(len(b.Accounts))])
+}
+
+// handleConn handles the player that joined on the connection passed, accepted by the listener passed, using the
+// config passed: it is checked against the limits, bans and challenges of the proxy, and forwarded to its backend
+// once it passed them. l is the Listener that the listener listens on, which specifies the backend that the player
+// joins and if it is a guest.
+func (p *Proxy) handleConn(conn *minecraft.Conn, listener *minecraft.Listener, c Config, l Listener) {
+	accepted := time.Now()
+	client, backend, guest := proxy.NewClientConn(listener, conn), l.backend(c), l.Guest
+	// The client is offered the resource packs of the backends reachable when the listener was started.
+	packs := l.resourcePackPaths(p.startedConfig())
+	// The identity of the client is used rather than that of the connection, which holds the identity claimed
+	// rather than the one assigned in dev mode.
+	identity := client.IdentityData()
+	xuid := identity.XUID
+	if guest {
+		xuid = ""
+	}
+	migrated := false
+	if m, ok := cluster.Take(xuid); ok {
+		// The player was migrated from another node, so it is attached to the backend it was on there.
+		if b, ok := proxy.BackendByName(m.Backend); ok {
+			backend, migrated = b, true
+		}
+	}
+	lg := proxy.ClientLogger(client, identity.DisplayName, xuid).With("backend", backend.Name)
+	defer func() {
+		// A panic handling a single connection must not bring down the proxy.
+		if r := recover(); r != nil {
+			metrics.Add("session_panics", 1)
+			lg.Error("panic handling connection", "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+			_ = conn.Close()
+		}
+	}()
+	if err := proxy.Admit(conn.RemoteAddr()); err != nil {
+		// Bots flooding the proxy are turned away before anything else is done for them.
+		key := "disconnect.rate_limited"
+		if errors.Is(err, proxy.ErrLoginTimeout) {
+			key = "disconnect.login_timeout"
+		}
+		lg.Debug("connection turned away", "err", err)
+		_ = client.Disconnect(lang.Translate(conn.ClientData().LanguageCode, key))
+		return
+	}
+	if _, ok := cluster.Draining(); ok {
+		_ = client.Disconnect(lang.Translate(conn.ClientData().LanguageCode, "disconnect.draining"))
+		return
+	}
+	if v := p.verifier; v != nil && !guest {
+		if err := v.Check(conn.RemoteAddr()); err != nil {
+			_ = client.Disconnect(lang.Translate(conn.ClientData().LanguageCode, "disconnect.identity"))
+			return
+		}
+	}
+	if p.bans != nil && !guest {
+		if e, ok := p.bans.Banned(xuid, identity.DisplayName); ok {
+			_ = client.Disconnect(ban.Message(func(key string, args ...any) string {
+				return lang.Translate(conn.ClientData().LanguageCode, key, args...)
+			}, e))
+			return
+		}
+	}
+	if p.bans != nil && !p.bans.Allowed(xuid, identity.DisplayName) {
+		_ = client.Disconnect(lang.Translate(conn.ClientData().LanguageCode, "disconnect.not_whitelisted"))
+		return
+	}
+	join := proxy.Join{Identity: identity, Client: conn.ClientData(), Addr: conn.RemoteAddr(), Time: accepted}
+	join.Identity.XUID = xuid
+	d := proxy.EvaluateRules(join)
+	if d.Deny {
+		message := d.Message
+		if message == "" {
+			message = lang.Translate(conn.ClientData().LanguageCode, "disconnect.denied")
+		}
+		lg.Info("player denied by rule", "rule", d.Matched[len(d.Matched)-1]+1)
+		_ = client.Disconnect(message)
+		return
+	}
+	if b, ok := proxy.BackendByName(d.Backend); ok && !migrated {
+		if offersPacks(packs, b) {
+			backend = b
+		} else {
+			// The rule was added by a reload, after the listener started offering resource packs.
+			lg.Warn("rule routes player to backend with resource packs not offered on listener", "rule.backend", b.Name)
+		}
+	}
+	if d.RoleSet && d.Role == proxy.RoleGuest && !guest {
+		// Players assigned the guest role join like guests, so that their identity isn't used anywhere.
+		guest, xuid = true, ""
+	}
+	lg = proxy.ClientLogger(client, identity.DisplayName, xuid).With("backend", backend.Name)
+	challenged := false
+	if data, ok := proxy.Suspicious(conn.RemoteAddr()); ok {
+		// The client is challenged before any backend is dialed for it, so that bots never reach a backend.
+		if err := conn.StartGame(data); err != nil {
+			lg.Error("error starting challenge", "err", err)
+			_ = conn.Close()
+			return
+		}
+		if err := proxy.SetCustomBlocks(client, data); err != nil {
+			lg.Error("error applying custom blocks of challenge", "err", err)
+		}
+		if err := proxy.RunChallenge(client, conn.RemoteAddr()); err != nil {
+			lg.Info("player failed challenge")
+			return
+		}
+		challenged = true
+	}
+	if err := proxy.ClaimIdentity(xuid); err != nil {
+		_ = client.Disconnect(lang.Translate(conn.ClientData().LanguageCode, "disconnect.duplicate_login"))
+		return
+	}
+	if !migrated {
+		// Migrated players are still connected to the node they were migrated from until its transfer completes.
+		elsewhere := lang.Translate(conn.ClientData().LanguageCode, "disconnect.logged_in_elsewhere")
+		if err := cluster.ClaimIdentity(xuid, c.Connection.DuplicateLogins, elsewhere); err != nil {
+			_ = client.Disconnect(lang.Translate(conn.ClientData().LanguageCode, "disconnect.duplicate_login"))
+			proxy.ReleaseIdentity(xuid)
+			return
+		}
+	}
+	if err := proxy.Reserve(backend); err != nil {
+		key := "disconnect.server_full"
+		if errors.Is(err, proxy.ErrBackendFull) {
+			key = "disconnect.backend_full"
+		}
+		_ = client.Disconnect(lang.Translate(conn.ClientData().LanguageCode, key))
+		proxy.ReleaseIdentity(xuid)
+		return
+	}
+	var name string
+	if guest {
+		name = proxy.GuestName(c.Guest.Prefix, identity.DisplayName)
+	}
+	serverConn, err := p.dialBackend(c, backend, identity, conn.ClientData(), conn.RemoteAddr(), name, conn.ClientCacheEnabled())
+	if err != nil {
+		lg.Error("error connecting to backend", "err", err)
+		_ = client.Disconnect(lang.Translate(conn.ClientData().LanguageCode, "disconnect.connection_lost"))
+		proxy.Release(backend)
+		proxy.ReleaseIdentity(xuid)
+		return
+	}
+
+	var g sync.WaitGroup
+	g.Add(2)
+	data := serverConn.GameData()
+	if c.Connection.StripEducationFeatures {
+		data = proxy.StripEducationGameData(data)
+	}
+	data = proxy.WorldTimeGameData(data, backend)
+	role := proxy.RoleMember
+	if guest {
+		role = proxy.RoleGuest
+	}
+	data = proxy.GameModeGameData(data, backend, role)
+	// The client merges the custom blocks of the backend into its block palette once started, so its packets must be
+	// translated with them from then on. Challenged clients were started with the custom blocks of the challenge
+	// instead, which may not be those of the backend.
+	if challenged {
+		err = proxy.SetServerBlocks(client, data.CustomBlocks)
+	} else {
+		err = proxy.SetCustomBlocks(client, data)
+	}
+	if err != nil {
+		lg.Error("error applying custom blocks of backend", "err", err)
+	}
+	var startErr, spawnErr error
+	go func() {
+		defer g.Done()
+		if !challenged {
+			startErr = recoverErr(func() error { return conn.StartGame(data) })
+		}
+	}()
+	go func() {
+		defer g.Done()
+		spawnErr = recoverErr(serverConn.DoSpawn)
+	}()
+	g.Wait()
+	if startErr != nil || spawnErr != nil {
+		lg.Error("error spawning on backend", "start_game_err", startErr, "spawn_err", spawnErr)
+		_ = serverConn.Close()
+		_ = client.Disconnect(lang.Translate(conn.ClientData().LanguageCode, "disconnect.connection_lost"))
+		proxy.Release(backend)
+		proxy.ReleaseIdentity(xuid)
+		return
+	}
+	// StartGame only returns once the client sent SetLocalPlayerAsInitialised, which completes the join.
+	slo.Observe(time.Since(accepted))
+
+	proxy.ObserveGameData(data)
+
+	var s *proxy.Session
+	if guest {
+		s = proxy.NewGuestSession(client, serverConn, backend, name)
+	} else {
+		s = proxy.NewSession(client, serverConn, backend)
+	}
+	if challenged {
+		s.ChangeWorld(data)
+	}
+	if d.ViewDistance > 0 {
+		s.SetMaxChunkRadius(d.ViewDistance)
+	}
+	s.SetResourcePacks(packs)
+	s.Start()
+}
+
+// dialBackend dials the backend passed for the player with the identity and client data passed, connecting to the
+// proxy from the address passed. guestName is the name of the player if it is a guest, or empty otherwise.
+// clientCache specifies if the client of the player has the client blob cache enabled. The connection returned is
+// not yet spawned.
+//
+// Backends are dialed when a player joins rather than being taken from a pool of connections dialed in advance: the
+// login request sent while dialing holds the identity and client data of the player, backends have no way of
+// changing the player of a connection once it logged in, and the dialer of gophertunnel can't be handed a RakNet
+// connection that was already established.
+func (p *Proxy) dialBackend(c Config, backend proxy.Backend, identityData login.IdentityData, clientData login.ClientData, addr net.Addr, guestName string, clientCache bool) (*minecraft.Conn, error) {
+	d := minecraft.Dialer{
+		ErrorLog:    log.New(logging.Writer(logging.LevelWarn), "", 0),
+		TokenSource: backendTokenSource(backend),
+		ClientData:  clientData,
+		// The blobs that the backend sends are translated by the protocol of the client, and the hashes of blobs
+		// don't depend on the player, so the cache can be passed through if the client has it enabled too.
+		EnableClientCache: backend.ClientCache && clientCache,
+	}
+	if backend.Offline {
+		// The backend doesn't authenticate players, so the identity of the player is passed on as it is.
+		d.TokenSource = nil
+		d.IdentityData = identityData
+		d.KeepXBLIdentityData = true
+	}
+	if guestName != "" {
+		// Guests are not authenticated, so they are forwarded to the backend without XBOX Live authentication. The
+		// backend must have authentication disabled to accept them.
+		d.TokenSource = nil
+		d.IdentityData = login.IdentityData{DisplayName: guestName}
+		d.ClientData.ThirdPartyName = guestName
+	}
+	if c.Forwarding.Secret != "" {
+		role := proxy.RoleMember
+		if guestName != "" {
+			role = proxy.RoleGuest
+		}
+		d.Protocol = forward.Protocol{Secret: []byte(c.Forwarding.Secret), Metadata: forward.Metadata{
+			Address: addr.String(),
+			Node:    c.Forwarding.Node,
+			Joined:  time.Now(),
+			Role:    role.String(),
+		}}
+	}
+	// Dials are limited per backend, so that players reconnecting all at once don't flood it with connections.
+	release, err := proxy.AcquireDial(backend)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	address := backend.Address
+	var relay *proxyproto.Relay
+	if backend.ProxyProtocol {
+		// The connection is dialed through a relay that prefixes the datagrams sent to the backend with the address
+		// of the player. The relay closes itself once the connection is closed.
+		if relay, err = proxyproto.Listen(backend.Address, addr); err != nil {
+			return nil, fmt.Errorf("relay PROXY protocol: %w", err)
+		}
+		address = relay.Addr()
+	}
+	serverConn, err := d.Dial("raknet", address)
+	if err != nil {
+		if relay != nil {
+			_ = relay.Close()
+		}
+		proxy.ObserveBackend(backend, 0, err)
+		return nil, err
+	}
+	// The latency of the connection is half of its round trip time.
+	proxy.ObserveBackend(backend, serverConn.Latency()*2, nil)
+	if err := sockopt.Apply(serverConn, c.Network.Dialer); err != nil {
+		logging.Default().Warn("error applying dialer socket options", "err", err)
+	}
+	p.storeBackendPacks(backend, serverConn)
+	return serverConn, nil
+}
+
+// transferDialer returns the proxy.Dialer used to dial the backends that players transfer to.
+func (p *Proxy) transferDialer(c Config) proxy.Dialer {
+	return func(s *proxy.Session, b proxy.Backend) (proxy.Conn, error) {
+		var addr net.Addr = &net.UDPAddr{}
+		if a, ok := s.Client().(interface{ RemoteAddr() net.Addr }); ok {
+			addr = a.RemoteAddr()
+		}
+		var guestName string
+		if s.Role() == proxy.RoleGuest {
+			guestName = s.Name()
+		}
+		clientCache := false
+		if cc, ok := s.Client().(interface{ ClientCacheEnabled() bool }); ok {
+			clientCache = cc.ClientCacheEnabled()
+		}
+		serverConn, err := p.dialBackend(c, b, s.Client().IdentityData(), s.Client().ClientData(), addr, guestName, clientCache)
+		if err != nil {
+			return nil, err
+		}
+		if err := recoverErr(serverConn.DoSpawn); err != nil {
+			_ = serverConn.Close()
+			return nil, fmt.Errorf("spawn: %w", err)
+		}
+		return serverConn, nil
+	}
+}
+
+// statusProvider returns the status.Chain used to show the status of the proxy in the server list, trying the
+// providers in the config in order.
+func statusProvider(c Config) *status.Chain {
+	return status.NewChain(parseDuration(c.Status.Interval), statusProviders(c)...)
+}
+
+// statusProviders returns the providers of the status of the proxy in the config passed, in order. Unknown
+// providers, which CheckConfig reports, are left out.
+func statusProviders(c Config) []status.Provider {
+	timeout := parseDuration(c.Status.Timeout)
+	addresses := make([]string, 0, len(c.Backends))
+	for _, b := range c.Backends {
+		addresses = append(addresses, b.Address)
+	}
+	providers := make([]status.Provider, 0, len(c.Status.Providers))
+	for _, name := range c.Status.Providers {
+		switch name {
+		case "static":
+			providers = append(providers, status.Static{ServerName: c.Status.ServerName})
+		case "foreign":
+			providers = append(providers, status.Foreign{Address: c.Backends[0].Address, Timeout: timeout})
+		case "aggregate":
+			providers = append(providers, status.Aggregate{Addresses: addresses, Timeout: timeout})
+		case "script":
+			providers = append(providers, status.Script{Command: c.Status.Script, Timeout: timeout})
+		}
+	}
+	if ttl := parseDuration(c.Status.CacheTTL); ttl > 0 || c.Status.Override != (status.Override{}) {
+		for i, p := range providers {
+			providers[i] = status.NewCached(p, ttl, c.Status.Override)
+		}
+	}
+	return providers
+}
+
+// parseDuration parses a duration from the config, such as "5s". An empty string results in a duration of 0. The
+// durations of a config are checked by CheckConfig before it is applied, so invalid durations never reach it.
+func parseDuration(s string) time.Duration {
+	d, _ := time.ParseDuration(s)
+	return d
+}
+
+// selfTest runs a self-test of the translation tables, returning an error if they are inconsistent. Blocks, items
+// and biomes that can't be translated between versions are logged.
+func selfTest() error {
+	r := draco.SelfTest()
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if len(r.UnmappedBlocks) > 0 || len(r.UnmappedItems) > 0 || len(r.UnmappedBiomes) > 0 {
+		log.Printf("translation tables: %v block state(s), %v item(s) and %v biome(s) can't be translated between versions", len(r.UnmappedBlocks), len(r.UnmappedItems), len(r.UnmappedBiomes))
+	}
+	if draco.IdenticalBlockPalettes() {
+		log.Printf("translation tables: block palettes and biomes are identical, chunks are forwarded without translation")
+	}
+	return nil
+}
+
+// recoverErr calls f and returns its error. If f panics, the panic is recovered and returned as an error, together
+// with the stack trace of the panic.
+func recoverErr(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			metrics.Add("session_panics", 1)
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return f()
+}
+
+// compileResourcePacks compiles the resource packs found at the paths passed. Paths may point to either a directory
+// or a .zip/.mcpack archive.
+func compileResourcePacks(paths []string) ([]*resource.Pack, error) {
+	packs := make([]*resource.Pack, 0, len(paths))
+	for _, path := range paths {
+		pack, err := resource.Compile(path)
+		if err != nil {
+			return nil, fmt.Errorf("load resource pack %v: %w", path, err)
+		}
+		packs = append(packs, pack)
+	}
+	return packs, nil
+}
+
+// applyConfig applies the settings of the config passed that may be changed while the proxy runs, which are
+// applied again by Reload. The config must have been checked using CheckConfig: an error is returned if it is
+// invalid nonetheless, in which case only some of its settings may have been applied.
+func (p *Proxy) applyConfig(c Config) error {
+	decodePolicy, err := policy.Parse(c.Network.DecodePolicy)
+	if err != nil {
+		return fmt.Errorf("parse decode policy: %w", err)
+	}
+	policy.Set(decodePolicy)
+	policy.SetFallbackBlock(c.Network.FallbackBlock)
+	o, err := joinObjective(c)
+	if err != nil {
+		return fmt.Errorf("parse join SLO: %w", err)
+	}
+	slo.Set(o)
+	if err := blockCommands(c); err != nil {
+		return err
+	}
+	if err := setPermissions(c); err != nil {
+		return err
+	}
+	proxy.SetMaxPlayers(c.Connection.MaxPlayers)
+	proxy.SetConnectionLimits(proxy.ConnectionLimits{
+		PerIP:        c.Connection.LoginsPerIP,
+		LoginTimeout: parseDuration(c.Connection.LoginTimeout),
+	})
+	proxy.SetBackends(c.Backends)
+	if p.bans != nil {
+		p.bans.SetWhitelist(c.Bans.Whitelist)
+	}
+	proxy.SetChallenge(proxy.Challenge{
+		Always:   c.Challenge.Always,
+		JoinRate: c.Challenge.JoinRate,
+		Timeout:  parseDuration(c.Challenge.Timeout),
+	})
+	cluster.SetNodes(c.Forwarding.Node, c.Admin.Secret, c.Cluster.Nodes)
+	proxy.SetDialer(p.transferDialer(c))
+	proxy.SetFallback(proxy.Fallback{
+		Backend: c.Fallback.Backend,
+		Limbo:   c.Fallback.Limbo,
+		Timeout: parseDuration(c.Fallback.Timeout),
+		OnKick:  c.Fallback.OnKick,
+	})
+	proxy.SetLimboWorld(proxy.LimboWorld{
+		Message:         c.Limbo.Message,
+		BossBar:         c.Limbo.BossBar,
+		DuringTransfers: c.Limbo.DuringTransfers,
+	})
+	proxy.SetLatencyReports(c.Log.LatencyReports)
+	proxy.SetDuplicateLoginPolicy(c.Connection.DuplicateLogins)
+	proxy.SetBlockUpdateCoalescing(c.Network.CoalesceBlockUpdates)
+	proxy.SetPotatoMode(proxy.PotatoMode{ChunkRadius: c.Network.Potato.ChunkRadius, KeepOneIn: c.Network.Potato.KeepOneIn})
+	proxy.SetAdaptiveView(proxy.AdaptiveView{
+		Enabled:     c.Network.AdaptiveView.Enabled,
+		MinRadius:   c.Network.AdaptiveView.MinRadius,
+		MaxLoss:     c.Network.AdaptiveView.MaxLoss,
+		MaxInFlight: c.Network.AdaptiveView.MaxInFlight,
+	})
+	proxy.SetBackendProbe(proxy.BackendProbe{
+		Interval: parseDuration(c.Network.BackendProbe.Interval),
+		Timeout:  parseDuration(c.Network.BackendProbe.Timeout),
+	})
+	if err := proxy.SetPacketPriorities(packetPriorities(c)); err != nil {
+		return fmt.Errorf("set packet priorities: %w", err)
+	}
+	proxy.SetDialLimit(proxy.DialLimit{
+		MaxConcurrent: c.Network.DialLimit.MaxConcurrent,
+		PerSecond:     c.Network.DialLimit.PerSecond,
+		Burst:         c.Network.DialLimit.Burst,
+		MaxWait:       parseDuration(c.Network.DialLimit.MaxWait),
+	})
+	proxy.SetInventoryResync(proxy.InventoryResync{
+		Failures: c.Network.InventoryResync.Failures,
+		Window:   parseDuration(c.Network.InventoryResync.Window),
+	})
+	proxy.SetPacketRateLimit(c.Network.PacketRateLimit)
+	if err := setCooldowns(c); err != nil {
+		return err
+	}
+	proxy.SetChunkWorkers(c.Network.ChunkWorkers)
+	proxy.SetMemoryWatch(proxy.MemoryWatch{
+		Limit:    c.Network.MemoryWatch.SessionLimitKB << 10,
+		Interval: parseDuration(c.Network.MemoryWatch.Interval),
+	})
+	if err := proxy.SetFilters(c.Filters); err != nil {
+		return fmt.Errorf("set filters: %w", err)
+	}
+	if err := proxy.SetRules(c.Rules); err != nil {
+		return fmt.Errorf("set rules: %w", err)
+	}
+	if err := proxy.SetMiddleware(c.Network.Middleware.Order, c.Network.Middleware.Disabled); err != nil {
+		return fmt.Errorf("set middleware: %w", err)
+	}
+	proxy.SetChunkPacing(proxy.ChunkPacing{
+		InitialRate: c.Network.ChunkRate.InitialKB << 10,
+		MinRate:     c.Network.ChunkRate.MinKB << 10,
+		MaxRate:     c.Network.ChunkRate.MaxKB << 10,
+	})
+	if err := serverSettings(c); err != nil {
+		return err
+	}
+	proxy.SetSidebar(proxy.SidebarConfig{Title: c.Sidebar.Title, Lines: c.Sidebar.Lines, Hidden: c.Sidebar.Hidden})
+	if err := setAtmospheres(c); err != nil {
+		return err
+	}
+	return setGameModes(c)
+}
+
+// packetPriorities returns the priorities of the packet classes in the config passed, or nil if packets should be
+// written in order.
+func packetPriorities(c Config) proxy.PacketPriorities {
+	if !c.Network.PacketPriorities.Enabled {
+		return nil
+	}
+	p := proxy.PacketPriorities{}
+	for class, priority := range proxy.DefaultPacketPriorities {
+		p[class] = priority
+	}
+	for class, priority := range c.Network.PacketPriorities.Classes {
+		p[proxy.PacketClass(class)] = priority
+	}
+	return p
+}
+
+// serverSettings sets the handling of server settings requests in the config passed.
+func serverSettings(c Config) error {
+	conf := proxy.ServerSettings{Title: c.ServerSettings.Title, Text: c.ServerSettings.Text}
+	if c.ServerSettings.Policy != "" {
+		policy, ok := proxy.ParseSettingsPolicy(c.ServerSettings.Policy)
+		if !ok {
+			return fmt.Errorf("set server settings: unknown policy %v", c.ServerSettings.Policy)
+		}
+		conf.Policy = policy
+	}
+	proxy.SetServerSettings(conf)
+	return nil
+}
+
+// setAtmospheres sets the atmospheres in the config passed.
+func setAtmospheres(c Config) error {
+	all := make([]proxy.Atmosphere, 0, len(c.Atmospheres))
+	for _, a := range c.Atmospheres {
+		roles, err := parseRoles(a.Roles)
+		if err != nil {
+			return fmt.Errorf("set atmospheres: %w", err)
+		}
+		all = append(all, proxy.Atmosphere{Backend: a.Backend, Roles: roles, Fog: a.Fog, ClearWeather: a.ClearWeather})
+	}
+	proxy.SetAtmospheres(all)
+	return nil
+}
+
+// setGameModes sets the game modes shown to players in the config passed.
+func setGameModes(c Config) error {
+	all := make([]proxy.GameMode, 0, len(c.GameModes))
+	for _, m := range c.GameModes {
+		roles, err := parseRoles(m.Roles)
+		if err != nil {
+			return fmt.Errorf("set game modes: %w", err)
+		}
+		all = append(all, proxy.GameMode{Backend: m.Backend, Roles: roles, Mode: m.Mode})
+	}
+	if err := proxy.SetGameModes(all); err != nil {
+		return fmt.Errorf("set game modes: %w", err)
+	}
+	return nil
+}
+
+// parseRoles parses the names of the roles passed, returning an error for the first name that isn't a role.
+func parseRoles(names []string) ([]proxy.Role, error) {
+	roles := make([]proxy.Role, 0, len(names))
+	for _, name := range names {
+		r, ok := proxy.ParseRole(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown role %v", name)
+		}
+		roles = append(roles, r)
+	}
+	return roles, nil
+}
+
+// setPermissions sets the permissions shown to clients of players with the roles in the config passed.
+func setPermissions(c Config) error {
+	for _, p := range c.Permissions {
+		r, ok := proxy.ParseRole(p.Role)
+		if !ok {
+			return fmt.Errorf("set permissions: unknown role %v", p.Role)
+		}
+		if err := proxy.SetPermissions(r, proxy.Permissions{PermissionLevel: p.PermissionLevel, CommandPermissionLevel: p.CommandPermissionLevel}); err != nil {
+			return fmt.Errorf("set permissions of role %v: %w", p.Role, err)
+		}
+	}
+	return nil
+}
+
+// setCooldowns sets the cooldowns of the chat messages and commands of players in the config passed.
+func setCooldowns(c Config) error {
+	bypass, err := parseRoles(c.Cooldowns.Bypass)
+	if err != nil {
+		return fmt.Errorf("set cooldowns: %w", err)
+	}
+	proxy.SetCooldowns(proxy.Cooldowns{
+		Chat:     parseDuration(c.Cooldowns.Chat),
+		Commands: parseDuration(c.Cooldowns.Commands),
+		Bypass:   bypass,
+	})
+	return nil
+}
+
+// blockCommands blocks the commands in the config passed at the proxy.
+func blockCommands(c Config) error {
+	for _, b := range c.BlockedCommands {
+		roles, err := parseRoles(b.Roles)
+		if err != nil {
+			return fmt.Errorf("block command %v: %w", b.Command, err)
+		}
+		proxy.BlockCommand(b.Command, b.Message, roles...)
+	}
+	return nil
+}
+
+// linker returns the HTTP API of a link.Linker, which websites may use to link the accounts of players.
+func linker(c Config) (http.Handler, error) {
+	ttl := 5 * time.Minute
+	if c.Link.CodeTTL != "" {
+		d, err := time.ParseDuration(c.Link.CodeTTL)
+		if err != nil {
+			return nil, fmt.Errorf("parse code TTL: %w", err)
+		}
+		ttl = d
+	}
+	if c.Link.Secret == "" {
+		return nil, errors.New("a secret must be set")
+	}
+	return link.New(ttl, c.Link.Secret), nil
+}
+
+// banSource returns the ban.Source configured in Bans.Sync of the config passed.
+func banSource(c Config) (ban.Source, error) {
+	src := ban.Source{URL: c.Bans.Sync.URL, Interval: time.Minute}
+	if c.Bans.Sync.Interval != "" {
+		d, err := time.ParseDuration(c.Bans.Sync.Interval)
+		if err != nil {
+			return ban.Source{}, fmt.Errorf("parse interval: %w", err)
+		}
+		src.Interval = d
+	}
+	if c.Bans.Sync.PublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(c.Bans.Sync.PublicKey)
+		if err != nil {
+			return ban.Source{}, fmt.Errorf("decode public key: %w", err)
+		}
+		src.PublicKey = key
+	}
+	return src, nil
+}
+
+// joinObjective returns the slo.Objective configured in JoinSLO of the config passed. The Objective returned has no
+// Threshold if join times are not tracked.
+func joinObjective(c Config) (slo.Objective, error) {
+	if c.JoinSLO.Threshold == "" {
+		return slo.Objective{}, nil
+	}
+	o := slo.Objective{Target: c.JoinSLO.Target, Window: time.Minute * 10, MinJoins: c.JoinSLO.MinJoins, WebhookURL: c.JoinSLO.WebhookURL}
+	d, err := time.ParseDuration(c.JoinSLO.Threshold)
+	if err != nil {
+		return slo.Objective{}, fmt.Errorf("parse threshold: %w", err)
+	}
+	if d <= 0 {
+		return slo.Objective{}, errors.New("threshold must be positive")
+	}
+	o.Threshold = d
+	if c.JoinSLO.Window != "" {
+		if o.Window, err = time.ParseDuration(c.JoinSLO.Window); err != nil {
+			return slo.Objective{}, fmt.Errorf("parse window: %w", err)
+		}
+	}
+	if o.Target <= 0 || o.Target > 1 {
+		return slo.Objective{}, errors.New("target must be above 0 and at most 1")
+	}
+	return o, nil
+}
+
+// openBans opens the ban.Store in the config passed and syncs it with Bans.Sync if set.
+func (p *Proxy) openBans(c Config) error {
+	s, err := ban.Open(c.Bans.File)
+	if err != nil {
+		return fmt.Errorf("open bans: %w", err)
+	}
+	p.bans = s
+	if c.Bans.Sync.URL != "" {
+		src, err := banSource(c)
+		if err != nil {
+			return fmt.Errorf("sync bans: %w", err)
+		}
+		if err := s.Sync(src); err != nil {
+			return fmt.Errorf("sync bans: %w", err)
+		}
+	}
+	return nil
+}
+
+// banAPI returns the HTTP API of the bans of the Proxy.
+func (p *Proxy) banAPI(c Config) (http.Handler, error) {
+	if c.Bans.Secret == "" {
+		return nil, errors.New("a secret must be set")
+	}
+	return ban.NewAPI(p.bans, c.Bans.Secret), nil
+}
+
+// adminAPI returns the admin HTTP API, which operators may use to debug and manage sessions, such as by logging their
+// packets, and to reload the config from the source set using SetConfigSource.
+func (p *Proxy) adminAPI(c Config) (http.Handler, error) {
+	if c.Admin.Secret == "" {
+		return nil, errors.New("a secret must be set")
+	}
+	a := admin.NewAPI(c.Admin.Secret, p.reloadFromSource)
+	a.Handle("/mismatches", mismatch.Handler())
+	a.Handle("/slo", slo.Handler())
+	a.Handle("/protocols/rejected", rejected.Handler())
+	a.Handle("/gdpr", gdpr.Handler())
+	if p.bans != nil {
+		// The bans and the whitelist may also be managed through the admin API, using the secret of the admin API.
+		b := ban.NewAPI(p.bans, c.Admin.Secret)
+		a.Handle("/bans", b)
+		a.Handle("/whitelist", b)
+	}
+	if p.positions != nil {
+		a.Handle("/positions", positions.NewAPI(p.positions, c.Admin.Secret))
+	}
+	return a, nil
+}
+
+// startDiscordBridges starts a discord.Bridge for every bridge in the config passed.
+func startDiscordBridges(c Config) error {
+	for _, d := range c.Discord {
+		conf := discord.Config{Backend: d.Backend, WebhookURL: d.WebhookURL, BotToken: d.BotToken, ChannelID: d.ChannelID}
+		if d.PollInterval != "" {
+			interval, err := time.ParseDuration(d.PollInterval)
+			if err != nil {
+				return fmt.Errorf("parse discord poll interval: %w", err)
+			}
+			conf.PollInterval = interval
+		}
+		b, err := discord.New(conf)
+		if err != nil {
+			return fmt.Errorf("start discord bridge: %w", err)
+		}
+		b.Start()
+	}
+	return nil
+}
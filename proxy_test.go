@@ -0,0 +1,235 @@
+package draco
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/ban"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// testConfig returns a config listening on a random local port with an offline backend, so that no XBOX Live
+// account is required.
+func testConfig(t *testing.T) Config {
+	c, err := DecodeConfig([]byte(`
+[Connection]
+LocalAddress = "127.0.0.1:0"
+Offline = true
+
+[[Backends]]
+Name = "lobby"
+Address = "127.0.0.1:19134"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestProxyListenAndServe(t *testing.T) {
+	// The Proxy is opened without New, which would fail on the self-test of the translation tables, as they still
+	// hold entries that can't be translated back.
+	c := testConfig(t)
+	p := &Proxy{started: c, running: c}
+	if err := p.open(c); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- p.ListenAndServe(ctx)
+	}()
+
+	invalid := p.Config()
+	invalid.Fallback.Backend = "unknown"
+	if err := p.Reload(invalid); err == nil {
+		t.Error("expected an invalid config not to be reloaded")
+	}
+	reloaded := p.Config()
+	reloaded.Connection.MaxPlayers = 10
+	if err := p.Reload(reloaded); err != nil {
+		t.Fatal(err)
+	}
+	if p.Config().Connection.MaxPlayers != 10 {
+		t.Error("config reloaded was not applied")
+	}
+
+	cancel()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected ListenAndServe to return the error of the context, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenAndServe didn't return after the context was done")
+	}
+	if err := p.Reload(reloaded); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected reloading a closed proxy to fail with ErrClosed, got %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("expected closing a closed proxy to do nothing, got %v", err)
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	c := testConfig(t)
+	c.Status.Timeout = "soon"
+	if _, err := New(c); err == nil {
+		t.Error("expected New to fail for an invalid config")
+	}
+}
+
+func TestCheckFallbackBlock(t *testing.T) {
+	if err := checkFallbackBlock("minecraft:info_update"); err != nil {
+		t.Errorf("unexpected error checking a block without properties: %v", err)
+	}
+	for _, name := range []string{"minecraft:stone", "minecraft:no_such_block"} {
+		if err := checkFallbackBlock(name); err == nil {
+			t.Errorf("expected error checking fallback block %v", name)
+		}
+	}
+}
+
+func TestDevModeConfig(t *testing.T) {
+	c, err := DecodeConfig([]byte(`
+[Connection]
+DevMode = true
+
+[[Backends]]
+Name = "lobby"
+Address = "127.0.0.1:19134"
+Accounts = ["alt"]
+
+[[Accounts]]
+Name = "alt"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Connection.Offline || !c.Connection.AuthenticationDisabled || !c.Backends[0].Offline {
+		t.Error("expected dev mode to disable XBOX Live authentication on both sides")
+	}
+	if names := requiredAccounts(c); len(names) != 0 {
+		t.Errorf("expected no accounts to be required in dev mode, got %v", names)
+	}
+	if err := checkErr(CheckConfig(c)); err != nil {
+		t.Fatalf("unexpected error checking config: %v", err)
+	}
+	c.Identity.Verify = true
+	var failed []Check
+	for _, ch := range CheckConfig(c) {
+		if ch.Err != nil {
+			failed = append(failed, ch)
+		}
+	}
+	if len(failed) != 1 || failed[0].Name != "config: dev mode" {
+		t.Errorf("expected a single error verifying identities in dev mode, got %v", failed)
+	}
+}
+
+// chatConn is a proxy.ClientConn of a player that sends the chat messages queued to it and discards the packets
+// written to it.
+type chatConn struct {
+	xuid, name string
+	messages   chan string
+	closed     chan struct{}
+}
+
+func (c *chatConn) ReadPacket() (packet.Packet, error) {
+	select {
+	case m := <-c.messages:
+		return &packet.Text{TextType: packet.TextTypeChat, SourceName: c.name, Message: m, XUID: c.xuid}, nil
+	case <-c.closed:
+		return nil, net.ErrClosed
+	}
+}
+func (c *chatConn) WritePacket(packet.Packet) error { return nil }
+func (c *chatConn) IdentityData() login.IdentityData {
+	return login.IdentityData{XUID: c.xuid, DisplayName: c.name}
+}
+func (c *chatConn) ClientData() login.ClientData { return login.ClientData{LanguageCode: "en_US"} }
+func (c *chatConn) Latency() time.Duration       { return 0 }
+func (c *chatConn) Disconnect(string) error      { return c.Close() }
+func (c *chatConn) Close() error {
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	return nil
+}
+
+func TestMutedChatNotBridged(t *testing.T) {
+	posted := make(chan string, 16)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Content string `json:"content"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		posted <- body.Content
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+
+	c, err := DecodeConfig([]byte(fmt.Sprintf(`
+[Connection]
+LocalAddress = "127.0.0.1:0"
+Offline = true
+
+[[Backends]]
+Name = "lobby"
+Address = "127.0.0.1:19134"
+
+[Bans]
+File = %q
+
+[[Discord]]
+WebhookURL = %q
+`, filepath.Join(t.TempDir(), "bans.json"), webhook.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Proxy{started: c, running: c}
+	if err := p.open(c); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if _, err := p.bans.Add(ban.KindMute, "1", "Muted", "spam", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	chat := func(xuid, name, message string) {
+		conn := &chatConn{xuid: xuid, name: name, messages: make(chan string, 1), closed: make(chan struct{})}
+		server := &chatConn{messages: make(chan string), closed: make(chan struct{})}
+		s := proxy.NewSession(conn, server, proxy.Backend{Name: "lobby"})
+		s.Start()
+		conn.messages <- message
+	}
+	// The chat of the muted player is sent first, so that it would be posted before that of the other player.
+	chat("1", "Muted", "muted message")
+	chat("2", "Talker", "bridged message")
+	for {
+		select {
+		case content := <-posted:
+			if strings.Contains(content, "muted message") {
+				t.Fatal("chat of a muted player was bridged to Discord")
+			}
+			if strings.Contains(content, "bridged message") {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("chat of the player that is not muted was not bridged")
+		}
+	}
+}
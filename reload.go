@@ -0,0 +1,180 @@
+package draco
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/cqdetdev/draco/draco"
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/proxy"
+	"github.com/cqdetdev/draco/draco/remoteconfig"
+)
+
+// startedConfig returns the config that the Proxy was created with.
+func (p *Proxy) startedConfig() Config {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.started
+}
+
+// runningConfig returns the config last applied.
+func (p *Proxy) runningConfig() Config {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.running
+}
+
+// Config returns the config last applied to the Proxy, which is the config it was created with until it is reloaded.
+// The settings that only apply once the proxy is restarted hold the values that the Proxy was created with.
+func (p *Proxy) Config() Config {
+	return p.runningConfig()
+}
+
+// restartSettings holds the settings of the config that only apply once the proxy is restarted, such as the
+// addresses listened on and the settings of the APIs, by their name in config.toml.
+var restartSettings = []struct {
+	name  string
+	field func(c *Config) any
+}{
+	{"Connection.LocalAddress", func(c *Config) any { return &c.Connection.LocalAddress }},
+	{"Connection.MaxConnections", func(c *Config) any { return &c.Connection.MaxConnections }},
+	{"Connection.ResourcePacks", func(c *Config) any { return &c.Connection.ResourcePacks }},
+	{"ResourcePacks", func(c *Config) any { return &c.ResourcePacks }},
+	{"Connection.StripEducationFeatures", func(c *Config) any { return &c.Connection.StripEducationFeatures }},
+	{"Connection.AuthenticationDisabled", func(c *Config) any { return &c.Connection.AuthenticationDisabled }},
+	{"Connection.DevMode", func(c *Config) any { return &c.Connection.DevMode }},
+	{"Connection.PassthroughNewest", func(c *Config) any { return &c.Connection.PassthroughNewest }},
+	{"Log.File", func(c *Config) any { return &c.Log.File }},
+	{"Log.MaxSizeMB", func(c *Config) any { return &c.Log.MaxSizeMB }},
+	{"Log.RotateInterval", func(c *Config) any { return &c.Log.RotateInterval }},
+	{"Log.MaxBackups", func(c *Config) any { return &c.Log.MaxBackups }},
+	{"Log.Level", func(c *Config) any { return &c.Log.Level }},
+	{"Log.JSON", func(c *Config) any { return &c.Log.JSON }},
+	{"Log.CaptureFile", func(c *Config) any { return &c.Log.CaptureFile }},
+	{"Log.MismatchReport", func(c *Config) any { return &c.Log.MismatchReport }},
+	{"Accounts", func(c *Config) any { return &c.Accounts }},
+	{"Network.Listener", func(c *Config) any { return &c.Network.Listener }},
+	{"Link", func(c *Config) any { return &c.Link }},
+	{"Guest.Address", func(c *Config) any { return &c.Guest.Address }},
+	{"Listeners", func(c *Config) any { return &c.Listeners }},
+	{"Identity", func(c *Config) any { return &c.Identity }},
+	{"Metrics", func(c *Config) any { return &c.Metrics }},
+	{"Status.Interval", func(c *Config) any { return &c.Status.Interval }},
+	{"Discord", func(c *Config) any { return &c.Discord }},
+	{"Bans.File", func(c *Config) any { return &c.Bans.File }},
+	{"Bans.Address", func(c *Config) any { return &c.Bans.Address }},
+	{"Bans.Secret", func(c *Config) any { return &c.Bans.Secret }},
+	{"Bans.Sync", func(c *Config) any { return &c.Bans.Sync }},
+	{"Admin", func(c *Config) any { return &c.Admin }},
+	{"Cache", func(c *Config) any { return &c.Cache }},
+	{"Lang", func(c *Config) any { return &c.Lang }},
+	{"Remote", func(c *Config) any { return &c.Remote }},
+	{"Discovery", func(c *Config) any { return &c.Discovery }},
+	{"Telemetry", func(c *Config) any { return &c.Telemetry }},
+}
+
+// keepRestartSettings sets the settings of c that only apply once the proxy is restarted back to those of the config
+// the proxy was started with, so that the config running reflects what is in effect. The names of the settings that
+// were changed are returned.
+func keepRestartSettings(c *Config, start Config) []string {
+	var changed []string
+	for _, s := range restartSettings {
+		v, prev := reflect.ValueOf(s.field(c)).Elem(), reflect.ValueOf(s.field(&start)).Elem()
+		if !reflect.DeepEqual(v.Interface(), prev.Interface()) {
+			changed = append(changed, s.name)
+			v.Set(prev)
+		}
+	}
+	// Backends may be reloaded, but the resource packs offered for them are loaded once on start, so backends keep
+	// the ResourcePacks they were started with and backends added have none.
+	packsChanged := false
+	c.Backends = append([]proxy.Backend(nil), c.Backends...)
+	for i, b := range c.Backends {
+		var prev []string
+		for _, started := range start.Backends {
+			if strings.EqualFold(started.Name, b.Name) {
+				prev = started.ResourcePacks
+				break
+			}
+		}
+		if !reflect.DeepEqual(b.ResourcePacks, prev) && (len(b.ResourcePacks) > 0 || len(prev) > 0) {
+			packsChanged = true
+		}
+		c.Backends[i].ResourcePacks = prev
+	}
+	if packsChanged {
+		changed = append(changed, "Backends.ResourcePacks")
+	}
+	return changed
+}
+
+// Reload applies the config passed without disconnecting players: the backends, including the one that players join
+// first, the player limits, the status shown in the server list, the fallback, limbo, permissions, blocked commands,
+// cooldowns, server settings, sidebar, decode policy and the network settings applied to sessions. If Remote is set,
+// the remote config obtained last is applied over the config passed. Changes to settings in restartSettings only apply
+// once the proxy is restarted, so a warning is logged for them instead. The config is checked using CheckConfig before
+// any of it is applied, so an error is returned and nothing changes if it is invalid.
+func (p *Proxy) Reload(c Config) error {
+	p.mu.Lock()
+	doc := p.remote
+	p.mu.Unlock()
+	return p.reload(c, doc)
+}
+
+// reload applies the local config passed with the remote config passed applied over it, as described in Reload.
+func (p *Proxy) reload(local Config, doc remoteconfig.Document) error {
+	c, err := p.withRemote(local, doc)
+	if err != nil {
+		return err
+	}
+	if err := checkErr(CheckConfig(c)); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	for _, name := range requiredAccounts(c) {
+		// XBL tokens are only obtained on start for the accounts that backends require.
+		if draco.AccountTokenSource(name) == nil {
+			return fmt.Errorf("backends with XBOX Live account %q can only be added once the proxy is restarted", name)
+		}
+	}
+	if changed := keepRestartSettings(&c, p.started); len(changed) > 0 {
+		logging.Default().Warn("settings changed that only apply once the proxy is restarted", "settings", strings.Join(changed, ","))
+	}
+	proxy.UnblockCommands()
+	if err := p.applyConfig(c); err != nil {
+		return err
+	}
+	p.status.SetProviders(statusProviders(c)...)
+	p.running, p.local, p.remote = c, local, doc
+	return nil
+}
+
+// SetConfigSource sets the function that the config is obtained from when it is reloaded through the admin API,
+// such as a function reading config.toml. Reloading through the admin API fails if no source is set.
+func (p *Proxy) SetConfigSource(f func() (Config, error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.source = f
+}
+
+// reloadFromSource obtains the config from the source set using SetConfigSource and reloads it.
+func (p *Proxy) reloadFromSource() error {
+	p.mu.Lock()
+	source := p.source
+	p.mu.Unlock()
+	if source == nil {
+		return errors.New("no config source set")
+	}
+	c, err := source()
+	if err != nil {
+		return err
+	}
+	return p.Reload(c)
+}
@@ -0,0 +1,114 @@
+package draco
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cqdetdev/draco/draco/proxy"
+)
+
+func TestKeepRestartSettings(t *testing.T) {
+	start, err := DecodeConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := start
+	c.Connection.LocalAddress = "0.0.0.0:19133"
+	c.Admin.Secret = "new"
+	c.Connection.MaxPlayers = 50
+	c.Backends = []proxy.Backend{{Name: "lobby", Address: "127.0.0.1:19134"}}
+
+	changed := keepRestartSettings(&c, start)
+	if expected := []string{"Connection.LocalAddress", "Admin"}; !reflect.DeepEqual(changed, expected) {
+		t.Errorf("changed settings are %v, expected %v", changed, expected)
+	}
+	if c.Connection.LocalAddress != start.Connection.LocalAddress || c.Admin.Secret != start.Admin.Secret {
+		t.Error("settings that only apply on restart were not kept")
+	}
+	if c.Connection.MaxPlayers != 50 || c.Backends[0].Name != "lobby" {
+		t.Error("settings that may be reloaded were reverted")
+	}
+
+	c.Backends[0].ResourcePacks = []string{"packs/lobby"}
+	if changed := keepRestartSettings(&c, start); !reflect.DeepEqual(changed, []string{"Backends.ResourcePacks"}) {
+		t.Errorf("changed settings are %v, expected the resource packs of backends", changed)
+	}
+	if len(c.Backends[0].ResourcePacks) != 0 {
+		t.Errorf("resource packs of backend reloaded as %v, expected the packs it was started with", c.Backends[0].ResourcePacks)
+	}
+	c.Backends = append(c.Backends, proxy.Backend{Name: "skywars", Address: "127.0.0.1:19135", ResourcePacks: []string{"packs/skywars"}})
+	if changed := keepRestartSettings(&c, start); !reflect.DeepEqual(changed, []string{"Backends.ResourcePacks"}) || c.Backends[1].ResourcePacks != nil {
+		t.Errorf("changed settings are %v and packs of added backend %v, expected the packs to be removed", changed, c.Backends[1].ResourcePacks)
+	}
+}
+
+func TestOfflineConfig(t *testing.T) {
+	c, err := DecodeConfig([]byte(`
+[Connection]
+Offline = true
+
+[[Backends]]
+Name = "lobby"
+Address = "127.0.0.1:19134"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Backends[0].Offline {
+		t.Error("expected backends to be offline in offline mode")
+	}
+	if accounts := requiredAccounts(c); len(accounts) != 0 {
+		t.Errorf("expected no accounts to be required in offline mode, got %v", accounts)
+	}
+}
+
+func TestOverlayConfig(t *testing.T) {
+	local, err := DecodeConfig([]byte(`
+[Connection]
+LocalAddress = "0.0.0.0:19133"
+MaxPlayers = 20
+
+[Forwarding]
+Node = "eu-1"
+
+[Remote]
+Kind = "http"
+URL = "https://config.example.com/draco.toml"
+
+[[Backends]]
+Name = "lobby"
+Address = "127.0.0.1:19134"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := overlayConfig(local, []byte(`
+[Connection]
+MaxPlayers = 100
+
+[Remote]
+Kind = "consul"
+
+[[Backends]]
+Name = "hub"
+Address = "10.0.0.2:19132"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Connection.MaxPlayers != 100 || len(c.Backends) != 1 || c.Backends[0].Name != "hub" {
+		t.Errorf("settings of the remote config were not applied: max players %v, backends %v", c.Connection.MaxPlayers, c.Backends)
+	}
+	if c.Connection.LocalAddress != "0.0.0.0:19133" || c.Forwarding.Node != "eu-1" {
+		t.Error("settings missing from the remote config were not kept")
+	}
+	if c.Remote.Kind != "http" {
+		t.Error("the remote settings were changed by the remote config")
+	}
+	if local.Backends[0].Name != "lobby" {
+		t.Error("the local config was modified")
+	}
+	if _, err := overlayConfig(local, []byte("[Connection")); err == nil {
+		t.Error("expected an invalid remote config to fail")
+	}
+}
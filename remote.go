@@ -0,0 +1,112 @@
+package draco
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cqdetdev/draco/draco/logging"
+	"github.com/cqdetdev/draco/draco/remoteconfig"
+	"github.com/pelletier/go-toml"
+)
+
+// remoteSource returns the remoteconfig.Source configured in Remote of the config passed.
+func remoteSource(c Config) (remoteconfig.Source, error) {
+	src := remoteconfig.Source{Kind: c.Remote.Kind, URL: c.Remote.URL, Key: c.Remote.Key, Token: c.Remote.Token, Interval: 30 * time.Second}
+	if c.Remote.Interval != "" {
+		d, err := time.ParseDuration(c.Remote.Interval)
+		if err != nil {
+			return remoteconfig.Source{}, fmt.Errorf("parse interval: %w", err)
+		}
+		src.Interval = d
+	}
+	return src, src.Validate()
+}
+
+// remoteCacheFile returns the file that the remote config of the config passed is cached in.
+func remoteCacheFile(c Config) string {
+	if c.Remote.CacheFile == "" {
+		return "config.remote.toml"
+	}
+	return c.Remote.CacheFile
+}
+
+// overlayConfig returns the config passed with the settings in the remote config passed, which is encoded like
+// config.toml, applied over it. The Remote settings are kept as they are in the config passed.
+func overlayConfig(c Config, data []byte) (Config, error) {
+	// The config is encoded and decoded again rather than decoding the remote config over it directly, so that the
+	// slices of the config passed are not modified.
+	local, err := toml.Marshal(c)
+	if err != nil {
+		return c, err
+	}
+	var merged Config
+	if err := toml.Unmarshal(local, &merged); err != nil {
+		return c, err
+	}
+	if err := toml.Unmarshal(data, &merged); err != nil {
+		return c, fmt.Errorf("decode remote config: %w", err)
+	}
+	merged.Remote = c.Remote
+	fillDefaults(&merged)
+	return merged, nil
+}
+
+// withRemote returns the config passed with the remote config passed applied over it, if the Proxy obtains its config
+// from a remote endpoint.
+func (p *Proxy) withRemote(c Config, doc remoteconfig.Document) (Config, error) {
+	if p.started.Remote.Kind == "" || doc.Data == nil {
+		return c, nil
+	}
+	return overlayConfig(c, doc.Data)
+}
+
+// watchRemote watches the remote endpoint in the config passed for changes to the remote config, starting from the
+// version passed, and reloads the Proxy with every change until it is closed.
+func (p *Proxy) watchRemote(c Config, version string) {
+	src, err := remoteSource(c)
+	if err != nil {
+		// The source was checked before the Proxy was created.
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stopRemote = cancel
+	go src.Watch(ctx, version, remoteCacheFile(c), func(doc remoteconfig.Document) {
+		p.mu.Lock()
+		local := p.local
+		p.mu.Unlock()
+		if err := p.reload(local, doc); err != nil {
+			logging.Default().Error("error applying remote config", "source", src.URL, "err", err)
+			return
+		}
+		logging.Default().Info("remote config applied", "source", src.URL, "version", doc.Version)
+	})
+}
+
+// loadRemoteConfig returns the config passed with the config obtained from the remote endpoint configured in it
+// applied over it, together with the remote config. If caching is true, the remote config is stored in its cache
+// file, and loaded from it if the endpoint can't be reached. The config passed is returned as it is if no endpoint
+// is configured.
+func loadRemoteConfig(c Config, caching bool) (Config, remoteconfig.Document, error) {
+	if c.Remote.Kind == "" {
+		return c, remoteconfig.Document{}, nil
+	}
+	src, err := remoteSource(c)
+	if err != nil {
+		return c, remoteconfig.Document{}, err
+	}
+	var doc remoteconfig.Document
+	if caching {
+		doc, err = src.Load(context.Background(), remoteCacheFile(c))
+	} else {
+		doc, _, err = src.Fetch(context.Background(), "")
+	}
+	if err != nil {
+		return c, remoteconfig.Document{}, err
+	}
+	merged, err := overlayConfig(c, doc.Data)
+	if err != nil {
+		return c, remoteconfig.Document{}, err
+	}
+	return merged, doc, nil
+}